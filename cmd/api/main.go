@@ -8,14 +8,17 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Dunning policies name time zones; the runtime image has no zoneinfo
 
 	billing_http "erp-billing-service/internal/adapters/inbound/http"
 	"erp-billing-service/internal/adapters/inbound/kafka"
+	"erp-billing-service/internal/adapters/outbound/gateway"
 	kafka_outbound "erp-billing-service/internal/adapters/outbound/kafka"
 	"erp-billing-service/internal/adapters/outbound/postgres"
 	"erp-billing-service/internal/application"
 	"erp-billing-service/internal/config"
 	"erp-billing-service/internal/database"
+	"erp-billing-service/internal/ports/external"
 
 	shared_kafka "github.com/efs/shared-kafka"
 	"github.com/gorilla/mux"
@@ -52,16 +55,51 @@ func main() {
 	paymentRepo := postgres.NewPaymentRepository(db)
 	auditRepo := postgres.NewAuditLogRepository(db)
 	rmRepo := postgres.NewReadModelRepository(db)
+	noteRepo := postgres.NewBillingNoteRepository(db)
+	recurringRepo := postgres.NewRecurringProfileRepository(db)
+	taxRepo := postgres.NewTaxRepository(db)
+	seqRepo := postgres.NewNumberSequenceRepository(db)
+	creditRepo := postgres.NewCustomerCreditRepository(db)
+	statementRepo := postgres.NewBankStatementRepository(db)
+	directDebitRepo := postgres.NewDirectDebitRepository(db)
+	dunningRepo := postgres.NewDunningRepository(db)
+	lateFeeRepo := postgres.NewLateFeeRepository(db)
+	termRepo := postgres.NewPaymentTermRepository(db)
+	estimateRepo := postgres.NewEstimateRepository(db)
+	progressRepo := postgres.NewProgressBillingRepository(db)
+	consolidationRepo := postgres.NewConsolidationRepository(db)
+	subscriptionRepo := postgres.NewSubscriptionRepository(db)
 	eventPublisher := kafka_outbound.NewEventPublisher(producer)
 
+	var paymentGateway external.PaymentGateway
+	switch cfg.GatewayProvider {
+	case gateway.MockProvider:
+		paymentGateway = gateway.NewMockGateway(cfg.GatewaySecret)
+	default:
+		log.Fatalf("Unknown payment gateway provider %q", cfg.GatewayProvider)
+	}
+
 	// 6. Initialize Services
-	invoiceService := application.NewInvoiceService(invoiceRepo, rmRepo, auditRepo, eventPublisher)
-	paymentService := application.NewPaymentService(paymentRepo, invoiceRepo, eventPublisher)
-	_ = paymentService // Reserved for future payment endpoints
+	paymentService := application.NewPaymentService(paymentRepo, invoiceRepo, rmRepo, creditRepo, auditRepo, eventPublisher)
+	invoiceService := application.NewInvoiceService(invoiceRepo, rmRepo, taxRepo, termRepo, progressRepo, consolidationRepo, paymentService, auditRepo, eventPublisher)
+	noteService := application.NewBillingNoteService(noteRepo, auditRepo, eventPublisher)
+	recurringService := application.NewRecurringInvoiceService(recurringRepo, invoiceService)
+	taxService := application.NewTaxService(taxRepo)
+	numberingService := application.NewNumberingService(seqRepo)
+	gatewayService := application.NewGatewayService(paymentGateway, paymentService, invoiceRepo, paymentRepo)
+	statementService := application.NewBankStatementService(statementRepo, invoiceRepo, rmRepo, paymentService)
+	directDebitService := application.NewDirectDebitService(directDebitRepo, auditRepo, paymentService)
+	dunningService := application.NewDunningService(dunningRepo, invoiceRepo, auditRepo, eventPublisher)
+	termService := application.NewPaymentTermService(termRepo)
+	lateFeeService := application.NewLateFeeService(lateFeeRepo, dunningRepo, invoiceRepo, invoiceService, auditRepo, eventPublisher)
+	estimateService := application.NewEstimateService(estimateRepo, invoiceService, auditRepo, eventPublisher)
+	progressService := application.NewProgressBillingService(progressRepo, estimateRepo, invoiceService, auditRepo)
+	consolidationService := application.NewConsolidationService(consolidationRepo, invoiceService, auditRepo, eventPublisher)
+	subscriptionService := application.NewSubscriptionService(subscriptionRepo, invoiceService, auditRepo)
 
 	// 7. Initialize Kafka Consumers
 	eventHandler := kafka.NewEventHandler(db)
-	topics := []string{"crm.customers", "crm.contacts", "crm.addresses", "inventory.services", "inventory.parts"}
+	topics := []string{"crm.customers", "crm.contacts", "crm.addresses", "inventory.services", "inventory.parts", "operations.work-orders", "billing.usage"}
 	consumerGroup, err := shared_kafka.NewConsumerGroup(kafkaCfg, "billing-service-group", topics, eventHandler, nil)
 	if err != nil {
 		log.Fatalf("Failed to initialize Kafka consumer: %v", err)
@@ -69,8 +107,31 @@ func main() {
 	consumerGroup.Start()
 	defer consumerGroup.Stop()
 
-	// 8. Initialize HTTP Handlers
+	// 8. Start Background Schedulers
+	recurringScheduler := application.NewRecurringScheduler(recurringService, subscriptionService, cfg.RecurringInterval)
+	recurringScheduler.Start()
+	defer recurringScheduler.Stop()
+	dunningScheduler := application.NewDunningScheduler(dunningService, lateFeeService, cfg.DunningInterval)
+	dunningScheduler.Start()
+	defer dunningScheduler.Stop()
+
+	// 9. Initialize HTTP Handlers
 	invoiceHandler := billing_http.NewInvoiceHandler(invoiceService)
+	paymentHandler := billing_http.NewPaymentHandler(paymentService)
+	noteHandler := billing_http.NewBillingNoteHandler(noteService)
+	recurringHandler := billing_http.NewRecurringInvoiceHandler(recurringService)
+	taxHandler := billing_http.NewTaxHandler(taxService)
+	numberingHandler := billing_http.NewNumberingHandler(numberingService)
+	gatewayHandler := billing_http.NewGatewayHandler(gatewayService)
+	statementHandler := billing_http.NewBankStatementHandler(statementService)
+	directDebitHandler := billing_http.NewDirectDebitHandler(directDebitService)
+	dunningHandler := billing_http.NewDunningHandler(dunningService)
+	lateFeeHandler := billing_http.NewLateFeeHandler(lateFeeService)
+	termHandler := billing_http.NewPaymentTermHandler(termService)
+	estimateHandler := billing_http.NewEstimateHandler(estimateService)
+	progressHandler := billing_http.NewProgressBillingHandler(progressService)
+	consolidationHandler := billing_http.NewConsolidationHandler(consolidationService)
+	subscriptionHandler := billing_http.NewSubscriptionHandler(subscriptionService)
 	rmHandler := billing_http.NewReadModelHandler(rmRepo)
 
 	router := mux.NewRouter()
@@ -84,6 +145,139 @@ func main() {
 	api.HandleFunc("/billing/invoices/{id}", invoiceHandler.DeleteInvoice).Methods("DELETE")
 	api.HandleFunc("/billing/invoices/{id}/status", invoiceHandler.UpdateStatus).Methods("PATCH")
 	api.HandleFunc("/billing/invoices/{id}/audit-logs", invoiceHandler.GetAuditLogs).Methods("GET")
+	api.HandleFunc("/billing/invoices/{id}/installments", invoiceHandler.SetInstallments).Methods("PUT")
+	api.HandleFunc("/billing/reports/aging", invoiceHandler.AgingReport).Methods("GET")
+	api.HandleFunc("/billing/work-orders/{id}/invoice", invoiceHandler.CreateFromWorkOrder).Methods("POST")
+
+	// Payment Routes
+	api.HandleFunc("/billing/invoices/{id}/payments", paymentHandler.RecordPayment).Methods("POST")
+	api.HandleFunc("/billing/invoices/{id}/payments", paymentHandler.ListInvoicePayments).Methods("GET")
+	api.HandleFunc("/billing/payments", paymentHandler.RecordCustomerPayment).Methods("POST")
+	api.HandleFunc("/billing/payments", paymentHandler.ListPayments).Methods("GET")
+	api.HandleFunc("/billing/payments/{id}/allocations", paymentHandler.ReallocatePayment).Methods("PUT")
+	api.HandleFunc("/billing/payments/{id}/receipt", paymentHandler.GetReceipt).Methods("GET")
+	api.HandleFunc("/billing/payments/{id}/refunds", paymentHandler.RefundPayment).Methods("POST")
+	api.HandleFunc("/billing/payments/{id}/refunds", paymentHandler.ListRefunds).Methods("GET")
+	api.HandleFunc("/billing/payments/{id}/reversal", paymentHandler.ReversePayment).Methods("POST")
+
+	// Payment Gateway Routes
+	api.HandleFunc("/billing/invoices/{id}/payment-intents", gatewayHandler.CreatePaymentIntent).Methods("POST")
+	api.HandleFunc("/billing/payment-intents/{id}/capture", gatewayHandler.CapturePaymentIntent).Methods("POST")
+	api.HandleFunc("/billing/payments/{id}/gateway-refunds", gatewayHandler.RefundPayment).Methods("POST")
+	api.HandleFunc("/billing/gateway/webhooks", gatewayHandler.HandleWebhook).Methods("POST")
+
+	// Bank Statement Routes
+	api.HandleFunc("/billing/bank-statements", statementHandler.ImportStatement).Methods("POST")
+	api.HandleFunc("/billing/bank-statements", statementHandler.ListStatements).Methods("GET")
+	api.HandleFunc("/billing/bank-statements/{id}", statementHandler.GetStatement).Methods("GET")
+	api.HandleFunc("/billing/bank-statement-lines", statementHandler.ListReviewQueue).Methods("GET")
+	api.HandleFunc("/billing/bank-statement-lines/{id}/confirm", statementHandler.ConfirmLine).Methods("POST")
+	api.HandleFunc("/billing/bank-statement-lines/{id}/ignore", statementHandler.IgnoreLine).Methods("POST")
+
+	// Direct Debit Routes
+	api.HandleFunc("/billing/direct-debit/creditors/{scheme}", directDebitHandler.SaveCreditor).Methods("PUT")
+	api.HandleFunc("/billing/direct-debit/creditors/{scheme}", directDebitHandler.GetCreditor).Methods("GET")
+	api.HandleFunc("/billing/customers/{id}/mandates", directDebitHandler.CreateMandate).Methods("POST")
+	api.HandleFunc("/billing/customers/{id}/mandates", directDebitHandler.ListMandates).Methods("GET")
+	api.HandleFunc("/billing/mandates/{id}/revoke", directDebitHandler.RevokeMandate).Methods("POST")
+	api.HandleFunc("/billing/direct-debit/batches", directDebitHandler.CreateBatch).Methods("POST")
+	api.HandleFunc("/billing/direct-debit/batches", directDebitHandler.ListBatches).Methods("GET")
+	api.HandleFunc("/billing/direct-debit/batches/{id}", directDebitHandler.GetBatch).Methods("GET")
+	api.HandleFunc("/billing/direct-debit/batches/{id}/file", directDebitHandler.DownloadBatchFile).Methods("GET")
+	api.HandleFunc("/billing/direct-debit/batches/{id}/settle", directDebitHandler.SettleBatch).Methods("POST")
+	api.HandleFunc("/billing/direct-debit/returns", directDebitHandler.ProcessReturns).Methods("POST")
+
+	// Dunning Routes
+	api.HandleFunc("/billing/dunning/policy", dunningHandler.GetPolicy).Methods("GET")
+	api.HandleFunc("/billing/dunning/policy", dunningHandler.SavePolicy).Methods("PUT")
+	api.HandleFunc("/billing/dunning/run", dunningHandler.Run).Methods("POST")
+	api.HandleFunc("/billing/invoices/{id}/reminders", dunningHandler.ListReminders).Methods("GET")
+
+	// Late Fee Routes
+	api.HandleFunc("/billing/late-fees/policy", lateFeeHandler.GetPolicy).Methods("GET")
+	api.HandleFunc("/billing/late-fees/policy", lateFeeHandler.SavePolicy).Methods("PUT")
+	api.HandleFunc("/billing/late-fees/run", lateFeeHandler.Run).Methods("POST")
+	api.HandleFunc("/billing/invoices/{id}/late-fees", lateFeeHandler.ListCharges).Methods("GET")
+
+	// Payment Term Routes
+	api.HandleFunc("/billing/payment-terms", termHandler.CreateTerm).Methods("POST")
+	api.HandleFunc("/billing/payment-terms", termHandler.ListTerms).Methods("GET")
+	api.HandleFunc("/billing/payment-terms/standard", termHandler.AddStandardTerms).Methods("POST")
+	api.HandleFunc("/billing/payment-terms/{id}", termHandler.GetTerm).Methods("GET")
+	api.HandleFunc("/billing/payment-terms/{id}", termHandler.UpdateTerm).Methods("PUT")
+	api.HandleFunc("/billing/payment-terms/{id}", termHandler.DeleteTerm).Methods("DELETE")
+	api.HandleFunc("/billing/customers/{id}/payment-term", termHandler.GetCustomerTerm).Methods("GET")
+	api.HandleFunc("/billing/customers/{id}/payment-term", termHandler.AssignCustomerTerm).Methods("PUT")
+	api.HandleFunc("/billing/customers/{id}/payment-term", termHandler.UnassignCustomerTerm).Methods("DELETE")
+
+	// Estimate Routes
+	api.HandleFunc("/billing/estimates", estimateHandler.CreateEstimate).Methods("POST")
+	api.HandleFunc("/billing/estimates", estimateHandler.ListEstimates).Methods("GET")
+	api.HandleFunc("/billing/estimates/{id}", estimateHandler.GetEstimate).Methods("GET")
+	api.HandleFunc("/billing/estimates/{id}", estimateHandler.UpdateEstimate).Methods("PUT")
+	api.HandleFunc("/billing/estimates/{id}/status", estimateHandler.UpdateStatus).Methods("PUT")
+	api.HandleFunc("/billing/estimates/{id}/convert", estimateHandler.ConvertToInvoice).Methods("POST")
+
+	// Deposit and Progress Billing Routes
+	api.HandleFunc("/billing/deposits", progressHandler.CreateDeposit).Methods("POST")
+	api.HandleFunc("/billing/customers/{id}/deposits", progressHandler.ListCustomerDeposits).Methods("GET")
+	api.HandleFunc("/billing/work-orders/{id}/progress-invoices", progressHandler.CreateWorkOrderProgressInvoice).Methods("POST")
+	api.HandleFunc("/billing/work-orders/{id}/progress", progressHandler.GetWorkOrderProgress).Methods("GET")
+	api.HandleFunc("/billing/estimates/{id}/progress-invoices", progressHandler.CreateEstimateProgressInvoice).Methods("POST")
+	api.HandleFunc("/billing/estimates/{id}/progress", progressHandler.GetEstimateProgress).Methods("GET")
+
+	// Consolidated Invoicing Routes
+	api.HandleFunc("/billing/consolidations", consolidationHandler.Consolidate).Methods("POST")
+	api.HandleFunc("/billing/consolidations", consolidationHandler.ListRuns).Methods("GET")
+
+	// Subscription Routes
+	api.HandleFunc("/billing/plans", subscriptionHandler.CreatePlan).Methods("POST")
+	api.HandleFunc("/billing/plans", subscriptionHandler.ListPlans).Methods("GET")
+	api.HandleFunc("/billing/plans/{id}", subscriptionHandler.GetPlan).Methods("GET")
+	api.HandleFunc("/billing/plans/{id}", subscriptionHandler.UpdatePlan).Methods("PUT")
+	api.HandleFunc("/billing/subscriptions", subscriptionHandler.CreateSubscription).Methods("POST")
+	api.HandleFunc("/billing/subscriptions", subscriptionHandler.ListSubscriptions).Methods("GET")
+	api.HandleFunc("/billing/subscriptions/{id}", subscriptionHandler.GetSubscription).Methods("GET")
+	api.HandleFunc("/billing/subscriptions/{id}/plan", subscriptionHandler.ChangePlan).Methods("PUT")
+	api.HandleFunc("/billing/subscriptions/{id}/cancel", subscriptionHandler.Cancel).Methods("POST")
+	api.HandleFunc("/billing/subscriptions/{id}/usage", subscriptionHandler.RecordUsage).Methods("POST")
+	api.HandleFunc("/billing/subscriptions/{id}/usage", subscriptionHandler.ListUsage).Methods("GET")
+	api.HandleFunc("/billing/subscriptions/{id}/close", subscriptionHandler.ClosePeriod).Methods("POST")
+	api.HandleFunc("/billing/subscriptions/{id}/runs", subscriptionHandler.ListRuns).Methods("GET")
+
+	// Customer Credit Routes
+	api.HandleFunc("/billing/customers/{id}/credit", paymentHandler.GetCustomerCredit).Methods("GET")
+	api.HandleFunc("/billing/customers/{id}/credit/apply", paymentHandler.ApplyCredit).Methods("POST")
+	api.HandleFunc("/billing/customers/{id}/credit/refunds", paymentHandler.RefundCredit).Methods("POST")
+
+	// Credit / Debit Note Routes
+	api.HandleFunc("/billing/invoices/{id}/credit-notes", noteHandler.CreateCreditNote).Methods("POST")
+	api.HandleFunc("/billing/invoices/{id}/debit-notes", noteHandler.CreateDebitNote).Methods("POST")
+	api.HandleFunc("/billing/invoices/{id}/notes", noteHandler.ListInvoiceNotes).Methods("GET")
+	api.HandleFunc("/billing/notes", noteHandler.ListNotes).Methods("GET")
+	api.HandleFunc("/billing/notes/{id}", noteHandler.GetNote).Methods("GET")
+
+	// Recurring Invoice Routes
+	api.HandleFunc("/billing/recurring-profiles", recurringHandler.CreateProfile).Methods("POST")
+	api.HandleFunc("/billing/recurring-profiles", recurringHandler.ListProfiles).Methods("GET")
+	api.HandleFunc("/billing/recurring-profiles/{id}", recurringHandler.GetProfile).Methods("GET")
+	api.HandleFunc("/billing/recurring-profiles/{id}", recurringHandler.UpdateProfile).Methods("PUT")
+	api.HandleFunc("/billing/recurring-profiles/{id}/status", recurringHandler.UpdateProfileStatus).Methods("PATCH")
+	api.HandleFunc("/billing/recurring-profiles/{id}/runs", recurringHandler.ListRuns).Methods("GET")
+
+	// Tax Routes
+	api.HandleFunc("/billing/tax-rates", taxHandler.CreateRate).Methods("POST")
+	api.HandleFunc("/billing/tax-rates", taxHandler.ListRates).Methods("GET")
+	api.HandleFunc("/billing/tax-rates/{id}", taxHandler.GetRate).Methods("GET")
+	api.HandleFunc("/billing/tax-rates/{id}", taxHandler.UpdateRate).Methods("PUT")
+	api.HandleFunc("/billing/tax-groups", taxHandler.CreateGroup).Methods("POST")
+	api.HandleFunc("/billing/tax-groups", taxHandler.ListGroups).Methods("GET")
+	api.HandleFunc("/billing/tax-groups/{id}", taxHandler.GetGroup).Methods("GET")
+	api.HandleFunc("/billing/tax-groups/{id}", taxHandler.UpdateGroup).Methods("PUT")
+
+	// Document Numbering Routes
+	api.HandleFunc("/billing/number-sequences", numberingHandler.ListSequences).Methods("GET")
+	api.HandleFunc("/billing/number-sequences/{type}", numberingHandler.UpdateSequence).Methods("PUT")
 
 	// Read Model Search Routes (for UI Autocomplete)
 	api.HandleFunc("/billing/search/customers", rmHandler.SearchCustomers).Methods("GET")
@@ -101,7 +295,7 @@ func main() {
 		Handler: router,
 	}
 
-	// 9. Start Server
+	// 10. Start Server
 	go func() {
 		log.Printf("Starting HTTP server on port %s", cfg.HTTPPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -109,7 +303,7 @@ func main() {
 		}
 	}()
 
-	// 10. Graceful Shutdown
+	// 11. Graceful Shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
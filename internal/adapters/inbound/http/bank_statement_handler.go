@@ -0,0 +1,224 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxStatementBytes bounds the size of an uploaded statement file
+const maxStatementBytes = 10 << 20
+
+type BankStatementHandler struct {
+	service *application.BankStatementService
+}
+
+func NewBankStatementHandler(service *application.BankStatementService) *BankStatementHandler {
+	return &BankStatementHandler{service: service}
+}
+
+// ImportStatement accepts a multipart upload with the file in the "file"
+// field and optional "format" and "auto_apply" fields
+func (h *BankStatementHandler) ImportStatement(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxStatementBytes)
+	if err := r.ParseMultipartForm(maxStatementBytes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing statement file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := dto.ImportStatementRequest{
+		Format:   r.FormValue("format"),
+		FileName: header.Filename,
+	}
+	if v := r.FormValue("auto_apply"); v != "" {
+		if req.AutoApply, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid auto_apply", http.StatusBadRequest)
+			return
+		}
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	statement, err := h.service.ImportStatement(r.Context(), orgID, req, data, performedBy)
+	if err != nil {
+		writeStatementError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(statement)
+}
+
+func (h *BankStatementHandler) ListStatements(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	statements, err := h.service.ListStatements(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": statements,
+	})
+}
+
+func (h *BankStatementHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Statement ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	statement, err := h.service.GetStatement(r.Context(), orgID, id)
+	if err != nil {
+		writeStatementError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statement)
+}
+
+// ListReviewQueue lists the lines waiting for review, or those with the
+// status given in the query
+func (h *BankStatementHandler) ListReviewQueue(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	lines, err := h.service.ListReviewQueue(r.Context(), orgID, r.URL.Query().Get("status"))
+	if err != nil {
+		writeStatementError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": lines,
+	})
+}
+
+func (h *BankStatementHandler) ConfirmLine(w http.ResponseWriter, r *http.Request) {
+	lineID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Statement Line ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.ConfirmStatementLineRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	line, err := h.service.ConfirmStatementLine(r.Context(), orgID, lineID, req, performedBy)
+	if err != nil {
+		writeStatementError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(line)
+}
+
+func (h *BankStatementHandler) IgnoreLine(w http.ResponseWriter, r *http.Request) {
+	lineID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Statement Line ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.IgnoreStatementLineRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	line, err := h.service.IgnoreStatementLine(r.Context(), orgID, lineID, req, performedBy)
+	if err != nil {
+		writeStatementError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(line)
+}
+
+func writeStatementError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrStatementNotFound), errors.Is(err, domain.ErrStatementLineNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		writePaymentError(w, err)
+	}
+}
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type BillingNoteHandler struct {
+	service *application.BillingNoteService
+}
+
+func NewBillingNoteHandler(service *application.BillingNoteService) *BillingNoteHandler {
+	return &BillingNoteHandler{service: service}
+}
+
+func (h *BillingNoteHandler) CreateCreditNote(w http.ResponseWriter, r *http.Request) {
+	h.createNote(w, r, h.service.IssueCreditNote)
+}
+
+func (h *BillingNoteHandler) CreateDebitNote(w http.ResponseWriter, r *http.Request) {
+	h.createNote(w, r, h.service.IssueDebitNote)
+}
+
+type issueNoteFunc func(ctx context.Context, invoiceID uuid.UUID, req dto.CreateBillingNoteRequest, performedBy string) (*dto.BillingNoteResponse, error)
+
+func (h *BillingNoteHandler) createNote(w http.ResponseWriter, r *http.Request, issue issueNoteFunc) {
+	vars := mux.Vars(r)
+	invoiceID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateBillingNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// In a real app, performedBy would come from auth context
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	note, err := issue(r.Context(), invoiceID, req, performedBy)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvoiceNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrInvoiceNotIssued), errors.Is(err, domain.ErrInvalidStatusTransition):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+func (h *BillingNoteHandler) ListInvoiceNotes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	invoiceID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	notes, err := h.service.ListInvoiceNotes(r.Context(), invoiceID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": notes,
+	})
+}
+
+func (h *BillingNoteHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := r.Header.Get("X-Organization-ID")
+	orgID, _ := uuid.Parse(orgIDStr)
+
+	notes, err := h.service.ListNotes(r.Context(), orgID, r.URL.Query().Get("type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": notes,
+	})
+}
+
+func (h *BillingNoteHandler) GetNote(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Note ID", http.StatusBadRequest)
+		return
+	}
+
+	note, err := h.service.GetNote(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(note)
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+
+	"github.com/google/uuid"
+)
+
+type ConsolidationHandler struct {
+	service *application.ConsolidationService
+}
+
+func NewConsolidationHandler(service *application.ConsolidationService) *ConsolidationHandler {
+	return &ConsolidationHandler{service: service}
+}
+
+// Consolidate merges a period's unbilled work orders and draft invoices into
+// one invoice per customer. Rerunning a period returns the runs already made.
+func (h *ConsolidationHandler) Consolidate(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.ConsolidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	res, err := h.service.Consolidate(r.Context(), orgID, req, performedBy)
+	if err != nil {
+		writeProgressBillingError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *ConsolidationHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.service.ListRuns(r.Context(), orgID)
+	if err != nil {
+		writeProgressBillingError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": runs,
+	})
+}
@@ -0,0 +1,326 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type DirectDebitHandler struct {
+	service *application.DirectDebitService
+}
+
+func NewDirectDebitHandler(service *application.DirectDebitService) *DirectDebitHandler {
+	return &DirectDebitHandler{service: service}
+}
+
+func (h *DirectDebitHandler) SaveCreditor(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SaveCreditorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	creditor, err := h.service.SaveCreditor(r.Context(), orgID, mux.Vars(r)["scheme"], req)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creditor)
+}
+
+func (h *DirectDebitHandler) GetCreditor(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	creditor, err := h.service.GetCreditor(r.Context(), orgID, mux.Vars(r)["scheme"])
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creditor)
+}
+
+func (h *DirectDebitHandler) CreateMandate(w http.ResponseWriter, r *http.Request) {
+	customerID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Customer ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateMandateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	mandate, err := h.service.CreateMandate(r.Context(), orgID, customerID, req, performedBy)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(mandate)
+}
+
+func (h *DirectDebitHandler) ListMandates(w http.ResponseWriter, r *http.Request) {
+	customerID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Customer ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	mandates, err := h.service.ListMandates(r.Context(), orgID, customerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": mandates,
+	})
+}
+
+func (h *DirectDebitHandler) RevokeMandate(w http.ResponseWriter, r *http.Request) {
+	mandateID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Mandate ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	mandate, err := h.service.RevokeMandate(r.Context(), orgID, mandateID)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mandate)
+}
+
+func (h *DirectDebitHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateDirectDebitBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	batch, err := h.service.CollectDue(r.Context(), orgID, req, performedBy)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(batch)
+}
+
+func (h *DirectDebitHandler) ListBatches(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	batches, err := h.service.ListBatches(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": batches,
+	})
+}
+
+func (h *DirectDebitHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Batch ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.service.GetBatch(r.Context(), orgID, id)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// DownloadBatchFile returns the file to upload to the bank
+func (h *DirectDebitHandler) DownloadBatchFile(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Batch ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	file, name, err := h.service.GetBatchFile(r.Context(), orgID, id)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	contentType := "text/plain"
+	if len(file) > 0 && file[0] == '<' {
+		contentType = "application/xml"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Write(file)
+}
+
+func (h *DirectDebitHandler) SettleBatch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Batch ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	batch, err := h.service.SettleBatch(r.Context(), orgID, id, performedBy)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batch)
+}
+
+// ProcessReturns accepts a multipart upload of a pain.002 status report or
+// a NACHA return file in the "file" field
+func (h *DirectDebitHandler) ProcessReturns(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxStatementBytes)
+	if err := r.ParseMultipartForm(maxStatementBytes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing return file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	summary, err := h.service.ProcessReturnFile(r.Context(), orgID, data, performedBy)
+	if err != nil {
+		writeDirectDebitError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+func writeDirectDebitError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrCreditorNotFound), errors.Is(err, domain.ErrMandateNotFound),
+		errors.Is(err, domain.ErrDirectDebitBatchNotFound), errors.Is(err, domain.ErrDirectDebitItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		writePaymentError(w, err)
+	}
+}
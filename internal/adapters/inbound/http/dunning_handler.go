@@ -0,0 +1,114 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type DunningHandler struct {
+	service *application.DunningService
+}
+
+func NewDunningHandler(service *application.DunningService) *DunningHandler {
+	return &DunningHandler{service: service}
+}
+
+func (h *DunningHandler) GetPolicy(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.service.GetPolicy(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *DunningHandler) SavePolicy(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SaveDunningPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.service.SavePolicy(r.Context(), orgID, req)
+	if err != nil {
+		writeDunningError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// Run runs dunning for the organization without waiting for the scheduler
+func (h *DunningHandler) Run(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.service.RunForOrganization(r.Context(), orgID)
+	if err != nil {
+		writeDunningError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *DunningHandler) ListReminders(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	reminders, err := h.service.ListReminders(r.Context(), orgID, invoiceID)
+	if err != nil {
+		writeDunningError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": reminders,
+	})
+}
+
+func writeDunningError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrDunningPolicyNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		writePaymentError(w, err)
+	}
+}
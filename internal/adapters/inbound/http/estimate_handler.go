@@ -0,0 +1,209 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type EstimateHandler struct {
+	service *application.EstimateService
+}
+
+func NewEstimateHandler(service *application.EstimateService) *EstimateHandler {
+	return &EstimateHandler{service: service}
+}
+
+func (h *EstimateHandler) CreateEstimate(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateEstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := h.service.CreateEstimate(r.Context(), orgID, req)
+	if err != nil {
+		writeEstimateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(estimate)
+}
+
+func (h *EstimateHandler) UpdateEstimate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Estimate ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateEstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := h.service.UpdateEstimate(r.Context(), orgID, id, req)
+	if err != nil {
+		writeEstimateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
+
+func (h *EstimateHandler) GetEstimate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Estimate ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := h.service.GetEstimate(r.Context(), orgID, id)
+	if err != nil {
+		writeEstimateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
+
+func (h *EstimateHandler) ListEstimates(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	estimates, err := h.service.ListEstimates(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": estimates,
+	})
+}
+
+func (h *EstimateHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Estimate ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.UpdateEstimateStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := domain.ParseEstimateStatus(req.Status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	estimate, err := h.service.UpdateStatus(r.Context(), orgID, id, status, performedBy)
+	if err != nil {
+		writeEstimateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estimate)
+}
+
+// ConvertToInvoice creates a draft invoice from the estimate
+func (h *EstimateHandler) ConvertToInvoice(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Estimate ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional
+	var req dto.ConvertEstimateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	invoice, err := h.service.ConvertToInvoice(r.Context(), orgID, id, req, performedBy)
+	if err != nil {
+		writeEstimateError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invoice)
+}
+
+func writeEstimateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrEstimateNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrInvalidEstimateTransition),
+		errors.Is(err, domain.ErrEstimateLocked),
+		errors.Is(err, domain.ErrEstimateConverted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writePaymentError(w, err)
+	}
+}
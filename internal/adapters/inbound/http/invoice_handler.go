@@ -2,7 +2,9 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"erp-billing-service/internal/application"
 	"erp-billing-service/internal/application/dto"
@@ -37,7 +39,53 @@ func (h *InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
 
 	invoice, err := h.service.CreateInvoice(r.Context(), orgID, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, domain.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invoice)
+}
+
+func (h *InvoiceHandler) CreateFromWorkOrder(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workOrderID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Work Order ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateInvoiceFromWorkOrderRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	invoice, err := h.service.CreateInvoiceFromWorkOrder(r.Context(), orgID, workOrderID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrWorkOrderNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrWorkOrderAlreadyInvoiced):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, domain.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -65,6 +113,10 @@ func (h *InvoiceHandler) UpdateInvoice(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if err.Error() == "invoice not found" {
 			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if errors.Is(err, domain.ErrInvoiceLocked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else if errors.Is(err, domain.ErrInvalidInput) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -117,6 +169,8 @@ func (h *InvoiceHandler) DeleteInvoice(w http.ResponseWriter, r *http.Request) {
 	if err := h.service.DeleteInvoice(r.Context(), id); err != nil {
 		if err.Error() == "invoice not found" {
 			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if errors.Is(err, domain.ErrInvoiceLocked) {
+			http.Error(w, err.Error(), http.StatusConflict)
 		} else {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -128,7 +182,11 @@ func (h *InvoiceHandler) DeleteInvoice(w http.ResponseWriter, r *http.Request) {
 
 func (h *InvoiceHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	id, _ := uuid.Parse(vars["id"])
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Invoice ID", http.StatusBadRequest)
+		return
+	}
 
 	var req struct {
 		Status string `json:"status"`
@@ -145,9 +203,33 @@ func (h *InvoiceHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 		performedBy = r.Header.Get("X-User-Name")
 	}
 
-	err := h.service.UpdateStatus(r.Context(), id, domain.InvoiceStatus(req.Status), req.Notes, performedBy)
+	status, err := domain.ParseInvoiceStatus(req.Status)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.service.UpdateStatus(r.Context(), id, status, req.Notes, performedBy)
+	if err != nil {
+		var transitionErr *domain.InvalidStatusTransitionError
+		if errors.As(err, &transitionErr) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+				"from":  string(transitionErr.From),
+				"to":    string(transitionErr.To),
+			})
+			return
+		}
+		switch {
+		case errors.Is(err, domain.ErrInvoiceNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrInvalidInput):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -170,3 +252,66 @@ func (h *InvoiceHandler) GetAuditLogs(w http.ResponseWriter, r *http.Request) {
 		"data": logs,
 	})
 }
+
+// SetInstallments gives the invoice a payment schedule, replacing any it had
+func (h *InvoiceHandler) SetInstallments(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Invoice ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.SetInstallmentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	invoice, err := h.service.SetInstallments(r.Context(), orgID, id, req, performedBy)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invoice)
+}
+
+// AgingReport ages the open invoices of a currency, by customer, as of a
+// day (YYYY-MM-DD, defaults to today)
+func (h *InvoiceHandler) AgingReport(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	asOf := time.Now().UTC()
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		asOf, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, "Invalid as_of date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := h.service.AgingReport(r.Context(), orgID, r.URL.Query().Get("currency"), asOf)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
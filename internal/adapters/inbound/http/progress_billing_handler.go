@@ -0,0 +1,165 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type ProgressBillingHandler struct {
+	service *application.ProgressBillingService
+}
+
+func NewProgressBillingHandler(service *application.ProgressBillingService) *ProgressBillingHandler {
+	return &ProgressBillingHandler{service: service}
+}
+
+// CreateDeposit bills a deposit for a work order or estimate, or a retainer
+func (h *ProgressBillingHandler) CreateDeposit(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateDepositRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	invoice, err := h.service.CreateDepositInvoice(r.Context(), orgID, req, performedBy)
+	if err != nil {
+		writeProgressBillingError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invoice)
+}
+
+func (h *ProgressBillingHandler) CreateWorkOrderProgressInvoice(w http.ResponseWriter, r *http.Request) {
+	h.createProgressInvoice(w, r, domain.BillingSourceWorkOrder)
+}
+
+func (h *ProgressBillingHandler) CreateEstimateProgressInvoice(w http.ResponseWriter, r *http.Request) {
+	h.createProgressInvoice(w, r, domain.BillingSourceEstimate)
+}
+
+func (h *ProgressBillingHandler) createProgressInvoice(w http.ResponseWriter, r *http.Request, sourceType domain.BillingSourceType) {
+	sourceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateProgressInvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	performedBy := "System User"
+	if r.Header.Get("X-User-Name") != "" {
+		performedBy = r.Header.Get("X-User-Name")
+	}
+
+	invoice, err := h.service.CreateProgressInvoice(r.Context(), orgID, sourceType, sourceID, req, performedBy)
+	if err != nil {
+		writeProgressBillingError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invoice)
+}
+
+func (h *ProgressBillingHandler) GetWorkOrderProgress(w http.ResponseWriter, r *http.Request) {
+	h.getProgress(w, r, domain.BillingSourceWorkOrder)
+}
+
+func (h *ProgressBillingHandler) GetEstimateProgress(w http.ResponseWriter, r *http.Request) {
+	h.getProgress(w, r, domain.BillingSourceEstimate)
+}
+
+func (h *ProgressBillingHandler) getProgress(w http.ResponseWriter, r *http.Request, sourceType domain.BillingSourceType) {
+	sourceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	progress, err := h.service.GetProgress(r.Context(), orgID, sourceType, sourceID)
+	if err != nil {
+		writeProgressBillingError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+func (h *ProgressBillingHandler) ListCustomerDeposits(w http.ResponseWriter, r *http.Request) {
+	customerID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Customer ID", http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	deposits, err := h.service.ListDeposits(r.Context(), orgID, customerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": deposits,
+	})
+}
+
+func writeProgressBillingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWorkOrderNotFound), errors.Is(err, domain.ErrEstimateNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrOverBilling),
+		errors.Is(err, domain.ErrWorkOrderAlreadyInvoiced),
+		errors.Is(err, domain.ErrEstimateConverted),
+		errors.Is(err, domain.ErrInvalidEstimateTransition):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writePaymentError(w, err)
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type RecurringInvoiceHandler struct {
+	service *application.RecurringInvoiceService
+}
+
+func NewRecurringInvoiceHandler(service *application.RecurringInvoiceService) *RecurringInvoiceHandler {
+	return &RecurringInvoiceHandler{service: service}
+}
+
+func (h *RecurringInvoiceHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateRecurringProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// In a real app, orgID would come from the auth token context
+	orgIDStr := r.Header.Get("X-Organization-ID")
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.service.CreateProfile(r.Context(), orgID, req)
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (h *RecurringInvoiceHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Profile ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateRecurringProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.service.UpdateProfile(r.Context(), id, req)
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (h *RecurringInvoiceHandler) UpdateProfileStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Profile ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"` // active, paused or cancelled
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.service.SetStatus(r.Context(), id, domain.RecurringProfileStatus(req.Status))
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (h *RecurringInvoiceHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	orgIDStr := r.Header.Get("X-Organization-ID")
+	orgID, _ := uuid.Parse(orgIDStr)
+
+	profiles, err := h.service.ListProfiles(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": profiles,
+	})
+}
+
+func (h *RecurringInvoiceHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Profile ID", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := h.service.GetProfile(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (h *RecurringInvoiceHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid Profile ID", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.service.ListRuns(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": runs,
+	})
+}
+
+func writeRecurringError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,189 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type TaxHandler struct {
+	service *application.TaxService
+}
+
+func NewTaxHandler(service *application.TaxService) *TaxHandler {
+	return &TaxHandler{service: service}
+}
+
+func (h *TaxHandler) CreateRate(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateTaxRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// In a real app, orgID would come from the auth token context
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	rate, err := h.service.CreateRate(r.Context(), orgID, req)
+	if err != nil {
+		writeTaxError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rate)
+}
+
+func (h *TaxHandler) UpdateRate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Tax Rate ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateTaxRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rate, err := h.service.UpdateRate(r.Context(), id, req)
+	if err != nil {
+		writeTaxError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rate)
+}
+
+func (h *TaxHandler) GetRate(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Tax Rate ID", http.StatusBadRequest)
+		return
+	}
+
+	rate, err := h.service.GetRate(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rate)
+}
+
+func (h *TaxHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	orgID, _ := uuid.Parse(r.Header.Get("X-Organization-ID"))
+
+	rates, err := h.service.ListRates(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": rates,
+	})
+}
+
+func (h *TaxHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateTaxGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	orgID, err := uuid.Parse(r.Header.Get("X-Organization-ID"))
+	if err != nil {
+		http.Error(w, "Invalid Organization ID", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.service.CreateGroup(r.Context(), orgID, req)
+	if err != nil {
+		writeTaxError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(group)
+}
+
+func (h *TaxHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Tax Group ID", http.StatusBadRequest)
+		return
+	}
+
+	var req dto.CreateTaxGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.service.UpdateGroup(r.Context(), id, req)
+	if err != nil {
+		writeTaxError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
+
+func (h *TaxHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid Tax Group ID", http.StatusBadRequest)
+		return
+	}
+
+	group, err := h.service.GetGroup(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
+
+func (h *TaxHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	orgID, _ := uuid.Parse(r.Header.Get("X-Organization-ID"))
+
+	groups, err := h.service.ListGroups(r.Context(), orgID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": groups,
+	})
+}
+
+func writeTaxError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -2,8 +2,10 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"erp-billing-service/internal/domain"
 
@@ -48,6 +50,8 @@ func (h *EventHandler) Handle(ctx context.Context, data []byte) error {
 			return h.handlePartEvent(tx, baseEvent)
 		case shared_events.AggregateWorkOrder:
 			return h.handleWorkOrderEvent(tx, baseEvent)
+		case domain.AggregateSubscription:
+			return h.handleUsageEvent(tx, baseEvent)
 		default:
 			log.Printf("Ignoring unrelated aggregate type: %s", baseEvent.Metadata.AggregateType)
 			return nil
@@ -165,26 +169,19 @@ func (h *EventHandler) handleWorkOrderEvent(tx *gorm.DB, event *shared_events.Ba
 			return err
 		}
 
-		id, _ := uuid.Parse(payload.WorkOrderID)
-		orgID, _ := uuid.Parse(payload.OrganizationID)
-		custID, _ := uuid.Parse(payload.CustomerID)
-		contID, _ := uuid.Parse(payload.ContactID)
-
-		rm := domain.WorkOrderRM{
-			ID:             id,
-			OrganizationID: orgID,
-			Summary:        payload.Summary,
-			Status:         payload.Status,
-			BillingStatus:  payload.BillingStatus,
-			CustomerID:     &custID,
-			ContactID:      &contID,
-			GrandTotal:     payload.GrandTotal,
-			UpdatedAt:      event.Metadata.OccurredAt,
+		rm, err := ProjectWorkOrderCreated(payload, event.Metadata.OccurredAt)
+		if err != nil {
+			log.Printf("Skipping work order event %s: %v", event.Metadata.EventID, err)
+			return nil
 		}
 
+		// Billing status, invoice and billed amount are owned by billing and
+		// must survive a replayed create, as must the date the work order was
+		// first seen
 		return tx.Clauses(clause.OnConflict{
-			UpdateAll: true,
-		}).Create(&rm).Error
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns(workOrderSyncColumns),
+		}).Create(rm).Error
 
 	case shared_events.WorkOrderUpdated:
 		var payload shared_events.WorkOrderUpdatedPayload
@@ -192,34 +189,236 @@ func (h *EventHandler) handleWorkOrderEvent(tx *gorm.DB, event *shared_events.Ba
 			return err
 		}
 
-		id, _ := uuid.Parse(payload.WorkOrderID)
+		id, updates, err := ProjectWorkOrderUpdated(payload, event.Metadata.OccurredAt)
+		if err != nil {
+			log.Printf("Skipping work order event %s: %v", event.Metadata.EventID, err)
+			return nil
+		}
+		return tx.Model(&domain.WorkOrderRM{}).Where("id = ?", id).Updates(updates).Error
 
-		updates := make(map[string]interface{})
-		if payload.Summary != "" {
-			updates["summary"] = payload.Summary
+	case shared_events.WorkOrderDeleted:
+		var payload shared_events.WorkOrderDeletedPayload
+		if err := shared_events.UnmarshalPayload(event, &payload); err != nil {
+			return err
+		}
+		id, err := uuid.Parse(payload.WorkOrderID)
+		if err != nil {
+			log.Printf("Skipping work order event %s: invalid work order ID %q", event.Metadata.EventID, payload.WorkOrderID)
+			return nil
 		}
-		if payload.Status != "" {
-			updates["status"] = payload.Status
+		if err := tx.Delete(&domain.WorkOrderServiceLineRM{}, "work_order_id = ?", id).Error; err != nil {
+			return err
 		}
-		if payload.BillingStatus != "" {
-			updates["billing_status"] = payload.BillingStatus
+		if err := tx.Delete(&domain.WorkOrderPartLineRM{}, "work_order_id = ?", id).Error; err != nil {
+			return err
 		}
-		if payload.GrandTotal > 0 {
-			updates["grand_total"] = payload.GrandTotal
+		return tx.Delete(&domain.WorkOrderRM{}, "id = ?", id).Error
+
+	case domain.EventWorkOrderServiceLineAdded, domain.EventWorkOrderServiceLineUpdated:
+		var payload domain.WorkOrderLinePayload
+		if err := shared_events.UnmarshalPayload(event, &payload); err != nil {
+			return err
 		}
-		updates["updated_at"] = event.Metadata.OccurredAt
 
-		return tx.Model(&domain.WorkOrderRM{}).Where("id = ?", id).Updates(updates).Error
+		lineID, woID, err := parseLineIDs(payload)
+		if err != nil {
+			log.Printf("Skipping work order line event %s: %v", event.Metadata.EventID, err)
+			return nil
+		}
 
-	case shared_events.WorkOrderDeleted:
-		var payload shared_events.WorkOrderDeletedPayload
+		rm := domain.WorkOrderServiceLineRM{
+			ID:          lineID,
+			WorkOrderID: woID,
+			ServiceID:   parseOptionalUUID(payload.ItemID),
+			Description: payload.Description,
+			Quantity:    payload.Quantity,
+			Unit:        payload.Unit,
+			ListPrice:   payload.ListPrice,
+			LineAmount:  payload.LineAmount,
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			UpdateAll: true,
+		}).Create(&rm).Error
+
+	case domain.EventWorkOrderServiceLineRemoved:
+		var payload domain.WorkOrderLinePayload
 		if err := shared_events.UnmarshalPayload(event, &payload); err != nil {
 			return err
 		}
-		id, _ := uuid.Parse(payload.WorkOrderID)
-		return tx.Delete(&domain.WorkOrderRM{}, "id = ?", id).Error
+		lineID, err := uuid.Parse(payload.LineID)
+		if err != nil {
+			log.Printf("Skipping work order line event %s: invalid line ID %q", event.Metadata.EventID, payload.LineID)
+			return nil
+		}
+		return tx.Delete(&domain.WorkOrderServiceLineRM{}, "id = ?", lineID).Error
+
+	case domain.EventWorkOrderPartLineAdded, domain.EventWorkOrderPartLineUpdated:
+		var payload domain.WorkOrderLinePayload
+		if err := shared_events.UnmarshalPayload(event, &payload); err != nil {
+			return err
+		}
+
+		lineID, woID, err := parseLineIDs(payload)
+		if err != nil {
+			log.Printf("Skipping work order line event %s: %v", event.Metadata.EventID, err)
+			return nil
+		}
+
+		rm := domain.WorkOrderPartLineRM{
+			ID:          lineID,
+			WorkOrderID: woID,
+			PartID:      parseOptionalUUID(payload.ItemID),
+			Description: payload.Description,
+			Quantity:    payload.Quantity,
+			Unit:        payload.Unit,
+			ListPrice:   payload.ListPrice,
+			LineAmount:  payload.LineAmount,
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			UpdateAll: true,
+		}).Create(&rm).Error
+
+	case domain.EventWorkOrderPartLineRemoved:
+		var payload domain.WorkOrderLinePayload
+		if err := shared_events.UnmarshalPayload(event, &payload); err != nil {
+			return err
+		}
+		lineID, err := uuid.Parse(payload.LineID)
+		if err != nil {
+			log.Printf("Skipping work order line event %s: invalid line ID %q", event.Metadata.EventID, payload.LineID)
+			return nil
+		}
+		return tx.Delete(&domain.WorkOrderPartLineRM{}, "id = ?", lineID).Error
 
 	default:
 		return nil
 	}
 }
+
+// workOrderSyncColumns are the work order columns the work order service
+// owns. billing_status, invoice_id and billed_amount belong to billing and
+// are never taken from events.
+var workOrderSyncColumns = []string{
+	"organization_id", "summary", "status", "customer_id", "contact_id", "grand_total", "updated_at",
+}
+
+// ProjectWorkOrderCreated maps a created work order to its read model. A new
+// work order starts out unbilled whatever billing status the event carries.
+func ProjectWorkOrderCreated(payload shared_events.WorkOrderCreatedPayload, occurredAt time.Time) (*domain.WorkOrderRM, error) {
+	id, err := uuid.Parse(payload.WorkOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid work order ID %q", payload.WorkOrderID)
+	}
+	orgID, err := uuid.Parse(payload.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid organization ID %q", payload.OrganizationID)
+	}
+
+	createdAt := occurredAt
+	return &domain.WorkOrderRM{
+		ID:             id,
+		OrganizationID: orgID,
+		Summary:        payload.Summary,
+		Status:         payload.Status,
+		BillingStatus:  domain.WorkOrderBillingUnbilled,
+		CustomerID:     parseOptionalUUID(payload.CustomerID),
+		ContactID:      parseOptionalUUID(payload.ContactID),
+		GrandTotal:     payload.GrandTotal,
+		CreatedAt:      &createdAt,
+		UpdatedAt:      occurredAt,
+	}, nil
+}
+
+// ProjectWorkOrderUpdated returns the work order an update applies to and the
+// columns it changes. Billing-owned columns are left alone.
+func ProjectWorkOrderUpdated(payload shared_events.WorkOrderUpdatedPayload, occurredAt time.Time) (uuid.UUID, map[string]interface{}, error) {
+	id, err := uuid.Parse(payload.WorkOrderID)
+	if err != nil {
+		return uuid.Nil, nil, fmt.Errorf("invalid work order ID %q", payload.WorkOrderID)
+	}
+
+	updates := make(map[string]interface{})
+	if payload.Summary != "" {
+		updates["summary"] = payload.Summary
+	}
+	if payload.Status != "" {
+		updates["status"] = payload.Status
+	}
+	if payload.GrandTotal > 0 {
+		updates["grand_total"] = payload.GrandTotal
+	}
+	updates["updated_at"] = occurredAt
+	return id, updates, nil
+}
+
+func parseLineIDs(payload domain.WorkOrderLinePayload) (uuid.UUID, uuid.UUID, error) {
+	lineID, err := uuid.Parse(payload.LineID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid line ID %q", payload.LineID)
+	}
+	woID, err := uuid.Parse(payload.WorkOrderID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid work order ID %q", payload.WorkOrderID)
+	}
+	return lineID, woID, nil
+}
+
+// handleUsageEvent records metered usage reported on the billing.usage topic.
+// Usage that cannot be recorded is logged and dropped rather than retried:
+// redelivering it would not make it valid.
+func (h *EventHandler) handleUsageEvent(tx *gorm.DB, event *shared_events.BaseEvent) error {
+	if event.Metadata.EventType != domain.EventUsageRecorded {
+		return nil
+	}
+	var payload domain.UsageRecordedPayload
+	if err := shared_events.UnmarshalPayload(event, &payload); err != nil {
+		return err
+	}
+
+	subID, err := uuid.Parse(payload.SubscriptionID)
+	if err != nil {
+		log.Printf("Dropping usage event %s: invalid subscription ID %q", event.Metadata.EventID, payload.SubscriptionID)
+		return nil
+	}
+	var sub domain.Subscription
+	if err := tx.First(&sub, "id = ?", subID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Dropping usage event %s: subscription %s not found", event.Metadata.EventID, subID)
+			return nil
+		}
+		return err
+	}
+	if orgID := parseOptionalUUID(payload.OrganizationID); orgID != nil && *orgID != sub.OrganizationID {
+		log.Printf("Dropping usage event %s: subscription %s belongs to another organization", event.Metadata.EventID, subID)
+		return nil
+	}
+
+	occurredAt := payload.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = event.Metadata.OccurredAt
+	}
+	key := payload.IdempotencyKey
+	if key == "" {
+		key = event.Metadata.EventID
+	}
+	record, err := sub.NewUsage(payload.Metric, payload.Quantity, occurredAt, key, domain.UsageSourceKafka)
+	if err != nil {
+		log.Printf("Dropping usage event %s: %v", event.Metadata.EventID, err)
+		return nil
+	}
+	record.CreatedAt = time.Now().UTC()
+
+	// A redelivered event carries the same key and is recorded once
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(record).Error
+}
+
+// parseOptionalUUID returns nil for empty or malformed IDs
+func parseOptionalUUID(s string) *uuid.UUID {
+	id, err := uuid.Parse(s)
+	if err != nil || id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
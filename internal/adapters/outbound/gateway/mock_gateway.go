@@ -0,0 +1,200 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// MockProvider is the provider name of the mock gateway
+const MockProvider = "mock"
+
+// declinedCents makes the mock decline captures of amounts ending in .02,
+// so failed payments can be tried out locally
+const declinedCents = 2
+
+// MockGateway is a payment gateway for local testing that never leaves the
+// process. IDs are derived from the request, so the same request always
+// gets the same intent, charge and refund. Webhooks are signed with
+// HMAC-SHA256 of the body using the webhook secret.
+type MockGateway struct {
+	secret  []byte
+	mu      sync.Mutex
+	intents map[string]*domain.PaymentIntent
+}
+
+func NewMockGateway(webhookSecret string) *MockGateway {
+	return &MockGateway{
+		secret:  []byte(webhookSecret),
+		intents: make(map[string]*domain.PaymentIntent),
+	}
+}
+
+func (g *MockGateway) Name() string {
+	return MockProvider
+}
+
+func (g *MockGateway) CreateIntent(ctx context.Context, req domain.PaymentIntentRequest) (*domain.PaymentIntent, error) {
+	id := "pi_mock_" + digest(req.OrganizationID.String(), req.InvoiceID.String(), req.Amount.String(), req.Currency, req.PaymentMethod, req.IdempotencyKey)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if intent, ok := g.intents[id]; ok {
+		copied := *intent
+		return &copied, nil
+	}
+	intent := &domain.PaymentIntent{
+		ID:             id,
+		Provider:       MockProvider,
+		OrganizationID: req.OrganizationID,
+		InvoiceID:      req.InvoiceID,
+		Amount:         req.Amount,
+		Currency:       req.Currency,
+		PaymentMethod:  req.PaymentMethod,
+		Status:         domain.PaymentIntentRequiresCapture,
+		ClientSecret:   id + "_secret_" + digest(id, string(g.secret)),
+		CreatedAt:      time.Now().UTC(),
+	}
+	g.intents[id] = intent
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *MockGateway) GetIntent(ctx context.Context, intentID string) (*domain.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrPaymentIntentNotFound, intentID)
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *MockGateway) Capture(ctx context.Context, intentID string, amount money.Amount) (*domain.PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	intent, ok := g.intents[intentID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrPaymentIntentNotFound, intentID)
+	}
+	if intent.Status == domain.PaymentIntentRequiresCapture {
+		if amount.IsPositive() {
+			if amount.GreaterThan(intent.Amount) {
+				return nil, fmt.Errorf("%w: capture of %s exceeds the authorized %s", domain.ErrInvalidInput, amount, intent.Amount)
+			}
+			intent.Amount = amount
+		}
+		if intent.Amount.MinorUnitsOf(intent.Currency, money.RoundHalfUp)%100 == declinedCents {
+			intent.Status = domain.PaymentIntentFailed
+		} else {
+			intent.Status = domain.PaymentIntentSucceeded
+			intent.TransactionRef = "ch_mock_" + digest(intent.ID)
+		}
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *MockGateway) Refund(ctx context.Context, transactionRef string, amount money.Amount, currency string, idempotencyKey string) (*domain.GatewayRefund, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: refund amount must be positive", domain.ErrInvalidInput)
+	}
+	return &domain.GatewayRefund{
+		ID:             "re_mock_" + digest(transactionRef, idempotencyKey),
+		TransactionRef: transactionRef,
+		Amount:         amount,
+		Currency:       currency,
+		CreatedAt:      time.Now().UTC(),
+	}, nil
+}
+
+// mockWebhook is the body of a mock webhook
+type mockWebhook struct {
+	ID             string       `json:"id"`
+	Type           string       `json:"type"`
+	IntentID       string       `json:"intent_id"`
+	TransactionRef string       `json:"transaction_ref"`
+	OrganizationID uuid.UUID    `json:"organization_id"`
+	InvoiceID      uuid.UUID    `json:"invoice_id"`
+	Amount         money.Amount `json:"amount"`
+	Currency       string       `json:"currency"`
+	PaymentMethod  string       `json:"payment_method"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+func (g *MockGateway) ParseWebhook(payload []byte, signature string) (*domain.GatewayEvent, error) {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, g.sign(payload)) {
+		return nil, domain.ErrInvalidSignature
+	}
+
+	var body mockWebhook
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, fmt.Errorf("%w: malformed webhook: %v", domain.ErrInvalidInput, err)
+	}
+	return &domain.GatewayEvent{
+		ID:             body.ID,
+		Type:           domain.GatewayEventType(body.Type),
+		Provider:       MockProvider,
+		IntentID:       body.IntentID,
+		TransactionRef: body.TransactionRef,
+		OrganizationID: body.OrganizationID,
+		InvoiceID:      body.InvoiceID,
+		Amount:         body.Amount,
+		Currency:       body.Currency,
+		PaymentMethod:  body.PaymentMethod,
+		OccurredAt:     body.CreatedAt,
+	}, nil
+}
+
+// Webhook builds the signed webhook the mock sends once an intent has been
+// captured or declined, so the webhook endpoint can be exercised locally
+func (g *MockGateway) Webhook(intent *domain.PaymentIntent) (payload []byte, signature string, err error) {
+	eventType := domain.GatewayEventPaymentSucceeded
+	if intent.Status == domain.PaymentIntentFailed {
+		eventType = domain.GatewayEventPaymentFailed
+	}
+	payload, err = json.Marshal(mockWebhook{
+		ID:             "evt_mock_" + digest(intent.ID, string(intent.Status)),
+		Type:           string(eventType),
+		IntentID:       intent.ID,
+		TransactionRef: intent.TransactionRef,
+		OrganizationID: intent.OrganizationID,
+		InvoiceID:      intent.InvoiceID,
+		Amount:         intent.Amount,
+		Currency:       intent.Currency,
+		PaymentMethod:  intent.PaymentMethod,
+		CreatedAt:      intent.CreatedAt,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, hex.EncodeToString(g.sign(payload)), nil
+}
+
+func (g *MockGateway) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// digest derives a short stable ID from its parts
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:24]
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BankStatementRepository struct {
+	db *gorm.DB
+}
+
+func NewBankStatementRepository(db *gorm.DB) *BankStatementRepository {
+	return &BankStatementRepository{db: db}
+}
+
+func (r *BankStatementRepository) Create(ctx context.Context, statement *domain.BankStatement) error {
+	return r.db.WithContext(ctx).Create(statement).Error
+}
+
+func (r *BankStatementRepository) ExistingFingerprints(ctx context.Context, orgID uuid.UUID, fingerprints []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(fingerprints) == 0 {
+		return existing, nil
+	}
+	var found []string
+	err := r.db.WithContext(ctx).Model(&domain.BankStatementLine{}).
+		Where("organization_id = ? AND fingerprint IN ?", orgID, fingerprints).
+		Pluck("fingerprint", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range found {
+		existing[f] = true
+	}
+	return existing, nil
+}
+
+func (r *BankStatementRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.BankStatement, error) {
+	var statement domain.BankStatement
+	err := r.db.WithContext(ctx).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("booking_date asc, created_at asc") }).
+		First(&statement, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrStatementNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+func (r *BankStatementRepository) List(ctx context.Context, orgID uuid.UUID) ([]domain.BankStatement, error) {
+	var statements []domain.BankStatement
+	err := r.db.WithContext(ctx).Where("organization_id = ?", orgID).Order("created_at desc").Find(&statements).Error
+	return statements, err
+}
+
+func (r *BankStatementRepository) GetLine(ctx context.Context, id uuid.UUID) (*domain.BankStatementLine, error) {
+	var line domain.BankStatementLine
+	err := r.db.WithContext(ctx).First(&line, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrStatementLineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+func (r *BankStatementRepository) ListLines(ctx context.Context, orgID uuid.UUID, statuses []domain.StatementLineStatus) ([]domain.BankStatementLine, error) {
+	var lines []domain.BankStatementLine
+	query := r.db.WithContext(ctx).Where("organization_id = ?", orgID)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	err := query.Order("booking_date asc, created_at asc").Find(&lines).Error
+	return lines, err
+}
+
+func (r *BankStatementRepository) SaveDecision(ctx context.Context, line *domain.BankStatementLine) error {
+	res := r.db.WithContext(ctx).Model(line).
+		Where("status IN ?", domain.StatementLineReviewStatuses).
+		Select("status", "matched_invoice_id", "matched_customer_id", "payment_id", "decision", "decision_notes", "decided_by", "decided_at", "updated_at").
+		Updates(line)
+	if res.Error != nil {
+		return fmt.Errorf("failed to save decision on statement line: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: statement line %s has already been decided", domain.ErrInvalidInput, line.ID)
+	}
+	return nil
+}
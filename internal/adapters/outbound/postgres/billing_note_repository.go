@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BillingNoteRepository struct {
+	db *gorm.DB
+}
+
+func NewBillingNoteRepository(db *gorm.DB) *BillingNoteRepository {
+	return &BillingNoteRepository{db: db}
+}
+
+func (r *BillingNoteRepository) Issue(ctx context.Context, invoiceID uuid.UUID, build func(invoice *domain.Invoice, earlier []domain.BillingNote) (*domain.BillingNote, error)) (*domain.BillingNote, *domain.Invoice, error) {
+	var note *domain.BillingNote
+	var invoice *domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Payments and other notes on the invoice wait here until this one commits
+		invoices, err := lockInvoices(tx, []uuid.UUID{invoiceID})
+		if err != nil {
+			return err
+		}
+		invoice = invoices[0]
+		if err := tx.Where("invoice_id = ?", invoice.ID).Find(&invoice.Items).Error; err != nil {
+			return err
+		}
+		var earlier []domain.BillingNote
+		if err := tx.Preload("Items").Where("invoice_id = ?", invoice.ID).Order("created_at asc").Find(&earlier).Error; err != nil {
+			return err
+		}
+
+		note, err = build(invoice, earlier)
+		if err != nil {
+			return err
+		}
+
+		number, err := nextNumber(tx, note.OrganizationID, note.NoteType.DocumentType(), time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("failed to allocate note number: %w", err)
+		}
+		note.NoteNumber = number
+
+		if err := tx.Create(note).Error; err != nil {
+			return fmt.Errorf("failed to create billing note: %w", err)
+		}
+
+		// Only the balance columns change; items and payments are left alone
+		if err := tx.Model(invoice).Select("credited_amount", "debited_amount", "excess_credit", "balance_amount", "status", "locked_at", "updated_at").Updates(invoice).Error; err != nil {
+			return fmt.Errorf("failed to update invoice balance: %w", err)
+		}
+		if err := saveInstallments(tx, invoice); err != nil {
+			return err
+		}
+
+		if entry := domain.CreditEntryForNote(note); entry != nil {
+			if err := postCredit(tx, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return note, invoice, nil
+}
+
+func (r *BillingNoteRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.BillingNote, error) {
+	var note domain.BillingNote
+	err := r.db.WithContext(ctx).Preload("Items").First(&note, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (r *BillingNoteRepository) ListByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]domain.BillingNote, error) {
+	var notes []domain.BillingNote
+	err := r.db.WithContext(ctx).Preload("Items").Where("invoice_id = ?", invoiceID).Order("created_at asc").Find(&notes).Error
+	return notes, err
+}
+
+func (r *BillingNoteRepository) List(ctx context.Context, filter map[string]interface{}) ([]domain.BillingNote, error) {
+	var notes []domain.BillingNote
+	err := r.db.WithContext(ctx).Where(filter).Order("created_at desc").Find(&notes).Error
+	return notes, err
+}
@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ConsolidationRepository struct {
+	db *gorm.DB
+}
+
+func NewConsolidationRepository(db *gorm.DB) *ConsolidationRepository {
+	return &ConsolidationRepository{db: db}
+}
+
+func (r *ConsolidationRepository) ClaimRun(ctx context.Context, run *domain.ConsolidationRun) (bool, error) {
+	db := r.db.WithContext(ctx)
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(run)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// A period with nothing billed for it may be tried again, as may one whose
+	// invoice has since been voided or deleted; anything else means it is taken
+	live := r.db.Model(&domain.Invoice{}).Select("id").Where("status <> ?", domain.InvoiceStatusVoid)
+	res = db.Model(&domain.ConsolidationRun{}).
+		Where("organization_id = ? AND customer_id = ? AND currency = ? AND period_start = ? AND period_end = ?",
+			run.OrganizationID, run.CustomerID, run.Currency, run.PeriodStart, run.PeriodEnd).
+		Where("(status IN ? OR (status = ? AND invoice_id NOT IN (?)))",
+			[]domain.ConsolidationStatus{domain.ConsolidationFailed, domain.ConsolidationEmpty}, domain.ConsolidationInvoiced, live).
+		Updates(map[string]interface{}{
+			"status":      domain.ConsolidationPending,
+			"source":      run.Source,
+			"invoice_id":  nil,
+			"work_orders": 0,
+			"drafts":      0,
+			"error":       "",
+			"updated_at":  time.Now().UTC(),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, db.First(run, "organization_id = ? AND customer_id = ? AND currency = ? AND period_start = ? AND period_end = ?",
+		run.OrganizationID, run.CustomerID, run.Currency, run.PeriodStart, run.PeriodEnd).Error
+}
+
+func (r *ConsolidationRepository) GetRun(ctx context.Context, orgID, customerID uuid.UUID, currency string, start, end time.Time) (*domain.ConsolidationRun, error) {
+	var run domain.ConsolidationRun
+	err := r.db.WithContext(ctx).First(&run, "organization_id = ? AND customer_id = ? AND currency = ? AND period_start = ? AND period_end = ?",
+		orgID, customerID, currency, start, end).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: no consolidation run for the period", domain.ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *ConsolidationRepository) UpdateRun(ctx context.Context, run *domain.ConsolidationRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+func (r *ConsolidationRepository) ListRuns(ctx context.Context, orgID uuid.UUID) ([]domain.ConsolidationRun, error) {
+	var runs []domain.ConsolidationRun
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", orgID).
+		Order("period_start desc, created_at desc").
+		Find(&runs).Error
+	return runs, err
+}
+
+func (r *ConsolidationRepository) ListUnbilledWorkOrders(ctx context.Context, orgID uuid.UUID, customerID *uuid.UUID, start, end time.Time) ([]domain.WorkOrderRM, error) {
+	var workOrders []domain.WorkOrderRM
+	// Rows synced before work orders carried their opening date fall back
+	// to the last change
+	query := r.db.WithContext(ctx).
+		Where("organization_id = ? AND customer_id IS NOT NULL", orgID).
+		Where("(billing_status IS NULL OR billing_status <> ?) AND invoice_id IS NULL AND COALESCE(billed_amount, 0) = 0", domain.WorkOrderBillingInvoiced).
+		Where("COALESCE(created_at, updated_at) >= ? AND COALESCE(created_at, updated_at) < ?", start, end.AddDate(0, 0, 1))
+	if customerID != nil {
+		query = query.Where("customer_id = ?", *customerID)
+	}
+	err := query.Order("COALESCE(created_at, updated_at) asc, id asc").Find(&workOrders).Error
+	return workOrders, err
+}
+
+func (r *ConsolidationRepository) ListDrafts(ctx context.Context, orgID uuid.UUID, customerID *uuid.UUID, currency string, start, end time.Time) ([]domain.Invoice, error) {
+	var invoices []domain.Invoice
+	query := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).
+		Where("organization_id = ? AND currency = ? AND status = ? AND kind = ?", orgID, currency, domain.InvoiceStatusDraft, domain.InvoiceKindStandard).
+		Where("tax_inclusive = ? AND consolidated_id IS NULL AND deleted_at IS NULL", false).
+		Where("invoice_date >= ? AND invoice_date < ?", start, end.AddDate(0, 0, 1))
+	if customerID != nil {
+		query = query.Where("customer_id = ?", *customerID)
+	}
+	err := query.Order("invoice_date asc, invoice_number asc").Find(&invoices).Error
+	return invoices, err
+}
+
+func (r *ConsolidationRepository) Complete(ctx context.Context, run *domain.ConsolidationRun, workOrderIDs, draftIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if len(draftIDs) > 0 {
+			res := tx.Model(&domain.Invoice{}).
+				Where("id IN ? AND status = ? AND consolidated_id IS NULL", draftIDs, domain.InvoiceStatusDraft).
+				Updates(map[string]interface{}{"status": domain.InvoiceStatusVoid, "consolidated_id": *run.InvoiceID, "locked_at": now, "updated_at": now})
+			if res.Error != nil {
+				return fmt.Errorf("failed to void consolidated drafts: %w", res.Error)
+			}
+			if res.RowsAffected != int64(len(draftIDs)) {
+				return fmt.Errorf("%w: a draft invoice changed while it was being consolidated", domain.ErrInvalidStatusTransition)
+			}
+			err := tx.Model(&domain.WorkOrderRM{}).
+				Where("invoice_id IN ?", draftIDs).
+				Update("invoice_id", *run.InvoiceID).Error
+			if err != nil {
+				return fmt.Errorf("failed to move work orders of consolidated drafts: %w", err)
+			}
+		}
+		if len(workOrderIDs) > 0 {
+			err := tx.Model(&domain.WorkOrderRM{}).
+				Where("id IN ?", workOrderIDs).
+				Update("invoice_id", *run.InvoiceID).Error
+			if err != nil {
+				return fmt.Errorf("failed to link consolidated work orders: %w", err)
+			}
+		}
+		return tx.Save(run).Error
+	})
+}
+
+func (r *ConsolidationRepository) Undo(ctx context.Context, invoiceID uuid.UUID) ([]domain.Invoice, error) {
+	var drafts []domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("consolidated_id = ? AND status = ?", invoiceID, domain.InvoiceStatusVoid).
+			Order("id").
+			Find(&drafts).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for i := range drafts {
+			draft := &drafts[i]
+			draft.Status = domain.InvoiceStatusDraft
+			draft.ConsolidatedID = nil
+			draft.LockedAt = nil
+			draft.UpdatedAt = now
+			if err := tx.Model(draft).Select("status", "consolidated_id", "locked_at", "updated_at").Updates(draft).Error; err != nil {
+				return fmt.Errorf("failed to restore draft %s: %w", draft.InvoiceNumber, err)
+			}
+			if draft.WorkOrderID != nil {
+				err := tx.Model(&domain.WorkOrderRM{}).
+					Where("id = ? AND invoice_id = ?", *draft.WorkOrderID, invoiceID).
+					Update("invoice_id", draft.ID).Error
+				if err != nil {
+					return fmt.Errorf("failed to relink work order of draft %s: %w", draft.InvoiceNumber, err)
+				}
+			}
+		}
+
+		return tx.Model(&domain.WorkOrderRM{}).
+			Where("invoice_id = ?", invoiceID).
+			Updates(map[string]interface{}{"billing_status": domain.WorkOrderBillingUnbilled, "invoice_id": nil}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
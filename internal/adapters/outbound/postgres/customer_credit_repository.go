@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CustomerCreditRepository struct {
+	db *gorm.DB
+}
+
+func NewCustomerCreditRepository(db *gorm.DB) *CustomerCreditRepository {
+	return &CustomerCreditRepository{db: db}
+}
+
+func (r *CustomerCreditRepository) ListBalances(ctx context.Context, orgID, customerID uuid.UUID) ([]domain.CustomerCredit, error) {
+	var credits []domain.CustomerCredit
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND customer_id = ?", orgID, customerID).
+		Order("currency asc").
+		Find(&credits).Error
+	return credits, err
+}
+
+func (r *CustomerCreditRepository) GetBalance(ctx context.Context, orgID, customerID uuid.UUID, currency string) (money.Amount, error) {
+	var credit domain.CustomerCredit
+	err := r.db.WithContext(ctx).
+		First(&credit, "organization_id = ? AND customer_id = ? AND currency = ?", orgID, customerID, currency).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return money.Zero, nil
+	}
+	if err != nil {
+		return money.Zero, err
+	}
+	return credit.Balance, nil
+}
+
+func (r *CustomerCreditRepository) ListEntries(ctx context.Context, orgID, customerID uuid.UUID) ([]domain.CustomerCreditEntry, error) {
+	var entries []domain.CustomerCreditEntry
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND customer_id = ?", orgID, customerID).
+		Order("created_at desc").
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *CustomerCreditRepository) Post(ctx context.Context, entry *domain.CustomerCreditEntry) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return postCredit(tx, entry)
+	})
+}
+
+// postCredit stores a credit movement inside tx. The customer's balance row
+// stays locked until tx ends, so two movements cannot spend the same credit.
+func postCredit(tx *gorm.DB, entry *domain.CustomerCreditEntry) error {
+	var credit domain.CustomerCredit
+	lock := func() error {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&credit, "organization_id = ? AND customer_id = ? AND currency = ?", entry.OrganizationID, entry.CustomerID, entry.Currency).Error
+	}
+
+	err := lock()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Another transaction may open the balance at the same time
+		seed := &domain.CustomerCredit{
+			ID:             uuid.New(),
+			OrganizationID: entry.OrganizationID,
+			CustomerID:     entry.CustomerID,
+			Currency:       entry.Currency,
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(seed).Error; err != nil {
+			return err
+		}
+		err = lock()
+	}
+	if err != nil {
+		return err
+	}
+
+	balance := credit.Balance.Add(entry.Amount)
+	if balance.IsNegative() {
+		return fmt.Errorf("%w: customer has %s %s of credit, %s is needed", domain.ErrInvalidInput, credit.Balance, credit.Currency, entry.Amount.Neg())
+	}
+	entry.BalanceAfter = balance
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create credit entry: %w", err)
+	}
+	credit.Balance = balance
+	return tx.Model(&credit).Select("balance", "updated_at").Updates(&credit).Error
+}
@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DirectDebitRepository struct {
+	db *gorm.DB
+}
+
+func NewDirectDebitRepository(db *gorm.DB) *DirectDebitRepository {
+	return &DirectDebitRepository{db: db}
+}
+
+func (r *DirectDebitRepository) SaveCreditor(ctx context.Context, creditor *domain.DirectDebitCreditor) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}, {Name: "scheme"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "creditor_id", "iban", "bic", "routing_number", "destination_name", "updated_at"}),
+	}).Create(creditor).Error
+}
+
+func (r *DirectDebitRepository) GetCreditor(ctx context.Context, orgID uuid.UUID, scheme domain.DirectDebitScheme) (*domain.DirectDebitCreditor, error) {
+	var creditor domain.DirectDebitCreditor
+	err := r.db.WithContext(ctx).First(&creditor, "organization_id = ? AND scheme = ?", orgID, scheme).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrCreditorNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &creditor, nil
+}
+
+func (r *DirectDebitRepository) CreateMandate(ctx context.Context, mandate *domain.DirectDebitMandate) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&domain.DirectDebitMandate{}).
+			Where("organization_id = ? AND customer_id = ? AND scheme = ? AND status = ?",
+				mandate.OrganizationID, mandate.CustomerID, mandate.Scheme, domain.MandateActive).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: customer already has an active %s mandate", domain.ErrInvalidInput, mandate.Scheme)
+		}
+		return tx.Create(mandate).Error
+	})
+}
+
+func (r *DirectDebitRepository) GetMandate(ctx context.Context, id uuid.UUID) (*domain.DirectDebitMandate, error) {
+	var mandate domain.DirectDebitMandate
+	err := r.db.WithContext(ctx).First(&mandate, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrMandateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mandate, nil
+}
+
+func (r *DirectDebitRepository) ListMandates(ctx context.Context, orgID, customerID uuid.UUID) ([]domain.DirectDebitMandate, error) {
+	var mandates []domain.DirectDebitMandate
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND customer_id = ?", orgID, customerID).
+		Order("created_at desc").
+		Find(&mandates).Error
+	return mandates, err
+}
+
+func (r *DirectDebitRepository) UpdateMandate(ctx context.Context, mandate *domain.DirectDebitMandate) error {
+	return r.db.WithContext(ctx).Save(mandate).Error
+}
+
+func (r *DirectDebitRepository) CreateBatch(ctx context.Context, orgID uuid.UUID, scheme domain.DirectDebitScheme, dueBy time.Time,
+	build func(creditor *domain.DirectDebitCreditor, invoices []*domain.Invoice, mandates map[uuid.UUID]*domain.DirectDebitMandate) (*domain.DirectDebitBatch, error)) (*domain.DirectDebitBatch, error) {
+	var batch *domain.DirectDebitBatch
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Batches of the same creditor are numbered one at a time
+		var creditor domain.DirectDebitCreditor
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&creditor, "organization_id = ? AND scheme = ?", orgID, scheme).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrCreditorNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var active []*domain.DirectDebitMandate
+		err = tx.Where("organization_id = ? AND scheme = ? AND status = ?", orgID, scheme, domain.MandateActive).
+			Find(&active).Error
+		if err != nil {
+			return err
+		}
+		mandates := make(map[uuid.UUID]*domain.DirectDebitMandate, len(active))
+		customerIDs := make([]uuid.UUID, 0, len(active))
+		for _, m := range active {
+			mandates[m.CustomerID] = m
+			customerIDs = append(customerIDs, m.CustomerID)
+		}
+
+		var invoices []*domain.Invoice
+		if len(customerIDs) > 0 {
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("organization_id = ? AND customer_id IN ? AND currency = ?", orgID, customerIDs, scheme.Currency()).
+				Where("status IN ? AND balance_amount > 0 AND due_date <= ?", domain.OpenInvoiceStatuses, dueBy).
+				Where("in_collection_at IS NULL AND deleted_at IS NULL").
+				Order("due_date asc, id asc").
+				Find(&invoices).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		batch, err = build(&creditor, invoices, mandates)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Create(batch).Error; err != nil {
+			return fmt.Errorf("failed to create direct debit batch: %w", err)
+		}
+		collected := make(map[uuid.UUID]bool)
+		for _, item := range batch.Items {
+			err := tx.Model(&domain.Invoice{}).Where("id = ?", item.InvoiceID).
+				Update("in_collection_at", batch.CreatedAt).Error
+			if err != nil {
+				return err
+			}
+			collected[item.MandateID] = true
+		}
+		for _, m := range active {
+			if collected[m.ID] {
+				if err := tx.Model(m).Select("first_collected", "updated_at").Updates(m).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&creditor).Select("next_batch", "next_trace", "updated_at").Updates(&creditor).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (r *DirectDebitRepository) GetBatch(ctx context.Context, id uuid.UUID) (*domain.DirectDebitBatch, error) {
+	var batch domain.DirectDebitBatch
+	err := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc, id asc") }).
+		First(&batch, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrDirectDebitBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (r *DirectDebitRepository) ListBatches(ctx context.Context, orgID uuid.UUID) ([]domain.DirectDebitBatch, error) {
+	var batches []domain.DirectDebitBatch
+	err := r.db.WithContext(ctx).Omit("file").
+		Where("organization_id = ?", orgID).
+		Order("created_at desc").
+		Find(&batches).Error
+	return batches, err
+}
+
+func (r *DirectDebitRepository) FindItem(ctx context.Context, orgID uuid.UUID, ref string) (*domain.DirectDebitItem, error) {
+	var item domain.DirectDebitItem
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND (end_to_end_id = ? OR trace_number = ?)", orgID, ref, ref).
+		Order("created_at desc").
+		First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrDirectDebitItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *DirectDebitRepository) SaveItem(ctx context.Context, item *domain.DirectDebitItem) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(item).Error; err != nil {
+			return fmt.Errorf("failed to save direct debit item: %w", err)
+		}
+		if item.Status == domain.DirectDebitItemPending {
+			return nil
+		}
+
+		// A later batch may be collecting the invoice again by now
+		err := tx.Model(&domain.Invoice{}).Where("id = ?", item.InvoiceID).
+			Where("NOT EXISTS (SELECT 1 FROM direct_debit_items WHERE invoice_id = ? AND status = ?)", item.InvoiceID, domain.DirectDebitItemPending).
+			Update("in_collection_at", nil).Error
+		if err != nil {
+			return err
+		}
+
+		var pending int64
+		err = tx.Model(&domain.DirectDebitItem{}).
+			Where("batch_id = ? AND status = ?", item.BatchID, domain.DirectDebitItemPending).
+			Count(&pending).Error
+		if err != nil || pending > 0 {
+			return err
+		}
+		return tx.Model(&domain.DirectDebitBatch{}).Where("id = ?", item.BatchID).
+			Update("status", domain.DirectDebitBatchSettled).Error
+	})
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DunningRepository struct {
+	db *gorm.DB
+}
+
+func NewDunningRepository(db *gorm.DB) *DunningRepository {
+	return &DunningRepository{db: db}
+}
+
+func (r *DunningRepository) GetPolicy(ctx context.Context, orgID uuid.UUID) (*domain.DunningPolicy, error) {
+	var policy domain.DunningPolicy
+	err := r.db.WithContext(ctx).First(&policy, "organization_id = ?", orgID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrDunningPolicyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *DunningRepository) SavePolicy(ctx context.Context, policy *domain.DunningPolicy) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"time_zone", "enabled", "steps", "updated_at"}),
+	}).Create(policy).Error
+}
+
+func (r *DunningRepository) ListPolicies(ctx context.Context) ([]domain.DunningPolicy, error) {
+	var policies []domain.DunningPolicy
+	err := r.db.WithContext(ctx).Find(&policies).Error
+	return policies, err
+}
+
+func (r *DunningRepository) ListCandidates(ctx context.Context, orgID *uuid.UUID, dueBefore time.Time) ([]domain.Invoice, error) {
+	var invoices []domain.Invoice
+	lapsed := r.db.Model(&domain.Installment{}).Select("1").
+		Where("installments.invoice_id = invoices.id AND installments.due_date < ? AND installments.status <> ?", dueBefore, domain.InstallmentPaid)
+	query := r.db.WithContext(ctx).
+		Scopes(withInstallments).
+		Where("status IN ? AND balance_amount > 0", domain.OpenInvoiceStatuses).
+		Where("(due_date < ? OR EXISTS (?))", dueBefore, lapsed).
+		Where("in_collection_at IS NULL AND deleted_at IS NULL")
+	if orgID != nil {
+		query = query.Where("organization_id = ?", *orgID)
+	}
+	err := query.Order("organization_id asc, due_date asc").Find(&invoices).Error
+	return invoices, err
+}
+
+func (r *DunningRepository) MarkOverdue(ctx context.Context, invoiceID uuid.UUID, from domain.InvoiceStatus) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&domain.Invoice{}).
+		Where("id = ? AND status = ? AND balance_amount > 0", invoiceID, from).
+		Updates(map[string]interface{}{"status": domain.InvoiceStatusOverdue, "updated_at": time.Now().UTC()})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *DunningRepository) MarkInstallmentsOverdue(ctx context.Context, installmentIDs []uuid.UUID) (int64, error) {
+	if len(installmentIDs) == 0 {
+		return 0, nil
+	}
+	res := r.db.WithContext(ctx).Model(&domain.Installment{}).
+		Where("id IN ? AND status IN ?", installmentIDs, []domain.InstallmentStatus{domain.InstallmentPending, domain.InstallmentPartial}).
+		Updates(map[string]interface{}{"status": domain.InstallmentOverdue, "updated_at": time.Now().UTC()})
+	return res.RowsAffected, res.Error
+}
+
+func (r *DunningRepository) ClaimReminder(ctx context.Context, reminder *domain.DunningReminder) (bool, error) {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(reminder)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *DunningRepository) ListReminders(ctx context.Context, invoiceIDs []uuid.UUID) ([]domain.DunningReminder, error) {
+	var reminders []domain.DunningReminder
+	if len(invoiceIDs) == 0 {
+		return reminders, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("invoice_id IN ?", invoiceIDs).
+		Order("created_at asc").
+		Find(&reminders).Error
+	return reminders, err
+}
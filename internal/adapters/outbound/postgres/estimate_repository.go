@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type EstimateRepository struct {
+	db *gorm.DB
+}
+
+func NewEstimateRepository(db *gorm.DB) *EstimateRepository {
+	return &EstimateRepository{db: db}
+}
+
+func (r *EstimateRepository) Create(ctx context.Context, estimate *domain.Estimate) error {
+	assigned := estimate.EstimateNumber == ""
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if assigned {
+			number, err := nextNumber(tx, estimate.OrganizationID, domain.DocumentEstimate, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("failed to allocate estimate number: %w", err)
+			}
+			estimate.EstimateNumber = number
+		}
+		return tx.Create(estimate).Error
+	})
+	if err != nil && assigned {
+		// The number was rolled back with the transaction
+		estimate.EstimateNumber = ""
+	}
+	return err
+}
+
+func (r *EstimateRepository) Update(ctx context.Context, estimate *domain.Estimate) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.EstimateTax{}, "estimate_id = ?", estimate.ID).Error; err != nil {
+			return fmt.Errorf("failed to clear estimate taxes: %w", err)
+		}
+		if err := tx.Delete(&domain.EstimateItem{}, "estimate_id = ?", estimate.ID).Error; err != nil {
+			return fmt.Errorf("failed to clear estimate items: %w", err)
+		}
+		return tx.Save(estimate).Error
+	})
+}
+
+func (r *EstimateRepository) UpdateStatus(ctx context.Context, estimate *domain.Estimate) error {
+	return r.db.WithContext(ctx).Model(&domain.Estimate{}).
+		Where("id = ?", estimate.ID).
+		Updates(map[string]interface{}{"status": estimate.Status, "updated_at": time.Now().UTC()}).Error
+}
+
+func (r *EstimateRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Estimate, error) {
+	var estimate domain.Estimate
+	err := r.db.WithContext(ctx).Preload("Items").Preload("Taxes").First(&estimate, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrEstimateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
+
+func (r *EstimateRepository) List(ctx context.Context, filter map[string]interface{}) ([]domain.Estimate, error) {
+	var estimates []domain.Estimate
+	err := r.db.WithContext(ctx).Where(filter).Order("created_at desc").Find(&estimates).Error
+	return estimates, err
+}
+
+func (r *EstimateRepository) ReserveConversion(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	// Compare-and-set so two concurrent requests cannot both convert the
+	// estimate, nor convert one that is being billed in progress invoices
+	res := r.db.WithContext(ctx).Model(&domain.Estimate{}).
+		Where("id = ? AND converted_at IS NULL AND billed_amount = 0", id).
+		Updates(map[string]interface{}{"status": domain.EstimateStatusAccepted, "converted_at": at, "updated_at": at})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *EstimateRepository) ReleaseConversion(ctx context.Context, id uuid.UUID, status domain.EstimateStatus) error {
+	return r.db.WithContext(ctx).Model(&domain.Estimate{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "converted_at": nil, "invoice_id": nil}).Error
+}
+
+func (r *EstimateRepository) LinkInvoice(ctx context.Context, id, invoiceID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&domain.Estimate{}).
+		Where("id = ?", id).
+		Update("invoice_id", invoiceID).Error
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -23,13 +24,66 @@ func (r *InvoiceRepository) Create(ctx context.Context, invoice *domain.Invoice)
 	return r.db.WithContext(ctx).Create(invoice).Error
 }
 
-func (r *InvoiceRepository) Update(ctx context.Context, invoice *domain.Invoice) error {
-	return r.db.WithContext(ctx).Save(invoice).Error
+// numberIssued gives an invoice that has just left draft the next number of
+// its organization's invoice series. The sequence stays locked until tx
+// ends, so a rolled back issue gives the number back. A draft that is voided
+// never went out and stays unnumbered.
+func numberIssued(tx *gorm.DB, invoice *domain.Invoice) error {
+	if invoice.InvoiceNumber != "" || !invoice.IsLocked() || invoice.Status == domain.InvoiceStatusVoid {
+		return nil
+	}
+	number, err := nextNumber(tx, invoice.OrganizationID, domain.DocumentInvoice, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to allocate invoice number: %w", err)
+	}
+	invoice.InvoiceNumber = number
+	return tx.Model(invoice).Update("invoice_number", number).Error
+}
+
+// InvoiceStatusColumns are the invoice columns a status change writes
+var InvoiceStatusColumns = []string{"status", "locked_at", "updated_at"}
+
+func (r *InvoiceRepository) UpdateStatus(ctx context.Context, invoiceID uuid.UUID, apply func(inv *domain.Invoice) error) (*domain.Invoice, error) {
+	var invoice *domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invoices, err := lockInvoices(tx, []uuid.UUID{invoiceID})
+		if err != nil {
+			return err
+		}
+		invoice = invoices[0]
+		if err := apply(invoice); err != nil {
+			return err
+		}
+		invoice.UpdatedAt = time.Now().UTC()
+		if err := tx.Model(invoice).Select(InvoiceStatusColumns).Updates(invoice).Error; err != nil {
+			return err
+		}
+		return numberIssued(tx, invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
 }
 
 func (r *InvoiceRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Invoice, error) {
 	var invoice domain.Invoice
-	err := r.db.WithContext(ctx).Preload("Items").Preload("Payments").First(&invoice, "id = ?", id).Error
+	err := r.db.WithContext(ctx).Preload("Items").Preload("Taxes").Scopes(withInstallments).First(&invoice, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *InvoiceRepository) GetByRunID(ctx context.Context, runID uuid.UUID) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	err := r.db.WithContext(ctx).Preload("Items").Preload("Taxes").Scopes(withInstallments).First(&invoice, "run_id = ?", runID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrInvoiceNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +96,28 @@ func (r *InvoiceRepository) List(ctx context.Context, filter map[string]interfac
 	return invoices, err
 }
 
+func (r *InvoiceRepository) ListOpenForCustomer(ctx context.Context, orgID, customerID uuid.UUID, currency string) ([]domain.Invoice, error) {
+	var invoices []domain.Invoice
+	err := r.db.WithContext(ctx).
+		Scopes(withInstallments).
+		Where("organization_id = ? AND customer_id = ? AND currency = ?", orgID, customerID, currency).
+		Where("status IN ? AND balance_amount > 0", domain.OpenInvoiceStatuses).
+		Order("due_date asc, invoice_date asc, invoice_number asc").
+		Find(&invoices).Error
+	return invoices, err
+}
+
+func (r *InvoiceRepository) ListOpenForOrganization(ctx context.Context, orgID uuid.UUID, currency string) ([]domain.Invoice, error) {
+	var invoices []domain.Invoice
+	err := r.db.WithContext(ctx).
+		Scopes(withInstallments).
+		Where("organization_id = ? AND currency = ?", orgID, currency).
+		Where("status IN ? AND balance_amount > 0", domain.OpenInvoiceStatuses).
+		Order("due_date asc, invoice_date asc, invoice_number asc").
+		Find(&invoices).Error
+	return invoices, err
+}
+
 func (r *InvoiceRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// First delete all invoice items
@@ -49,9 +125,17 @@ func (r *InvoiceRepository) Delete(ctx context.Context, id uuid.UUID) error {
 			return fmt.Errorf("failed to delete invoice items: %w", err)
 		}
 
-		// Then delete all payments
-		if err := tx.Delete(&domain.Payment{}, "invoice_id = ?", id).Error; err != nil {
-			return fmt.Errorf("failed to delete payments: %w", err)
+		if err := tx.Delete(&domain.InvoiceTax{}, "invoice_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to delete invoice taxes: %w", err)
+		}
+
+		if err := tx.Delete(&domain.Installment{}, "invoice_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to delete installments: %w", err)
+		}
+
+		// Then delete all payment allocations
+		if err := tx.Delete(&domain.PaymentAllocation{}, "invoice_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to delete payment allocations: %w", err)
 		}
 
 		// Finally delete the invoice
@@ -63,18 +147,100 @@ func (r *InvoiceRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	})
 }
 
-func (r *InvoiceRepository) GetNextInvoiceNumber(ctx context.Context, orgID uuid.UUID) (string, error) {
-	var count int64
-	err := r.db.WithContext(ctx).Model(&domain.Invoice{}).Where("organization_id = ?", orgID).Count(&count).Error
+// InvoiceEditColumns are the invoice columns a draft edit rewrites. Payments,
+// notes and status changes own the rest.
+var InvoiceEditColumns = []string{
+	"subject", "contact_id", "owner_id", "invoice_date", "due_date",
+	"payment_term_id", "early_pay_pct", "early_pay_by", "currency",
+	"sub_total", "discount", "discount_pct", "discount_total", "tax_total", "tax_inclusive",
+	"adjustment", "excise_duty", "sales_commission", "total_amount", "balance_amount",
+	"sales_order", "purchase_order", "terms", "notes",
+	"billing_street", "billing_city", "billing_state", "billing_code", "billing_country",
+	"shipping_street", "shipping_city", "shipping_state", "shipping_code", "shipping_country",
+	"updated_at",
+}
+
+func (r *InvoiceRepository) ReplaceItems(ctx context.Context, invoiceID uuid.UUID, apply func(inv *domain.Invoice) error) (*domain.Invoice, error) {
+	var invoice *domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invoices, err := lockInvoices(tx, []uuid.UUID{invoiceID})
+		if err != nil {
+			return err
+		}
+		invoice = invoices[0]
+		if err := apply(invoice); err != nil {
+			return err
+		}
+
+		// The tax breakdown is derived from the lines and goes with them
+		if err := tx.Delete(&domain.InvoiceTax{}, "invoice_id = ?", invoiceID).Error; err != nil {
+			return fmt.Errorf("failed to delete invoice taxes: %w", err)
+		}
+		if err := tx.Delete(&domain.InvoiceItem{}, "invoice_id = ?", invoiceID).Error; err != nil {
+			return fmt.Errorf("failed to delete invoice items: %w", err)
+		}
+		if len(invoice.Items) > 0 {
+			if err := tx.Create(&invoice.Items).Error; err != nil {
+				return fmt.Errorf("failed to create invoice items: %w", err)
+			}
+		}
+		if len(invoice.Taxes) > 0 {
+			if err := tx.Create(&invoice.Taxes).Error; err != nil {
+				return fmt.Errorf("failed to create invoice taxes: %w", err)
+			}
+		}
+		invoice.UpdatedAt = time.Now().UTC()
+		return tx.Model(invoice).Select(InvoiceEditColumns).Updates(invoice).Error
+	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return invoice, nil
+}
+
+func (r *InvoiceRepository) ReplaceInstallments(ctx context.Context, invoiceID uuid.UUID, apply func(inv *domain.Invoice) error) (*domain.Invoice, error) {
+	var invoice *domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invoices, err := lockInvoices(tx, []uuid.UUID{invoiceID})
+		if err != nil {
+			return err
+		}
+		invoice = invoices[0]
+		if err := apply(invoice); err != nil {
+			return err
+		}
 
-	// Example format: INV-2023-0001
-	year := time.Now().Year()
-	return fmt.Sprintf("INV-%d-%04d", year, count+1), nil
+		if err := tx.Delete(&domain.Installment{}, "invoice_id = ?", invoiceID).Error; err != nil {
+			return fmt.Errorf("failed to delete installments: %w", err)
+		}
+		if len(invoice.Installments) > 0 {
+			if err := tx.Create(&invoice.Installments).Error; err != nil {
+				return fmt.Errorf("failed to create installments: %w", err)
+			}
+		}
+		return tx.Model(invoice).Select("due_date", "status", "updated_at").Updates(invoice).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
 }
 
-func (r *InvoiceRepository) ClearItems(ctx context.Context, invoiceID uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&domain.InvoiceItem{}, "invoice_id = ?", invoiceID).Error
+// withInstallments loads the invoices' payment schedules in order
+func withInstallments(db *gorm.DB) *gorm.DB {
+	return db.Preload("Installments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence asc")
+	})
+}
+
+// saveInstallments stores what is paid of each installment of the invoice
+// after its balance changed
+func saveInstallments(tx *gorm.DB, invoice *domain.Invoice) error {
+	for i := range invoice.Installments {
+		inst := &invoice.Installments[i]
+		if err := tx.Model(inst).Select("paid_amount", "status", "updated_at").Updates(inst).Error; err != nil {
+			return fmt.Errorf("failed to update installment %d of invoice %s: %w", inst.Sequence, invoice.InvoiceNumber, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LateFeeRepository struct {
+	db *gorm.DB
+}
+
+func NewLateFeeRepository(db *gorm.DB) *LateFeeRepository {
+	return &LateFeeRepository{db: db}
+}
+
+func (r *LateFeeRepository) GetPolicy(ctx context.Context, orgID uuid.UUID) (*domain.LateFeePolicy, error) {
+	var policy domain.LateFeePolicy
+	err := r.db.WithContext(ctx).First(&policy, "organization_id = ?", orgID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrLateFeePolicyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *LateFeeRepository) SavePolicy(ctx context.Context, policy *domain.LateFeePolicy) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "organization_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"type", "amount", "rate", "grace_days", "interval_days", "cap",
+			"mode", "enabled", "updated_at"}),
+	}).Create(policy).Error
+}
+
+func (r *LateFeeRepository) ListPolicies(ctx context.Context) ([]domain.LateFeePolicy, error) {
+	var policies []domain.LateFeePolicy
+	err := r.db.WithContext(ctx).Where("enabled = ?", true).Find(&policies).Error
+	return policies, err
+}
+
+func (r *LateFeeRepository) ListCandidates(ctx context.Context, orgID uuid.UUID) ([]domain.Invoice, error) {
+	var invoices []domain.Invoice
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND status = ? AND balance_amount > 0", orgID, domain.InvoiceStatusOverdue).
+		Where("in_collection_at IS NULL AND deleted_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM late_fee_charges c WHERE c.fee_invoice_id = invoices.id OR c.id = invoices.id)").
+		Order("due_date asc").
+		Find(&invoices).Error
+	return invoices, err
+}
+
+func (r *LateFeeRepository) ListCharges(ctx context.Context, invoiceIDs []uuid.UUID) ([]domain.LateFeeCharge, error) {
+	var charges []domain.LateFeeCharge
+	if len(invoiceIDs) == 0 {
+		return charges, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("invoice_id IN ?", invoiceIDs).
+		Order("accrued_through asc").
+		Find(&charges).Error
+	return charges, err
+}
+
+func (r *LateFeeRepository) ChargeLine(ctx context.Context, invoiceID uuid.UUID, charge func(inv *domain.Invoice, previous []domain.LateFeeCharge) *domain.LateFeeCharge) (*domain.LateFeeCharge, *domain.Invoice, error) {
+	var fee *domain.LateFeeCharge
+	var invoice *domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Payments on the invoice wait here until the fee is on it
+		invoices, err := lockInvoices(tx, []uuid.UUID{invoiceID})
+		if err != nil {
+			return err
+		}
+		invoice = invoices[0]
+
+		var previous []domain.LateFeeCharge
+		if err := tx.Where("invoice_id = ?", invoiceID).Order("accrued_through asc").Find(&previous).Error; err != nil {
+			return err
+		}
+		fee = charge(invoice, previous)
+		if fee == nil {
+			return nil
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(fee)
+		if res.Error != nil {
+			return fmt.Errorf("failed to store late fee: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			fee = nil
+			return nil
+		}
+
+		invoice.AddLateFeeLine(fee)
+		line := invoice.Items[len(invoice.Items)-1]
+		if err := tx.Create(&line).Error; err != nil {
+			return fmt.Errorf("failed to add late fee line: %w", err)
+		}
+		invoice.UpdatedAt = time.Now().UTC()
+		return tx.Model(invoice).Select("late_fee_total", "total_amount", "balance_amount", "updated_at").Updates(invoice).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return fee, invoice, nil
+}
+
+func (r *LateFeeRepository) ClaimCharge(ctx context.Context, charge *domain.LateFeeCharge) (bool, error) {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(charge)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *LateFeeRepository) ReleaseCharge(ctx context.Context, chargeID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&domain.LateFeeCharge{}, "id = ? AND fee_invoice_id IS NULL", chargeID).Error
+}
+
+func (r *LateFeeRepository) SetFeeInvoice(ctx context.Context, chargeID, feeInvoiceID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&domain.LateFeeCharge{}).
+		Where("id = ?", chargeID).
+		Update("fee_invoice_id", feeInvoiceID).Error
+}
+
+func (r *LateFeeRepository) ListUnbilledCharges(ctx context.Context, orgID uuid.UUID, claimedBefore time.Time) ([]domain.LateFeeCharge, error) {
+	var charges []domain.LateFeeCharge
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND mode = ? AND created_at < ?", orgID, domain.LateFeeAsInvoice, claimedBefore).
+		Where("fee_invoice_id IS NULL OR EXISTS (SELECT 1 FROM invoices i WHERE i.id = late_fee_charges.fee_invoice_id AND i.status = ? AND i.deleted_at IS NULL)", domain.InvoiceStatusDraft).
+		Order("created_at asc").
+		Find(&charges).Error
+	return charges, err
+}
@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NumberSequenceRepository struct {
+	db *gorm.DB
+}
+
+func NewNumberSequenceRepository(db *gorm.DB) *NumberSequenceRepository {
+	return &NumberSequenceRepository{db: db}
+}
+
+func (r *NumberSequenceRepository) Get(ctx context.Context, orgID uuid.UUID, docType domain.DocumentType) (*domain.NumberSequence, error) {
+	var seq domain.NumberSequence
+	err := r.db.WithContext(ctx).First(&seq, "organization_id = ? AND document_type = ?", orgID, docType).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return seedSequence(r.db.WithContext(ctx), orgID, docType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &seq, nil
+}
+
+func (r *NumberSequenceRepository) Save(ctx context.Context, seq *domain.NumberSequence) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := lockSequence(tx, seq.OrganizationID, seq.DocumentType)
+		if err != nil {
+			return err
+		}
+		seq.ID = current.ID
+		seq.CreatedAt = current.CreatedAt
+		// Never hand out a number again that was allocated since seq was read
+		if seq.CurrentPeriod == current.CurrentPeriod && seq.NextValue < current.NextValue {
+			seq.NextValue = current.NextValue
+		}
+		return tx.Model(current).
+			Select("prefix", "format", "padding", "reset_period", "fiscal_year_start", "next_value", "current_period", "updated_at").
+			Updates(seq).Error
+	})
+}
+
+// nextNumber allocates the next number of a series inside tx. The sequence
+// row stays locked until tx ends, so concurrent documents of the same series
+// queue up and a rolled back insert gives its number back
+func nextNumber(tx *gorm.DB, orgID uuid.UUID, docType domain.DocumentType, now time.Time) (string, error) {
+	seq, err := lockSequence(tx, orgID, docType)
+	if err != nil {
+		return "", err
+	}
+
+	number := seq.Advance(now)
+	err = tx.Model(seq).Select("next_value", "current_period", "updated_at").Updates(seq).Error
+	if err != nil {
+		return "", err
+	}
+	return number, nil
+}
+
+// lockSequence reads a series FOR UPDATE, creating the default series on first use
+func lockSequence(tx *gorm.DB, orgID uuid.UUID, docType domain.DocumentType) (*domain.NumberSequence, error) {
+	var seq domain.NumberSequence
+	lock := func() error {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&seq, "organization_id = ? AND document_type = ?", orgID, docType).Error
+	}
+
+	err := lock()
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &seq, err
+	}
+
+	seed, err := seedSequence(tx, orgID, docType)
+	if err != nil {
+		return nil, err
+	}
+	// Another transaction may have created the series in the meantime
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(seed).Error; err != nil {
+		return nil, err
+	}
+	if err := lock(); err != nil {
+		return nil, err
+	}
+	return &seq, nil
+}
+
+// seedSequence builds the default series of an organization, continuing after
+// the highest number given out before sequences existed. Counting documents
+// instead would hand out a number again once one of them had been deleted.
+func seedSequence(db *gorm.DB, orgID uuid.UUID, docType domain.DocumentType) (*domain.NumberSequence, error) {
+	seq := domain.DefaultNumberSequence(orgID, docType)
+
+	var query *gorm.DB
+	switch docType {
+	case domain.DocumentInvoice:
+		query = db.Model(&domain.Invoice{}).Where("organization_id = ?", orgID).Select(maxSuffix("invoice_number"))
+	case domain.DocumentCreditNote:
+		query = db.Model(&domain.BillingNote{}).Where("organization_id = ? AND note_type = ?", orgID, domain.BillingNoteCredit).Select(maxSuffix("note_number"))
+	case domain.DocumentDebitNote:
+		query = db.Model(&domain.BillingNote{}).Where("organization_id = ? AND note_type = ?", orgID, domain.BillingNoteDebit).Select(maxSuffix("note_number"))
+	case domain.DocumentEstimate:
+		query = db.Model(&domain.Estimate{}).Where("organization_id = ?", orgID).Select(maxSuffix("estimate_number"))
+	default:
+		return seq, nil
+	}
+
+	var highest int64
+	if err := query.Scan(&highest).Error; err != nil {
+		return nil, err
+	}
+	seq.NextValue = highest + 1
+	return seq, nil
+}
+
+// maxSuffix selects the highest trailing run of digits of a document number
+// column, e.g. 42 for INV-2025-0042, or 0 when there are none
+func maxSuffix(column string) string {
+	return "COALESCE(MAX(CAST(substring(" + column + " from '([0-9]+)$') AS BIGINT)), 0)"
+}
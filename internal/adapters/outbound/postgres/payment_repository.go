@@ -2,11 +2,15 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"erp-billing-service/internal/domain"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentRepository struct {
@@ -21,8 +25,306 @@ func (r *PaymentRepository) Create(ctx context.Context, payment *domain.Payment)
 	return r.db.WithContext(ctx).Create(payment).Error
 }
 
+func (r *PaymentRepository) Record(ctx context.Context, invoiceIDs []uuid.UUID, apply func(invoices []*domain.Invoice) (*domain.Payment, error)) (*domain.Payment, []*domain.Invoice, error) {
+	var invoices []*domain.Invoice
+	var payment *domain.Payment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Concurrent payments on the invoices wait here until this one commits
+		var err error
+		invoices, err = lockInvoices(tx, invoiceIDs)
+		if err != nil {
+			return err
+		}
+
+		payment, err = apply(invoices)
+		if err != nil {
+			return err
+		}
+
+		if payment.IdempotencyKey != nil {
+			var count int64
+			err := tx.Model(&domain.Payment{}).
+				Where("organization_id = ? AND idempotency_key = ?", payment.OrganizationID, *payment.IdempotencyKey).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return domain.ErrPaymentAlreadyRecorded
+			}
+		}
+
+		if err := tx.Omit(clause.Associations).Create(payment).Error; err != nil {
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+		if err := createAllocations(tx, payment); err != nil {
+			return err
+		}
+
+		if entry := domain.CreditEntryForPayment(payment); entry != nil {
+			if err := postCredit(tx, entry); err != nil {
+				return err
+			}
+		}
+		if err := holdDeposits(tx, payment, nil, invoices); err != nil {
+			return err
+		}
+
+		return saveBalances(tx, invoices)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return payment, invoices, nil
+}
+
+func (r *PaymentRepository) Reallocate(ctx context.Context, paymentID uuid.UUID, invoiceIDs []uuid.UUID, apply func(payment *domain.Payment, invoices []*domain.Invoice) error) (*domain.Payment, []*domain.Invoice, error) {
+	var payment *domain.Payment
+	var invoices []*domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		payment, invoices, err = lockPayment(tx, paymentID, invoiceIDs)
+		if err != nil {
+			return err
+		}
+
+		previousUnapplied := payment.Unapplied
+		previous := append([]domain.PaymentAllocation(nil), payment.Allocations...)
+		if err := apply(payment, invoices); err != nil {
+			return err
+		}
+		if err := savePayment(tx, payment); err != nil {
+			return err
+		}
+
+		if entry := domain.CreditEntryForReallocation(payment, previousUnapplied); entry != nil {
+			if err := postCredit(tx, entry); err != nil {
+				return err
+			}
+		}
+		if err := holdDeposits(tx, payment, previous, invoices); err != nil {
+			return err
+		}
+
+		return saveBalances(tx, invoices)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return payment, invoices, nil
+}
+
+func (r *PaymentRepository) Refund(ctx context.Context, paymentID uuid.UUID, apply func(payment *domain.Payment, invoices []*domain.Invoice) (*domain.PaymentRefund, error)) (*domain.Payment, *domain.PaymentRefund, []*domain.Invoice, error) {
+	var payment *domain.Payment
+	var refund *domain.PaymentRefund
+	var invoices []*domain.Invoice
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		payment, invoices, err = lockPayment(tx, paymentID, nil)
+		if err != nil {
+			return err
+		}
+
+		previous := append([]domain.PaymentAllocation(nil), payment.Allocations...)
+		refund, err = apply(payment, invoices)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return fmt.Errorf("failed to create payment refund: %w", err)
+		}
+		if err := savePayment(tx, payment); err != nil {
+			return err
+		}
+
+		if entry := domain.CreditEntryForRefund(payment, refund); entry != nil {
+			if err := postCredit(tx, entry); err != nil {
+				return err
+			}
+		}
+		if err := holdDeposits(tx, payment, previous, invoices); err != nil {
+			return err
+		}
+
+		return saveBalances(tx, invoices)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return payment, refund, invoices, nil
+}
+
+func (r *PaymentRepository) ListRefunds(ctx context.Context, paymentID uuid.UUID) ([]domain.PaymentRefund, error) {
+	var refunds []domain.PaymentRefund
+	err := r.db.WithContext(ctx).Where("payment_id = ?", paymentID).Order("refund_date asc, created_at asc").Find(&refunds).Error
+	return refunds, err
+}
+
+func (r *PaymentRepository) SetRefundReference(ctx context.Context, refundID uuid.UUID, reference string) error {
+	return r.db.WithContext(ctx).Model(&domain.PaymentRefund{}).Where("id = ?", refundID).Update("reference", reference).Error
+}
+
+// lockPayment locks the payment with its allocations, then the invoices it
+// is allocated to together with invoiceIDs
+func lockPayment(tx *gorm.DB, paymentID uuid.UUID, invoiceIDs []uuid.UUID) (*domain.Payment, []*domain.Invoice, error) {
+	var payment domain.Payment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", paymentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, domain.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Where("payment_id = ?", paymentID).Find(&payment.Allocations).Error; err != nil {
+		return nil, nil, err
+	}
+
+	ids := append([]uuid.UUID{}, invoiceIDs...)
+	for _, a := range payment.Allocations {
+		ids = append(ids, a.InvoiceID)
+	}
+	invoices, err := lockInvoices(tx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &payment, invoices, nil
+}
+
+// savePayment replaces the allocations of a locked payment and stores the
+// amounts that follow from them
+func savePayment(tx *gorm.DB, payment *domain.Payment) error {
+	if err := tx.Delete(&domain.PaymentAllocation{}, "payment_id = ?", payment.ID).Error; err != nil {
+		return fmt.Errorf("failed to clear allocations: %w", err)
+	}
+	if err := createAllocations(tx, payment); err != nil {
+		return err
+	}
+	if err := tx.Model(payment).Select("amount", "unapplied", "refunded", "status", "updated_at").Updates(payment).Error; err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	return nil
+}
+
+// lockInvoices locks the invoices FOR UPDATE in ID order, so two
+// transactions locking overlapping sets cannot deadlock
+func lockInvoices(tx *gorm.DB, ids []uuid.UUID) ([]*domain.Invoice, error) {
+	unique := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	var invoices []*domain.Invoice
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Scopes(withInstallments).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&invoices).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(invoices) != len(unique) {
+		return nil, domain.ErrInvoiceNotFound
+	}
+	return invoices, nil
+}
+
+// holdDeposits moves the change in what the payment pays on deposit invoices
+// into the customer's credit and the deposits' held amounts, and marks what a
+// credit payment uses up of held deposits as applied. Taking back a deposit
+// that a final invoice has already used fails for lack of credit.
+func holdDeposits(tx *gorm.DB, payment *domain.Payment, previous []domain.PaymentAllocation, invoices []*domain.Invoice) error {
+	now := time.Now().UTC()
+	for _, entry := range domain.DepositCreditEntries(payment, previous, invoices) {
+		if err := postCredit(tx, entry); err != nil {
+			return err
+		}
+		err := tx.Model(&domain.ProgressBill{}).
+			Where("invoice_id = ?", *entry.InvoiceID).
+			Updates(map[string]interface{}{"held": gorm.Expr("held + ?", entry.Amount), "updated_at": now}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update deposit: %w", err)
+		}
+	}
+
+	for billID, amount := range payment.FromDeposits {
+		// Another invoice may have used the deposit since it was listed
+		res := tx.Model(&domain.ProgressBill{}).
+			Where("id = ? AND held - applied >= ?", billID, amount).
+			Updates(map[string]interface{}{"applied": gorm.Expr("applied + ?", amount), "updated_at": now})
+		if res.Error != nil {
+			return fmt.Errorf("failed to apply deposit: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: deposit %s no longer holds %s", domain.ErrInvalidInput, billID, amount)
+		}
+	}
+	return nil
+}
+
+func createAllocations(tx *gorm.DB, payment *domain.Payment) error {
+	if len(payment.Allocations) == 0 {
+		return nil
+	}
+	if err := tx.Create(&payment.Allocations).Error; err != nil {
+		return fmt.Errorf("failed to create payment allocations: %w", err)
+	}
+	return nil
+}
+
+// saveBalances stores the balance columns and installments of invoices a
+// payment touched, numbering a draft the payment issued; items and notes are
+// left alone
+func saveBalances(tx *gorm.DB, invoices []*domain.Invoice) error {
+	for _, invoice := range invoices {
+		if err := tx.Model(invoice).Select("paid_amount", "early_pay_taken", "balance_amount", "status", "locked_at", "updated_at").Updates(invoice).Error; err != nil {
+			return fmt.Errorf("failed to update balance of invoice %s: %w", invoice.InvoiceNumber, err)
+		}
+		if err := numberIssued(tx, invoice); err != nil {
+			return err
+		}
+		if err := saveInstallments(tx, invoice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PaymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := r.db.WithContext(ctx).Preload("Allocations").First(&payment, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) GetByIdempotencyKey(ctx context.Context, orgID uuid.UUID, key string) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := r.db.WithContext(ctx).Preload("Allocations").First(&payment, "organization_id = ? AND idempotency_key = ?", orgID, key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
 func (r *PaymentRepository) GetByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]domain.Payment, error) {
 	var payments []domain.Payment
-	err := r.db.WithContext(ctx).Where("invoice_id = ?", invoiceID).Find(&payments).Error
+	err := r.db.WithContext(ctx).
+		Preload("Allocations").
+		Where("id IN (?)", r.db.Model(&domain.PaymentAllocation{}).Select("payment_id").Where("invoice_id = ?", invoiceID)).
+		Order("payment_date asc, created_at asc").
+		Find(&payments).Error
+	return payments, err
+}
+
+func (r *PaymentRepository) List(ctx context.Context, filter map[string]interface{}) ([]domain.Payment, error) {
+	var payments []domain.Payment
+	err := r.db.WithContext(ctx).Preload("Allocations").Where(filter).Order("payment_date desc, created_at desc").Find(&payments).Error
 	return payments, err
 }
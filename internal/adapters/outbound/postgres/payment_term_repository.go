@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentTermRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentTermRepository(db *gorm.DB) *PaymentTermRepository {
+	return &PaymentTermRepository{db: db}
+}
+
+func (r *PaymentTermRepository) CreateTerm(ctx context.Context, term *domain.PaymentTerm) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultTerm(tx, term); err != nil {
+			return err
+		}
+		return tx.Create(term).Error
+	})
+}
+
+func (r *PaymentTermRepository) UpdateTerm(ctx context.Context, term *domain.PaymentTerm) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultTerm(tx, term); err != nil {
+			return err
+		}
+		return tx.Save(term).Error
+	})
+}
+
+// clearDefaultTerm takes the default flag off the organization's other terms
+// when term becomes its default
+func clearDefaultTerm(tx *gorm.DB, term *domain.PaymentTerm) error {
+	if !term.IsDefault {
+		return nil
+	}
+	err := tx.Model(&domain.PaymentTerm{}).
+		Where("organization_id = ? AND id <> ? AND is_default", term.OrganizationID, term.ID).
+		Update("is_default", false).Error
+	if err != nil {
+		return fmt.Errorf("failed to clear default payment term: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentTermRepository) DeleteTerm(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.CustomerPaymentTerm{}, "payment_term_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to unassign payment term: %w", err)
+		}
+		return tx.Delete(&domain.PaymentTerm{}, "id = ?", id).Error
+	})
+}
+
+func (r *PaymentTermRepository) GetTerm(ctx context.Context, id uuid.UUID) (*domain.PaymentTerm, error) {
+	return r.first(r.db.WithContext(ctx).Where("id = ?", id))
+}
+
+func (r *PaymentTermRepository) GetTermByCode(ctx context.Context, orgID uuid.UUID, code string) (*domain.PaymentTerm, error) {
+	return r.first(r.db.WithContext(ctx).Where("organization_id = ? AND code = ?", orgID, code))
+}
+
+func (r *PaymentTermRepository) GetDefaultTerm(ctx context.Context, orgID uuid.UUID) (*domain.PaymentTerm, error) {
+	return r.first(r.db.WithContext(ctx).Where("organization_id = ? AND is_default", orgID))
+}
+
+func (r *PaymentTermRepository) GetCustomerTerm(ctx context.Context, orgID, customerID uuid.UUID) (*domain.PaymentTerm, error) {
+	return r.first(r.db.WithContext(ctx).
+		Joins("JOIN customer_payment_terms a ON a.payment_term_id = payment_terms.id").
+		Where("a.organization_id = ? AND a.customer_id = ?", orgID, customerID))
+}
+
+func (r *PaymentTermRepository) first(query *gorm.DB) (*domain.PaymentTerm, error) {
+	var term domain.PaymentTerm
+	err := query.First(&term).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrPaymentTermNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+func (r *PaymentTermRepository) ListTerms(ctx context.Context, orgID uuid.UUID) ([]domain.PaymentTerm, error) {
+	var terms []domain.PaymentTerm
+	err := r.db.WithContext(ctx).Where("organization_id = ?", orgID).Order("code").Find(&terms).Error
+	return terms, err
+}
+
+func (r *PaymentTermRepository) AssignCustomerTerm(ctx context.Context, assignment *domain.CustomerPaymentTerm) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}, {Name: "customer_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"payment_term_id", "updated_at"}),
+	}).Create(assignment).Error
+}
+
+func (r *PaymentTermRepository) UnassignCustomerTerm(ctx context.Context, orgID, customerID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&domain.CustomerPaymentTerm{}, "organization_id = ? AND customer_id = ?", orgID, customerID).Error
+}
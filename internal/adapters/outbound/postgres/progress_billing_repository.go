@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProgressBillingRepository struct {
+	db *gorm.DB
+}
+
+func NewProgressBillingRepository(db *gorm.DB) *ProgressBillingRepository {
+	return &ProgressBillingRepository{db: db}
+}
+
+func (r *ProgressBillingRepository) Reserve(ctx context.Context, bill *domain.ProgressBill, check func(billed, deposited money.Amount) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if bill.SourceID == nil {
+			// A retainer bills no job, so there is nothing to exceed
+			if err := check(money.Zero, money.Zero); err != nil {
+				return err
+			}
+			return tx.Create(bill).Error
+		}
+
+		// Concurrent bills of the job wait here until this one commits
+		if err := lockSource(tx, bill); err != nil {
+			return err
+		}
+		billed, deposited, err := sumBills(tx, bill.SourceType, *bill.SourceID)
+		if err != nil {
+			return err
+		}
+		if err := check(billed, deposited); err != nil {
+			return err
+		}
+		if err := tx.Create(bill).Error; err != nil {
+			return fmt.Errorf("failed to create progress bill: %w", err)
+		}
+		if bill.Kind != domain.InvoiceKindProgress {
+			return nil
+		}
+		return setBilled(tx, bill.SourceType, *bill.SourceID, billed.Add(bill.Amount))
+	})
+}
+
+// lockSource locks the work order or estimate a bill is made against and
+// refuses a progress bill of one already invoiced in full
+func lockSource(tx *gorm.DB, bill *domain.ProgressBill) error {
+	locked := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	switch bill.SourceType {
+	case domain.BillingSourceEstimate:
+		var estimate domain.Estimate
+		err := locked.First(&estimate, "id = ?", *bill.SourceID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrEstimateNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if bill.Kind == domain.InvoiceKindProgress && estimate.IsConverted() {
+			return fmt.Errorf("%w: %s", domain.ErrEstimateConverted, estimate.EstimateNumber)
+		}
+	case domain.BillingSourceWorkOrder:
+		var wo domain.WorkOrderRM
+		err := locked.First(&wo, "id = ?", *bill.SourceID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrWorkOrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if bill.Kind == domain.InvoiceKindProgress && (wo.BillingStatus == domain.WorkOrderBillingInvoiced || wo.InvoiceID != nil) {
+			return fmt.Errorf("%w: %s", domain.ErrWorkOrderAlreadyInvoiced, wo.ID)
+		}
+	default:
+		return fmt.Errorf("%w: unknown billing source %q", domain.ErrInvalidInput, bill.SourceType)
+	}
+	return nil
+}
+
+// sumBills adds up the progress bills and deposits of a job whose invoices
+// are not void. Bills still waiting for their invoice count too.
+func sumBills(tx *gorm.DB, sourceType domain.BillingSourceType, sourceID uuid.UUID) (money.Amount, money.Amount, error) {
+	var totals struct {
+		Billed    money.Amount
+		Deposited money.Amount
+	}
+	err := tx.Model(&domain.ProgressBill{}).
+		Select("COALESCE(SUM(CASE WHEN progress_bills.kind = ? THEN progress_bills.amount ELSE 0 END), 0) AS billed, "+
+			"COALESCE(SUM(CASE WHEN progress_bills.kind = ? THEN progress_bills.amount ELSE 0 END), 0) AS deposited",
+			domain.InvoiceKindProgress, domain.InvoiceKindDeposit).
+		Joins("LEFT JOIN invoices ON invoices.id = progress_bills.invoice_id").
+		Where("progress_bills.source_type = ? AND progress_bills.source_id = ?", sourceType, sourceID).
+		Where("invoices.id IS NULL OR invoices.status <> ?", domain.InvoiceStatusVoid).
+		Scan(&totals).Error
+	return totals.Billed, totals.Deposited, err
+}
+
+// setBilled stores what a job has billed through progress invoices. A job
+// with anything billed cannot be invoiced in full.
+func setBilled(tx *gorm.DB, sourceType domain.BillingSourceType, sourceID uuid.UUID, billed money.Amount) error {
+	now := time.Now().UTC()
+	switch sourceType {
+	case domain.BillingSourceEstimate:
+		updates := map[string]interface{}{"billed_amount": billed, "updated_at": now}
+		if billed.IsPositive() {
+			// Billing part of the estimate accepts it
+			updates["status"] = domain.EstimateStatusAccepted
+		}
+		return tx.Model(&domain.Estimate{}).Where("id = ?", sourceID).Updates(updates).Error
+	case domain.BillingSourceWorkOrder:
+		return tx.Model(&domain.WorkOrderRM{}).Where("id = ?", sourceID).Update("billed_amount", billed).Error
+	}
+	return nil
+}
+
+func (r *ProgressBillingRepository) Release(ctx context.Context, bill *domain.ProgressBill) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if bill.SourceID != nil {
+			if err := lockSource(tx, &domain.ProgressBill{SourceType: bill.SourceType, SourceID: bill.SourceID}); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&domain.ProgressBill{}, "id = ?", bill.ID).Error; err != nil {
+			return err
+		}
+		if bill.SourceID == nil || bill.Kind != domain.InvoiceKindProgress {
+			return nil
+		}
+		billed, _, err := sumBills(tx, bill.SourceType, *bill.SourceID)
+		if err != nil {
+			return err
+		}
+		return setBilled(tx, bill.SourceType, *bill.SourceID, billed)
+	})
+}
+
+func (r *ProgressBillingRepository) LinkInvoice(ctx context.Context, billID, invoiceID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&domain.ProgressBill{}).
+		Where("id = ?", billID).
+		Updates(map[string]interface{}{"invoice_id": invoiceID, "updated_at": time.Now().UTC()}).Error
+}
+
+func (r *ProgressBillingRepository) GetByInvoice(ctx context.Context, invoiceID uuid.UUID) (*domain.ProgressBill, error) {
+	var bill domain.ProgressBill
+	err := r.db.WithContext(ctx).First(&bill, "invoice_id = ?", invoiceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrProgressBillNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+func (r *ProgressBillingRepository) Recount(ctx context.Context, invoiceID uuid.UUID) error {
+	bill, err := r.GetByInvoice(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+	if bill.SourceID == nil || bill.Kind != domain.InvoiceKindProgress {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockSource(tx, &domain.ProgressBill{SourceType: bill.SourceType, SourceID: bill.SourceID}); err != nil {
+			return err
+		}
+		billed, _, err := sumBills(tx, bill.SourceType, *bill.SourceID)
+		if err != nil {
+			return err
+		}
+		return setBilled(tx, bill.SourceType, *bill.SourceID, billed)
+	})
+}
+
+func (r *ProgressBillingRepository) ListBySource(ctx context.Context, sourceType domain.BillingSourceType, sourceID uuid.UUID) ([]domain.ProgressBill, error) {
+	var bills []domain.ProgressBill
+	err := r.db.WithContext(ctx).
+		Where("source_type = ? AND source_id = ?", sourceType, sourceID).
+		Order("created_at asc").
+		Find(&bills).Error
+	return bills, err
+}
+
+func (r *ProgressBillingRepository) ListDeposits(ctx context.Context, orgID, customerID uuid.UUID) ([]domain.ProgressBill, error) {
+	var bills []domain.ProgressBill
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND customer_id = ? AND kind = ?", orgID, customerID, domain.InvoiceKindDeposit).
+		Order("created_at asc").
+		Find(&bills).Error
+	return bills, err
+}
+
+func (r *ProgressBillingRepository) ListHeldDeposits(ctx context.Context, orgID, customerID uuid.UUID, currency string) ([]domain.ProgressBill, error) {
+	var bills []domain.ProgressBill
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND customer_id = ? AND currency = ? AND kind = ?", orgID, customerID, currency, domain.InvoiceKindDeposit).
+		Where("held > applied").
+		Order("created_at asc").
+		Find(&bills).Error
+	return bills, err
+}
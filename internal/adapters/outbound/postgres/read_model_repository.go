@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -133,3 +134,52 @@ func (r *ReadModelRepository) SearchContacts(ctx context.Context, orgID uuid.UUI
 	err := db.Where("(first_name ILIKE ? OR last_name ILIKE ? OR email ILIKE ?)", q, q, q).Limit(20).Find(&res).Error
 	return res, err
 }
+
+func (r *ReadModelRepository) GetWorkOrder(ctx context.Context, id uuid.UUID) (*domain.WorkOrderRM, error) {
+	var rm domain.WorkOrderRM
+	err := r.db.WithContext(ctx).First(&rm, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrWorkOrderNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rm, nil
+}
+
+func (r *ReadModelRepository) ListWorkOrderServiceLines(ctx context.Context, workOrderID uuid.UUID) ([]domain.WorkOrderServiceLineRM, error) {
+	var res []domain.WorkOrderServiceLineRM
+	err := r.db.WithContext(ctx).Where("work_order_id = ?", workOrderID).Order("id").Find(&res).Error
+	return res, err
+}
+
+func (r *ReadModelRepository) ListWorkOrderPartLines(ctx context.Context, workOrderID uuid.UUID) ([]domain.WorkOrderPartLineRM, error) {
+	var res []domain.WorkOrderPartLineRM
+	err := r.db.WithContext(ctx).Where("work_order_id = ?", workOrderID).Order("id").Find(&res).Error
+	return res, err
+}
+
+func (r *ReadModelRepository) ReserveWorkOrderForBilling(ctx context.Context, workOrderID uuid.UUID) (bool, error) {
+	// Compare-and-set on the same rules as WorkOrderRM.ReserveForBilling, so
+	// two concurrent requests cannot both bill the work order, nor bill in
+	// full one that is being billed in progress invoices
+	res := r.db.WithContext(ctx).Model(&domain.WorkOrderRM{}).
+		Where("id = ? AND (billing_status IS NULL OR billing_status <> ?) AND invoice_id IS NULL AND COALESCE(billed_amount, 0) = 0", workOrderID, domain.WorkOrderBillingInvoiced).
+		Update("billing_status", domain.WorkOrderBillingInvoiced)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *ReadModelRepository) LinkWorkOrderInvoice(ctx context.Context, workOrderID uuid.UUID, invoiceID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&domain.WorkOrderRM{}).
+		Where("id = ?", workOrderID).
+		Update("invoice_id", invoiceID).Error
+}
+
+func (r *ReadModelRepository) ReleaseWorkOrderBilling(ctx context.Context, workOrderID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&domain.WorkOrderRM{}).
+		Where("id = ?", workOrderID).
+		Updates(map[string]interface{}{"billing_status": domain.WorkOrderBillingUnbilled, "invoice_id": nil}).Error
+}
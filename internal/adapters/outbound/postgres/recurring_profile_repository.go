@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RecurringProfileRepository struct {
+	db *gorm.DB
+}
+
+func NewRecurringProfileRepository(db *gorm.DB) *RecurringProfileRepository {
+	return &RecurringProfileRepository{db: db}
+}
+
+func (r *RecurringProfileRepository) Create(ctx context.Context, profile *domain.RecurringProfile) error {
+	return r.db.WithContext(ctx).Create(profile).Error
+}
+
+func (r *RecurringProfileRepository) Update(ctx context.Context, profile *domain.RecurringProfile) error {
+	return r.db.WithContext(ctx).Save(profile).Error
+}
+
+func (r *RecurringProfileRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.RecurringProfile, error) {
+	var profile domain.RecurringProfile
+	err := r.db.WithContext(ctx).First(&profile, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *RecurringProfileRepository) List(ctx context.Context, filter map[string]interface{}) ([]domain.RecurringProfile, error) {
+	var profiles []domain.RecurringProfile
+	err := r.db.WithContext(ctx).Where(filter).Order("created_at desc").Find(&profiles).Error
+	return profiles, err
+}
+
+func (r *RecurringProfileRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]domain.RecurringProfile, error) {
+	var profiles []domain.RecurringProfile
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", domain.RecurringProfileActive, now).
+		Order("next_run_at asc").
+		Limit(limit).
+		Find(&profiles).Error
+	return profiles, err
+}
+
+func (r *RecurringProfileRepository) AdvanceSchedule(ctx context.Context, profile *domain.RecurringProfile, fromCount int) (bool, error) {
+	profile.UpdatedAt = time.Now().UTC()
+	res := r.db.WithContext(ctx).Model(&domain.RecurringProfile{}).
+		Where("id = ? AND status = ? AND occurrence_count = ?", profile.ID, domain.RecurringProfileActive, fromCount).
+		Updates(map[string]interface{}{
+			"next_run_at":      profile.NextRunAt,
+			"last_run_at":      profile.LastRunAt,
+			"occurrence_count": profile.OccurrenceCount,
+			"status":           profile.Status,
+			"updated_at":       profile.UpdatedAt,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (r *RecurringProfileRepository) ClaimRun(ctx context.Context, run *domain.RecurringRun) (bool, error) {
+	db := r.db.WithContext(ctx)
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(run)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// A failed or abandoned attempt may be retried; anything else means the occurrence is taken
+	now := time.Now().UTC()
+	res = db.Model(&domain.RecurringRun{}).
+		Where("profile_id = ? AND scheduled_for = ?", run.ProfileID, run.ScheduledFor).
+		Where("(status = ? OR (status = ? AND updated_at < ?))", domain.RecurringRunFailed, domain.RecurringRunPending, now.Add(-domain.RunLease)).
+		Updates(map[string]interface{}{"status": domain.RecurringRunPending, "error": "", "updated_at": now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, db.First(run, "profile_id = ? AND scheduled_for = ?", run.ProfileID, run.ScheduledFor).Error
+}
+
+func (r *RecurringProfileRepository) UpdateRun(ctx context.Context, run *domain.RecurringRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+func (r *RecurringProfileRepository) ListRuns(ctx context.Context, profileID uuid.UUID) ([]domain.RecurringRun, error) {
+	var runs []domain.RecurringRun
+	err := r.db.WithContext(ctx).Where("profile_id = ?", profileID).Order("scheduled_for desc").Find(&runs).Error
+	return runs, err
+}
@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SubscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionRepository(db *gorm.DB) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db}
+}
+
+func (r *SubscriptionRepository) CreatePlan(ctx context.Context, plan *domain.SubscriptionPlan) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		err := tx.Model(&domain.SubscriptionPlan{}).
+			Where("organization_id = ? AND code = ?", plan.OrganizationID, plan.Code).
+			Count(&taken).Error
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			return fmt.Errorf("%w: %s", domain.ErrPlanCodeTaken, plan.Code)
+		}
+		return tx.Create(plan).Error
+	})
+}
+
+// UpdatePlan saves the plan itself; its components are left as they are
+func (r *SubscriptionRepository) UpdatePlan(ctx context.Context, plan *domain.SubscriptionPlan) error {
+	return r.db.WithContext(ctx).Omit(clause.Associations).Save(plan).Error
+}
+
+func (r *SubscriptionRepository) GetPlan(ctx context.Context, id uuid.UUID) (*domain.SubscriptionPlan, error) {
+	var plan domain.SubscriptionPlan
+	err := r.db.WithContext(ctx).Preload("Components").First(&plan, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *SubscriptionRepository) ListPlans(ctx context.Context, orgID uuid.UUID) ([]domain.SubscriptionPlan, error) {
+	var plans []domain.SubscriptionPlan
+	err := r.db.WithContext(ctx).
+		Preload("Components").
+		Where("organization_id = ?", orgID).
+		Order("code asc").
+		Find(&plans).Error
+	return plans, err
+}
+
+func (r *SubscriptionRepository) Create(ctx context.Context, sub *domain.Subscription) error {
+	return r.db.WithContext(ctx).Create(sub).Error
+}
+
+func (r *SubscriptionRepository) Update(ctx context.Context, sub *domain.Subscription) error {
+	return r.db.WithContext(ctx).Save(sub).Error
+}
+
+func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Subscription, error) {
+	var sub domain.Subscription
+	err := r.db.WithContext(ctx).First(&sub, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *SubscriptionRepository) List(ctx context.Context, filter map[string]interface{}) ([]domain.Subscription, error) {
+	var subs []domain.Subscription
+	err := r.db.WithContext(ctx).Where(filter).Order("created_at desc").Find(&subs).Error
+	return subs, err
+}
+
+func (r *SubscriptionRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]domain.Subscription, error) {
+	var subs []domain.Subscription
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND (current_period_end <= ? OR ends_at <= ?)", domain.SubscriptionActive, now, now).
+		Order("current_period_end asc").
+		Limit(limit).
+		Find(&subs).Error
+	return subs, err
+}
+
+func (r *SubscriptionRepository) ChangePlan(ctx context.Context, sub *domain.Subscription, change *domain.SubscriptionPlanChange) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&domain.Subscription{}).
+			Where("id = ? AND plan_id = ? AND status = ?", sub.ID, change.FromPlanID, domain.SubscriptionActive).
+			Updates(map[string]interface{}{"plan_id": sub.PlanID, "plan_since": sub.PlanSince, "updated_at": sub.UpdatedAt})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: the subscription changed while its plan was being changed", domain.ErrInvalidInput)
+		}
+		return tx.Create(change).Error
+	})
+}
+
+func (r *SubscriptionRepository) ListPlanChanges(ctx context.Context, subscriptionID uuid.UUID, since time.Time) ([]domain.SubscriptionPlanChange, error) {
+	var changes []domain.SubscriptionPlanChange
+	err := r.db.WithContext(ctx).
+		Where("subscription_id = ? AND effective_at >= ?", subscriptionID, since).
+		Order("effective_at asc, created_at asc").
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *SubscriptionRepository) RecordUsage(ctx context.Context, record *domain.UsageRecord) (bool, error) {
+	db := r.db.WithContext(ctx)
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+
+	want := *record
+	if err := db.First(record, "organization_id = ? AND idempotency_key = ?", want.OrganizationID, want.IdempotencyKey).Error; err != nil {
+		return false, err
+	}
+	if record.SubscriptionID != want.SubscriptionID || record.Metric != want.Metric || record.Quantity != want.Quantity {
+		return false, fmt.Errorf("%w: %s", domain.ErrUsageKeyReused, want.IdempotencyKey)
+	}
+	return false, nil
+}
+
+func (r *SubscriptionRepository) ListUsage(ctx context.Context, subscriptionID uuid.UUID, start, end time.Time) ([]domain.UsageRecord, error) {
+	var records []domain.UsageRecord
+	query := r.db.WithContext(ctx).Where("subscription_id = ? AND occurred_at >= ?", subscriptionID, start)
+	if !end.IsZero() {
+		query = query.Where("occurred_at < ?", end)
+	}
+	err := query.Order("occurred_at asc, created_at asc").Find(&records).Error
+	return records, err
+}
+
+func (r *SubscriptionRepository) ListUnbilledUsage(ctx context.Context, subscriptionID uuid.UUID, start, before time.Time) ([]domain.UsageRecord, error) {
+	var records []domain.UsageRecord
+	query := r.db.WithContext(ctx).Where("subscription_id = ? AND occurred_at >= ? AND invoice_id IS NULL", subscriptionID, start)
+	if !before.IsZero() {
+		query = query.Where("occurred_at < ?", before)
+	}
+	err := query.Order("occurred_at asc, created_at asc").Find(&records).Error
+	return records, err
+}
+
+func (r *SubscriptionRepository) ClaimRun(ctx context.Context, run *domain.SubscriptionRun) (bool, error) {
+	db := r.db.WithContext(ctx)
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(run)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return true, nil
+	}
+
+	// A failed or abandoned attempt may be retried; anything else means the period is taken
+	now := time.Now().UTC()
+	res = db.Model(&domain.SubscriptionRun{}).
+		Where("subscription_id = ? AND period_start = ?", run.SubscriptionID, run.PeriodStart).
+		Where("(status = ? OR (status = ? AND updated_at < ?))", domain.RecurringRunFailed, domain.RecurringRunPending, now.Add(-domain.RunLease)).
+		Updates(map[string]interface{}{"status": domain.RecurringRunPending, "period_end": run.PeriodEnd, "error": "", "updated_at": now})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, db.First(run, "subscription_id = ? AND period_start = ?", run.SubscriptionID, run.PeriodStart).Error
+}
+
+func (r *SubscriptionRepository) UpdateRun(ctx context.Context, run *domain.SubscriptionRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+func (r *SubscriptionRepository) ListRuns(ctx context.Context, subscriptionID uuid.UUID) ([]domain.SubscriptionRun, error) {
+	var runs []domain.SubscriptionRun
+	err := r.db.WithContext(ctx).Where("subscription_id = ?", subscriptionID).Order("period_start desc").Find(&runs).Error
+	return runs, err
+}
+
+func (r *SubscriptionRepository) ClosePeriod(ctx context.Context, sub *domain.Subscription, periodStart time.Time, run *domain.SubscriptionRun, usageIDs []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&domain.Subscription{}).
+			Where("id = ? AND current_period_start = ? AND status = ?", sub.ID, periodStart, domain.SubscriptionActive).
+			Updates(map[string]interface{}{
+				"status":               sub.Status,
+				"current_period_start": sub.CurrentPeriodStart,
+				"current_period_end":   sub.CurrentPeriodEnd,
+				"period_count":         sub.PeriodCount,
+				"updated_at":           sub.UpdatedAt,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("failed to advance subscription: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w: the period of %s was closed meanwhile", domain.ErrInvalidInput, periodStart.Format("2006-01-02"))
+		}
+
+		if len(usageIDs) > 0 && run.InvoiceID != nil {
+			res := tx.Model(&domain.UsageRecord{}).
+				Where("id IN ? AND invoice_id IS NULL", usageIDs).
+				Update("invoice_id", *run.InvoiceID)
+			if res.Error != nil {
+				return fmt.Errorf("failed to mark usage billed: %w", res.Error)
+			}
+			if res.RowsAffected != int64(len(usageIDs)) {
+				return fmt.Errorf("%w: usage was billed meanwhile", domain.ErrInvalidInput)
+			}
+		}
+		return tx.Save(run).Error
+	})
+}
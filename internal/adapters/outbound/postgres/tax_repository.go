@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TaxRepository struct {
+	db *gorm.DB
+}
+
+func NewTaxRepository(db *gorm.DB) *TaxRepository {
+	return &TaxRepository{db: db}
+}
+
+func (r *TaxRepository) CreateRate(ctx context.Context, rate *domain.TaxRate) error {
+	return r.db.WithContext(ctx).Create(rate).Error
+}
+
+func (r *TaxRepository) UpdateRate(ctx context.Context, rate *domain.TaxRate) error {
+	return r.db.WithContext(ctx).Save(rate).Error
+}
+
+func (r *TaxRepository) GetRateByID(ctx context.Context, id uuid.UUID) (*domain.TaxRate, error) {
+	var rate domain.TaxRate
+	err := r.db.WithContext(ctx).First(&rate, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *TaxRepository) ListRates(ctx context.Context, orgID uuid.UUID) ([]domain.TaxRate, error) {
+	var rates []domain.TaxRate
+	err := r.db.WithContext(ctx).Where("organization_id = ?", orgID).Order("code").Find(&rates).Error
+	return rates, err
+}
+
+func (r *TaxRepository) CreateGroup(ctx context.Context, group *domain.TaxGroup) error {
+	return r.db.WithContext(ctx).Omit("Components.TaxRate").Create(group).Error
+}
+
+func (r *TaxRepository) UpdateGroup(ctx context.Context, group *domain.TaxGroup) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&domain.TaxGroupComponent{}, "tax_group_id = ?", group.ID).Error; err != nil {
+			return fmt.Errorf("failed to clear tax group components: %w", err)
+		}
+		return tx.Omit("Components.TaxRate").Save(group).Error
+	})
+}
+
+func (r *TaxRepository) GetGroupByID(ctx context.Context, id uuid.UUID) (*domain.TaxGroup, error) {
+	var group domain.TaxGroup
+	err := r.db.WithContext(ctx).
+		Preload("Components", func(db *gorm.DB) *gorm.DB { return db.Order("sequence") }).
+		Preload("Components.TaxRate").
+		First(&group, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *TaxRepository) ListGroups(ctx context.Context, orgID uuid.UUID) ([]domain.TaxGroup, error) {
+	var groups []domain.TaxGroup
+	err := r.db.WithContext(ctx).
+		Preload("Components", func(db *gorm.DB) *gorm.DB { return db.Order("sequence") }).
+		Preload("Components.TaxRate").
+		Where("organization_id = ?", orgID).
+		Order("code").
+		Find(&groups).Error
+	return groups, err
+}
+
+func (r *TaxRepository) ResolveCode(ctx context.Context, orgID uuid.UUID, code string) ([]domain.TaxRate, error) {
+	db := r.db.WithContext(ctx)
+
+	var group domain.TaxGroup
+	err := db.Where("organization_id = ? AND code = ? AND active = ?", orgID, code, true).First(&group).Error
+	switch {
+	case err == nil:
+		var rates []domain.TaxRate
+		err := db.Table("tax_rates").
+			Select("tax_rates.*").
+			Joins("JOIN tax_group_components c ON c.tax_rate_id = tax_rates.id").
+			Where("c.tax_group_id = ? AND tax_rates.active = ?", group.ID, true).
+			Order("c.sequence").
+			Find(&rates).Error
+		return rates, err
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	var rate domain.TaxRate
+	err = db.Where("organization_id = ? AND code = ? AND active = ?", orgID, code, true).First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", domain.ErrTaxCodeNotFound, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []domain.TaxRate{rate}, nil
+}
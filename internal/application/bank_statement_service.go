@@ -0,0 +1,425 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/bankstatement"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// BankStatementService imports bank statements and matches the money
+// received to open invoices. Certain matches can be recorded right away;
+// everything else waits in a review queue until someone confirms or
+// ignores it. Payments are recorded through PaymentService, keyed on the
+// statement line, so a line is never paid twice.
+type BankStatementService struct {
+	statementRepo domain.BankStatementRepository
+	invoiceRepo   domain.InvoiceRepository
+	rmRepo        domain.ReadModelRepository
+	payments      *PaymentService
+}
+
+func NewBankStatementService(statementRepo domain.BankStatementRepository, invoiceRepo domain.InvoiceRepository, rmRepo domain.ReadModelRepository, payments *PaymentService) *BankStatementService {
+	return &BankStatementService{
+		statementRepo: statementRepo,
+		invoiceRepo:   invoiceRepo,
+		rmRepo:        rmRepo,
+		payments:      payments,
+	}
+}
+
+// ImportStatement parses a statement file, skips transactions imported
+// before and matches the money received to open invoices
+func (s *BankStatementService) ImportStatement(ctx context.Context, orgID uuid.UUID, req dto.ImportStatementRequest, data []byte, performedBy string) (*dto.BankStatementResponse, error) {
+	var format bankstatement.Format
+	var err error
+	if req.Format != "" {
+		format, err = bankstatement.ParseFormat(req.Format)
+	} else {
+		format, err = bankstatement.Detect(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+	parsed, err := bankstatement.Parse(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	statement := &domain.BankStatement{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Format:         string(format),
+		FileName:       req.FileName,
+		StatementRef:   truncate(parsed.ID, 100),
+		Account:        truncate(parsed.Account, 50),
+		Currency:       strings.ToUpper(parsed.Currency),
+		ImportedBy:     performedBy,
+	}
+	if statement.Currency == "" {
+		statement.Currency = money.DefaultCurrency
+	}
+
+	lines := make([]domain.BankStatementLine, 0, len(parsed.Transactions))
+	fingerprints := make([]string, 0, len(parsed.Transactions))
+	occurrences := make(map[string]int)
+	for _, tx := range parsed.Transactions {
+		currency := strings.ToUpper(tx.Currency)
+		if currency == "" {
+			currency = statement.Currency
+		}
+		line := domain.BankStatementLine{
+			ID:                  uuid.New(),
+			OrganizationID:      orgID,
+			StatementID:         statement.ID,
+			BookingDate:         tx.BookingDate,
+			ValueDate:           tx.ValueDate,
+			Amount:              tx.Amount.Round(currency, domain.MoneyRounding),
+			Currency:            currency,
+			Reference:           truncate(tx.Reference, 255),
+			Description:         tx.Description,
+			CounterpartyName:    truncate(tx.CounterpartyName, 255),
+			CounterpartyAccount: truncate(tx.CounterpartyAccount, 50),
+			BankRef:             truncate(tx.BankRef, 100),
+		}
+		base := domain.StatementLineFingerprint(statement.Account, &line, 0)
+		line.Fingerprint = domain.StatementLineFingerprint(statement.Account, &line, occurrences[base])
+		occurrences[base]++
+		lines = append(lines, line)
+		fingerprints = append(fingerprints, line.Fingerprint)
+	}
+
+	existing, err := s.statementRepo.ExistingFingerprints(ctx, orgID, fingerprints)
+	if err != nil {
+		return nil, err
+	}
+	summary := &dto.StatementSummary{}
+	for _, line := range lines {
+		if existing[line.Fingerprint] {
+			summary.Skipped++
+			continue
+		}
+		statement.Lines = append(statement.Lines, line)
+	}
+
+	now := time.Now().UTC()
+	autoApply, err := s.matchLines(ctx, orgID, statement.Lines, req.AutoApply, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.statementRepo.Create(ctx, statement); err != nil {
+		return nil, fmt.Errorf("failed to store bank statement: %w", err)
+	}
+
+	for i := range statement.Lines {
+		line := &statement.Lines[i]
+		if !autoApply[line.ID] {
+			continue
+		}
+		if err := s.applyLine(ctx, line, line.MatchedInvoiceID, nil, domain.DecisionAutoApplied, "", performedBy); err != nil {
+			// The line stays in the review queue with its suggestion
+			log.Printf("Failed to auto-apply statement line %s: %v", line.ID, err)
+		}
+	}
+
+	summary.Imported = len(statement.Lines)
+	for _, line := range statement.Lines {
+		switch line.Status {
+		case domain.StatementLineApplied:
+			summary.Applied++
+		case domain.StatementLineSuggested:
+			summary.Suggested++
+		case domain.StatementLineUnmatched:
+			summary.Unmatched++
+		case domain.StatementLineIgnored:
+			summary.Ignored++
+		}
+	}
+
+	res := mapStatementToResponse(statement)
+	res.Summary = summary
+	return &res, nil
+}
+
+// matchLines matches the lines against the organization's open invoices and
+// returns the lines certain enough to auto-apply. Money paid out is not a
+// customer payment and is ignored. When autoApply is set the balances are
+// reduced as lines are planned, so two lines cannot both settle one invoice.
+func (s *BankStatementService) matchLines(ctx context.Context, orgID uuid.UUID, lines []domain.BankStatementLine, autoApply bool, now time.Time) (map[uuid.UUID]bool, error) {
+	openByCurrency := make(map[string][]*domain.Invoice)
+	customerNames := make(map[uuid.UUID][]string)
+	planned := make(map[uuid.UUID]bool)
+
+	for i := range lines {
+		line := &lines[i]
+		if !line.Amount.IsPositive() {
+			line.Decide(domain.DecisionIgnored, nil, "money paid out", "System", now)
+			continue
+		}
+
+		open, ok := openByCurrency[line.Currency]
+		if !ok {
+			invoices, err := s.invoiceRepo.ListOpenForOrganization(ctx, orgID, line.Currency)
+			if err != nil {
+				return nil, err
+			}
+			for i := range invoices {
+				inv := &invoices[i]
+				open = append(open, inv)
+				if _, seen := customerNames[inv.CustomerID]; !seen {
+					customerNames[inv.CustomerID] = s.customerNames(ctx, inv.CustomerID)
+				}
+			}
+			openByCurrency[line.Currency] = open
+		}
+
+		match := domain.MatchStatementLine(line, open, customerNames)
+		line.Apply(match)
+		if autoApply && match.AutoApply() {
+			planned[line.ID] = true
+			for _, inv := range open {
+				if inv.ID == *match.InvoiceID {
+					inv.ApplyPayment(line.Amount)
+				}
+			}
+		}
+	}
+	return planned, nil
+}
+
+func (s *BankStatementService) customerNames(ctx context.Context, customerID uuid.UUID) []string {
+	customer, err := s.rmRepo.GetCustomer(ctx, customerID)
+	if err != nil || customer == nil {
+		return nil
+	}
+	return []string{customer.DisplayName, customer.CompanyName}
+}
+
+// ConfirmStatementLine records a line in the review queue as a payment of
+// the chosen invoice or customer, or of its suggested match
+func (s *BankStatementService) ConfirmStatementLine(ctx context.Context, orgID, lineID uuid.UUID, req dto.ConfirmStatementLineRequest, performedBy string) (*dto.StatementLineResponse, error) {
+	line, err := s.reviewLine(ctx, orgID, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if !line.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: only money received can be recorded as a payment", domain.ErrInvalidInput)
+	}
+
+	invoiceID, customerID := req.InvoiceID, req.CustomerID
+	if invoiceID == nil && customerID == nil {
+		invoiceID, customerID = line.MatchedInvoiceID, line.MatchedCustomerID
+	}
+	if invoiceID == nil && customerID == nil {
+		return nil, fmt.Errorf("%w: choose the invoice or customer the line pays", domain.ErrInvalidInput)
+	}
+	if invoiceID != nil {
+		customerID = nil
+	}
+
+	if err := s.applyLine(ctx, line, invoiceID, customerID, domain.DecisionConfirmed, req.Notes, performedBy); err != nil {
+		return nil, err
+	}
+	res := mapStatementLineToResponse(line)
+	return &res, nil
+}
+
+// IgnoreStatementLine takes a line that is not a customer payment out of the
+// review queue
+func (s *BankStatementService) IgnoreStatementLine(ctx context.Context, orgID, lineID uuid.UUID, req dto.IgnoreStatementLineRequest, performedBy string) (*dto.StatementLineResponse, error) {
+	line, err := s.reviewLine(ctx, orgID, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if err := line.Decide(domain.DecisionIgnored, nil, req.Notes, performedBy, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := s.statementRepo.SaveDecision(ctx, line); err != nil {
+		return nil, err
+	}
+	res := mapStatementLineToResponse(line)
+	return &res, nil
+}
+
+func (s *BankStatementService) reviewLine(ctx context.Context, orgID, lineID uuid.UUID) (*domain.BankStatementLine, error) {
+	line, err := s.statementRepo.GetLine(ctx, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if line.OrganizationID != orgID {
+		return nil, domain.ErrStatementLineNotFound
+	}
+	return line, nil
+}
+
+// applyLine records the line as a payment and stores the decision. The
+// payment's idempotency key is the line, so a retry after a failure to
+// store the decision does not pay twice.
+func (s *BankStatementService) applyLine(ctx context.Context, line *domain.BankStatementLine, invoiceID, customerID *uuid.UUID, decision domain.StatementDecision, notes, performedBy string) error {
+	now := time.Now().UTC()
+	paidAt := line.BookingDate
+	if paidAt.IsZero() || paidAt.After(now) {
+		paidAt = now
+	}
+	ref := line.Reference
+	if ref == "" {
+		ref = line.BankRef
+	}
+	paymentNotes := fmt.Sprintf("Bank statement line %s", line.ID)
+	if line.CounterpartyName != "" {
+		paymentNotes += " from " + line.CounterpartyName
+	}
+	key := "bank-line:" + line.ID.String()
+
+	var payment *dto.PaymentResponse
+	var err error
+	if invoiceID != nil {
+		payment, err = s.payments.RecordPayment(ctx, line.OrganizationID, *invoiceID, dto.RecordPaymentRequest{
+			Amount:         line.Amount,
+			PaymentMethod:  domain.PaymentMethodBankTransfer,
+			PaymentDate:    &paidAt,
+			TransactionRef: truncate(ref, 100),
+			Notes:          paymentNotes,
+			IdempotencyKey: key,
+		}, performedBy)
+	} else {
+		payment, err = s.payments.RecordCustomerPayment(ctx, line.OrganizationID, dto.RecordCustomerPaymentRequest{
+			CustomerID:     *customerID,
+			Amount:         line.Amount,
+			Currency:       line.Currency,
+			PaymentMethod:  domain.PaymentMethodBankTransfer,
+			PaymentDate:    &paidAt,
+			TransactionRef: truncate(ref, 100),
+			Notes:          paymentNotes,
+			IdempotencyKey: key,
+			Strategy:       string(domain.AllocateOldestFirst),
+		}, performedBy)
+	}
+	if err != nil {
+		return err
+	}
+
+	line.MatchedInvoiceID, line.MatchedCustomerID = invoiceID, &payment.CustomerID
+	if err := line.Decide(decision, &payment.ID, notes, performedBy, now); err != nil {
+		return err
+	}
+	if err := s.statementRepo.SaveDecision(ctx, line); err != nil {
+		if errors.Is(err, domain.ErrInvalidInput) {
+			log.Printf("Statement line %s was decided by someone else while payment %s was recorded", line.ID, payment.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// ListReviewQueue returns the statement lines waiting for a decision, or the
+// lines with the given status
+func (s *BankStatementService) ListReviewQueue(ctx context.Context, orgID uuid.UUID, status string) ([]dto.StatementLineResponse, error) {
+	statuses := domain.StatementLineReviewStatuses
+	if status != "" {
+		switch st := domain.StatementLineStatus(status); st {
+		case domain.StatementLineUnmatched, domain.StatementLineSuggested, domain.StatementLineApplied, domain.StatementLineIgnored:
+			statuses = []domain.StatementLineStatus{st}
+		default:
+			return nil, fmt.Errorf("%w: unknown statement line status %q", domain.ErrInvalidInput, status)
+		}
+	}
+
+	lines, err := s.statementRepo.ListLines(ctx, orgID, statuses)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.StatementLineResponse, 0, len(lines))
+	for i := range lines {
+		res = append(res, mapStatementLineToResponse(&lines[i]))
+	}
+	return res, nil
+}
+
+func (s *BankStatementService) GetStatement(ctx context.Context, orgID, id uuid.UUID) (*dto.BankStatementResponse, error) {
+	statement, err := s.statementRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if statement.OrganizationID != orgID {
+		return nil, domain.ErrStatementNotFound
+	}
+	res := mapStatementToResponse(statement)
+	return &res, nil
+}
+
+func (s *BankStatementService) ListStatements(ctx context.Context, orgID uuid.UUID) ([]dto.BankStatementResponse, error) {
+	statements, err := s.statementRepo.List(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.BankStatementResponse, 0, len(statements))
+	for i := range statements {
+		res = append(res, mapStatementToResponse(&statements[i]))
+	}
+	return res, nil
+}
+
+func mapStatementToResponse(st *domain.BankStatement) dto.BankStatementResponse {
+	res := dto.BankStatementResponse{
+		ID:           st.ID,
+		Format:       st.Format,
+		FileName:     st.FileName,
+		StatementRef: st.StatementRef,
+		Account:      st.Account,
+		Currency:     st.Currency,
+		ImportedBy:   st.ImportedBy,
+		CreatedAt:    st.CreatedAt,
+	}
+	for i := range st.Lines {
+		res.Lines = append(res.Lines, mapStatementLineToResponse(&st.Lines[i]))
+	}
+	return res
+}
+
+func mapStatementLineToResponse(line *domain.BankStatementLine) dto.StatementLineResponse {
+	return dto.StatementLineResponse{
+		ID:                  line.ID,
+		StatementID:         line.StatementID,
+		BookingDate:         line.BookingDate,
+		ValueDate:           line.ValueDate,
+		Amount:              line.Amount,
+		Currency:            line.Currency,
+		Reference:           line.Reference,
+		Description:         line.Description,
+		CounterpartyName:    line.CounterpartyName,
+		CounterpartyAccount: line.CounterpartyAccount,
+		BankRef:             line.BankRef,
+		Status:              string(line.Status),
+		MatchedInvoiceID:    line.MatchedInvoiceID,
+		MatchedCustomerID:   line.MatchedCustomerID,
+		MatchScore:          line.MatchScore,
+		MatchReasons:        line.MatchReasons,
+		PaymentID:           line.PaymentID,
+		Decision:            string(line.Decision),
+		DecisionNotes:       line.DecisionNotes,
+		DecidedBy:           line.DecidedBy,
+		DecidedAt:           line.DecidedAt,
+	}
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,217 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+type BillingNoteService struct {
+	noteRepo       domain.BillingNoteRepository
+	auditRepo      domain.AuditLogRepository
+	eventPublisher domain.EventPublisher
+}
+
+func NewBillingNoteService(
+	noteRepo domain.BillingNoteRepository,
+	auditRepo domain.AuditLogRepository,
+	eventPublisher domain.EventPublisher,
+) *BillingNoteService {
+	return &BillingNoteService{
+		noteRepo:       noteRepo,
+		auditRepo:      auditRepo,
+		eventPublisher: eventPublisher,
+	}
+}
+
+func (s *BillingNoteService) IssueCreditNote(ctx context.Context, invoiceID uuid.UUID, req dto.CreateBillingNoteRequest, performedBy string) (*dto.BillingNoteResponse, error) {
+	return s.issue(ctx, domain.BillingNoteCredit, invoiceID, req, performedBy)
+}
+
+func (s *BillingNoteService) IssueDebitNote(ctx context.Context, invoiceID uuid.UUID, req dto.CreateBillingNoteRequest, performedBy string) (*dto.BillingNoteResponse, error) {
+	return s.issue(ctx, domain.BillingNoteDebit, invoiceID, req, performedBy)
+}
+
+func (s *BillingNoteService) issue(ctx context.Context, noteType domain.BillingNoteType, invoiceID uuid.UUID, req dto.CreateBillingNoteRequest, performedBy string) (*dto.BillingNoteResponse, error) {
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", domain.ErrInvalidInput)
+	}
+	issueDate := time.Now().UTC()
+	if req.IssueDate != nil {
+		issueDate = *req.IssueDate
+	}
+
+	noteReq := domain.NoteRequest{
+		Type:       noteType,
+		Full:       req.Full,
+		Adjustment: req.Adjustment,
+		Lines:      make([]domain.NoteLine, 0, len(req.Items)),
+	}
+	for _, item := range req.Items {
+		noteReq.Lines = append(noteReq.Lines, domain.NoteLine{
+			InvoiceItemID: item.InvoiceItemID,
+			Quantity:      item.Quantity,
+			ItemID:        item.ItemID,
+			ItemType:      item.ItemType,
+			Name:          item.Name,
+			Description:   item.Description,
+			UnitPrice:     item.UnitPrice,
+			Discount:      item.Discount,
+			Tax:           item.Tax,
+		})
+	}
+
+	// 1. Build the note and apply it to the invoice balance under the invoice lock
+	var oldStatus domain.InvoiceStatus
+	note, invoice, err := s.noteRepo.Issue(ctx, invoiceID, func(invoice *domain.Invoice, earlier []domain.BillingNote) (*domain.BillingNote, error) {
+		oldStatus = invoice.Status
+		note, err := invoice.DraftNote(noteReq, earlier)
+		if err != nil {
+			return nil, err
+		}
+		note.Reason = req.Reason
+		note.IssueDate = issueDate
+		note.IssuedBy = performedBy
+		if err := invoice.ApplyNote(note); err != nil {
+			return nil, err
+		}
+		return note, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Audit trail and events
+	notes := fmt.Sprintf("%s %s for %s: %s", note.NoteNumber, noteType, note.TotalAmount, note.Reason)
+	if note.ToCredit.IsPositive() {
+		notes += fmt.Sprintf(" (%s added to customer credit)", note.ToCredit)
+	}
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: invoice.OrganizationID,
+		InvoiceID:      invoice.ID,
+		Action:         fmt.Sprintf("%s_note_issued", noteType),
+		OldStatus:      string(oldStatus),
+		NewStatus:      string(invoice.Status),
+		Notes:          notes,
+		PerformedBy:    performedBy,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		// Log error but don't fail the operation
+		fmt.Printf("failed to create audit log: %v\n", err)
+	}
+	recordStatusChange(ctx, s.auditRepo, s.eventPublisher, invoice, oldStatus, "balance changed by "+note.NoteNumber, performedBy)
+	s.publishNoteIssued(note, invoice)
+
+	return mapBillingNoteToResponse(note, invoice.InvoiceNumber), nil
+}
+
+func (s *BillingNoteService) GetNote(ctx context.Context, id uuid.UUID) (*dto.BillingNoteResponse, error) {
+	note, err := s.noteRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mapBillingNoteToResponse(note, ""), nil
+}
+
+func (s *BillingNoteService) ListInvoiceNotes(ctx context.Context, invoiceID uuid.UUID) ([]dto.BillingNoteResponse, error) {
+	notes, err := s.noteRepo.ListByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.BillingNoteResponse, 0, len(notes))
+	for _, note := range notes {
+		res = append(res, *mapBillingNoteToResponse(&note, ""))
+	}
+	return res, nil
+}
+
+func (s *BillingNoteService) ListNotes(ctx context.Context, orgID uuid.UUID, noteType string) ([]dto.BillingNoteResponse, error) {
+	filter := map[string]interface{}{"organization_id": orgID}
+	if noteType != "" {
+		filter["note_type"] = noteType
+	}
+	notes, err := s.noteRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.BillingNoteResponse, 0, len(notes))
+	for _, note := range notes {
+		res = append(res, *mapBillingNoteToResponse(&note, ""))
+	}
+	return res, nil
+}
+
+func (s *BillingNoteService) publishNoteIssued(note *domain.BillingNote, inv *domain.Invoice) {
+	payload := domain.BillingNoteIssuedPayload{
+		NoteID:         note.ID.String(),
+		NoteNumber:     note.NoteNumber,
+		NoteType:       string(note.NoteType),
+		OrganizationID: note.OrganizationID.String(),
+		CustomerID:     note.CustomerID.String(),
+		InvoiceID:      inv.ID.String(),
+		InvoiceNumber:  inv.InvoiceNumber,
+		TotalAmount:    note.TotalAmount.Float64(),
+		BalanceAmount:  inv.BalanceAmount.Float64(),
+		Currency:       note.Currency,
+		Reason:         note.Reason,
+		IssueDate:      note.IssueDate,
+	}
+
+	metadata := shared_events.NewEventMetadata(domain.EventCreditNoteIssued, shared_events.AggregateInvoice, inv.ID.String())
+	if note.NoteType == domain.BillingNoteDebit {
+		metadata = shared_events.NewEventMetadata(domain.EventDebitNoteIssued, shared_events.AggregateInvoice, inv.ID.String())
+	}
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+func mapBillingNoteToResponse(note *domain.BillingNote, invoiceNumber string) *dto.BillingNoteResponse {
+	res := &dto.BillingNoteResponse{
+		ID:            note.ID,
+		NoteNumber:    note.NoteNumber,
+		NoteType:      string(note.NoteType),
+		InvoiceID:     note.InvoiceID,
+		InvoiceNumber: invoiceNumber,
+		CustomerID:    note.CustomerID,
+		Reason:        note.Reason,
+		IssueDate:     note.IssueDate,
+		Currency:      note.Currency,
+		SubTotal:      note.SubTotal,
+		DiscountTotal: note.DiscountTotal,
+		TaxTotal:      note.TaxTotal,
+		Adjustment:    note.Adjustment,
+		TotalAmount:   note.TotalAmount,
+		ToCredit:      note.ToCredit,
+		IssuedBy:      note.IssuedBy,
+		CreatedAt:     note.CreatedAt,
+	}
+
+	if len(note.Items) > 0 {
+		res.Items = make([]dto.BillingNoteItemResponse, 0, len(note.Items))
+		for _, item := range note.Items {
+			res.Items = append(res.Items, dto.BillingNoteItemResponse{
+				InvoiceItemID: item.InvoiceItemID,
+				ItemID:        item.ItemID,
+				ItemType:      item.ItemType,
+				Name:          item.Name,
+				Description:   item.Description,
+				Quantity:      item.Quantity,
+				UnitPrice:     item.UnitPrice,
+				Discount:      item.Discount,
+				Tax:           item.Tax,
+				Total:         item.Total,
+			})
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,433 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// ConsolidationService bills customers once per period: the work orders they
+// have not been billed for and their draft invoices are merged into a single
+// invoice, with the lines grouped by where they came from. Each customer,
+// currency and period is consolidated once; rerunning a period returns the
+// invoice already made.
+type ConsolidationService struct {
+	consolidationRepo domain.ConsolidationRepository
+	invoiceService    *InvoiceService
+	auditRepo         domain.AuditLogRepository
+	eventPublisher    domain.EventPublisher
+}
+
+func NewConsolidationService(
+	consolidationRepo domain.ConsolidationRepository,
+	invoiceService *InvoiceService,
+	auditRepo domain.AuditLogRepository,
+	eventPublisher domain.EventPublisher,
+) *ConsolidationService {
+	return &ConsolidationService{
+		consolidationRepo: consolidationRepo,
+		invoiceService:    invoiceService,
+		auditRepo:         auditRepo,
+		eventPublisher:    eventPublisher,
+	}
+}
+
+// consolidation is what is being merged for one customer
+type consolidation struct {
+	run        *domain.ConsolidationRun
+	workOrders []*domain.WorkOrderRM // Reserved for the invoice
+	drafts     []*domain.Invoice
+	items      []dto.CreateInvoiceItem
+	adjustment money.Amount
+	excise     money.Amount
+	commission money.Amount
+}
+
+// Consolidate merges the period's unbilled work orders and draft invoices of
+// the customer in the request, or of every customer that has any
+func (s *ConsolidationService) Consolidate(ctx context.Context, orgID uuid.UUID, req dto.ConsolidateRequest, performedBy string) (*dto.ConsolidateResponse, error) {
+	start, end := domain.PreviousMonth(time.Now())
+	if req.PeriodStart != nil || req.PeriodEnd != nil {
+		if req.PeriodStart == nil || req.PeriodEnd == nil {
+			return nil, fmt.Errorf("%w: give both the start and the end of the period", domain.ErrInvalidInput)
+		}
+		var err error
+		if start, end, err = domain.ConsolidationPeriod(*req.PeriodStart, *req.PeriodEnd); err != nil {
+			return nil, err
+		}
+	}
+	source, err := domain.ParseConsolidationSource(req.Source)
+	if err != nil {
+		return nil, err
+	}
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	customers := []uuid.UUID{}
+	if req.CustomerID != nil {
+		customers = append(customers, *req.CustomerID)
+	} else if customers, err = s.customersToConsolidate(ctx, orgID, source, currency, start, end); err != nil {
+		return nil, err
+	}
+
+	res := &dto.ConsolidateResponse{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Runs:        make([]dto.ConsolidationRunResponse, 0, len(customers)),
+	}
+	for _, customerID := range customers {
+		run, err := s.consolidateCustomer(ctx, orgID, customerID, currency, source, start, end, req, performedBy)
+		if err != nil {
+			if req.CustomerID != nil {
+				return nil, err
+			}
+			// One customer failing does not hold up the others; its run
+			// records the error and the period can be run again
+			fmt.Printf("failed to consolidate customer %s: %v\n", customerID, err)
+			if run == nil {
+				continue
+			}
+		}
+		if run.Status == domain.ConsolidationInvoiced {
+			res.Invoiced++
+		}
+		res.Runs = append(res.Runs, s.mapRunToResponse(ctx, run))
+	}
+	return res, nil
+}
+
+// customersToConsolidate lists the customers with something to bill in the period
+func (s *ConsolidationService) customersToConsolidate(ctx context.Context, orgID uuid.UUID, source domain.ConsolidationSource, currency string, start, end time.Time) ([]uuid.UUID, error) {
+	seen := make(map[uuid.UUID]bool)
+	if source != domain.ConsolidateDrafts {
+		workOrders, err := s.consolidationRepo.ListUnbilledWorkOrders(ctx, orgID, nil, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, wo := range workOrders {
+			seen[*wo.CustomerID] = true
+		}
+	}
+	if source != domain.ConsolidateWorkOrders {
+		drafts, err := s.consolidationRepo.ListDrafts(ctx, orgID, nil, currency, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, inv := range drafts {
+			seen[inv.CustomerID] = true
+		}
+	}
+
+	customers := make([]uuid.UUID, 0, len(seen))
+	for id := range seen {
+		customers = append(customers, id)
+	}
+	sort.Slice(customers, func(i, j int) bool { return customers[i].String() < customers[j].String() })
+	return customers, nil
+}
+
+func (s *ConsolidationService) consolidateCustomer(ctx context.Context, orgID, customerID uuid.UUID, currency string, source domain.ConsolidationSource, start, end time.Time, req dto.ConsolidateRequest, performedBy string) (*domain.ConsolidationRun, error) {
+	now := time.Now().UTC()
+	run := &domain.ConsolidationRun{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		CustomerID:     customerID,
+		Currency:       currency,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		Source:         source,
+		Status:         domain.ConsolidationPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	claimed, err := s.consolidationRepo.ClaimRun(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		// Already consolidated, or being consolidated right now
+		return s.consolidationRepo.GetRun(ctx, orgID, customerID, currency, start, end)
+	}
+
+	c := &consolidation{run: run}
+	if err := s.gather(ctx, c, req.TaxCode); err != nil {
+		s.releaseWorkOrders(ctx, c.workOrders)
+		return s.fail(ctx, run, err)
+	}
+	if len(c.items) == 0 {
+		run.Status = domain.ConsolidationEmpty
+		run.UpdatedAt = time.Now().UTC()
+		return run, s.consolidationRepo.UpdateRun(ctx, run)
+	}
+
+	invoice, err := s.invoiceService.createInvoice(ctx, orgID, s.invoiceRequest(ctx, c, req), invoiceSource{Kind: domain.InvoiceKindConsolidated})
+	if err != nil {
+		s.releaseWorkOrders(ctx, c.workOrders)
+		return s.fail(ctx, run, err)
+	}
+
+	run.Status = domain.ConsolidationInvoiced
+	run.InvoiceID = &invoice.ID
+	run.WorkOrders = len(c.workOrders)
+	run.Drafts = len(c.drafts)
+	run.UpdatedAt = time.Now().UTC()
+	workOrderIDs := make([]uuid.UUID, 0, len(c.workOrders))
+	for _, wo := range c.workOrders {
+		workOrderIDs = append(workOrderIDs, wo.ID)
+	}
+	draftIDs := make([]uuid.UUID, 0, len(c.drafts))
+	for _, d := range c.drafts {
+		draftIDs = append(draftIDs, d.ID)
+	}
+	if err := s.consolidationRepo.Complete(ctx, run, workOrderIDs, draftIDs); err != nil {
+		// Nothing was marked billed, so the invoice is taken back
+		if deleteErr := s.invoiceService.invoiceRepo.Delete(ctx, invoice.ID); deleteErr != nil {
+			fmt.Printf("failed to delete consolidated invoice %s: %v\n", invoice.InvoiceNumber, deleteErr)
+		}
+		s.releaseWorkOrders(ctx, c.workOrders)
+		run.InvoiceID = nil
+		run.WorkOrders, run.Drafts = 0, 0
+		return s.fail(ctx, run, err)
+	}
+
+	s.recordConsolidation(ctx, c, invoice, performedBy)
+	return run, nil
+}
+
+// gather reserves the unbilled work orders of the run's customer and picks
+// up its drafts, and turns both into the lines of the consolidated invoice
+func (s *ConsolidationService) gather(ctx context.Context, c *consolidation, taxCode string) error {
+	run := c.run
+	if run.Source != domain.ConsolidateDrafts {
+		workOrders, err := s.consolidationRepo.ListUnbilledWorkOrders(ctx, run.OrganizationID, &run.CustomerID, run.PeriodStart, run.PeriodEnd)
+		if err != nil {
+			return err
+		}
+		for i := range workOrders {
+			wo := &workOrders[i]
+			lines, err := s.invoiceService.workOrderItems(ctx, wo)
+			if errors.Is(err, domain.ErrInvalidInput) {
+				continue // Nothing to bill on it yet
+			}
+			if err != nil {
+				return err
+			}
+			reserved, err := s.invoiceService.rmRepo.ReserveWorkOrderForBilling(ctx, wo.ID)
+			if err != nil {
+				return err
+			}
+			if !reserved {
+				continue // Invoiced on its own since it was listed
+			}
+			c.workOrders = append(c.workOrders, wo)
+			c.items = append(c.items, workOrderGroup(wo, lines, run.Currency, taxCode)...)
+			c.adjustment = c.adjustment.Add(money.FromFloat(wo.Adjustment))
+		}
+	}
+
+	if run.Source != domain.ConsolidateWorkOrders {
+		drafts, err := s.consolidationRepo.ListDrafts(ctx, run.OrganizationID, &run.CustomerID, run.Currency, run.PeriodStart, run.PeriodEnd)
+		if err != nil {
+			return err
+		}
+		for i := range drafts {
+			draft := &drafts[i]
+			if len(draft.Items) == 0 {
+				continue
+			}
+			c.drafts = append(c.drafts, draft)
+			c.items = append(c.items, draftGroup(draft)...)
+			c.adjustment = c.adjustment.Add(draft.Adjustment)
+			c.excise = c.excise.Add(draft.ExciseDuty)
+			c.commission = c.commission.Add(draft.SalesCommission)
+		}
+	}
+	return nil
+}
+
+// workOrderGroup labels the lines of a work order and spreads its discount
+// over them in proportion to their amounts
+func workOrderGroup(wo *domain.WorkOrderRM, lines []dto.CreateInvoiceItem, currency, taxCode string) []dto.CreateInvoiceItem {
+	label := domain.WorkOrderGroupLabel(wo)
+	weights := make([]money.Amount, len(lines))
+	var total money.Amount
+	for i := range lines {
+		lines[i].GroupLabel = label
+		lines[i].SourceID = &wo.ID
+		lines[i].TaxCode = taxCode
+		weights[i] = lines[i].UnitPrice.Mul(lines[i].Quantity).Round(currency, domain.MoneyRounding)
+		total = total.Add(weights[i])
+	}
+
+	discount := money.Min(money.FromFloat(wo.Discount).Round(currency, domain.MoneyRounding), total)
+	if discount.IsPositive() {
+		for i, share := range discount.Allocate(currency, weights) {
+			lines[i].Discount = share
+		}
+	}
+	return lines
+}
+
+// draftGroup copies the lines of a draft invoice as they were priced on it,
+// its share of the draft's own discount included
+func draftGroup(draft *domain.Invoice) []dto.CreateInvoiceItem {
+	label := domain.DraftGroupLabel(draft)
+	lines := make([]dto.CreateInvoiceItem, 0, len(draft.Items))
+	for _, item := range draft.Items {
+		line := dto.CreateInvoiceItem{
+			ItemID:      item.ItemID,
+			ItemType:    item.ItemType,
+			Name:        item.Name,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Discount:    item.Discount.Add(item.DocDiscount),
+			TaxCode:     item.TaxCode,
+			GroupLabel:  label,
+			SourceID:    &draft.ID,
+		}
+		if item.TaxCode == "" {
+			line.Tax = item.Tax
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// invoiceRequest builds the consolidated invoice, addressed like the customer
+func (s *ConsolidationService) invoiceRequest(ctx context.Context, c *consolidation, req dto.ConsolidateRequest) dto.CreateInvoiceRequest {
+	run := c.run
+	period := run.PeriodStart.Format("2006-01-02") + " to " + run.PeriodEnd.Format("2006-01-02")
+	subject := req.Subject
+	if subject == "" {
+		subject = "Consolidated invoice " + period
+	}
+	invoiceDate := time.Now().UTC()
+	if req.InvoiceDate != nil {
+		invoiceDate = *req.InvoiceDate
+	}
+
+	invReq := dto.CreateInvoiceRequest{
+		Subject:         subject,
+		CustomerID:      run.CustomerID,
+		InvoiceDate:     invoiceDate,
+		PaymentTermID:   req.PaymentTermID,
+		ReferenceNo:     period,
+		Currency:        run.Currency,
+		Adjustment:      c.adjustment,
+		ExciseDuty:      c.excise,
+		SalesCommission: c.commission,
+		Terms:           req.Terms,
+		Notes:           req.Notes,
+		Items:           c.items,
+	}
+	s.invoiceService.addressToCustomer(ctx, &invReq)
+	return invReq
+}
+
+// recordConsolidation audits the new invoice and the drafts it replaced, and
+// tells operations its work orders are billed
+func (s *ConsolidationService) recordConsolidation(ctx context.Context, c *consolidation, invoice *domain.Invoice, performedBy string) {
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: invoice.OrganizationID,
+		InvoiceID:      invoice.ID,
+		Action:         "consolidated",
+		NewStatus:      string(invoice.Status),
+		Notes: fmt.Sprintf("Consolidated %d work order(s) and %d draft invoice(s) from %s to %s", len(c.workOrders), len(c.drafts),
+			c.run.PeriodStart.Format("2006-01-02"), c.run.PeriodEnd.Format("2006-01-02")),
+		PerformedBy: performedBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		fmt.Printf("failed to create audit log: %v\n", err)
+	}
+
+	for _, draft := range c.drafts {
+		draft.Status = domain.InvoiceStatusVoid
+		draft.ConsolidatedID = &invoice.ID
+		recordStatusChange(ctx, s.auditRepo, s.eventPublisher, draft, domain.InvoiceStatusDraft, "consolidated into "+invoice.InvoiceNumber, performedBy)
+	}
+	for _, wo := range c.workOrders {
+		s.invoiceService.publishWorkOrderInvoiced(wo, invoice)
+	}
+}
+
+func (s *ConsolidationService) releaseWorkOrders(ctx context.Context, workOrders []*domain.WorkOrderRM) {
+	for _, wo := range workOrders {
+		if err := s.invoiceService.rmRepo.ReleaseWorkOrderBilling(ctx, wo.ID); err != nil {
+			fmt.Printf("failed to release work order %s: %v\n", wo.ID, err)
+		}
+	}
+}
+
+// fail records why the run failed so the period may be consolidated again
+func (s *ConsolidationService) fail(ctx context.Context, run *domain.ConsolidationRun, cause error) (*domain.ConsolidationRun, error) {
+	run.Status = domain.ConsolidationFailed
+	run.Error = cause.Error()
+	run.UpdatedAt = time.Now().UTC()
+	if err := s.consolidationRepo.UpdateRun(ctx, run); err != nil {
+		fmt.Printf("failed to record consolidation run %s: %v\n", run.ID, err)
+	}
+	return run, cause
+}
+
+func (s *ConsolidationService) ListRuns(ctx context.Context, orgID uuid.UUID) ([]dto.ConsolidationRunResponse, error) {
+	runs, err := s.consolidationRepo.ListRuns(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.ConsolidationRunResponse, 0, len(runs))
+	for i := range runs {
+		res = append(res, s.mapRunToResponse(ctx, &runs[i]))
+	}
+	return res, nil
+}
+
+func (s *ConsolidationService) mapRunToResponse(ctx context.Context, run *domain.ConsolidationRun) dto.ConsolidationRunResponse {
+	res := dto.ConsolidationRunResponse{
+		ID:          run.ID,
+		CustomerID:  run.CustomerID,
+		Currency:    run.Currency,
+		PeriodStart: run.PeriodStart,
+		PeriodEnd:   run.PeriodEnd,
+		Source:      string(run.Source),
+		Status:      string(run.Status),
+		InvoiceID:   run.InvoiceID,
+		WorkOrders:  run.WorkOrders,
+		Drafts:      run.Drafts,
+		Error:       run.Error,
+		CreatedAt:   run.CreatedAt,
+		UpdatedAt:   run.UpdatedAt,
+	}
+	if run.InvoiceID != nil {
+		if inv, err := s.invoiceService.invoiceRepo.GetByID(ctx, *run.InvoiceID); err == nil && inv != nil {
+			res.InvoiceNumber = inv.InvoiceNumber
+		}
+	}
+	return res
+}
+
+// undoConsolidation hands back what a voided or deleted consolidated invoice
+// billed: its drafts become drafts again and its work orders billable
+func (s *InvoiceService) undoConsolidation(ctx context.Context, invoice *domain.Invoice, notes, performedBy string) {
+	drafts, err := s.consolidations.Undo(ctx, invoice.ID)
+	if err != nil {
+		fmt.Printf("failed to undo consolidated invoice %s: %v\n", invoice.InvoiceNumber, err)
+		return
+	}
+	for i := range drafts {
+		recordStatusChange(ctx, s.auditRepo, s.eventPublisher, &drafts[i], domain.InvoiceStatusVoid, notes, performedBy)
+	}
+}
@@ -0,0 +1,123 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// GetCustomerCredit returns a customer's unapplied credit per currency and
+// the movements behind it, newest first
+func (s *PaymentService) GetCustomerCredit(ctx context.Context, orgID, customerID uuid.UUID) (*dto.CustomerCreditResponse, error) {
+	balances, err := s.creditRepo.ListBalances(ctx, orgID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.creditRepo.ListEntries(ctx, orgID, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.CustomerCreditResponse{
+		CustomerID: customerID,
+		Balances:   make([]dto.CreditBalance, 0, len(balances)),
+		Entries:    make([]dto.CreditEntryResponse, 0, len(entries)),
+	}
+	for _, b := range balances {
+		res.Balances = append(res.Balances, dto.CreditBalance{Currency: b.Currency, Balance: b.Balance})
+	}
+	for i := range entries {
+		res.Entries = append(res.Entries, mapCreditEntryToResponse(&entries[i]))
+	}
+	return res, nil
+}
+
+// ApplyCredit pays an open invoice of the customer out of their credit. It is
+// recorded as a payment made with PaymentMethodCredit, so the invoice
+// balance and the credit balance change in the same transaction.
+func (s *PaymentService) ApplyCredit(ctx context.Context, orgID, customerID uuid.UUID, req dto.ApplyCreditRequest, performedBy string) (*dto.PaymentResponse, error) {
+	payment := dto.RecordPaymentRequest{
+		Amount:         req.Amount,
+		PaymentMethod:  domain.PaymentMethodCredit,
+		IdempotencyKey: req.IdempotencyKey,
+		Notes:          "Applied from customer credit",
+	}
+	return s.recordPayment(ctx, orgID, req.InvoiceID, payment, &customerID, nil, performedBy)
+}
+
+// RefundCredit pays unapplied credit back to the customer
+func (s *PaymentService) RefundCredit(ctx context.Context, orgID, customerID uuid.UUID, req dto.RefundCreditRequest, performedBy string) (*dto.CreditEntryResponse, error) {
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		return nil, fmt.Errorf("%w: refund needs a currency", domain.ErrInvalidInput)
+	}
+	if !domain.IsValidPaymentMethod(req.PaymentMethod) {
+		return nil, fmt.Errorf("%w: unknown payment method %q", domain.ErrInvalidInput, req.PaymentMethod)
+	}
+	amount := req.Amount.Round(currency, domain.MoneyRounding)
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: refund amount must be positive", domain.ErrInvalidInput)
+	}
+
+	entry := &domain.CustomerCreditEntry{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		CustomerID:     customerID,
+		Currency:       currency,
+		EntryType:      domain.CreditEntryRefunded,
+		Amount:         amount.Neg(),
+		PaymentMethod:  req.PaymentMethod,
+		Reference:      req.Reference,
+		Notes:          req.Notes,
+		CreatedBy:      performedBy,
+	}
+	if err := s.creditRepo.Post(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	s.publishCreditRefunded(entry)
+
+	res := mapCreditEntryToResponse(entry)
+	return &res, nil
+}
+
+func (s *PaymentService) publishCreditRefunded(entry *domain.CustomerCreditEntry) {
+	payload := domain.CustomerCreditRefundedPayload{
+		EntryID:        entry.ID.String(),
+		OrganizationID: entry.OrganizationID.String(),
+		CustomerID:     entry.CustomerID.String(),
+		Amount:         entry.Amount.Neg().Float64(),
+		Currency:       entry.Currency,
+		BalanceAfter:   entry.BalanceAfter.Float64(),
+		PaymentMethod:  entry.PaymentMethod,
+		Reference:      entry.Reference,
+		RefundedAt:     time.Now().UTC(),
+	}
+
+	metadata := shared_events.NewEventMetadata(domain.EventCustomerCreditRefunded, shared_events.AggregateCustomer, entry.CustomerID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+func mapCreditEntryToResponse(e *domain.CustomerCreditEntry) dto.CreditEntryResponse {
+	return dto.CreditEntryResponse{
+		ID:           e.ID,
+		EntryType:    string(e.EntryType),
+		Currency:     e.Currency,
+		Amount:       e.Amount,
+		BalanceAfter: e.BalanceAfter,
+		PaymentID:    e.PaymentID,
+		InvoiceID:    e.InvoiceID,
+		Method:       e.PaymentMethod,
+		Reference:    e.Reference,
+		Notes:        e.Notes,
+		CreatedBy:    e.CreatedBy,
+		CreatedAt:    e.CreatedAt,
+	}
+}
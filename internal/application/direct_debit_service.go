@@ -0,0 +1,558 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/directdebit"
+
+	"github.com/google/uuid"
+)
+
+// DirectDebitService collects the balance of due invoices from customers
+// who signed a direct debit mandate. A batch turns the invoices into a SEPA
+// pain.008 or NACHA file for the bank and marks them as in collection.
+// Payments are recorded once the bank confirms the collection, or when the
+// batch is settled, keyed on the batch item so an item is never paid twice.
+// Items the bank returns release their invoice again, reversing the payment
+// if it had already been recorded.
+type DirectDebitService struct {
+	ddRepo    domain.DirectDebitRepository
+	auditRepo domain.AuditLogRepository
+	payments  *PaymentService
+}
+
+func NewDirectDebitService(ddRepo domain.DirectDebitRepository, auditRepo domain.AuditLogRepository, payments *PaymentService) *DirectDebitService {
+	return &DirectDebitService{
+		ddRepo:    ddRepo,
+		auditRepo: auditRepo,
+		payments:  payments,
+	}
+}
+
+func (s *DirectDebitService) SaveCreditor(ctx context.Context, orgID uuid.UUID, scheme string, req dto.SaveCreditorRequest) (*dto.CreditorResponse, error) {
+	sch, err := domain.ParseDirectDebitScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	creditor := &domain.DirectDebitCreditor{
+		ID:              uuid.New(),
+		OrganizationID:  orgID,
+		Scheme:          sch,
+		Name:            req.Name,
+		CreditorID:      strings.TrimSpace(req.CreditorID),
+		IBAN:            req.IBAN,
+		BIC:             req.BIC,
+		RoutingNumber:   strings.TrimSpace(req.RoutingNumber),
+		DestinationName: req.DestinationName,
+		NextBatch:       1,
+		NextTrace:       1,
+	}
+	if err := creditor.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.ddRepo.SaveCreditor(ctx, creditor); err != nil {
+		return nil, err
+	}
+	return s.GetCreditor(ctx, orgID, scheme)
+}
+
+func (s *DirectDebitService) GetCreditor(ctx context.Context, orgID uuid.UUID, scheme string) (*dto.CreditorResponse, error) {
+	sch, err := domain.ParseDirectDebitScheme(scheme)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.ddRepo.GetCreditor(ctx, orgID, sch)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.CreditorResponse{
+		ID:              c.ID,
+		Scheme:          string(c.Scheme),
+		Name:            c.Name,
+		CreditorID:      c.CreditorID,
+		IBAN:            c.IBAN,
+		BIC:             c.BIC,
+		RoutingNumber:   c.RoutingNumber,
+		DestinationName: c.DestinationName,
+		UpdatedAt:       c.UpdatedAt,
+	}, nil
+}
+
+func (s *DirectDebitService) CreateMandate(ctx context.Context, orgID, customerID uuid.UUID, req dto.CreateMandateRequest, performedBy string) (*dto.MandateResponse, error) {
+	scheme, err := domain.ParseDirectDebitScheme(req.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	mandate := &domain.DirectDebitMandate{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		CustomerID:     customerID,
+		Scheme:         scheme,
+		Reference:      req.Reference,
+		SignedAt:       req.SignedAt.UTC(),
+		DebtorName:     req.DebtorName,
+		IBAN:           req.IBAN,
+		BIC:            req.BIC,
+		RoutingNumber:  strings.TrimSpace(req.RoutingNumber),
+		AccountNumber:  strings.TrimSpace(req.AccountNumber),
+		AccountType:    req.AccountType,
+		Status:         domain.MandateActive,
+		CreatedBy:      performedBy,
+	}
+	if err := mandate.Validate(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := s.ddRepo.CreateMandate(ctx, mandate); err != nil {
+		return nil, err
+	}
+	res := mapMandateToResponse(mandate)
+	return &res, nil
+}
+
+func (s *DirectDebitService) ListMandates(ctx context.Context, orgID, customerID uuid.UUID) ([]dto.MandateResponse, error) {
+	mandates, err := s.ddRepo.ListMandates(ctx, orgID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.MandateResponse, 0, len(mandates))
+	for i := range mandates {
+		res = append(res, mapMandateToResponse(&mandates[i]))
+	}
+	return res, nil
+}
+
+// RevokeMandate stops collections under a mandate. Items already sent to
+// the bank are not affected.
+func (s *DirectDebitService) RevokeMandate(ctx context.Context, orgID, mandateID uuid.UUID) (*dto.MandateResponse, error) {
+	mandate, err := s.ddRepo.GetMandate(ctx, mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if mandate.OrganizationID != orgID {
+		return nil, domain.ErrMandateNotFound
+	}
+	if err := mandate.Revoke(time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if err := s.ddRepo.UpdateMandate(ctx, mandate); err != nil {
+		return nil, err
+	}
+	res := mapMandateToResponse(mandate)
+	return &res, nil
+}
+
+// CollectDue creates a batch collecting the balance of every invoice due by
+// the collection date from customers with an active mandate for the scheme
+func (s *DirectDebitService) CollectDue(ctx context.Context, orgID uuid.UUID, req dto.CreateDirectDebitBatchRequest, performedBy string) (*dto.DirectDebitBatchResponse, error) {
+	scheme, err := domain.ParseDirectDebitScheme(req.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	collectionDate := today.AddDate(0, 0, 1)
+	if req.CollectionDate != nil {
+		collectionDate = req.CollectionDate.UTC().Truncate(24 * time.Hour)
+		if collectionDate.Before(today) {
+			return nil, fmt.Errorf("%w: collection date is in the past", domain.ErrInvalidInput)
+		}
+	}
+
+	batch, err := s.ddRepo.CreateBatch(ctx, orgID, scheme, collectionDate, func(creditor *domain.DirectDebitCreditor, invoices []*domain.Invoice, mandates map[uuid.UUID]*domain.DirectDebitMandate) (*domain.DirectDebitBatch, error) {
+		if len(invoices) == 0 {
+			return nil, fmt.Errorf("%w: no invoices are due for collection by %s", domain.ErrInvalidInput, collectionDate.Format("2006-01-02"))
+		}
+		batch := buildDirectDebitBatch(creditor, invoices, mandates, collectionDate, now, performedBy)
+		file, err := directDebitFile(creditor, batch, invoices, mandates)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+		}
+		batch.File = string(file)
+		return batch, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range batch.Items {
+		s.audit(ctx, item, "direct_debit_collection",
+			fmt.Sprintf("Balance of %s %s sent for collection in direct debit batch %s", item.Amount.StringFixed(item.Currency), item.Currency, batch.MessageID), performedBy)
+	}
+
+	res := mapDirectDebitBatchToResponse(batch)
+	return &res, nil
+}
+
+// buildDirectDebitBatch makes an item of every invoice and advances the
+// creditor's counters. All items of a mandate share its sequence type, so a
+// new mandate's invoices are all first collections.
+func buildDirectDebitBatch(creditor *domain.DirectDebitCreditor, invoices []*domain.Invoice, mandates map[uuid.UUID]*domain.DirectDebitMandate, collectionDate, now time.Time, performedBy string) *domain.DirectDebitBatch {
+	batch := &domain.DirectDebitBatch{
+		ID:             uuid.New(),
+		OrganizationID: creditor.OrganizationID,
+		Scheme:         creditor.Scheme,
+		BatchNumber:    creditor.NextBatch,
+		MessageID:      fmt.Sprintf("DD-%s-%s-%06d", strings.ToUpper(string(creditor.Scheme)), now.Format("20060102"), creditor.NextBatch),
+		CollectionDate: collectionDate,
+		Currency:       creditor.Scheme.Currency(),
+		Status:         domain.DirectDebitBatchSubmitted,
+		CreatedBy:      performedBy,
+		CreatedAt:      now,
+	}
+	batch.FileName = batch.MessageID + ".xml"
+	if creditor.Scheme == domain.SchemeACH {
+		batch.FileName = batch.MessageID + ".ach"
+	}
+	creditor.NextBatch++
+
+	sequences := make(map[uuid.UUID]string)
+	for _, inv := range invoices {
+		m := mandates[inv.CustomerID]
+		if _, ok := sequences[m.ID]; !ok {
+			sequences[m.ID] = m.SequenceType()
+		}
+		item := domain.DirectDebitItem{
+			ID:             uuid.New(),
+			OrganizationID: inv.OrganizationID,
+			BatchID:        batch.ID,
+			InvoiceID:      inv.ID,
+			CustomerID:     inv.CustomerID,
+			MandateID:      m.ID,
+			Amount:         inv.AmountDue(),
+			Currency:       batch.Currency,
+			Status:         domain.DirectDebitItemPending,
+		}
+		if creditor.Scheme == domain.SchemeSEPA {
+			item.EndToEndID = strings.ReplaceAll(item.ID.String(), "-", "")
+			item.SequenceType = sequences[m.ID]
+		} else {
+			item.TraceNumber = directdebit.TraceNumber(creditor.RoutingNumber, creditor.NextTrace)
+			creditor.NextTrace++
+		}
+		batch.Items = append(batch.Items, item)
+		batch.TotalAmount = batch.TotalAmount.Add(item.Amount)
+		if m.FirstCollected == nil {
+			m.FirstCollected = &now
+		}
+	}
+	batch.ItemCount = len(batch.Items)
+	return batch
+}
+
+// directDebitFile renders the batch in the format of its scheme
+func directDebitFile(creditor *domain.DirectDebitCreditor, batch *domain.DirectDebitBatch, invoices []*domain.Invoice, mandates map[uuid.UUID]*domain.DirectDebitMandate) ([]byte, error) {
+	byID := make(map[uuid.UUID]*domain.Invoice, len(invoices))
+	for _, inv := range invoices {
+		byID[inv.ID] = inv
+	}
+	byMandate := make(map[uuid.UUID]*domain.DirectDebitMandate, len(mandates))
+	for _, m := range mandates {
+		byMandate[m.ID] = m
+	}
+
+	if creditor.Scheme == domain.SchemeSEPA {
+		b := directdebit.SEPABatch{
+			MessageID:      batch.MessageID,
+			CreatedAt:      batch.CreatedAt,
+			CollectionDate: batch.CollectionDate,
+			Creditor:       directdebit.SEPACreditor{Name: creditor.Name, CreditorID: creditor.CreditorID, IBAN: creditor.IBAN, BIC: creditor.BIC},
+		}
+		for _, item := range batch.Items {
+			m := byMandate[item.MandateID]
+			b.Transactions = append(b.Transactions, directdebit.SEPATransaction{
+				EndToEndID:      item.EndToEndID,
+				Amount:          item.Amount,
+				MandateID:       m.Reference,
+				MandateSignedAt: m.SignedAt,
+				SequenceType:    item.SequenceType,
+				DebtorName:      m.DebtorName,
+				DebtorIBAN:      m.IBAN,
+				DebtorBIC:       m.BIC,
+				Remittance:      "Invoice " + byID[item.InvoiceID].InvoiceNumber,
+			})
+		}
+		return directdebit.GeneratePain008(b)
+	}
+
+	b := directdebit.ACHBatch{
+		BatchNumber:      batch.BatchNumber,
+		CreatedAt:        batch.CreatedAt,
+		EffectiveDate:    batch.CollectionDate,
+		EntryDescription: "INVOICE",
+		Originator: directdebit.ACHOriginator{
+			CompanyName:          creditor.Name,
+			CompanyID:            creditor.CreditorID,
+			ODFIRouting:          creditor.RoutingNumber,
+			ImmediateDestination: creditor.RoutingNumber,
+			DestinationName:      creditor.DestinationName,
+		},
+	}
+	for _, item := range batch.Items {
+		m := byMandate[item.MandateID]
+		b.Entries = append(b.Entries, directdebit.ACHEntry{
+			TraceNumber:    item.TraceNumber,
+			RoutingNumber:  m.RoutingNumber,
+			AccountNumber:  m.AccountNumber,
+			AccountType:    m.AccountType,
+			Amount:         item.Amount,
+			IndividualID:   byID[item.InvoiceID].InvoiceNumber,
+			IndividualName: m.DebtorName,
+		})
+	}
+	return directdebit.GenerateNACHA(b)
+}
+
+func (s *DirectDebitService) GetBatch(ctx context.Context, orgID, id uuid.UUID) (*dto.DirectDebitBatchResponse, error) {
+	batch, err := s.batch(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := mapDirectDebitBatchToResponse(batch)
+	return &res, nil
+}
+
+// GetBatchFile returns the file generated for a batch and its name
+func (s *DirectDebitService) GetBatchFile(ctx context.Context, orgID, id uuid.UUID) ([]byte, string, error) {
+	batch, err := s.batch(ctx, orgID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(batch.File), batch.FileName, nil
+}
+
+func (s *DirectDebitService) ListBatches(ctx context.Context, orgID uuid.UUID) ([]dto.DirectDebitBatchResponse, error) {
+	batches, err := s.ddRepo.ListBatches(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.DirectDebitBatchResponse, 0, len(batches))
+	for i := range batches {
+		res = append(res, mapDirectDebitBatchToResponse(&batches[i]))
+	}
+	return res, nil
+}
+
+func (s *DirectDebitService) batch(ctx context.Context, orgID, id uuid.UUID) (*domain.DirectDebitBatch, error) {
+	batch, err := s.ddRepo.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.OrganizationID != orgID {
+		return nil, domain.ErrDirectDebitBatchNotFound
+	}
+	return batch, nil
+}
+
+// SettleBatch records the payment of every item still pending, for banks
+// that report returns only. Items returned later have their payment
+// reversed.
+func (s *DirectDebitService) SettleBatch(ctx context.Context, orgID, id uuid.UUID, performedBy string) (*dto.DirectDebitBatchResponse, error) {
+	batch, err := s.batch(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.CollectionDate.After(time.Now().UTC()) {
+		return nil, fmt.Errorf("%w: batch %s is collected on %s", domain.ErrInvalidInput, batch.MessageID, batch.CollectionDate.Format("2006-01-02"))
+	}
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		if item.Status != domain.DirectDebitItemPending {
+			continue
+		}
+		if err := s.collect(ctx, item, batch.CollectionDate, performedBy); err != nil {
+			return nil, err
+		}
+	}
+	return s.GetBatch(ctx, orgID, id)
+}
+
+// ProcessReturnFile applies a pain.002 status report or a NACHA return file.
+// Items the bank accepted are paid; returned ones release their invoice and
+// have their payment reversed if it had been recorded. Items that cannot be
+// processed are reported without stopping the rest.
+func (s *DirectDebitService) ProcessReturnFile(ctx context.Context, orgID uuid.UUID, data []byte, performedBy string) (*dto.DirectDebitReturnsResponse, error) {
+	results, err := directdebit.ParseReturns(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+
+	res := &dto.DirectDebitReturnsResponse{}
+	now := time.Now().UTC()
+	for _, r := range results {
+		ref := r.EndToEndID
+		if ref == "" {
+			ref = r.TraceNumber
+		}
+		item, err := s.ddRepo.FindItem(ctx, orgID, ref)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", ref, err))
+			continue
+		}
+
+		switch {
+		case r.Accepted && item.Status == domain.DirectDebitItemPending:
+			err = s.collect(ctx, item, now, performedBy)
+			if err == nil {
+				res.Collected++
+			}
+		case !r.Accepted && item.Status == domain.DirectDebitItemPending:
+			err = s.markReturned(ctx, item, r.ReasonCode, now, performedBy)
+			if err == nil {
+				res.Returned++
+			}
+		case !r.Accepted && item.Status == domain.DirectDebitItemCollected:
+			err = s.reverseCollected(ctx, item, ref, r.ReasonCode, now, performedBy)
+			if err == nil {
+				err = s.markReturned(ctx, item, r.ReasonCode, now, performedBy)
+			}
+			if err == nil {
+				res.Reversed++
+			}
+		default:
+			res.Unchanged++
+		}
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", ref, err))
+		}
+	}
+	return res, nil
+}
+
+// collect records the payment of an item and marks it collected
+func (s *DirectDebitService) collect(ctx context.Context, item *domain.DirectDebitItem, paidAt time.Time, performedBy string) error {
+	if now := time.Now().UTC(); paidAt.After(now) {
+		paidAt = now
+	}
+	ref := item.EndToEndID
+	if ref == "" {
+		ref = item.TraceNumber
+	}
+	payment, err := s.payments.RecordPayment(ctx, item.OrganizationID, item.InvoiceID, dto.RecordPaymentRequest{
+		Amount:         item.Amount,
+		PaymentMethod:  domain.PaymentMethodDirectDebit,
+		PaymentDate:    &paidAt,
+		TransactionRef: ref,
+		Notes:          fmt.Sprintf("Direct debit item %s", item.ID),
+		IdempotencyKey: "direct-debit:" + item.ID.String(),
+	}, performedBy)
+	if err != nil {
+		return err
+	}
+	if err := item.Collect(payment.ID); err != nil {
+		return err
+	}
+	return s.ddRepo.SaveItem(ctx, item)
+}
+
+// reverseCollected reverses the payment of a collected item that came back
+// returned. A payment already reversed by an earlier upload of the file,
+// which then failed to mark the item, is left as it is.
+func (s *DirectDebitService) reverseCollected(ctx context.Context, item *domain.DirectDebitItem, ref, code string, now time.Time, performedBy string) error {
+	payment, err := s.payments.paymentRepo.GetByID(ctx, *item.PaymentID)
+	if err != nil {
+		return err
+	}
+	if payment.Status == domain.PaymentStatusReversed {
+		return nil
+	}
+	_, err = s.payments.ReversePayment(ctx, item.OrganizationID, payment.ID, dto.ReversePaymentRequest{
+		ReversalDate: &now,
+		Reference:    ref,
+		Reason:       "Direct debit returned with reason " + code,
+	}, performedBy)
+	return err
+}
+
+func (s *DirectDebitService) markReturned(ctx context.Context, item *domain.DirectDebitItem, code string, now time.Time, performedBy string) error {
+	if err := item.Return(code, now); err != nil {
+		return err
+	}
+	if err := s.ddRepo.SaveItem(ctx, item); err != nil {
+		return err
+	}
+	s.audit(ctx, *item, "direct_debit_returned", fmt.Sprintf("Direct debit of %s %s returned with reason %s", item.Amount.StringFixed(item.Currency), item.Currency, code), performedBy)
+	return nil
+}
+
+func (s *DirectDebitService) audit(ctx context.Context, item domain.DirectDebitItem, action, notes, performedBy string) {
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: item.OrganizationID,
+		InvoiceID:      item.InvoiceID,
+		Action:         action,
+		Notes:          notes,
+		PerformedBy:    performedBy,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		log.Printf("Failed to audit direct debit item %s: %v", item.ID, err)
+	}
+}
+
+func mapMandateToResponse(m *domain.DirectDebitMandate) dto.MandateResponse {
+	account := m.IBAN
+	if m.Scheme == domain.SchemeACH {
+		account = m.AccountNumber
+	}
+	return dto.MandateResponse{
+		ID:             m.ID,
+		CustomerID:     m.CustomerID,
+		Scheme:         string(m.Scheme),
+		Reference:      m.Reference,
+		SignedAt:       m.SignedAt,
+		DebtorName:     m.DebtorName,
+		Account:        maskAccount(account),
+		Status:         string(m.Status),
+		FirstCollected: m.FirstCollected,
+		RevokedAt:      m.RevokedAt,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+// maskAccount hides all but the last four characters of an account number
+func maskAccount(account string) string {
+	if len(account) <= 4 {
+		return account
+	}
+	return strings.Repeat("*", len(account)-4) + account[len(account)-4:]
+}
+
+func mapDirectDebitBatchToResponse(b *domain.DirectDebitBatch) dto.DirectDebitBatchResponse {
+	res := dto.DirectDebitBatchResponse{
+		ID:             b.ID,
+		Scheme:         string(b.Scheme),
+		BatchNumber:    b.BatchNumber,
+		MessageID:      b.MessageID,
+		CollectionDate: b.CollectionDate,
+		Currency:       b.Currency,
+		TotalAmount:    b.TotalAmount,
+		ItemCount:      b.ItemCount,
+		Status:         string(b.Status),
+		FileName:       b.FileName,
+		CreatedBy:      b.CreatedBy,
+		CreatedAt:      b.CreatedAt,
+	}
+	for _, it := range b.Items {
+		res.Items = append(res.Items, dto.DirectDebitItemResponse{
+			ID:           it.ID,
+			InvoiceID:    it.InvoiceID,
+			CustomerID:   it.CustomerID,
+			MandateID:    it.MandateID,
+			Amount:       it.Amount,
+			EndToEndID:   it.EndToEndID,
+			TraceNumber:  it.TraceNumber,
+			SequenceType: it.SequenceType,
+			Status:       string(it.Status),
+			ReturnCode:   it.ReturnCode,
+			ReturnedAt:   it.ReturnedAt,
+			PaymentID:    it.PaymentID,
+		})
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// ImportStatementRequest holds the form fields sent with a statement file
+type ImportStatementRequest struct {
+	Format    string // camt053, mt940 or ofx; detected from the file when empty
+	FileName  string
+	AutoApply bool // Record payments for certain matches without review
+}
+
+// ConfirmStatementLineRequest records a statement line as a payment. Without
+// an invoice or customer the suggested match is used. A customer payment is
+// spread over the customer's open invoices, oldest first.
+type ConfirmStatementLineRequest struct {
+	InvoiceID  *uuid.UUID `json:"invoice_id"`
+	CustomerID *uuid.UUID `json:"customer_id"`
+	Notes      string     `json:"notes"`
+}
+
+type IgnoreStatementLineRequest struct {
+	Notes string `json:"notes"`
+}
+
+type BankStatementResponse struct {
+	ID           uuid.UUID               `json:"id"`
+	Format       string                  `json:"format"`
+	FileName     string                  `json:"file_name"`
+	StatementRef string                  `json:"statement_ref"`
+	Account      string                  `json:"account"`
+	Currency     string                  `json:"currency"`
+	ImportedBy   string                  `json:"imported_by"`
+	CreatedAt    time.Time               `json:"created_at"`
+	Summary      *StatementSummary       `json:"summary,omitempty"`
+	Lines        []StatementLineResponse `json:"lines,omitempty"`
+}
+
+type StatementSummary struct {
+	Imported  int `json:"imported"`
+	Skipped   int `json:"skipped"` // Already imported with an earlier statement
+	Applied   int `json:"applied"`
+	Suggested int `json:"suggested"`
+	Unmatched int `json:"unmatched"`
+	Ignored   int `json:"ignored"`
+}
+
+type StatementLineResponse struct {
+	ID                  uuid.UUID    `json:"id"`
+	StatementID         uuid.UUID    `json:"statement_id"`
+	BookingDate         time.Time    `json:"booking_date"`
+	ValueDate           time.Time    `json:"value_date"`
+	Amount              money.Amount `json:"amount"` // Negative for money paid out
+	Currency            string       `json:"currency"`
+	Reference           string       `json:"reference"`
+	Description         string       `json:"description"`
+	CounterpartyName    string       `json:"counterparty_name"`
+	CounterpartyAccount string       `json:"counterparty_account"`
+	BankRef             string       `json:"bank_ref"`
+	Status              string       `json:"status"` // unmatched, suggested, applied or ignored
+	MatchedInvoiceID    *uuid.UUID   `json:"matched_invoice_id,omitempty"`
+	MatchedCustomerID   *uuid.UUID   `json:"matched_customer_id,omitempty"`
+	MatchScore          int          `json:"match_score"`
+	MatchReasons        string       `json:"match_reasons"`
+	PaymentID           *uuid.UUID   `json:"payment_id,omitempty"`
+	Decision            string       `json:"decision,omitempty"`
+	DecisionNotes       string       `json:"decision_notes"`
+	DecidedBy           string       `json:"decided_by"`
+	DecidedAt           *time.Time   `json:"decided_at,omitempty"`
+}
@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type CreateBillingNoteRequest struct {
+	Reason     string                  `json:"reason" validate:"required"`
+	IssueDate  *time.Time              `json:"issue_date"`
+	Full       bool                    `json:"full"` // Credit notes only: reverse everything not yet credited
+	Adjustment money.Amount            `json:"adjustment"`
+	Items      []CreateBillingNoteItem `json:"items"`
+}
+
+type CreateBillingNoteItem struct {
+	InvoiceItemID *uuid.UUID   `json:"invoice_item_id"` // Source invoice line; required for credit notes
+	Quantity      float64      `json:"quantity"`        // Zero means the whole remaining quantity of the source line
+	ItemID        uuid.UUID    `json:"item_id"`         // The fields below describe extra debit note charges
+	ItemType      string       `json:"item_type"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	UnitPrice     money.Amount `json:"unit_price"`
+	Discount      money.Amount `json:"discount"`
+	Tax           money.Amount `json:"tax"`
+}
+
+type BillingNoteResponse struct {
+	ID            uuid.UUID                 `json:"id"`
+	NoteNumber    string                    `json:"note_number"`
+	NoteType      string                    `json:"note_type"`
+	InvoiceID     uuid.UUID                 `json:"invoice_id"`
+	InvoiceNumber string                    `json:"invoice_number,omitempty"`
+	CustomerID    uuid.UUID                 `json:"customer_id"`
+	Reason        string                    `json:"reason"`
+	IssueDate     time.Time                 `json:"issue_date"`
+	Currency      string                    `json:"currency"`
+	SubTotal      money.Amount              `json:"sub_total"`
+	DiscountTotal money.Amount              `json:"discount_total"`
+	TaxTotal      money.Amount              `json:"tax_total"`
+	Adjustment    money.Amount              `json:"adjustment"`
+	TotalAmount   money.Amount              `json:"total_amount"`
+	ToCredit      money.Amount              `json:"to_credit"` // Credit beyond what the invoice still owed, added to the customer's credit
+	IssuedBy      string                    `json:"issued_by"`
+	Items         []BillingNoteItemResponse `json:"items,omitempty"`
+	CreatedAt     time.Time                 `json:"created_at"`
+}
+
+type BillingNoteItemResponse struct {
+	InvoiceItemID *uuid.UUID   `json:"invoice_item_id,omitempty"`
+	ItemID        uuid.UUID    `json:"item_id"`
+	ItemType      string       `json:"item_type"`
+	Name          string       `json:"name"`
+	Description   string       `json:"description"`
+	Quantity      float64      `json:"quantity"`
+	UnitPrice     money.Amount `json:"unit_price"`
+	Discount      money.Amount `json:"discount"`
+	Tax           money.Amount `json:"tax"`
+	Total         money.Amount `json:"total"`
+}
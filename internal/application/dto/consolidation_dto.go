@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConsolidateRequest merges what a customer has not been billed for in a
+// period into one invoice. Without a customer every customer with unbilled
+// work orders or draft invoices in the period is consolidated.
+type ConsolidateRequest struct {
+	CustomerID    *uuid.UUID `json:"customer_id"`
+	PeriodStart   *time.Time `json:"period_start"` // Defaults to the first day of last month
+	PeriodEnd     *time.Time `json:"period_end"`   // Last day of the period, inclusive
+	Source        string     `json:"source"`       // all, work_orders or draft_invoices; defaults to all
+	Currency      string     `json:"currency"`     // Defaults to USD
+	Subject       string     `json:"subject"`      // Defaults to the period
+	InvoiceDate   *time.Time `json:"invoice_date"` // Defaults to today
+	PaymentTermID *uuid.UUID `json:"payment_term_id"`
+	TaxCode       string     `json:"tax_code"` // Applied to every work order line
+	Terms         string     `json:"terms"`
+	Notes         string     `json:"notes"`
+}
+
+type ConsolidationRunResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	CustomerID    uuid.UUID  `json:"customer_id"`
+	Currency      string     `json:"currency"`
+	PeriodStart   time.Time  `json:"period_start"`
+	PeriodEnd     time.Time  `json:"period_end"`
+	Source        string     `json:"source"`
+	Status        string     `json:"status"`
+	InvoiceID     *uuid.UUID `json:"invoice_id,omitempty"`
+	InvoiceNumber string     `json:"invoice_number,omitempty"`
+	WorkOrders    int        `json:"work_orders"`
+	Drafts        int        `json:"draft_invoices"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type ConsolidateResponse struct {
+	PeriodStart time.Time                  `json:"period_start"`
+	PeriodEnd   time.Time                  `json:"period_end"`
+	Invoiced    int                        `json:"invoiced"`
+	Runs        []ConsolidationRunResponse `json:"runs"`
+}
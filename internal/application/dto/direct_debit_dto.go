@@ -0,0 +1,108 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// SaveCreditorRequest sets the organization's details as creditor under a
+// scheme. SEPA needs the creditor identifier and IBAN, ACH the company ID
+// and the routing number of the originating bank.
+type SaveCreditorRequest struct {
+	Name            string `json:"name" validate:"required"`
+	CreditorID      string `json:"creditor_id" validate:"required"`
+	IBAN            string `json:"iban"`
+	BIC             string `json:"bic"`
+	RoutingNumber   string `json:"routing_number"`
+	DestinationName string `json:"destination_name"` // Name of the bank receiving ACH files
+}
+
+type CreditorResponse struct {
+	ID              uuid.UUID `json:"id"`
+	Scheme          string    `json:"scheme"`
+	Name            string    `json:"name"`
+	CreditorID      string    `json:"creditor_id"`
+	IBAN            string    `json:"iban,omitempty"`
+	BIC             string    `json:"bic,omitempty"`
+	RoutingNumber   string    `json:"routing_number,omitempty"`
+	DestinationName string    `json:"destination_name,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// CreateMandateRequest records a customer's direct debit mandate. SEPA
+// mandates carry an IBAN, ACH mandates a routing and account number.
+type CreateMandateRequest struct {
+	Scheme        string    `json:"scheme" validate:"required"` // sepa or ach
+	Reference     string    `json:"reference" validate:"required"`
+	SignedAt      time.Time `json:"signed_at" validate:"required"`
+	DebtorName    string    `json:"debtor_name" validate:"required"`
+	IBAN          string    `json:"iban"`
+	BIC           string    `json:"bic"`
+	RoutingNumber string    `json:"routing_number"`
+	AccountNumber string    `json:"account_number"`
+	AccountType   string    `json:"account_type"` // checking or savings, defaults to checking
+}
+
+type MandateResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	CustomerID     uuid.UUID  `json:"customer_id"`
+	Scheme         string     `json:"scheme"`
+	Reference      string     `json:"reference"`
+	SignedAt       time.Time  `json:"signed_at"`
+	DebtorName     string     `json:"debtor_name"`
+	Account        string     `json:"account"` // IBAN or account number, masked
+	Status         string     `json:"status"`
+	FirstCollected *time.Time `json:"first_collected,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CreateDirectDebitBatchRequest collects every invoice due by the collection
+// date from customers with an active mandate
+type CreateDirectDebitBatchRequest struct {
+	Scheme         string     `json:"scheme" validate:"required"` // sepa or ach
+	CollectionDate *time.Time `json:"collection_date"`            // Defaults to tomorrow
+}
+
+type DirectDebitBatchResponse struct {
+	ID             uuid.UUID                 `json:"id"`
+	Scheme         string                    `json:"scheme"`
+	BatchNumber    int64                     `json:"batch_number"`
+	MessageID      string                    `json:"message_id"`
+	CollectionDate time.Time                 `json:"collection_date"`
+	Currency       string                    `json:"currency"`
+	TotalAmount    money.Amount              `json:"total_amount"`
+	ItemCount      int                       `json:"item_count"`
+	Status         string                    `json:"status"` // submitted or settled
+	FileName       string                    `json:"file_name"`
+	CreatedBy      string                    `json:"created_by"`
+	CreatedAt      time.Time                 `json:"created_at"`
+	Items          []DirectDebitItemResponse `json:"items,omitempty"`
+}
+
+type DirectDebitItemResponse struct {
+	ID           uuid.UUID    `json:"id"`
+	InvoiceID    uuid.UUID    `json:"invoice_id"`
+	CustomerID   uuid.UUID    `json:"customer_id"`
+	MandateID    uuid.UUID    `json:"mandate_id"`
+	Amount       money.Amount `json:"amount"`
+	EndToEndID   string       `json:"end_to_end_id,omitempty"`
+	TraceNumber  string       `json:"trace_number,omitempty"`
+	SequenceType string       `json:"sequence_type,omitempty"`
+	Status       string       `json:"status"` // pending, collected or returned
+	ReturnCode   string       `json:"return_code,omitempty"`
+	ReturnedAt   *time.Time   `json:"returned_at,omitempty"`
+	PaymentID    *uuid.UUID   `json:"payment_id,omitempty"`
+}
+
+// DirectDebitReturnsResponse summarizes a processed return file
+type DirectDebitReturnsResponse struct {
+	Collected int      `json:"collected"`
+	Returned  int      `json:"returned"`
+	Reversed  int      `json:"reversed"`  // Returned after the payment had been recorded
+	Unchanged int      `json:"unchanged"` // Already processed
+	Errors    []string `json:"errors,omitempty"`
+}
@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// SaveDunningPolicyRequest replaces the organization's reminder schedule.
+// Without steps the default schedule is used: 3 days before the due date,
+// on the due date, and 7 and 30 days after it.
+type SaveDunningPolicyRequest struct {
+	TimeZone string               `json:"time_zone"` // IANA name such as Europe/Berlin, defaults to UTC
+	Enabled  *bool                `json:"enabled"`   // Defaults to true
+	Steps    []DunningStepRequest `json:"steps"`
+}
+
+type DunningStepRequest struct {
+	DaysFromDue int    `json:"days_from_due"` // Negative for reminders before the due date
+	Level       int    `json:"level"`
+	Name        string `json:"name" validate:"required"`
+}
+
+type DunningPolicyResponse struct {
+	TimeZone  string               `json:"time_zone"`
+	Enabled   bool                 `json:"enabled"`
+	Steps     []DunningStepRequest `json:"steps"`
+	UpdatedAt *time.Time           `json:"updated_at,omitempty"` // Unset while the organization has no policy
+}
+
+type DunningRunResponse struct {
+	MarkedOverdue       int `json:"marked_overdue"`
+	InstallmentsOverdue int `json:"installments_overdue"`
+	RemindersDue        int `json:"reminders_due"`
+}
+
+type DunningReminderResponse struct {
+	ID            uuid.UUID    `json:"id"`
+	InvoiceID     uuid.UUID    `json:"invoice_id"`
+	DueDate       time.Time    `json:"due_date"`
+	DaysFromDue   int          `json:"days_from_due"`
+	Level         int          `json:"level"`
+	Name          string       `json:"name"`
+	BalanceAmount money.Amount `json:"balance_amount"`
+	Currency      string       `json:"currency"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
@@ -0,0 +1,94 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// CreateEstimateRequest creates or revises an estimate. Lines and discounts
+// are given and priced exactly as on an invoice.
+type CreateEstimateRequest struct {
+	Subject         string              `json:"subject" validate:"required"`
+	CustomerID      uuid.UUID           `json:"customer_id" validate:"required"`
+	ContactID       *uuid.UUID          `json:"contact_id"`
+	OwnerID         *uuid.UUID          `json:"owner_id"`
+	EstimateDate    time.Time           `json:"estimate_date"` // Defaults to today
+	ExpiryDate      *time.Time          `json:"expiry_date"`   // Last day the customer may accept
+	ReferenceNo     string              `json:"reference_no"`
+	Currency        string              `json:"currency"`
+	Adjustment      money.Amount        `json:"adjustment"`
+	Discount        money.Amount        `json:"discount"`         // Estimate-level fixed discount
+	DiscountPct     money.Amount        `json:"discount_percent"` // Estimate-level percentage discount
+	TaxInclusive    bool                `json:"tax_inclusive"`
+	Terms           string              `json:"terms"`
+	Notes           string              `json:"notes"`
+	BillingStreet   string              `json:"billing_street"`
+	BillingCity     string              `json:"billing_city"`
+	BillingState    string              `json:"billing_state"`
+	BillingCode     string              `json:"billing_code"`
+	BillingCountry  string              `json:"billing_country"`
+	ShippingStreet  string              `json:"shipping_street"`
+	ShippingCity    string              `json:"shipping_city"`
+	ShippingState   string              `json:"shipping_state"`
+	ShippingCode    string              `json:"shipping_code"`
+	ShippingCountry string              `json:"shipping_country"`
+	Items           []CreateInvoiceItem `json:"items" validate:"required,min=1"`
+}
+
+type UpdateEstimateStatusRequest struct {
+	Status string `json:"status" validate:"required"` // sent, accepted, declined, expired or draft
+}
+
+// ConvertEstimateRequest holds the invoice fields that an estimate does not carry
+type ConvertEstimateRequest struct {
+	InvoiceDate   *time.Time `json:"invoice_date"`    // Defaults to today
+	DueDate       *time.Time `json:"due_date"`        // Worked out from the payment term when left empty
+	PaymentTermID *uuid.UUID `json:"payment_term_id"` // Defaults to the customer's term, then the organization's
+	SalesOrder    string     `json:"sales_order"`
+	PurchaseOrder string     `json:"purchase_order"`
+}
+
+type EstimateResponse struct {
+	ID              uuid.UUID         `json:"id"`
+	EstimateNumber  string            `json:"estimate_number"`
+	Subject         string            `json:"subject"`
+	Status          string            `json:"status"`
+	CustomerID      uuid.UUID         `json:"customer_id"`
+	ContactID       *uuid.UUID        `json:"contact_id"`
+	OwnerID         *uuid.UUID        `json:"owner_id"`
+	ReferenceNo     string            `json:"reference_no"`
+	EstimateDate    time.Time         `json:"estimate_date"`
+	ExpiryDate      *time.Time        `json:"expiry_date,omitempty"`
+	SubTotal        money.Amount      `json:"sub_total"`
+	Discount        money.Amount      `json:"discount"`
+	DiscountPct     money.Amount      `json:"discount_percent"`
+	DiscountTotal   money.Amount      `json:"discount_total"`
+	TaxTotal        money.Amount      `json:"tax_total"`
+	TaxInclusive    bool              `json:"tax_inclusive"`
+	Taxes           []TaxResponse     `json:"taxes,omitempty"`
+	Adjustment      money.Amount      `json:"adjustment"`
+	TotalAmount     money.Amount      `json:"total_amount"`
+	Currency        string            `json:"currency"`
+	Customer        *CustomerResponse `json:"customer,omitempty"`
+	Items           []ItemResponse    `json:"items,omitempty"`
+	Terms           string            `json:"terms"`
+	Notes           string            `json:"notes"`
+	BillingStreet   string            `json:"billing_street"`
+	BillingCity     string            `json:"billing_city"`
+	BillingState    string            `json:"billing_state"`
+	BillingCode     string            `json:"billing_code"`
+	BillingCountry  string            `json:"billing_country"`
+	ShippingStreet  string            `json:"shipping_street"`
+	ShippingCity    string            `json:"shipping_city"`
+	ShippingState   string            `json:"shipping_state"`
+	ShippingCode    string            `json:"shipping_code"`
+	ShippingCountry string            `json:"shipping_country"`
+	InvoiceID       *uuid.UUID        `json:"invoice_id,omitempty"`
+	ConvertedAt     *time.Time        `json:"converted_at,omitempty"`
+	BilledAmount    money.Amount      `json:"billed_amount"` // Billed through progress invoices
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
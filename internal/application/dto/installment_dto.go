@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// SetInstallmentsRequest gives an invoice a payment schedule, either listed
+// installment by installment or generated from a count and frequency
+type SetInstallmentsRequest struct {
+	Installments []InstallmentRequest `json:"installments"`   // Must add up to the invoice total
+	Count        int                  `json:"count"`          // Number of even installments to generate
+	Frequency    string               `json:"frequency"`      // weekly, monthly, quarterly or annual; defaults to monthly
+	FirstDueDate *time.Time           `json:"first_due_date"` // Defaults to the invoice's first due date
+}
+
+type InstallmentRequest struct {
+	DueDate time.Time    `json:"due_date"`
+	Amount  money.Amount `json:"amount"`
+}
+
+type InstallmentResponse struct {
+	ID          uuid.UUID    `json:"id"`
+	Sequence    int          `json:"sequence"`
+	DueDate     time.Time    `json:"due_date"`
+	Amount      money.Amount `json:"amount"`
+	PaidAmount  money.Amount `json:"paid_amount"`
+	Outstanding money.Amount `json:"outstanding"`
+	Status      string       `json:"status"`
+}
+
+// AgingBalanceResponse is a balance split by how many days it is past due
+type AgingBalanceResponse struct {
+	Current    money.Amount `json:"current"`
+	Days1To30  money.Amount `json:"days_1_30"`
+	Days31To60 money.Amount `json:"days_31_60"`
+	Days61To90 money.Amount `json:"days_61_90"`
+	Over90     money.Amount `json:"over_90"`
+	Total      money.Amount `json:"total"`
+}
+
+type CustomerAgingResponse struct {
+	CustomerID   uuid.UUID `json:"customer_id"`
+	CustomerName string    `json:"customer_name"`
+	AgingBalanceResponse
+}
+
+// AgingReportResponse ages the open receivables of one currency
+type AgingReportResponse struct {
+	AsOf      time.Time               `json:"as_of"`
+	Currency  string                  `json:"currency"`
+	Customers []CustomerAgingResponse `json:"customers"`
+	Total     AgingBalanceResponse    `json:"total"`
+}
@@ -3,6 +3,8 @@ package dto
 import (
 	"time"
 
+	"erp-billing-service/pkg/money"
+
 	"github.com/google/uuid"
 )
 
@@ -12,14 +14,18 @@ type CreateInvoiceRequest struct {
 	ContactID       *uuid.UUID          `json:"contact_id"`
 	OwnerID         *uuid.UUID          `json:"owner_id"`
 	InvoiceDate     time.Time           `json:"invoice_date"`
-	DueDate         time.Time           `json:"due_date"`
+	DueDate         time.Time           `json:"due_date"`        // Worked out from the payment term when left empty
+	PaymentTermID   *uuid.UUID          `json:"payment_term_id"` // Defaults to the customer's term, then the organization's
 	ReferenceNo     string              `json:"reference_no"`
 	SalesOrder      string              `json:"sales_order"`
 	PurchaseOrder   string              `json:"purchase_order"`
 	Currency        string              `json:"currency"`
-	Adjustment      float64             `json:"adjustment"`
-	ExciseDuty      float64             `json:"excise_duty"`
-	SalesCommission float64             `json:"sales_commission"`
+	Adjustment      money.Amount        `json:"adjustment"`
+	Discount        money.Amount        `json:"discount"`         // Invoice-level fixed discount
+	DiscountPct     money.Amount        `json:"discount_percent"` // Invoice-level percentage discount
+	TaxInclusive    bool                `json:"tax_inclusive"`    // Unit prices already include tax
+	ExciseDuty      money.Amount        `json:"excise_duty"`
+	SalesCommission money.Amount        `json:"sales_commission"`
 	Terms           string              `json:"terms"`
 	Notes           string              `json:"notes"`
 	BillingStreet   string              `json:"billing_street"`
@@ -36,14 +42,18 @@ type CreateInvoiceRequest struct {
 }
 
 type CreateInvoiceItem struct {
-	ItemID      uuid.UUID `json:"item_id" validate:"required"`
-	ItemType    string    `json:"item_type"` // Optional, defaults to service if not determined
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Quantity    float64   `json:"quantity" validate:"required,gt=0"`
-	UnitPrice   float64   `json:"unit_price" validate:"required,gte=0"`
-	Discount    float64   `json:"discount"`
-	Tax         float64   `json:"tax"`
+	ItemID      uuid.UUID    `json:"item_id" validate:"required"`
+	ItemType    string       `json:"item_type"` // Optional, defaults to service if not determined
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Quantity    float64      `json:"quantity" validate:"required,gt=0"`
+	UnitPrice   money.Amount `json:"unit_price" validate:"required,gte=0"`
+	Discount    money.Amount `json:"discount"`         // Fixed discount on the line
+	DiscountPct money.Amount `json:"discount_percent"` // Percentage discount on the line
+	TaxCode     string       `json:"tax_code"`         // Tax rate or group code; the tax is then computed server-side
+	Tax         money.Amount `json:"tax"`              // Only used for lines without a tax code
+	GroupLabel  string       `json:"group_label"`      // Heading the line is grouped under
+	SourceID    *uuid.UUID   `json:"source_id"`        // Work order or invoice the line was billed from
 }
 
 type InvoiceResponse struct {
@@ -51,18 +61,33 @@ type InvoiceResponse struct {
 	InvoiceNumber   string            `json:"invoice_number"`
 	Subject         string            `json:"subject"`
 	Status          string            `json:"status"`
-	SubTotal        float64           `json:"sub_total"`
-	DiscountTotal   float64           `json:"discount_total"`
-	TaxTotal        float64           `json:"tax_total"`
-	TotalAmount     float64           `json:"total_amount"`
-	PaidAmount      float64           `json:"paid_amount"`
-	BalanceAmount   float64           `json:"balance_amount"`
-	Adjustment      float64           `json:"adjustment"`
-	ExciseDuty      float64           `json:"excise_duty"`
-	SalesCommission float64           `json:"sales_commission"`
+	SubTotal        money.Amount      `json:"sub_total"`
+	Discount        money.Amount      `json:"discount"`
+	DiscountPct     money.Amount      `json:"discount_percent"`
+	DiscountTotal   money.Amount      `json:"discount_total"`
+	TaxTotal        money.Amount      `json:"tax_total"`
+	TaxInclusive    bool              `json:"tax_inclusive"`
+	Taxes           []TaxResponse     `json:"taxes,omitempty"`
+	TotalAmount     money.Amount      `json:"total_amount"`
+	PaidAmount      money.Amount      `json:"paid_amount"`
+	CreditedAmount  money.Amount      `json:"credited_amount"`
+	DebitedAmount   money.Amount      `json:"debited_amount"`
+	ExcessCredit    money.Amount      `json:"excess_credit"`
+	BalanceAmount   money.Amount      `json:"balance_amount"`
+	Adjustment      money.Amount      `json:"adjustment"`
+	ExciseDuty      money.Amount      `json:"excise_duty"`
+	SalesCommission money.Amount      `json:"sales_commission"`
+	LateFeeTotal    money.Amount      `json:"late_fee_total"`
+	PaymentTermID   *uuid.UUID        `json:"payment_term_id,omitempty"`
+	EarlyPayPct     money.Amount      `json:"early_payment_discount_percent"`
+	EarlyPayBy      *time.Time        `json:"early_payment_discount_until,omitempty"`
+	EarlyPayTaken   money.Amount      `json:"early_payment_discount"`
 	SalesOrder      string            `json:"sales_order"`
 	PurchaseOrder   string            `json:"purchase_order"`
 	OwnerID         *uuid.UUID        `json:"owner_id"`
+	WorkOrderID     *uuid.UUID        `json:"work_order_id,omitempty"`
+	EstimateID      *uuid.UUID        `json:"estimate_id,omitempty"`
+	Kind            string            `json:"kind"`
 	CustomerID      uuid.UUID         `json:"customer_id"`
 	ContactID       *uuid.UUID        `json:"contact_id"`
 	InvoiceDate     time.Time         `json:"invoice_date"`
@@ -82,6 +107,13 @@ type InvoiceResponse struct {
 	ShippingState   string            `json:"shipping_state"`
 	ShippingCode    string            `json:"shipping_code"`
 	ShippingCountry string            `json:"shipping_country"`
+	Currency        string            `json:"currency"`
+
+	// Payment schedule, when the invoice is paid in installments
+	Installments []InstallmentResponse `json:"installments,omitempty"`
+
+	// Consolidated invoice a draft was merged into
+	ConsolidatedID *uuid.UUID `json:"consolidated_id,omitempty"`
 }
 
 type CustomerResponse struct {
@@ -98,13 +130,41 @@ type ContactResponse struct {
 }
 
 type ItemResponse struct {
-	ItemID      uuid.UUID `json:"item_id"`
-	ItemType    string    `json:"item_type"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Quantity    float64   `json:"quantity"`
-	UnitPrice   float64   `json:"unit_price"`
-	Discount    float64   `json:"discount"`
-	Tax         float64   `json:"tax"`
-	Total       float64   `json:"total"`
+	ItemID      uuid.UUID    `json:"item_id"`
+	ItemType    string       `json:"item_type"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Quantity    float64      `json:"quantity"`
+	UnitPrice   money.Amount `json:"unit_price"`
+	Discount    money.Amount `json:"discount"`
+	DiscountPct money.Amount `json:"discount_percent"`
+	DocDiscount money.Amount `json:"document_discount"`
+	TaxCode     string       `json:"tax_code,omitempty"`
+	GroupLabel  string       `json:"group_label,omitempty"`
+	SourceID    *uuid.UUID   `json:"source_id,omitempty"`
+	Tax         money.Amount `json:"tax"`
+	Total       money.Amount `json:"total"`
+}
+
+// TaxResponse is one row of the invoice tax breakdown
+type TaxResponse struct {
+	TaxRateID     *uuid.UUID   `json:"tax_rate_id,omitempty"`
+	Code          string       `json:"code"`
+	Name          string       `json:"name"`
+	Rate          money.Amount `json:"rate"`
+	Compound      bool         `json:"compound"`
+	TaxableAmount money.Amount `json:"taxable_amount"`
+	TaxAmount     money.Amount `json:"tax_amount"`
+}
+
+// CreateInvoiceFromWorkOrderRequest holds the invoice fields that a work order does not carry
+type CreateInvoiceFromWorkOrderRequest struct {
+	Subject     string     `json:"subject"` // Defaults to the work order summary
+	InvoiceDate *time.Time `json:"invoice_date"`
+	DueDate     *time.Time `json:"due_date"`
+	Currency    string     `json:"currency"`
+	OwnerID     *uuid.UUID `json:"owner_id"`
+	TaxCode     string     `json:"tax_code"` // Applied to every work order line
+	Terms       string     `json:"terms"`
+	Notes       string     `json:"notes"`
 }
@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// SaveLateFeePolicyRequest replaces the organization's late fee policy.
+// Flat fees use Amount; percent fees and daily interest use Rate.
+type SaveLateFeePolicyRequest struct {
+	Type         string       `json:"type" validate:"required"` // flat, percent or daily_interest
+	Amount       money.Amount `json:"amount"`
+	Rate         money.Amount `json:"rate"`          // Percent of the balance, or percent a year for daily interest
+	GraceDays    int          `json:"grace_days"`    // Days overdue before anything is charged
+	IntervalDays int          `json:"interval_days"` // How often accrued interest is charged, defaults to 30
+	Cap          money.Amount `json:"cap"`           // Most that is charged on one invoice, 0 for no cap
+	Mode         string       `json:"mode"`          // fee_invoice (default) or invoice_line
+	Enabled      *bool        `json:"enabled"`       // Defaults to true
+}
+
+type LateFeePolicyResponse struct {
+	Type         string       `json:"type"`
+	Amount       money.Amount `json:"amount"`
+	Rate         money.Amount `json:"rate"`
+	GraceDays    int          `json:"grace_days"`
+	IntervalDays int          `json:"interval_days"`
+	Cap          money.Amount `json:"cap"`
+	Mode         string       `json:"mode"`
+	Enabled      bool         `json:"enabled"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+type LateFeeRunResponse struct {
+	Charged int `json:"charged"`
+}
+
+type LateFeeChargeResponse struct {
+	ID             uuid.UUID    `json:"id"`
+	InvoiceID      uuid.UUID    `json:"invoice_id"`
+	Type           string       `json:"type"`
+	Mode           string       `json:"mode"`
+	AccruedFrom    time.Time    `json:"accrued_from"`
+	AccruedThrough time.Time    `json:"accrued_through"`
+	Days           int          `json:"days,omitempty"`
+	Basis          money.Amount `json:"basis"`
+	Rate           money.Amount `json:"rate"`
+	Amount         money.Amount `json:"amount"`
+	Currency       string       `json:"currency"`
+	Capped         bool         `json:"capped"`
+	FeeInvoiceID   *uuid.UUID   `json:"fee_invoice_id,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
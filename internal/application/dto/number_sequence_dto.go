@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+type UpdateNumberSequenceRequest struct {
+	Prefix          string `json:"prefix"`
+	Format          string `json:"format" validate:"required"` // e.g. {PREFIX}-{YYYY}-{SEQ}
+	Padding         int    `json:"padding" validate:"required"`
+	ResetPeriod     string `json:"reset_period" validate:"required"` // never, yearly, monthly or fiscal_year
+	FiscalYearStart int    `json:"fiscal_year_start"`                // Month, defaults to 1
+	NextValue       *int64 `json:"next_value"`                       // Leave empty to keep counting
+}
+
+type NumberSequenceResponse struct {
+	DocumentType    string    `json:"document_type"`
+	Prefix          string    `json:"prefix"`
+	Format          string    `json:"format"`
+	Padding         int       `json:"padding"`
+	ResetPeriod     string    `json:"reset_period"`
+	FiscalYearStart int       `json:"fiscal_year_start"`
+	NextValue       int64     `json:"next_value"`
+	CurrentPeriod   string    `json:"current_period"`
+	NextNumber      string    `json:"next_number"` // Number the next document would get now
+	UpdatedAt       time.Time `json:"updated_at"`
+}
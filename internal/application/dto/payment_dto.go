@@ -0,0 +1,169 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type RecordPaymentRequest struct {
+	Amount         money.Amount `json:"amount" validate:"required"`
+	PaymentMethod  string       `json:"payment_method" validate:"required"` // cash, check, bank_transfer, card or other
+	PaymentDate    *time.Time   `json:"payment_date"`                       // Defaults to now
+	TransactionRef string       `json:"transaction_ref"`
+	Notes          string       `json:"notes"`
+	IdempotencyKey string       `json:"idempotency_key"` // Also read from the Idempotency-Key header
+}
+
+// RecordCustomerPaymentRequest records one payment from a customer and
+// spreads it over several of their invoices
+type RecordCustomerPaymentRequest struct {
+	CustomerID     uuid.UUID           `json:"customer_id" validate:"required"`
+	Amount         money.Amount        `json:"amount" validate:"required"`
+	Currency       string              `json:"currency"`                           // Defaults to USD
+	PaymentMethod  string              `json:"payment_method" validate:"required"` // cash, check, bank_transfer, card or other
+	PaymentDate    *time.Time          `json:"payment_date"`                       // Defaults to now
+	TransactionRef string              `json:"transaction_ref"`
+	Notes          string              `json:"notes"`
+	IdempotencyKey string              `json:"idempotency_key"` // Also read from the Idempotency-Key header
+	Strategy       string              `json:"strategy"`        // oldest_first, exact_match or manual; manual when allocations are given, oldest_first otherwise
+	Allocations    []AllocationRequest `json:"allocations"`     // Only used by the manual strategy
+}
+
+type AllocationRequest struct {
+	InvoiceID uuid.UUID    `json:"invoice_id" validate:"required"`
+	Amount    money.Amount `json:"amount" validate:"required"`
+}
+
+// ReallocatePaymentRequest replaces every allocation of a payment. Invoices
+// left out are no longer paid by it; what is not allocated becomes credit.
+type ReallocatePaymentRequest struct {
+	Allocations []AllocationRequest `json:"allocations"`
+	Notes       string              `json:"notes"`
+}
+
+type PaymentResponse struct {
+	ID             uuid.UUID                   `json:"id"`
+	CustomerID     uuid.UUID                   `json:"customer_id"`
+	InvoiceID      *uuid.UUID                  `json:"invoice_id,omitempty"` // Set when the payment was recorded against one invoice
+	Currency       string                      `json:"currency"`
+	Amount         money.Amount                `json:"amount"`    // Allocated to invoices
+	Unapplied      money.Amount                `json:"unapplied"` // Kept as customer credit
+	PaymentDate    time.Time                   `json:"payment_date"`
+	PaymentMethod  string                      `json:"payment_method"`
+	TransactionRef string                      `json:"transaction_ref"`
+	Notes          string                      `json:"notes"`
+	Refunded       money.Amount                `json:"refunded"` // Refunded or reversed so far
+	Status         string                      `json:"status"`   // completed, partially_refunded, refunded or reversed
+	Allocations    []PaymentAllocationResponse `json:"allocations"`
+	CreatedAt      time.Time                   `json:"created_at"`
+}
+
+type PaymentAllocationResponse struct {
+	ID        uuid.UUID    `json:"id"`
+	InvoiceID uuid.UUID    `json:"invoice_id"`
+	Amount    money.Amount `json:"amount"`
+	CreatedBy string       `json:"created_by"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// PaymentReceiptResponse is a payment together with the invoices it settled.
+// The invoice fields are only set when the payment settled a single invoice.
+type PaymentReceiptResponse struct {
+	PaymentResponse
+	InvoiceNumber string              `json:"invoice_number,omitempty"`
+	Customer      *CustomerResponse   `json:"customer,omitempty"`
+	InvoiceTotal  money.Amount        `json:"invoice_total"`
+	PaidAmount    money.Amount        `json:"paid_amount"`    // All payments on the invoice so far
+	BalanceAmount money.Amount        `json:"balance_amount"` // Still due on the invoice
+	InvoiceStatus string              `json:"invoice_status,omitempty"`
+	Invoices      []ReceiptAllocation `json:"invoices"`
+}
+
+// ReceiptAllocation is one invoice a payment was applied to, as it stands now
+type ReceiptAllocation struct {
+	InvoiceID     uuid.UUID    `json:"invoice_id"`
+	InvoiceNumber string       `json:"invoice_number"`
+	Allocated     money.Amount `json:"allocated"` // Paid by this payment
+	InvoiceTotal  money.Amount `json:"invoice_total"`
+	PaidAmount    money.Amount `json:"paid_amount"`
+	BalanceAmount money.Amount `json:"balance_amount"`
+	InvoiceStatus string       `json:"invoice_status"`
+}
+
+// RefundPaymentRequest pays part or all of a payment back to the customer.
+// Unapplied credit of the payment is refunded first, then its allocations
+// are taken back, most recent first.
+type RefundPaymentRequest struct {
+	Amount        money.Amount `json:"amount" validate:"required"`
+	PaymentMethod string       `json:"payment_method" validate:"required"` // How the money is paid back
+	RefundDate    *time.Time   `json:"refund_date"`                        // Defaults to now
+	Reference     string       `json:"reference"`
+	Reason        string       `json:"reason"`
+}
+
+// ReversePaymentRequest undoes a payment that never arrived, such as a
+// bounced check or a chargeback. Whatever is left of it is taken back.
+type ReversePaymentRequest struct {
+	ReversalDate *time.Time `json:"reversal_date"` // Defaults to now
+	Reference    string     `json:"reference"`
+	Reason       string     `json:"reason" validate:"required"`
+}
+
+type PaymentRefundResponse struct {
+	ID            uuid.UUID       `json:"id"`
+	PaymentID     uuid.UUID       `json:"payment_id"`
+	RefundType    string          `json:"refund_type"` // refund or reversal
+	Currency      string          `json:"currency"`
+	Amount        money.Amount    `json:"amount"`
+	FromCredit    money.Amount    `json:"from_credit"` // Taken out of the payment's unapplied credit
+	RefundDate    time.Time       `json:"refund_date"`
+	PaymentMethod string          `json:"payment_method"`
+	Reference     string          `json:"reference"`
+	Reason        string          `json:"reason"`
+	CreatedBy     string          `json:"created_by"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Payment       PaymentResponse `json:"payment"` // The payment after the refund
+}
+
+type ApplyCreditRequest struct {
+	InvoiceID      uuid.UUID    `json:"invoice_id" validate:"required"`
+	Amount         money.Amount `json:"amount" validate:"required"`
+	IdempotencyKey string       `json:"idempotency_key"`
+}
+
+type RefundCreditRequest struct {
+	Amount        money.Amount `json:"amount" validate:"required"`
+	Currency      string       `json:"currency" validate:"required"`
+	PaymentMethod string       `json:"payment_method" validate:"required"` // How the money is paid back
+	Reference     string       `json:"reference"`
+	Notes         string       `json:"notes"`
+}
+
+type CustomerCreditResponse struct {
+	CustomerID uuid.UUID             `json:"customer_id"`
+	Balances   []CreditBalance       `json:"balances"`
+	Entries    []CreditEntryResponse `json:"entries"`
+}
+
+type CreditBalance struct {
+	Currency string       `json:"currency"`
+	Balance  money.Amount `json:"balance"`
+}
+
+type CreditEntryResponse struct {
+	ID           uuid.UUID    `json:"id"`
+	EntryType    string       `json:"entry_type"`
+	Currency     string       `json:"currency"`
+	Amount       money.Amount `json:"amount"`
+	BalanceAfter money.Amount `json:"balance_after"`
+	PaymentID    *uuid.UUID   `json:"payment_id,omitempty"`
+	InvoiceID    *uuid.UUID   `json:"invoice_id,omitempty"`
+	Method       string       `json:"payment_method"`
+	Reference    string       `json:"reference"`
+	Notes        string       `json:"notes"`
+	CreatedBy    string       `json:"created_by"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
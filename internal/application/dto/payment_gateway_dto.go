@@ -0,0 +1,39 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type CreatePaymentIntentRequest struct {
+	Amount         *money.Amount `json:"amount"`         // Defaults to the amount due
+	PaymentMethod  string        `json:"payment_method"` // card or bank_transfer; defaults to card
+	IdempotencyKey string        `json:"idempotency_key"`
+}
+
+type CapturePaymentIntentRequest struct {
+	Amount *money.Amount `json:"amount"` // Defaults to the authorized amount
+}
+
+type GatewayRefundRequest struct {
+	Amount   money.Amount `json:"amount" validate:"required"`
+	Reason   string       `json:"reason"`
+	RefundID *uuid.UUID   `json:"refund_id"` // Refund of an earlier attempt the provider did not confirm; it is sent again instead of recording another
+}
+
+type PaymentIntentResponse struct {
+	ID             string           `json:"id"`
+	Provider       string           `json:"provider"`
+	InvoiceID      uuid.UUID        `json:"invoice_id"`
+	Amount         money.Amount     `json:"amount"`
+	Currency       string           `json:"currency"`
+	PaymentMethod  string           `json:"payment_method"`
+	Status         string           `json:"status"` // requires_capture, succeeded or failed
+	ClientSecret   string           `json:"client_secret,omitempty"`
+	TransactionRef string           `json:"transaction_ref,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Payment        *PaymentResponse `json:"payment,omitempty"` // Set once the captured charge is recorded
+}
@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type CreatePaymentTermRequest struct {
+	Code         string       `json:"code" validate:"required"`
+	Name         string       `json:"name" validate:"required"`
+	Type         string       `json:"type" validate:"required"` // net, end_of_month or due_on_receipt
+	Days         int          `json:"days"`                     // After the invoice date, or after the end of its month
+	DiscountPct  money.Amount `json:"discount_percent"`         // Early-payment discount, e.g. 2 for 2/10 Net 30
+	DiscountDays int          `json:"discount_days"`            // Days after the invoice date the discount is offered
+	IsDefault    bool         `json:"is_default"`               // Used for customers without a term of their own
+}
+
+type PaymentTermResponse struct {
+	ID           uuid.UUID    `json:"id"`
+	Code         string       `json:"code"`
+	Name         string       `json:"name"`
+	Type         string       `json:"type"`
+	Days         int          `json:"days"`
+	DiscountPct  money.Amount `json:"discount_percent"`
+	DiscountDays int          `json:"discount_days"`
+	IsDefault    bool         `json:"is_default"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+type AssignPaymentTermRequest struct {
+	PaymentTermID uuid.UUID `json:"payment_term_id" validate:"required"`
+}
+
+type CustomerPaymentTermResponse struct {
+	CustomerID  uuid.UUID           `json:"customer_id"`
+	PaymentTerm PaymentTermResponse `json:"payment_term"`
+}
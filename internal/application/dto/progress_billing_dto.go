@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// CreateDepositRequest bills a deposit ahead of a work order or estimate, or
+// a retainer when neither is given. What the customer pays on it is held as
+// credit and applied to the job's final invoice when that is issued.
+type CreateDepositRequest struct {
+	CustomerID    uuid.UUID    `json:"customer_id"` // Required for a retainer, taken from the job otherwise
+	WorkOrderID   *uuid.UUID   `json:"work_order_id"`
+	EstimateID    *uuid.UUID   `json:"estimate_id"`
+	Percent       money.Amount `json:"percent"` // Share of the job's total; give either this or amount
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"` // Defaults to the estimate's currency, then USD
+	Description   string       `json:"description"`
+	InvoiceDate   *time.Time   `json:"invoice_date"`    // Defaults to today
+	DueDate       *time.Time   `json:"due_date"`        // Worked out from the payment term when left empty
+	PaymentTermID *uuid.UUID   `json:"payment_term_id"` // Defaults to the customer's term, then the organization's
+	PurchaseOrder string       `json:"purchase_order"`
+}
+
+// CreateProgressInvoiceRequest bills a percentage or milestone of a work
+// order or estimate
+type CreateProgressInvoiceRequest struct {
+	Milestone     string       `json:"milestone"`
+	Percent       money.Amount `json:"percent"` // Share of the job's total; give either this, amount or final
+	Amount        money.Amount `json:"amount"`
+	Final         bool         `json:"final"`    // Bills whatever is left of the job
+	Currency      string       `json:"currency"` // Work orders only; estimates bill in their own currency
+	TaxCode       string       `json:"tax_code"`
+	InvoiceDate   *time.Time   `json:"invoice_date"`    // Defaults to today
+	DueDate       *time.Time   `json:"due_date"`        // Worked out from the payment term when left empty
+	PaymentTermID *uuid.UUID   `json:"payment_term_id"` // Defaults to the customer's term, then the organization's
+	PurchaseOrder string       `json:"purchase_order"`
+}
+
+type ProgressBillResponse struct {
+	ID         uuid.UUID    `json:"id"`
+	Kind       string       `json:"kind"`
+	SourceType string       `json:"source_type,omitempty"`
+	SourceID   *uuid.UUID   `json:"source_id,omitempty"`
+	InvoiceID  *uuid.UUID   `json:"invoice_id,omitempty"`
+	InvoiceNo  string       `json:"invoice_number,omitempty"`
+	Status     string       `json:"invoice_status,omitempty"`
+	Milestone  string       `json:"milestone"`
+	Percent    money.Amount `json:"percent"`
+	Amount     money.Amount `json:"amount"`
+	Currency   string       `json:"currency"`
+	Final      bool         `json:"final"`
+	Held       money.Amount `json:"held,omitempty"`    // Deposits only
+	Applied    money.Amount `json:"applied,omitempty"` // Deposits only
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// ProgressSummaryResponse shows how much of a job has been billed. Amounts
+// are before tax.
+type ProgressSummaryResponse struct {
+	SourceType      string                 `json:"source_type"`
+	SourceID        uuid.UUID              `json:"source_id"`
+	Currency        string                 `json:"currency"`
+	ContractAmount  money.Amount           `json:"contract_amount"`
+	BilledAmount    money.Amount           `json:"billed_amount"` // Through progress invoices that are not void
+	RemainingAmount money.Amount           `json:"remaining_amount"`
+	DepositAmount   money.Amount           `json:"deposit_amount"` // Billed as deposits
+	DepositHeld     money.Amount           `json:"deposit_held"`   // Paid on the deposits
+	DepositApplied  money.Amount           `json:"deposit_applied"`
+	Bills           []ProgressBillResponse `json:"bills"`
+}
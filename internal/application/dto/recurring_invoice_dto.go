@@ -0,0 +1,76 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type CreateRecurringProfileRequest struct {
+	Name           string                   `json:"name" validate:"required"`
+	CustomerID     uuid.UUID                `json:"customer_id" validate:"required"`
+	Frequency      string                   `json:"frequency" validate:"required"` // weekly, monthly, quarterly, annual or custom
+	CronExpression string                   `json:"cron_expression"`               // Required when frequency is custom
+	StartDate      time.Time                `json:"start_date" validate:"required"`
+	EndDate        *time.Time               `json:"end_date"`
+	AutoSend       bool                     `json:"auto_send"`
+	Template       RecurringInvoiceTemplate `json:"template" validate:"required"`
+}
+
+// RecurringInvoiceTemplate holds the invoice fields copied into every generated invoice
+type RecurringInvoiceTemplate struct {
+	Subject         string              `json:"subject" validate:"required"`
+	ContactID       *uuid.UUID          `json:"contact_id"`
+	OwnerID         *uuid.UUID          `json:"owner_id"`
+	ReferenceNo     string              `json:"reference_no"`
+	PurchaseOrder   string              `json:"purchase_order"`
+	Currency        string              `json:"currency"`
+	DueInDays       int                 `json:"due_in_days"`
+	Adjustment      money.Amount        `json:"adjustment"`
+	Discount        money.Amount        `json:"discount"`
+	DiscountPct     money.Amount        `json:"discount_percent"`
+	TaxInclusive    bool                `json:"tax_inclusive"`
+	Terms           string              `json:"terms"`
+	Notes           string              `json:"notes"`
+	BillingStreet   string              `json:"billing_street"`
+	BillingCity     string              `json:"billing_city"`
+	BillingState    string              `json:"billing_state"`
+	BillingCode     string              `json:"billing_code"`
+	BillingCountry  string              `json:"billing_country"`
+	ShippingStreet  string              `json:"shipping_street"`
+	ShippingCity    string              `json:"shipping_city"`
+	ShippingState   string              `json:"shipping_state"`
+	ShippingCode    string              `json:"shipping_code"`
+	ShippingCountry string              `json:"shipping_country"`
+	Items           []CreateInvoiceItem `json:"items" validate:"required,min=1"`
+}
+
+type RecurringProfileResponse struct {
+	ID              uuid.UUID                `json:"id"`
+	Name            string                   `json:"name"`
+	CustomerID      uuid.UUID                `json:"customer_id"`
+	Frequency       string                   `json:"frequency"`
+	CronExpression  string                   `json:"cron_expression,omitempty"`
+	StartDate       time.Time                `json:"start_date"`
+	EndDate         *time.Time               `json:"end_date,omitempty"`
+	NextRunAt       *time.Time               `json:"next_run_at,omitempty"`
+	LastRunAt       *time.Time               `json:"last_run_at,omitempty"`
+	OccurrenceCount int                      `json:"occurrence_count"`
+	AutoSend        bool                     `json:"auto_send"`
+	Status          string                   `json:"status"`
+	Template        RecurringInvoiceTemplate `json:"template"`
+	CreatedAt       time.Time                `json:"created_at"`
+	UpdatedAt       time.Time                `json:"updated_at"`
+}
+
+type RecurringRunResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	ProfileID    uuid.UUID  `json:"profile_id"`
+	ScheduledFor time.Time  `json:"scheduled_for"`
+	Status       string     `json:"status"`
+	InvoiceID    *uuid.UUID `json:"invoice_id,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
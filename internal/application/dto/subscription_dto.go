@@ -0,0 +1,141 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// CreatePlanRequest defines a subscription plan: a base fee billed every
+// period and metered components priced by the usage recorded against them
+type CreatePlanRequest struct {
+	Code       string                 `json:"code" validate:"required"`
+	Name       string                 `json:"name" validate:"required"`
+	Currency   string                 `json:"currency"` // Defaults to USD
+	Interval   string                 `json:"interval"` // weekly, monthly, quarterly or annual; defaults to monthly
+	BaseFee    money.Amount           `json:"base_fee"`
+	ItemID     *uuid.UUID             `json:"item_id"` // Item the base fee is billed as
+	TaxCode    string                 `json:"tax_code"`
+	Components []PlanComponentRequest `json:"components"`
+}
+
+type PlanComponentRequest struct {
+	Metric  string             `json:"metric" validate:"required"` // Key usage is recorded under, e.g. site_visits
+	Name    string             `json:"name"`                       // Defaults to the metric
+	Unit    string             `json:"unit"`
+	ItemID  *uuid.UUID         `json:"item_id"`
+	TaxCode string             `json:"tax_code"`
+	Pricing string             `json:"pricing"` // flat, volume or graduated; defaults to flat
+	Tiers   []PriceTierRequest `json:"tiers"`
+}
+
+type PriceTierRequest struct {
+	UpTo      *float64     `json:"up_to"` // Left out on the last tier
+	UnitPrice money.Amount `json:"unit_price"`
+	FlatFee   money.Amount `json:"flat_fee"`
+}
+
+// UpdatePlanRequest renames a plan or stops it taking new subscribers.
+// Prices are not changed on a plan in use; a new plan is made instead.
+type UpdatePlanRequest struct {
+	Name   *string `json:"name"`
+	Active *bool   `json:"active"`
+}
+
+type PlanResponse struct {
+	ID         uuid.UUID               `json:"id"`
+	Code       string                  `json:"code"`
+	Name       string                  `json:"name"`
+	Currency   string                  `json:"currency"`
+	Interval   string                  `json:"interval"`
+	BaseFee    money.Amount            `json:"base_fee"`
+	ItemID     *uuid.UUID              `json:"item_id,omitempty"`
+	TaxCode    string                  `json:"tax_code,omitempty"`
+	Active     bool                    `json:"active"`
+	Components []PlanComponentResponse `json:"components"`
+	CreatedAt  time.Time               `json:"created_at"`
+	UpdatedAt  time.Time               `json:"updated_at"`
+}
+
+type PlanComponentResponse struct {
+	ID      uuid.UUID          `json:"id"`
+	Metric  string             `json:"metric"`
+	Name    string             `json:"name"`
+	Unit    string             `json:"unit,omitempty"`
+	ItemID  *uuid.UUID         `json:"item_id,omitempty"`
+	TaxCode string             `json:"tax_code,omitempty"`
+	Pricing string             `json:"pricing"`
+	Tiers   []PriceTierRequest `json:"tiers"`
+}
+
+type CreateSubscriptionRequest struct {
+	CustomerID    uuid.UUID  `json:"customer_id" validate:"required"`
+	PlanID        uuid.UUID  `json:"plan_id" validate:"required"`
+	StartDate     *time.Time `json:"start_date"`      // Defaults to today; anchors the billing periods
+	PaymentTermID *uuid.UUID `json:"payment_term_id"` // Defaults to the customer's term, then the organization's
+	PurchaseOrder string     `json:"purchase_order"`
+}
+
+// ChangePlanRequest moves a subscription to another plan with the same
+// currency and billing period. The current period is billed on each plan for
+// the days spent on it.
+type ChangePlanRequest struct {
+	PlanID      uuid.UUID  `json:"plan_id" validate:"required"`
+	EffectiveAt *time.Time `json:"effective_at"` // Defaults to today
+}
+
+type CancelSubscriptionRequest struct {
+	EndsAt *time.Time `json:"ends_at"` // First day no longer covered; defaults to the end of the current period
+}
+
+type SubscriptionResponse struct {
+	ID                 uuid.UUID     `json:"id"`
+	CustomerID         uuid.UUID     `json:"customer_id"`
+	Plan               *PlanResponse `json:"plan,omitempty"`
+	PlanID             uuid.UUID     `json:"plan_id"`
+	PlanSince          time.Time     `json:"plan_since"`
+	Status             string        `json:"status"`
+	StartDate          time.Time     `json:"start_date"`
+	CurrentPeriodStart time.Time     `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time     `json:"current_period_end"`
+	EndsAt             *time.Time    `json:"ends_at,omitempty"`
+	PaymentTermID      *uuid.UUID    `json:"payment_term_id,omitempty"`
+	PurchaseOrder      string        `json:"purchase_order,omitempty"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+}
+
+// RecordUsageRequest reports metered usage. Resending a request with the same
+// idempotency key records it once.
+type RecordUsageRequest struct {
+	Metric         string     `json:"metric" validate:"required"`
+	Quantity       float64    `json:"quantity" validate:"required,gt=0"`
+	OccurredAt     *time.Time `json:"occurred_at"` // Defaults to now
+	IdempotencyKey string     `json:"idempotency_key"`
+}
+
+type UsageRecordResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	SubscriptionID uuid.UUID  `json:"subscription_id"`
+	Metric         string     `json:"metric"`
+	Quantity       float64    `json:"quantity"`
+	OccurredAt     time.Time  `json:"occurred_at"`
+	IdempotencyKey string     `json:"idempotency_key"`
+	Source         string     `json:"source"`
+	InvoiceID      *uuid.UUID `json:"invoice_id,omitempty"`
+	Duplicate      bool       `json:"duplicate,omitempty"` // Recorded earlier under the same key
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type SubscriptionRunResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	PeriodStart time.Time  `json:"period_start"`
+	PeriodEnd   time.Time  `json:"period_end"`
+	Status      string     `json:"status"`
+	InvoiceID   *uuid.UUID `json:"invoice_id,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
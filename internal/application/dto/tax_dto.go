@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type CreateTaxRateRequest struct {
+	Code     string       `json:"code" validate:"required"`
+	Name     string       `json:"name" validate:"required"`
+	Rate     money.Amount `json:"rate" validate:"required"` // Percentage, e.g. 7.25
+	Compound bool         `json:"compound"`
+	Active   *bool        `json:"active"` // Defaults to true
+}
+
+type CreateTaxGroupRequest struct {
+	Code    string      `json:"code" validate:"required"`
+	Name    string      `json:"name" validate:"required"`
+	RateIDs []uuid.UUID `json:"rate_ids" validate:"required,min=1"` // In the order they apply
+	Active  *bool       `json:"active"`                             // Defaults to true
+}
+
+type TaxRateResponse struct {
+	ID        uuid.UUID    `json:"id"`
+	Code      string       `json:"code"`
+	Name      string       `json:"name"`
+	Rate      money.Amount `json:"rate"`
+	Compound  bool         `json:"compound"`
+	Active    bool         `json:"active"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type TaxGroupResponse struct {
+	ID        uuid.UUID         `json:"id"`
+	Code      string            `json:"code"`
+	Name      string            `json:"name"`
+	Active    bool              `json:"active"`
+	Rates     []TaxRateResponse `json:"rates"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
@@ -0,0 +1,83 @@
+package application
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DunningScheduler periodically runs dunning for all organizations, then
+// charges the late fees of the invoices it found overdue. Running several
+// instances is safe: overdue marking is conditional and every reminder and
+// fee is claimed in the database before it is announced.
+type DunningScheduler struct {
+	service  *DunningService
+	lateFees *LateFeeService
+	interval time.Duration
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewDunningScheduler(service *DunningService, lateFees *LateFeeService, interval time.Duration) *DunningScheduler {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &DunningScheduler{
+		service:  service,
+		lateFees: lateFees,
+		interval: interval,
+	}
+}
+
+// Start runs the scheduler in the background until Stop is called
+func (s *DunningScheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.tick(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Dunning scheduler started (interval %s)", s.interval)
+}
+
+// Stop signals the scheduler to finish and waits for the current tick
+func (s *DunningScheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+func (s *DunningScheduler) tick(ctx context.Context) {
+	now := time.Now().UTC()
+	res, err := s.service.RunDue(ctx, now)
+	if err != nil {
+		log.Printf("Dunning scheduler: %v", err)
+		return
+	}
+	if res.MarkedOverdue > 0 || res.RemindersDue > 0 {
+		log.Printf("Dunning scheduler marked %d invoice(s) overdue and sent %d reminder(s)", res.MarkedOverdue, res.RemindersDue)
+	}
+
+	fees, err := s.lateFees.RunDue(ctx, now)
+	if err != nil {
+		log.Printf("Dunning scheduler: late fees: %v", err)
+		return
+	}
+	if fees.Charged > 0 {
+		log.Printf("Dunning scheduler charged %d late fee(s)", fees.Charged)
+	}
+}
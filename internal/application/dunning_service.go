@@ -0,0 +1,329 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// dunningActor is recorded as the author of everything the dunning run does
+const dunningActor = "Dunning"
+
+// DunningService chases unpaid invoices. Once an invoice's due date has
+// passed in the organization's time zone it is marked overdue, and the steps
+// of the organization's reminder schedule are announced to the notification
+// service as they come due. Invoices being collected by direct debit are
+// left alone. Every step is claimed in the database first, so running it
+// from several instances sends each reminder once.
+type DunningService struct {
+	dunningRepo    domain.DunningRepository
+	invoiceRepo    domain.InvoiceRepository
+	auditRepo      domain.AuditLogRepository
+	eventPublisher domain.EventPublisher
+}
+
+func NewDunningService(dunningRepo domain.DunningRepository, invoiceRepo domain.InvoiceRepository, auditRepo domain.AuditLogRepository, eventPublisher domain.EventPublisher) *DunningService {
+	return &DunningService{
+		dunningRepo:    dunningRepo,
+		invoiceRepo:    invoiceRepo,
+		auditRepo:      auditRepo,
+		eventPublisher: eventPublisher,
+	}
+}
+
+// GetPolicy returns the organization's schedule. Organizations without one
+// get the default schedule, disabled.
+func (s *DunningService) GetPolicy(ctx context.Context, orgID uuid.UUID) (*dto.DunningPolicyResponse, error) {
+	policy, err := s.dunningRepo.GetPolicy(ctx, orgID)
+	if errors.Is(err, domain.ErrDunningPolicyNotFound) {
+		policy = &domain.DunningPolicy{TimeZone: "UTC", Steps: domain.DefaultDunningSteps}
+	} else if err != nil {
+		return nil, err
+	}
+	res := mapDunningPolicyToResponse(policy)
+	return &res, nil
+}
+
+func (s *DunningService) SavePolicy(ctx context.Context, orgID uuid.UUID, req dto.SaveDunningPolicyRequest) (*dto.DunningPolicyResponse, error) {
+	policy := &domain.DunningPolicy{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		TimeZone:       req.TimeZone,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+		Steps:          append(domain.DunningSteps(nil), domain.DefaultDunningSteps...),
+	}
+	if len(req.Steps) > 0 {
+		policy.Steps = make(domain.DunningSteps, 0, len(req.Steps))
+		for _, step := range req.Steps {
+			policy.Steps = append(policy.Steps, domain.DunningStep{DaysFromDue: step.DaysFromDue, Level: step.Level, Name: step.Name})
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.dunningRepo.SavePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	return s.GetPolicy(ctx, orgID)
+}
+
+// RunDue runs dunning for every organization at now
+func (s *DunningService) RunDue(ctx context.Context, now time.Time) (*dto.DunningRunResponse, error) {
+	policies, err := s.dunningRepo.ListPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dunning policies: %w", err)
+	}
+	byOrg := make(map[uuid.UUID]*domain.DunningPolicy, len(policies))
+	for i := range policies {
+		byOrg[policies[i].OrganizationID] = &policies[i]
+	}
+	return s.run(ctx, nil, byOrg, now)
+}
+
+// RunForOrganization runs dunning for one organization right away
+func (s *DunningService) RunForOrganization(ctx context.Context, orgID uuid.UUID) (*dto.DunningRunResponse, error) {
+	byOrg := make(map[uuid.UUID]*domain.DunningPolicy)
+	policy, err := s.dunningRepo.GetPolicy(ctx, orgID)
+	if err == nil {
+		byOrg[orgID] = policy
+	} else if !errors.Is(err, domain.ErrDunningPolicyNotFound) {
+		return nil, err
+	}
+	return s.run(ctx, &orgID, byOrg, time.Now().UTC())
+}
+
+func (s *DunningService) run(ctx context.Context, orgID *uuid.UUID, policies map[uuid.UUID]*domain.DunningPolicy, now time.Time) (*dto.DunningRunResponse, error) {
+	// Reminders before the due date need invoices that are not due yet. A
+	// day is added for time zones ahead of UTC.
+	lead := 0
+	for _, p := range policies {
+		if p.Enabled && p.Lead() > lead {
+			lead = p.Lead()
+		}
+	}
+	dueBefore := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, lead+2)
+
+	invoices, err := s.dunningRepo.ListCandidates(ctx, orgID, dueBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invoices for dunning: %w", err)
+	}
+	ids := make([]uuid.UUID, 0, len(invoices))
+	dueDates := make(map[uuid.UUID]time.Time, len(invoices))
+	for _, inv := range invoices {
+		ids = append(ids, inv.ID)
+		dueDates[inv.ID] = inv.CurrentDueDate()
+	}
+	reminders, err := s.dunningRepo.ListReminders(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reminders: %w", err)
+	}
+	// The latest step sent for each invoice's current due date
+	latest := make(map[uuid.UUID]int)
+	for _, r := range reminders {
+		if !r.DueDate.Equal(dueDates[r.InvoiceID]) {
+			continue
+		}
+		if last, ok := latest[r.InvoiceID]; !ok || r.DaysFromDue > last {
+			latest[r.InvoiceID] = r.DaysFromDue
+		}
+	}
+
+	res := &dto.DunningRunResponse{}
+	for i := range invoices {
+		inv := &invoices[i]
+		policy := policies[inv.OrganizationID]
+		days := domain.DaysFromDue(inv, now, policy.Location())
+
+		if lapsed := inv.LapsedInstallments(now, policy.Location()); len(lapsed) > 0 {
+			ids := make([]uuid.UUID, 0, len(lapsed))
+			for _, inst := range lapsed {
+				ids = append(ids, inst.ID)
+			}
+			marked, err := s.dunningRepo.MarkInstallmentsOverdue(ctx, ids)
+			if err != nil {
+				log.Printf("Dunning: failed to mark installments of invoice %s overdue: %v", inv.ID, err)
+			}
+			res.InstallmentsOverdue += int(marked)
+		}
+
+		if days > 0 && inv.Status != domain.InvoiceStatusOverdue {
+			marked, err := s.markOverdue(ctx, inv, days, now)
+			if err != nil {
+				log.Printf("Dunning: failed to mark invoice %s overdue: %v", inv.ID, err)
+			} else if marked {
+				res.MarkedOverdue++
+			}
+		}
+
+		if policy == nil || !policy.Enabled {
+			continue
+		}
+		step := policy.StepFor(days)
+		if step == nil {
+			continue
+		}
+		if last, ok := latest[inv.ID]; ok && last >= step.DaysFromDue {
+			continue
+		}
+		sent, err := s.remind(ctx, inv, step, now)
+		if err != nil {
+			log.Printf("Dunning: failed to send %q for invoice %s: %v", step.Name, inv.ID, err)
+		} else if sent {
+			res.RemindersDue++
+		}
+	}
+	return res, nil
+}
+
+func (s *DunningService) markOverdue(ctx context.Context, inv *domain.Invoice, days int, now time.Time) (bool, error) {
+	oldStatus := inv.Status
+	if err := inv.TransitionTo(domain.InvoiceStatusOverdue, domain.TriggerSystem, now); err != nil {
+		return false, err
+	}
+	// A payment may have settled the invoice since it was listed
+	marked, err := s.dunningRepo.MarkOverdue(ctx, inv.ID, oldStatus)
+	if err != nil || !marked {
+		inv.Status = oldStatus
+		return false, err
+	}
+
+	notes := fmt.Sprintf("Due date %s passed", inv.CurrentDueDate().Format("2006-01-02"))
+	recordStatusChange(ctx, s.auditRepo, s.eventPublisher, inv, oldStatus, notes, dunningActor)
+
+	payload := domain.InvoiceOverduePayload{
+		InvoiceID:      inv.ID.String(),
+		OrganizationID: inv.OrganizationID.String(),
+		CustomerID:     inv.CustomerID.String(),
+		InvoiceNumber:  inv.InvoiceNumber,
+		DueDate:        inv.CurrentDueDate(),
+		BalanceAmount:  inv.BalanceAmount.Float64(),
+		Currency:       inv.CurrencyCode(),
+		DaysOverdue:    days,
+		OverdueAt:      now,
+	}
+	metadata := shared_events.NewEventMetadata(domain.EventInvoiceOverdue, shared_events.AggregateInvoice, inv.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+	return true, nil
+}
+
+// remind claims the step for the invoice and announces it. It returns false
+// without error when another run claimed the step first.
+func (s *DunningService) remind(ctx context.Context, inv *domain.Invoice, step *domain.DunningStep, now time.Time) (bool, error) {
+	reminder := &domain.DunningReminder{
+		ID:             uuid.New(),
+		OrganizationID: inv.OrganizationID,
+		InvoiceID:      inv.ID,
+		DueDate:        inv.CurrentDueDate(),
+		DaysFromDue:    step.DaysFromDue,
+		Level:          step.Level,
+		Name:           step.Name,
+		BalanceAmount:  inv.BalanceAmount,
+		Currency:       inv.CurrencyCode(),
+		CreatedAt:      now,
+	}
+	claimed, err := s.dunningRepo.ClaimReminder(ctx, reminder)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: inv.OrganizationID,
+		InvoiceID:      inv.ID,
+		Action:         "dunning_reminder",
+		OldStatus:      string(inv.Status),
+		NewStatus:      string(inv.Status),
+		Notes: fmt.Sprintf("Level %d reminder %q for %s %s, %s", step.Level, step.Name,
+			inv.BalanceAmount.StringFixed(inv.CurrencyCode()), inv.CurrencyCode(), describeDaysFromDue(step.DaysFromDue)),
+		PerformedBy: dunningActor,
+		CreatedAt:   now,
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		log.Printf("Failed to audit reminder %s of invoice %s: %v", reminder.ID, inv.ID, err)
+	}
+
+	payload := domain.ReminderDuePayload{
+		ReminderID:     reminder.ID.String(),
+		InvoiceID:      inv.ID.String(),
+		OrganizationID: inv.OrganizationID.String(),
+		CustomerID:     inv.CustomerID.String(),
+		InvoiceNumber:  inv.InvoiceNumber,
+		DueDate:        inv.CurrentDueDate(),
+		DaysFromDue:    step.DaysFromDue,
+		Level:          step.Level,
+		StepName:       step.Name,
+		BalanceAmount:  inv.BalanceAmount.Float64(),
+		Currency:       inv.CurrencyCode(),
+		RemindedAt:     now,
+	}
+	if inv.ContactID != nil {
+		payload.ContactID = inv.ContactID.String()
+	}
+	metadata := shared_events.NewEventMetadata(domain.EventReminderDue, shared_events.AggregateInvoice, inv.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+	return true, nil
+}
+
+func describeDaysFromDue(days int) string {
+	switch {
+	case days < 0:
+		return fmt.Sprintf("%d days before the due date", -days)
+	case days == 0:
+		return "on the due date"
+	default:
+		return fmt.Sprintf("%d days after the due date", days)
+	}
+}
+
+// ListReminders returns the reminders sent for an invoice
+func (s *DunningService) ListReminders(ctx context.Context, orgID, invoiceID uuid.UUID) ([]dto.DunningReminderResponse, error) {
+	inv, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if inv.OrganizationID != orgID {
+		return nil, domain.ErrInvoiceNotFound
+	}
+	reminders, err := s.dunningRepo.ListReminders(ctx, []uuid.UUID{invoiceID})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.DunningReminderResponse, 0, len(reminders))
+	for _, r := range reminders {
+		res = append(res, dto.DunningReminderResponse{
+			ID:            r.ID,
+			InvoiceID:     r.InvoiceID,
+			DueDate:       r.DueDate,
+			DaysFromDue:   r.DaysFromDue,
+			Level:         r.Level,
+			Name:          r.Name,
+			BalanceAmount: r.BalanceAmount,
+			Currency:      r.Currency,
+			CreatedAt:     r.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func mapDunningPolicyToResponse(p *domain.DunningPolicy) dto.DunningPolicyResponse {
+	res := dto.DunningPolicyResponse{
+		TimeZone: p.TimeZone,
+		Enabled:  p.Enabled,
+		Steps:    make([]dto.DunningStepRequest, 0, len(p.Steps)),
+	}
+	if !p.UpdatedAt.IsZero() {
+		res.UpdatedAt = &p.UpdatedAt
+	}
+	for _, step := range p.Steps {
+		res.Steps = append(res.Steps, dto.DunningStepRequest{DaysFromDue: step.DaysFromDue, Level: step.Level, Name: step.Name})
+	}
+	return res
+}
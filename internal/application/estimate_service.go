@@ -0,0 +1,479 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// EstimateService manages quotes sent to customers before the work is done.
+// Estimates are priced by the invoice calculation, so an estimate converted
+// into an invoice comes out at the same amounts.
+type EstimateService struct {
+	estimateRepo   domain.EstimateRepository
+	invoiceService *InvoiceService
+	auditRepo      domain.AuditLogRepository
+	eventPublisher domain.EventPublisher
+}
+
+func NewEstimateService(
+	estimateRepo domain.EstimateRepository,
+	invoiceService *InvoiceService,
+	auditRepo domain.AuditLogRepository,
+	eventPublisher domain.EventPublisher,
+) *EstimateService {
+	return &EstimateService{
+		estimateRepo:   estimateRepo,
+		invoiceService: invoiceService,
+		auditRepo:      auditRepo,
+		eventPublisher: eventPublisher,
+	}
+}
+
+func (s *EstimateService) CreateEstimate(ctx context.Context, orgID uuid.UUID, req dto.CreateEstimateRequest) (*dto.EstimateResponse, error) {
+	estimate := &domain.Estimate{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Status:         domain.EstimateStatusDraft,
+	}
+	applyEstimateRequest(estimate, req)
+	if err := s.price(ctx, estimate, req.Items); err != nil {
+		return nil, err
+	}
+
+	// The estimate number is allocated in the same transaction as the insert
+	if err := s.estimateRepo.Create(ctx, estimate); err != nil {
+		return nil, err
+	}
+	return s.mapToResponse(ctx, estimate, time.Now().UTC()), nil
+}
+
+// UpdateEstimate revises a draft estimate. A sent estimate is taken back to
+// draft through its status first.
+func (s *EstimateService) UpdateEstimate(ctx context.Context, orgID, id uuid.UUID, req dto.CreateEstimateRequest) (*dto.EstimateResponse, error) {
+	estimate, err := s.getEstimate(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !estimate.IsEditable() {
+		return nil, fmt.Errorf("%w: %s is %s", domain.ErrEstimateLocked, estimate.EstimateNumber, estimate.Status)
+	}
+
+	applyEstimateRequest(estimate, req)
+	if err := s.price(ctx, estimate, req.Items); err != nil {
+		return nil, err
+	}
+	if err := s.estimateRepo.Update(ctx, estimate); err != nil {
+		return nil, err
+	}
+	return s.mapToResponse(ctx, estimate, time.Now().UTC()), nil
+}
+
+func (s *EstimateService) GetEstimate(ctx context.Context, orgID, id uuid.UUID) (*dto.EstimateResponse, error) {
+	estimate, err := s.getEstimate(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.mapToResponse(ctx, estimate, time.Now().UTC()), nil
+}
+
+func (s *EstimateService) ListEstimates(ctx context.Context, orgID uuid.UUID) ([]dto.EstimateResponse, error) {
+	estimates, err := s.estimateRepo.List(ctx, map[string]interface{}{"organization_id": orgID})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	res := make([]dto.EstimateResponse, 0, len(estimates))
+	for i := range estimates {
+		res = append(res, *s.mapToResponse(ctx, &estimates[i], now))
+	}
+	return res, nil
+}
+
+// UpdateStatus records that the estimate was sent, or the customer's answer
+// to it
+func (s *EstimateService) UpdateStatus(ctx context.Context, orgID, id uuid.UUID, status domain.EstimateStatus, performedBy string) (*dto.EstimateResponse, error) {
+	estimate, err := s.getEstimate(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	oldStatus := estimate.Status
+	if err := estimate.TransitionTo(status, now); err != nil {
+		return nil, err
+	}
+	if estimate.Status != oldStatus {
+		if err := s.estimateRepo.UpdateStatus(ctx, estimate); err != nil {
+			return nil, err
+		}
+		s.publishStatusChanged(estimate, oldStatus, performedBy, now)
+	}
+	return s.mapToResponse(ctx, estimate, now), nil
+}
+
+// ConvertToInvoice creates a draft invoice from a sent or accepted estimate,
+// with its customer, addresses, lines and discounts. The estimate is marked
+// accepted and linked to the invoice, which links back to it. An estimate
+// can only be converted once.
+func (s *EstimateService) ConvertToInvoice(ctx context.Context, orgID, id uuid.UUID, req dto.ConvertEstimateRequest, performedBy string) (*dto.InvoiceResponse, error) {
+	estimate, err := s.getEstimate(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if estimate.IsConverted() {
+		return nil, fmt.Errorf("%w: %s", domain.ErrEstimateConverted, estimate.EstimateNumber)
+	}
+	if estimate.BilledAmount.IsPositive() {
+		return nil, fmt.Errorf("%w: %s is billed through progress invoices", domain.ErrEstimateConverted, estimate.EstimateNumber)
+	}
+	now := time.Now().UTC()
+	oldStatus := estimate.Status
+	if status := estimate.CurrentStatus(now); status != domain.EstimateStatusSent && status != domain.EstimateStatusAccepted {
+		return nil, fmt.Errorf("%w: only sent or accepted estimates can be converted, %s is %s", domain.ErrInvalidEstimateTransition, estimate.EstimateNumber, status)
+	}
+
+	// Reserve the estimate before creating the invoice so that concurrent
+	// requests cannot both convert it
+	reserved, err := s.estimateRepo.ReserveConversion(ctx, estimate.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		return nil, fmt.Errorf("%w: %s", domain.ErrEstimateConverted, estimate.EstimateNumber)
+	}
+
+	invoice, err := s.invoiceService.createInvoice(ctx, estimate.OrganizationID, invoiceRequestFromEstimate(estimate, req, now), invoiceSource{EstimateID: &estimate.ID})
+	if err != nil {
+		if releaseErr := s.estimateRepo.ReleaseConversion(ctx, estimate.ID, oldStatus); releaseErr != nil {
+			fmt.Printf("failed to release estimate %s: %v\n", estimate.ID, releaseErr)
+		}
+		return nil, err
+	}
+
+	if err := s.estimateRepo.LinkInvoice(ctx, estimate.ID, invoice.ID); err != nil {
+		// The invoice already carries the estimate, so this is only logged
+		fmt.Printf("failed to link estimate %s to invoice %s: %v\n", estimate.ID, invoice.ID, err)
+	}
+	estimate.Status = domain.EstimateStatusAccepted
+	estimate.InvoiceID = &invoice.ID
+	estimate.ConvertedAt = &now
+
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: invoice.OrganizationID,
+		InvoiceID:      invoice.ID,
+		Action:         "converted_from_estimate",
+		NewStatus:      string(invoice.Status),
+		Notes:          fmt.Sprintf("Converted from estimate %s", estimate.EstimateNumber),
+		PerformedBy:    performedBy,
+		CreatedAt:      now,
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		fmt.Printf("failed to create audit log: %v\n", err)
+	}
+
+	if oldStatus != domain.EstimateStatusAccepted {
+		s.publishStatusChanged(estimate, oldStatus, performedBy, now)
+	}
+	s.publishConverted(estimate, invoice)
+
+	return s.invoiceService.mapToResponse(ctx, invoice), nil
+}
+
+func (s *EstimateService) getEstimate(ctx context.Context, orgID, id uuid.UUID) (*domain.Estimate, error) {
+	estimate, err := s.estimateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if estimate.OrganizationID != orgID {
+		return nil, domain.ErrEstimateNotFound
+	}
+	return estimate, nil
+}
+
+// price runs the estimate lines through the invoice calculation and copies
+// the priced lines, tax breakdown and totals back onto the estimate
+func (s *EstimateService) price(ctx context.Context, estimate *domain.Estimate, reqItems []dto.CreateInvoiceItem) error {
+	if len(reqItems) == 0 {
+		return fmt.Errorf("%w: an estimate needs at least one line", domain.ErrInvalidInput)
+	}
+	priced := &domain.Invoice{
+		ID:             estimate.ID,
+		OrganizationID: estimate.OrganizationID,
+		Currency:       estimate.Currency,
+		Discount:       estimate.Discount,
+		DiscountPct:    estimate.DiscountPct,
+		TaxInclusive:   estimate.TaxInclusive,
+		Adjustment:     estimate.Adjustment,
+	}
+	if err := s.invoiceService.applyItems(ctx, priced, reqItems); err != nil {
+		return err
+	}
+
+	estimate.Items = make([]domain.EstimateItem, 0, len(priced.Items))
+	for _, item := range priced.Items {
+		estimate.Items = append(estimate.Items, domain.EstimateItem{
+			ID:          item.ID,
+			EstimateID:  estimate.ID,
+			ItemID:      item.ItemID,
+			ItemType:    item.ItemType,
+			Name:        item.Name,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Discount:    item.Discount,
+			DiscountPct: item.DiscountPct,
+			DocDiscount: item.DocDiscount,
+			TaxCode:     item.TaxCode,
+			Tax:         item.Tax,
+			Total:       item.Total,
+		})
+	}
+	estimate.Taxes = make([]domain.EstimateTax, 0, len(priced.Taxes))
+	for _, t := range priced.Taxes {
+		estimate.Taxes = append(estimate.Taxes, domain.EstimateTax{
+			ID:            t.ID,
+			EstimateID:    estimate.ID,
+			TaxRateID:     t.TaxRateID,
+			Code:          t.Code,
+			Name:          t.Name,
+			Rate:          t.Rate,
+			Compound:      t.Compound,
+			TaxableAmount: t.TaxableAmount,
+			TaxAmount:     t.TaxAmount,
+		})
+	}
+	estimate.Discount = priced.Discount
+	estimate.Adjustment = priced.Adjustment
+	estimate.SubTotal = priced.SubTotal
+	estimate.DiscountTotal = priced.DiscountTotal
+	estimate.TaxTotal = priced.TaxTotal
+	estimate.TotalAmount = priced.TotalAmount
+	return nil
+}
+
+// invoiceRequestFromEstimate gives the invoice the estimate's lines and
+// discounts as they were entered. Lines with a tax code are taxed again at
+// the rates in force when the invoice is created.
+func invoiceRequestFromEstimate(estimate *domain.Estimate, req dto.ConvertEstimateRequest, now time.Time) dto.CreateInvoiceRequest {
+	invoiceDate := now
+	if req.InvoiceDate != nil {
+		invoiceDate = *req.InvoiceDate
+	}
+	var dueDate time.Time
+	if req.DueDate != nil {
+		dueDate = *req.DueDate
+	}
+
+	items := make([]dto.CreateInvoiceItem, 0, len(estimate.Items))
+	for _, item := range estimate.Items {
+		line := dto.CreateInvoiceItem{
+			ItemID:      item.ItemID,
+			ItemType:    item.ItemType,
+			Name:        item.Name,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			DiscountPct: item.DiscountPct,
+			TaxCode:     item.TaxCode,
+		}
+		if item.DiscountPct.IsZero() {
+			// Discount also holds the line's share of the estimate discount
+			line.Discount = item.Discount.Sub(item.DocDiscount)
+		}
+		if item.TaxCode == "" {
+			line.Tax = item.Tax
+		}
+		items = append(items, line)
+	}
+
+	invReq := dto.CreateInvoiceRequest{
+		Subject:         estimate.Subject,
+		CustomerID:      estimate.CustomerID,
+		ContactID:       estimate.ContactID,
+		OwnerID:         estimate.OwnerID,
+		InvoiceDate:     invoiceDate,
+		DueDate:         dueDate,
+		PaymentTermID:   req.PaymentTermID,
+		ReferenceNo:     estimate.EstimateNumber,
+		SalesOrder:      req.SalesOrder,
+		PurchaseOrder:   req.PurchaseOrder,
+		Currency:        estimate.Currency,
+		Adjustment:      estimate.Adjustment,
+		DiscountPct:     estimate.DiscountPct,
+		TaxInclusive:    estimate.TaxInclusive,
+		Terms:           estimate.Terms,
+		Notes:           estimate.Notes,
+		BillingStreet:   estimate.BillingStreet,
+		BillingCity:     estimate.BillingCity,
+		BillingState:    estimate.BillingState,
+		BillingCode:     estimate.BillingCode,
+		BillingCountry:  estimate.BillingCountry,
+		ShippingStreet:  estimate.ShippingStreet,
+		ShippingCity:    estimate.ShippingCity,
+		ShippingState:   estimate.ShippingState,
+		ShippingCode:    estimate.ShippingCode,
+		ShippingCountry: estimate.ShippingCountry,
+		Items:           items,
+	}
+	if estimate.DiscountPct.IsZero() {
+		invReq.Discount = estimate.Discount
+	}
+	return invReq
+}
+
+func applyEstimateRequest(estimate *domain.Estimate, req dto.CreateEstimateRequest) {
+	estimate.Subject = req.Subject
+	estimate.CustomerID = req.CustomerID
+	estimate.ContactID = req.ContactID
+	estimate.OwnerID = req.OwnerID
+	estimate.EstimateDate = req.EstimateDate
+	if estimate.EstimateDate.IsZero() {
+		estimate.EstimateDate = time.Now().UTC()
+	}
+	estimate.ExpiryDate = req.ExpiryDate
+	estimate.ReferenceNo = req.ReferenceNo
+	estimate.Currency = req.Currency
+	estimate.Adjustment = req.Adjustment
+	estimate.Discount = req.Discount
+	estimate.DiscountPct = req.DiscountPct
+	estimate.TaxInclusive = req.TaxInclusive
+	estimate.Terms = req.Terms
+	estimate.Notes = req.Notes
+	estimate.BillingStreet = req.BillingStreet
+	estimate.BillingCity = req.BillingCity
+	estimate.BillingState = req.BillingState
+	estimate.BillingCode = req.BillingCode
+	estimate.BillingCountry = req.BillingCountry
+	estimate.ShippingStreet = req.ShippingStreet
+	estimate.ShippingCity = req.ShippingCity
+	estimate.ShippingState = req.ShippingState
+	estimate.ShippingCode = req.ShippingCode
+	estimate.ShippingCountry = req.ShippingCountry
+}
+
+func (s *EstimateService) publishStatusChanged(estimate *domain.Estimate, oldStatus domain.EstimateStatus, performedBy string, now time.Time) {
+	payload := domain.EstimateStatusChangedPayload{
+		EstimateID:     estimate.ID.String(),
+		OrganizationID: estimate.OrganizationID.String(),
+		CustomerID:     estimate.CustomerID.String(),
+		EstimateNumber: estimate.EstimateNumber,
+		OldStatus:      string(oldStatus),
+		NewStatus:      string(estimate.Status),
+		TotalAmount:    estimate.TotalAmount.Float64(),
+		Currency:       estimate.Currency,
+		PerformedBy:    performedBy,
+		ChangedAt:      now,
+	}
+
+	metadata := shared_events.NewEventMetadata(domain.EventEstimateStatusChanged, domain.AggregateEstimate, estimate.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+func (s *EstimateService) publishConverted(estimate *domain.Estimate, inv *domain.Invoice) {
+	payload := domain.EstimateConvertedPayload{
+		EstimateID:     estimate.ID.String(),
+		OrganizationID: estimate.OrganizationID.String(),
+		CustomerID:     estimate.CustomerID.String(),
+		EstimateNumber: estimate.EstimateNumber,
+		InvoiceID:      inv.ID.String(),
+		InvoiceNumber:  inv.InvoiceNumber,
+		TotalAmount:    inv.TotalAmount.Float64(),
+		Currency:       inv.CurrencyCode(),
+		ConvertedAt:    *estimate.ConvertedAt,
+	}
+
+	metadata := shared_events.NewEventMetadata(domain.EventEstimateConverted, domain.AggregateEstimate, estimate.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+// mapToResponse reports the status the estimate has on the given day, so a
+// sent estimate past its expiry date shows as expired before that is stored
+func (s *EstimateService) mapToResponse(ctx context.Context, e *domain.Estimate, now time.Time) *dto.EstimateResponse {
+	res := &dto.EstimateResponse{
+		ID:              e.ID,
+		EstimateNumber:  e.EstimateNumber,
+		Subject:         e.Subject,
+		Status:          string(e.CurrentStatus(now)),
+		CustomerID:      e.CustomerID,
+		ContactID:       e.ContactID,
+		OwnerID:         e.OwnerID,
+		ReferenceNo:     e.ReferenceNo,
+		EstimateDate:    e.EstimateDate,
+		ExpiryDate:      e.ExpiryDate,
+		SubTotal:        e.SubTotal,
+		Discount:        e.Discount,
+		DiscountPct:     e.DiscountPct,
+		DiscountTotal:   e.DiscountTotal,
+		TaxTotal:        e.TaxTotal,
+		TaxInclusive:    e.TaxInclusive,
+		Adjustment:      e.Adjustment,
+		TotalAmount:     e.TotalAmount,
+		Currency:        e.Currency,
+		Terms:           e.Terms,
+		Notes:           e.Notes,
+		BillingStreet:   e.BillingStreet,
+		BillingCity:     e.BillingCity,
+		BillingState:    e.BillingState,
+		BillingCode:     e.BillingCode,
+		BillingCountry:  e.BillingCountry,
+		ShippingStreet:  e.ShippingStreet,
+		ShippingCity:    e.ShippingCity,
+		ShippingState:   e.ShippingState,
+		ShippingCode:    e.ShippingCode,
+		ShippingCountry: e.ShippingCountry,
+		InvoiceID:       e.InvoiceID,
+		ConvertedAt:     e.ConvertedAt,
+		BilledAmount:    e.BilledAmount,
+		CreatedAt:       e.CreatedAt,
+		UpdatedAt:       e.UpdatedAt,
+	}
+
+	if customer, err := s.invoiceService.rmRepo.GetCustomer(ctx, e.CustomerID); err == nil && customer != nil {
+		res.Customer = &dto.CustomerResponse{
+			ID:          customer.ID,
+			DisplayName: customer.DisplayName,
+			CompanyName: customer.CompanyName,
+		}
+	}
+
+	if len(e.Items) > 0 {
+		res.Items = make([]dto.ItemResponse, 0, len(e.Items))
+		for _, item := range e.Items {
+			res.Items = append(res.Items, dto.ItemResponse{
+				ItemID:      item.ItemID,
+				ItemType:    item.ItemType,
+				Name:        item.Name,
+				Description: item.Description,
+				Quantity:    item.Quantity,
+				UnitPrice:   item.UnitPrice,
+				Discount:    item.Discount,
+				DiscountPct: item.DiscountPct,
+				DocDiscount: item.DocDiscount,
+				TaxCode:     item.TaxCode,
+				Tax:         item.Tax,
+				Total:       item.Total,
+			})
+		}
+	}
+
+	for _, t := range e.Taxes {
+		res.Taxes = append(res.Taxes, dto.TaxResponse{
+			TaxRateID:     t.TaxRateID,
+			Code:          t.Code,
+			Name:          t.Name,
+			Rate:          t.Rate,
+			Compound:      t.Compound,
+			TaxableAmount: t.TaxableAmount,
+			TaxAmount:     t.TaxAmount,
+		})
+	}
+
+	return res
+}
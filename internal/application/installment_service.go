@@ -0,0 +1,163 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// SetInstallments gives the invoice a payment schedule, replacing any it
+// had. What has been paid on the invoice is spread over the new
+// installments in order.
+func (s *InvoiceService) SetInstallments(ctx context.Context, orgID, invoiceID uuid.UUID, req dto.SetInstallmentsRequest, performedBy string) (*dto.InvoiceResponse, error) {
+	now := time.Now().UTC()
+	var oldStatus domain.InvoiceStatus
+	invoice, err := s.invoiceRepo.ReplaceInstallments(ctx, invoiceID, func(inv *domain.Invoice) error {
+		if inv.OrganizationID != orgID {
+			return domain.ErrInvoiceNotFound
+		}
+		oldStatus = inv.Status
+		installments, err := buildInstallments(inv, req)
+		if err != nil {
+			return err
+		}
+		if err := inv.SetInstallments(installments); err != nil {
+			return err
+		}
+		// An invoice overdue on an installment that has been moved is due again
+		if status := inv.SettlementStatus(); status != inv.Status {
+			return inv.TransitionTo(status, domain.TriggerSystem, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	last := invoice.Installments[len(invoice.Installments)-1]
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		InvoiceID:      invoice.ID,
+		Action:         "installments_set",
+		OldStatus:      string(oldStatus),
+		NewStatus:      string(invoice.Status),
+		Notes: fmt.Sprintf("Payment schedule of %d installments from %s to %s", len(invoice.Installments),
+			invoice.Installments[0].DueDate.Format("2006-01-02"), last.DueDate.Format("2006-01-02")),
+		PerformedBy: performedBy,
+		CreatedAt:   now,
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		fmt.Printf("failed to create audit log: %v\n", err)
+	}
+	recordStatusChange(ctx, s.auditRepo, s.eventPublisher, invoice, oldStatus, "payment schedule changed", performedBy)
+
+	return s.GetInvoice(ctx, invoice.ID)
+}
+
+// buildInstallments takes the installments listed in the request, or
+// generates them from its count and frequency
+func buildInstallments(inv *domain.Invoice, req dto.SetInstallmentsRequest) ([]domain.Installment, error) {
+	if len(req.Installments) > 0 {
+		if req.Count != 0 {
+			return nil, fmt.Errorf("%w: give either installments or a count", domain.ErrInvalidInput)
+		}
+		installments := make([]domain.Installment, 0, len(req.Installments))
+		for _, item := range req.Installments {
+			installments = append(installments, domain.Installment{ID: uuid.New(), DueDate: item.DueDate, Amount: item.Amount})
+		}
+		return installments, nil
+	}
+
+	frequency := domain.RecurringMonthly
+	if req.Frequency != "" {
+		frequency = domain.RecurringFrequency(strings.ToLower(req.Frequency))
+	}
+	first := inv.DueDate
+	if inv.HasSchedule() {
+		first = inv.Installments[0].DueDate
+	}
+	if req.FirstDueDate != nil {
+		first = *req.FirstDueDate
+	}
+	return domain.GenerateInstallments(inv.TotalAmount, inv.CurrencyCode(), req.Count, first, frequency)
+}
+
+// AgingReport ages the organization's open invoices in a currency as of a
+// day, by customer. Invoices paid in installments are aged by the due date
+// of each installment.
+func (s *InvoiceService) AgingReport(ctx context.Context, orgID uuid.UUID, currency string, asOf time.Time) (*dto.AgingReportResponse, error) {
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	invoices, err := s.invoiceRepo.ListOpenForOrganization(ctx, orgID, strings.ToUpper(currency))
+	if err != nil {
+		return nil, err
+	}
+
+	var total domain.AgingBalance
+	byCustomer := make(map[uuid.UUID]*domain.AgingBalance)
+	for i := range invoices {
+		balance, ok := byCustomer[invoices[i].CustomerID]
+		if !ok {
+			balance = &domain.AgingBalance{}
+			byCustomer[invoices[i].CustomerID] = balance
+		}
+		var aged domain.AgingBalance
+		aged.Age(&invoices[i], asOf)
+		balance.Add(aged)
+		total.Add(aged)
+	}
+
+	res := &dto.AgingReportResponse{
+		AsOf:      asOf,
+		Currency:  strings.ToUpper(currency),
+		Customers: make([]dto.CustomerAgingResponse, 0, len(byCustomer)),
+		Total:     mapAgingBalance(total),
+	}
+	for customerID, balance := range byCustomer {
+		row := dto.CustomerAgingResponse{CustomerID: customerID, AgingBalanceResponse: mapAgingBalance(*balance)}
+		if customer, err := s.rmRepo.GetCustomer(ctx, customerID); err == nil && customer != nil {
+			row.CustomerName = customer.DisplayName
+		}
+		res.Customers = append(res.Customers, row)
+	}
+	sort.Slice(res.Customers, func(i, j int) bool {
+		if res.Customers[i].CustomerName != res.Customers[j].CustomerName {
+			return res.Customers[i].CustomerName < res.Customers[j].CustomerName
+		}
+		return res.Customers[i].CustomerID.String() < res.Customers[j].CustomerID.String()
+	})
+	return res, nil
+}
+
+func mapAgingBalance(b domain.AgingBalance) dto.AgingBalanceResponse {
+	return dto.AgingBalanceResponse{
+		Current:    b.Current,
+		Days1To30:  b.Days1To30,
+		Days31To60: b.Days31To60,
+		Days61To90: b.Days61To90,
+		Over90:     b.Over90,
+		Total:      b.Total(),
+	}
+}
+
+func mapInstallmentToResponse(inst *domain.Installment) dto.InstallmentResponse {
+	return dto.InstallmentResponse{
+		ID:          inst.ID,
+		Sequence:    inst.Sequence,
+		DueDate:     inst.DueDate,
+		Amount:      inst.Amount,
+		PaidAmount:  inst.PaidAmount,
+		Outstanding: inst.Outstanding(),
+		Status:      string(inst.Status),
+	}
+}
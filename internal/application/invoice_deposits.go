@@ -0,0 +1,75 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// applyDeposits pays an invoice that has just been issued out of the
+// deposits the customer holds for the job it bills, and out of their
+// retainers. It runs however the invoice leaves draft: sent by hand or by a
+// recurring profile, or paid while still a draft. Deposit invoices, and
+// progress invoices other than the final one, are left alone. The credit
+// payment and the deposits it uses up are stored in one transaction;
+// failures are only logged, as the deposit stays in the customer's credit
+// and can still be applied by hand.
+func (s *InvoiceService) applyDeposits(ctx context.Context, invoice *domain.Invoice, performedBy string) {
+	switch invoice.Kind {
+	case domain.InvoiceKindDeposit:
+		return
+	case domain.InvoiceKindProgress:
+		bill, err := s.progressRepo.GetByInvoice(ctx, invoice.ID)
+		if err != nil || !bill.Final {
+			return
+		}
+	}
+
+	deposits, err := s.progressRepo.ListHeldDeposits(ctx, invoice.OrganizationID, invoice.CustomerID, invoice.CurrencyCode())
+	if err != nil {
+		fmt.Printf("failed to list deposits for invoice %s: %v\n", invoice.InvoiceNumber, err)
+		return
+	}
+	jobs := s.invoiceJobs(ctx, invoice)
+	var matching []domain.ProgressBill
+	for _, d := range deposits {
+		if d.Matches(jobs...) {
+			matching = append(matching, d)
+		}
+	}
+	taken, total := domain.AllocateDeposits(matching, invoice.AmountDue())
+	if !total.IsPositive() {
+		return
+	}
+
+	req := dto.RecordPaymentRequest{
+		Amount:         total,
+		PaymentMethod:  domain.PaymentMethodCredit,
+		IdempotencyKey: "deposits:" + invoice.ID.String(),
+		Notes:          "Applied from deposits",
+	}
+	if _, err := s.payments.recordPayment(ctx, invoice.OrganizationID, invoice.ID, req, &invoice.CustomerID, taken, performedBy); err != nil {
+		fmt.Printf("failed to apply deposits to invoice %s: %v\n", invoice.InvoiceNumber, err)
+	}
+}
+
+// invoiceJobs returns the work order and estimates an invoice bills, so the
+// deposits taken for any of them apply to it
+func (s *InvoiceService) invoiceJobs(ctx context.Context, invoice *domain.Invoice) []uuid.UUID {
+	var jobs []uuid.UUID
+	if invoice.EstimateID != nil {
+		jobs = append(jobs, *invoice.EstimateID)
+	}
+	if invoice.WorkOrderID != nil {
+		jobs = append(jobs, *invoice.WorkOrderID)
+		// A deposit taken on the estimate carries over to the work order it became
+		if wo, err := s.rmRepo.GetWorkOrder(ctx, *invoice.WorkOrderID); err == nil && wo.EstimateID != nil {
+			jobs = append(jobs, *wo.EstimateID)
+		}
+	}
+	return jobs
+}
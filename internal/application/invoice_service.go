@@ -2,11 +2,13 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"erp-billing-service/internal/application/dto"
 	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
 
 	shared_events "github.com/efs/shared-events"
 	"github.com/google/uuid"
@@ -15,6 +17,11 @@ import (
 type InvoiceService struct {
 	invoiceRepo    domain.InvoiceRepository
 	rmRepo         domain.ReadModelRepository
+	taxRepo        domain.TaxRepository
+	termRepo       domain.PaymentTermRepository
+	progressRepo   domain.ProgressBillingRepository
+	consolidations domain.ConsolidationRepository
+	payments       *PaymentService // Applies held deposits when an invoice is issued
 	auditRepo      domain.AuditLogRepository
 	eventPublisher domain.EventPublisher
 }
@@ -22,25 +29,67 @@ type InvoiceService struct {
 func NewInvoiceService(
 	invoiceRepo domain.InvoiceRepository,
 	rmRepo domain.ReadModelRepository,
+	taxRepo domain.TaxRepository,
+	termRepo domain.PaymentTermRepository,
+	progressRepo domain.ProgressBillingRepository,
+	consolidations domain.ConsolidationRepository,
+	payments *PaymentService,
 	auditRepo domain.AuditLogRepository,
 	eventPublisher domain.EventPublisher,
 ) *InvoiceService {
-	return &InvoiceService{
+	s := &InvoiceService{
 		invoiceRepo:    invoiceRepo,
 		rmRepo:         rmRepo,
+		taxRepo:        taxRepo,
+		termRepo:       termRepo,
+		progressRepo:   progressRepo,
+		consolidations: consolidations,
+		payments:       payments,
 		auditRepo:      auditRepo,
 		eventPublisher: eventPublisher,
 	}
+	// A draft paid before it was sent is issued by the payment, so its
+	// deposits are applied then
+	if payments != nil {
+		payments.issued = s.applyDeposits
+	}
+	return s
 }
 
 func (s *InvoiceService) CreateInvoice(ctx context.Context, orgID uuid.UUID, req dto.CreateInvoiceRequest) (*dto.InvoiceResponse, error) {
-	// 1. Generate Invoice Number
-	invNum, err := s.invoiceRepo.GetNextInvoiceNumber(ctx, orgID)
+	invoice, err := s.createInvoice(ctx, orgID, req, invoiceSource{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate invoice number: %w", err)
+		return nil, err
 	}
+	return s.mapToResponse(ctx, invoice), nil
+}
 
-	invoiceID := uuid.New()
+// invoiceSource is the document an invoice is created from, if any, and
+// what kind of invoice it is; standard when Kind is left empty.
+// DefaultDueDate is the due date when neither the request nor a payment
+// term gives one; without it such an invoice is due on receipt. RunID is the
+// recurring or subscription run generating the invoice; a run can only ever
+// store one.
+type invoiceSource struct {
+	WorkOrderID    *uuid.UUID
+	EstimateID     *uuid.UUID
+	Kind           domain.InvoiceKind
+	DefaultDueDate time.Time
+	RunID          *uuid.UUID
+	ID             uuid.UUID // Set when the caller must find the invoice again after a crash
+}
+
+// createInvoice persists a new draft invoice and publishes its creation.
+// src links the invoice to the work order or estimate it bills.
+func (s *InvoiceService) createInvoice(ctx context.Context, orgID uuid.UUID, req dto.CreateInvoiceRequest, src invoiceSource) (*domain.Invoice, error) {
+	invoiceID := src.ID
+	if invoiceID == uuid.Nil {
+		invoiceID = uuid.New()
+	}
+	kind := src.Kind
+	if kind == "" {
+		kind = domain.InvoiceKindStandard
+	}
 	invoice := &domain.Invoice{
 		ID:             invoiceID,
 		OrganizationID: orgID,
@@ -48,14 +97,20 @@ func (s *InvoiceService) CreateInvoice(ctx context.Context, orgID uuid.UUID, req
 
 		ContactID:       req.ContactID,
 		OwnerID:         req.OwnerID,
+		WorkOrderID:     src.WorkOrderID,
+		EstimateID:      src.EstimateID,
+		RunID:           src.RunID,
+		Kind:            kind,
 		Subject:         req.Subject,
-		InvoiceNumber:   invNum,
 		ReferenceNo:     req.ReferenceNo,
 		InvoiceDate:     req.InvoiceDate,
 		DueDate:         req.DueDate,
 		Status:          domain.InvoiceStatusDraft,
 		Currency:        req.Currency,
 		Adjustment:      req.Adjustment,
+		Discount:        req.Discount,
+		DiscountPct:     req.DiscountPct,
+		TaxInclusive:    req.TaxInclusive,
 		ExciseDuty:      req.ExciseDuty,
 		SalesCommission: req.SalesCommission,
 		SalesOrder:      req.SalesOrder,
@@ -74,167 +129,283 @@ func (s *InvoiceService) CreateInvoice(ctx context.Context, orgID uuid.UUID, req
 		ShippingCountry: req.ShippingCountry,
 	}
 
-	var subTotal, discountTotal, taxTotal float64
-	items := make([]domain.InvoiceItem, 0, len(req.Items))
-
-	for _, itemReq := range req.Items {
-		// Validate item exists in Read Model
-		itemName := itemReq.Name
-		itemRM, err := s.rmRepo.GetItem(ctx, itemReq.ItemID)
-		if err != nil {
-			// Fallback: If item not found in read model (e.g. sync lag),
-			// use the name provided in the request if available.
-			if itemName == "" {
-				return nil, fmt.Errorf("item %s not found and no name provided: %w", itemReq.ItemID, err)
-			}
-			// Log warning here ideally
-		} else {
-			itemName = itemRM.Name
+	if err := s.applyPaymentTerm(ctx, invoice, req.PaymentTermID); err != nil {
+		return nil, err
+	}
+	if invoice.DueDate.IsZero() {
+		invoice.DueDate = src.DefaultDueDate
+		if invoice.DueDate.IsZero() {
+			invoice.DueDate = invoice.InvoiceDate
 		}
-
-		itemTotal := (itemReq.Quantity * itemReq.UnitPrice) - itemReq.Discount + itemReq.Tax
-
-		items = append(items, domain.InvoiceItem{
-			ID:          uuid.New(),
-			InvoiceID:   invoiceID,
-			ItemID:      itemReq.ItemID,
-			ItemType:    itemReq.ItemType, // Optional
-			Name:        itemName,
-			Description: itemReq.Description,
-			Quantity:    itemReq.Quantity,
-			UnitPrice:   itemReq.UnitPrice,
-			Discount:    itemReq.Discount,
-			Tax:         itemReq.Tax,
-			Total:       itemTotal,
-		})
-
-		subTotal += (itemReq.Quantity * itemReq.UnitPrice)
-		discountTotal += itemReq.Discount
-		taxTotal += itemReq.Tax
 	}
-
-	invoice.Items = items
-	invoice.SubTotal = subTotal
-	invoice.DiscountTotal = discountTotal
-	invoice.TaxTotal = taxTotal
-	invoice.TotalAmount = subTotal - discountTotal + taxTotal + req.Adjustment + req.ExciseDuty
+	if err := s.applyItems(ctx, invoice, req.Items); err != nil {
+		return nil, err
+	}
 	invoice.BalanceAmount = invoice.TotalAmount
 
+	// The invoice number is allocated in the same transaction as the insert
 	if err := s.invoiceRepo.Create(ctx, invoice); err != nil {
 		return nil, err
 	}
 
-	// 2. Publish Event
+	// Publish Event
 	s.publishInvoiceCreated(invoice)
 
-	return s.mapToResponse(ctx, invoice), nil
+	return invoice, nil
 }
 
 func (s *InvoiceService) UpdateInvoice(ctx context.Context, id uuid.UUID, req dto.CreateInvoiceRequest) (*dto.InvoiceResponse, error) {
-	// 1. Get Existing Invoice
-	invoice, err := s.invoiceRepo.GetByID(ctx, id)
+	// Lines are built and validated on the locked invoice before anything is
+	// written, and stored together with the header
+	invoice, err := s.invoiceRepo.ReplaceItems(ctx, id, func(invoice *domain.Invoice) error {
+		if invoice.IsLocked() {
+			return fmt.Errorf("%w: %s has been issued and can only be changed through its status", domain.ErrInvoiceLocked, invoice.InvoiceNumber)
+		}
+		if !invoice.BillsInFull() {
+			// Its amount is counted against the job it bills
+			return fmt.Errorf("%w: %s is a %s invoice, void it and bill again", domain.ErrInvoiceLocked, invoice.InvoiceNumber, invoice.Kind)
+		}
+
+		// 1. Update Fields
+		invoice.Subject = req.Subject
+		// invoice.CustomerID = req.CustomerID // Usually changing customer is restricted, or complicated. Allow for now.
+		if req.ContactID != nil {
+			invoice.ContactID = req.ContactID
+		}
+		invoice.InvoiceDate = req.InvoiceDate
+		invoice.DueDate = req.DueDate
+		invoice.Currency = req.Currency
+		invoice.Adjustment = req.Adjustment
+		invoice.Discount = req.Discount
+		invoice.DiscountPct = req.DiscountPct
+		invoice.TaxInclusive = req.TaxInclusive
+		invoice.ExciseDuty = req.ExciseDuty
+		invoice.SalesCommission = req.SalesCommission
+		invoice.SalesOrder = req.SalesOrder
+		invoice.PurchaseOrder = req.PurchaseOrder
+		invoice.Terms = req.Terms
+		if req.OwnerID != nil {
+			invoice.OwnerID = req.OwnerID
+		}
+		invoice.Notes = req.Notes
+		invoice.BillingStreet = req.BillingStreet
+		invoice.BillingCity = req.BillingCity
+		invoice.BillingState = req.BillingState
+		invoice.BillingCode = req.BillingCode
+		invoice.BillingCountry = req.BillingCountry
+		invoice.ShippingStreet = req.ShippingStreet
+		invoice.ShippingCity = req.ShippingCity
+		invoice.ShippingState = req.ShippingState
+		invoice.ShippingCode = req.ShippingCode
+		invoice.ShippingCountry = req.ShippingCountry
+		if err := s.applyPaymentTerm(ctx, invoice, req.PaymentTermID); err != nil {
+			return err
+		}
+
+		// 2. Rebuild Items
+		if err := s.applyItems(ctx, invoice, req.Items); err != nil {
+			return err
+		}
+
+		// A payment schedule is replaced on its own, and keeps the due date
+		if invoice.HasSchedule() {
+			if err := invoice.KeepSchedule(); err != nil {
+				return err
+			}
+		}
+
+		// PaidAmount is kept up to date by the payment allocations
+		invoice.RecalculateBalance()
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if invoice == nil {
-		return nil, fmt.Errorf("invoice not found")
-	}
-
-	// 2. Update Fields
-	invoice.Subject = req.Subject
-	// invoice.CustomerID = req.CustomerID // Usually changing customer is restricted, or complicated. Allow for now.
-	if req.ContactID != nil {
-		invoice.ContactID = req.ContactID
-	}
-	invoice.InvoiceDate = req.InvoiceDate
-	invoice.DueDate = req.DueDate
-	invoice.Currency = req.Currency
-	invoice.Adjustment = req.Adjustment
-	invoice.ExciseDuty = req.ExciseDuty
-	invoice.SalesCommission = req.SalesCommission
-	invoice.SalesOrder = req.SalesOrder
-	invoice.PurchaseOrder = req.PurchaseOrder
-	invoice.Terms = req.Terms
-	if req.OwnerID != nil {
-		invoice.OwnerID = req.OwnerID
-	}
-	invoice.Notes = req.Notes
-	invoice.BillingStreet = req.BillingStreet
-	invoice.BillingCity = req.BillingCity
-	invoice.BillingState = req.BillingState
-	invoice.BillingCode = req.BillingCode
-	invoice.BillingCountry = req.BillingCountry
-	invoice.ShippingStreet = req.ShippingStreet
-	invoice.ShippingCity = req.ShippingCity
-	invoice.ShippingState = req.ShippingState
-	invoice.ShippingCode = req.ShippingCode
-	invoice.ShippingCountry = req.ShippingCountry
-
-	// 3. Update Items
-	// Clear existing items
-	if err := s.invoiceRepo.ClearItems(ctx, invoice.ID); err != nil {
-		return nil, fmt.Errorf("failed to clear existing items: %w", err)
-	}
-
-	var subTotal, discountTotal, taxTotal float64
-	items := make([]domain.InvoiceItem, 0, len(req.Items))
-
-	for _, itemReq := range req.Items {
+
+	// 3. Publish Event (?) - InvoiceUpdated
+	// s.publishInvoiceUpdated(invoice)
+
+	return s.mapToResponse(ctx, invoice), nil
+}
+
+// applyPaymentTerm gives the invoice the term chosen for it, or else the
+// customer's term or the organization's default. The term sets the due date
+// unless one was given, and the early-payment discount.
+func (s *InvoiceService) applyPaymentTerm(ctx context.Context, invoice *domain.Invoice, termID *uuid.UUID) error {
+	var term *domain.PaymentTerm
+	var err error
+	if termID != nil {
+		term, err = s.termRepo.GetTerm(ctx, *termID)
+		if err == nil && term.OrganizationID != invoice.OrganizationID {
+			err = domain.ErrPaymentTermNotFound
+		}
+		if errors.Is(err, domain.ErrPaymentTermNotFound) {
+			return fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+		}
+	} else {
+		term, err = s.termRepo.GetCustomerTerm(ctx, invoice.OrganizationID, invoice.CustomerID)
+		if errors.Is(err, domain.ErrPaymentTermNotFound) {
+			term, err = s.termRepo.GetDefaultTerm(ctx, invoice.OrganizationID)
+		}
+		if errors.Is(err, domain.ErrPaymentTermNotFound) {
+			invoice.PaymentTermID, invoice.EarlyPayPct, invoice.EarlyPayBy = nil, money.Zero, nil
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve payment term: %w", err)
+	}
+	term.ApplyTo(invoice)
+	return nil
+}
+
+// applyItems builds the invoice lines from the request and recomputes the
+// invoice totals. Every line amount is rounded to the invoice currency before
+// it is summed, so the header totals always equal the sum of the stored lines.
+//
+// Line discounts are applied first. The invoice-level discount is then spread
+// over the discounted lines in proportion to their amounts, before tax, so
+// each line is taxed on what the customer actually pays for it.
+//
+// Lines with a tax code are taxed server-side with the rates behind the code;
+// lines without one keep the tax amount given in the request. On tax-inclusive
+// invoices the line total is the discounted line amount and the sub total only
+// counts the part of it that is not tax.
+func (s *InvoiceService) applyItems(ctx context.Context, invoice *domain.Invoice, reqItems []dto.CreateInvoiceItem) error {
+	var subTotal, discountTotal, taxTotal money.Amount
+	items := make([]domain.InvoiceItem, 0, len(reqItems))
+	taxes := newTaxBreakdown(invoice.ID)
+	ratesByCode := make(map[string][]domain.TaxRate)
+
+	// 1. Price every line and apply its own discount
+	var discounted money.Amount
+	weights := make([]money.Amount, 0, len(reqItems))
+	for _, itemReq := range reqItems {
+		// Validate item exists in Read Model
 		itemName := itemReq.Name
 		itemRM, err := s.rmRepo.GetItem(ctx, itemReq.ItemID)
 		if err != nil {
+			// Fallback: If item not found in read model (e.g. sync lag),
+			// use the name provided in the request if available.
 			if itemName == "" {
-				return nil, fmt.Errorf("item %s not found and no name provided: %w", itemReq.ItemID, err)
+				return fmt.Errorf("item %s not found and no name provided: %w", itemReq.ItemID, err)
 			}
+			// Log warning here ideally
 		} else {
 			itemName = itemRM.Name
 		}
 
-		itemTotal := (itemReq.Quantity * itemReq.UnitPrice) - itemReq.Discount + itemReq.Tax
+		lineAmount := invoice.Round(itemReq.UnitPrice.Mul(itemReq.Quantity))
+		discount, err := domain.ResolveDiscount(lineAmount, itemReq.Discount, itemReq.DiscountPct, invoice.Round)
+		if err != nil {
+			return fmt.Errorf("line %s: %w", itemName, err)
+		}
 
 		items = append(items, domain.InvoiceItem{
 			ID:          uuid.New(),
 			InvoiceID:   invoice.ID,
 			ItemID:      itemReq.ItemID,
-			ItemType:    itemReq.ItemType,
+			ItemType:    itemReq.ItemType, // Optional
 			Name:        itemName,
 			Description: itemReq.Description,
 			Quantity:    itemReq.Quantity,
 			UnitPrice:   itemReq.UnitPrice,
-			Discount:    itemReq.Discount,
-			Tax:         itemReq.Tax,
-			Total:       itemTotal,
+			Discount:    discount,
+			DiscountPct: itemReq.DiscountPct,
+			TaxCode:     itemReq.TaxCode,
+			GroupLabel:  itemReq.GroupLabel,
+			SourceID:    itemReq.SourceID,
 		})
+		weights = append(weights, lineAmount.Sub(discount))
+		discounted = discounted.Add(lineAmount.Sub(discount))
+	}
 
-		subTotal += (itemReq.Quantity * itemReq.UnitPrice)
-		discountTotal += itemReq.Discount
-		taxTotal += itemReq.Tax
+	// 2. Spread the invoice-level discount over the lines
+	docDiscount, err := domain.ResolveDiscount(discounted, invoice.Discount, invoice.DiscountPct, invoice.Round)
+	if err != nil {
+		return err
 	}
+	invoice.Discount = docDiscount
+	shares := docDiscount.Allocate(invoice.CurrencyCode(), weights)
+
+	// 3. Tax each line on its discounted amount
+	for i := range items {
+		item := &items[i]
+		item.DocDiscount = shares[i]
+		item.Discount = item.Discount.Add(shares[i])
+
+		lineAmount := invoice.Round(item.UnitPrice.Mul(item.Quantity))
+		taxable := lineAmount.Sub(item.Discount)
+
+		var tax money.Amount
+		if item.TaxCode != "" {
+			rates, ok := ratesByCode[item.TaxCode]
+			if !ok {
+				rates, err = s.taxRepo.ResolveCode(ctx, invoice.OrganizationID, item.TaxCode)
+				if errors.Is(err, domain.ErrTaxCodeNotFound) {
+					return fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to resolve tax code %s: %w", item.TaxCode, err)
+				}
+				ratesByCode[item.TaxCode] = rates
+			}
+			_, lineTaxes := domain.CalculateTax(taxable, rates, invoice.TaxInclusive, invoice.Round)
+			for _, t := range lineTaxes {
+				tax = tax.Add(t.Amount)
+				taxes.addRate(t)
+			}
+		} else {
+			tax = invoice.Round(reqItems[i].Tax)
+			net := taxable
+			if invoice.TaxInclusive {
+				net = taxable.Sub(tax)
+			}
+			taxes.addManual(net, tax)
+		}
+
+		item.Tax = tax
+		item.Total = taxable.Add(tax)
+		if invoice.TaxInclusive {
+			item.Total = taxable
+			lineAmount = lineAmount.Sub(tax)
+		}
+
+		subTotal = subTotal.Add(lineAmount)
+		discountTotal = discountTotal.Add(item.Discount)
+		taxTotal = taxTotal.Add(tax)
+	}
+
+	invoice.Adjustment = invoice.Round(invoice.Adjustment)
+	invoice.ExciseDuty = invoice.Round(invoice.ExciseDuty)
+	invoice.SalesCommission = invoice.Round(invoice.SalesCommission)
 
 	invoice.Items = items
+	invoice.Taxes = taxes.rows
 	invoice.SubTotal = subTotal
 	invoice.DiscountTotal = discountTotal
 	invoice.TaxTotal = taxTotal
-	invoice.TotalAmount = subTotal - discountTotal + taxTotal + req.Adjustment + req.ExciseDuty
-	invoice.BalanceAmount = invoice.TotalAmount // Assuming no payments yet, or simple Recalc. For partial, we need to subtract payments.
-
-	// Recalculate Balance with existing payments (if any)
-	// Currently GetByID preloads Payments.
-	paidAmount := 0.0
-	for _, p := range invoice.Payments {
-		paidAmount += p.Amount
-	}
-	invoice.PaidAmount = paidAmount
-	invoice.BalanceAmount = invoice.TotalAmount - paidAmount
+	invoice.TotalAmount = subTotal.Sub(discountTotal).Add(taxTotal).Add(invoice.Adjustment).Add(invoice.ExciseDuty).Add(invoice.LateFeeTotal)
+	return nil
+}
 
-	if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
-		return nil, err
+// addressToCustomer fills in the billing and shipping address of the
+// customer, when the read model has it
+func (s *InvoiceService) addressToCustomer(ctx context.Context, req *dto.CreateInvoiceRequest) {
+	customer, err := s.rmRepo.GetCustomer(ctx, req.CustomerID)
+	if err != nil || customer == nil {
+		return
 	}
-
-	// 4. Publish Event (?) - InvoiceUpdated
-	// s.publishInvoiceUpdated(invoice)
-
-	return s.mapToResponse(ctx, invoice), nil
+	req.BillingStreet = customer.BillingStreet
+	req.BillingCity = customer.BillingCity
+	req.BillingState = customer.BillingState
+	req.BillingCode = customer.BillingCode
+	req.BillingCountry = customer.BillingCountry
+	req.ShippingStreet = customer.ShippingStreet
+	req.ShippingCity = customer.ShippingCity
+	req.ShippingState = customer.ShippingState
+	req.ShippingCode = customer.ShippingCode
+	req.ShippingCountry = customer.ShippingCountry
 }
 
 func (s *InvoiceService) publishInvoiceCreated(inv *domain.Invoice) {
@@ -247,7 +418,7 @@ func (s *InvoiceService) publishInvoiceCreated(inv *domain.Invoice) {
 		InvoiceDate:    inv.InvoiceDate.Format(time.RFC3339),
 		DueDate:        inv.DueDate.Format(time.RFC3339),
 		Status:         string(inv.Status),
-		TotalAmount:    inv.TotalAmount,
+		TotalAmount:    inv.TotalAmount.Float64(),
 		Currency:       inv.Currency,
 	}
 
@@ -272,11 +443,33 @@ func (s *InvoiceService) DeleteInvoice(ctx context.Context, id uuid.UUID) error
 	if invoice == nil {
 		return fmt.Errorf("invoice not found")
 	}
+	if invoice.IsLocked() {
+		return fmt.Errorf("%w: %s has been issued and must be voided instead", domain.ErrInvoiceLocked, invoice.InvoiceNumber)
+	}
 
 	// 2. Delete the invoice (cascade will handle items)
 	if err := s.invoiceRepo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete invoice: %w", err)
 	}
+	if invoice.Kind == domain.InvoiceKindConsolidated {
+		s.undoConsolidation(ctx, invoice, "consolidated invoice deleted", "System User")
+	}
+	// A deleted work order invoice no longer bills the work order
+	if invoice.WorkOrderID != nil && invoice.BillsInFull() {
+		if err := s.rmRepo.ReleaseWorkOrderBilling(ctx, *invoice.WorkOrderID); err != nil {
+			fmt.Printf("failed to release work order %s: %v\n", *invoice.WorkOrderID, err)
+		}
+	}
+	// A deleted deposit or progress invoice no longer counts against its job
+	if invoice.Kind == domain.InvoiceKindDeposit || invoice.Kind == domain.InvoiceKindProgress {
+		bill, err := s.progressRepo.GetByInvoice(ctx, invoice.ID)
+		if err == nil {
+			err = s.progressRepo.Release(ctx, bill)
+		}
+		if err != nil {
+			fmt.Printf("failed to release progress bill of invoice %s: %v\n", invoice.InvoiceNumber, err)
+		}
+	}
 
 	// 3. Optionally publish InvoiceDeleted event
 	// s.publishInvoiceDeleted(invoice)
@@ -304,17 +497,31 @@ func (s *InvoiceService) mapToResponse(ctx context.Context, inv *domain.Invoice)
 		Subject:         inv.Subject,
 		Status:          string(inv.Status),
 		SubTotal:        inv.SubTotal,
+		Discount:        inv.Discount,
+		DiscountPct:     inv.DiscountPct,
 		DiscountTotal:   inv.DiscountTotal,
 		TaxTotal:        inv.TaxTotal,
+		TaxInclusive:    inv.TaxInclusive,
 		TotalAmount:     inv.TotalAmount,
 		PaidAmount:      inv.PaidAmount,
+		CreditedAmount:  inv.CreditedAmount,
+		DebitedAmount:   inv.DebitedAmount,
+		ExcessCredit:    inv.ExcessCredit,
 		BalanceAmount:   inv.BalanceAmount,
 		Adjustment:      inv.Adjustment,
 		ExciseDuty:      inv.ExciseDuty,
 		SalesCommission: inv.SalesCommission,
+		LateFeeTotal:    inv.LateFeeTotal,
+		PaymentTermID:   inv.PaymentTermID,
+		EarlyPayPct:     inv.EarlyPayPct,
+		EarlyPayBy:      inv.EarlyPayBy,
+		EarlyPayTaken:   inv.EarlyPayTaken,
 		SalesOrder:      inv.SalesOrder,
 		PurchaseOrder:   inv.PurchaseOrder,
 		OwnerID:         inv.OwnerID,
+		WorkOrderID:     inv.WorkOrderID,
+		EstimateID:      inv.EstimateID,
+		Kind:            string(inv.Kind),
 		CustomerID:      inv.CustomerID,
 		ContactID:       inv.ContactID,
 		InvoiceDate:     inv.InvoiceDate,
@@ -329,8 +536,13 @@ func (s *InvoiceService) mapToResponse(ctx context.Context, inv *domain.Invoice)
 		ShippingState:   inv.ShippingState,
 		ShippingCode:    inv.ShippingCode,
 		ShippingCountry: inv.ShippingCountry,
+		Currency:        inv.Currency,
 		Notes:           inv.Notes,
 		Terms:           inv.Terms,
+		ConsolidatedID:  inv.ConsolidatedID,
+	}
+	for i := range inv.Installments {
+		res.Installments = append(res.Installments, mapInstallmentToResponse(&inv.Installments[i]))
 	}
 
 	// Fetch Customer details from Read Model
@@ -365,47 +577,65 @@ func (s *InvoiceService) mapToResponse(ctx context.Context, inv *domain.Invoice)
 				Quantity:    item.Quantity,
 				UnitPrice:   item.UnitPrice,
 				Discount:    item.Discount,
+				DiscountPct: item.DiscountPct,
+				DocDiscount: item.DocDiscount,
+				TaxCode:     item.TaxCode,
+				GroupLabel:  item.GroupLabel,
+				SourceID:    item.SourceID,
 				Tax:         item.Tax,
 				Total:       item.Total,
 			})
 		}
 	}
 
+	for _, t := range inv.Taxes {
+		res.Taxes = append(res.Taxes, dto.TaxResponse{
+			TaxRateID:     t.TaxRateID,
+			Code:          t.Code,
+			Name:          t.Name,
+			Rate:          t.Rate,
+			Compound:      t.Compound,
+			TaxableAmount: t.TaxableAmount,
+			TaxAmount:     t.TaxAmount,
+		})
+	}
+
 	return res
 }
 
 func (s *InvoiceService) UpdateStatus(ctx context.Context, id uuid.UUID, newStatus domain.InvoiceStatus, notes string, performedBy string) error {
-	invoice, err := s.invoiceRepo.GetByID(ctx, id)
+	var oldStatus domain.InvoiceStatus
+	invoice, err := s.invoiceRepo.UpdateStatus(ctx, id, func(invoice *domain.Invoice) error {
+		oldStatus = invoice.Status
+		return invoice.TransitionTo(newStatus, domain.TriggerManual, time.Now().UTC())
+	})
 	if err != nil {
 		return err
 	}
-	if invoice == nil {
-		return fmt.Errorf("invoice not found")
+	if oldStatus == invoice.Status {
+		return nil
 	}
 
-	oldStatus := string(invoice.Status)
-	invoice.Status = newStatus
+	recordStatusChange(ctx, s.auditRepo, s.eventPublisher, invoice, oldStatus, notes, performedBy)
 
-	if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
-		return err
+	// A voided invoice no longer bills its work order, so it may be invoiced again
+	if invoice.Status == domain.InvoiceStatusVoid && invoice.WorkOrderID != nil && invoice.BillsInFull() {
+		if err := s.rmRepo.ReleaseWorkOrderBilling(ctx, *invoice.WorkOrderID); err != nil {
+			fmt.Printf("failed to release work order %s: %v\n", *invoice.WorkOrderID, err)
+		}
 	}
-
-	// Record Audit Log
-	auditLog := &domain.InvoiceAuditLog{
-		ID:             uuid.New(),
-		OrganizationID: invoice.OrganizationID,
-		InvoiceID:      invoice.ID,
-		Action:         "status_change",
-		OldStatus:      oldStatus,
-		NewStatus:      string(newStatus),
-		Notes:          notes,
-		PerformedBy:    performedBy,
-		CreatedAt:      time.Now().UTC(),
+	// nor does a voided progress invoice count against its job any more
+	if invoice.Status == domain.InvoiceStatusVoid && invoice.Kind == domain.InvoiceKindProgress {
+		if err := s.progressRepo.Recount(ctx, invoice.ID); err != nil {
+			fmt.Printf("failed to recount progress billing of invoice %s: %v\n", invoice.InvoiceNumber, err)
+		}
 	}
-
-	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
-		// Log error but don't fail the operation
-		fmt.Printf("failed to create audit log: %v\n", err)
+	// and a voided consolidated invoice gives back what it merged
+	if invoice.Status == domain.InvoiceStatusVoid && invoice.Kind == domain.InvoiceKindConsolidated {
+		s.undoConsolidation(ctx, invoice, "consolidated invoice voided", performedBy)
+	}
+	if oldStatus == domain.InvoiceStatusDraft && invoice.Status == domain.InvoiceStatusSent {
+		s.applyDeposits(ctx, invoice, performedBy)
 	}
 
 	return nil
@@ -0,0 +1,64 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// recordStatusChange runs the side effects shared by every invoice status
+// change, whichever service caused it: an audit entry and a status event.
+// The invoice must already be persisted with its new status.
+func recordStatusChange(
+	ctx context.Context,
+	auditRepo domain.AuditLogRepository,
+	eventPublisher domain.EventPublisher,
+	invoice *domain.Invoice,
+	oldStatus domain.InvoiceStatus,
+	notes string,
+	performedBy string,
+) {
+	if oldStatus == invoice.Status {
+		return
+	}
+	now := time.Now().UTC()
+
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: invoice.OrganizationID,
+		InvoiceID:      invoice.ID,
+		Action:         "status_change",
+		OldStatus:      string(oldStatus),
+		NewStatus:      string(invoice.Status),
+		Notes:          notes,
+		PerformedBy:    performedBy,
+		CreatedAt:      now,
+	}
+
+	if err := auditRepo.Create(ctx, auditLog); err != nil {
+		// Log error but don't fail the operation
+		fmt.Printf("failed to create audit log: %v\n", err)
+	}
+
+	payload := domain.InvoiceStatusChangedPayload{
+		InvoiceID:      invoice.ID.String(),
+		OrganizationID: invoice.OrganizationID.String(),
+		CustomerID:     invoice.CustomerID.String(),
+		InvoiceNumber:  invoice.InvoiceNumber,
+		OldStatus:      string(oldStatus),
+		NewStatus:      string(invoice.Status),
+		BalanceAmount:  invoice.BalanceAmount.Float64(),
+		Currency:       invoice.CurrencyCode(),
+		Notes:          notes,
+		PerformedBy:    performedBy,
+		ChangedAt:      now,
+	}
+
+	metadata := shared_events.NewEventMetadata(domain.EventInvoiceStatusChanged, shared_events.AggregateInvoice, invoice.ID.String())
+	eventPublisher.Publish(context.Background(), metadata, payload)
+}
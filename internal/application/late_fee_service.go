@@ -0,0 +1,400 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// lateFeeActor is recorded as the author of the fees the late fee run charges
+const lateFeeActor = "Late Fees"
+
+// LateFeeService charges the late fees and interest of an organization's
+// policy on its overdue invoices. A fee is either billed on an invoice of
+// its own or added to the overdue invoice as a late fee line; either way it
+// is recorded as a charge first, and the charge's unique invoice and accrual
+// date keep a fee from being charged twice. Each run first finishes the fee
+// invoices an earlier run left unlinked or unsent. Days are counted in the time
+// zone of the organization's dunning policy.
+type LateFeeService struct {
+	lateFeeRepo    domain.LateFeeRepository
+	dunningRepo    domain.DunningRepository
+	invoiceRepo    domain.InvoiceRepository
+	invoiceService *InvoiceService
+	auditRepo      domain.AuditLogRepository
+	eventPublisher domain.EventPublisher
+}
+
+func NewLateFeeService(lateFeeRepo domain.LateFeeRepository, dunningRepo domain.DunningRepository, invoiceRepo domain.InvoiceRepository, invoiceService *InvoiceService, auditRepo domain.AuditLogRepository, eventPublisher domain.EventPublisher) *LateFeeService {
+	return &LateFeeService{
+		lateFeeRepo:    lateFeeRepo,
+		dunningRepo:    dunningRepo,
+		invoiceRepo:    invoiceRepo,
+		invoiceService: invoiceService,
+		auditRepo:      auditRepo,
+		eventPublisher: eventPublisher,
+	}
+}
+
+func (s *LateFeeService) GetPolicy(ctx context.Context, orgID uuid.UUID) (*dto.LateFeePolicyResponse, error) {
+	policy, err := s.lateFeeRepo.GetPolicy(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := mapLateFeePolicyToResponse(policy)
+	return &res, nil
+}
+
+func (s *LateFeeService) SavePolicy(ctx context.Context, orgID uuid.UUID, req dto.SaveLateFeePolicyRequest) (*dto.LateFeePolicyResponse, error) {
+	policy := &domain.LateFeePolicy{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Type:           domain.LateFeeType(req.Type),
+		Amount:         req.Amount,
+		Rate:           req.Rate,
+		GraceDays:      req.GraceDays,
+		IntervalDays:   req.IntervalDays,
+		Cap:            req.Cap,
+		Mode:           domain.LateFeeMode(req.Mode),
+		Enabled:        req.Enabled == nil || *req.Enabled,
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.lateFeeRepo.SavePolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+	return s.GetPolicy(ctx, orgID)
+}
+
+// RunDue charges the fees due at now for every organization with an enabled policy
+func (s *LateFeeService) RunDue(ctx context.Context, now time.Time) (*dto.LateFeeRunResponse, error) {
+	policies, err := s.lateFeeRepo.ListPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list late fee policies: %w", err)
+	}
+	res := &dto.LateFeeRunResponse{}
+	for i := range policies {
+		charged, err := s.run(ctx, &policies[i], now)
+		if err != nil {
+			log.Printf("Late fees: organization %s: %v", policies[i].OrganizationID, err)
+		}
+		res.Charged += charged
+	}
+	return res, nil
+}
+
+// RunForOrganization charges the organization's fees right away
+func (s *LateFeeService) RunForOrganization(ctx context.Context, orgID uuid.UUID) (*dto.LateFeeRunResponse, error) {
+	policy, err := s.lateFeeRepo.GetPolicy(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.Enabled {
+		return &dto.LateFeeRunResponse{}, nil
+	}
+	charged, err := s.run(ctx, policy, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return &dto.LateFeeRunResponse{Charged: charged}, nil
+}
+
+func (s *LateFeeService) run(ctx context.Context, policy *domain.LateFeePolicy, now time.Time) (int, error) {
+	dunning, err := s.dunningRepo.GetPolicy(ctx, policy.OrganizationID)
+	if err != nil && !errors.Is(err, domain.ErrDunningPolicyNotFound) {
+		return 0, fmt.Errorf("failed to get dunning policy: %w", err)
+	}
+	local := now.In(dunning.Location())
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	invoices, err := s.lateFeeRepo.ListCandidates(ctx, policy.OrganizationID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list overdue invoices: %w", err)
+	}
+	ids := make([]uuid.UUID, 0, len(invoices))
+	for _, inv := range invoices {
+		ids = append(ids, inv.ID)
+	}
+	charges, err := s.lateFeeRepo.ListCharges(ctx, ids)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list late fees: %w", err)
+	}
+	previous := make(map[uuid.UUID][]domain.LateFeeCharge)
+	for _, c := range charges {
+		previous[c.InvoiceID] = append(previous[c.InvoiceID], c)
+	}
+
+	charged := s.retryUnbilled(ctx, policy.OrganizationID, today, now)
+	for i := range invoices {
+		inv := &invoices[i]
+		// Most invoices owe nothing today; only those that do are locked
+		if policy.ComputeLateFee(inv, today, previous[inv.ID]) == nil {
+			continue
+		}
+		var ok bool
+		if policy.Mode == domain.LateFeeAsLine {
+			ok, err = s.chargeLine(ctx, policy, inv.ID, today, now)
+		} else {
+			ok, err = s.chargeInvoice(ctx, policy, inv, today, previous[inv.ID], now)
+		}
+		if err != nil {
+			log.Printf("Late fees: failed to charge invoice %s: %v", inv.ID, err)
+		} else if ok {
+			charged++
+		}
+	}
+	return charged, nil
+}
+
+// chargeLine adds the fee to the overdue invoice. The fee is worked out
+// again under the invoice's lock, as a payment may have come in since.
+func (s *LateFeeService) chargeLine(ctx context.Context, policy *domain.LateFeePolicy, invoiceID uuid.UUID, today, now time.Time) (bool, error) {
+	charge, inv, err := s.lateFeeRepo.ChargeLine(ctx, invoiceID, func(inv *domain.Invoice, previous []domain.LateFeeCharge) *domain.LateFeeCharge {
+		if inv.Status != domain.InvoiceStatusOverdue || inv.InCollectionAt != nil {
+			return nil
+		}
+		charge := policy.ComputeLateFee(inv, today, previous)
+		if charge != nil {
+			charge.ID = uuid.New()
+			charge.CreatedAt = now
+		}
+		return charge
+	})
+	if err != nil || charge == nil {
+		return false, err
+	}
+	notes := fmt.Sprintf("%s added as a late fee line", describeLateFee(charge))
+	s.recordCharge(ctx, inv, charge, notes, now)
+	return true, nil
+}
+
+// chargeInvoice claims the fee and bills it on a new invoice, sent right
+// away and due on receipt.
+func (s *LateFeeService) chargeInvoice(ctx context.Context, policy *domain.LateFeePolicy, inv *domain.Invoice, today time.Time, previous []domain.LateFeeCharge, now time.Time) (bool, error) {
+	charge := policy.ComputeLateFee(inv, today, previous)
+	charge.ID = uuid.New()
+	charge.CreatedAt = now
+	claimed, err := s.lateFeeRepo.ClaimCharge(ctx, charge)
+	if err != nil || !claimed {
+		return false, err
+	}
+	if err := s.billCharge(ctx, inv, charge, today, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// retryUnbilled finishes billing the fees a previous run claimed but did
+// not get to invoice, link or send. Claims younger than RunLease may still
+// be in the hands of another run and are left alone.
+func (s *LateFeeService) retryUnbilled(ctx context.Context, orgID uuid.UUID, today, now time.Time) int {
+	charges, err := s.lateFeeRepo.ListUnbilledCharges(ctx, orgID, now.Add(-domain.RunLease))
+	if err != nil {
+		log.Printf("Late fees: organization %s: failed to list unbilled fees: %v", orgID, err)
+		return 0
+	}
+	billed := 0
+	for i := range charges {
+		charge := &charges[i]
+		inv, err := s.invoiceRepo.GetByID(ctx, charge.InvoiceID)
+		if err == nil {
+			err = s.billCharge(ctx, inv, charge, today, now)
+		}
+		if err != nil {
+			log.Printf("Late fees: failed to bill late fee %s: %v", charge.ID, err)
+			continue
+		}
+		billed++
+	}
+	return billed
+}
+
+// billCharge bills a claimed fee on an invoice of its own, links it to the
+// charge and sends it. The fee invoice takes the charge's ID, so a retry
+// after a crash finds it instead of creating a second one. The claim is
+// released if the invoice cannot be created, so the next run charges the
+// fee afresh.
+func (s *LateFeeService) billCharge(ctx context.Context, inv *domain.Invoice, charge *domain.LateFeeCharge, today, now time.Time) error {
+	feeInvoice, err := s.invoiceRepo.GetByID(ctx, charge.ID)
+	if errors.Is(err, domain.ErrInvoiceNotFound) {
+		feeInvoice, err = s.invoiceService.createInvoice(ctx, inv.OrganizationID, lateFeeInvoiceRequest(inv, charge, today), invoiceSource{ID: charge.ID})
+		if err != nil {
+			if releaseErr := s.lateFeeRepo.ReleaseCharge(ctx, charge.ID); releaseErr != nil {
+				log.Printf("Late fee %s: failed to release claim: %v", charge.ID, releaseErr)
+			}
+			return fmt.Errorf("failed to create fee invoice: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get fee invoice: %w", err)
+	}
+
+	if charge.FeeInvoiceID == nil {
+		if err := s.lateFeeRepo.SetFeeInvoice(ctx, charge.ID, feeInvoice.ID); err != nil {
+			return fmt.Errorf("failed to link fee invoice %s: %w", feeInvoice.ID, err)
+		}
+		charge.FeeInvoiceID = &feeInvoice.ID
+	}
+	if feeInvoice.Status != domain.InvoiceStatusDraft {
+		return nil
+	}
+
+	notes := fmt.Sprintf("Late fee for %s", inv.InvoiceNumber)
+	if err := s.invoiceService.UpdateStatus(ctx, feeInvoice.ID, domain.InvoiceStatusSent, notes, lateFeeActor); err != nil {
+		return fmt.Errorf("failed to send fee invoice %s: %w", feeInvoice.ID, err)
+	}
+	// The fee invoice is numbered when it is sent
+	number := feeInvoice.ID.String()
+	if sent, err := s.invoiceRepo.GetByID(ctx, feeInvoice.ID); err == nil {
+		number = sent.InvoiceNumber
+	}
+
+	notes = fmt.Sprintf("%s billed on invoice %s", describeLateFee(charge), number)
+	s.recordCharge(ctx, inv, charge, notes, now)
+	return nil
+}
+
+// lateFeeInvoiceRequest is the invoice a fee is billed on, dated and due today
+func lateFeeInvoiceRequest(inv *domain.Invoice, charge *domain.LateFeeCharge, today time.Time) dto.CreateInvoiceRequest {
+	return dto.CreateInvoiceRequest{
+		Subject:         fmt.Sprintf("Late fee on %s", inv.InvoiceNumber),
+		CustomerID:      inv.CustomerID,
+		ContactID:       inv.ContactID,
+		OwnerID:         inv.OwnerID,
+		InvoiceDate:     today,
+		DueDate:         today,
+		ReferenceNo:     inv.InvoiceNumber,
+		Currency:        inv.CurrencyCode(),
+		Terms:           "Due on receipt",
+		BillingStreet:   inv.BillingStreet,
+		BillingCity:     inv.BillingCity,
+		BillingState:    inv.BillingState,
+		BillingCode:     inv.BillingCode,
+		BillingCountry:  inv.BillingCountry,
+		ShippingStreet:  inv.ShippingStreet,
+		ShippingCity:    inv.ShippingCity,
+		ShippingState:   inv.ShippingState,
+		ShippingCode:    inv.ShippingCode,
+		ShippingCountry: inv.ShippingCountry,
+		Items: []dto.CreateInvoiceItem{{
+			ItemType:    domain.LateFeeItemType,
+			Name:        "Late fee",
+			Description: fmt.Sprintf("%s on invoice %s", describeLateFee(charge), inv.InvoiceNumber),
+			Quantity:    1,
+			UnitPrice:   charge.Amount,
+		}},
+	}
+}
+
+func (s *LateFeeService) recordCharge(ctx context.Context, inv *domain.Invoice, charge *domain.LateFeeCharge, notes string, now time.Time) {
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: inv.OrganizationID,
+		InvoiceID:      inv.ID,
+		Action:         "late_fee",
+		OldStatus:      string(inv.Status),
+		NewStatus:      string(inv.Status),
+		Notes:          notes,
+		PerformedBy:    lateFeeActor,
+		CreatedAt:      now,
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		log.Printf("Failed to audit late fee %s of invoice %s: %v", charge.ID, inv.ID, err)
+	}
+
+	payload := domain.LateFeeChargedPayload{
+		ChargeID:       charge.ID.String(),
+		InvoiceID:      inv.ID.String(),
+		OrganizationID: inv.OrganizationID.String(),
+		CustomerID:     inv.CustomerID.String(),
+		InvoiceNumber:  inv.InvoiceNumber,
+		Type:           string(charge.Type),
+		Basis:          charge.Basis.Float64(),
+		Amount:         charge.Amount.Float64(),
+		Currency:       charge.Currency,
+		AccruedFrom:    charge.AccruedFrom,
+		AccruedThrough: charge.AccruedThrough,
+		ChargedAt:      now,
+	}
+	if charge.FeeInvoiceID != nil {
+		payload.FeeInvoiceID = charge.FeeInvoiceID.String()
+	}
+	metadata := shared_events.NewEventMetadata(domain.EventLateFeeCharged, shared_events.AggregateInvoice, inv.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+func describeLateFee(c *domain.LateFeeCharge) string {
+	amount := fmt.Sprintf("%s %s", c.Amount.StringFixed(c.Currency), c.Currency)
+	basis := fmt.Sprintf("%s %s", c.Basis.StringFixed(c.Currency), c.Currency)
+	var s string
+	switch c.Type {
+	case domain.LateFeeInterest:
+		s = fmt.Sprintf("Interest of %s at %s%% a year on %s from %s to %s", amount, c.Rate, basis,
+			c.AccruedFrom.Format("2006-01-02"), c.AccruedThrough.Format("2006-01-02"))
+	case domain.LateFeePercent:
+		s = fmt.Sprintf("Late fee of %s, %s%% of %s", amount, c.Rate, basis)
+	default:
+		s = fmt.Sprintf("Late fee of %s", amount)
+	}
+	if c.Capped {
+		s += ", reduced to the policy's cap"
+	}
+	return s
+}
+
+// ListCharges returns the late fees charged on an invoice
+func (s *LateFeeService) ListCharges(ctx context.Context, orgID, invoiceID uuid.UUID) ([]dto.LateFeeChargeResponse, error) {
+	inv, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if inv.OrganizationID != orgID {
+		return nil, domain.ErrInvoiceNotFound
+	}
+	charges, err := s.lateFeeRepo.ListCharges(ctx, []uuid.UUID{invoiceID})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.LateFeeChargeResponse, 0, len(charges))
+	for _, c := range charges {
+		res = append(res, dto.LateFeeChargeResponse{
+			ID:             c.ID,
+			InvoiceID:      c.InvoiceID,
+			Type:           string(c.Type),
+			Mode:           string(c.Mode),
+			AccruedFrom:    c.AccruedFrom,
+			AccruedThrough: c.AccruedThrough,
+			Days:           c.Days,
+			Basis:          c.Basis,
+			Rate:           c.Rate,
+			Amount:         c.Amount,
+			Currency:       c.Currency,
+			Capped:         c.Capped,
+			FeeInvoiceID:   c.FeeInvoiceID,
+			CreatedAt:      c.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func mapLateFeePolicyToResponse(p *domain.LateFeePolicy) dto.LateFeePolicyResponse {
+	return dto.LateFeePolicyResponse{
+		Type:         string(p.Type),
+		Amount:       p.Amount,
+		Rate:         p.Rate,
+		GraceDays:    p.GraceDays,
+		IntervalDays: p.IntervalDays,
+		Cap:          p.Cap,
+		Mode:         string(p.Mode),
+		Enabled:      p.Enabled,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
@@ -0,0 +1,97 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// NumberingService configures the per-organization series that invoice and
+// note numbers are allocated from
+type NumberingService struct {
+	seqRepo domain.NumberSequenceRepository
+}
+
+func NewNumberingService(seqRepo domain.NumberSequenceRepository) *NumberingService {
+	return &NumberingService{seqRepo: seqRepo}
+}
+
+func (s *NumberingService) ListSequences(ctx context.Context, orgID uuid.UUID) ([]dto.NumberSequenceResponse, error) {
+	now := time.Now().UTC()
+	res := make([]dto.NumberSequenceResponse, 0, len(domain.DocumentTypes))
+	for _, docType := range domain.DocumentTypes {
+		seq, err := s.seqRepo.Get(ctx, orgID, docType)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, mapNumberSequenceToResponse(seq, now))
+	}
+	return res, nil
+}
+
+// UpdateSequence changes the format of a series. The counter carries on from
+// where it is unless NextValue is given, which may only move it forward.
+func (s *NumberingService) UpdateSequence(ctx context.Context, orgID uuid.UUID, docType domain.DocumentType, req dto.UpdateNumberSequenceRequest) (*dto.NumberSequenceResponse, error) {
+	if !docType.IsValid() {
+		return nil, fmt.Errorf("%w: unknown document type %q", domain.ErrInvalidInput, docType)
+	}
+	seq, err := s.seqRepo.Get(ctx, orgID, docType)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	// Value the next document gets under the current settings
+	next := seq.NextValue
+	if seq.Period(now) != seq.CurrentPeriod {
+		next = 1
+	}
+
+	seq.Prefix = req.Prefix
+	seq.Format = req.Format
+	seq.Padding = req.Padding
+	seq.ResetPeriod = domain.SequenceReset(req.ResetPeriod)
+	seq.FiscalYearStart = req.FiscalYearStart
+	if seq.FiscalYearStart == 0 {
+		seq.FiscalYearStart = 1
+	}
+	if req.NextValue != nil {
+		if *req.NextValue < next {
+			return nil, fmt.Errorf("%w: next_value %d would reuse numbers already issued, the series is at %d", domain.ErrInvalidInput, *req.NextValue, next)
+		}
+		next = *req.NextValue
+	}
+	// Pin the counter to the current period so a changed reset period does
+	// not restart numbering that is already under way
+	seq.NextValue = next
+	seq.CurrentPeriod = seq.Period(now)
+
+	if err := seq.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.seqRepo.Save(ctx, seq); err != nil {
+		return nil, err
+	}
+	res := mapNumberSequenceToResponse(seq, now)
+	return &res, nil
+}
+
+func mapNumberSequenceToResponse(seq *domain.NumberSequence, now time.Time) dto.NumberSequenceResponse {
+	return dto.NumberSequenceResponse{
+		DocumentType:    string(seq.DocumentType),
+		Prefix:          seq.Prefix,
+		Format:          seq.Format,
+		Padding:         seq.Padding,
+		ResetPeriod:     string(seq.ResetPeriod),
+		FiscalYearStart: seq.FiscalYearStart,
+		NextValue:       seq.NextValue,
+		CurrentPeriod:   seq.CurrentPeriod,
+		NextNumber:      seq.Preview(now),
+		UpdatedAt:       seq.UpdatedAt,
+	}
+}
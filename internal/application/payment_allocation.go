@@ -0,0 +1,190 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// RecordCustomerPayment records one payment from a customer and spreads it
+// over their invoices, either as listed in the request or with an automatic
+// strategy. Every invoice involved is locked while the payment is stored.
+// Whatever is not allocated is kept as credit for the customer.
+func (s *PaymentService) RecordCustomerPayment(ctx context.Context, orgID uuid.UUID, req dto.RecordCustomerPaymentRequest, performedBy string) (*dto.PaymentResponse, error) {
+	if req.PaymentMethod == domain.PaymentMethodCredit {
+		return nil, fmt.Errorf("%w: customer credit is applied through the customer's credit endpoint", domain.ErrInvalidInput)
+	}
+	if len(req.IdempotencyKey) > 100 {
+		return nil, fmt.Errorf("%w: idempotency key is longer than 100 characters", domain.ErrInvalidInput)
+	}
+
+	strategy := domain.AllocateOldestFirst
+	if len(req.Allocations) > 0 {
+		strategy = domain.AllocateManual
+	}
+	if req.Strategy != "" {
+		var err error
+		if strategy, err = domain.ParseAllocationStrategy(req.Strategy); err != nil {
+			return nil, err
+		}
+	}
+	if strategy != domain.AllocateManual && len(req.Allocations) > 0 {
+		return nil, fmt.Errorf("%w: allocations can only be listed with the manual strategy", domain.ErrInvalidInput)
+	}
+
+	sameRequest := func(p *domain.Payment) bool {
+		return p.InvoiceID == nil && p.CustomerID == req.CustomerID && p.PaymentMethod == req.PaymentMethod
+	}
+	if req.IdempotencyKey != "" {
+		if res, err := s.replayPayment(ctx, orgID, req.IdempotencyKey, sameRequest); !errors.Is(err, domain.ErrPaymentNotFound) {
+			return res, err
+		}
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	now := time.Now().UTC()
+	paidAt := now
+	if req.PaymentDate != nil {
+		paidAt = req.PaymentDate.UTC()
+	}
+	amount := req.Amount.Round(currency, domain.MoneyRounding)
+	if err := domain.ValidateReceipt(amount, req.PaymentMethod, paidAt, now); err != nil {
+		return nil, err
+	}
+
+	var invoiceIDs []uuid.UUID
+	if strategy == domain.AllocateManual {
+		for _, a := range req.Allocations {
+			invoiceIDs = append(invoiceIDs, a.InvoiceID)
+		}
+	} else {
+		open, err := s.invoiceRepo.ListOpenForCustomer(ctx, orgID, req.CustomerID, currency)
+		if err != nil {
+			return nil, err
+		}
+		for _, inv := range open {
+			invoiceIDs = append(invoiceIDs, inv.ID)
+		}
+	}
+
+	oldStatuses := make(map[uuid.UUID]domain.InvoiceStatus)
+	payment, invoices, err := s.paymentRepo.Record(ctx, invoiceIDs, func(invoices []*domain.Invoice) (*domain.Payment, error) {
+		payment := &domain.Payment{
+			ID:             uuid.New(),
+			OrganizationID: orgID,
+			CustomerID:     req.CustomerID,
+			Currency:       currency,
+			Unapplied:      amount,
+			PaymentDate:    paidAt,
+			PaymentMethod:  req.PaymentMethod,
+			TransactionRef: req.TransactionRef,
+			Notes:          req.Notes,
+			Status:         domain.PaymentStatusCompleted,
+		}
+		if req.IdempotencyKey != "" {
+			key := req.IdempotencyKey
+			payment.IdempotencyKey = &key
+		}
+
+		// Automatic strategies plan against the balances as they are under the lock
+		allocations := mapAllocationRequests(req.Allocations)
+		if strategy != domain.AllocateManual {
+			var err error
+			if allocations, err = domain.PlanAllocations(strategy, amount, invoices); err != nil {
+				return nil, err
+			}
+		}
+		return payment, allocatePayment(payment, allocations, invoices, oldStatuses, now, performedBy)
+	})
+	if errors.Is(err, domain.ErrPaymentAlreadyRecorded) {
+		return s.replayPayment(ctx, orgID, req.IdempotencyKey, sameRequest)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAllocationChanges(ctx, payment, nil, invoices, oldStatuses, fmt.Sprintf("payment %s recorded", payment.ID), performedBy)
+	s.publishPaymentCreated(ctx, payment)
+
+	res := mapPaymentToResponse(payment)
+	return &res, nil
+}
+
+// ReallocatePayment replaces the allocations of a payment. Invoices it no
+// longer pays get their balance back; an amount freed this way returns to the
+// customer's credit, and credit is used up when more is allocated than before.
+// Payments made from credit are not reallocated, only new money is.
+func (s *PaymentService) ReallocatePayment(ctx context.Context, orgID, paymentID uuid.UUID, req dto.ReallocatePaymentRequest, performedBy string) (*dto.PaymentResponse, error) {
+	invoiceIDs := make([]uuid.UUID, 0, len(req.Allocations))
+	for _, a := range req.Allocations {
+		invoiceIDs = append(invoiceIDs, a.InvoiceID)
+	}
+
+	now := time.Now().UTC()
+	var previous []domain.PaymentAllocation
+	oldStatuses := make(map[uuid.UUID]domain.InvoiceStatus)
+	payment, invoices, err := s.paymentRepo.Reallocate(ctx, paymentID, invoiceIDs, func(p *domain.Payment, invoices []*domain.Invoice) error {
+		if p.OrganizationID != orgID {
+			return domain.ErrPaymentNotFound
+		}
+		if p.PaymentMethod == domain.PaymentMethodCredit {
+			return fmt.Errorf("%w: credit applied to an invoice cannot be reallocated", domain.ErrInvalidInput)
+		}
+		if p.Status == domain.PaymentStatusReversed {
+			return fmt.Errorf("%w: payment %s has been reversed", domain.ErrInvalidInput, p.ID)
+		}
+		previous = append([]domain.PaymentAllocation(nil), p.Allocations...)
+		return allocatePayment(p, mapAllocationRequests(req.Allocations), invoices, oldStatuses, now, performedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notes := req.Notes
+	if notes == "" {
+		notes = "payment reallocated"
+	}
+	s.recordAllocationChanges(ctx, payment, previous, invoices, oldStatuses, notes, performedBy)
+	s.publishPaymentReallocated(payment, previous, performedBy)
+
+	res := mapPaymentToResponse(payment)
+	return &res, nil
+}
+
+func (s *PaymentService) publishPaymentReallocated(p *domain.Payment, previous []domain.PaymentAllocation, performedBy string) {
+	payload := domain.PaymentReallocatedPayload{
+		PaymentID:           p.ID.String(),
+		OrganizationID:      p.OrganizationID.String(),
+		CustomerID:          p.CustomerID.String(),
+		Currency:            p.Currency,
+		AllocatedAmount:     p.Amount.Float64(),
+		UnappliedAmount:     p.Unapplied.Float64(),
+		PreviousAllocations: mapAllocationsToPayload(previous),
+		Allocations:         mapAllocationsToPayload(p.Allocations),
+		PerformedBy:         performedBy,
+		ReallocatedAt:       time.Now().UTC(),
+	}
+
+	metadata := shared_events.NewEventMetadata(domain.EventPaymentReallocated, shared_events.AggregatePayment, p.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+func mapAllocationRequests(reqs []dto.AllocationRequest) []domain.PaymentAllocation {
+	allocations := make([]domain.PaymentAllocation, 0, len(reqs))
+	for _, a := range reqs {
+		allocations = append(allocations, domain.PaymentAllocation{InvoiceID: a.InvoiceID, Amount: a.Amount})
+	}
+	return allocations
+}
@@ -0,0 +1,318 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/internal/ports/external"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// gatewayMethods are the payment methods collected online
+var gatewayMethods = map[string]bool{
+	domain.PaymentMethodCard:         true,
+	domain.PaymentMethodBankTransfer: true,
+}
+
+// GatewayService collects invoice payments through an online payment
+// gateway. Captured charges are recorded with PaymentService, keyed on the
+// provider's transaction reference, so a capture and the webhook announcing
+// it record the payment once.
+type GatewayService struct {
+	gateway     external.PaymentGateway
+	payments    *PaymentService
+	invoiceRepo domain.InvoiceRepository
+	paymentRepo domain.PaymentRepository
+}
+
+func NewGatewayService(gateway external.PaymentGateway, payments *PaymentService, invoiceRepo domain.InvoiceRepository, paymentRepo domain.PaymentRepository) *GatewayService {
+	return &GatewayService{
+		gateway:     gateway,
+		payments:    payments,
+		invoiceRepo: invoiceRepo,
+		paymentRepo: paymentRepo,
+	}
+}
+
+// CreatePaymentIntent starts collecting an invoice's amount due, or part of
+// it, through the gateway
+func (s *GatewayService) CreatePaymentIntent(ctx context.Context, orgID, invoiceID uuid.UUID, req dto.CreatePaymentIntentRequest) (*dto.PaymentIntentResponse, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.OrganizationID != orgID {
+		return nil, domain.ErrInvoiceNotFound
+	}
+
+	method := req.PaymentMethod
+	if method == "" {
+		method = domain.PaymentMethodCard
+	}
+	if !gatewayMethods[method] {
+		return nil, fmt.Errorf("%w: %q payments are not collected online", domain.ErrInvalidInput, method)
+	}
+
+	due := invoice.AmountDue()
+	amount := due
+	if req.Amount != nil {
+		amount = invoice.Round(*req.Amount)
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: nothing is due on %s", domain.ErrInvalidInput, invoice.InvoiceNumber)
+	}
+	if amount.GreaterThan(due) {
+		return nil, fmt.Errorf("%w: %s exceeds the %s due on %s", domain.ErrInvalidInput, amount, due, invoice.InvoiceNumber)
+	}
+
+	intent, err := s.gateway.CreateIntent(ctx, domain.PaymentIntentRequest{
+		OrganizationID: orgID,
+		InvoiceID:      invoice.ID,
+		Amount:         amount,
+		Currency:       invoice.CurrencyCode(),
+		PaymentMethod:  method,
+		IdempotencyKey: req.IdempotencyKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := mapIntentToResponse(intent, nil)
+	return &res, nil
+}
+
+// CapturePaymentIntent collects an authorized intent and records the payment
+// when the provider accepts it
+func (s *GatewayService) CapturePaymentIntent(ctx context.Context, orgID uuid.UUID, intentID string, req dto.CapturePaymentIntentRequest, performedBy string) (*dto.PaymentIntentResponse, error) {
+	// Another organization's intent must not even be captured
+	intent, err := s.gateway.GetIntent(ctx, intentID)
+	if err != nil {
+		return nil, err
+	}
+	if intent.OrganizationID != orgID {
+		return nil, domain.ErrPaymentIntentNotFound
+	}
+
+	amount := money.Zero
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	intent, err = s.gateway.Capture(ctx, intentID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	var payment *dto.PaymentResponse
+	if intent.Status == domain.PaymentIntentSucceeded {
+		payment, err = s.recordGatewayPayment(ctx, &domain.GatewayEvent{
+			Type:           domain.GatewayEventPaymentSucceeded,
+			Provider:       s.gateway.Name(),
+			IntentID:       intent.ID,
+			TransactionRef: intent.TransactionRef,
+			OrganizationID: intent.OrganizationID,
+			InvoiceID:      intent.InvoiceID,
+			Amount:         intent.Amount,
+			Currency:       intent.Currency,
+			PaymentMethod:  intent.PaymentMethod,
+			OccurredAt:     time.Now().UTC(),
+		}, performedBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := mapIntentToResponse(intent, payment)
+	return &res, nil
+}
+
+// HandleWebhook verifies a webhook from the gateway and records the payment
+// it announces. Deliveries are retried by providers, so a payment that has
+// been recorded already is returned as is. Events that record nothing return
+// a nil payment.
+func (s *GatewayService) HandleWebhook(ctx context.Context, payload []byte, signature string) (*dto.PaymentResponse, error) {
+	event, err := s.gateway.ParseWebhook(payload, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	switch event.Type {
+	case domain.GatewayEventPaymentSucceeded:
+		return s.recordGatewayPayment(ctx, event, s.gateway.Name()+" webhook")
+	case domain.GatewayEventPaymentFailed:
+		log.Printf("Gateway payment %s for invoice %s failed", event.IntentID, event.InvoiceID)
+		return nil, nil
+	default:
+		log.Printf("Ignoring gateway event %s of type %s", event.ID, event.Type)
+		return nil, nil
+	}
+}
+
+// recordGatewayPayment records a captured charge against its invoice. Money
+// the invoice cannot take, because it is in another currency or was settled
+// or voided while the charge was in flight, has still been collected, so it
+// is kept as credit for the invoice's customer instead.
+func (s *GatewayService) recordGatewayPayment(ctx context.Context, event *domain.GatewayEvent, performedBy string) (*dto.PaymentResponse, error) {
+	if event.TransactionRef == "" {
+		return nil, fmt.Errorf("%w: gateway payment %s has no transaction reference", domain.ErrInvalidInput, event.IntentID)
+	}
+	key := domain.GatewayIdempotencyKey(event.Provider, event.TransactionRef)
+
+	// The charge may have been recorded already, on the invoice or as credit
+	existing, err := s.paymentRepo.GetByIdempotencyKey(ctx, event.OrganizationID, key)
+	if err == nil {
+		res := mapPaymentToResponse(existing)
+		return &res, nil
+	}
+	if !errors.Is(err, domain.ErrPaymentNotFound) {
+		return nil, err
+	}
+
+	invoice, err := s.invoiceRepo.GetByID(ctx, event.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.OrganizationID != event.OrganizationID {
+		return nil, domain.ErrInvoiceNotFound
+	}
+
+	// Provider clocks may run slightly ahead of ours
+	paidAt := time.Now().UTC()
+	if !event.OccurredAt.IsZero() && event.OccurredAt.Before(paidAt) {
+		paidAt = event.OccurredAt.UTC()
+	}
+	notes := fmt.Sprintf("Collected online through %s, intent %s", event.Provider, event.IntentID)
+
+	currency := strings.ToUpper(event.Currency)
+	if currency == invoice.CurrencyCode() {
+		res, err := s.payments.RecordPayment(ctx, event.OrganizationID, invoice.ID, dto.RecordPaymentRequest{
+			Amount:         event.Amount,
+			PaymentMethod:  event.PaymentMethod,
+			PaymentDate:    &paidAt,
+			TransactionRef: event.TransactionRef,
+			Notes:          notes,
+			IdempotencyKey: key,
+		}, performedBy)
+		if !errors.Is(err, domain.ErrInvalidInput) && !errors.Is(err, domain.ErrInvalidStatusTransition) {
+			return res, err
+		}
+		log.Printf("Gateway payment %s cannot be applied to invoice %s, keeping it as customer credit: %v", event.TransactionRef, invoice.InvoiceNumber, err)
+		notes += fmt.Sprintf(", not applicable to invoice %s", invoice.InvoiceNumber)
+	} else {
+		log.Printf("Gateway payment %s is in %s, invoice %s in %s; keeping it as customer credit", event.TransactionRef, currency, invoice.InvoiceNumber, invoice.CurrencyCode())
+		notes += fmt.Sprintf(", in %s while invoice %s is in %s", currency, invoice.InvoiceNumber, invoice.CurrencyCode())
+	}
+
+	return s.payments.RecordCustomerPayment(ctx, event.OrganizationID, dto.RecordCustomerPaymentRequest{
+		CustomerID:     invoice.CustomerID,
+		Amount:         event.Amount,
+		Currency:       currency,
+		PaymentMethod:  event.PaymentMethod,
+		PaymentDate:    &paidAt,
+		TransactionRef: event.TransactionRef,
+		Notes:          notes,
+		IdempotencyKey: key,
+		Strategy:       string(domain.AllocateManual),
+	}, performedBy)
+}
+
+// RefundGatewayPayment sends part of a payment collected online back through
+// the gateway. The refund is recorded against the payment first, so money
+// never leaves without the ledger knowing, and its ID is the provider's
+// idempotency key. When the provider does not confirm it, the request is
+// retried with the refund's ID, which sends the same refund again instead of
+// recording another.
+func (s *GatewayService) RefundGatewayPayment(ctx context.Context, orgID, paymentID uuid.UUID, req dto.GatewayRefundRequest, performedBy string) (*dto.PaymentRefundResponse, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.OrganizationID != orgID {
+		return nil, domain.ErrPaymentNotFound
+	}
+	if payment.IdempotencyKey == nil || *payment.IdempotencyKey != domain.GatewayIdempotencyKey(s.gateway.Name(), payment.TransactionRef) {
+		return nil, fmt.Errorf("%w: payment %s was not collected through %s", domain.ErrInvalidInput, payment.ID, s.gateway.Name())
+	}
+
+	var refund *dto.PaymentRefundResponse
+	if req.RefundID != nil {
+		refund, err = s.unsentRefund(ctx, payment, *req.RefundID)
+		if err != nil {
+			return nil, err
+		}
+		// The provider confirmed it already
+		if refund.Reference != s.pendingReference() {
+			return refund, nil
+		}
+	} else {
+		amount := req.Amount.Round(payment.Currency, domain.MoneyRounding)
+		if !amount.IsPositive() || amount.GreaterThan(payment.Received()) {
+			return nil, fmt.Errorf("%w: refund must be positive and at most the %s left of the payment", domain.ErrInvalidInput, payment.Received())
+		}
+		refund, err = s.payments.RefundPayment(ctx, orgID, paymentID, dto.RefundPaymentRequest{
+			Amount:        amount,
+			PaymentMethod: payment.PaymentMethod,
+			Reference:     s.pendingReference(),
+			Reason:        req.Reason,
+		}, performedBy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sent, err := s.gateway.Refund(ctx, payment.TransactionRef, refund.Amount, payment.Currency, refund.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("refund %s is recorded but %s did not confirm it, retry with its refund_id: %w", refund.ID, s.gateway.Name(), err)
+	}
+	if err := s.paymentRepo.SetRefundReference(ctx, refund.ID, sent.ID); err != nil {
+		// The money went back and the refund is recorded; only the provider's reference is missing
+		log.Printf("Gateway refund %s of payment %s was sent but its reference could not be stored: %v", sent.ID, payment.ID, err)
+	} else {
+		refund.Reference = sent.ID
+	}
+	return refund, nil
+}
+
+// pendingReference marks a refund recorded for the gateway that the
+// provider has not confirmed yet
+func (s *GatewayService) pendingReference() string {
+	return s.gateway.Name() + ":pending"
+}
+
+// unsentRefund returns a refund of payment recorded by an earlier attempt
+func (s *GatewayService) unsentRefund(ctx context.Context, payment *domain.Payment, refundID uuid.UUID) (*dto.PaymentRefundResponse, error) {
+	refunds, err := s.paymentRepo.ListRefunds(ctx, payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range refunds {
+		if refunds[i].ID == refundID && refunds[i].RefundType == domain.RefundTypeRefund {
+			res := mapRefundToResponse(&refunds[i], payment)
+			return &res, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: payment %s has no refund %s", domain.ErrInvalidInput, payment.ID, refundID)
+}
+
+func mapIntentToResponse(intent *domain.PaymentIntent, payment *dto.PaymentResponse) dto.PaymentIntentResponse {
+	return dto.PaymentIntentResponse{
+		ID:             intent.ID,
+		Provider:       intent.Provider,
+		InvoiceID:      intent.InvoiceID,
+		Amount:         intent.Amount,
+		Currency:       intent.Currency,
+		PaymentMethod:  intent.PaymentMethod,
+		Status:         string(intent.Status),
+		ClientSecret:   intent.ClientSecret,
+		TransactionRef: intent.TransactionRef,
+		CreatedAt:      intent.CreatedAt,
+		Payment:        payment,
+	}
+}
@@ -0,0 +1,177 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// RefundPayment pays part or all of a payment back to the customer. The
+// payment's unapplied credit is refunded first; anything beyond it is taken
+// off the invoices the payment settled, which are re-opened.
+func (s *PaymentService) RefundPayment(ctx context.Context, orgID, paymentID uuid.UUID, req dto.RefundPaymentRequest, performedBy string) (*dto.PaymentRefundResponse, error) {
+	if !domain.IsValidPaymentMethod(req.PaymentMethod) {
+		return nil, fmt.Errorf("%w: unknown payment method %q", domain.ErrInvalidInput, req.PaymentMethod)
+	}
+	refund := &domain.PaymentRefund{
+		RefundType:    domain.RefundTypeRefund,
+		Amount:        req.Amount,
+		PaymentMethod: req.PaymentMethod,
+		Reference:     req.Reference,
+		Reason:        req.Reason,
+	}
+	return s.refundPayment(ctx, orgID, paymentID, refund, req.RefundDate, performedBy)
+}
+
+// ReversePayment undoes a payment that never arrived, such as a bounced
+// check or a chargeback. Everything left of it is taken back: its unapplied
+// credit is removed and every invoice it settled is re-opened. Reversing a
+// payment made from credit gives the credit back to the customer.
+func (s *PaymentService) ReversePayment(ctx context.Context, orgID, paymentID uuid.UUID, req dto.ReversePaymentRequest, performedBy string) (*dto.PaymentRefundResponse, error) {
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: a reversal needs a reason", domain.ErrInvalidInput)
+	}
+	refund := &domain.PaymentRefund{
+		RefundType: domain.RefundTypeReversal,
+		Reference:  req.Reference,
+		Reason:     req.Reason,
+	}
+	return s.refundPayment(ctx, orgID, paymentID, refund, req.ReversalDate, performedBy)
+}
+
+func (s *PaymentService) refundPayment(ctx context.Context, orgID, paymentID uuid.UUID, refund *domain.PaymentRefund, refundDate *time.Time, performedBy string) (*dto.PaymentRefundResponse, error) {
+	now := time.Now().UTC()
+	refund.RefundDate = now
+	if refundDate != nil {
+		refund.RefundDate = refundDate.UTC()
+	}
+	if refund.RefundDate.After(now) {
+		return nil, fmt.Errorf("%w: refund date cannot be in the future", domain.ErrInvalidInput)
+	}
+
+	var previous []domain.PaymentAllocation
+	oldStatuses := make(map[uuid.UUID]domain.InvoiceStatus)
+	payment, refund, invoices, err := s.paymentRepo.Refund(ctx, paymentID, func(p *domain.Payment, invoices []*domain.Invoice) (*domain.PaymentRefund, error) {
+		if p.OrganizationID != orgID {
+			return nil, domain.ErrPaymentNotFound
+		}
+		if refund.RefundDate.Before(p.PaymentDate) {
+			return nil, fmt.Errorf("%w: refund date is before the payment date", domain.ErrInvalidInput)
+		}
+		previous = append([]domain.PaymentAllocation(nil), p.Allocations...)
+
+		kept, err := p.Refund(refund)
+		if err != nil {
+			return nil, err
+		}
+		if err := allocatePayment(p, kept, invoices, oldStatuses, now, performedBy); err != nil {
+			return nil, err
+		}
+
+		refund.ID = uuid.New()
+		refund.OrganizationID = p.OrganizationID
+		refund.PaymentID = p.ID
+		refund.CustomerID = p.CustomerID
+		refund.Currency = p.Currency
+		refund.CreatedBy = performedBy
+		return refund, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notes := fmt.Sprintf("payment %s %s", payment.ID, refundAction(refund))
+	if refund.Reason != "" {
+		notes += ": " + refund.Reason
+	}
+	s.recordAllocationChanges(ctx, payment, previous, invoices, oldStatuses, notes, performedBy)
+	s.publishPaymentRefunded(payment, refund, previous)
+
+	res := mapRefundToResponse(refund, payment)
+	return &res, nil
+}
+
+// ListRefunds returns the refunds and reversals of a payment
+func (s *PaymentService) ListRefunds(ctx context.Context, orgID, paymentID uuid.UUID) ([]dto.PaymentRefundResponse, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.OrganizationID != orgID {
+		return nil, domain.ErrPaymentNotFound
+	}
+	refunds, err := s.paymentRepo.ListRefunds(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.PaymentRefundResponse, 0, len(refunds))
+	for i := range refunds {
+		res = append(res, mapRefundToResponse(&refunds[i], payment))
+	}
+	return res, nil
+}
+
+func refundAction(refund *domain.PaymentRefund) string {
+	if refund.RefundType == domain.RefundTypeReversal {
+		return "reversed"
+	}
+	return fmt.Sprintf("refunded %s", refund.Amount)
+}
+
+func (s *PaymentService) publishPaymentRefunded(p *domain.Payment, refund *domain.PaymentRefund, previous []domain.PaymentAllocation) {
+	var reopened []domain.PaymentAllocation
+	for _, a := range previous {
+		if taken := a.Amount.Sub(p.AllocatedTo(a.InvoiceID)); taken.IsPositive() {
+			reopened = append(reopened, domain.PaymentAllocation{InvoiceID: a.InvoiceID, Amount: taken})
+		}
+	}
+
+	payload := domain.PaymentRefundedPayload{
+		RefundID:         refund.ID.String(),
+		PaymentID:        p.ID.String(),
+		OrganizationID:   p.OrganizationID.String(),
+		CustomerID:       p.CustomerID.String(),
+		RefundType:       string(refund.RefundType),
+		Amount:           refund.Amount.Float64(),
+		FromCredit:       refund.FromCredit.Float64(),
+		Currency:         refund.Currency,
+		PaymentMethod:    refund.PaymentMethod,
+		Reference:        refund.Reference,
+		Reason:           refund.Reason,
+		PaymentStatus:    string(p.Status),
+		ReopenedInvoices: mapAllocationsToPayload(reopened),
+		RefundedAt:       refund.RefundDate,
+	}
+
+	eventType := domain.EventPaymentRefunded
+	if refund.RefundType == domain.RefundTypeReversal {
+		eventType = domain.EventPaymentReversed
+	}
+	metadata := shared_events.NewEventMetadata(eventType, shared_events.AggregatePayment, p.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+func mapRefundToResponse(r *domain.PaymentRefund, p *domain.Payment) dto.PaymentRefundResponse {
+	return dto.PaymentRefundResponse{
+		ID:            r.ID,
+		PaymentID:     r.PaymentID,
+		RefundType:    string(r.RefundType),
+		Currency:      r.Currency,
+		Amount:        r.Amount,
+		FromCredit:    r.FromCredit,
+		RefundDate:    r.RefundDate,
+		PaymentMethod: r.PaymentMethod,
+		Reference:     r.Reference,
+		Reason:        r.Reason,
+		CreatedBy:     r.CreatedBy,
+		CreatedAt:     r.CreatedAt,
+		Payment:       mapPaymentToResponse(p),
+	}
+}
@@ -2,10 +2,14 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"erp-billing-service/internal/application/dto"
 	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
 
 	shared_events "github.com/efs/shared-events"
 	"github.com/google/uuid"
@@ -14,76 +18,386 @@ import (
 type PaymentService struct {
 	paymentRepo    domain.PaymentRepository
 	invoiceRepo    domain.InvoiceRepository
+	rmRepo         domain.ReadModelRepository
+	creditRepo     domain.CustomerCreditRepository
+	auditRepo      domain.AuditLogRepository
 	eventPublisher domain.EventPublisher
+
+	// issued is called for each draft invoice a payment issues
+	issued func(ctx context.Context, invoice *domain.Invoice, performedBy string)
 }
 
 func NewPaymentService(
 	paymentRepo domain.PaymentRepository,
 	invoiceRepo domain.InvoiceRepository,
+	rmRepo domain.ReadModelRepository,
+	creditRepo domain.CustomerCreditRepository,
+	auditRepo domain.AuditLogRepository,
 	eventPublisher domain.EventPublisher,
 ) *PaymentService {
 	return &PaymentService{
 		paymentRepo:    paymentRepo,
 		invoiceRepo:    invoiceRepo,
+		rmRepo:         rmRepo,
+		creditRepo:     creditRepo,
+		auditRepo:      auditRepo,
 		eventPublisher: eventPublisher,
 	}
 }
 
-func (s *PaymentService) RecordPayment(ctx context.Context, orgID uuid.UUID, invoiceID uuid.UUID, amount float64, method string, ref string) (*domain.Payment, error) {
-	// 1. Get Invoice
-	invoice, err := s.invoiceRepo.GetByID(ctx, invoiceID)
+// RecordPayment applies a payment to an invoice. The invoice row is locked
+// while the payment is stored, so concurrent payments cannot lose each
+// other's update. A retry carrying the same idempotency key returns the
+// payment recorded the first time instead of charging the invoice again.
+// Whatever exceeds the amount due is kept as credit for the customer.
+func (s *PaymentService) RecordPayment(ctx context.Context, orgID uuid.UUID, invoiceID uuid.UUID, req dto.RecordPaymentRequest, performedBy string) (*dto.PaymentResponse, error) {
+	if req.PaymentMethod == domain.PaymentMethodCredit {
+		return nil, fmt.Errorf("%w: customer credit is applied through the customer's credit endpoint", domain.ErrInvalidInput)
+	}
+	return s.recordPayment(ctx, orgID, invoiceID, req, nil, nil, performedBy)
+}
+
+// recordPayment stores a payment under the invoice row lock. When customerID
+// is set the invoice must belong to that customer. fromDeposits lists what a
+// credit payment uses up of each held deposit; it is marked applied in the
+// same transaction.
+func (s *PaymentService) recordPayment(ctx context.Context, orgID uuid.UUID, invoiceID uuid.UUID, req dto.RecordPaymentRequest, customerID *uuid.UUID, fromDeposits map[uuid.UUID]money.Amount, performedBy string) (*dto.PaymentResponse, error) {
+	if len(req.IdempotencyKey) > 100 {
+		return nil, fmt.Errorf("%w: idempotency key is longer than 100 characters", domain.ErrInvalidInput)
+	}
+	sameRequest := func(p *domain.Payment) bool {
+		return p.InvoiceID != nil && *p.InvoiceID == invoiceID && p.PaymentMethod == req.PaymentMethod
+	}
+	if req.IdempotencyKey != "" {
+		if res, err := s.replayPayment(ctx, orgID, req.IdempotencyKey, sameRequest); !errors.Is(err, domain.ErrPaymentNotFound) {
+			return res, err
+		}
+	}
+
+	now := time.Now().UTC()
+	paidAt := now
+	if req.PaymentDate != nil {
+		paidAt = req.PaymentDate.UTC()
+	}
+
+	var discount money.Amount
+	oldStatuses := make(map[uuid.UUID]domain.InvoiceStatus)
+	payment, invoices, err := s.paymentRepo.Record(ctx, []uuid.UUID{invoiceID}, func(invoices []*domain.Invoice) (*domain.Payment, error) {
+		invoice := invoices[0]
+		if invoice.OrganizationID != orgID {
+			return nil, domain.ErrInvoiceNotFound
+		}
+		if customerID != nil && invoice.CustomerID != *customerID {
+			return nil, fmt.Errorf("%w: invoice %s belongs to another customer", domain.ErrInvalidInput, invoice.InvoiceNumber)
+		}
+		amount := invoice.Round(req.Amount)
+		if err := domain.ValidatePayment(invoice, amount, req.PaymentMethod, paidAt, now); err != nil {
+			return nil, err
+		}
+
+		payment := &domain.Payment{
+			ID:             uuid.New(),
+			OrganizationID: orgID,
+			CustomerID:     invoice.CustomerID,
+			InvoiceID:      &invoice.ID,
+			Currency:       invoice.CurrencyCode(),
+			Unapplied:      amount,
+			PaymentDate:    paidAt,
+			PaymentMethod:  req.PaymentMethod,
+			TransactionRef: req.TransactionRef,
+			Notes:          req.Notes,
+			Status:         domain.PaymentStatusCompleted,
+			FromDeposits:   fromDeposits,
+		}
+		if req.IdempotencyKey != "" {
+			key := req.IdempotencyKey
+			payment.IdempotencyKey = &key
+		}
+		// Paid within the payment term's discount period, the rest is forgiven
+		discount = invoice.EarlyPayDiscount(amount, paidAt)
+		if discount.IsPositive() {
+			invoice.TakeEarlyPayDiscount(discount)
+		}
+		allocation := domain.PaymentAllocation{InvoiceID: invoice.ID, Amount: money.Min(amount, invoice.AmountDue())}
+		return payment, allocatePayment(payment, []domain.PaymentAllocation{allocation}, invoices, oldStatuses, now, performedBy)
+	})
+	if errors.Is(err, domain.ErrPaymentAlreadyRecorded) {
+		// A retry of the same request committed while this one waited for the lock
+		return s.replayPayment(ctx, orgID, req.IdempotencyKey, sameRequest)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("invoice not found: %w", err)
+		return nil, err
 	}
 
-	// 2. Create Payment Record
-	payment := &domain.Payment{
+	if discount.IsPositive() {
+		s.recordEarlyPayDiscount(ctx, invoices[0], payment, discount, performedBy)
+	}
+	s.recordAllocationChanges(ctx, payment, nil, invoices, oldStatuses, fmt.Sprintf("payment %s recorded", payment.ID), performedBy)
+	s.publishPaymentCreated(ctx, payment)
+
+	res := mapPaymentToResponse(payment)
+	return &res, nil
+}
+
+func (s *PaymentService) recordEarlyPayDiscount(ctx context.Context, inv *domain.Invoice, p *domain.Payment, discount money.Amount, performedBy string) {
+	auditLog := &domain.InvoiceAuditLog{
 		ID:             uuid.New(),
-		OrganizationID: orgID,
-		InvoiceID:      invoiceID,
-		Amount:         amount,
-		PaymentDate:    time.Now().UTC(),
-		PaymentMethod:  method,
-		TransactionRef: ref,
+		OrganizationID: inv.OrganizationID,
+		InvoiceID:      inv.ID,
+		Action:         "early_payment_discount",
+		OldStatus:      string(inv.Status),
+		NewStatus:      string(inv.Status),
+		Notes: fmt.Sprintf("Early-payment discount of %s %s (%s%%) for payment %s made %s", discount.StringFixed(inv.CurrencyCode()),
+			inv.CurrencyCode(), inv.EarlyPayPct, p.ID, p.PaymentDate.Format("2006-01-02")),
+		PerformedBy: performedBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		log.Printf("Failed to audit early-payment discount of invoice %s: %v", inv.ID, err)
 	}
+}
+
+// allocatePayment moves the payment onto allocations and settles the status
+// of every invoice whose share changed. The statuses the invoices had before
+// are kept in oldStatuses. Statuses are worked out before anything is stored,
+// so payments against void invoices are rejected.
+func allocatePayment(p *domain.Payment, allocations []domain.PaymentAllocation, invoices []*domain.Invoice, oldStatuses map[uuid.UUID]domain.InvoiceStatus, now time.Time, performedBy string) error {
+	previous := make(map[uuid.UUID]money.Amount, len(p.Allocations))
+	for _, a := range p.Allocations {
+		previous[a.InvoiceID] = a.Amount
+	}
+	byID := make(map[uuid.UUID]*domain.Invoice, len(invoices))
+	for _, inv := range invoices {
+		byID[inv.ID] = inv
+		oldStatuses[inv.ID] = inv.Status
+	}
+
+	if err := p.Allocate(allocations, byID, performedBy); err != nil {
+		return err
+	}
+
+	for _, inv := range invoices {
+		if previous[inv.ID].Equal(p.AllocatedTo(inv.ID)) {
+			continue
+		}
+		// An early-payment discount only stands while the invoice is settled
+		if p.AllocatedTo(inv.ID).LessThan(previous[inv.ID]) {
+			inv.RevokeEarlyPayDiscount()
+		}
+		if err := inv.TransitionTo(inv.SettlementStatus(), domain.TriggerPayment, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if err := s.paymentRepo.Create(ctx, payment); err != nil {
+// recordAllocationChanges audits every invoice whose share of the payment
+// changed, followed by the status change it caused. Drafts the payment issued
+// are then handed to the issued callback.
+func (s *PaymentService) recordAllocationChanges(ctx context.Context, p *domain.Payment, previous []domain.PaymentAllocation, invoices []*domain.Invoice, oldStatuses map[uuid.UUID]domain.InvoiceStatus, notes string, performedBy string) {
+	before := make(map[uuid.UUID]money.Amount, len(previous))
+	for _, a := range previous {
+		before[a.InvoiceID] = a.Amount
+	}
+
+	for _, inv := range invoices {
+		was, now := before[inv.ID], p.AllocatedTo(inv.ID)
+		if was.Equal(now) {
+			continue
+		}
+
+		auditLog := &domain.InvoiceAuditLog{
+			ID:             uuid.New(),
+			OrganizationID: inv.OrganizationID,
+			InvoiceID:      inv.ID,
+			Action:         "payment_allocation",
+			OldStatus:      string(oldStatuses[inv.ID]),
+			NewStatus:      string(inv.Status),
+			Notes:          allocationNotes(p, was, now, notes),
+			PerformedBy:    performedBy,
+			CreatedAt:      time.Now().UTC(),
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			log.Printf("Failed to audit allocation of payment %s to invoice %s: %v", p.ID, inv.ID, err)
+		}
+
+		recordStatusChange(ctx, s.auditRepo, s.eventPublisher, inv, oldStatuses[inv.ID], notes, performedBy)
+		if oldStatuses[inv.ID] == domain.InvoiceStatusDraft && inv.Status != domain.InvoiceStatusDraft && s.issued != nil {
+			s.issued(ctx, inv, performedBy)
+		}
+	}
+}
+
+func allocationNotes(p *domain.Payment, was, now money.Amount, notes string) string {
+	text := fmt.Sprintf("payment %s allocation changed from %s to %s", p.ID, was, now)
+	if notes != "" {
+		text += ": " + notes
+	}
+	return text
+}
+
+// replayPayment returns the payment already recorded under an idempotency
+// key, or ErrPaymentNotFound if there is none. sameRequest tells whether the
+// stored payment was recorded by the request being retried.
+func (s *PaymentService) replayPayment(ctx context.Context, orgID uuid.UUID, key string, sameRequest func(p *domain.Payment) bool) (*dto.PaymentResponse, error) {
+	payment, err := s.paymentRepo.GetByIdempotencyKey(ctx, orgID, key)
+	if err != nil {
 		return nil, err
 	}
+	if !sameRequest(payment) {
+		return nil, fmt.Errorf("%w: key %s belongs to payment %s", domain.ErrIdempotencyKeyReused, key, payment.ID)
+	}
+	res := mapPaymentToResponse(payment)
+	return &res, nil
+}
 
-	// 3. Update Invoice Status
-	invoice.PaidAmount += amount
-	invoice.BalanceAmount = invoice.TotalAmount - invoice.PaidAmount
+func (s *PaymentService) ListInvoicePayments(ctx context.Context, invoiceID uuid.UUID) ([]dto.PaymentResponse, error) {
+	payments, err := s.paymentRepo.GetByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	return mapPaymentsToResponse(payments), nil
+}
 
-	if invoice.BalanceAmount <= 0 {
-		invoice.Status = domain.InvoiceStatusPaid
-		invoice.BalanceAmount = 0
-	} else if invoice.PaidAmount > 0 {
-		invoice.Status = domain.InvoiceStatusPartial
+func (s *PaymentService) ListPayments(ctx context.Context, orgID uuid.UUID, method string) ([]dto.PaymentResponse, error) {
+	filter := map[string]interface{}{"organization_id": orgID}
+	if method != "" {
+		filter["payment_method"] = method
+	}
+	payments, err := s.paymentRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
 	}
+	return mapPaymentsToResponse(payments), nil
+}
 
-	if err := s.invoiceRepo.Update(ctx, invoice); err != nil {
+// GetReceipt returns a payment with the state of the invoices it was applied to
+func (s *PaymentService) GetReceipt(ctx context.Context, id uuid.UUID) (*dto.PaymentReceiptResponse, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, id)
+	if err != nil {
 		return nil, err
 	}
 
-	// 4. Publish Event
-	s.publishPaymentCreated(payment)
+	res := &dto.PaymentReceiptResponse{
+		PaymentResponse: mapPaymentToResponse(payment),
+		Invoices:        make([]dto.ReceiptAllocation, 0, len(payment.Allocations)),
+	}
+	for _, a := range payment.Allocations {
+		invoice, err := s.invoiceRepo.GetByID(ctx, a.InvoiceID)
+		if err != nil {
+			return nil, err
+		}
+		res.Invoices = append(res.Invoices, dto.ReceiptAllocation{
+			InvoiceID:     invoice.ID,
+			InvoiceNumber: invoice.InvoiceNumber,
+			Allocated:     a.Amount,
+			InvoiceTotal:  invoice.TotalAmount,
+			PaidAmount:    invoice.PaidAmount,
+			BalanceAmount: invoice.BalanceAmount,
+			InvoiceStatus: string(invoice.Status),
+		})
+		if payment.InvoiceID != nil && *payment.InvoiceID == invoice.ID {
+			res.InvoiceNumber = invoice.InvoiceNumber
+			res.InvoiceTotal = invoice.TotalAmount
+			res.PaidAmount = invoice.PaidAmount
+			res.BalanceAmount = invoice.BalanceAmount
+			res.InvoiceStatus = string(invoice.Status)
+		}
+	}
+	if customer, err := s.rmRepo.GetCustomer(ctx, payment.CustomerID); err == nil && customer != nil {
+		res.Customer = &dto.CustomerResponse{
+			ID:          customer.ID,
+			DisplayName: customer.DisplayName,
+			CompanyName: customer.CompanyName,
+		}
+	}
+	return res, nil
+}
 
-	return payment, nil
+// paymentCreatedPayload extends the shared payload with the invoices the
+// payment was allocated to and the customer's credit position after it
+type paymentCreatedPayload struct {
+	shared_events.PaymentCreatedPayload
+	CustomerID            string
+	UnappliedAmount       float64
+	CustomerCreditBalance float64
+	Currency              string
+	Allocations           []domain.PaymentAllocationPayload
 }
 
-func (s *PaymentService) publishPaymentCreated(p *domain.Payment) {
-	payload := shared_events.PaymentCreatedPayload{
-		PaymentID:      p.ID.String(),
-		OrganizationID: p.OrganizationID.String(),
-		InvoiceID:      p.InvoiceID.String(),
+func (s *PaymentService) publishPaymentCreated(ctx context.Context, p *domain.Payment) {
+	creditBalance, err := s.creditRepo.GetBalance(ctx, p.OrganizationID, p.CustomerID, p.Currency)
+	if err != nil {
+		log.Printf("Failed to read credit balance of customer %s: %v", p.CustomerID, err)
+	}
+
+	var invoiceID string
+	if p.InvoiceID != nil {
+		invoiceID = p.InvoiceID.String()
+	}
+	payload := paymentCreatedPayload{
+		PaymentCreatedPayload: shared_events.PaymentCreatedPayload{
+			PaymentID:      p.ID.String(),
+			OrganizationID: p.OrganizationID.String(),
+			InvoiceID:      invoiceID,
+			Amount:         p.Received().Float64(),
+			PaymentDate:    p.PaymentDate,
+			PaymentMethod:  p.PaymentMethod,
+			ReferenceNo:    p.TransactionRef,
+			Status:         "completed",
+		},
+		CustomerID:            p.CustomerID.String(),
+		UnappliedAmount:       p.Unapplied.Float64(),
+		CustomerCreditBalance: creditBalance.Float64(),
+		Currency:              p.Currency,
+		Allocations:           mapAllocationsToPayload(p.Allocations),
+	}
+
+	metadata := shared_events.NewEventMetadata(shared_events.PaymentCreated, shared_events.AggregatePayment, p.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
+
+func mapAllocationsToPayload(allocations []domain.PaymentAllocation) []domain.PaymentAllocationPayload {
+	res := make([]domain.PaymentAllocationPayload, 0, len(allocations))
+	for _, a := range allocations {
+		res = append(res, domain.PaymentAllocationPayload{InvoiceID: a.InvoiceID.String(), Amount: a.Amount.Float64()})
+	}
+	return res
+}
+
+func mapPaymentToResponse(p *domain.Payment) dto.PaymentResponse {
+	res := dto.PaymentResponse{
+		ID:             p.ID,
+		CustomerID:     p.CustomerID,
+		InvoiceID:      p.InvoiceID,
+		Currency:       p.Currency,
 		Amount:         p.Amount,
+		Unapplied:      p.Unapplied,
 		PaymentDate:    p.PaymentDate,
 		PaymentMethod:  p.PaymentMethod,
-		ReferenceNo:    p.TransactionRef,
-		Status:         "completed",
+		TransactionRef: p.TransactionRef,
+		Notes:          p.Notes,
+		Refunded:       p.Refunded,
+		Status:         string(p.Status),
+		Allocations:    make([]dto.PaymentAllocationResponse, 0, len(p.Allocations)),
+		CreatedAt:      p.CreatedAt,
+	}
+	for _, a := range p.Allocations {
+		res.Allocations = append(res.Allocations, dto.PaymentAllocationResponse{
+			ID:        a.ID,
+			InvoiceID: a.InvoiceID,
+			Amount:    a.Amount,
+			CreatedBy: a.CreatedBy,
+			CreatedAt: a.CreatedAt,
+		})
 	}
+	return res
+}
 
-	metadata := shared_events.NewEventMetadata(shared_events.PaymentCreated, shared_events.AggregatePayment, p.ID.String())
-	s.eventPublisher.Publish(context.Background(), metadata, payload)
+func mapPaymentsToResponse(payments []domain.Payment) []dto.PaymentResponse {
+	res := make([]dto.PaymentResponse, 0, len(payments))
+	for i := range payments {
+		res = append(res, mapPaymentToResponse(&payments[i]))
+	}
+	return res
 }
@@ -0,0 +1,198 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// PaymentTermService manages an organization's payment terms and which
+// customer has which. Invoices pick their term up when they are created;
+// changing a term later leaves the invoices already created alone.
+type PaymentTermService struct {
+	termRepo domain.PaymentTermRepository
+}
+
+func NewPaymentTermService(termRepo domain.PaymentTermRepository) *PaymentTermService {
+	return &PaymentTermService{termRepo: termRepo}
+}
+
+func (s *PaymentTermService) CreateTerm(ctx context.Context, orgID uuid.UUID, req dto.CreatePaymentTermRequest) (*dto.PaymentTermResponse, error) {
+	term := &domain.PaymentTerm{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+	}
+	applyPaymentTermRequest(term, req)
+	if err := term.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.ensureCodeFree(ctx, term); err != nil {
+		return nil, err
+	}
+
+	if err := s.termRepo.CreateTerm(ctx, term); err != nil {
+		return nil, err
+	}
+	res := mapPaymentTermToResponse(term)
+	return &res, nil
+}
+
+func (s *PaymentTermService) UpdateTerm(ctx context.Context, orgID, id uuid.UUID, req dto.CreatePaymentTermRequest) (*dto.PaymentTermResponse, error) {
+	term, err := s.getTerm(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	applyPaymentTermRequest(term, req)
+	if err := term.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.ensureCodeFree(ctx, term); err != nil {
+		return nil, err
+	}
+
+	if err := s.termRepo.UpdateTerm(ctx, term); err != nil {
+		return nil, err
+	}
+	res := mapPaymentTermToResponse(term)
+	return &res, nil
+}
+
+// DeleteTerm removes a term. Its customers fall back to the default term.
+func (s *PaymentTermService) DeleteTerm(ctx context.Context, orgID, id uuid.UUID) error {
+	if _, err := s.getTerm(ctx, orgID, id); err != nil {
+		return err
+	}
+	return s.termRepo.DeleteTerm(ctx, id)
+}
+
+func (s *PaymentTermService) GetTerm(ctx context.Context, orgID, id uuid.UUID) (*dto.PaymentTermResponse, error) {
+	term, err := s.getTerm(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := mapPaymentTermToResponse(term)
+	return &res, nil
+}
+
+func (s *PaymentTermService) ListTerms(ctx context.Context, orgID uuid.UUID) ([]dto.PaymentTermResponse, error) {
+	terms, err := s.termRepo.ListTerms(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.PaymentTermResponse, 0, len(terms))
+	for i := range terms {
+		res = append(res, mapPaymentTermToResponse(&terms[i]))
+	}
+	return res, nil
+}
+
+// AddStandardTerms adds the standard catalog to the organization's terms,
+// skipping any code it already uses, and returns all of its terms
+func (s *PaymentTermService) AddStandardTerms(ctx context.Context, orgID uuid.UUID) ([]dto.PaymentTermResponse, error) {
+	for _, standard := range domain.StandardPaymentTerms {
+		_, err := s.termRepo.GetTermByCode(ctx, orgID, standard.Code)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, domain.ErrPaymentTermNotFound) {
+			return nil, err
+		}
+		term := standard
+		term.ID = uuid.New()
+		term.OrganizationID = orgID
+		if err := s.termRepo.CreateTerm(ctx, &term); err != nil {
+			return nil, fmt.Errorf("failed to add payment term %s: %w", term.Code, err)
+		}
+	}
+	return s.ListTerms(ctx, orgID)
+}
+
+func (s *PaymentTermService) GetCustomerTerm(ctx context.Context, orgID, customerID uuid.UUID) (*dto.CustomerPaymentTermResponse, error) {
+	term, err := s.termRepo.GetCustomerTerm(ctx, orgID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.CustomerPaymentTermResponse{CustomerID: customerID, PaymentTerm: mapPaymentTermToResponse(term)}, nil
+}
+
+func (s *PaymentTermService) AssignCustomerTerm(ctx context.Context, orgID, customerID uuid.UUID, req dto.AssignPaymentTermRequest) (*dto.CustomerPaymentTermResponse, error) {
+	term, err := s.getTerm(ctx, orgID, req.PaymentTermID)
+	if errors.Is(err, domain.ErrPaymentTermNotFound) {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	assignment := &domain.CustomerPaymentTerm{
+		OrganizationID: orgID,
+		CustomerID:     customerID,
+		PaymentTermID:  term.ID,
+		UpdatedAt:      time.Now().UTC(),
+	}
+	if err := s.termRepo.AssignCustomerTerm(ctx, assignment); err != nil {
+		return nil, err
+	}
+	return &dto.CustomerPaymentTermResponse{CustomerID: customerID, PaymentTerm: mapPaymentTermToResponse(term)}, nil
+}
+
+func (s *PaymentTermService) UnassignCustomerTerm(ctx context.Context, orgID, customerID uuid.UUID) error {
+	return s.termRepo.UnassignCustomerTerm(ctx, orgID, customerID)
+}
+
+func (s *PaymentTermService) getTerm(ctx context.Context, orgID, id uuid.UUID) (*domain.PaymentTerm, error) {
+	term, err := s.termRepo.GetTerm(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if term.OrganizationID != orgID {
+		return nil, domain.ErrPaymentTermNotFound
+	}
+	return term, nil
+}
+
+// ensureCodeFree fails when another term of the organization has the code
+func (s *PaymentTermService) ensureCodeFree(ctx context.Context, term *domain.PaymentTerm) error {
+	existing, err := s.termRepo.GetTermByCode(ctx, term.OrganizationID, term.Code)
+	if errors.Is(err, domain.ErrPaymentTermNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != term.ID {
+		return fmt.Errorf("%w: payment term code %s is already used", domain.ErrInvalidInput, term.Code)
+	}
+	return nil
+}
+
+func applyPaymentTermRequest(term *domain.PaymentTerm, req dto.CreatePaymentTermRequest) {
+	term.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	term.Name = strings.TrimSpace(req.Name)
+	term.Type = domain.PaymentTermType(req.Type)
+	term.Days = req.Days
+	term.DiscountPct = req.DiscountPct
+	term.DiscountDays = req.DiscountDays
+	term.IsDefault = req.IsDefault
+}
+
+func mapPaymentTermToResponse(t *domain.PaymentTerm) dto.PaymentTermResponse {
+	return dto.PaymentTermResponse{
+		ID:           t.ID,
+		Code:         t.Code,
+		Name:         t.Name,
+		Type:         string(t.Type),
+		Days:         t.Days,
+		DiscountPct:  t.DiscountPct,
+		DiscountDays: t.DiscountDays,
+		IsDefault:    t.IsDefault,
+		CreatedAt:    t.CreatedAt,
+		UpdatedAt:    t.UpdatedAt,
+	}
+}
@@ -0,0 +1,460 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// ProgressBillingService bills large jobs ahead of and during the work:
+// deposit invoices whose payments are held as credit for the final invoice,
+// and progress invoices for a percentage or milestone of a work order or
+// estimate. What a job has billed is tracked so it is never billed past its
+// total.
+type ProgressBillingService struct {
+	progressRepo   domain.ProgressBillingRepository
+	estimateRepo   domain.EstimateRepository
+	invoiceService *InvoiceService
+	auditRepo      domain.AuditLogRepository
+}
+
+func NewProgressBillingService(
+	progressRepo domain.ProgressBillingRepository,
+	estimateRepo domain.EstimateRepository,
+	invoiceService *InvoiceService,
+	auditRepo domain.AuditLogRepository,
+) *ProgressBillingService {
+	return &ProgressBillingService{
+		progressRepo:   progressRepo,
+		estimateRepo:   estimateRepo,
+		invoiceService: invoiceService,
+		auditRepo:      auditRepo,
+	}
+}
+
+// billingJob is the work order or estimate a deposit or progress invoice
+// bills, with the invoice fields taken from it
+type billingJob struct {
+	sourceType domain.BillingSourceType
+	id         uuid.UUID
+	label      string
+	currency   string       // Empty when the job does not fix one
+	contract   money.Amount // What the job bills before tax
+	header     dto.CreateInvoiceRequest
+}
+
+func (j *billingJob) source(kind domain.InvoiceKind) invoiceSource {
+	src := invoiceSource{Kind: kind}
+	if j.sourceType == domain.BillingSourceEstimate {
+		src.EstimateID = &j.id
+	} else {
+		src.WorkOrderID = &j.id
+	}
+	return src
+}
+
+// CreateDepositInvoice bills a deposit for a work order or estimate, or a
+// retainer for the customer when neither is given. Deposits of a job are
+// limited to its total.
+func (s *ProgressBillingService) CreateDepositInvoice(ctx context.Context, orgID uuid.UUID, req dto.CreateDepositRequest, performedBy string) (*dto.InvoiceResponse, error) {
+	if req.WorkOrderID != nil && req.EstimateID != nil {
+		return nil, fmt.Errorf("%w: a deposit is for a work order or an estimate, not both", domain.ErrInvalidInput)
+	}
+
+	var job *billingJob
+	var err error
+	switch {
+	case req.EstimateID != nil:
+		job, err = s.loadJob(ctx, orgID, domain.BillingSourceEstimate, *req.EstimateID)
+	case req.WorkOrderID != nil:
+		job, err = s.loadJob(ctx, orgID, domain.BillingSourceWorkOrder, *req.WorkOrderID)
+	default:
+		if req.CustomerID == uuid.Nil {
+			return nil, fmt.Errorf("%w: a retainer needs a customer", domain.ErrInvalidInput)
+		}
+		job = &billingJob{label: "Retainer", header: dto.CreateInvoiceRequest{CustomerID: req.CustomerID}}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.CustomerID != uuid.Nil && req.CustomerID != job.header.CustomerID {
+		return nil, fmt.Errorf("%w: %s belongs to another customer", domain.ErrInvalidInput, job.label)
+	}
+	currency, err := jobCurrency(job, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	round := func(a money.Amount) money.Amount { return a.Round(currency, domain.MoneyRounding) }
+	job.contract = round(job.contract)
+	amount, err := domain.ResolveBillAmount(job.contract, req.Percent, req.Amount, round)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "Retainer"
+	if job.sourceType != "" {
+		name = fmt.Sprintf("Deposit for %s", job.label)
+	}
+	description := req.Description
+	if description == "" && req.Percent.IsPositive() {
+		description = fmt.Sprintf("%s%% of %s %s", req.Percent, job.contract.StringFixed(currency), currency)
+	}
+
+	bill := &domain.ProgressBill{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		CustomerID:     job.header.CustomerID,
+		Kind:           domain.InvoiceKindDeposit,
+		Milestone:      name,
+		Percent:        req.Percent,
+		Amount:         amount,
+		Currency:       currency,
+	}
+	if job.sourceType != "" {
+		bill.SourceType = job.sourceType
+		bill.SourceID = &job.id
+	}
+	check := func(billed, deposited money.Amount) error {
+		if bill.SourceID == nil {
+			return nil
+		}
+		if err := domain.CheckBillable(job.contract, deposited, amount); err != nil {
+			return fmt.Errorf("deposits of %s: %w", job.label, err)
+		}
+		return nil
+	}
+
+	// Deposits are not taxed; the tax is due on the invoices that bill the work
+	item := dto.CreateInvoiceItem{ItemType: "service", Name: name, Description: description, Quantity: 1, UnitPrice: amount}
+	invReq := jobInvoiceRequest(job, currency, name, req.InvoiceDate, req.DueDate, req.PaymentTermID, req.PurchaseOrder, item)
+	return s.bill(ctx, orgID, job, bill, check, invReq, performedBy)
+}
+
+// CreateProgressInvoice bills a percentage or milestone of a work order or
+// estimate, or with Final whatever is left of it. A job billed this way can
+// no longer be invoiced in full, nor can a job already invoiced in full be
+// billed this way.
+func (s *ProgressBillingService) CreateProgressInvoice(ctx context.Context, orgID uuid.UUID, sourceType domain.BillingSourceType, sourceID uuid.UUID, req dto.CreateProgressInvoiceRequest, performedBy string) (*dto.InvoiceResponse, error) {
+	job, err := s.loadJob(ctx, orgID, sourceType, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if !job.contract.IsPositive() {
+		return nil, fmt.Errorf("%w: %s has no total to bill", domain.ErrInvalidInput, job.label)
+	}
+	currency, err := jobCurrency(job, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	round := func(a money.Amount) money.Amount { return a.Round(currency, domain.MoneyRounding) }
+	job.contract = round(job.contract)
+
+	var amount money.Amount
+	if req.Final {
+		if !req.Percent.IsZero() || !req.Amount.IsZero() {
+			return nil, fmt.Errorf("%w: a final bill takes no amount or percentage", domain.ErrInvalidInput)
+		}
+	} else if amount, err = domain.ResolveBillAmount(job.contract, req.Percent, req.Amount, round); err != nil {
+		return nil, err
+	}
+
+	name := req.Milestone
+	if name == "" && req.Percent.IsPositive() {
+		name = fmt.Sprintf("%s%% of %s", req.Percent, job.label)
+	}
+	if name == "" {
+		name = fmt.Sprintf("Progress billing of %s", job.label)
+	}
+
+	bill := &domain.ProgressBill{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		CustomerID:     job.header.CustomerID,
+		SourceType:     job.sourceType,
+		SourceID:       &job.id,
+		Kind:           domain.InvoiceKindProgress,
+		Milestone:      name,
+		Percent:        req.Percent,
+		Amount:         amount,
+		Currency:       currency,
+		Final:          req.Final,
+	}
+	check := func(billed, deposited money.Amount) error {
+		if bill.Final {
+			// Whatever is left, worked out under the lock
+			bill.Amount = job.contract.Sub(billed)
+			if !bill.Amount.IsPositive() {
+				return fmt.Errorf("%w: %s has been billed in full", domain.ErrOverBilling, job.label)
+			}
+			return nil
+		}
+		if err := domain.CheckBillable(job.contract, billed, bill.Amount); err != nil {
+			return fmt.Errorf("%s: %w", job.label, err)
+		}
+		bill.Final = billed.Add(bill.Amount).Equal(job.contract)
+		return nil
+	}
+
+	invReq := jobInvoiceRequest(job, currency, name, req.InvoiceDate, req.DueDate, req.PaymentTermID, req.PurchaseOrder,
+		dto.CreateInvoiceItem{ItemType: "service", Name: name, Description: job.header.Subject, Quantity: 1, TaxCode: req.TaxCode})
+	return s.bill(ctx, orgID, job, bill, check, invReq, performedBy)
+}
+
+// bill reserves the bill against its job, then creates its invoice. The
+// invoice line is priced from the bill, whose amount a final bill only
+// learns under the lock.
+func (s *ProgressBillingService) bill(ctx context.Context, orgID uuid.UUID, job *billingJob, bill *domain.ProgressBill, check func(billed, deposited money.Amount) error, invReq dto.CreateInvoiceRequest, performedBy string) (*dto.InvoiceResponse, error) {
+	if err := s.progressRepo.Reserve(ctx, bill, check); err != nil {
+		return nil, err
+	}
+
+	invReq.Items[0].UnitPrice = bill.Amount
+	invoice, err := s.invoiceService.createInvoice(ctx, orgID, invReq, job.source(bill.Kind))
+	if err != nil {
+		if releaseErr := s.progressRepo.Release(ctx, bill); releaseErr != nil {
+			fmt.Printf("failed to release progress bill %s: %v\n", bill.ID, releaseErr)
+		}
+		return nil, err
+	}
+	if err := s.progressRepo.LinkInvoice(ctx, bill.ID, invoice.ID); err != nil {
+		// The bill is counted against the job either way, so this is only logged
+		fmt.Printf("failed to link progress bill %s to invoice %s: %v\n", bill.ID, invoice.ID, err)
+	}
+
+	notes := fmt.Sprintf("Deposit of %s billed for %s", bill.Amount.StringFixed(bill.Currency), job.label)
+	if bill.Kind == domain.InvoiceKindProgress {
+		notes = fmt.Sprintf("Billed %s of %s", bill.Amount.StringFixed(bill.Currency), job.label)
+	}
+	if bill.Final {
+		notes += ", the final bill"
+	}
+	auditLog := &domain.InvoiceAuditLog{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		InvoiceID:      invoice.ID,
+		Action:         string(bill.Kind) + "_billed",
+		NewStatus:      string(invoice.Status),
+		Notes:          notes,
+		PerformedBy:    performedBy,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+		fmt.Printf("failed to create audit log: %v\n", err)
+	}
+
+	return s.invoiceService.mapToResponse(ctx, invoice), nil
+}
+
+// GetProgress reports how much of a work order or estimate has been billed
+// in deposits and progress invoices
+func (s *ProgressBillingService) GetProgress(ctx context.Context, orgID uuid.UUID, sourceType domain.BillingSourceType, sourceID uuid.UUID) (*dto.ProgressSummaryResponse, error) {
+	job, err := s.loadJob(ctx, orgID, sourceType, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	bills, err := s.progressRepo.ListBySource(ctx, sourceType, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := job.currency
+	if currency == "" && len(bills) > 0 {
+		currency = bills[0].Currency
+	}
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	job.contract = job.contract.Round(currency, domain.MoneyRounding)
+
+	res := &dto.ProgressSummaryResponse{
+		SourceType:     string(sourceType),
+		SourceID:       sourceID,
+		Currency:       currency,
+		ContractAmount: job.contract,
+		Bills:          make([]dto.ProgressBillResponse, 0, len(bills)),
+	}
+	for _, b := range bills {
+		item := s.mapBillToResponse(ctx, &b)
+		res.Bills = append(res.Bills, item)
+		if item.Status == string(domain.InvoiceStatusVoid) {
+			continue
+		}
+		if b.Kind == domain.InvoiceKindDeposit {
+			res.DepositAmount = res.DepositAmount.Add(b.Amount)
+			res.DepositHeld = res.DepositHeld.Add(b.Held)
+			res.DepositApplied = res.DepositApplied.Add(b.Applied)
+		} else {
+			res.BilledAmount = res.BilledAmount.Add(b.Amount)
+		}
+	}
+	res.RemainingAmount = money.Max(job.contract.Sub(res.BilledAmount), money.Zero)
+	return res, nil
+}
+
+// ListDeposits returns the customer's deposits and retainers with what is
+// held of each
+func (s *ProgressBillingService) ListDeposits(ctx context.Context, orgID, customerID uuid.UUID) ([]dto.ProgressBillResponse, error) {
+	bills, err := s.progressRepo.ListDeposits(ctx, orgID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.ProgressBillResponse, 0, len(bills))
+	for _, b := range bills {
+		res = append(res, s.mapBillToResponse(ctx, &b))
+	}
+	return res, nil
+}
+
+func (s *ProgressBillingService) loadJob(ctx context.Context, orgID uuid.UUID, sourceType domain.BillingSourceType, id uuid.UUID) (*billingJob, error) {
+	switch sourceType {
+	case domain.BillingSourceEstimate:
+		estimate, err := s.estimateRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if estimate.OrganizationID != orgID {
+			return nil, domain.ErrEstimateNotFound
+		}
+		if status := estimate.CurrentStatus(time.Now().UTC()); status != domain.EstimateStatusSent && status != domain.EstimateStatusAccepted {
+			return nil, fmt.Errorf("%w: only sent or accepted estimates can be billed, %s is %s", domain.ErrInvalidEstimateTransition, estimate.EstimateNumber, status)
+		}
+		return &billingJob{
+			sourceType: sourceType,
+			id:         estimate.ID,
+			label:      estimate.EstimateNumber,
+			currency:   estimate.Currency,
+			contract:   estimate.TotalAmount.Sub(estimate.TaxTotal),
+			header: dto.CreateInvoiceRequest{
+				Subject:         estimate.Subject,
+				CustomerID:      estimate.CustomerID,
+				ContactID:       estimate.ContactID,
+				OwnerID:         estimate.OwnerID,
+				ReferenceNo:     estimate.EstimateNumber,
+				Terms:           estimate.Terms,
+				BillingStreet:   estimate.BillingStreet,
+				BillingCity:     estimate.BillingCity,
+				BillingState:    estimate.BillingState,
+				BillingCode:     estimate.BillingCode,
+				BillingCountry:  estimate.BillingCountry,
+				ShippingStreet:  estimate.ShippingStreet,
+				ShippingCity:    estimate.ShippingCity,
+				ShippingState:   estimate.ShippingState,
+				ShippingCode:    estimate.ShippingCode,
+				ShippingCountry: estimate.ShippingCountry,
+			},
+		}, nil
+	case domain.BillingSourceWorkOrder:
+		wo, err := s.invoiceService.rmRepo.GetWorkOrder(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if wo.OrganizationID != orgID {
+			return nil, domain.ErrWorkOrderNotFound
+		}
+		if wo.CustomerID == nil || *wo.CustomerID == uuid.Nil {
+			return nil, fmt.Errorf("%w: work order %s has no customer", domain.ErrInvalidInput, id)
+		}
+		// Billed in full, the work order's lines come out at this before tax
+		contract := money.FromFloat(wo.Adjustment - wo.Discount)
+		serviceLines, err := s.invoiceService.rmRepo.ListWorkOrderServiceLines(ctx, wo.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range serviceLines {
+			contract = contract.Add(money.FromFloat(line.ListPrice).Mul(line.Quantity))
+		}
+		partLines, err := s.invoiceService.rmRepo.ListWorkOrderPartLines(ctx, wo.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range partLines {
+			contract = contract.Add(money.FromFloat(line.ListPrice).Mul(line.Quantity))
+		}
+
+		header := dto.CreateInvoiceRequest{
+			Subject:        wo.Summary,
+			CustomerID:     *wo.CustomerID,
+			ContactID:      wo.ContactID,
+			ReferenceNo:    wo.ID.String(),
+			BillingStreet:  wo.BillingAddress,
+			ShippingStreet: wo.ServiceAddress,
+		}
+		if wo.ContactID != nil && *wo.ContactID == uuid.Nil {
+			header.ContactID = nil
+		}
+		return &billingJob{
+			sourceType: sourceType,
+			id:         wo.ID,
+			label:      fmt.Sprintf("work order %s", wo.ID),
+			contract:   contract,
+			header:     header,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown billing source %q", domain.ErrInvalidInput, sourceType)
+}
+
+// jobCurrency returns the currency to bill a job in. Estimates fix their own;
+// otherwise the requested one is used, defaulting to USD.
+func jobCurrency(job *billingJob, requested string) (string, error) {
+	requested = strings.ToUpper(requested)
+	if job.currency == "" {
+		if requested == "" {
+			return money.DefaultCurrency, nil
+		}
+		return requested, nil
+	}
+	currency := strings.ToUpper(job.currency)
+	if requested != "" && requested != currency {
+		return "", fmt.Errorf("%w: %s is billed in %s", domain.ErrInvalidInput, job.label, currency)
+	}
+	return currency, nil
+}
+
+func jobInvoiceRequest(job *billingJob, currency, subject string, invoiceDate, dueDate *time.Time, paymentTermID *uuid.UUID, purchaseOrder string, item dto.CreateInvoiceItem) dto.CreateInvoiceRequest {
+	req := job.header
+	req.Subject = subject
+	req.Currency = currency
+	req.InvoiceDate = time.Now().UTC()
+	if invoiceDate != nil {
+		req.InvoiceDate = *invoiceDate
+	}
+	if dueDate != nil {
+		req.DueDate = *dueDate
+	}
+	req.PaymentTermID = paymentTermID
+	req.PurchaseOrder = purchaseOrder
+	req.Items = []dto.CreateInvoiceItem{item}
+	return req
+}
+
+func (s *ProgressBillingService) mapBillToResponse(ctx context.Context, b *domain.ProgressBill) dto.ProgressBillResponse {
+	res := dto.ProgressBillResponse{
+		ID:         b.ID,
+		Kind:       string(b.Kind),
+		SourceType: string(b.SourceType),
+		SourceID:   b.SourceID,
+		InvoiceID:  b.InvoiceID,
+		Milestone:  b.Milestone,
+		Percent:    b.Percent,
+		Amount:     b.Amount,
+		Currency:   b.Currency,
+		Final:      b.Final,
+		Held:       b.Held,
+		Applied:    b.Applied,
+		CreatedAt:  b.CreatedAt,
+	}
+	if b.InvoiceID != nil {
+		if inv, err := s.invoiceService.invoiceRepo.GetByID(ctx, *b.InvoiceID); err == nil && inv != nil {
+			res.InvoiceNo = inv.InvoiceNumber
+			res.Status = string(inv.Status)
+		}
+	}
+	return res
+}
@@ -0,0 +1,400 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// maxCatchUpRuns bounds how many missed occurrences of one profile are
+// generated in a single scheduler tick after downtime.
+const maxCatchUpRuns = 12
+
+type RecurringInvoiceService struct {
+	profileRepo    domain.RecurringProfileRepository
+	invoiceService *InvoiceService
+}
+
+func NewRecurringInvoiceService(
+	profileRepo domain.RecurringProfileRepository,
+	invoiceService *InvoiceService,
+) *RecurringInvoiceService {
+	return &RecurringInvoiceService{
+		profileRepo:    profileRepo,
+		invoiceService: invoiceService,
+	}
+}
+
+func (s *RecurringInvoiceService) CreateProfile(ctx context.Context, orgID uuid.UUID, req dto.CreateRecurringProfileRequest) (*dto.RecurringProfileResponse, error) {
+	profile := &domain.RecurringProfile{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Status:         domain.RecurringProfileActive,
+	}
+	applyProfileRequest(profile, req)
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	profile.ScheduleNext()
+
+	if err := s.profileRepo.Create(ctx, profile); err != nil {
+		return nil, err
+	}
+	return mapProfileToResponse(profile), nil
+}
+
+// UpdateProfile changes the template and schedule. Occurrences already
+// generated are kept; the next run is recomputed from the new schedule.
+func (s *RecurringInvoiceService) UpdateProfile(ctx context.Context, id uuid.UUID, req dto.CreateRecurringProfileRequest) (*dto.RecurringProfileResponse, error) {
+	profile, err := s.profileRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Status == domain.RecurringProfileCancelled {
+		return nil, fmt.Errorf("%w: profile is cancelled", domain.ErrInvalidInput)
+	}
+
+	applyProfileRequest(profile, req)
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if profile.Status == domain.RecurringProfileCompleted {
+		profile.Status = domain.RecurringProfileActive
+	}
+	profile.SkipMissed(time.Now().UTC())
+
+	if err := s.profileRepo.Update(ctx, profile); err != nil {
+		return nil, err
+	}
+	return mapProfileToResponse(profile), nil
+}
+
+// SetStatus pauses, resumes or cancels a profile
+func (s *RecurringInvoiceService) SetStatus(ctx context.Context, id uuid.UUID, status domain.RecurringProfileStatus) (*dto.RecurringProfileResponse, error) {
+	profile, err := s.profileRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case domain.RecurringProfilePaused, domain.RecurringProfileCancelled:
+		if profile.Status == domain.RecurringProfileCancelled {
+			return nil, fmt.Errorf("%w: profile is cancelled", domain.ErrInvalidInput)
+		}
+		profile.Status = status
+	case domain.RecurringProfileActive:
+		if profile.Status != domain.RecurringProfilePaused {
+			return nil, fmt.Errorf("%w: only paused profiles can be resumed", domain.ErrInvalidInput)
+		}
+		// Occurrences missed while paused are skipped, not back-filled
+		profile.Status = domain.RecurringProfileActive
+		profile.SkipMissed(time.Now().UTC())
+	default:
+		return nil, fmt.Errorf("%w: unsupported profile status %q", domain.ErrInvalidInput, status)
+	}
+
+	if err := s.profileRepo.Update(ctx, profile); err != nil {
+		return nil, err
+	}
+	return mapProfileToResponse(profile), nil
+}
+
+func (s *RecurringInvoiceService) GetProfile(ctx context.Context, id uuid.UUID) (*dto.RecurringProfileResponse, error) {
+	profile, err := s.profileRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mapProfileToResponse(profile), nil
+}
+
+func (s *RecurringInvoiceService) ListProfiles(ctx context.Context, orgID uuid.UUID) ([]dto.RecurringProfileResponse, error) {
+	profiles, err := s.profileRepo.List(ctx, map[string]interface{}{"organization_id": orgID})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.RecurringProfileResponse, 0, len(profiles))
+	for _, p := range profiles {
+		res = append(res, *mapProfileToResponse(&p))
+	}
+	return res, nil
+}
+
+func (s *RecurringInvoiceService) ListRuns(ctx context.Context, profileID uuid.UUID) ([]dto.RecurringRunResponse, error) {
+	runs, err := s.profileRepo.ListRuns(ctx, profileID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.RecurringRunResponse, 0, len(runs))
+	for _, run := range runs {
+		res = append(res, dto.RecurringRunResponse{
+			ID:           run.ID,
+			ProfileID:    run.ProfileID,
+			ScheduledFor: run.ScheduledFor,
+			Status:       string(run.Status),
+			InvoiceID:    run.InvoiceID,
+			Error:        run.Error,
+			CreatedAt:    run.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+// RunDue generates invoices for every occurrence that is due at now and
+// returns how many invoices were created.
+func (s *RecurringInvoiceService) RunDue(ctx context.Context, now time.Time) (int, error) {
+	profiles, err := s.profileRepo.ListDue(ctx, now, 100)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due recurring profiles: %w", err)
+	}
+
+	generated := 0
+	for i := range profiles {
+		profile := &profiles[i]
+		for n := 0; n < maxCatchUpRuns && profile.NextRunAt != nil && !profile.NextRunAt.After(now); n++ {
+			created, err := s.runOccurrence(ctx, profile, *profile.NextRunAt)
+			if err != nil {
+				// Leave the occurrence in place so it is retried on the next tick
+				log.Printf("Recurring profile %s: failed to generate invoice for %s: %v", profile.ID, profile.NextRunAt.Format(time.RFC3339), err)
+				break
+			}
+			if created {
+				generated++
+			}
+
+			fromCount := profile.OccurrenceCount
+			profile.LastRunAt = profile.NextRunAt
+			profile.OccurrenceCount++
+			profile.ScheduleNext()
+			// Only the schedule is written, so a pause, cancel or edit made
+			// while the run was executing is kept
+			advanced, err := s.profileRepo.AdvanceSchedule(ctx, profile, fromCount)
+			if err != nil {
+				log.Printf("Recurring profile %s: failed to advance schedule: %v", profile.ID, err)
+				break
+			}
+			if !advanced {
+				log.Printf("Recurring profile %s: changed while running, schedule left as it is", profile.ID)
+				break
+			}
+		}
+	}
+	return generated, nil
+}
+
+// runOccurrence claims the occurrence and creates its invoice. It returns
+// false without error when the occurrence had already been claimed.
+func (s *RecurringInvoiceService) runOccurrence(ctx context.Context, profile *domain.RecurringProfile, scheduledFor time.Time) (bool, error) {
+	run := &domain.RecurringRun{
+		ID:             uuid.New(),
+		OrganizationID: profile.OrganizationID,
+		ProfileID:      profile.ID,
+		ScheduledFor:   scheduledFor,
+		Status:         domain.RecurringRunPending,
+	}
+	claimed, err := s.profileRepo.ClaimRun(ctx, run)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim run: %w", err)
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	// A run reclaimed after a crash may already have stored its invoice; the
+	// unique run ID on invoices keeps it from being generated twice
+	invoice, err := s.invoiceService.invoiceRepo.GetByRunID(ctx, run.ID)
+	if errors.Is(err, domain.ErrInvoiceNotFound) {
+		// The customer's payment term takes precedence over the template's due days
+		src := invoiceSource{DefaultDueDate: scheduledFor.AddDate(0, 0, profile.Template.DueInDays), RunID: &run.ID}
+		invoice, err = s.invoiceService.createInvoice(ctx, profile.OrganizationID, profileInvoiceRequest(profile, scheduledFor), src)
+	}
+	if err != nil {
+		run.Status = domain.RecurringRunFailed
+		run.Error = err.Error()
+		if updateErr := s.profileRepo.UpdateRun(ctx, run); updateErr != nil {
+			log.Printf("Recurring run %s: failed to record failure: %v", run.ID, updateErr)
+		}
+		return false, err
+	}
+
+	run.Status = domain.RecurringRunGenerated
+	run.InvoiceID = &invoice.ID
+	if err := s.profileRepo.UpdateRun(ctx, run); err != nil {
+		// The run stays pending and is reclaimed after its lease, finding this invoice
+		return false, fmt.Errorf("failed to link invoice %s: %w", invoice.ID, err)
+	}
+
+	if profile.AutoSend && invoice.Status == domain.InvoiceStatusDraft {
+		notes := fmt.Sprintf("Sent automatically by recurring profile %s", profile.Name)
+		if err := s.invoiceService.UpdateStatus(ctx, invoice.ID, domain.InvoiceStatusSent, notes, "Recurring Scheduler"); err != nil {
+			log.Printf("Recurring run %s: failed to send invoice %s: %v", run.ID, invoice.ID, err)
+		}
+	}
+	return true, nil
+}
+
+func profileInvoiceRequest(profile *domain.RecurringProfile, invoiceDate time.Time) dto.CreateInvoiceRequest {
+	t := profile.Template
+	req := dto.CreateInvoiceRequest{
+		Subject:         t.Subject,
+		CustomerID:      profile.CustomerID,
+		ContactID:       t.ContactID,
+		OwnerID:         t.OwnerID,
+		InvoiceDate:     invoiceDate,
+		ReferenceNo:     t.ReferenceNo,
+		PurchaseOrder:   t.PurchaseOrder,
+		Currency:        t.Currency,
+		Adjustment:      t.Adjustment,
+		Discount:        t.Discount,
+		DiscountPct:     t.DiscountPct,
+		TaxInclusive:    t.TaxInclusive,
+		Terms:           t.Terms,
+		Notes:           t.Notes,
+		BillingStreet:   t.BillingStreet,
+		BillingCity:     t.BillingCity,
+		BillingState:    t.BillingState,
+		BillingCode:     t.BillingCode,
+		BillingCountry:  t.BillingCountry,
+		ShippingStreet:  t.ShippingStreet,
+		ShippingCity:    t.ShippingCity,
+		ShippingState:   t.ShippingState,
+		ShippingCode:    t.ShippingCode,
+		ShippingCountry: t.ShippingCountry,
+		Items:           make([]dto.CreateInvoiceItem, 0, len(t.Items)),
+	}
+	for _, item := range t.Items {
+		req.Items = append(req.Items, dto.CreateInvoiceItem{
+			ItemID:      item.ItemID,
+			ItemType:    item.ItemType,
+			Name:        item.Name,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Discount:    item.Discount,
+			DiscountPct: item.DiscountPct,
+			TaxCode:     item.TaxCode,
+			Tax:         item.Tax,
+		})
+	}
+	return req
+}
+
+func applyProfileRequest(profile *domain.RecurringProfile, req dto.CreateRecurringProfileRequest) {
+	t := req.Template
+	profile.Name = req.Name
+	profile.CustomerID = req.CustomerID
+	profile.Frequency = domain.RecurringFrequency(req.Frequency)
+	profile.CronExpression = req.CronExpression
+	profile.StartDate = req.StartDate
+	profile.EndDate = req.EndDate
+	profile.AutoSend = req.AutoSend
+	profile.Template = domain.InvoiceTemplate{
+		Subject:         t.Subject,
+		ContactID:       t.ContactID,
+		OwnerID:         t.OwnerID,
+		ReferenceNo:     t.ReferenceNo,
+		PurchaseOrder:   t.PurchaseOrder,
+		Currency:        t.Currency,
+		DueInDays:       t.DueInDays,
+		Adjustment:      t.Adjustment,
+		Discount:        t.Discount,
+		DiscountPct:     t.DiscountPct,
+		TaxInclusive:    t.TaxInclusive,
+		Terms:           t.Terms,
+		Notes:           t.Notes,
+		BillingStreet:   t.BillingStreet,
+		BillingCity:     t.BillingCity,
+		BillingState:    t.BillingState,
+		BillingCode:     t.BillingCode,
+		BillingCountry:  t.BillingCountry,
+		ShippingStreet:  t.ShippingStreet,
+		ShippingCity:    t.ShippingCity,
+		ShippingState:   t.ShippingState,
+		ShippingCode:    t.ShippingCode,
+		ShippingCountry: t.ShippingCountry,
+		Items:           make([]domain.InvoiceTemplateItem, 0, len(t.Items)),
+	}
+	for _, item := range t.Items {
+		profile.Template.Items = append(profile.Template.Items, domain.InvoiceTemplateItem{
+			ItemID:      item.ItemID,
+			ItemType:    item.ItemType,
+			Name:        item.Name,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Discount:    item.Discount,
+			DiscountPct: item.DiscountPct,
+			TaxCode:     item.TaxCode,
+			Tax:         item.Tax,
+		})
+	}
+}
+
+func mapProfileToResponse(profile *domain.RecurringProfile) *dto.RecurringProfileResponse {
+	t := profile.Template
+	res := &dto.RecurringProfileResponse{
+		ID:              profile.ID,
+		Name:            profile.Name,
+		CustomerID:      profile.CustomerID,
+		Frequency:       string(profile.Frequency),
+		CronExpression:  profile.CronExpression,
+		StartDate:       profile.StartDate,
+		EndDate:         profile.EndDate,
+		NextRunAt:       profile.NextRunAt,
+		LastRunAt:       profile.LastRunAt,
+		OccurrenceCount: profile.OccurrenceCount,
+		AutoSend:        profile.AutoSend,
+		Status:          string(profile.Status),
+		CreatedAt:       profile.CreatedAt,
+		UpdatedAt:       profile.UpdatedAt,
+		Template: dto.RecurringInvoiceTemplate{
+			Subject:         t.Subject,
+			ContactID:       t.ContactID,
+			OwnerID:         t.OwnerID,
+			ReferenceNo:     t.ReferenceNo,
+			PurchaseOrder:   t.PurchaseOrder,
+			Currency:        t.Currency,
+			DueInDays:       t.DueInDays,
+			Adjustment:      t.Adjustment,
+			Discount:        t.Discount,
+			DiscountPct:     t.DiscountPct,
+			TaxInclusive:    t.TaxInclusive,
+			Terms:           t.Terms,
+			Notes:           t.Notes,
+			BillingStreet:   t.BillingStreet,
+			BillingCity:     t.BillingCity,
+			BillingState:    t.BillingState,
+			BillingCode:     t.BillingCode,
+			BillingCountry:  t.BillingCountry,
+			ShippingStreet:  t.ShippingStreet,
+			ShippingCity:    t.ShippingCity,
+			ShippingState:   t.ShippingState,
+			ShippingCode:    t.ShippingCode,
+			ShippingCountry: t.ShippingCountry,
+			Items:           make([]dto.CreateInvoiceItem, 0, len(t.Items)),
+		},
+	}
+	for _, item := range t.Items {
+		res.Template.Items = append(res.Template.Items, dto.CreateInvoiceItem{
+			ItemID:      item.ItemID,
+			ItemType:    item.ItemType,
+			Name:        item.Name,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Discount:    item.Discount,
+			DiscountPct: item.DiscountPct,
+			TaxCode:     item.TaxCode,
+			Tax:         item.Tax,
+		})
+	}
+	return res
+}
@@ -0,0 +1,81 @@
+package application
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RecurringScheduler periodically generates invoices for due recurring
+// profiles and closes the subscription periods that have ended. Running
+// several instances is safe: each occurrence and period is claimed in the
+// database before its invoice is created.
+type RecurringScheduler struct {
+	service       *RecurringInvoiceService
+	subscriptions *SubscriptionService
+	interval      time.Duration
+	cancel        context.CancelFunc
+	done          chan struct{}
+}
+
+func NewRecurringScheduler(service *RecurringInvoiceService, subscriptions *SubscriptionService, interval time.Duration) *RecurringScheduler {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &RecurringScheduler{
+		service:       service,
+		subscriptions: subscriptions,
+		interval:      interval,
+	}
+}
+
+// Start runs the scheduler in the background until Stop is called
+func (s *RecurringScheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.tick(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Recurring invoice scheduler started (interval %s)", s.interval)
+}
+
+// Stop signals the scheduler to finish and waits for the current tick
+func (s *RecurringScheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+func (s *RecurringScheduler) tick(ctx context.Context) {
+	now := time.Now().UTC()
+	generated, err := s.service.RunDue(ctx, now)
+	if err != nil {
+		log.Printf("Recurring invoice scheduler: %v", err)
+	} else if generated > 0 {
+		log.Printf("Recurring invoice scheduler generated %d invoice(s)", generated)
+	}
+
+	billed, err := s.subscriptions.RunDue(ctx, now)
+	if err != nil {
+		log.Printf("Recurring invoice scheduler: subscriptions: %v", err)
+		return
+	}
+	if billed > 0 {
+		log.Printf("Recurring invoice scheduler billed %d subscription period(s)", billed)
+	}
+}
@@ -0,0 +1,660 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionService sells plans with metered components. Usage is recorded
+// against the current period of a subscription, and when the period closes
+// its base fee and usage are billed through the invoice pipeline.
+type SubscriptionService struct {
+	subscriptionRepo domain.SubscriptionRepository
+	invoiceService   *InvoiceService
+	auditRepo        domain.AuditLogRepository
+}
+
+func NewSubscriptionService(
+	subscriptionRepo domain.SubscriptionRepository,
+	invoiceService *InvoiceService,
+	auditRepo domain.AuditLogRepository,
+) *SubscriptionService {
+	return &SubscriptionService{
+		subscriptionRepo: subscriptionRepo,
+		invoiceService:   invoiceService,
+		auditRepo:        auditRepo,
+	}
+}
+
+func (s *SubscriptionService) CreatePlan(ctx context.Context, orgID uuid.UUID, req dto.CreatePlanRequest) (*dto.PlanResponse, error) {
+	now := time.Now().UTC()
+	plan := &domain.SubscriptionPlan{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Code:           strings.TrimSpace(req.Code),
+		Name:           strings.TrimSpace(req.Name),
+		Currency:       strings.ToUpper(req.Currency),
+		Interval:       domain.RecurringFrequency(req.Interval),
+		BaseFee:        req.BaseFee,
+		ItemID:         req.ItemID,
+		TaxCode:        req.TaxCode,
+		Active:         true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if plan.Currency == "" {
+		plan.Currency = "USD"
+	}
+	if plan.Interval == "" {
+		plan.Interval = domain.RecurringMonthly
+	}
+	for _, c := range req.Components {
+		component := domain.PlanComponent{
+			ID:      uuid.New(),
+			PlanID:  plan.ID,
+			Metric:  strings.TrimSpace(c.Metric),
+			Name:    c.Name,
+			Unit:    c.Unit,
+			ItemID:  c.ItemID,
+			TaxCode: c.TaxCode,
+			Pricing: domain.PricingModel(c.Pricing),
+		}
+		if component.Name == "" {
+			component.Name = component.Metric
+		}
+		if component.Pricing == "" {
+			component.Pricing = domain.PricingFlat
+		}
+		for _, t := range c.Tiers {
+			component.Tiers = append(component.Tiers, domain.PriceTier{UpTo: t.UpTo, UnitPrice: t.UnitPrice, FlatFee: t.FlatFee})
+		}
+		plan.Components = append(plan.Components, component)
+	}
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.subscriptionRepo.CreatePlan(ctx, plan); err != nil {
+		return nil, err
+	}
+	res := mapPlanToResponse(plan)
+	return &res, nil
+}
+
+func (s *SubscriptionService) UpdatePlan(ctx context.Context, orgID, id uuid.UUID, req dto.UpdatePlanRequest) (*dto.PlanResponse, error) {
+	plan, err := s.getPlan(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		plan.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Active != nil {
+		plan.Active = *req.Active
+	}
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan.UpdatedAt = time.Now().UTC()
+	if err := s.subscriptionRepo.UpdatePlan(ctx, plan); err != nil {
+		return nil, err
+	}
+	res := mapPlanToResponse(plan)
+	return &res, nil
+}
+
+func (s *SubscriptionService) GetPlan(ctx context.Context, orgID, id uuid.UUID) (*dto.PlanResponse, error) {
+	plan, err := s.getPlan(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := mapPlanToResponse(plan)
+	return &res, nil
+}
+
+func (s *SubscriptionService) ListPlans(ctx context.Context, orgID uuid.UUID) ([]dto.PlanResponse, error) {
+	plans, err := s.subscriptionRepo.ListPlans(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.PlanResponse, 0, len(plans))
+	for i := range plans {
+		res = append(res, mapPlanToResponse(&plans[i]))
+	}
+	return res, nil
+}
+
+// getPlan loads a plan, hiding plans of other organizations
+func (s *SubscriptionService) getPlan(ctx context.Context, orgID, id uuid.UUID) (*domain.SubscriptionPlan, error) {
+	plan, err := s.subscriptionRepo.GetPlan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan.OrganizationID != orgID {
+		return nil, domain.ErrPlanNotFound
+	}
+	return plan, nil
+}
+
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, orgID uuid.UUID, req dto.CreateSubscriptionRequest) (*dto.SubscriptionResponse, error) {
+	plan, err := s.getPlan(ctx, orgID, req.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	start := now
+	if req.StartDate != nil {
+		start = *req.StartDate
+	}
+	sub := &domain.Subscription{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		CustomerID:     req.CustomerID,
+		PaymentTermID:  req.PaymentTermID,
+		PurchaseOrder:  req.PurchaseOrder,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := sub.Begin(plan, start); err != nil {
+		return nil, err
+	}
+
+	if err := s.subscriptionRepo.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	res := mapSubscriptionToResponse(sub, plan)
+	return &res, nil
+}
+
+func (s *SubscriptionService) GetSubscription(ctx context.Context, orgID, id uuid.UUID) (*dto.SubscriptionResponse, error) {
+	sub, err := s.getSubscription(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.subscriptionRepo.GetPlan(ctx, sub.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	res := mapSubscriptionToResponse(sub, plan)
+	return &res, nil
+}
+
+// ListSubscriptions lists the organization's subscriptions, or one customer's
+func (s *SubscriptionService) ListSubscriptions(ctx context.Context, orgID uuid.UUID, customerID *uuid.UUID) ([]dto.SubscriptionResponse, error) {
+	filter := map[string]interface{}{"organization_id": orgID}
+	if customerID != nil {
+		filter["customer_id"] = *customerID
+	}
+	subs, err := s.subscriptionRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.SubscriptionResponse, 0, len(subs))
+	for i := range subs {
+		res = append(res, mapSubscriptionToResponse(&subs[i], nil))
+	}
+	return res, nil
+}
+
+// ChangePlan moves the subscription to another plan. The period the change
+// falls in is billed on both plans, each for its share of the days.
+func (s *SubscriptionService) ChangePlan(ctx context.Context, orgID, id uuid.UUID, req dto.ChangePlanRequest) (*dto.SubscriptionResponse, error) {
+	sub, err := s.getSubscription(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.subscriptionRepo.GetPlan(ctx, sub.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getPlan(ctx, orgID, req.PlanID)
+	if err != nil {
+		return nil, err
+	}
+
+	effectiveAt := time.Now().UTC()
+	if req.EffectiveAt != nil {
+		effectiveAt = *req.EffectiveAt
+	}
+	change, err := sub.ChangePlan(from, to, effectiveAt)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	change.CreatedAt = now
+	sub.UpdatedAt = now
+
+	if err := s.subscriptionRepo.ChangePlan(ctx, sub, change); err != nil {
+		return nil, err
+	}
+	res := mapSubscriptionToResponse(sub, to)
+	return &res, nil
+}
+
+// Cancel ends the subscription. Its last period is billed when it closes.
+func (s *SubscriptionService) Cancel(ctx context.Context, orgID, id uuid.UUID, req dto.CancelSubscriptionRequest) (*dto.SubscriptionResponse, error) {
+	sub, err := s.getSubscription(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	var at time.Time
+	if req.EndsAt != nil {
+		at = *req.EndsAt
+	}
+	if err := sub.Cancel(at); err != nil {
+		return nil, err
+	}
+
+	sub.UpdatedAt = time.Now().UTC()
+	if err := s.subscriptionRepo.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	res := mapSubscriptionToResponse(sub, nil)
+	return &res, nil
+}
+
+// RecordUsage records usage reported over the API. A retried request with the
+// same idempotency key returns the usage recorded the first time.
+func (s *SubscriptionService) RecordUsage(ctx context.Context, orgID, id uuid.UUID, req dto.RecordUsageRequest) (*dto.UsageRecordResponse, error) {
+	sub, err := s.getSubscription(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	occurredAt := time.Now().UTC()
+	if req.OccurredAt != nil {
+		occurredAt = *req.OccurredAt
+	}
+	record, err := sub.NewUsage(req.Metric, req.Quantity, occurredAt, req.IdempotencyKey, domain.UsageSourceAPI)
+	if err != nil {
+		return nil, err
+	}
+	record.CreatedAt = time.Now().UTC()
+
+	inserted, err := s.subscriptionRepo.RecordUsage(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+	res := mapUsageToResponse(record)
+	res.Duplicate = !inserted
+	return &res, nil
+}
+
+// ListUsage lists the usage recorded in the current period
+func (s *SubscriptionService) ListUsage(ctx context.Context, orgID, id uuid.UUID) ([]dto.UsageRecordResponse, error) {
+	sub, err := s.getSubscription(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.subscriptionRepo.ListUsage(ctx, sub.ID, sub.CurrentPeriodStart, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.UsageRecordResponse, 0, len(records))
+	for i := range records {
+		res = append(res, mapUsageToResponse(&records[i]))
+	}
+	return res, nil
+}
+
+func (s *SubscriptionService) ListRuns(ctx context.Context, orgID, id uuid.UUID) ([]dto.SubscriptionRunResponse, error) {
+	sub, err := s.getSubscription(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := s.subscriptionRepo.ListRuns(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.SubscriptionRunResponse, 0, len(runs))
+	for i := range runs {
+		res = append(res, mapSubscriptionRunToResponse(&runs[i]))
+	}
+	return res, nil
+}
+
+// ClosePeriod bills the current period of the subscription now, provided it
+// has come to an end. The scheduler does the same for every due subscription.
+func (s *SubscriptionService) ClosePeriod(ctx context.Context, orgID, id uuid.UUID, performedBy string) (*dto.SubscriptionRunResponse, error) {
+	sub, err := s.getSubscription(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if sub.Status != domain.SubscriptionActive {
+		return nil, fmt.Errorf("%w: subscription has ended", domain.ErrInvalidInput)
+	}
+	if sub.ClosesAt().After(now) {
+		return nil, fmt.Errorf("%w: the current period closes on %s", domain.ErrInvalidInput, sub.ClosesAt().Format("2006-01-02"))
+	}
+
+	run, err := s.closePeriod(ctx, sub, performedBy)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, fmt.Errorf("%w: the period is already being billed", domain.ErrInvalidInput)
+	}
+	res := mapSubscriptionRunToResponse(run)
+	return &res, nil
+}
+
+// RunDue bills every subscription period that has closed by now and returns
+// how many invoices were created.
+func (s *SubscriptionService) RunDue(ctx context.Context, now time.Time) (int, error) {
+	subs, err := s.subscriptionRepo.ListDue(ctx, now, 100)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+
+	generated := 0
+	for i := range subs {
+		sub := &subs[i]
+		for n := 0; n < maxCatchUpRuns && sub.Status == domain.SubscriptionActive && !sub.ClosesAt().After(now); n++ {
+			run, err := s.closePeriod(ctx, sub, "Subscription Scheduler")
+			if err != nil {
+				// The run is left failed so the period is retried on the next tick
+				log.Printf("Subscription %s: failed to bill period of %s: %v", sub.ID, sub.CurrentPeriodStart.Format("2006-01-02"), err)
+				break
+			}
+			if run == nil {
+				break
+			}
+			if run.InvoiceID != nil {
+				generated++
+			}
+		}
+	}
+	return generated, nil
+}
+
+// closePeriod claims the current period of the subscription, invoices its
+// charges and moves the subscription on to its next period. It returns nil
+// without error when the period had already been claimed.
+func (s *SubscriptionService) closePeriod(ctx context.Context, sub *domain.Subscription, performedBy string) (*domain.SubscriptionRun, error) {
+	periodStart := sub.CurrentPeriodStart
+	now := time.Now().UTC()
+	run := &domain.SubscriptionRun{
+		ID:             uuid.New(),
+		OrganizationID: sub.OrganizationID,
+		SubscriptionID: sub.ID,
+		PeriodStart:    periodStart,
+		PeriodEnd:      sub.ClosesAt(),
+		Status:         domain.RecurringRunPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	claimed, err := s.subscriptionRepo.ClaimRun(ctx, run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim run: %w", err)
+	}
+	if !claimed {
+		return nil, nil
+	}
+	if err := s.discardStaleInvoice(ctx, run); err != nil {
+		return nil, s.failRun(ctx, run, err)
+	}
+
+	changes, err := s.subscriptionRepo.ListPlanChanges(ctx, sub.ID, periodStart)
+	if err != nil {
+		return nil, s.failRun(ctx, run, err)
+	}
+	segments := sub.PeriodSegments(changes)
+	plans := make(map[uuid.UUID]*domain.SubscriptionPlan, len(segments)+1)
+	for _, planID := range append([]uuid.UUID{sub.PlanID}, segmentPlanIDs(segments)...) {
+		if _, ok := plans[planID]; ok {
+			continue
+		}
+		plan, err := s.subscriptionRepo.GetPlan(ctx, planID)
+		if err != nil {
+			return nil, s.failRun(ctx, run, err)
+		}
+		plans[planID] = plan
+	}
+
+	// The final period also picks up usage reported after its nominal end
+	before := sub.CurrentPeriodEnd
+	if sub.IsFinalPeriod() {
+		before = time.Time{}
+	}
+	usage, err := s.subscriptionRepo.ListUnbilledUsage(ctx, sub.ID, periodStart, before)
+	if err != nil {
+		return nil, s.failRun(ctx, run, err)
+	}
+	charges, err := sub.PeriodCharges(segments, plans, usage)
+	if err != nil {
+		return nil, s.failRun(ctx, run, err)
+	}
+
+	var invoice *domain.Invoice
+	if len(charges) > 0 {
+		req := s.periodInvoiceRequest(ctx, sub, plans, charges)
+		invoice, err = s.invoiceService.createInvoice(ctx, sub.OrganizationID, req, invoiceSource{Kind: domain.InvoiceKindSubscription, RunID: &run.ID})
+		if err != nil {
+			return nil, s.failRun(ctx, run, err)
+		}
+		run.InvoiceID = &invoice.ID
+	}
+
+	usageIDs := make([]uuid.UUID, 0, len(usage))
+	for _, u := range usage {
+		usageIDs = append(usageIDs, u.ID)
+	}
+	closed := *sub
+	closed.AdvancePeriod(plans[sub.PlanID].Interval)
+	closed.UpdatedAt = time.Now().UTC()
+	run.Status = domain.RecurringRunGenerated
+	run.UpdatedAt = closed.UpdatedAt
+	if err := s.subscriptionRepo.ClosePeriod(ctx, &closed, periodStart, run, usageIDs); err != nil {
+		// Nothing was marked billed, so the invoice is taken back
+		if invoice != nil {
+			if deleteErr := s.invoiceService.invoiceRepo.Delete(ctx, invoice.ID); deleteErr != nil {
+				fmt.Printf("failed to delete subscription invoice %s: %v\n", invoice.InvoiceNumber, deleteErr)
+			}
+		}
+		run.InvoiceID = nil
+		return nil, s.failRun(ctx, run, err)
+	}
+	*sub = closed
+
+	if invoice != nil {
+		auditLog := &domain.InvoiceAuditLog{
+			ID:             uuid.New(),
+			OrganizationID: invoice.OrganizationID,
+			InvoiceID:      invoice.ID,
+			Action:         "subscription_billed",
+			NewStatus:      string(invoice.Status),
+			Notes: fmt.Sprintf("Subscription %s billed for %s to %s with %d usage record(s)", sub.ID,
+				periodStart.Format("2006-01-02"), run.PeriodEnd.AddDate(0, 0, -1).Format("2006-01-02"), len(usage)),
+			PerformedBy: performedBy,
+			CreatedAt:   time.Now().UTC(),
+		}
+		if err := s.auditRepo.Create(ctx, auditLog); err != nil {
+			fmt.Printf("failed to create audit log: %v\n", err)
+		}
+	}
+	return run, nil
+}
+
+// discardStaleInvoice deletes the invoice an earlier attempt at a reclaimed
+// run created before it stopped. That attempt never closed the period, so its
+// usage is still unbilled and the invoice is built again from scratch. An
+// invoice that has been issued meanwhile cannot be taken back.
+func (s *SubscriptionService) discardStaleInvoice(ctx context.Context, run *domain.SubscriptionRun) error {
+	stale, err := s.invoiceService.invoiceRepo.GetByRunID(ctx, run.ID)
+	if errors.Is(err, domain.ErrInvoiceNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stale.IsLocked() {
+		return fmt.Errorf("%w: invoice %s of an earlier attempt has been issued", domain.ErrInvalidInput, stale.InvoiceNumber)
+	}
+	return s.invoiceService.invoiceRepo.Delete(ctx, stale.ID)
+}
+
+// periodInvoiceRequest builds the invoice of a closed period, a group of lines
+// per plan the period was spent on
+func (s *SubscriptionService) periodInvoiceRequest(ctx context.Context, sub *domain.Subscription, plans map[uuid.UUID]*domain.SubscriptionPlan, charges []domain.SubscriptionCharge) dto.CreateInvoiceRequest {
+	plan := plans[sub.PlanID]
+	closesAt := sub.ClosesAt()
+	period := sub.CurrentPeriodStart.Format("2006-01-02") + " to " + closesAt.AddDate(0, 0, -1).Format("2006-01-02")
+
+	req := dto.CreateInvoiceRequest{
+		Subject:       plan.Name + " subscription " + period,
+		CustomerID:    sub.CustomerID,
+		InvoiceDate:   closesAt,
+		PaymentTermID: sub.PaymentTermID,
+		ReferenceNo:   period,
+		PurchaseOrder: sub.PurchaseOrder,
+		Currency:      plan.Currency,
+		Items:         make([]dto.CreateInvoiceItem, 0, len(charges)),
+	}
+	for _, c := range charges {
+		itemID := uuid.Nil
+		if c.ItemID != nil {
+			itemID = *c.ItemID
+		}
+		segmentPlan := plans[c.Segment.PlanID]
+		req.Items = append(req.Items, dto.CreateInvoiceItem{
+			ItemID:      itemID,
+			ItemType:    "service",
+			Name:        c.Name,
+			Description: c.Description,
+			Quantity:    c.Quantity,
+			UnitPrice:   c.UnitPrice,
+			TaxCode:     c.TaxCode,
+			GroupLabel: fmt.Sprintf("%s, %s to %s", segmentPlan.Name,
+				c.Segment.Start.Format("2006-01-02"), c.Segment.End.AddDate(0, 0, -1).Format("2006-01-02")),
+			SourceID: &sub.ID,
+		})
+	}
+	s.invoiceService.addressToCustomer(ctx, &req)
+	return req
+}
+
+// failRun records why the period could not be billed, so it is retried
+func (s *SubscriptionService) failRun(ctx context.Context, run *domain.SubscriptionRun, cause error) error {
+	run.Status = domain.RecurringRunFailed
+	run.Error = cause.Error()
+	run.UpdatedAt = time.Now().UTC()
+	if err := s.subscriptionRepo.UpdateRun(ctx, run); err != nil {
+		log.Printf("Subscription run %s: failed to record failure: %v", run.ID, err)
+	}
+	return cause
+}
+
+// getSubscription loads a subscription, hiding those of other organizations
+func (s *SubscriptionService) getSubscription(ctx context.Context, orgID, id uuid.UUID) (*domain.Subscription, error) {
+	sub, err := s.subscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.OrganizationID != orgID {
+		return nil, domain.ErrSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+func segmentPlanIDs(segments []domain.PlanSegment) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(segments))
+	for _, seg := range segments {
+		ids = append(ids, seg.PlanID)
+	}
+	return ids
+}
+
+func mapPlanToResponse(plan *domain.SubscriptionPlan) dto.PlanResponse {
+	res := dto.PlanResponse{
+		ID:         plan.ID,
+		Code:       plan.Code,
+		Name:       plan.Name,
+		Currency:   plan.Currency,
+		Interval:   string(plan.Interval),
+		BaseFee:    plan.BaseFee,
+		ItemID:     plan.ItemID,
+		TaxCode:    plan.TaxCode,
+		Active:     plan.Active,
+		Components: make([]dto.PlanComponentResponse, 0, len(plan.Components)),
+		CreatedAt:  plan.CreatedAt,
+		UpdatedAt:  plan.UpdatedAt,
+	}
+	for _, c := range plan.Components {
+		component := dto.PlanComponentResponse{
+			ID:      c.ID,
+			Metric:  c.Metric,
+			Name:    c.Name,
+			Unit:    c.Unit,
+			ItemID:  c.ItemID,
+			TaxCode: c.TaxCode,
+			Pricing: string(c.Pricing),
+			Tiers:   make([]dto.PriceTierRequest, 0, len(c.Tiers)),
+		}
+		for _, t := range c.Tiers {
+			component.Tiers = append(component.Tiers, dto.PriceTierRequest{UpTo: t.UpTo, UnitPrice: t.UnitPrice, FlatFee: t.FlatFee})
+		}
+		res.Components = append(res.Components, component)
+	}
+	return res
+}
+
+func mapSubscriptionToResponse(sub *domain.Subscription, plan *domain.SubscriptionPlan) dto.SubscriptionResponse {
+	res := dto.SubscriptionResponse{
+		ID:                 sub.ID,
+		CustomerID:         sub.CustomerID,
+		PlanID:             sub.PlanID,
+		PlanSince:          sub.PlanSince,
+		Status:             string(sub.Status),
+		StartDate:          sub.StartDate,
+		CurrentPeriodStart: sub.CurrentPeriodStart,
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd,
+		EndsAt:             sub.EndsAt,
+		PaymentTermID:      sub.PaymentTermID,
+		PurchaseOrder:      sub.PurchaseOrder,
+		CreatedAt:          sub.CreatedAt,
+		UpdatedAt:          sub.UpdatedAt,
+	}
+	if plan != nil {
+		p := mapPlanToResponse(plan)
+		res.Plan = &p
+	}
+	return res
+}
+
+func mapUsageToResponse(record *domain.UsageRecord) dto.UsageRecordResponse {
+	return dto.UsageRecordResponse{
+		ID:             record.ID,
+		SubscriptionID: record.SubscriptionID,
+		Metric:         record.Metric,
+		Quantity:       record.Quantity,
+		OccurredAt:     record.OccurredAt,
+		IdempotencyKey: record.IdempotencyKey,
+		Source:         string(record.Source),
+		InvoiceID:      record.InvoiceID,
+		CreatedAt:      record.CreatedAt,
+	}
+}
+
+func mapSubscriptionRunToResponse(run *domain.SubscriptionRun) dto.SubscriptionRunResponse {
+	return dto.SubscriptionRunResponse{
+		ID:          run.ID,
+		PeriodStart: run.PeriodStart,
+		PeriodEnd:   run.PeriodEnd,
+		Status:      string(run.Status),
+		InvoiceID:   run.InvoiceID,
+		Error:       run.Error,
+		CreatedAt:   run.CreatedAt,
+		UpdatedAt:   run.UpdatedAt,
+	}
+}
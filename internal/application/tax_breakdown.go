@@ -0,0 +1,60 @@
+package application
+
+import (
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// manualTaxName labels the breakdown row for tax amounts entered by hand
+const manualTaxName = "Manual tax"
+
+// taxBreakdown sums line taxes into one row per tax rate, in the order the
+// rates first appear on the invoice
+type taxBreakdown struct {
+	invoiceID uuid.UUID
+	rows      []domain.InvoiceTax
+	index     map[uuid.UUID]int
+	manual    int
+}
+
+func newTaxBreakdown(invoiceID uuid.UUID) *taxBreakdown {
+	return &taxBreakdown{invoiceID: invoiceID, index: make(map[uuid.UUID]int), manual: -1}
+}
+
+func (b *taxBreakdown) addRate(t domain.TaxLine) {
+	i, ok := b.index[t.Rate.ID]
+	if !ok {
+		rateID := t.Rate.ID
+		b.rows = append(b.rows, domain.InvoiceTax{
+			ID:        uuid.New(),
+			InvoiceID: b.invoiceID,
+			TaxRateID: &rateID,
+			Code:      t.Rate.Code,
+			Name:      t.Rate.Name,
+			Rate:      t.Rate.Rate,
+			Compound:  t.Rate.Compound,
+		})
+		i = len(b.rows) - 1
+		b.index[t.Rate.ID] = i
+	}
+	b.rows[i].TaxableAmount = b.rows[i].TaxableAmount.Add(t.Taxable)
+	b.rows[i].TaxAmount = b.rows[i].TaxAmount.Add(t.Amount)
+}
+
+func (b *taxBreakdown) addManual(taxable, tax money.Amount) {
+	if tax.IsZero() {
+		return
+	}
+	if b.manual < 0 {
+		b.rows = append(b.rows, domain.InvoiceTax{
+			ID:        uuid.New(),
+			InvoiceID: b.invoiceID,
+			Name:      manualTaxName,
+		})
+		b.manual = len(b.rows) - 1
+	}
+	b.rows[b.manual].TaxableAmount = b.rows[b.manual].TaxableAmount.Add(taxable)
+	b.rows[b.manual].TaxAmount = b.rows[b.manual].TaxAmount.Add(tax)
+}
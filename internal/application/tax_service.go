@@ -0,0 +1,235 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// TaxService manages the tax rates and tax groups that invoice lines refer
+// to by code. Rate and group codes share one namespace per organization.
+type TaxService struct {
+	taxRepo domain.TaxRepository
+}
+
+func NewTaxService(taxRepo domain.TaxRepository) *TaxService {
+	return &TaxService{taxRepo: taxRepo}
+}
+
+func (s *TaxService) CreateRate(ctx context.Context, orgID uuid.UUID, req dto.CreateTaxRateRequest) (*dto.TaxRateResponse, error) {
+	rate := &domain.TaxRate{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+	}
+	applyTaxRateRequest(rate, req)
+	if err := rate.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.ensureCodeFree(ctx, orgID, rate.Code, rate.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.taxRepo.CreateRate(ctx, rate); err != nil {
+		return nil, err
+	}
+	res := mapTaxRateToResponse(rate)
+	return &res, nil
+}
+
+// UpdateRate changes a rate. Invoices already issued keep the tax they were
+// computed with; only invoices created or updated afterwards use the new rate.
+func (s *TaxService) UpdateRate(ctx context.Context, id uuid.UUID, req dto.CreateTaxRateRequest) (*dto.TaxRateResponse, error) {
+	rate, err := s.taxRepo.GetRateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	applyTaxRateRequest(rate, req)
+	if err := rate.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.ensureCodeFree(ctx, rate.OrganizationID, rate.Code, rate.ID); err != nil {
+		return nil, err
+	}
+
+	if err := s.taxRepo.UpdateRate(ctx, rate); err != nil {
+		return nil, err
+	}
+	res := mapTaxRateToResponse(rate)
+	return &res, nil
+}
+
+func (s *TaxService) GetRate(ctx context.Context, id uuid.UUID) (*dto.TaxRateResponse, error) {
+	rate, err := s.taxRepo.GetRateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	res := mapTaxRateToResponse(rate)
+	return &res, nil
+}
+
+func (s *TaxService) ListRates(ctx context.Context, orgID uuid.UUID) ([]dto.TaxRateResponse, error) {
+	rates, err := s.taxRepo.ListRates(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.TaxRateResponse, 0, len(rates))
+	for i := range rates {
+		res = append(res, mapTaxRateToResponse(&rates[i]))
+	}
+	return res, nil
+}
+
+func (s *TaxService) CreateGroup(ctx context.Context, orgID uuid.UUID, req dto.CreateTaxGroupRequest) (*dto.TaxGroupResponse, error) {
+	group := &domain.TaxGroup{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+	}
+	if err := s.applyTaxGroupRequest(ctx, group, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.taxRepo.CreateGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	return mapTaxGroupToResponse(group), nil
+}
+
+func (s *TaxService) UpdateGroup(ctx context.Context, id uuid.UUID, req dto.CreateTaxGroupRequest) (*dto.TaxGroupResponse, error) {
+	group, err := s.taxRepo.GetGroupByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyTaxGroupRequest(ctx, group, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.taxRepo.UpdateGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	return mapTaxGroupToResponse(group), nil
+}
+
+func (s *TaxService) GetGroup(ctx context.Context, id uuid.UUID) (*dto.TaxGroupResponse, error) {
+	group, err := s.taxRepo.GetGroupByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mapTaxGroupToResponse(group), nil
+}
+
+func (s *TaxService) ListGroups(ctx context.Context, orgID uuid.UUID) ([]dto.TaxGroupResponse, error) {
+	groups, err := s.taxRepo.ListGroups(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.TaxGroupResponse, 0, len(groups))
+	for i := range groups {
+		res = append(res, *mapTaxGroupToResponse(&groups[i]))
+	}
+	return res, nil
+}
+
+func (s *TaxService) applyTaxGroupRequest(ctx context.Context, group *domain.TaxGroup, req dto.CreateTaxGroupRequest) error {
+	if req.Code == "" || req.Name == "" {
+		return fmt.Errorf("%w: tax group needs a code and a name", domain.ErrInvalidInput)
+	}
+	if len(req.RateIDs) == 0 {
+		return fmt.Errorf("%w: tax group needs at least one rate", domain.ErrInvalidInput)
+	}
+	if err := s.ensureCodeFree(ctx, group.OrganizationID, req.Code, group.ID); err != nil {
+		return err
+	}
+
+	components := make([]domain.TaxGroupComponent, 0, len(req.RateIDs))
+	seen := make(map[uuid.UUID]bool, len(req.RateIDs))
+	for i, rateID := range req.RateIDs {
+		if seen[rateID] {
+			return fmt.Errorf("%w: tax rate %s is listed twice", domain.ErrInvalidInput, rateID)
+		}
+		seen[rateID] = true
+
+		rate, err := s.taxRepo.GetRateByID(ctx, rateID)
+		if err != nil || rate.OrganizationID != group.OrganizationID {
+			return fmt.Errorf("%w: unknown tax rate %s", domain.ErrInvalidInput, rateID)
+		}
+		components = append(components, domain.TaxGroupComponent{
+			ID:         uuid.New(),
+			TaxGroupID: group.ID,
+			TaxRateID:  rate.ID,
+			Sequence:   i + 1,
+			TaxRate:    rate,
+		})
+	}
+
+	group.Code = req.Code
+	group.Name = req.Name
+	group.Active = req.Active == nil || *req.Active
+	group.Components = components
+	return nil
+}
+
+// ensureCodeFree rejects a code already used by another rate or group of the organization
+func (s *TaxService) ensureCodeFree(ctx context.Context, orgID uuid.UUID, code string, ownerID uuid.UUID) error {
+	rates, err := s.taxRepo.ListRates(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	for _, r := range rates {
+		if r.Code == code && r.ID != ownerID {
+			return fmt.Errorf("%w: tax code %s is already used by rate %s", domain.ErrInvalidInput, code, r.Name)
+		}
+	}
+	groups, err := s.taxRepo.ListGroups(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if g.Code == code && g.ID != ownerID {
+			return fmt.Errorf("%w: tax code %s is already used by group %s", domain.ErrInvalidInput, code, g.Name)
+		}
+	}
+	return nil
+}
+
+func applyTaxRateRequest(rate *domain.TaxRate, req dto.CreateTaxRateRequest) {
+	rate.Code = req.Code
+	rate.Name = req.Name
+	rate.Rate = req.Rate
+	rate.Compound = req.Compound
+	rate.Active = req.Active == nil || *req.Active
+}
+
+func mapTaxRateToResponse(rate *domain.TaxRate) dto.TaxRateResponse {
+	return dto.TaxRateResponse{
+		ID:        rate.ID,
+		Code:      rate.Code,
+		Name:      rate.Name,
+		Rate:      rate.Rate,
+		Compound:  rate.Compound,
+		Active:    rate.Active,
+		CreatedAt: rate.CreatedAt,
+		UpdatedAt: rate.UpdatedAt,
+	}
+}
+
+func mapTaxGroupToResponse(group *domain.TaxGroup) *dto.TaxGroupResponse {
+	res := &dto.TaxGroupResponse{
+		ID:        group.ID,
+		Code:      group.Code,
+		Name:      group.Name,
+		Active:    group.Active,
+		Rates:     make([]dto.TaxRateResponse, 0, len(group.Components)),
+		CreatedAt: group.CreatedAt,
+		UpdatedAt: group.UpdatedAt,
+	}
+	for _, c := range group.Components {
+		if c.TaxRate != nil {
+			res.Rates = append(res.Rates, mapTaxRateToResponse(c.TaxRate))
+		}
+	}
+	return res
+}
@@ -0,0 +1,199 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// defaultWorkOrderDueDays is used when neither the request nor a payment
+// term gives a due date
+const defaultWorkOrderDueDays = 30
+
+// CreateInvoiceFromWorkOrder bills a work order: its customer, contact,
+// billing address and every service and part line are copied into a new
+// draft invoice linked back to the work order. A work order can only be
+// invoiced once until that invoice is voided.
+func (s *InvoiceService) CreateInvoiceFromWorkOrder(ctx context.Context, orgID, workOrderID uuid.UUID, req dto.CreateInvoiceFromWorkOrderRequest) (*dto.InvoiceResponse, error) {
+	wo, err := s.rmRepo.GetWorkOrder(ctx, workOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if wo.OrganizationID != orgID {
+		return nil, fmt.Errorf("%w: %s", domain.ErrWorkOrderNotFound, workOrderID)
+	}
+	if wo.CustomerID == nil || *wo.CustomerID == uuid.Nil {
+		return nil, fmt.Errorf("%w: work order %s has no customer", domain.ErrInvalidInput, workOrderID)
+	}
+	if err := wo.CheckBillable(); err != nil {
+		return nil, err
+	}
+
+	invReq, err := s.invoiceRequestFromWorkOrder(ctx, wo, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reserve the work order before creating the invoice so that concurrent
+	// requests cannot both bill it
+	reserved, err := s.rmRepo.ReserveWorkOrderForBilling(ctx, wo.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		return nil, fmt.Errorf("%w: %s", domain.ErrWorkOrderAlreadyInvoiced, workOrderID)
+	}
+
+	src := invoiceSource{
+		WorkOrderID:    &wo.ID,
+		DefaultDueDate: invReq.InvoiceDate.AddDate(0, 0, defaultWorkOrderDueDays),
+	}
+	invoice, err := s.createInvoice(ctx, wo.OrganizationID, invReq, src)
+	if err != nil {
+		if releaseErr := s.rmRepo.ReleaseWorkOrderBilling(ctx, wo.ID); releaseErr != nil {
+			fmt.Printf("failed to release work order %s: %v\n", wo.ID, releaseErr)
+		}
+		return nil, err
+	}
+
+	if err := s.rmRepo.LinkWorkOrderInvoice(ctx, wo.ID, invoice.ID); err != nil {
+		// The invoice already carries the work order, so this is only logged
+		fmt.Printf("failed to link work order %s to invoice %s: %v\n", wo.ID, invoice.ID, err)
+	}
+
+	s.publishWorkOrderInvoiced(wo, invoice)
+
+	return s.mapToResponse(ctx, invoice), nil
+}
+
+func (s *InvoiceService) invoiceRequestFromWorkOrder(ctx context.Context, wo *domain.WorkOrderRM, req dto.CreateInvoiceFromWorkOrderRequest) (dto.CreateInvoiceRequest, error) {
+	items, err := s.workOrderItems(ctx, wo)
+	if err != nil {
+		return dto.CreateInvoiceRequest{}, err
+	}
+
+	invoiceDate := time.Now().UTC()
+	if req.InvoiceDate != nil {
+		invoiceDate = *req.InvoiceDate
+	}
+	// Without a due date the customer's payment term sets it
+	var dueDate time.Time
+	if req.DueDate != nil {
+		dueDate = *req.DueDate
+	}
+	subject := req.Subject
+	if subject == "" {
+		subject = wo.Summary
+	}
+
+	invReq := dto.CreateInvoiceRequest{
+		Subject:       subject,
+		CustomerID:    *wo.CustomerID,
+		ContactID:     wo.ContactID,
+		OwnerID:       req.OwnerID,
+		InvoiceDate:   invoiceDate,
+		DueDate:       dueDate,
+		ReferenceNo:   wo.ID.String(),
+		Currency:      req.Currency,
+		Adjustment:    money.FromFloat(wo.Adjustment),
+		Discount:      money.FromFloat(wo.Discount),
+		Terms:         req.Terms,
+		Notes:         req.Notes,
+		BillingStreet: wo.BillingAddress,
+	}
+	if wo.ContactID != nil && *wo.ContactID == uuid.Nil {
+		invReq.ContactID = nil
+	}
+
+	// The work order only carries a free-form billing address; fall back to
+	// the structured address of the customer when it is empty
+	if customer, err := s.rmRepo.GetCustomer(ctx, *wo.CustomerID); err == nil && customer != nil {
+		if invReq.BillingStreet == "" {
+			invReq.BillingStreet = customer.BillingStreet
+			invReq.BillingCity = customer.BillingCity
+			invReq.BillingState = customer.BillingState
+			invReq.BillingCode = customer.BillingCode
+			invReq.BillingCountry = customer.BillingCountry
+		}
+		invReq.ShippingStreet = customer.ShippingStreet
+		invReq.ShippingCity = customer.ShippingCity
+		invReq.ShippingState = customer.ShippingState
+		invReq.ShippingCode = customer.ShippingCode
+		invReq.ShippingCountry = customer.ShippingCountry
+	}
+	if wo.ServiceAddress != "" {
+		invReq.ShippingStreet = wo.ServiceAddress
+		invReq.ShippingCity, invReq.ShippingState, invReq.ShippingCode, invReq.ShippingCountry = "", "", "", ""
+	}
+
+	invReq.Items = items
+	for i := range invReq.Items {
+		invReq.Items[i].TaxCode = req.TaxCode
+	}
+
+	return invReq, nil
+}
+
+// workOrderItems turns every service and part line of the work order into an
+// invoice line
+func (s *InvoiceService) workOrderItems(ctx context.Context, wo *domain.WorkOrderRM) ([]dto.CreateInvoiceItem, error) {
+	serviceLines, err := s.rmRepo.ListWorkOrderServiceLines(ctx, wo.ID)
+	if err != nil {
+		return nil, err
+	}
+	partLines, err := s.rmRepo.ListWorkOrderPartLines(ctx, wo.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(serviceLines)+len(partLines) == 0 {
+		return nil, fmt.Errorf("%w: work order %s has no service or part lines", domain.ErrInvalidInput, wo.ID)
+	}
+
+	items := make([]dto.CreateInvoiceItem, 0, len(serviceLines)+len(partLines))
+	for _, line := range serviceLines {
+		items = append(items, workOrderLineItem("service", line.ServiceID, line.Description, line.Quantity, line.ListPrice))
+	}
+	for _, line := range partLines {
+		items = append(items, workOrderLineItem("part", line.PartID, line.Description, line.Quantity, line.ListPrice))
+	}
+	return items, nil
+}
+
+func workOrderLineItem(itemType string, itemID *uuid.UUID, description string, quantity, listPrice float64) dto.CreateInvoiceItem {
+	item := dto.CreateInvoiceItem{
+		ItemType:    itemType,
+		Name:        description,
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   money.FromFloat(listPrice),
+	}
+	if itemID != nil {
+		item.ItemID = *itemID
+	}
+	if item.Name == "" {
+		item.Name = itemType
+	}
+	return item
+}
+
+func (s *InvoiceService) publishWorkOrderInvoiced(wo *domain.WorkOrderRM, inv *domain.Invoice) {
+	payload := domain.WorkOrderInvoicedPayload{
+		WorkOrderID:    wo.ID.String(),
+		OrganizationID: wo.OrganizationID.String(),
+		InvoiceID:      inv.ID.String(),
+		InvoiceNumber:  inv.InvoiceNumber,
+		TotalAmount:    inv.TotalAmount.Float64(),
+		Currency:       inv.CurrencyCode(),
+		BillingStatus:  domain.WorkOrderBillingInvoiced,
+	}
+
+	metadata := shared_events.NewEventMetadata(domain.EventWorkOrderInvoiced, shared_events.AggregateWorkOrder, wo.ID.String())
+	s.eventPublisher.Publish(context.Background(), metadata, payload)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 	"time"
@@ -18,6 +19,10 @@ type Config struct {
 	RefreshTokenExpiry time.Duration
 	GRPCPort           string
 	HTTPPort           string
+	RecurringInterval  time.Duration
+	DunningInterval    time.Duration
+	GatewayProvider    string
+	GatewaySecret      string
 }
 
 // Load loads configuration from environment variables
@@ -28,9 +33,23 @@ func Load() (*Config, error) {
 	accessExpiry, _ := strconv.Atoi(getEnv("ACCESS_TOKEN_EXPIRY", "900"))      // 15 minutes
 	refreshExpiry, _ := strconv.Atoi(getEnv("REFRESH_TOKEN_EXPIRY", "604800")) // 7 days
 
+	recurringInterval, _ := strconv.Atoi(getEnv("RECURRING_SCHEDULER_INTERVAL", "60")) // 1 minute
+	dunningInterval, _ := strconv.Atoi(getEnv("DUNNING_SCHEDULER_INTERVAL", "3600"))   // 1 hour
+
 	accessTokenExpiry := time.Duration(accessExpiry) * time.Second
 	refreshTokenExpiry := time.Duration(refreshExpiry) * time.Second
 
+	// The gateway has no safe default: a guessable webhook secret would let
+	// anyone post payments
+	gatewayProvider := os.Getenv("PAYMENT_GATEWAY_PROVIDER")
+	if gatewayProvider == "" {
+		return nil, fmt.Errorf("PAYMENT_GATEWAY_PROVIDER is not set")
+	}
+	gatewaySecret := os.Getenv("PAYMENT_GATEWAY_WEBHOOK_SECRET")
+	if gatewaySecret == "" {
+		return nil, fmt.Errorf("PAYMENT_GATEWAY_WEBHOOK_SECRET is not set")
+	}
+
 	return &Config{
 		DatabaseURL:        getEnv("DATABASE_URL", ""),
 		RedisURL:           getEnv("REDIS_URL", "localhost:6379"),
@@ -40,6 +59,10 @@ func Load() (*Config, error) {
 		JWTSecret:          getEnv("JWT_SECRET", "your-secret-key"),
 		AccessTokenExpiry:  accessTokenExpiry,
 		RefreshTokenExpiry: refreshTokenExpiry,
+		RecurringInterval:  time.Duration(recurringInterval) * time.Second,
+		DunningInterval:    time.Duration(dunningInterval) * time.Second,
+		GatewayProvider:    gatewayProvider,
+		GatewaySecret:      gatewaySecret,
 	}, nil
 }
 
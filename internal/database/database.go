@@ -84,6 +84,14 @@ func AutoMigrate(db *gorm.DB) error {
 		db.Exec("DROP TABLE IF EXISTS work_order_rms CASCADE")
 	}
 
+	// Document numbers used to be unique across organizations; they are now
+	// unique per organization
+	db.Exec("DROP INDEX IF EXISTS idx_invoices_invoice_number")
+	db.Exec("DROP INDEX IF EXISTS idx_billing_notes_note_number")
+	// Drafts are no longer numbered, so invoice numbers are only unique among
+	// the invoices that have one
+	db.Exec("DROP INDEX IF EXISTS idx_invoice_org_number")
+
 	// Auto migrate all models
 	err := db.AutoMigrate(
 		&domain.Invoice{},
@@ -96,11 +104,63 @@ func AutoMigrate(db *gorm.DB) error {
 		&domain.WorkOrderRM{},
 		&domain.WorkOrderServiceLineRM{},
 		&domain.WorkOrderPartLineRM{},
+		&domain.BillingNote{},
+		&domain.BillingNoteItem{},
+		&domain.RecurringProfile{},
+		&domain.RecurringRun{},
+		&domain.TaxRate{},
+		&domain.TaxGroup{},
+		&domain.TaxGroupComponent{},
+		&domain.InvoiceTax{},
+		&domain.NumberSequence{},
+		&domain.CustomerCredit{},
+		&domain.CustomerCreditEntry{},
+		&domain.PaymentAllocation{},
+		&domain.PaymentRefund{},
+		&domain.BankStatement{},
+		&domain.BankStatementLine{},
+		&domain.DirectDebitCreditor{},
+		&domain.DirectDebitMandate{},
+		&domain.DirectDebitBatch{},
+		&domain.DirectDebitItem{},
+		&domain.DunningPolicy{},
+		&domain.DunningReminder{},
+		&domain.LateFeePolicy{},
+		&domain.LateFeeCharge{},
+		&domain.PaymentTerm{},
+		&domain.CustomerPaymentTerm{},
+		&domain.Estimate{},
+		&domain.EstimateItem{},
+		&domain.EstimateTax{},
+		&domain.ProgressBill{},
+		&domain.Installment{},
+		&domain.ConsolidationRun{},
+		&domain.SubscriptionPlan{},
+		&domain.PlanComponent{},
+		&domain.Subscription{},
+		&domain.SubscriptionPlanChange{},
+		&domain.UsageRecord{},
+		&domain.SubscriptionRun{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to auto migrate: %w", err)
 	}
 
+	// Payments used to belong to exactly one invoice; give the ones recorded
+	// that way their customer and an allocation row
+	db.Exec(`
+		UPDATE payments SET customer_id = i.customer_id, currency = i.currency
+		FROM invoices i
+		WHERE payments.invoice_id = i.id AND payments.customer_id IS NULL
+	`)
+	db.Exec(`
+		INSERT INTO payment_allocations (id, organization_id, payment_id, invoice_id, amount, created_at, updated_at)
+		SELECT gen_random_uuid(), p.organization_id, p.id, p.invoice_id, p.amount, p.created_at, p.created_at
+		FROM payments p
+		WHERE p.invoice_id IS NOT NULL AND p.amount > 0
+		AND NOT EXISTS (SELECT 1 FROM payment_allocations a WHERE a.payment_id = p.id)
+	`)
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
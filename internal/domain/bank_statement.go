@@ -0,0 +1,256 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// BankStatement is a bank statement file uploaded for reconciliation
+type BankStatement struct {
+	ID             uuid.UUID           `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID           `gorm:"type:uuid;index" json:"organization_id"`
+	Format         string              `gorm:"type:varchar(10)" json:"format"` // camt053, mt940 or ofx
+	FileName       string              `gorm:"type:varchar(255)" json:"file_name"`
+	StatementRef   string              `gorm:"type:varchar(100)" json:"statement_ref"`
+	Account        string              `gorm:"type:varchar(50)" json:"account"`
+	Currency       string              `gorm:"type:varchar(3)" json:"currency"`
+	ImportedBy     string              `gorm:"type:varchar(255)" json:"imported_by"`
+	Lines          []BankStatementLine `gorm:"foreignKey:StatementID" json:"lines"`
+	CreatedAt      time.Time           `json:"created_at"`
+}
+
+type StatementLineStatus string
+
+const (
+	StatementLineUnmatched StatementLineStatus = "unmatched" // Waiting for review without a suggestion
+	StatementLineSuggested StatementLineStatus = "suggested" // Waiting for review with a likely invoice
+	StatementLineApplied   StatementLineStatus = "applied"   // Recorded as a payment
+	StatementLineIgnored   StatementLineStatus = "ignored"   // Not a customer payment
+)
+
+// StatementLineReviewStatuses are the statuses of lines in the review queue
+var StatementLineReviewStatuses = []StatementLineStatus{StatementLineUnmatched, StatementLineSuggested}
+
+type StatementDecision string
+
+const (
+	DecisionAutoApplied StatementDecision = "auto_applied"
+	DecisionConfirmed   StatementDecision = "confirmed"
+	DecisionIgnored     StatementDecision = "ignored"
+)
+
+// BankStatementLine is one transaction of a statement together with the
+// match found for it and the decision taken on it. Amount is positive for
+// money received.
+type BankStatementLine struct {
+	ID                  uuid.UUID           `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID      uuid.UUID           `gorm:"type:uuid;index;uniqueIndex:idx_statement_line_fingerprint" json:"organization_id"`
+	StatementID         uuid.UUID           `gorm:"type:uuid;index" json:"statement_id"`
+	Fingerprint         string              `gorm:"type:varchar(64);uniqueIndex:idx_statement_line_fingerprint" json:"-"` // Keeps a line from being imported twice
+	BookingDate         time.Time           `json:"booking_date"`
+	ValueDate           time.Time           `json:"value_date"`
+	Amount              money.Amount        `gorm:"type:decimal(15,2)" json:"amount"`
+	Currency            string              `gorm:"type:varchar(3)" json:"currency"`
+	Reference           string              `gorm:"type:varchar(255)" json:"reference"`
+	Description         string              `gorm:"type:text" json:"description"`
+	CounterpartyName    string              `gorm:"type:varchar(255)" json:"counterparty_name"`
+	CounterpartyAccount string              `gorm:"type:varchar(50)" json:"counterparty_account"`
+	BankRef             string              `gorm:"type:varchar(100)" json:"bank_ref"`
+	Status              StatementLineStatus `gorm:"type:varchar(20);index" json:"status"`
+	MatchedInvoiceID    *uuid.UUID          `gorm:"type:uuid" json:"matched_invoice_id,omitempty"`
+	MatchedCustomerID   *uuid.UUID          `gorm:"type:uuid" json:"matched_customer_id,omitempty"`
+	MatchScore          int                 `json:"match_score"`
+	MatchReasons        string              `gorm:"type:varchar(255)" json:"match_reasons"` // Comma-separated signals behind the score
+	PaymentID           *uuid.UUID          `gorm:"type:uuid;index" json:"payment_id,omitempty"`
+	Decision            StatementDecision   `gorm:"type:varchar(20)" json:"decision,omitempty"`
+	DecisionNotes       string              `gorm:"type:text" json:"decision_notes"`
+	DecidedBy           string              `gorm:"type:varchar(255)" json:"decided_by"`
+	DecidedAt           *time.Time          `json:"decided_at,omitempty"`
+	CreatedAt           time.Time           `json:"created_at"`
+	UpdatedAt           time.Time           `json:"updated_at"`
+}
+
+// StatementLineFingerprint identifies a transaction across uploads of
+// overlapping statements. occurrence tells apart identical transactions
+// within one statement.
+func StatementLineFingerprint(account string, line *BankStatementLine, occurrence int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|%s|%s|%s|%d", account, line.BookingDate.Format("2006-01-02"), line.Amount, line.Currency,
+		line.BankRef, line.Reference, line.Description, occurrence)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Match scores. A line is suggested from MatchSuggestScore and applied
+// without review from MatchAutoApplyScore, when no other invoice scores the
+// same.
+const (
+	scoreInvoiceNumber = 50
+	scoreReferenceNo   = 40
+	scoreExactAmount   = 30
+	scoreCustomer      = 20
+
+	MatchSuggestScore   = 30
+	MatchAutoApplyScore = 80
+)
+
+// StatementMatch is the best invoice found for a statement line
+type StatementMatch struct {
+	InvoiceID  *uuid.UUID
+	CustomerID *uuid.UUID
+	Score      int
+	Reasons    []string
+	Ambiguous  bool // Another invoice scored the same
+}
+
+// AutoApply reports whether the match is certain enough to record the
+// payment without review
+func (m StatementMatch) AutoApply() bool {
+	return m.InvoiceID != nil && m.Score >= MatchAutoApplyScore && !m.Ambiguous
+}
+
+// MatchStatementLine looks for the open invoice a received amount pays. The
+// invoice number or reference quoted in the line's reference or description
+// weigh most, then an amount equal to the amount due and a payer whose name
+// is one of the customer's names. customerNames holds the display and
+// company names of the invoices' customers. Lines that match no invoice
+// still name the customer when the payer is recognised.
+func MatchStatementLine(line *BankStatementLine, invoices []*Invoice, customerNames map[uuid.UUID][]string) StatementMatch {
+	var best StatementMatch
+	if !line.Amount.IsPositive() {
+		return best
+	}
+	tokens := matchTokens(line.Reference + " " + line.Description)
+	payer := normalizeMatchText(line.CounterpartyName)
+
+	sorted := append([]*Invoice(nil), invoices...)
+	sort.SliceStable(sorted, func(i, j int) bool { return dueBefore(sorted[i], sorted[j]) })
+	for _, inv := range sorted {
+		if inv.CurrencyCode() != line.Currency || !inv.AmountDue().IsPositive() {
+			continue
+		}
+
+		score, customerScore := 0, 0
+		var reasons []string
+		if n := normalizeMatchText(inv.InvoiceNumber); n != "" && quotes(tokens, n) {
+			score += scoreInvoiceNumber
+			reasons = append(reasons, "invoice_number")
+		}
+		if n := normalizeMatchText(inv.ReferenceNo); len(n) >= 4 && quotes(tokens, n) {
+			score += scoreReferenceNo
+			reasons = append(reasons, "reference_no")
+		}
+		if inv.AmountDue().Equal(line.Amount) {
+			score += scoreExactAmount
+			reasons = append(reasons, "amount")
+		}
+		if payer != "" && namesMatch(payer, customerNames[inv.CustomerID]) {
+			customerScore = scoreCustomer
+			reasons = append(reasons, "customer")
+		}
+		score += customerScore
+
+		if customerScore > 0 && best.CustomerID == nil {
+			customerID := inv.CustomerID
+			best.CustomerID = &customerID
+		}
+		if score < MatchSuggestScore {
+			continue
+		}
+		switch {
+		case score > best.Score:
+			id, customerID := inv.ID, inv.CustomerID
+			best = StatementMatch{InvoiceID: &id, CustomerID: &customerID, Score: score, Reasons: reasons}
+		case score == best.Score:
+			best.Ambiguous = true
+		}
+	}
+	return best
+}
+
+// Apply stores the match on the line and puts it in the review queue
+func (line *BankStatementLine) Apply(m StatementMatch) {
+	line.MatchedInvoiceID = m.InvoiceID
+	line.MatchedCustomerID = m.CustomerID
+	line.MatchScore = m.Score
+	line.MatchReasons = strings.Join(m.Reasons, ",")
+	if m.Ambiguous {
+		line.MatchReasons = strings.TrimPrefix(line.MatchReasons+",ambiguous", ",")
+	}
+	line.Status = StatementLineUnmatched
+	if m.InvoiceID != nil {
+		line.Status = StatementLineSuggested
+	}
+}
+
+// Decide records the decision taken on a line in the review queue
+func (line *BankStatementLine) Decide(decision StatementDecision, paymentID *uuid.UUID, notes, decidedBy string, now time.Time) error {
+	if line.Status == StatementLineApplied || line.Status == StatementLineIgnored {
+		return fmt.Errorf("%w: statement line %s has already been %s", ErrInvalidInput, line.ID, line.Status)
+	}
+	line.Status = StatementLineApplied
+	if decision == DecisionIgnored {
+		line.Status = StatementLineIgnored
+	}
+	line.Decision = decision
+	line.PaymentID = paymentID
+	line.DecisionNotes = notes
+	line.DecidedBy = decidedBy
+	line.DecidedAt = &now
+	return nil
+}
+
+// normalizeMatchText keeps letters and digits only, upper-cased, so
+// "INV-2026/0001" and "inv 2026 0001" compare equal
+func normalizeMatchText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// matchTokens splits text into upper-cased runs of letters and digits
+func matchTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// quotes reports whether consecutive tokens spell code exactly, so
+// "INV-2026-0001" and "INV20260001" quote INV20260001 but "INV-2026-00012"
+// does not
+func quotes(tokens []string, code string) bool {
+	for i := range tokens {
+		joined := ""
+		for _, t := range tokens[i:] {
+			joined += t
+			if len(joined) >= len(code) {
+				break
+			}
+		}
+		if joined == code {
+			return true
+		}
+	}
+	return false
+}
+
+func namesMatch(payer string, names []string) bool {
+	for _, name := range names {
+		if n := normalizeMatchText(name); len(n) >= 3 && (strings.Contains(payer, n) || strings.Contains(n, payer)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type BillingNoteType string
+
+const (
+	// BillingNoteCredit reduces the amount owed on the source invoice
+	BillingNoteCredit BillingNoteType = "credit"
+	// BillingNoteDebit increases the amount owed on the source invoice
+	BillingNoteDebit BillingNoteType = "debit"
+)
+
+// BillingNote is a credit or debit note issued against an existing invoice.
+// Issued invoices are never edited in place; corrections are made with notes.
+type BillingNote struct {
+	ID             uuid.UUID         `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID         `gorm:"type:uuid;index;uniqueIndex:idx_billing_note_org_number" json:"organization_id"`
+	InvoiceID      uuid.UUID         `gorm:"type:uuid;index" json:"invoice_id"`
+	CustomerID     uuid.UUID         `gorm:"type:uuid;index" json:"customer_id"`
+	NoteNumber     string            `gorm:"type:varchar(50);uniqueIndex:idx_billing_note_org_number" json:"note_number"`
+	NoteType       BillingNoteType   `gorm:"type:varchar(20);index" json:"note_type"`
+	Reason         string            `gorm:"type:text" json:"reason"`
+	IssueDate      time.Time         `json:"issue_date"`
+	Currency       string            `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+	SubTotal       money.Amount      `gorm:"type:decimal(15,2)" json:"sub_total"`
+	DiscountTotal  money.Amount      `gorm:"type:decimal(15,2)" json:"discount_total"`
+	TaxTotal       money.Amount      `gorm:"type:decimal(15,2)" json:"tax_total"`
+	Adjustment     money.Amount      `gorm:"type:decimal(15,2)" json:"adjustment"` // Header-level amount, e.g. the invoice adjustment on a full reversal
+	TotalAmount    money.Amount      `gorm:"type:decimal(15,2)" json:"total_amount"`
+	ToCredit       money.Amount      `gorm:"type:decimal(15,2);default:0" json:"to_credit"` // Part of a credit note beyond what was owed, added to the customer's credit
+	IssuedBy       string            `gorm:"type:varchar(255)" json:"issued_by"`
+	Items          []BillingNoteItem `gorm:"foreignKey:NoteID" json:"items"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+type BillingNoteItem struct {
+	ID            uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	NoteID        uuid.UUID    `gorm:"type:uuid;index" json:"note_id"`
+	InvoiceItemID *uuid.UUID   `gorm:"type:uuid;index" json:"invoice_item_id,omitempty"` // Source line, nil for extra debit charges
+	ItemID        uuid.UUID    `gorm:"type:uuid" json:"item_id"`
+	ItemType      string       `gorm:"type:varchar(20)" json:"item_type"`
+	Name          string       `gorm:"type:varchar(255)" json:"name"`
+	Description   string       `gorm:"type:text" json:"description"`
+	Quantity      float64      `gorm:"type:decimal(15,2)" json:"quantity"`
+	UnitPrice     money.Amount `gorm:"type:decimal(15,2)" json:"unit_price"`
+	Discount      money.Amount `gorm:"type:decimal(15,2)" json:"discount"`
+	Tax           money.Amount `gorm:"type:decimal(15,2)" json:"tax"`
+	Total         money.Amount `gorm:"type:decimal(15,2)" json:"total"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// DocumentType returns the numbering series of the note type
+func (t BillingNoteType) DocumentType() DocumentType {
+	if t == BillingNoteDebit {
+		return DocumentDebitNote
+	}
+	return DocumentCreditNote
+}
+
+// IsValid reports whether t is a known note type
+func (t BillingNoteType) IsValid() bool {
+	return t == BillingNoteCredit || t == BillingNoteDebit
+}
+
+// NoteLine asks for part of a source invoice line, or for an extra charge on
+// a debit note when InvoiceItemID is nil
+type NoteLine struct {
+	InvoiceItemID *uuid.UUID
+	Quantity      float64 // Zero means what is left of the source line
+	ItemID        uuid.UUID
+	ItemType      string
+	Name          string
+	Description   string
+	UnitPrice     money.Amount
+	Discount      money.Amount
+	Tax           money.Amount
+}
+
+// NoteRequest describes the lines of a credit or debit note
+type NoteRequest struct {
+	Type       BillingNoteType
+	Full       bool // Credit notes only: reverse everything not yet credited
+	Adjustment money.Amount
+	Lines      []NoteLine
+}
+
+// creditedLine tracks how much of a source invoice line has already been credited
+type creditedLine struct {
+	quantity float64
+	amount   money.Amount
+	discount money.Amount
+	tax      money.Amount
+}
+
+// DraftNote builds the lines and totals of a note against the invoice, given
+// the notes issued on it before. It does not touch the invoice balance.
+func (inv *Invoice) DraftNote(req NoteRequest, earlier []BillingNote) (*BillingNote, error) {
+	if !inv.IsLocked() {
+		return nil, fmt.Errorf("%w: draft invoices can be edited directly", ErrInvoiceNotIssued)
+	}
+	if inv.Status == InvoiceStatusVoid {
+		return nil, fmt.Errorf("%w: invoice %s is void", ErrInvalidInput, inv.InvoiceNumber)
+	}
+	if !req.Type.IsValid() {
+		return nil, fmt.Errorf("%w: unknown note type %q", ErrInvalidInput, req.Type)
+	}
+
+	credited, creditedAdjustment := summarizeCredits(earlier)
+	note := &BillingNote{
+		ID:             uuid.New(),
+		OrganizationID: inv.OrganizationID,
+		InvoiceID:      inv.ID,
+		CustomerID:     inv.CustomerID,
+		NoteType:       req.Type,
+		Currency:       inv.CurrencyCode(),
+	}
+
+	adjustment := req.Adjustment
+	if req.Type == BillingNoteCredit && req.Full {
+		note.Items = inv.fullReversalItems(note.ID, credited)
+		adjustment = inv.Adjustment.Add(inv.ExciseDuty).Sub(creditedAdjustment)
+	} else {
+		items, err := inv.noteItems(req.Type, note.ID, req.Lines, credited)
+		if err != nil {
+			return nil, err
+		}
+		note.Items = items
+	}
+	if len(note.Items) == 0 && adjustment.IsZero() {
+		return nil, fmt.Errorf("%w: note has no lines to issue", ErrInvalidInput)
+	}
+
+	note.Adjustment = inv.Round(adjustment)
+	for _, item := range note.Items {
+		note.SubTotal = note.SubTotal.Add(item.Total.Add(item.Discount).Sub(item.Tax))
+		note.DiscountTotal = note.DiscountTotal.Add(item.Discount)
+		note.TaxTotal = note.TaxTotal.Add(item.Tax)
+		note.TotalAmount = note.TotalAmount.Add(item.Total)
+	}
+	note.TotalAmount = note.TotalAmount.Add(note.Adjustment)
+	return note, nil
+}
+
+// ApplyNote adds an issued note to the invoice balance and moves the invoice
+// to the status that balance calls for. Credit beyond what is still owed,
+// such as a credit note on a paid invoice, is not lost: it is recorded as
+// the note's ToCredit for the customer's credit balance.
+func (inv *Invoice) ApplyNote(note *BillingNote) error {
+	if note.NoteType == BillingNoteCredit {
+		creditable := inv.TotalAmount.Add(inv.DebitedAmount).Sub(inv.CreditedAmount)
+		if note.TotalAmount.GreaterThan(creditable) {
+			return fmt.Errorf("%w: credit of %s exceeds the %s still invoiced", ErrInvalidInput, note.TotalAmount, creditable)
+		}
+		inv.CreditedAmount = inv.CreditedAmount.Add(note.TotalAmount)
+		note.ToCredit = money.Zero
+		if due := inv.AmountDue(); due.IsNegative() {
+			note.ToCredit = due.Neg()
+			inv.ExcessCredit = inv.ExcessCredit.Add(note.ToCredit)
+		}
+	} else {
+		inv.DebitedAmount = inv.DebitedAmount.Add(note.TotalAmount)
+	}
+	inv.RecalculateBalance()
+	return inv.TransitionTo(inv.SettlementStatus(), TriggerPayment, note.IssueDate)
+}
+
+// fullReversalItems credits every source line for whatever has not been credited yet
+func (inv *Invoice) fullReversalItems(noteID uuid.UUID, credited map[uuid.UUID]creditedLine) []BillingNoteItem {
+	items := make([]BillingNoteItem, 0, len(inv.Items))
+	for _, src := range inv.Items {
+		done := credited[src.ID]
+		remaining := roundQuantity(src.Quantity - done.quantity)
+		if remaining <= 0 {
+			continue
+		}
+		srcID := src.ID
+		items = append(items, BillingNoteItem{
+			ID:            uuid.New(),
+			NoteID:        noteID,
+			InvoiceItemID: &srcID,
+			ItemID:        src.ItemID,
+			ItemType:      src.ItemType,
+			Name:          src.Name,
+			Description:   src.Description,
+			Quantity:      remaining,
+			UnitPrice:     src.UnitPrice,
+			Discount:      src.Discount.Sub(done.discount),
+			Tax:           src.Tax.Sub(done.tax),
+			Total:         src.Total.Sub(done.amount.Sub(done.discount).Add(done.tax)),
+		})
+	}
+	return items
+}
+
+func (inv *Invoice) noteItems(noteType BillingNoteType, noteID uuid.UUID, lines []NoteLine, credited map[uuid.UUID]creditedLine) ([]BillingNoteItem, error) {
+	sources := make(map[uuid.UUID]InvoiceItem, len(inv.Items))
+	for _, item := range inv.Items {
+		sources[item.ID] = item
+	}
+
+	items := make([]BillingNoteItem, 0, len(lines))
+	for _, line := range lines {
+		if line.InvoiceItemID == nil {
+			// Extra charge, only meaningful on a debit note
+			if noteType == BillingNoteCredit {
+				return nil, fmt.Errorf("%w: credit note lines must reference an invoice line", ErrInvalidInput)
+			}
+			if line.Name == "" || line.Quantity <= 0 {
+				return nil, fmt.Errorf("%w: debit note charges need a name and a positive quantity", ErrInvalidInput)
+			}
+			lineAmount := inv.Round(line.UnitPrice.Mul(line.Quantity))
+			discount := inv.Round(line.Discount)
+			tax := inv.Round(line.Tax)
+			items = append(items, BillingNoteItem{
+				ID:          uuid.New(),
+				NoteID:      noteID,
+				ItemID:      line.ItemID,
+				ItemType:    line.ItemType,
+				Name:        line.Name,
+				Description: line.Description,
+				Quantity:    line.Quantity,
+				UnitPrice:   line.UnitPrice,
+				Discount:    discount,
+				Tax:         tax,
+				Total:       lineAmount.Sub(discount).Add(tax),
+			})
+			continue
+		}
+
+		src, ok := sources[*line.InvoiceItemID]
+		if !ok {
+			return nil, fmt.Errorf("%w: line %s does not belong to invoice %s", ErrInvalidInput, *line.InvoiceItemID, inv.InvoiceNumber)
+		}
+		done := credited[src.ID]
+		// Rounded, as quantities added up over several notes drift
+		remaining := roundQuantity(src.Quantity - done.quantity)
+
+		qty := roundQuantity(line.Quantity)
+		if qty == 0 {
+			qty = remaining
+			if noteType == BillingNoteDebit {
+				qty = src.Quantity
+			}
+		}
+		if qty <= 0 {
+			return nil, fmt.Errorf("%w: line %s has nothing left to credit", ErrInvalidInput, src.Name)
+		}
+		if noteType == BillingNoteCredit && qty > remaining {
+			return nil, fmt.Errorf("%w: cannot credit %v of %s, only %v remaining", ErrInvalidInput, qty, src.Name, remaining)
+		}
+
+		srcID := src.ID
+		item := BillingNoteItem{
+			ID:            uuid.New(),
+			NoteID:        noteID,
+			InvoiceItemID: &srcID,
+			ItemID:        src.ItemID,
+			ItemType:      src.ItemType,
+			Name:          src.Name,
+			Description:   src.Description,
+			Quantity:      qty,
+			UnitPrice:     src.UnitPrice,
+		}
+		if noteType == BillingNoteCredit && qty == remaining {
+			// Last part of the line: take exactly what is left so rounding never leaves pennies behind
+			item.Discount = src.Discount.Sub(done.discount)
+			item.Tax = src.Tax.Sub(done.tax)
+			item.Total = src.Total.Sub(done.amount.Sub(done.discount).Add(done.tax))
+		} else {
+			// Prorate the stored amounts rather than re-pricing the line, which
+			// also holds for lines on tax-inclusive invoices
+			srcQty := money.FromFloat(src.Quantity)
+			item.Discount = inv.Round(src.Discount.Mul(qty).Div(srcQty, MoneyRounding))
+			item.Tax = inv.Round(src.Tax.Mul(qty).Div(srcQty, MoneyRounding))
+			item.Total = inv.Round(src.Total.Mul(qty).Div(srcQty, MoneyRounding))
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// summarizeCredits adds up earlier credit notes per source line, plus any header-level credits
+func summarizeCredits(notes []BillingNote) (map[uuid.UUID]creditedLine, money.Amount) {
+	credited := make(map[uuid.UUID]creditedLine)
+	var adjustment money.Amount
+	for _, note := range notes {
+		if note.NoteType != BillingNoteCredit {
+			continue
+		}
+		adjustment = adjustment.Add(note.Adjustment)
+		for _, item := range note.Items {
+			if item.InvoiceItemID == nil {
+				continue
+			}
+			line := credited[*item.InvoiceItemID]
+			line.quantity += item.Quantity
+			line.discount = line.discount.Add(item.Discount)
+			line.tax = line.tax.Add(item.Tax)
+			line.amount = line.amount.Add(item.Total.Add(item.Discount).Sub(item.Tax))
+			credited[*item.InvoiceItemID] = line
+		}
+	}
+	return credited, adjustment
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ConsolidationStatus string
+
+const (
+	ConsolidationPending  ConsolidationStatus = "pending"
+	ConsolidationInvoiced ConsolidationStatus = "invoiced"
+	ConsolidationEmpty    ConsolidationStatus = "empty" // Nothing was left to bill in the period
+	ConsolidationFailed   ConsolidationStatus = "failed"
+)
+
+// ConsolidationSource selects what a consolidation gathers
+type ConsolidationSource string
+
+const (
+	ConsolidateAll        ConsolidationSource = "all"
+	ConsolidateWorkOrders ConsolidationSource = "work_orders"
+	ConsolidateDrafts     ConsolidationSource = "draft_invoices"
+)
+
+// ParseConsolidationSource validates a source given in a request; empty
+// means all of them
+func ParseConsolidationSource(s string) (ConsolidationSource, error) {
+	switch src := ConsolidationSource(s); src {
+	case "":
+		return ConsolidateAll, nil
+	case ConsolidateAll, ConsolidateWorkOrders, ConsolidateDrafts:
+		return src, nil
+	default:
+		return "", fmt.Errorf("%w: unknown consolidation source %q", ErrInvalidInput, s)
+	}
+}
+
+// ConsolidationRun records the consolidated invoice of one customer, currency
+// and billing period. The unique period is what makes a rerun for the same
+// period return the invoice already made instead of billing twice.
+type ConsolidationRun struct {
+	ID             uuid.UUID           `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID           `gorm:"type:uuid;index;uniqueIndex:idx_consolidation_period" json:"organization_id"`
+	CustomerID     uuid.UUID           `gorm:"type:uuid;uniqueIndex:idx_consolidation_period" json:"customer_id"`
+	Currency       string              `gorm:"type:varchar(3);uniqueIndex:idx_consolidation_period" json:"currency"`
+	PeriodStart    time.Time           `gorm:"uniqueIndex:idx_consolidation_period" json:"period_start"`
+	PeriodEnd      time.Time           `gorm:"uniqueIndex:idx_consolidation_period" json:"period_end"` // Last day of the period
+	Source         ConsolidationSource `gorm:"type:varchar(20)" json:"source"`
+	Status         ConsolidationStatus `gorm:"type:varchar(20)" json:"status"`
+	InvoiceID      *uuid.UUID          `gorm:"type:uuid;index" json:"invoice_id,omitempty"`
+	WorkOrders     int                 `gorm:"default:0" json:"work_orders"` // Work orders billed on the invoice
+	Drafts         int                 `gorm:"default:0" json:"drafts"`      // Draft invoices merged into it
+	Error          string              `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+// ConsolidationPeriod checks a billing period and returns its first and last
+// day as midnight UTC
+func ConsolidationPeriod(start, end time.Time) (time.Time, time.Time, error) {
+	if start.IsZero() || end.IsZero() {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: a period needs a start and an end", ErrInvalidInput)
+	}
+	start, end = dayOf(start), dayOf(end)
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: period ends before it starts", ErrInvalidInput)
+	}
+	if end.After(start.AddDate(1, 0, 0)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: a period is at most a year", ErrInvalidInput)
+	}
+	return start, end, nil
+}
+
+// PreviousMonth returns the first and last day of the calendar month before now
+func PreviousMonth(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, -1, 0), first.AddDate(0, 0, -1)
+}
+
+// WorkOrderGroupLabel labels the lines a work order adds to a consolidated invoice
+func WorkOrderGroupLabel(wo *WorkOrderRM) string {
+	label := "Work order " + wo.ID.String()[:8]
+	if wo.Summary != "" {
+		label += ": " + wo.Summary
+	}
+	return truncateLabel(label)
+}
+
+// DraftGroupLabel labels the lines a draft invoice adds to a consolidated invoice
+func DraftGroupLabel(inv *Invoice) string {
+	label := "Invoice " + inv.InvoiceNumber
+	if inv.WorkOrderID != nil {
+		label = "Work order " + inv.WorkOrderID.String()[:8] + ", invoice " + inv.InvoiceNumber
+	}
+	if inv.Subject != "" {
+		label += ": " + inv.Subject
+	}
+	return truncateLabel(label)
+}
+
+// truncateLabel keeps a group label within its column
+func truncateLabel(label string) string {
+	if runes := []rune(label); len(runes) > 255 {
+		return string(runes[:255])
+	}
+	return label
+}
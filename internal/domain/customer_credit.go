@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// PaymentMethodCredit marks a payment made from the customer's credit
+// balance rather than with new money. It is never accepted from clients.
+const PaymentMethodCredit = "customer_credit"
+
+type CreditEntryType string
+
+const (
+	CreditEntryOverpayment CreditEntryType = "overpayment" // Excess of a payment over the invoice balance
+	CreditEntryApplied     CreditEntryType = "applied"     // Credit used to pay an invoice
+	CreditEntryRefunded    CreditEntryType = "refunded"    // Credit paid back to the customer
+	CreditEntryReallocated CreditEntryType = "reallocated" // A payment's unapplied part changed with its allocations
+	CreditEntryReversed    CreditEntryType = "reversed"    // Credit from a payment that was reversed, or given back by reversing its application
+	CreditEntryDeposit     CreditEntryType = "deposit"     // Money paid on a deposit invoice, held for the final invoice
+	CreditEntryCreditNote  CreditEntryType = "credit_note" // Part of a credit note beyond what was owed on the invoice
+)
+
+// CustomerCredit is the unapplied credit a customer holds in one currency.
+// The row is locked while entries are posted against it, so the balance can
+// never be spent twice.
+type CustomerCredit struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;uniqueIndex:idx_customer_credit_currency" json:"organization_id"`
+	CustomerID     uuid.UUID    `gorm:"type:uuid;uniqueIndex:idx_customer_credit_currency" json:"customer_id"`
+	Currency       string       `gorm:"type:varchar(3);uniqueIndex:idx_customer_credit_currency" json:"currency"`
+	Balance        money.Amount `gorm:"type:decimal(15,2)" json:"balance"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// CustomerCreditEntry is one movement on a customer's credit balance.
+// Amount is positive when credit is added and negative when it is used.
+type CustomerCreditEntry struct {
+	ID             uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID       `gorm:"type:uuid;index" json:"organization_id"`
+	CustomerID     uuid.UUID       `gorm:"type:uuid;index" json:"customer_id"`
+	Currency       string          `gorm:"type:varchar(3)" json:"currency"`
+	EntryType      CreditEntryType `gorm:"type:varchar(20)" json:"entry_type"`
+	Amount         money.Amount    `gorm:"type:decimal(15,2)" json:"amount"`
+	BalanceAfter   money.Amount    `gorm:"type:decimal(15,2)" json:"balance_after"`
+	PaymentID      *uuid.UUID      `gorm:"type:uuid;index" json:"payment_id,omitempty"`
+	InvoiceID      *uuid.UUID      `gorm:"type:uuid;index" json:"invoice_id,omitempty"`
+	PaymentMethod  string          `gorm:"type:varchar(50)" json:"payment_method"`
+	Reference      string          `gorm:"type:varchar(100)" json:"reference"`
+	Notes          string          `gorm:"type:text" json:"notes"`
+	CreatedBy      string          `gorm:"type:varchar(255)" json:"created_by"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// CreditEntryForPayment returns the credit movement a new payment causes: its
+// unapplied excess is added to the customer's credit, and a payment made from
+// credit uses it up. It returns nil when the credit balance is untouched.
+func CreditEntryForPayment(p *Payment) *CustomerCreditEntry {
+	entry := newPaymentCreditEntry(p)
+	switch {
+	case p.PaymentMethod == PaymentMethodCredit:
+		entry.EntryType = CreditEntryApplied
+		entry.Amount = p.Amount.Neg()
+	case p.Unapplied.IsPositive():
+		entry.EntryType = CreditEntryOverpayment
+		entry.Amount = p.Unapplied
+	default:
+		return nil
+	}
+	return entry
+}
+
+// CreditEntryForReallocation returns the credit movement caused by moving the
+// allocations of a payment whose unapplied part used to be previousUnapplied,
+// or nil when it did not change
+func CreditEntryForReallocation(p *Payment, previousUnapplied money.Amount) *CustomerCreditEntry {
+	delta := p.Unapplied.Sub(previousUnapplied)
+	if delta.IsZero() {
+		return nil
+	}
+	entry := newPaymentCreditEntry(p)
+	entry.EntryType = CreditEntryReallocated
+	entry.Amount = delta
+	return entry
+}
+
+// CreditEntryForRefund returns the credit movement a refund or reversal of p
+// causes: the unapplied part it took back leaves the customer's credit, and
+// reversing a payment made from credit gives that credit back. It returns nil
+// when the credit balance is untouched.
+func CreditEntryForRefund(p *Payment, refund *PaymentRefund) *CustomerCreditEntry {
+	entry := newPaymentCreditEntry(p)
+	entry.Notes = refund.Reason
+	switch {
+	case p.PaymentMethod == PaymentMethodCredit:
+		entry.EntryType = CreditEntryReversed
+		entry.Amount = refund.Amount
+	case refund.FromCredit.IsPositive():
+		entry.EntryType = CreditEntryRefunded
+		if refund.RefundType == RefundTypeReversal {
+			entry.EntryType = CreditEntryReversed
+		}
+		entry.Amount = refund.FromCredit.Neg()
+	default:
+		return nil
+	}
+	return entry
+}
+
+// CreditEntryForNote returns the credit a credit note gives the customer
+// beyond what they still owed on the invoice, or nil when it all went
+// against the balance
+func CreditEntryForNote(note *BillingNote) *CustomerCreditEntry {
+	if !note.ToCredit.IsPositive() {
+		return nil
+	}
+	return &CustomerCreditEntry{
+		ID:             uuid.New(),
+		OrganizationID: note.OrganizationID,
+		CustomerID:     note.CustomerID,
+		Currency:       note.Currency,
+		EntryType:      CreditEntryCreditNote,
+		Amount:         note.ToCredit,
+		InvoiceID:      &note.InvoiceID,
+		Reference:      note.NoteNumber,
+		Notes:          note.Reason,
+		CreatedBy:      note.IssuedBy,
+	}
+}
+
+func newPaymentCreditEntry(p *Payment) *CustomerCreditEntry {
+	return &CustomerCreditEntry{
+		ID:             uuid.New(),
+		OrganizationID: p.OrganizationID,
+		CustomerID:     p.CustomerID,
+		Currency:       p.Currency,
+		PaymentID:      &p.ID,
+		InvoiceID:      p.InvoiceID,
+		PaymentMethod:  p.PaymentMethod,
+		Reference:      p.TransactionRef,
+	}
+}
@@ -0,0 +1,240 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"erp-billing-service/pkg/directdebit"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type DirectDebitScheme string
+
+const (
+	SchemeSEPA DirectDebitScheme = "sepa" // SEPA Core direct debit, collected in EUR
+	SchemeACH  DirectDebitScheme = "ach"  // NACHA ACH debit, collected in USD
+)
+
+// ParseDirectDebitScheme returns the scheme named s
+func ParseDirectDebitScheme(s string) (DirectDebitScheme, error) {
+	switch scheme := DirectDebitScheme(strings.ToLower(s)); scheme {
+	case SchemeSEPA, SchemeACH:
+		return scheme, nil
+	default:
+		return "", fmt.Errorf("%w: unknown direct debit scheme %q", ErrInvalidInput, s)
+	}
+}
+
+// Currency returns the only currency the scheme collects
+func (s DirectDebitScheme) Currency() string {
+	if s == SchemeSEPA {
+		return "EUR"
+	}
+	return "USD"
+}
+
+// DirectDebitCreditor holds the organization's details as the party
+// collecting under a scheme, and the counters that number its batches
+type DirectDebitCreditor struct {
+	ID              uuid.UUID         `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID  uuid.UUID         `gorm:"type:uuid;uniqueIndex:idx_dd_creditor_scheme" json:"organization_id"`
+	Scheme          DirectDebitScheme `gorm:"type:varchar(10);uniqueIndex:idx_dd_creditor_scheme" json:"scheme"`
+	Name            string            `gorm:"type:varchar(140)" json:"name"`
+	CreditorID      string            `gorm:"type:varchar(35)" json:"creditor_id"` // SEPA creditor identifier or ACH company ID
+	IBAN            string            `gorm:"type:varchar(34)" json:"iban,omitempty"`
+	BIC             string            `gorm:"type:varchar(11)" json:"bic,omitempty"`
+	RoutingNumber   string            `gorm:"type:varchar(9)" json:"routing_number,omitempty"` // ACH originating bank
+	DestinationName string            `gorm:"type:varchar(23)" json:"destination_name,omitempty"`
+	NextBatch       int64             `gorm:"default:1" json:"next_batch"`
+	NextTrace       int64             `gorm:"default:1" json:"next_trace"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// Validate checks the details the scheme needs to produce a file
+func (c *DirectDebitCreditor) Validate() error {
+	if strings.TrimSpace(c.Name) == "" || strings.TrimSpace(c.CreditorID) == "" {
+		return fmt.Errorf("%w: creditor name and identifier are required", ErrInvalidInput)
+	}
+	switch c.Scheme {
+	case SchemeSEPA:
+		c.IBAN, c.BIC = directdebit.NormalizeIBAN(c.IBAN), strings.ToUpper(strings.TrimSpace(c.BIC))
+		if !directdebit.ValidIBAN(c.IBAN) {
+			return fmt.Errorf("%w: creditor IBAN is not valid", ErrInvalidInput)
+		}
+	case SchemeACH:
+		if !directdebit.ValidRoutingNumber(c.RoutingNumber) {
+			return fmt.Errorf("%w: originating routing number is not valid", ErrInvalidInput)
+		}
+		if len(c.CreditorID) > 10 {
+			return fmt.Errorf("%w: ACH company ID is longer than 10 characters", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+type MandateStatus string
+
+const (
+	MandateActive  MandateStatus = "active"
+	MandateRevoked MandateStatus = "revoked"
+)
+
+// DirectDebitMandate is a customer's authorization to collect their invoices
+// from a bank account. A customer has at most one active mandate per scheme.
+type DirectDebitMandate struct {
+	ID             uuid.UUID         `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID         `gorm:"type:uuid;index;uniqueIndex:idx_dd_mandate_active,where:status = 'active'" json:"organization_id"`
+	CustomerID     uuid.UUID         `gorm:"type:uuid;index;uniqueIndex:idx_dd_mandate_active,where:status = 'active'" json:"customer_id"`
+	Scheme         DirectDebitScheme `gorm:"type:varchar(10);uniqueIndex:idx_dd_mandate_active,where:status = 'active'" json:"scheme"`
+	Reference      string            `gorm:"type:varchar(35)" json:"reference"` // Mandate ID quoted in every collection
+	SignedAt       time.Time         `json:"signed_at"`
+	DebtorName     string            `gorm:"type:varchar(140)" json:"debtor_name"`
+	IBAN           string            `gorm:"type:varchar(34)" json:"iban,omitempty"`
+	BIC            string            `gorm:"type:varchar(11)" json:"bic,omitempty"`
+	RoutingNumber  string            `gorm:"type:varchar(9)" json:"routing_number,omitempty"`
+	AccountNumber  string            `gorm:"type:varchar(17)" json:"account_number,omitempty"`
+	AccountType    string            `gorm:"type:varchar(10)" json:"account_type,omitempty"` // checking or savings
+	Status         MandateStatus     `gorm:"type:varchar(20);default:'active'" json:"status"`
+	FirstCollected *time.Time        `json:"first_collected,omitempty"`
+	RevokedAt      *time.Time        `json:"revoked_at,omitempty"`
+	CreatedBy      string            `gorm:"type:varchar(255)" json:"created_by"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// Validate checks the mandate holds the account details its scheme needs
+func (m *DirectDebitMandate) Validate(now time.Time) error {
+	m.Reference, m.DebtorName = strings.TrimSpace(m.Reference), strings.TrimSpace(m.DebtorName)
+	if m.Reference == "" || len(m.Reference) > 35 {
+		return fmt.Errorf("%w: mandate reference must be 1 to 35 characters", ErrInvalidInput)
+	}
+	if m.DebtorName == "" {
+		return fmt.Errorf("%w: debtor name is required", ErrInvalidInput)
+	}
+	if m.SignedAt.IsZero() || m.SignedAt.After(now) {
+		return fmt.Errorf("%w: mandate must have been signed already", ErrInvalidInput)
+	}
+	switch m.Scheme {
+	case SchemeSEPA:
+		m.IBAN, m.BIC = directdebit.NormalizeIBAN(m.IBAN), strings.ToUpper(strings.TrimSpace(m.BIC))
+		if !directdebit.ValidIBAN(m.IBAN) {
+			return fmt.Errorf("%w: IBAN %s is not valid", ErrInvalidInput, m.IBAN)
+		}
+	case SchemeACH:
+		if !directdebit.ValidRoutingNumber(m.RoutingNumber) {
+			return fmt.Errorf("%w: routing number %s is not valid", ErrInvalidInput, m.RoutingNumber)
+		}
+		if m.AccountNumber == "" || len(m.AccountNumber) > 17 || strings.IndexFunc(m.AccountNumber, func(r rune) bool { return !unicode.IsDigit(r) }) >= 0 {
+			return fmt.Errorf("%w: account number must be 1 to 17 digits", ErrInvalidInput)
+		}
+		switch m.AccountType {
+		case "":
+			m.AccountType = directdebit.AccountChecking
+		case directdebit.AccountChecking, directdebit.AccountSavings:
+		default:
+			return fmt.Errorf("%w: account type must be checking or savings", ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unknown direct debit scheme %q", ErrInvalidInput, m.Scheme)
+	}
+	return nil
+}
+
+// Revoke stops further collections under the mandate
+func (m *DirectDebitMandate) Revoke(now time.Time) error {
+	if m.Status == MandateRevoked {
+		return fmt.Errorf("%w: mandate %s has already been revoked", ErrInvalidInput, m.Reference)
+	}
+	m.Status, m.RevokedAt = MandateRevoked, &now
+	return nil
+}
+
+// SequenceType returns the SEPA sequence type of the mandate's next
+// collection: first until something has been collected under it
+func (m *DirectDebitMandate) SequenceType() string {
+	if m.FirstCollected == nil {
+		return directdebit.SequenceFirst
+	}
+	return directdebit.SequenceRecurring
+}
+
+type DirectDebitBatchStatus string
+
+const (
+	DirectDebitBatchSubmitted DirectDebitBatchStatus = "submitted" // File generated, outcomes pending
+	DirectDebitBatchSettled   DirectDebitBatchStatus = "settled"   // Every item collected or returned
+)
+
+// DirectDebitBatch is one collection file sent to the bank
+type DirectDebitBatch struct {
+	ID             uuid.UUID              `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID              `gorm:"type:uuid;index" json:"organization_id"`
+	Scheme         DirectDebitScheme      `gorm:"type:varchar(10)" json:"scheme"`
+	BatchNumber    int64                  `json:"batch_number"`
+	MessageID      string                 `gorm:"type:varchar(35)" json:"message_id"`
+	CollectionDate time.Time              `json:"collection_date"`
+	Currency       string                 `gorm:"type:varchar(3)" json:"currency"`
+	TotalAmount    money.Amount           `gorm:"type:decimal(15,2)" json:"total_amount"`
+	ItemCount      int                    `json:"item_count"`
+	Status         DirectDebitBatchStatus `gorm:"type:varchar(20)" json:"status"`
+	FileName       string                 `gorm:"type:varchar(100)" json:"file_name"`
+	File           string                 `gorm:"type:text" json:"-"`
+	CreatedBy      string                 `gorm:"type:varchar(255)" json:"created_by"`
+	Items          []DirectDebitItem      `gorm:"foreignKey:BatchID" json:"items"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+type DirectDebitItemStatus string
+
+const (
+	DirectDebitItemPending   DirectDebitItemStatus = "pending"
+	DirectDebitItemCollected DirectDebitItemStatus = "collected"
+	DirectDebitItemReturned  DirectDebitItemStatus = "returned"
+)
+
+// DirectDebitItem is the collection of one invoice's balance within a batch.
+// SEPA returns quote the end-to-end ID, ACH returns the trace number.
+type DirectDebitItem struct {
+	ID             uuid.UUID             `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID             `gorm:"type:uuid;index" json:"organization_id"`
+	BatchID        uuid.UUID             `gorm:"type:uuid;index" json:"batch_id"`
+	InvoiceID      uuid.UUID             `gorm:"type:uuid;index" json:"invoice_id"`
+	CustomerID     uuid.UUID             `gorm:"type:uuid" json:"customer_id"`
+	MandateID      uuid.UUID             `gorm:"type:uuid" json:"mandate_id"`
+	Amount         money.Amount          `gorm:"type:decimal(15,2)" json:"amount"`
+	Currency       string                `gorm:"type:varchar(3)" json:"currency"`
+	EndToEndID     string                `gorm:"type:varchar(35);index" json:"end_to_end_id,omitempty"`
+	TraceNumber    string                `gorm:"type:varchar(15);index" json:"trace_number,omitempty"`
+	SequenceType   string                `gorm:"type:varchar(4)" json:"sequence_type,omitempty"`
+	Status         DirectDebitItemStatus `gorm:"type:varchar(20);index" json:"status"`
+	ReturnCode     string                `gorm:"type:varchar(10)" json:"return_code,omitempty"`
+	ReturnedAt     *time.Time            `json:"returned_at,omitempty"`
+	PaymentID      *uuid.UUID            `gorm:"type:uuid" json:"payment_id,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// Collect marks the item as paid by paymentID
+func (it *DirectDebitItem) Collect(paymentID uuid.UUID) error {
+	if it.Status != DirectDebitItemPending {
+		return fmt.Errorf("%w: direct debit item %s is already %s", ErrInvalidInput, it.ID, it.Status)
+	}
+	it.Status, it.PaymentID = DirectDebitItemCollected, &paymentID
+	return nil
+}
+
+// Return marks the item as bounced with the bank's reason code. Collected
+// items can still be returned; their payment has to be reversed.
+func (it *DirectDebitItem) Return(code string, now time.Time) error {
+	if it.Status == DirectDebitItemReturned {
+		return fmt.Errorf("%w: direct debit item %s has already been returned", ErrInvalidInput, it.ID)
+	}
+	it.Status, it.ReturnCode, it.ReturnedAt = DirectDebitItemReturned, code, &now
+	return nil
+}
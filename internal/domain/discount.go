@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"fmt"
+
+	"erp-billing-service/pkg/money"
+)
+
+// ResolveDiscount turns a discount as entered into an amount off base: either
+// a fixed amount or a percentage of base, never both. The result is rounded
+// with round and may not exceed base.
+func ResolveDiscount(base, fixed, percent money.Amount, round func(money.Amount) money.Amount) (money.Amount, error) {
+	if fixed.IsNegative() || percent.IsNegative() {
+		return money.Zero, fmt.Errorf("%w: discount cannot be negative", ErrInvalidInput)
+	}
+	if !fixed.IsZero() && !percent.IsZero() {
+		return money.Zero, fmt.Errorf("%w: give either a discount amount or a discount percentage", ErrInvalidInput)
+	}
+	if percent.GreaterThan(money.New(100)) {
+		return money.Zero, fmt.Errorf("%w: discount percentage cannot exceed 100", ErrInvalidInput)
+	}
+
+	discount := round(fixed)
+	if !percent.IsZero() {
+		discount = round(base.Percent(percent))
+	}
+	if discount.GreaterThan(money.Max(base, money.Zero)) {
+		return money.Zero, fmt.Errorf("%w: discount %s exceeds the amount %s", ErrInvalidInput, discount, base)
+	}
+	return discount, nil
+}
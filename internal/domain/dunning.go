@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// DunningStep is one reminder of a schedule, sent DaysFromDue days after
+// the due date, or before it when negative. Level grows as the tone escalates.
+type DunningStep struct {
+	DaysFromDue int    `json:"days_from_due"`
+	Level       int    `json:"level"`
+	Name        string `json:"name"`
+}
+
+// DefaultDunningSteps is the schedule of a policy created without steps
+var DefaultDunningSteps = DunningSteps{
+	{DaysFromDue: -3, Level: 0, Name: "Upcoming payment"},
+	{DaysFromDue: 0, Level: 1, Name: "Payment due"},
+	{DaysFromDue: 7, Level: 2, Name: "First reminder"},
+	{DaysFromDue: 30, Level: 3, Name: "Final notice"},
+}
+
+type DunningSteps []DunningStep
+
+// Value stores the steps as JSON
+func (s DunningSteps) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads the steps from a JSON column
+func (s *DunningSteps) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into DunningSteps", src)
+	}
+}
+
+// DunningPolicy is an organization's reminder schedule. Due dates are
+// calendar dates; they pass at midnight in the policy's time zone.
+// Organizations without a policy have their invoices marked overdue by UTC
+// dates and receive no reminders.
+type DunningPolicy struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;uniqueIndex" json:"organization_id"`
+	TimeZone       string       `gorm:"type:varchar(64);default:'UTC'" json:"time_zone"`
+	Enabled        bool         `gorm:"default:true" json:"enabled"` // Send reminders; overdue marking always runs
+	Steps          DunningSteps `gorm:"type:jsonb" json:"steps"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+const maxDunningSteps = 10
+
+// Validate checks the time zone and sorts the steps by their day
+func (p *DunningPolicy) Validate() error {
+	if p.TimeZone == "" {
+		p.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(p.TimeZone); err != nil {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidInput, p.TimeZone)
+	}
+	if len(p.Steps) > maxDunningSteps {
+		return fmt.Errorf("%w: a schedule has at most %d steps", ErrInvalidInput, maxDunningSteps)
+	}
+
+	sort.SliceStable(p.Steps, func(i, j int) bool { return p.Steps[i].DaysFromDue < p.Steps[j].DaysFromDue })
+	for i, step := range p.Steps {
+		if step.DaysFromDue < -60 || step.DaysFromDue > 365 {
+			return fmt.Errorf("%w: steps run from 60 days before to 365 days after the due date", ErrInvalidInput)
+		}
+		if step.Name == "" || len(step.Name) > 100 {
+			return fmt.Errorf("%w: step names must be 1 to 100 characters", ErrInvalidInput)
+		}
+		if i > 0 && step.DaysFromDue == p.Steps[i-1].DaysFromDue {
+			return fmt.Errorf("%w: two steps run %d days from the due date", ErrInvalidInput, step.DaysFromDue)
+		}
+		if i > 0 && step.Level < p.Steps[i-1].Level {
+			return fmt.Errorf("%w: escalation levels cannot go down from one step to the next", ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// Location returns the policy's time zone, UTC when it cannot be loaded
+func (p *DunningPolicy) Location() *time.Location {
+	if p == nil || p.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Lead returns how many days before the due date the first reminder is sent
+func (p *DunningPolicy) Lead() int {
+	if p == nil || len(p.Steps) == 0 || p.Steps[0].DaysFromDue >= 0 {
+		return 0
+	}
+	return -p.Steps[0].DaysFromDue
+}
+
+// StepFor returns the latest step whose day has come daysFromDue days after
+// the due date, or nil when none has. Steps missed while the invoice was not
+// due for dunning are not sent late; only the latest one is.
+func (p *DunningPolicy) StepFor(daysFromDue int) *DunningStep {
+	var step *DunningStep
+	for i := range p.Steps {
+		if p.Steps[i].DaysFromDue <= daysFromDue {
+			step = &p.Steps[i]
+		}
+	}
+	return step
+}
+
+// DaysFromDue returns how many calendar days today, in loc, is past the
+// invoice's current due date, that of its first unpaid installment when it
+// is paid in installments. It is negative before the due date.
+func DaysFromDue(inv *Invoice, now time.Time, loc *time.Location) int {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	due := inv.CurrentDueDate().UTC()
+	dueDay := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+	return int(today.Sub(dueDay).Hours() / 24)
+}
+
+// DunningReminder records a step sent for an invoice. The unique invoice,
+// due date and step is what keeps a restarted or duplicated scheduler from
+// sending the same reminder twice; a new due date starts the schedule over.
+type DunningReminder struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;index" json:"organization_id"`
+	InvoiceID      uuid.UUID    `gorm:"type:uuid;uniqueIndex:idx_dunning_reminder_step" json:"invoice_id"`
+	DueDate        time.Time    `gorm:"uniqueIndex:idx_dunning_reminder_step" json:"due_date"`
+	DaysFromDue    int          `gorm:"uniqueIndex:idx_dunning_reminder_step" json:"days_from_due"`
+	Level          int          `json:"level"`
+	Name           string       `gorm:"type:varchar(100)" json:"name"`
+	BalanceAmount  money.Amount `gorm:"type:decimal(15,2)" json:"balance_amount"`
+	Currency       string       `gorm:"type:varchar(3)" json:"currency"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
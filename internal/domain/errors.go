@@ -7,5 +7,48 @@ var (
 	ErrExampleNotFound      = errors.New("example not found")
 	ErrExampleAlreadyExists = errors.New("example already exists")
 	ErrInvalidInput         = errors.New("invalid input")
-)
 
+	ErrInvoiceNotFound         = errors.New("invoice not found")
+	ErrInvalidStatusTransition = errors.New("invalid invoice status transition")
+	ErrInvoiceLocked           = errors.New("invoice is locked")
+	ErrInvoiceNotIssued        = errors.New("invoice has not been issued")
+
+	ErrWorkOrderNotFound        = errors.New("work order not found")
+	ErrWorkOrderAlreadyInvoiced = errors.New("work order has already been invoiced")
+
+	ErrTaxCodeNotFound = errors.New("tax code not found")
+
+	ErrPaymentNotFound        = errors.New("payment not found")
+	ErrPaymentAlreadyRecorded = errors.New("payment already recorded")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was used for a different payment")
+
+	ErrPaymentIntentNotFound = errors.New("payment intent not found")
+	ErrInvalidSignature      = errors.New("invalid webhook signature")
+
+	ErrStatementNotFound     = errors.New("bank statement not found")
+	ErrStatementLineNotFound = errors.New("bank statement line not found")
+
+	ErrCreditorNotFound         = errors.New("direct debit creditor not found")
+	ErrMandateNotFound          = errors.New("direct debit mandate not found")
+	ErrDirectDebitBatchNotFound = errors.New("direct debit batch not found")
+	ErrDirectDebitItemNotFound  = errors.New("direct debit item not found")
+
+	ErrDunningPolicyNotFound = errors.New("dunning policy not found")
+
+	ErrLateFeePolicyNotFound = errors.New("late fee policy not found")
+
+	ErrPaymentTermNotFound = errors.New("payment term not found")
+
+	ErrEstimateNotFound          = errors.New("estimate not found")
+	ErrInvalidEstimateTransition = errors.New("invalid estimate status transition")
+	ErrEstimateLocked            = errors.New("estimate is no longer a draft")
+	ErrEstimateConverted         = errors.New("estimate has already been converted")
+
+	ErrProgressBillNotFound = errors.New("progress bill not found")
+	ErrOverBilling          = errors.New("bill exceeds what is left to bill")
+
+	ErrPlanNotFound         = errors.New("subscription plan not found")
+	ErrPlanCodeTaken        = errors.New("subscription plan code is already in use")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrUsageKeyReused       = errors.New("idempotency key was used for different usage")
+)
@@ -0,0 +1,172 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type EstimateStatus string
+
+const (
+	EstimateStatusDraft    EstimateStatus = "draft"
+	EstimateStatusSent     EstimateStatus = "sent"
+	EstimateStatusAccepted EstimateStatus = "accepted"
+	EstimateStatusDeclined EstimateStatus = "declined"
+	EstimateStatusExpired  EstimateStatus = "expired"
+)
+
+// estimateTransitions lists the statuses an estimate may move to. A declined
+// or expired estimate goes back to draft to be revised and sent again.
+var estimateTransitions = map[EstimateStatus][]EstimateStatus{
+	EstimateStatusDraft:    {EstimateStatusSent},
+	EstimateStatusSent:     {EstimateStatusAccepted, EstimateStatusDeclined, EstimateStatusExpired},
+	EstimateStatusDeclined: {EstimateStatusDraft},
+	EstimateStatusExpired:  {EstimateStatusDraft},
+}
+
+// Estimate is a quote sent to a customer before the work is done. It is
+// priced like an invoice and, once accepted, converted into a draft invoice
+// that links back to it.
+type Estimate struct {
+	ID              uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID  uuid.UUID      `gorm:"type:uuid;index;uniqueIndex:idx_estimate_org_number" json:"organization_id"`
+	CustomerID      uuid.UUID      `gorm:"type:uuid;index" json:"customer_id"`
+	ContactID       *uuid.UUID     `gorm:"type:uuid;index" json:"contact_id"`
+	OwnerID         *uuid.UUID     `gorm:"type:uuid;index" json:"owner_id"`
+	Subject         string         `gorm:"type:varchar(255)" json:"subject"`
+	EstimateNumber  string         `gorm:"type:varchar(50);uniqueIndex:idx_estimate_org_number" json:"estimate_number"`
+	ReferenceNo     string         `gorm:"type:varchar(50)" json:"reference_no"`
+	EstimateDate    time.Time      `json:"estimate_date"`
+	ExpiryDate      *time.Time     `json:"expiry_date,omitempty"` // Last day the customer may accept
+	Status          EstimateStatus `gorm:"type:varchar(20);default:'draft';index" json:"status"`
+	SubTotal        money.Amount   `gorm:"type:decimal(15,2)" json:"sub_total"`
+	Discount        money.Amount   `gorm:"type:decimal(15,2);default:0" json:"discount"`        // Estimate-level discount, spread over the lines
+	DiscountPct     money.Amount   `gorm:"type:decimal(9,4);default:0" json:"discount_percent"` // Set when Discount is a percentage
+	DiscountTotal   money.Amount   `gorm:"type:decimal(15,2)" json:"discount_total"`
+	TaxTotal        money.Amount   `gorm:"type:decimal(15,2)" json:"tax_total"`
+	TaxInclusive    bool           `gorm:"default:false" json:"tax_inclusive"`
+	Adjustment      money.Amount   `gorm:"type:decimal(15,2)" json:"adjustment"`
+	TotalAmount     money.Amount   `gorm:"type:decimal(15,2)" json:"total_amount"`
+	Currency        string         `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+	Terms           string         `gorm:"type:text" json:"terms"`
+	Notes           string         `gorm:"type:text" json:"notes"`
+	BillingStreet   string         `gorm:"type:varchar(255)" json:"billing_street"`
+	BillingCity     string         `gorm:"type:varchar(100)" json:"billing_city"`
+	BillingState    string         `gorm:"type:varchar(100)" json:"billing_state"`
+	BillingCode     string         `gorm:"type:varchar(20)" json:"billing_code"`
+	BillingCountry  string         `gorm:"type:varchar(100)" json:"billing_country"`
+	ShippingStreet  string         `gorm:"type:varchar(255)" json:"shipping_street"`
+	ShippingCity    string         `gorm:"type:varchar(100)" json:"shipping_city"`
+	ShippingState   string         `gorm:"type:varchar(100)" json:"shipping_state"`
+	ShippingCode    string         `gorm:"type:varchar(20)" json:"shipping_code"`
+	ShippingCountry string         `gorm:"type:varchar(100)" json:"shipping_country"`
+	Items           []EstimateItem `gorm:"foreignKey:EstimateID" json:"items"`
+	Taxes           []EstimateTax  `gorm:"foreignKey:EstimateID" json:"taxes"`
+	InvoiceID       *uuid.UUID     `gorm:"type:uuid;index" json:"invoice_id,omitempty"` // Invoice the estimate was converted into
+	ConvertedAt     *time.Time     `json:"converted_at,omitempty"`
+	BilledAmount    money.Amount   `gorm:"type:decimal(15,2);default:0" json:"billed_amount"` // Billed so far through progress invoices
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// EstimateItem is a quoted line. It has the columns of an invoice line so
+// that conversion copies it unchanged.
+type EstimateItem struct {
+	ID          uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	EstimateID  uuid.UUID    `gorm:"type:uuid;index" json:"estimate_id"`
+	ItemID      uuid.UUID    `gorm:"type:uuid;index" json:"item_id"`
+	ItemType    string       `gorm:"type:varchar(20);default:'service'" json:"item_type"`
+	Name        string       `gorm:"type:varchar(255)" json:"name"`
+	Description string       `gorm:"type:text" json:"description"`
+	Quantity    float64      `gorm:"type:decimal(15,2)" json:"quantity"`
+	UnitPrice   money.Amount `gorm:"type:decimal(15,2)" json:"unit_price"`
+	Discount    money.Amount `gorm:"type:decimal(15,2)" json:"discount"` // Line discount plus DocDiscount
+	DiscountPct money.Amount `gorm:"type:decimal(9,4);default:0" json:"discount_percent"`
+	DocDiscount money.Amount `gorm:"type:decimal(15,2);default:0" json:"document_discount"`
+	TaxCode     string       `gorm:"type:varchar(50)" json:"tax_code,omitempty"`
+	Tax         money.Amount `gorm:"type:decimal(15,2)" json:"tax"`
+	Total       money.Amount `gorm:"type:decimal(15,2)" json:"total"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// EstimateTax is one row of the estimate's tax breakdown
+type EstimateTax struct {
+	ID            uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	EstimateID    uuid.UUID    `gorm:"type:uuid;index" json:"estimate_id"`
+	TaxRateID     *uuid.UUID   `gorm:"type:uuid;index" json:"tax_rate_id,omitempty"`
+	Code          string       `gorm:"type:varchar(50)" json:"code"`
+	Name          string       `gorm:"type:varchar(100)" json:"name"`
+	Rate          money.Amount `gorm:"type:decimal(9,4)" json:"rate"`
+	Compound      bool         `json:"compound"`
+	TaxableAmount money.Amount `gorm:"type:decimal(15,2)" json:"taxable_amount"`
+	TaxAmount     money.Amount `gorm:"type:decimal(15,2)" json:"tax_amount"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// IsEditable reports whether the estimate's content may still be changed
+func (e *Estimate) IsEditable() bool {
+	return e.Status == EstimateStatusDraft || e.Status == ""
+}
+
+// IsConverted reports whether an invoice has been created from the estimate
+func (e *Estimate) IsConverted() bool {
+	return e.ConvertedAt != nil
+}
+
+// IsExpired reports whether a sent estimate can no longer be accepted on
+// the given day. The expiry date itself is the last day it can be.
+func (e *Estimate) IsExpired(now time.Time) bool {
+	if e.Status != EstimateStatusSent || e.ExpiryDate == nil {
+		return false
+	}
+	y, m, d := e.ExpiryDate.Date()
+	return !now.Before(time.Date(y, m, d+1, 0, 0, 0, 0, e.ExpiryDate.Location()))
+}
+
+// CurrentStatus returns the status the estimate has on the given day, which
+// is expired for a sent estimate past its expiry date even before that has
+// been stored
+func (e *Estimate) CurrentStatus(now time.Time) EstimateStatus {
+	if e.IsExpired(now) {
+		return EstimateStatusExpired
+	}
+	if e.Status == "" {
+		return EstimateStatusDraft
+	}
+	return e.Status
+}
+
+// TransitionTo validates and applies a status change. A sent estimate past
+// its expiry date is treated as expired: it can no longer be accepted or
+// declined, only stored as expired or taken back to draft.
+func (e *Estimate) TransitionTo(to EstimateStatus, now time.Time) error {
+	from := e.CurrentStatus(now)
+	if from == to && from == EstimateStatusExpired {
+		e.Status = to
+		return nil
+	}
+	if e.IsConverted() {
+		return fmt.Errorf("%w: %s", ErrEstimateConverted, e.EstimateNumber)
+	}
+	for _, allowed := range estimateTransitions[from] {
+		if allowed == to {
+			e.Status = to
+			return nil
+		}
+	}
+	return fmt.Errorf("%w from %s to %s", ErrInvalidEstimateTransition, from, to)
+}
+
+// ParseEstimateStatus validates a status string received from a client
+func ParseEstimateStatus(s string) (EstimateStatus, error) {
+	switch status := EstimateStatus(s); status {
+	case EstimateStatusDraft, EstimateStatusSent, EstimateStatusAccepted, EstimateStatusDeclined, EstimateStatusExpired:
+		return status, nil
+	}
+	return "", fmt.Errorf("%w: unknown estimate status %q", ErrInvalidInput, s)
+}
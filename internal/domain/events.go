@@ -29,3 +29,259 @@ type ExampleDeletedEvent struct {
 	Timestamp time.Time
 }
 
+// Billing event types that are not part of the shared event catalogue.
+// They are untyped so they can be passed to shared_events.NewEventMetadata.
+const (
+	EventInvoiceStatusChanged = "invoice.status_changed"
+	EventCreditNoteIssued     = "credit_note.issued"
+	EventDebitNoteIssued      = "debit_note.issued"
+	EventWorkOrderInvoiced    = "work_order.invoiced"
+)
+
+// Customer credit events
+const (
+	EventCustomerCreditRefunded = "customer_credit.refunded"
+)
+
+// Payment events that are not part of the shared event catalogue
+const (
+	EventPaymentReallocated = "payment.reallocated"
+	EventPaymentRefunded    = "payment.refunded"
+	EventPaymentReversed    = "payment.reversed"
+)
+
+// Dunning events for the notification service
+const (
+	EventInvoiceOverdue = "invoice.overdue"
+	EventReminderDue    = "invoice.reminder_due"
+)
+
+// Late fee events
+const (
+	EventLateFeeCharged = "invoice.late_fee_charged"
+)
+
+// Estimate events. Estimates are not an aggregate of the shared catalogue.
+const (
+	AggregateEstimate = "estimate"
+
+	EventEstimateStatusChanged = "estimate.status_changed"
+	EventEstimateConverted     = "estimate.converted"
+)
+
+// Usage events consumed from the services that meter subscriptions, on the
+// billing.usage topic. Subscriptions are not an aggregate of the shared catalogue.
+const (
+	AggregateSubscription = "subscription"
+
+	EventUsageRecorded = "subscription.usage_recorded"
+)
+
+// Work order line events consumed from the work-order service. Service and
+// part lines share one payload and differ only in which item they reference.
+const (
+	EventWorkOrderServiceLineAdded   = "workorder.service_line.added"
+	EventWorkOrderServiceLineUpdated = "workorder.service_line.updated"
+	EventWorkOrderServiceLineRemoved = "workorder.service_line.removed"
+	EventWorkOrderPartLineAdded      = "workorder.part_line.added"
+	EventWorkOrderPartLineUpdated    = "workorder.part_line.updated"
+	EventWorkOrderPartLineRemoved    = "workorder.part_line.removed"
+)
+
+// InvoiceStatusChangedPayload is published whenever an invoice moves between statuses
+type InvoiceStatusChangedPayload struct {
+	InvoiceID      string    `json:"invoice_id"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	OldStatus      string    `json:"old_status"`
+	NewStatus      string    `json:"new_status"`
+	BalanceAmount  float64   `json:"balance_amount"`
+	Currency       string    `json:"currency"`
+	Notes          string    `json:"notes,omitempty"`
+	PerformedBy    string    `json:"performed_by"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// BillingNoteIssuedPayload is published when a credit or debit note is issued against an invoice
+type BillingNoteIssuedPayload struct {
+	NoteID         string    `json:"note_id"`
+	NoteNumber     string    `json:"note_number"`
+	NoteType       string    `json:"note_type"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	InvoiceID      string    `json:"invoice_id"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	TotalAmount    float64   `json:"total_amount"`
+	BalanceAmount  float64   `json:"balance_amount"`
+	Currency       string    `json:"currency"`
+	Reason         string    `json:"reason"`
+	IssueDate      time.Time `json:"issue_date"`
+}
+
+// WorkOrderInvoicedPayload tells the work-order service that a work order has been billed
+type WorkOrderInvoicedPayload struct {
+	WorkOrderID    string  `json:"work_order_id"`
+	OrganizationID string  `json:"organization_id"`
+	InvoiceID      string  `json:"invoice_id"`
+	InvoiceNumber  string  `json:"invoice_number"`
+	TotalAmount    float64 `json:"total_amount"`
+	Currency       string  `json:"currency"`
+	BillingStatus  string  `json:"billing_status"`
+}
+
+// EstimateStatusChangedPayload is published whenever an estimate moves between statuses
+type EstimateStatusChangedPayload struct {
+	EstimateID     string    `json:"estimate_id"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	EstimateNumber string    `json:"estimate_number"`
+	OldStatus      string    `json:"old_status"`
+	NewStatus      string    `json:"new_status"`
+	TotalAmount    float64   `json:"total_amount"`
+	Currency       string    `json:"currency"`
+	PerformedBy    string    `json:"performed_by"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+// EstimateConvertedPayload is published when an estimate is converted into a draft invoice
+type EstimateConvertedPayload struct {
+	EstimateID     string    `json:"estimate_id"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	EstimateNumber string    `json:"estimate_number"`
+	InvoiceID      string    `json:"invoice_id"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	TotalAmount    float64   `json:"total_amount"`
+	Currency       string    `json:"currency"`
+	ConvertedAt    time.Time `json:"converted_at"`
+}
+
+// CustomerCreditRefundedPayload is published when unapplied credit is paid back to a customer
+type CustomerCreditRefundedPayload struct {
+	EntryID        string    `json:"entry_id"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	BalanceAfter   float64   `json:"balance_after"`
+	PaymentMethod  string    `json:"payment_method"`
+	Reference      string    `json:"reference"`
+	RefundedAt     time.Time `json:"refunded_at"`
+}
+
+// PaymentAllocationPayload is the part of a payment applied to one invoice
+type PaymentAllocationPayload struct {
+	InvoiceID string  `json:"invoice_id"`
+	Amount    float64 `json:"amount"`
+}
+
+// PaymentReallocatedPayload is published when a payment's allocations are
+// moved between invoices
+type PaymentReallocatedPayload struct {
+	PaymentID           string                     `json:"payment_id"`
+	OrganizationID      string                     `json:"organization_id"`
+	CustomerID          string                     `json:"customer_id"`
+	Currency            string                     `json:"currency"`
+	AllocatedAmount     float64                    `json:"allocated_amount"`
+	UnappliedAmount     float64                    `json:"unapplied_amount"`
+	PreviousAllocations []PaymentAllocationPayload `json:"previous_allocations"`
+	Allocations         []PaymentAllocationPayload `json:"allocations"`
+	PerformedBy         string                     `json:"performed_by"`
+	ReallocatedAt       time.Time                  `json:"reallocated_at"`
+}
+
+// PaymentRefundedPayload is published when a payment is refunded or
+// reversed. ReopenedInvoices lists how much was taken back off each invoice.
+type PaymentRefundedPayload struct {
+	RefundID         string                     `json:"refund_id"`
+	PaymentID        string                     `json:"payment_id"`
+	OrganizationID   string                     `json:"organization_id"`
+	CustomerID       string                     `json:"customer_id"`
+	RefundType       string                     `json:"refund_type"`
+	Amount           float64                    `json:"amount"`
+	FromCredit       float64                    `json:"from_credit"`
+	Currency         string                     `json:"currency"`
+	PaymentMethod    string                     `json:"payment_method"`
+	Reference        string                     `json:"reference"`
+	Reason           string                     `json:"reason"`
+	PaymentStatus    string                     `json:"payment_status"`
+	ReopenedInvoices []PaymentAllocationPayload `json:"reopened_invoices"`
+	RefundedAt       time.Time                  `json:"refunded_at"`
+}
+
+// InvoiceOverduePayload is published when an invoice's due date passes
+// without it being paid
+type InvoiceOverduePayload struct {
+	InvoiceID      string    `json:"invoice_id"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	DueDate        time.Time `json:"due_date"`
+	BalanceAmount  float64   `json:"balance_amount"`
+	Currency       string    `json:"currency"`
+	DaysOverdue    int       `json:"days_overdue"`
+	OverdueAt      time.Time `json:"overdue_at"`
+}
+
+// ReminderDuePayload asks for a payment reminder to be sent to the customer.
+// Level tells how far the schedule has escalated.
+type ReminderDuePayload struct {
+	ReminderID     string    `json:"reminder_id"`
+	InvoiceID      string    `json:"invoice_id"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	ContactID      string    `json:"contact_id,omitempty"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	DueDate        time.Time `json:"due_date"`
+	DaysFromDue    int       `json:"days_from_due"`
+	Level          int       `json:"level"`
+	StepName       string    `json:"step_name"`
+	BalanceAmount  float64   `json:"balance_amount"`
+	Currency       string    `json:"currency"`
+	RemindedAt     time.Time `json:"reminded_at"`
+}
+
+// LateFeeChargedPayload is published when a late fee or interest is charged
+// on an overdue invoice. FeeInvoiceID is set when the fee was billed on an
+// invoice of its own rather than added to the overdue one.
+type LateFeeChargedPayload struct {
+	ChargeID       string    `json:"charge_id"`
+	InvoiceID      string    `json:"invoice_id"`
+	OrganizationID string    `json:"organization_id"`
+	CustomerID     string    `json:"customer_id"`
+	InvoiceNumber  string    `json:"invoice_number"`
+	FeeInvoiceID   string    `json:"fee_invoice_id,omitempty"`
+	Type           string    `json:"type"`
+	Basis          float64   `json:"basis"`
+	Amount         float64   `json:"amount"`
+	Currency       string    `json:"currency"`
+	AccruedFrom    time.Time `json:"accrued_from"`
+	AccruedThrough time.Time `json:"accrued_through"`
+	ChargedAt      time.Time `json:"charged_at"`
+}
+
+// WorkOrderLinePayload describes a service or part line of a work order.
+// ItemID is the service or part ID; removal events only carry the IDs.
+type WorkOrderLinePayload struct {
+	LineID         string  `json:"line_id"`
+	WorkOrderID    string  `json:"work_order_id"`
+	OrganizationID string  `json:"organization_id"`
+	ItemID         string  `json:"item_id,omitempty"`
+	Description    string  `json:"description"`
+	Quantity       float64 `json:"quantity"`
+	Unit           string  `json:"unit"`
+	ListPrice      float64 `json:"list_price"`
+	LineAmount     float64 `json:"line_amount"`
+}
+
+// UsageRecordedPayload reports metered usage of a subscription. The event ID
+// is used as the idempotency key when the payload carries none.
+type UsageRecordedPayload struct {
+	SubscriptionID string    `json:"subscription_id"`
+	OrganizationID string    `json:"organization_id"`
+	Metric         string    `json:"metric"`
+	Quantity       float64   `json:"quantity"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	IdempotencyKey string    `json:"idempotency_key,omitempty"`
+}
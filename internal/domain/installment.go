@@ -0,0 +1,277 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type InstallmentStatus string
+
+const (
+	InstallmentPending InstallmentStatus = "pending"
+	InstallmentPartial InstallmentStatus = "partial"
+	InstallmentPaid    InstallmentStatus = "paid"
+	InstallmentOverdue InstallmentStatus = "overdue" // Set by the dunning run once the due date has passed
+)
+
+// maxInstallments caps the length of a payment schedule
+const maxInstallments = 120
+
+// Installment is one part of an invoice's payment schedule. What has been
+// settled on the invoice is spread over its installments in order, so
+// PaidAmount always follows the invoice's payments and credit notes.
+type Installment struct {
+	ID             uuid.UUID         `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID         `gorm:"type:uuid;index" json:"organization_id"`
+	InvoiceID      uuid.UUID         `gorm:"type:uuid;uniqueIndex:idx_installment_sequence" json:"invoice_id"`
+	Sequence       int               `gorm:"uniqueIndex:idx_installment_sequence" json:"sequence"`
+	DueDate        time.Time         `gorm:"index" json:"due_date"`
+	Amount         money.Amount      `gorm:"type:decimal(15,2)" json:"amount"`
+	PaidAmount     money.Amount      `gorm:"type:decimal(15,2);default:0" json:"paid_amount"`
+	Status         InstallmentStatus `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// Outstanding returns what is still owed on the installment
+func (i *Installment) Outstanding() money.Amount {
+	return money.Max(i.Amount.Sub(i.PaidAmount), money.Zero)
+}
+
+// HasSchedule reports whether the invoice is paid in installments
+func (inv *Invoice) HasSchedule() bool {
+	return len(inv.Installments) > 0
+}
+
+// CurrentDueDate returns the date the invoice is chased by: the due date of
+// its first installment not paid in full, or DueDate without a schedule or
+// once every installment is paid
+func (inv *Invoice) CurrentDueDate() time.Time {
+	if next := inv.nextInstallment(); next != nil {
+		return next.DueDate
+	}
+	return inv.DueDate
+}
+
+// nextInstallment returns the first installment not paid in full
+func (inv *Invoice) nextInstallment() *Installment {
+	for i := range inv.Installments {
+		if inv.Installments[i].Status != InstallmentPaid {
+			return &inv.Installments[i]
+		}
+	}
+	return nil
+}
+
+// SetInstallments replaces the invoice's payment schedule. The installments
+// must add up to the invoice total and fall due one after another, no
+// earlier than the invoice date; the last due date becomes the invoice's.
+func (inv *Invoice) SetInstallments(installments []Installment) error {
+	switch inv.Status {
+	case InvoiceStatusPaid, InvoiceStatusVoid, InvoiceStatusWrittenOff:
+		return fmt.Errorf("%w: %s is %s", ErrInvalidInput, inv.InvoiceNumber, inv.Status)
+	}
+	if len(installments) < 2 || len(installments) > maxInstallments {
+		return fmt.Errorf("%w: a payment schedule has 2 to %d installments", ErrInvalidInput, maxInstallments)
+	}
+
+	sort.SliceStable(installments, func(i, j int) bool { return installments[i].DueDate.Before(installments[j].DueDate) })
+	total := money.Zero
+	for i := range installments {
+		inst := &installments[i]
+		if !inv.Round(inst.Amount).Equal(inst.Amount) || !inst.Amount.IsPositive() {
+			return fmt.Errorf("%w: installment amounts must be positive and in whole minor units", ErrInvalidInput)
+		}
+		if inst.DueDate.IsZero() || dayOf(inst.DueDate).Before(dayOf(inv.InvoiceDate)) {
+			return fmt.Errorf("%w: installments fall due on or after the invoice date", ErrInvalidInput)
+		}
+		if i > 0 && dayOf(inst.DueDate).Equal(dayOf(installments[i-1].DueDate)) {
+			return fmt.Errorf("%w: two installments fall due on %s", ErrInvalidInput, inst.DueDate.Format("2006-01-02"))
+		}
+		inst.InvoiceID = inv.ID
+		inst.OrganizationID = inv.OrganizationID
+		inst.Sequence = i + 1
+		inst.PaidAmount = money.Zero
+		inst.Status = InstallmentPending
+		total = total.Add(inst.Amount)
+	}
+	if !total.Equal(inv.TotalAmount) {
+		return fmt.Errorf("%w: installments add up to %s, the invoice total is %s", ErrInvalidInput, total, inv.TotalAmount)
+	}
+
+	inv.Installments = installments
+	inv.DueDate = installments[len(installments)-1].DueDate
+	inv.settleInstallments()
+	return nil
+}
+
+// KeepSchedule carries the payment schedule over an edit of the invoice: it
+// refuses a total the installments no longer add up to, and puts back the
+// last installment's due date
+func (inv *Invoice) KeepSchedule() error {
+	total := money.Zero
+	for _, inst := range inv.Installments {
+		total = total.Add(inst.Amount)
+	}
+	if !total.Equal(inv.TotalAmount) {
+		return fmt.Errorf("%w: the payment schedule adds up to %s, replace it to bill %s", ErrInvalidInput, total, inv.TotalAmount)
+	}
+	inv.DueDate = inv.Installments[len(inv.Installments)-1].DueDate
+	return nil
+}
+
+// GenerateInstallments splits total into count installments due every
+// frequency from first. Amounts are as even as the currency allows, any odd
+// minor units going to the first installments.
+func GenerateInstallments(total money.Amount, currency string, count int, first time.Time, frequency RecurringFrequency) ([]Installment, error) {
+	if count < 2 || count > maxInstallments {
+		return nil, fmt.Errorf("%w: a payment schedule has 2 to %d installments", ErrInvalidInput, maxInstallments)
+	}
+	step := map[RecurringFrequency]func(int) time.Time{
+		RecurringWeekly:    func(n int) time.Time { return first.AddDate(0, 0, 7*n) },
+		RecurringMonthly:   func(n int) time.Time { return addMonthsClamped(first, n) },
+		RecurringQuarterly: func(n int) time.Time { return addMonthsClamped(first, 3*n) },
+		RecurringAnnual:    func(n int) time.Time { return addMonthsClamped(first, 12*n) },
+	}[frequency]
+	if step == nil {
+		return nil, fmt.Errorf("%w: installments fall due weekly, monthly, quarterly or annually, not %q", ErrInvalidInput, frequency)
+	}
+
+	weights := make([]money.Amount, count)
+	for i := range weights {
+		weights[i] = money.New(1)
+	}
+	amounts := total.Allocate(currency, weights)
+	installments := make([]Installment, count)
+	for i := range installments {
+		installments[i] = Installment{ID: uuid.New(), DueDate: step(i), Amount: amounts[i]}
+	}
+	return installments, nil
+}
+
+// settleInstallments spreads what has been paid or credited on the invoice
+// over its installments in order. Debit notes and late fees are owed on the
+// invoice's own due date, outside the schedule.
+func (inv *Invoice) settleInstallments() {
+	settled := inv.PaidAmount.Add(inv.CreditedAmount).Add(inv.EarlyPayTaken)
+	for i := range inv.Installments {
+		inst := &inv.Installments[i]
+		inst.PaidAmount = money.Max(money.Min(settled, inst.Amount), money.Zero)
+		settled = settled.Sub(inst.PaidAmount)
+		switch {
+		case inst.PaidAmount.Equal(inst.Amount):
+			inst.Status = InstallmentPaid
+		case inst.Status == InstallmentOverdue:
+		case inst.PaidAmount.IsPositive():
+			inst.Status = InstallmentPartial
+		default:
+			inst.Status = InstallmentPending
+		}
+	}
+}
+
+// InstallmentOverdue reports whether an installment of the invoice is overdue
+func (inv *Invoice) InstallmentOverdue() bool {
+	for _, inst := range inv.Installments {
+		if inst.Status == InstallmentOverdue {
+			return true
+		}
+	}
+	return false
+}
+
+// LapsedInstallments returns the installments whose due date has passed by
+// today, in loc, without being paid or marked overdue
+func (inv *Invoice) LapsedInstallments(now time.Time, loc *time.Location) []*Installment {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	var lapsed []*Installment
+	for i := range inv.Installments {
+		inst := &inv.Installments[i]
+		if inst.Status != InstallmentPaid && inst.Status != InstallmentOverdue && dayOf(inst.DueDate).Before(today) {
+			lapsed = append(lapsed, inst)
+		}
+	}
+	return lapsed
+}
+
+// DueAmount is part of an invoice's balance and the date it falls due
+type DueAmount struct {
+	DueDate time.Time
+	Amount  money.Amount
+}
+
+// DueAmounts splits the invoice's balance by due date: what is left of each
+// installment on its own date, and the rest on the invoice's due date
+func (inv *Invoice) DueAmounts() []DueAmount {
+	balance := money.Max(inv.AmountDue(), money.Zero)
+	var parts []DueAmount
+	for _, inst := range inv.Installments {
+		if !balance.IsPositive() {
+			break
+		}
+		part := money.Min(inst.Outstanding(), balance)
+		if part.IsPositive() {
+			parts = append(parts, DueAmount{DueDate: inst.DueDate, Amount: part})
+			balance = balance.Sub(part)
+		}
+	}
+	if balance.IsPositive() {
+		parts = append(parts, DueAmount{DueDate: inv.DueDate, Amount: balance})
+	}
+	return parts
+}
+
+// AgingBalance is a balance split by how many days it is past due
+type AgingBalance struct {
+	Current    money.Amount
+	Days1To30  money.Amount
+	Days31To60 money.Amount
+	Days61To90 money.Amount
+	Over90     money.Amount
+}
+
+// Age adds what the invoice owes as of asOf, each installment by its own
+// due date
+func (b *AgingBalance) Age(inv *Invoice, asOf time.Time) {
+	for _, part := range inv.DueAmounts() {
+		days := int(dayOf(asOf).Sub(dayOf(part.DueDate)).Hours() / 24)
+		switch {
+		case days <= 0:
+			b.Current = b.Current.Add(part.Amount)
+		case days <= 30:
+			b.Days1To30 = b.Days1To30.Add(part.Amount)
+		case days <= 60:
+			b.Days31To60 = b.Days31To60.Add(part.Amount)
+		case days <= 90:
+			b.Days61To90 = b.Days61To90.Add(part.Amount)
+		default:
+			b.Over90 = b.Over90.Add(part.Amount)
+		}
+	}
+}
+
+// Add adds another aged balance to b
+func (b *AgingBalance) Add(o AgingBalance) {
+	b.Current = b.Current.Add(o.Current)
+	b.Days1To30 = b.Days1To30.Add(o.Days1To30)
+	b.Days31To60 = b.Days31To60.Add(o.Days31To60)
+	b.Days61To90 = b.Days61To90.Add(o.Days61To90)
+	b.Over90 = b.Over90.Add(o.Over90)
+}
+
+// Total returns the whole balance
+func (b AgingBalance) Total() money.Amount {
+	return money.Sum(b.Current, b.Days1To30, b.Days31To60, b.Days61To90, b.Over90)
+}
+
+// dayOf returns the calendar date of t as midnight UTC
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
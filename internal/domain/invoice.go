@@ -3,6 +3,8 @@ package domain
 import (
 	"time"
 
+	"erp-billing-service/pkg/money"
+
 	"github.com/google/uuid"
 )
 
@@ -15,31 +17,50 @@ const (
 	InvoiceStatusPaid    InvoiceStatus = "paid"
 	InvoiceStatusOverdue InvoiceStatus = "overdue"
 	InvoiceStatusVoid    InvoiceStatus = "void"
+
+	InvoiceStatusDisputed   InvoiceStatus = "disputed"
+	InvoiceStatusWrittenOff InvoiceStatus = "written_off"
 )
 
 type Invoice struct {
 	ID              uuid.UUID     `gorm:"type:uuid;primaryKey" json:"id"`
-	OrganizationID  uuid.UUID     `gorm:"type:uuid;index" json:"organization_id"`
+	OrganizationID  uuid.UUID     `gorm:"type:uuid;index;uniqueIndex:idx_invoice_org_issued_number" json:"organization_id"`
 	CustomerID      uuid.UUID     `gorm:"type:uuid;index" json:"customer_id"`
 	ContactID       *uuid.UUID    `gorm:"type:uuid;index" json:"contact_id"`
 	OwnerID         *uuid.UUID    `gorm:"type:uuid;index" json:"owner_id"`
+	WorkOrderID     *uuid.UUID    `gorm:"type:uuid;index" json:"work_order_id,omitempty"`
+	EstimateID      *uuid.UUID    `gorm:"type:uuid;index" json:"estimate_id,omitempty"` // Estimate the invoice was converted from
+	Kind            InvoiceKind   `gorm:"type:varchar(20);default:'standard';index" json:"kind"`
+	ConsolidatedID  *uuid.UUID    `gorm:"type:uuid;index" json:"consolidated_id,omitempty"` // Consolidated invoice this draft was merged into
+	RunID           *uuid.UUID    `gorm:"type:uuid;uniqueIndex" json:"run_id,omitempty"`    // Recurring or subscription run that generated it; one invoice per run
 	Subject         string        `gorm:"type:varchar(255)" json:"subject"`
-	InvoiceNumber   string        `gorm:"type:varchar(50);uniqueIndex" json:"invoice_number"`
+	InvoiceNumber   string        `gorm:"type:varchar(50);uniqueIndex:idx_invoice_org_issued_number,where:invoice_number <> ''" json:"invoice_number"` // Empty until the invoice is issued
 	ReferenceNo     string        `gorm:"type:varchar(50)" json:"reference_no"`
 	SalesOrder      string        `gorm:"type:varchar(50)" json:"sales_order"`
 	PurchaseOrder   string        `gorm:"type:varchar(50)" json:"purchase_order"`
 	InvoiceDate     time.Time     `json:"invoice_date"`
 	DueDate         time.Time     `json:"due_date"`
+	PaymentTermID   *uuid.UUID    `gorm:"type:uuid;index" json:"payment_term_id,omitempty"`
+	EarlyPayPct     money.Amount  `gorm:"type:decimal(9,4);default:0" json:"early_payment_discount_percent"` // From the payment term
+	EarlyPayBy      *time.Time    `json:"early_payment_discount_until,omitempty"`
+	EarlyPayTaken   money.Amount  `gorm:"type:decimal(15,2);default:0" json:"early_payment_discount"` // Discount granted for paying by EarlyPayBy
 	Status          InvoiceStatus `gorm:"type:varchar(20);default:'draft'" json:"status"`
-	SubTotal        float64       `gorm:"type:decimal(15,2)" json:"sub_total"`
-	DiscountTotal   float64       `gorm:"type:decimal(15,2)" json:"discount_total"`
-	TaxTotal        float64       `gorm:"type:decimal(15,2)" json:"tax_total"`
-	Adjustment      float64       `gorm:"type:decimal(15,2)" json:"adjustment"`
-	ExciseDuty      float64       `gorm:"type:decimal(15,2)" json:"excise_duty"`
-	SalesCommission float64       `gorm:"type:decimal(15,2)" json:"sales_commission"`
-	TotalAmount     float64       `gorm:"type:decimal(15,2)" json:"total_amount"`
-	PaidAmount      float64       `gorm:"type:decimal(15,2);default:0" json:"paid_amount"`
-	BalanceAmount   float64       `gorm:"type:decimal(15,2)" json:"balance_amount"`
+	SubTotal        money.Amount  `gorm:"type:decimal(15,2)" json:"sub_total"`
+	Discount        money.Amount  `gorm:"type:decimal(15,2);default:0" json:"discount"`        // Invoice-level discount, spread over the lines
+	DiscountPct     money.Amount  `gorm:"type:decimal(9,4);default:0" json:"discount_percent"` // Set when Discount is a percentage
+	DiscountTotal   money.Amount  `gorm:"type:decimal(15,2)" json:"discount_total"`
+	TaxTotal        money.Amount  `gorm:"type:decimal(15,2)" json:"tax_total"`
+	TaxInclusive    bool          `gorm:"default:false" json:"tax_inclusive"` // Unit prices already include tax
+	Adjustment      money.Amount  `gorm:"type:decimal(15,2)" json:"adjustment"`
+	ExciseDuty      money.Amount  `gorm:"type:decimal(15,2)" json:"excise_duty"`
+	SalesCommission money.Amount  `gorm:"type:decimal(15,2)" json:"sales_commission"`
+	LateFeeTotal    money.Amount  `gorm:"type:decimal(15,2);default:0" json:"late_fee_total"` // Late fee lines added after the due date
+	TotalAmount     money.Amount  `gorm:"type:decimal(15,2)" json:"total_amount"`
+	PaidAmount      money.Amount  `gorm:"type:decimal(15,2);default:0" json:"paid_amount"`
+	CreditedAmount  money.Amount  `gorm:"type:decimal(15,2);default:0" json:"credited_amount"`
+	DebitedAmount   money.Amount  `gorm:"type:decimal(15,2);default:0" json:"debited_amount"`
+	ExcessCredit    money.Amount  `gorm:"type:decimal(15,2);default:0" json:"excess_credit"` // Credit noted beyond what was owed, moved to the customer's credit
+	BalanceAmount   money.Amount  `gorm:"type:decimal(15,2)" json:"balance_amount"`
 	Currency        string        `gorm:"type:varchar(3);default:'USD'" json:"currency"`
 	Terms           string        `gorm:"type:text" json:"terms"`
 	Notes           string        `gorm:"type:text" json:"notes"`
@@ -54,39 +75,118 @@ type Invoice struct {
 	ShippingCode    string        `gorm:"type:varchar(20)" json:"shipping_code"`
 	ShippingCountry string        `gorm:"type:varchar(100)" json:"shipping_country"`
 	Items           []InvoiceItem `gorm:"foreignKey:InvoiceID" json:"items"`
-	Payments        []Payment     `gorm:"foreignKey:InvoiceID" json:"payments"`
+	Taxes           []InvoiceTax  `gorm:"foreignKey:InvoiceID" json:"taxes"`
+	Installments    []Installment `gorm:"foreignKey:InvoiceID" json:"installments,omitempty"` // Payment schedule, in due date order
+	LockedAt        *time.Time    `json:"locked_at,omitempty"`
+	InCollectionAt  *time.Time    `json:"in_collection_at,omitempty"` // Set while a direct debit of the balance is pending
 	CreatedAt       time.Time     `json:"created_at"`
 	UpdatedAt       time.Time     `json:"updated_at"`
 	DeletedAt       *time.Time    `gorm:"index" json:"deleted_at,omitempty"`
 }
 
+// MoneyRounding is applied whenever an invoice amount is reduced to the
+// minor units of its currency. Fixed so totals are reproducible.
+const MoneyRounding = money.RoundHalfUp
+
+// CurrencyCode returns the invoice currency, falling back to the column default
+func (inv *Invoice) CurrencyCode() string {
+	if inv.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return inv.Currency
+}
+
+// Round rounds an amount to the minor units of the invoice currency
+func (inv *Invoice) Round(a money.Amount) money.Amount {
+	return a.Round(inv.CurrencyCode(), MoneyRounding)
+}
+
+// AmountDue returns what the customer owes once notes, payments and any
+// early-payment discount are applied. Credit already moved to the customer's
+// credit balance is not owed back a second time.
+func (inv *Invoice) AmountDue() money.Amount {
+	return inv.TotalAmount.Add(inv.DebitedAmount).Sub(inv.CreditedAmount).Add(inv.ExcessCredit).Sub(inv.PaidAmount).Sub(inv.EarlyPayTaken)
+}
+
+// RecalculateBalance refreshes BalanceAmount from the totals, notes and
+// payments, and what is paid of each installment
+func (inv *Invoice) RecalculateBalance() {
+	inv.BalanceAmount = money.Max(inv.AmountDue(), money.Zero)
+	inv.settleInstallments()
+}
+
+// SettlementStatus returns the status the invoice should have given its
+// current balance. Statuses that are not about settlement are kept, except
+// that an invoice paid in installments is no longer overdue once every
+// lapsed installment is paid.
+func (inv *Invoice) SettlementStatus() InvoiceStatus {
+	switch {
+	case !inv.AmountDue().IsPositive():
+		return InvoiceStatusPaid
+	case inv.Status == InvoiceStatusOverdue && inv.nextInstallment() != nil && !inv.InstallmentOverdue():
+		if inv.PaidAmount.IsPositive() {
+			return InvoiceStatusPartial
+		}
+		return InvoiceStatusSent
+	case inv.PaidAmount.IsPositive():
+		switch inv.Status {
+		case InvoiceStatusOverdue, InvoiceStatusDisputed, InvoiceStatusWrittenOff:
+			return inv.Status
+		default:
+			return InvoiceStatusPartial
+		}
+	case inv.Status == InvoiceStatusPaid || inv.Status == InvoiceStatusPartial:
+		return InvoiceStatusSent
+	default:
+		return inv.Status
+	}
+}
+
 type InvoiceItem struct {
-	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	InvoiceID   uuid.UUID `gorm:"type:uuid;index" json:"invoice_id"`
-	ItemID      uuid.UUID `gorm:"type:uuid;index" json:"item_id"`                      // Reference to Service/Part Read Model
-	ItemType    string    `gorm:"type:varchar(20);default:'service'" json:"item_type"` // 'service' or 'part'
-	Name        string    `gorm:"type:varchar(255)" json:"name"`
-	Description string    `gorm:"type:text" json:"description"`
-	Quantity    float64   `gorm:"type:decimal(15,2)" json:"quantity"`
-	UnitPrice   float64   `gorm:"type:decimal(15,2)" json:"unit_price"`
-	Discount    float64   `gorm:"type:decimal(15,2)" json:"discount"`
-	Tax         float64   `gorm:"type:decimal(15,2)" json:"tax"`
-	Total       float64   `gorm:"type:decimal(15,2)" json:"total"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	InvoiceID   uuid.UUID    `gorm:"type:uuid;index" json:"invoice_id"`
+	ItemID      uuid.UUID    `gorm:"type:uuid;index" json:"item_id"`                      // Reference to Service/Part Read Model
+	ItemType    string       `gorm:"type:varchar(20);default:'service'" json:"item_type"` // 'service' or 'part'
+	Name        string       `gorm:"type:varchar(255)" json:"name"`
+	Description string       `gorm:"type:text" json:"description"`
+	Quantity    float64      `gorm:"type:decimal(15,2)" json:"quantity"`
+	UnitPrice   money.Amount `gorm:"type:decimal(15,2)" json:"unit_price"`
+	Discount    money.Amount `gorm:"type:decimal(15,2)" json:"discount"`                    // Line discount plus DocDiscount
+	DiscountPct money.Amount `gorm:"type:decimal(9,4);default:0" json:"discount_percent"`   // Set when the line discount is a percentage
+	DocDiscount money.Amount `gorm:"type:decimal(15,2);default:0" json:"document_discount"` // Share of the invoice-level discount
+	TaxCode     string       `gorm:"type:varchar(50)" json:"tax_code,omitempty"`            // Tax rate or group code
+	GroupLabel  string       `gorm:"type:varchar(255)" json:"group_label,omitempty"`        // Source of a consolidated line
+	SourceID    *uuid.UUID   `gorm:"type:uuid;index" json:"source_id,omitempty"`            // Work order or draft invoice it came from
+	Tax         money.Amount `gorm:"type:decimal(15,2)" json:"tax"`
+	Total       money.Amount `gorm:"type:decimal(15,2)" json:"total"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
 }
 
+// Payment is money received from a customer. What it pays is recorded in its
+// allocations, so one receipt can settle several invoices.
 type Payment struct {
-	ID             uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	OrganizationID uuid.UUID `gorm:"type:uuid;index" json:"organization_id"`
-	InvoiceID      uuid.UUID `gorm:"type:uuid;index" json:"invoice_id"`
-	Amount         float64   `gorm:"type:decimal(15,2)" json:"amount"`
-	PaymentDate    time.Time `json:"payment_date"`
-	PaymentMethod  string    `gorm:"type:varchar(50)" json:"payment_method"`
-	TransactionRef string    `gorm:"type:varchar(100)" json:"transaction_ref"`
-	Notes          string    `gorm:"type:text" json:"notes"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uuid.UUID           `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID           `gorm:"type:uuid;index;uniqueIndex:idx_payment_org_idempotency" json:"organization_id"`
+	CustomerID     uuid.UUID           `gorm:"type:uuid;index" json:"customer_id"`
+	InvoiceID      *uuid.UUID          `gorm:"type:uuid;index" json:"invoice_id,omitempty"` // Set when the payment was recorded against one invoice
+	Currency       string              `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+	Amount         money.Amount        `gorm:"type:decimal(15,2)" json:"amount"`    // Part allocated to invoices
+	Unapplied      money.Amount        `gorm:"type:decimal(15,2)" json:"unapplied"` // Excess kept as customer credit
+	PaymentDate    time.Time           `json:"payment_date"`
+	PaymentMethod  string              `gorm:"type:varchar(50)" json:"payment_method"`
+	TransactionRef string              `gorm:"type:varchar(100)" json:"transaction_ref"`
+	Notes          string              `gorm:"type:text" json:"notes"`
+	Refunded       money.Amount        `gorm:"type:decimal(15,2);default:0" json:"refunded"` // Paid back or reversed so far
+	Status         PaymentStatus       `gorm:"type:varchar(20);default:'completed'" json:"status"`
+	IdempotencyKey *string             `gorm:"type:varchar(100);uniqueIndex:idx_payment_org_idempotency" json:"idempotency_key,omitempty"` // Client key that makes retries safe
+	Allocations    []PaymentAllocation `gorm:"foreignKey:PaymentID" json:"allocations"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+
+	// FromDeposits is what a credit payment uses up of each held deposit, by
+	// progress bill. It is recorded on the deposits, not on the payment.
+	FromDeposits map[uuid.UUID]money.Amount `gorm:"-" json:"-"`
 }
 
 type InvoiceAuditLog struct {
@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatusTrigger identifies who is asking for a status change. Some moves are
+// only legal as a consequence of money moving (e.g. paid -> partial after a
+// refund) and must not be reachable from the status endpoint.
+type StatusTrigger int
+
+const (
+	// TriggerManual is a user-initiated change through the API
+	TriggerManual StatusTrigger = 1 << iota
+	// TriggerPayment is a change caused by money moving: recording, refunding or
+	// reversing a payment, or issuing a credit or debit note
+	TriggerPayment
+	// TriggerSystem is a change made by a background job such as dunning
+	TriggerSystem
+)
+
+// invoiceTransitions lists the legal moves and which triggers may perform them.
+// Staying in the same status is always allowed and is not listed.
+var invoiceTransitions = map[InvoiceStatus]map[InvoiceStatus]StatusTrigger{
+	InvoiceStatusDraft: {
+		InvoiceStatusSent:    TriggerManual,
+		InvoiceStatusPartial: TriggerPayment,
+		InvoiceStatusPaid:    TriggerPayment,
+		InvoiceStatusVoid:    TriggerManual,
+	},
+	InvoiceStatusSent: {
+		InvoiceStatusPartial:    TriggerPayment,
+		InvoiceStatusPaid:       TriggerPayment,
+		InvoiceStatusOverdue:    TriggerManual | TriggerSystem,
+		InvoiceStatusDisputed:   TriggerManual,
+		InvoiceStatusWrittenOff: TriggerManual | TriggerSystem,
+		InvoiceStatusVoid:       TriggerManual,
+	},
+	InvoiceStatusPartial: {
+		InvoiceStatusSent:       TriggerPayment,
+		InvoiceStatusPaid:       TriggerPayment,
+		InvoiceStatusOverdue:    TriggerManual | TriggerSystem,
+		InvoiceStatusDisputed:   TriggerManual,
+		InvoiceStatusWrittenOff: TriggerManual | TriggerSystem,
+	},
+	InvoiceStatusOverdue: {
+		InvoiceStatusSent:       TriggerPayment | TriggerSystem,
+		InvoiceStatusPartial:    TriggerPayment | TriggerSystem,
+		InvoiceStatusPaid:       TriggerPayment,
+		InvoiceStatusDisputed:   TriggerManual,
+		InvoiceStatusWrittenOff: TriggerManual | TriggerSystem,
+		InvoiceStatusVoid:       TriggerManual,
+	},
+	InvoiceStatusDisputed: {
+		InvoiceStatusSent:       TriggerManual,
+		InvoiceStatusPartial:    TriggerManual | TriggerPayment,
+		InvoiceStatusPaid:       TriggerPayment,
+		InvoiceStatusOverdue:    TriggerManual,
+		InvoiceStatusWrittenOff: TriggerManual,
+		InvoiceStatusVoid:       TriggerManual,
+	},
+	InvoiceStatusPaid: {
+		InvoiceStatusSent:    TriggerPayment,
+		InvoiceStatusPartial: TriggerPayment,
+		InvoiceStatusOverdue: TriggerPayment,
+	},
+	InvoiceStatusWrittenOff: {
+		InvoiceStatusPaid: TriggerPayment,
+	},
+	InvoiceStatusVoid: {},
+}
+
+// InvalidStatusTransitionError is returned when an invoice cannot move between two statuses
+type InvalidStatusTransitionError struct {
+	From   InvoiceStatus
+	To     InvoiceStatus
+	Reason string
+}
+
+func (e *InvalidStatusTransitionError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("invalid invoice status transition from %s to %s: %s", e.From, e.To, e.Reason)
+	}
+	return fmt.Sprintf("invalid invoice status transition from %s to %s", e.From, e.To)
+}
+
+// Is lets callers match any transition error with errors.Is(err, ErrInvalidStatusTransition)
+func (e *InvalidStatusTransitionError) Is(target error) bool {
+	return target == ErrInvalidStatusTransition
+}
+
+// ParseInvoiceStatus validates a status string received from a client
+func ParseInvoiceStatus(s string) (InvoiceStatus, error) {
+	status := InvoiceStatus(s)
+	if _, ok := invoiceTransitions[status]; !ok {
+		return "", fmt.Errorf("%w: unknown invoice status %q", ErrInvalidInput, s)
+	}
+	return status, nil
+}
+
+// IsTerminal reports whether no further changes are expected for the status
+func (s InvoiceStatus) IsTerminal() bool {
+	return s == InvoiceStatusVoid
+}
+
+// CanTransition checks whether trigger may move an invoice from one status to another
+func CanTransition(from, to InvoiceStatus, trigger StatusTrigger) error {
+	if from == to {
+		return nil
+	}
+	allowed, ok := invoiceTransitions[from][to]
+	if !ok || allowed&trigger == 0 {
+		return &InvalidStatusTransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// TransitionTo validates and applies a status change, including the state
+// side effects of the move. The caller persists the invoice and records the
+// audit entry and event.
+func (inv *Invoice) TransitionTo(to InvoiceStatus, trigger StatusTrigger, now time.Time) error {
+	from := inv.Status
+	if from == "" {
+		from = InvoiceStatusDraft
+	}
+	if err := CanTransition(from, to, trigger); err != nil {
+		return err
+	}
+	if to == InvoiceStatusVoid && inv.PaidAmount.IsPositive() {
+		return &InvalidStatusTransitionError{From: from, To: to, Reason: "invoice has payments"}
+	}
+
+	inv.Status = to
+	// Leaving draft issues the invoice; from then on it may only be corrected
+	// through status changes and payments, never edited in place.
+	if from == InvoiceStatusDraft && to != InvoiceStatusDraft && inv.LockedAt == nil {
+		locked := now
+		inv.LockedAt = &locked
+	}
+	return nil
+}
+
+// IsLocked reports whether the invoice has been issued and can no longer be edited or deleted
+func (inv *Invoice) IsLocked() bool {
+	return inv.LockedAt != nil
+}
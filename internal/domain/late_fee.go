@@ -0,0 +1,190 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type LateFeeType string
+
+const (
+	LateFeeFlat     LateFeeType = "flat"           // Amount, charged once
+	LateFeePercent  LateFeeType = "percent"        // Rate percent of the overdue balance, charged once
+	LateFeeInterest LateFeeType = "daily_interest" // Rate percent a year, accrued daily and charged every IntervalDays
+)
+
+type LateFeeMode string
+
+const (
+	LateFeeAsInvoice LateFeeMode = "fee_invoice"  // A separate invoice for the fee
+	LateFeeAsLine    LateFeeMode = "invoice_line" // A late fee line on the overdue invoice
+)
+
+// LateFeeItemType is the item type of late fee lines
+const LateFeeItemType = "late_fee"
+
+// LateFeePolicy is how an organization charges for late payment. Nothing
+// is charged until the invoice has been overdue for more than GraceDays
+// days. Cap bounds the fees charged on one invoice; zero means no cap.
+// Fee invoices are never charged late fees themselves.
+type LateFeePolicy struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;uniqueIndex" json:"organization_id"`
+	Type           LateFeeType  `gorm:"type:varchar(20)" json:"type"`
+	Amount         money.Amount `gorm:"type:decimal(15,2);default:0" json:"amount"`
+	Rate           money.Amount `gorm:"type:decimal(9,4);default:0" json:"rate"`
+	GraceDays      int          `json:"grace_days"`
+	IntervalDays   int          `gorm:"default:30" json:"interval_days"`
+	Cap            money.Amount `gorm:"type:decimal(15,2);default:0" json:"cap"`
+	Mode           LateFeeMode  `gorm:"type:varchar(20)" json:"mode"`
+	Enabled        bool         `gorm:"default:true" json:"enabled"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// Validate checks the policy's amounts for its type
+func (p *LateFeePolicy) Validate() error {
+	switch p.Type {
+	case LateFeeFlat:
+		if !p.Amount.IsPositive() {
+			return fmt.Errorf("%w: flat late fees need a positive amount", ErrInvalidInput)
+		}
+	case LateFeePercent, LateFeeInterest:
+		if !p.Rate.IsPositive() || p.Rate.GreaterThan(money.New(100)) {
+			return fmt.Errorf("%w: rate must be above 0 and at most 100 percent", ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unknown late fee type %q", ErrInvalidInput, p.Type)
+	}
+	switch p.Mode {
+	case "":
+		p.Mode = LateFeeAsInvoice
+	case LateFeeAsInvoice, LateFeeAsLine:
+	default:
+		return fmt.Errorf("%w: unknown late fee mode %q", ErrInvalidInput, p.Mode)
+	}
+	if p.IntervalDays == 0 {
+		p.IntervalDays = 30
+	}
+	if p.GraceDays < 0 || p.IntervalDays < 1 || p.Cap.IsNegative() {
+		return fmt.Errorf("%w: grace days, interval and cap cannot be negative", ErrInvalidInput)
+	}
+	return nil
+}
+
+// LateFeeCharge records a fee charged on an invoice. AccruedThrough is the
+// due date for one-off fees and the last day of accrual for interest; the
+// unique invoice and date make charging idempotent.
+type LateFeeCharge struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;index" json:"organization_id"`
+	InvoiceID      uuid.UUID    `gorm:"type:uuid;uniqueIndex:idx_late_fee_accrual" json:"invoice_id"`
+	AccruedThrough time.Time    `gorm:"uniqueIndex:idx_late_fee_accrual" json:"accrued_through"`
+	AccruedFrom    time.Time    `json:"accrued_from"`
+	Type           LateFeeType  `gorm:"type:varchar(20)" json:"type"`
+	Mode           LateFeeMode  `gorm:"type:varchar(20)" json:"mode"`
+	Basis          money.Amount `gorm:"type:decimal(15,2)" json:"basis"` // Overdue balance the fee was computed on
+	Rate           money.Amount `gorm:"type:decimal(9,4)" json:"rate"`
+	Days           int          `json:"days"`
+	Amount         money.Amount `gorm:"type:decimal(15,2)" json:"amount"`
+	Currency       string       `gorm:"type:varchar(3)" json:"currency"`
+	Capped         bool         `json:"capped"` // The policy's cap reduced the fee
+	FeeInvoiceID   *uuid.UUID   `gorm:"type:uuid;index" json:"fee_invoice_id,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// ComputeLateFee works out the fee due on an overdue invoice today, given
+// the fees charged on it before. It returns nil when nothing is due: within
+// the grace period, once a one-off fee has been charged, before a full
+// interest interval has accrued, or when the cap has been reached. Dates
+// are calendar dates in the organization's time zone.
+func (p *LateFeePolicy) ComputeLateFee(inv *Invoice, today time.Time, previous []LateFeeCharge) *LateFeeCharge {
+	due := inv.DueDate.UTC()
+	dueDay := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+	overdue := int(today.Sub(dueDay).Hours() / 24)
+	// Fees are charged on what is owed for the invoice itself, not on earlier fees
+	balance := money.Max(inv.AmountDue().Sub(inv.LateFeeTotal), money.Zero)
+	if overdue <= p.GraceDays || !balance.IsPositive() {
+		return nil
+	}
+
+	var charged money.Amount
+	from := dueDay
+	for _, c := range previous {
+		charged = charged.Add(c.Amount)
+		if c.AccruedThrough.After(from) {
+			from = c.AccruedThrough
+		}
+	}
+
+	charge := &LateFeeCharge{
+		OrganizationID: inv.OrganizationID,
+		InvoiceID:      inv.ID,
+		Type:           p.Type,
+		Mode:           p.Mode,
+		Basis:          balance,
+		Rate:           p.Rate,
+		Currency:       inv.CurrencyCode(),
+	}
+	switch p.Type {
+	case LateFeeFlat, LateFeePercent:
+		if len(previous) > 0 {
+			return nil
+		}
+		charge.AccruedFrom, charge.AccruedThrough = dueDay, dueDay
+		charge.Amount = p.Amount
+		if p.Type == LateFeePercent {
+			charge.Amount = balance.Percent(p.Rate)
+		}
+	case LateFeeInterest:
+		days := int(today.Sub(from).Hours() / 24)
+		if days < p.IntervalDays {
+			return nil
+		}
+		charge.AccruedFrom, charge.AccruedThrough, charge.Days = from, today, days
+		// Simple interest on the balance as it is now, on a 365-day year
+		charge.Amount = balance.MulDiv(p.Rate.Mul(float64(days)), money.New(36500), MoneyRounding)
+	}
+	charge.Amount = inv.Round(charge.Amount)
+
+	if p.Cap.IsPositive() {
+		left := p.Cap.Sub(charged)
+		if !left.IsPositive() {
+			return nil
+		}
+		if charge.Amount.GreaterThan(left) {
+			charge.Amount, charge.Capped = left, true
+		}
+	}
+	if !charge.Amount.IsPositive() {
+		return nil
+	}
+	return charge
+}
+
+// AddLateFeeLine puts the fee on the invoice as an untaxed line. The fee is
+// kept in LateFeeTotal, apart from Adjustment, and raises the amount due.
+func (inv *Invoice) AddLateFeeLine(charge *LateFeeCharge) {
+	description := fmt.Sprintf("Late fee on balance of %s %s", charge.Basis.StringFixed(charge.Currency), charge.Currency)
+	if charge.Type == LateFeeInterest {
+		description = fmt.Sprintf("Interest at %s%% a year on %s %s for %d days", charge.Rate, charge.Basis.StringFixed(charge.Currency),
+			charge.Currency, charge.Days)
+	}
+	inv.Items = append(inv.Items, InvoiceItem{
+		ID:          uuid.New(),
+		InvoiceID:   inv.ID,
+		ItemType:    LateFeeItemType,
+		Name:        "Late fee",
+		Description: description,
+		Quantity:    1,
+		UnitPrice:   charge.Amount,
+		Total:       charge.Amount,
+	})
+	inv.LateFeeTotal = inv.LateFeeTotal.Add(charge.Amount)
+	inv.TotalAmount = inv.TotalAmount.Add(charge.Amount)
+	inv.RecalculateBalance()
+}
@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DocumentType identifies a numbering series
+type DocumentType string
+
+const (
+	DocumentInvoice    DocumentType = "invoice"
+	DocumentCreditNote DocumentType = "credit_note"
+	DocumentDebitNote  DocumentType = "debit_note"
+	DocumentEstimate   DocumentType = "estimate"
+)
+
+// DocumentTypes lists every numbering series an organization has
+var DocumentTypes = []DocumentType{DocumentInvoice, DocumentCreditNote, DocumentDebitNote, DocumentEstimate}
+
+// IsValid reports whether t is a known document type
+func (t DocumentType) IsValid() bool {
+	_, ok := defaultSequenceFormats[t]
+	return ok
+}
+
+type SequenceReset string
+
+const (
+	ResetNever      SequenceReset = "never"
+	ResetYearly     SequenceReset = "yearly"
+	ResetMonthly    SequenceReset = "monthly"
+	ResetFiscalYear SequenceReset = "fiscal_year"
+)
+
+// Tokens understood by NumberSequence.Format
+const (
+	TokenPrefix     = "{PREFIX}"
+	TokenYear       = "{YYYY}"
+	TokenShortYear  = "{YY}"
+	TokenMonth      = "{MM}"
+	TokenFiscalYear = "{FY}"
+	TokenSequence   = "{SEQ}"
+)
+
+const DefaultSequenceFormat = TokenPrefix + "-" + TokenYear + "-" + TokenSequence
+
+// defaultSequenceFormats gives the prefix of each series until the
+// organization configures its own
+var defaultSequenceFormats = map[DocumentType]string{
+	DocumentInvoice:    "INV",
+	DocumentCreditNote: "CN",
+	DocumentDebitNote:  "DN",
+	DocumentEstimate:   "EST",
+}
+
+// NumberSequence hands out the document numbers of one series of an
+// organization. NextValue is only advanced inside the transaction that stores
+// the numbered document, so a failed insert never burns a number. Invoices
+// are numbered when they are issued rather than as drafts, and issued
+// documents are voided, never deleted, so every series is gap-free.
+type NumberSequence struct {
+	ID              uuid.UUID     `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID  uuid.UUID     `gorm:"type:uuid;uniqueIndex:idx_number_sequence_org_type" json:"organization_id"`
+	DocumentType    DocumentType  `gorm:"type:varchar(30);uniqueIndex:idx_number_sequence_org_type" json:"document_type"`
+	Prefix          string        `gorm:"type:varchar(20)" json:"prefix"`
+	Format          string        `gorm:"type:varchar(100)" json:"format"`
+	Padding         int           `gorm:"default:4" json:"padding"`
+	ResetPeriod     SequenceReset `gorm:"type:varchar(20);default:'never'" json:"reset_period"`
+	FiscalYearStart int           `gorm:"default:1" json:"fiscal_year_start"` // Month the fiscal year starts in
+	NextValue       int64         `gorm:"default:1" json:"next_value"`
+	CurrentPeriod   string        `gorm:"type:varchar(20)" json:"current_period"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// DefaultNumberSequence returns the series used when an organization has not
+// configured one, e.g. INV-2026-0001
+func DefaultNumberSequence(orgID uuid.UUID, docType DocumentType) *NumberSequence {
+	return &NumberSequence{
+		ID:              uuid.New(),
+		OrganizationID:  orgID,
+		DocumentType:    docType,
+		Prefix:          defaultSequenceFormats[docType],
+		Format:          DefaultSequenceFormat,
+		Padding:         4,
+		ResetPeriod:     ResetNever,
+		FiscalYearStart: 1,
+		NextValue:       1,
+	}
+}
+
+// Validate checks that the format produces unique numbers for the reset period
+func (s *NumberSequence) Validate() error {
+	if !s.DocumentType.IsValid() {
+		return fmt.Errorf("%w: unknown document type %q", ErrInvalidInput, s.DocumentType)
+	}
+	if !strings.Contains(s.Format, TokenSequence) {
+		return fmt.Errorf("%w: format must contain %s", ErrInvalidInput, TokenSequence)
+	}
+	if s.Padding < 1 || s.Padding > 12 {
+		return fmt.Errorf("%w: padding must be between 1 and 12", ErrInvalidInput)
+	}
+	if s.FiscalYearStart < 1 || s.FiscalYearStart > 12 {
+		return fmt.Errorf("%w: fiscal_year_start must be a month between 1 and 12", ErrInvalidInput)
+	}
+	if s.NextValue < 1 {
+		return fmt.Errorf("%w: next_value must be positive", ErrInvalidInput)
+	}
+
+	hasYear := strings.Contains(s.Format, TokenYear) || strings.Contains(s.Format, TokenShortYear)
+	switch s.ResetPeriod {
+	case ResetNever:
+	case ResetYearly:
+		if !hasYear {
+			return fmt.Errorf("%w: a yearly series needs %s or %s in its format", ErrInvalidInput, TokenYear, TokenShortYear)
+		}
+	case ResetMonthly:
+		if !hasYear || !strings.Contains(s.Format, TokenMonth) {
+			return fmt.Errorf("%w: a monthly series needs a year and %s in its format", ErrInvalidInput, TokenMonth)
+		}
+	case ResetFiscalYear:
+		if !strings.Contains(s.Format, TokenFiscalYear) {
+			return fmt.Errorf("%w: a fiscal year series needs %s in its format", ErrInvalidInput, TokenFiscalYear)
+		}
+	default:
+		return fmt.Errorf("%w: unknown reset period %q", ErrInvalidInput, s.ResetPeriod)
+	}
+	return nil
+}
+
+// FiscalYear returns the fiscal year containing t, named after the calendar
+// year in which it ends
+func (s *NumberSequence) FiscalYear(t time.Time) int {
+	if s.FiscalYearStart > 1 && int(t.Month()) >= s.FiscalYearStart {
+		return t.Year() + 1
+	}
+	return t.Year()
+}
+
+// Period returns the counter period that t falls in
+func (s *NumberSequence) Period(t time.Time) string {
+	switch s.ResetPeriod {
+	case ResetYearly:
+		return strconv.Itoa(t.Year())
+	case ResetMonthly:
+		return t.Format("2006-01")
+	case ResetFiscalYear:
+		return "FY" + strconv.Itoa(s.FiscalYear(t))
+	default:
+		return ""
+	}
+}
+
+// Render formats value as a document number allocated at t
+func (s *NumberSequence) Render(value int64, t time.Time) string {
+	seq := fmt.Sprintf("%0*d", s.Padding, value)
+	return strings.NewReplacer(
+		TokenPrefix, s.Prefix,
+		TokenYear, strconv.Itoa(t.Year()),
+		TokenShortYear, fmt.Sprintf("%02d", t.Year()%100),
+		TokenMonth, fmt.Sprintf("%02d", int(t.Month())),
+		TokenFiscalYear, strconv.Itoa(s.FiscalYear(t)),
+		TokenSequence, seq,
+	).Replace(s.Format)
+}
+
+// Preview returns the number the next document allocated at t would get,
+// without advancing the sequence
+func (s *NumberSequence) Preview(t time.Time) string {
+	value := s.NextValue
+	if s.Period(t) != s.CurrentPeriod {
+		value = 1
+	}
+	return s.Render(value, t)
+}
+
+// Advance allocates the next number at time t, restarting the counter when t
+// falls in a new period. Numbers are dated by when they are allocated, not by
+// the document date, so a back-dated document cannot reopen a closed period.
+func (s *NumberSequence) Advance(t time.Time) string {
+	if period := s.Period(t); period != s.CurrentPeriod {
+		s.CurrentPeriod = period
+		s.NextValue = 1
+	}
+	number := s.Render(s.NextValue, t)
+	s.NextValue++
+	return number
+}
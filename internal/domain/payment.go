@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+)
+
+// Payment methods accepted when recording a payment
+const (
+	PaymentMethodCash         = "cash"
+	PaymentMethodCheck        = "check"
+	PaymentMethodBankTransfer = "bank_transfer"
+	PaymentMethodCard         = "card"
+	PaymentMethodOther        = "other"
+	PaymentMethodDirectDebit  = "direct_debit"
+)
+
+var paymentMethods = map[string]bool{
+	PaymentMethodCash:         true,
+	PaymentMethodCheck:        true,
+	PaymentMethodBankTransfer: true,
+	PaymentMethodCard:         true,
+	PaymentMethodOther:        true,
+	PaymentMethodDirectDebit:  true,
+}
+
+// IsValidPaymentMethod reports whether method is a known payment method
+func IsValidPaymentMethod(method string) bool {
+	return paymentMethods[method]
+}
+
+// ValidatePayment checks a payment of amount received at paidAt against the
+// invoice it settles. The amount must already be rounded to the invoice
+// currency. Paying more than is due is allowed, the excess becomes customer
+// credit, but credit itself can only be applied up to the amount due.
+func ValidatePayment(inv *Invoice, amount money.Amount, method string, paidAt, now time.Time) error {
+	if err := ValidateReceipt(amount, method, paidAt, now); err != nil {
+		return err
+	}
+	due := inv.AmountDue()
+	if !due.IsPositive() {
+		return fmt.Errorf("%w: nothing is due on %s", ErrInvalidInput, inv.InvoiceNumber)
+	}
+	if method == PaymentMethodCredit && amount.GreaterThan(due) {
+		return fmt.Errorf("%w: credit of %s exceeds the %s due on %s", ErrInvalidInput, amount, due, inv.InvoiceNumber)
+	}
+	return nil
+}
+
+// ValidateReceipt checks the parts of a payment that do not depend on what
+// it is applied to
+func ValidateReceipt(amount money.Amount, method string, paidAt, now time.Time) error {
+	if method != PaymentMethodCredit && !IsValidPaymentMethod(method) {
+		return fmt.Errorf("%w: unknown payment method %q", ErrInvalidInput, method)
+	}
+	if !amount.IsPositive() {
+		return fmt.Errorf("%w: payment amount must be positive", ErrInvalidInput)
+	}
+	if paidAt.After(now) {
+		return fmt.Errorf("%w: payment date cannot be in the future", ErrInvalidInput)
+	}
+	return nil
+}
+
+// ApplyPayment settles as much of the amount due as amount covers and
+// returns the part left over
+func (inv *Invoice) ApplyPayment(amount money.Amount) (applied, unapplied money.Amount) {
+	applied = money.Min(amount, money.Max(inv.AmountDue(), money.Zero))
+	inv.PaidAmount = inv.PaidAmount.Add(applied)
+	inv.RecalculateBalance()
+	return applied, amount.Sub(applied)
+}
+
+// UnapplyPayment takes amount back off the invoice's paid amount, re-opening
+// its balance
+func (inv *Invoice) UnapplyPayment(amount money.Amount) {
+	inv.PaidAmount = inv.PaidAmount.Sub(amount)
+	inv.RecalculateBalance()
+}
+
+// Received returns the amount of the payment that has not been refunded or
+// reversed, allocated or not
+func (p *Payment) Received() money.Amount {
+	return p.Amount.Add(p.Unapplied)
+}
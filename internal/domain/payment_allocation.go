@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// AllocationStrategy decides how a payment received from a customer is
+// spread over their invoices
+type AllocationStrategy string
+
+const (
+	AllocateOldestFirst AllocationStrategy = "oldest_first" // Earliest due invoices are settled first
+	AllocateExactMatch  AllocationStrategy = "exact_match"  // Only an invoice whose amount due equals the payment
+	AllocateManual      AllocationStrategy = "manual"       // The caller lists the allocations
+)
+
+// ParseAllocationStrategy validates a strategy received from a client
+func ParseAllocationStrategy(s string) (AllocationStrategy, error) {
+	switch strategy := AllocationStrategy(s); strategy {
+	case AllocateOldestFirst, AllocateExactMatch, AllocateManual:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("%w: unknown allocation strategy %q", ErrInvalidInput, s)
+	}
+}
+
+// OpenInvoiceStatuses are the statuses of invoices that take automatically
+// allocated payments. Drafts and disputed invoices are only paid on purpose.
+var OpenInvoiceStatuses = []InvoiceStatus{InvoiceStatusSent, InvoiceStatusPartial, InvoiceStatusOverdue}
+
+// PaymentAllocation is the part of a payment applied to one invoice. The
+// invoice's PaidAmount is the sum of its allocations.
+type PaymentAllocation struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;index" json:"organization_id"`
+	PaymentID      uuid.UUID    `gorm:"type:uuid;uniqueIndex:idx_payment_allocation_invoice" json:"payment_id"`
+	InvoiceID      uuid.UUID    `gorm:"type:uuid;index;uniqueIndex:idx_payment_allocation_invoice" json:"invoice_id"`
+	Amount         money.Amount `gorm:"type:decimal(15,2)" json:"amount"`
+	CreatedBy      string       `gorm:"type:varchar(255)" json:"created_by"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// PlanAllocations spreads amount over invoices with an automatic strategy.
+// Invoices with nothing due are skipped; what cannot be allocated is left
+// for the caller to keep as credit.
+func PlanAllocations(strategy AllocationStrategy, amount money.Amount, invoices []*Invoice) ([]PaymentAllocation, error) {
+	open := make([]*Invoice, 0, len(invoices))
+	for _, inv := range invoices {
+		if inv.AmountDue().IsPositive() {
+			open = append(open, inv)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool { return dueBefore(open[i], open[j]) })
+
+	switch strategy {
+	case AllocateOldestFirst:
+		var allocations []PaymentAllocation
+		remaining := amount
+		for _, inv := range open {
+			if !remaining.IsPositive() {
+				break
+			}
+			part := money.Min(remaining, inv.AmountDue())
+			allocations = append(allocations, PaymentAllocation{InvoiceID: inv.ID, Amount: part})
+			remaining = remaining.Sub(part)
+		}
+		return allocations, nil
+	case AllocateExactMatch:
+		// No guessing: a payment that matches no invoice stays unallocated
+		for _, inv := range open {
+			if inv.AmountDue().Equal(amount) {
+				return []PaymentAllocation{{InvoiceID: inv.ID, Amount: amount}}, nil
+			}
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: %q is not an automatic allocation strategy", ErrInvalidInput, strategy)
+	}
+}
+
+// dueBefore orders invoices by current due date, then invoice date and number
+func dueBefore(a, b *Invoice) bool {
+	if due, other := a.CurrentDueDate(), b.CurrentDueDate(); !due.Equal(other) {
+		return due.Before(other)
+	}
+	if !a.InvoiceDate.Equal(b.InvoiceDate) {
+		return a.InvoiceDate.Before(b.InvoiceDate)
+	}
+	return a.InvoiceNumber < b.InvoiceNumber
+}
+
+// Allocate replaces the payment's allocations with allocations and moves the
+// paid amounts of the invoices to match. invoices must hold every invoice the
+// payment was or will be allocated to; they are updated in place and are
+// only consistent when no error is returned. Whatever is left over becomes
+// unapplied credit, except on payments made from credit, which must be
+// allocated in full.
+func (p *Payment) Allocate(allocations []PaymentAllocation, invoices map[uuid.UUID]*Invoice, performedBy string) error {
+	for _, a := range p.Allocations {
+		inv, ok := invoices[a.InvoiceID]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrInvoiceNotFound, a.InvoiceID)
+		}
+		inv.UnapplyPayment(a.Amount)
+	}
+
+	received := p.Received()
+	var allocated money.Amount
+	next := make([]PaymentAllocation, 0, len(allocations))
+	seen := make(map[uuid.UUID]bool, len(allocations))
+	for _, a := range allocations {
+		inv, ok := invoices[a.InvoiceID]
+		if !ok || inv.OrganizationID != p.OrganizationID {
+			return fmt.Errorf("%w: %s", ErrInvoiceNotFound, a.InvoiceID)
+		}
+		if seen[inv.ID] {
+			return fmt.Errorf("%w: %s is allocated more than once", ErrInvalidInput, inv.InvoiceNumber)
+		}
+		seen[inv.ID] = true
+		if inv.CustomerID != p.CustomerID {
+			return fmt.Errorf("%w: invoice %s belongs to another customer", ErrInvalidInput, inv.InvoiceNumber)
+		}
+		if inv.CurrencyCode() != p.Currency {
+			return fmt.Errorf("%w: invoice %s is in %s, the payment in %s", ErrInvalidInput, inv.InvoiceNumber, inv.CurrencyCode(), p.Currency)
+		}
+		amount := inv.Round(a.Amount)
+		if !amount.IsPositive() {
+			return fmt.Errorf("%w: allocation to %s must be positive", ErrInvalidInput, inv.InvoiceNumber)
+		}
+		if due := inv.AmountDue(); amount.GreaterThan(due) {
+			return fmt.Errorf("%w: allocation of %s exceeds the %s due on %s", ErrInvalidInput, amount, money.Max(due, money.Zero), inv.InvoiceNumber)
+		}
+
+		inv.ApplyPayment(amount)
+		allocated = allocated.Add(amount)
+		next = append(next, PaymentAllocation{
+			ID:             uuid.New(),
+			OrganizationID: p.OrganizationID,
+			PaymentID:      p.ID,
+			InvoiceID:      inv.ID,
+			Amount:         amount,
+			CreatedBy:      performedBy,
+		})
+	}
+
+	if allocated.GreaterThan(received) {
+		return fmt.Errorf("%w: allocations of %s exceed the payment of %s", ErrInvalidInput, allocated, received)
+	}
+	if p.PaymentMethod == PaymentMethodCredit && !allocated.Equal(received) {
+		return fmt.Errorf("%w: credit must be applied in full", ErrInvalidInput)
+	}
+
+	p.Allocations = next
+	p.Amount = allocated
+	p.Unapplied = received.Sub(allocated)
+	return nil
+}
+
+// AllocatedTo returns the part of the payment applied to an invoice
+func (p *Payment) AllocatedTo(invoiceID uuid.UUID) money.Amount {
+	for _, a := range p.Allocations {
+		if a.InvoiceID == invoiceID {
+			return a.Amount
+		}
+	}
+	return money.Zero
+}
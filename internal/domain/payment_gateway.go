@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type PaymentIntentStatus string
+
+const (
+	PaymentIntentRequiresCapture PaymentIntentStatus = "requires_capture"
+	PaymentIntentSucceeded       PaymentIntentStatus = "succeeded"
+	PaymentIntentFailed          PaymentIntentStatus = "failed"
+)
+
+// PaymentIntent is a payment being collected online through a gateway. The
+// provider keeps the organization and invoice it was created for and echoes
+// them back on capture and in webhooks.
+type PaymentIntent struct {
+	ID             string
+	Provider       string
+	OrganizationID uuid.UUID
+	InvoiceID      uuid.UUID
+	Amount         money.Amount
+	Currency       string
+	PaymentMethod  string // card or bank_transfer
+	Status         PaymentIntentStatus
+	ClientSecret   string // Handed to the browser to confirm the payment
+	TransactionRef string // Provider reference of the captured charge
+	CreatedAt      time.Time
+}
+
+type PaymentIntentRequest struct {
+	OrganizationID uuid.UUID
+	InvoiceID      uuid.UUID
+	Amount         money.Amount
+	Currency       string
+	PaymentMethod  string
+	IdempotencyKey string
+}
+
+// GatewayRefund is money sent back to the payer by the provider
+type GatewayRefund struct {
+	ID             string
+	TransactionRef string
+	Amount         money.Amount
+	Currency       string
+	CreatedAt      time.Time
+}
+
+type GatewayEventType string
+
+const (
+	GatewayEventPaymentSucceeded GatewayEventType = "payment.succeeded"
+	GatewayEventPaymentFailed    GatewayEventType = "payment.failed"
+)
+
+// GatewayEvent is a webhook notification whose signature has been verified
+type GatewayEvent struct {
+	ID             string
+	Type           GatewayEventType
+	Provider       string
+	IntentID       string
+	TransactionRef string
+	OrganizationID uuid.UUID
+	InvoiceID      uuid.UUID
+	Amount         money.Amount
+	Currency       string
+	PaymentMethod  string
+	OccurredAt     time.Time
+}
+
+// GatewayIdempotencyKey is the idempotency key of the payment recorded for a
+// provider transaction, so a capture and its webhook record it only once
+func GatewayIdempotencyKey(provider, transactionRef string) string {
+	return provider + ":" + transactionRef
+}
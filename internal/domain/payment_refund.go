@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentStatusCompleted         PaymentStatus = "completed"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	PaymentStatusReversed          PaymentStatus = "reversed"
+)
+
+type RefundType string
+
+const (
+	// RefundTypeRefund is money paid back to the customer
+	RefundTypeRefund RefundType = "refund"
+	// RefundTypeReversal undoes a payment that never arrived, such as a
+	// bounced check or a chargeback
+	RefundTypeReversal RefundType = "reversal"
+)
+
+// PaymentRefund is money taken back out of a payment. The invoices the
+// payment settled are re-opened by the amount taken off their allocations.
+type PaymentRefund struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;index" json:"organization_id"`
+	PaymentID      uuid.UUID    `gorm:"type:uuid;index" json:"payment_id"`
+	CustomerID     uuid.UUID    `gorm:"type:uuid;index" json:"customer_id"`
+	RefundType     RefundType   `gorm:"type:varchar(20)" json:"refund_type"`
+	Amount         money.Amount `gorm:"type:decimal(15,2)" json:"amount"`
+	FromCredit     money.Amount `gorm:"type:decimal(15,2)" json:"from_credit"` // Part that came out of the payment's unapplied credit
+	Currency       string       `gorm:"type:varchar(3)" json:"currency"`
+	RefundDate     time.Time    `json:"refund_date"`
+	PaymentMethod  string       `gorm:"type:varchar(50)" json:"payment_method"` // How a refund was paid back; empty for reversals
+	Reference      string       `gorm:"type:varchar(100)" json:"reference"`
+	Reason         string       `gorm:"type:text" json:"reason"`
+	CreatedBy      string       `gorm:"type:varchar(255)" json:"created_by"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// Refund takes refund.Amount back out of the payment, or everything that is
+// left of it for a reversal. The unapplied part, which is still the
+// customer's credit, goes first; the rest comes off the allocations, most
+// recent first. It returns the allocations the payment keeps, which the
+// caller passes to Allocate to re-open the invoices.
+func (p *Payment) Refund(refund *PaymentRefund) ([]PaymentAllocation, error) {
+	if p.Status == PaymentStatusReversed {
+		return nil, fmt.Errorf("%w: payment %s has been reversed", ErrInvalidInput, p.ID)
+	}
+
+	switch refund.RefundType {
+	case RefundTypeReversal:
+		refund.Amount = p.Received()
+	case RefundTypeRefund:
+		if p.PaymentMethod == PaymentMethodCredit {
+			return nil, fmt.Errorf("%w: credit applied to an invoice is reversed, not refunded", ErrInvalidInput)
+		}
+		refund.Amount = refund.Amount.Round(p.Currency, MoneyRounding)
+		if refund.Amount.GreaterThan(p.Received()) {
+			return nil, fmt.Errorf("%w: refund of %s exceeds the %s left of payment %s", ErrInvalidInput, refund.Amount, p.Received(), p.ID)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown refund type %q", ErrInvalidInput, refund.RefundType)
+	}
+	if !refund.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: nothing to take back from payment %s", ErrInvalidInput, p.ID)
+	}
+
+	refund.FromCredit = money.Min(refund.Amount, p.Unapplied)
+	remaining := refund.Amount.Sub(refund.FromCredit)
+	kept := make([]PaymentAllocation, len(p.Allocations))
+	copy(kept, p.Allocations)
+	for i := len(kept) - 1; i >= 0 && remaining.IsPositive(); i-- {
+		part := money.Min(remaining, kept[i].Amount)
+		kept[i].Amount = kept[i].Amount.Sub(part)
+		remaining = remaining.Sub(part)
+	}
+	allocations := kept[:0]
+	for _, a := range kept {
+		if a.Amount.IsPositive() {
+			allocations = append(allocations, a)
+		}
+	}
+
+	// Lower what the payment holds; Allocate splits the rest again
+	p.Unapplied = p.Unapplied.Sub(refund.Amount)
+	p.Refunded = p.Refunded.Add(refund.Amount)
+	switch {
+	case refund.RefundType == RefundTypeReversal:
+		p.Status = PaymentStatusReversed
+	case p.Received().IsZero():
+		p.Status = PaymentStatusRefunded
+	default:
+		p.Status = PaymentStatusPartiallyRefunded
+	}
+	return allocations, nil
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type PaymentTermType string
+
+const (
+	PaymentTermNet          PaymentTermType = "net"            // Due Days after the invoice date
+	PaymentTermEndOfMonth   PaymentTermType = "end_of_month"   // Due Days after the end of the invoice month
+	PaymentTermDueOnReceipt PaymentTermType = "due_on_receipt" // Due on the invoice date
+)
+
+// PaymentTerm is a named rule an organization gives its invoices due dates
+// by, e.g. "Net 30" or "2/10 Net 30". DiscountPct is an early-payment
+// discount for invoices settled within DiscountDays of the invoice date.
+// At most one term of an organization is its default, used for customers
+// without a term of their own.
+type PaymentTerm struct {
+	ID             uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID       `gorm:"type:uuid;uniqueIndex:idx_payment_term_org_code;uniqueIndex:idx_payment_term_default,where:is_default" json:"organization_id"`
+	Code           string          `gorm:"type:varchar(30);uniqueIndex:idx_payment_term_org_code" json:"code"`
+	Name           string          `gorm:"type:varchar(100)" json:"name"`
+	Type           PaymentTermType `gorm:"type:varchar(20)" json:"type"`
+	Days           int             `json:"days"`
+	DiscountPct    money.Amount    `gorm:"type:decimal(9,4);default:0" json:"discount_percent"`
+	DiscountDays   int             `json:"discount_days"`
+	IsDefault      bool            `gorm:"default:false" json:"is_default"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// StandardPaymentTerms is the catalog an organization can start from
+var StandardPaymentTerms = []PaymentTerm{
+	{Code: "DUE_ON_RECEIPT", Name: "Due on receipt", Type: PaymentTermDueOnReceipt},
+	{Code: "NET15", Name: "Net 15", Type: PaymentTermNet, Days: 15},
+	{Code: "NET30", Name: "Net 30", Type: PaymentTermNet, Days: 30},
+	{Code: "NET60", Name: "Net 60", Type: PaymentTermNet, Days: 60},
+	{Code: "EOM", Name: "End of month", Type: PaymentTermEndOfMonth},
+	{Code: "EOM15", Name: "End of month + 15", Type: PaymentTermEndOfMonth, Days: 15},
+	{Code: "2_10_NET30", Name: "2/10 Net 30", Type: PaymentTermNet, Days: 30, DiscountPct: money.New(2), DiscountDays: 10},
+}
+
+// Validate checks a term before it is stored
+func (t *PaymentTerm) Validate() error {
+	if t.Code == "" || t.Name == "" || len(t.Code) > 30 || len(t.Name) > 100 {
+		return fmt.Errorf("%w: payment term needs a code of up to 30 and a name of up to 100 characters", ErrInvalidInput)
+	}
+	switch t.Type {
+	case PaymentTermNet, PaymentTermEndOfMonth:
+	case PaymentTermDueOnReceipt:
+		if t.Days != 0 {
+			return fmt.Errorf("%w: invoices due on receipt have no days", ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unknown payment term type %q", ErrInvalidInput, t.Type)
+	}
+	if t.Days < 0 || t.Days > 365 {
+		return fmt.Errorf("%w: payment term days must be between 0 and 365", ErrInvalidInput)
+	}
+	if t.DiscountPct.IsNegative() || !t.DiscountPct.LessThan(money.New(100)) {
+		return fmt.Errorf("%w: early-payment discount must be at least 0 and below 100 percent", ErrInvalidInput)
+	}
+	if t.DiscountPct.IsPositive() && (t.DiscountDays < 1 || t.Type == PaymentTermDueOnReceipt) {
+		return fmt.Errorf("%w: an early-payment discount needs a discount period", ErrInvalidInput)
+	}
+	if !t.DiscountPct.IsPositive() {
+		t.DiscountDays = 0
+	}
+	return nil
+}
+
+// DueDate returns the due date of an invoice dated invoiceDate
+func (t *PaymentTerm) DueDate(invoiceDate time.Time) time.Time {
+	switch t.Type {
+	case PaymentTermEndOfMonth:
+		// Day 0 of the next month is the last day of this one
+		endOfMonth := time.Date(invoiceDate.Year(), invoiceDate.Month()+1, 0, 0, 0, 0, 0, invoiceDate.Location())
+		return endOfMonth.AddDate(0, 0, t.Days)
+	case PaymentTermDueOnReceipt:
+		return invoiceDate
+	default:
+		return invoiceDate.AddDate(0, 0, t.Days)
+	}
+}
+
+// ApplyTo gives the invoice the term and its early-payment discount. The due
+// date is only worked out when the invoice has none, so one entered by hand
+// wins. Invoices keep the discount they were issued with when the term changes.
+func (t *PaymentTerm) ApplyTo(inv *Invoice) {
+	inv.PaymentTermID = &t.ID
+	if inv.DueDate.IsZero() {
+		inv.DueDate = t.DueDate(inv.InvoiceDate)
+	}
+	inv.EarlyPayPct = money.Zero
+	inv.EarlyPayBy = nil
+	if t.DiscountPct.IsPositive() {
+		by := inv.InvoiceDate.AddDate(0, 0, t.DiscountDays)
+		inv.EarlyPayPct = t.DiscountPct
+		inv.EarlyPayBy = &by
+	}
+}
+
+// EarlyPayDiscount returns the discount a payment of amount made at paidAt
+// earns: the invoice's discount percentage of its total, when the payment
+// arrives by the end of the discount period and settles the rest of the
+// invoice. It is zero otherwise, and once a discount has been taken.
+func (inv *Invoice) EarlyPayDiscount(amount money.Amount, paidAt time.Time) money.Amount {
+	if inv.EarlyPayBy == nil || !inv.EarlyPayPct.IsPositive() || !inv.EarlyPayTaken.IsZero() {
+		return money.Zero
+	}
+	by := inv.EarlyPayBy.UTC()
+	paid := paidAt.UTC()
+	if time.Date(paid.Year(), paid.Month(), paid.Day(), 0, 0, 0, 0, time.UTC).After(time.Date(by.Year(), by.Month(), by.Day(), 0, 0, 0, 0, time.UTC)) {
+		return money.Zero
+	}
+	discount := money.Min(inv.Round(inv.TotalAmount.Percent(inv.EarlyPayPct)), inv.AmountDue())
+	if !discount.IsPositive() || amount.LessThan(inv.AmountDue().Sub(discount)) {
+		return money.Zero
+	}
+	return discount
+}
+
+// TakeEarlyPayDiscount takes the discount off the amount due
+func (inv *Invoice) TakeEarlyPayDiscount(discount money.Amount) {
+	inv.EarlyPayTaken = discount
+	inv.RecalculateBalance()
+}
+
+// RevokeEarlyPayDiscount puts a discount taken back on the invoice once the
+// payment that earned it no longer settles the invoice, e.g. after it was
+// reversed. It reports whether there was a discount to revoke.
+func (inv *Invoice) RevokeEarlyPayDiscount() bool {
+	if !inv.EarlyPayTaken.IsPositive() || !inv.AmountDue().IsPositive() {
+		return false
+	}
+	inv.EarlyPayTaken = money.Zero
+	inv.RecalculateBalance()
+	return true
+}
+
+// CustomerPaymentTerm assigns a term to a customer; their invoices get it
+// unless another is chosen when the invoice is created
+type CustomerPaymentTerm struct {
+	OrganizationID uuid.UUID `gorm:"type:uuid;primaryKey" json:"organization_id"`
+	CustomerID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"customer_id"`
+	PaymentTermID  uuid.UUID `gorm:"type:uuid;index" json:"payment_term_id"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// InvoiceKind tells ordinary invoices apart from the ones that bill a job
+// ahead of or in parts
+type InvoiceKind string
+
+const (
+	InvoiceKindStandard InvoiceKind = "standard"
+	InvoiceKindDeposit  InvoiceKind = "deposit"  // Payments are held as customer credit for the final invoice
+	InvoiceKindProgress InvoiceKind = "progress" // Bills a percentage or milestone of a work order or estimate
+
+	InvoiceKindConsolidated InvoiceKind = "consolidated" // Merges a customer's work orders and draft invoices of a period
+	InvoiceKindSubscription InvoiceKind = "subscription" // Closes a billing period of a subscription
+)
+
+// BillingSourceType is the kind of document a job is billed against
+type BillingSourceType string
+
+const (
+	BillingSourceWorkOrder BillingSourceType = "work_order"
+	BillingSourceEstimate  BillingSourceType = "estimate"
+)
+
+// ProgressBill is one deposit or progress invoice of a job. The job is a work
+// order or an estimate; a deposit without one is a retainer held for any of
+// the customer's invoices.
+//
+// Amount is what the bill charges before tax. For deposits, Held is what has
+// been paid on the deposit invoice and is kept as customer credit, and
+// Applied is the part of it used on final invoices.
+type ProgressBill struct {
+	ID             uuid.UUID         `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID         `gorm:"type:uuid;index" json:"organization_id"`
+	CustomerID     uuid.UUID         `gorm:"type:uuid;index" json:"customer_id"`
+	SourceType     BillingSourceType `gorm:"type:varchar(20);index:idx_progress_bill_source" json:"source_type,omitempty"`
+	SourceID       *uuid.UUID        `gorm:"type:uuid;index:idx_progress_bill_source" json:"source_id,omitempty"`
+	Kind           InvoiceKind       `gorm:"type:varchar(20)" json:"kind"`
+	InvoiceID      *uuid.UUID        `gorm:"type:uuid;uniqueIndex" json:"invoice_id,omitempty"` // Unset while the invoice is being created
+	Milestone      string            `gorm:"type:varchar(255)" json:"milestone"`
+	Percent        money.Amount      `gorm:"type:decimal(9,4);default:0" json:"percent"` // Set when billed as a percentage of the job
+	Amount         money.Amount      `gorm:"type:decimal(15,2)" json:"amount"`
+	Currency       string            `gorm:"type:varchar(3)" json:"currency"`
+	Final          bool              `gorm:"default:false" json:"final"` // Bills the rest of the job
+	Held           money.Amount      `gorm:"type:decimal(15,2);default:0" json:"held"`
+	Applied        money.Amount      `gorm:"type:decimal(15,2);default:0" json:"applied"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// BillsInFull reports whether the invoice bills its job in full, rather than
+// ahead of it or in parts
+func (inv *Invoice) BillsInFull() bool {
+	return inv.Kind != InvoiceKindDeposit && inv.Kind != InvoiceKindProgress
+}
+
+// Available returns the part of a deposit still held for final invoices
+func (b *ProgressBill) Available() money.Amount {
+	return money.Max(b.Held.Sub(b.Applied), money.Zero)
+}
+
+// Matches reports whether a deposit may be applied to an invoice billing the
+// given jobs. Retainers match every invoice of the customer.
+func (b *ProgressBill) Matches(jobs ...uuid.UUID) bool {
+	if b.SourceID == nil {
+		return true
+	}
+	for _, id := range jobs {
+		if id == *b.SourceID {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveBillAmount works out what a bill charges of a job worth contract:
+// a percentage of it or a fixed amount, never both
+func ResolveBillAmount(contract, percent, amount money.Amount, round func(money.Amount) money.Amount) (money.Amount, error) {
+	if !percent.IsZero() && !amount.IsZero() {
+		return money.Zero, fmt.Errorf("%w: give either an amount or a percentage", ErrInvalidInput)
+	}
+	if percent.IsNegative() || percent.GreaterThan(money.New(100)) {
+		return money.Zero, fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidInput)
+	}
+	if !percent.IsZero() {
+		if !contract.IsPositive() {
+			return money.Zero, fmt.Errorf("%w: a percentage needs a work order or estimate with a total", ErrInvalidInput)
+		}
+		amount = contract.Percent(percent)
+	}
+	amount = round(amount)
+	if !amount.IsPositive() {
+		return money.Zero, fmt.Errorf("%w: amount to bill must be positive", ErrInvalidInput)
+	}
+	return amount, nil
+}
+
+// CheckBillable refuses a bill of amount on a job worth contract of which
+// billed has already been billed
+func CheckBillable(contract, billed, amount money.Amount) error {
+	left := money.Max(contract.Sub(billed), money.Zero)
+	if amount.GreaterThan(left) {
+		return fmt.Errorf("%w: %s would be billed but only %s of %s is left", ErrOverBilling, amount, left, contract)
+	}
+	return nil
+}
+
+// AllocateDeposits spreads up to due over the deposits in order and returns
+// the part taken from each, keyed by deposit, with their sum
+func AllocateDeposits(deposits []ProgressBill, due money.Amount) (map[uuid.UUID]money.Amount, money.Amount) {
+	taken := make(map[uuid.UUID]money.Amount)
+	total := money.Zero
+	for _, d := range deposits {
+		left := due.Sub(total)
+		if !left.IsPositive() {
+			break
+		}
+		part := money.Min(d.Available(), left)
+		if !part.IsPositive() {
+			continue
+		}
+		taken[d.ID] = part
+		total = total.Add(part)
+	}
+	return taken, total
+}
+
+// DepositCreditEntries returns the credit movements caused by a change in
+// what p pays on deposit invoices: money received on a deposit invoice is
+// held as customer credit until a final invoice uses it, and taken back out
+// when the payment is moved, refunded or reversed
+func DepositCreditEntries(p *Payment, previous []PaymentAllocation, invoices []*Invoice) []*CustomerCreditEntry {
+	before := make(map[uuid.UUID]money.Amount, len(previous))
+	for _, a := range previous {
+		before[a.InvoiceID] = before[a.InvoiceID].Add(a.Amount)
+	}
+
+	var entries []*CustomerCreditEntry
+	for _, inv := range invoices {
+		if inv.Kind != InvoiceKindDeposit {
+			continue
+		}
+		delta := p.AllocatedTo(inv.ID).Sub(before[inv.ID])
+		if delta.IsZero() {
+			continue
+		}
+		entry := newPaymentCreditEntry(p)
+		entry.EntryType = CreditEntryDeposit
+		entry.Amount = delta
+		entry.InvoiceID = &inv.ID
+		entry.Notes = fmt.Sprintf("Deposit %s", inv.InvoiceNumber)
+		entries = append(entries, entry)
+	}
+	return entries
+}
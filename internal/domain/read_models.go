@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -51,6 +52,12 @@ type ItemRM struct {
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+// Work order billing statuses as tracked by billing
+const (
+	WorkOrderBillingUnbilled = "unbilled"
+	WorkOrderBillingInvoiced = "invoiced"
+)
+
 // WorkOrderRM represents a read-optimized version of a Work Order
 type WorkOrderRM struct {
 	ID             uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
@@ -62,6 +69,8 @@ type WorkOrderRM struct {
 	Type           string     `json:"type"`
 	Status         string     `json:"status"`
 	BillingStatus  string     `json:"billing_status"`
+	InvoiceID      *uuid.UUID `gorm:"type:uuid;index" json:"invoice_id,omitempty"`       // Invoice billing this work order
+	BilledAmount   float64    `gorm:"type:decimal(15,2);default:0" json:"billed_amount"` // Billed so far through progress invoices
 	CustomerID     *uuid.UUID `gorm:"type:uuid" json:"customer_id,omitempty"`
 	ContactID      *uuid.UUID `gorm:"type:uuid" json:"contact_id,omitempty"`
 	ServiceAddress string     `json:"service_address"`
@@ -70,9 +79,45 @@ type WorkOrderRM struct {
 	Discount       float64    `gorm:"type:decimal(15,2)" json:"discount"`
 	Adjustment     float64    `gorm:"type:decimal(15,2)" json:"adjustment"`
 	GrandTotal     float64    `gorm:"type:decimal(15,2)" json:"grand_total"`
+	CreatedAt      *time.Time `gorm:"index" json:"created_at,omitempty"` // When the work order was opened; unset on rows synced before it was tracked
 	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
+// CheckBillable returns ErrWorkOrderAlreadyInvoiced when the work order is
+// reserved for, or linked to, an invoice, or is billed through progress
+// invoices
+func (wo *WorkOrderRM) CheckBillable() error {
+	if wo.BillingStatus == WorkOrderBillingInvoiced || wo.InvoiceID != nil {
+		return fmt.Errorf("%w: %s", ErrWorkOrderAlreadyInvoiced, wo.ID)
+	}
+	if wo.BilledAmount > 0 {
+		return fmt.Errorf("%w: %s is billed through progress invoices", ErrWorkOrderAlreadyInvoiced, wo.ID)
+	}
+	return nil
+}
+
+// ReserveForBilling marks the work order invoiced before its invoice exists,
+// so that no one else can bill it in the meantime
+func (wo *WorkOrderRM) ReserveForBilling() error {
+	if err := wo.CheckBillable(); err != nil {
+		return err
+	}
+	wo.BillingStatus = WorkOrderBillingInvoiced
+	return nil
+}
+
+// LinkInvoice records the invoice that bills a reserved work order
+func (wo *WorkOrderRM) LinkInvoice(invoiceID uuid.UUID) {
+	wo.InvoiceID = &invoiceID
+}
+
+// ReleaseBilling makes the work order billable again, after its invoice
+// could not be created or was voided
+func (wo *WorkOrderRM) ReleaseBilling() {
+	wo.BillingStatus = WorkOrderBillingUnbilled
+	wo.InvoiceID = nil
+}
+
 // WorkOrderServiceLineRM represents a read-optimized version of a Work Order Service Line
 type WorkOrderServiceLineRM struct {
 	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
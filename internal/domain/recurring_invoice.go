@@ -0,0 +1,230 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/cron"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+type RecurringFrequency string
+
+const (
+	RecurringWeekly    RecurringFrequency = "weekly"
+	RecurringMonthly   RecurringFrequency = "monthly"
+	RecurringQuarterly RecurringFrequency = "quarterly"
+	RecurringAnnual    RecurringFrequency = "annual"
+	RecurringCustom    RecurringFrequency = "custom" // Uses CronExpression
+)
+
+type RecurringProfileStatus string
+
+const (
+	RecurringProfileActive    RecurringProfileStatus = "active"
+	RecurringProfilePaused    RecurringProfileStatus = "paused"
+	RecurringProfileCompleted RecurringProfileStatus = "completed"
+	RecurringProfileCancelled RecurringProfileStatus = "cancelled"
+)
+
+type RecurringRunStatus string
+
+const (
+	RecurringRunPending   RecurringRunStatus = "pending"
+	RecurringRunGenerated RecurringRunStatus = "generated"
+	RecurringRunFailed    RecurringRunStatus = "failed"
+)
+
+// RunLease is how long a pending run belongs to the worker that claimed it. A
+// run still pending after that was abandoned by a worker that crashed or was
+// stopped mid-run, and may be claimed again.
+const RunLease = 15 * time.Minute
+
+// RecurringProfile generates a new invoice from its template on every occurrence
+// of its schedule between StartDate and EndDate.
+type RecurringProfile struct {
+	ID              uuid.UUID              `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID  uuid.UUID              `gorm:"type:uuid;index" json:"organization_id"`
+	CustomerID      uuid.UUID              `gorm:"type:uuid;index" json:"customer_id"`
+	Name            string                 `gorm:"type:varchar(255)" json:"name"`
+	Frequency       RecurringFrequency     `gorm:"type:varchar(20)" json:"frequency"`
+	CronExpression  string                 `gorm:"type:varchar(100)" json:"cron_expression,omitempty"`
+	StartDate       time.Time              `json:"start_date"`
+	EndDate         *time.Time             `json:"end_date,omitempty"`
+	NextRunAt       *time.Time             `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt       *time.Time             `json:"last_run_at,omitempty"`
+	OccurrenceCount int                    `gorm:"default:0" json:"occurrence_count"`
+	AutoSend        bool                   `gorm:"default:false" json:"auto_send"`
+	Status          RecurringProfileStatus `gorm:"type:varchar(20);default:'active';index" json:"status"`
+	Template        InvoiceTemplate        `gorm:"type:jsonb" json:"template"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+// RecurringRun records one scheduled occurrence of a profile and the invoice it
+// produced. The unique (profile, scheduled_for) pair is what keeps a restarted
+// or duplicated scheduler from generating the same invoice twice.
+type RecurringRun struct {
+	ID             uuid.UUID          `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID          `gorm:"type:uuid;index" json:"organization_id"`
+	ProfileID      uuid.UUID          `gorm:"type:uuid;uniqueIndex:idx_recurring_run_occurrence" json:"profile_id"`
+	ScheduledFor   time.Time          `gorm:"uniqueIndex:idx_recurring_run_occurrence" json:"scheduled_for"`
+	Status         RecurringRunStatus `gorm:"type:varchar(20)" json:"status"`
+	InvoiceID      *uuid.UUID         `gorm:"type:uuid;index" json:"invoice_id,omitempty"`
+	Error          string             `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// InvoiceTemplate is the part of an invoice that is copied into every generated invoice
+type InvoiceTemplate struct {
+	Subject         string                `json:"subject"`
+	ContactID       *uuid.UUID            `json:"contact_id,omitempty"`
+	OwnerID         *uuid.UUID            `json:"owner_id,omitempty"`
+	ReferenceNo     string                `json:"reference_no,omitempty"`
+	PurchaseOrder   string                `json:"purchase_order,omitempty"`
+	Currency        string                `json:"currency,omitempty"`
+	DueInDays       int                   `json:"due_in_days"`
+	Adjustment      money.Amount          `json:"adjustment"`
+	Discount        money.Amount          `json:"discount"`
+	DiscountPct     money.Amount          `json:"discount_percent"`
+	TaxInclusive    bool                  `json:"tax_inclusive,omitempty"`
+	Terms           string                `json:"terms,omitempty"`
+	Notes           string                `json:"notes,omitempty"`
+	BillingStreet   string                `json:"billing_street,omitempty"`
+	BillingCity     string                `json:"billing_city,omitempty"`
+	BillingState    string                `json:"billing_state,omitempty"`
+	BillingCode     string                `json:"billing_code,omitempty"`
+	BillingCountry  string                `json:"billing_country,omitempty"`
+	ShippingStreet  string                `json:"shipping_street,omitempty"`
+	ShippingCity    string                `json:"shipping_city,omitempty"`
+	ShippingState   string                `json:"shipping_state,omitempty"`
+	ShippingCode    string                `json:"shipping_code,omitempty"`
+	ShippingCountry string                `json:"shipping_country,omitempty"`
+	Items           []InvoiceTemplateItem `json:"items"`
+}
+
+type InvoiceTemplateItem struct {
+	ItemID      uuid.UUID    `json:"item_id"`
+	ItemType    string       `json:"item_type"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Quantity    float64      `json:"quantity"`
+	UnitPrice   money.Amount `json:"unit_price"`
+	Discount    money.Amount `json:"discount"`
+	DiscountPct money.Amount `json:"discount_percent"`
+	TaxCode     string       `json:"tax_code,omitempty"`
+	Tax         money.Amount `json:"tax"`
+}
+
+// Value stores the template as JSON
+func (t InvoiceTemplate) Value() (driver.Value, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads the template from a JSON column
+func (t *InvoiceTemplate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = InvoiceTemplate{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("cannot scan %T into InvoiceTemplate", src)
+	}
+}
+
+// Validate checks the schedule settings of the profile
+func (p *RecurringProfile) Validate() error {
+	switch p.Frequency {
+	case RecurringWeekly, RecurringMonthly, RecurringQuarterly, RecurringAnnual:
+	case RecurringCustom:
+		if _, err := cron.Parse(p.CronExpression); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	default:
+		return fmt.Errorf("%w: unknown frequency %q", ErrInvalidInput, p.Frequency)
+	}
+	if p.StartDate.IsZero() {
+		return fmt.Errorf("%w: start_date is required", ErrInvalidInput)
+	}
+	if p.EndDate != nil && p.EndDate.Before(p.StartDate) {
+		return fmt.Errorf("%w: end_date is before start_date", ErrInvalidInput)
+	}
+	if len(p.Template.Items) == 0 {
+		return fmt.Errorf("%w: template needs at least one item", ErrInvalidInput)
+	}
+	return nil
+}
+
+// Occurrence returns the n-th (0-based) scheduled date of the profile.
+// Month-based frequencies are anchored on StartDate so a profile starting on
+// the 31st runs on the last day of shorter months and returns to the 31st after.
+func (p *RecurringProfile) Occurrence(n int) time.Time {
+	switch p.Frequency {
+	case RecurringWeekly:
+		return p.StartDate.AddDate(0, 0, 7*n)
+	case RecurringMonthly:
+		return addMonthsClamped(p.StartDate, n)
+	case RecurringQuarterly:
+		return addMonthsClamped(p.StartDate, 3*n)
+	case RecurringAnnual:
+		return addMonthsClamped(p.StartDate, 12*n)
+	case RecurringCustom:
+		schedule, err := cron.Parse(p.CronExpression)
+		if err != nil {
+			return time.Time{}
+		}
+		// The first occurrence may be StartDate itself
+		t := schedule.Next(p.StartDate.Add(-time.Minute))
+		for i := 0; i < n && !t.IsZero(); i++ {
+			t = schedule.Next(t)
+		}
+		return t
+	default:
+		return time.Time{}
+	}
+}
+
+// ScheduleNext moves NextRunAt to the occurrence after the ones already
+// generated, completing the profile once EndDate has passed.
+func (p *RecurringProfile) ScheduleNext() {
+	next := p.Occurrence(p.OccurrenceCount)
+	if next.IsZero() || (p.EndDate != nil && next.After(*p.EndDate)) {
+		p.NextRunAt = nil
+		p.Status = RecurringProfileCompleted
+		return
+	}
+	p.NextRunAt = &next
+}
+
+// SkipMissed advances past occurrences before now without generating them,
+// e.g. after the profile was paused or its schedule changed.
+func (p *RecurringProfile) SkipMissed(now time.Time) {
+	p.ScheduleNext()
+	for p.NextRunAt != nil && p.NextRunAt.Before(now) {
+		p.OccurrenceCount++
+		p.ScheduleNext()
+	}
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
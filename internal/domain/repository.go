@@ -2,23 +2,69 @@ package domain
 
 import (
 	"context"
+	"time"
+
+	"erp-billing-service/pkg/money"
 
 	"github.com/google/uuid"
 )
 
 type InvoiceRepository interface {
+	// Create stores a new draft invoice. Drafts are not numbered; an invoice
+	// takes the next number of the organization's invoice series in the
+	// transaction that issues it, whether a status change or a payment.
 	Create(ctx context.Context, invoice *Invoice) error
-	Update(ctx context.Context, invoice *Invoice) error
+	// UpdateStatus locks the invoice, lets apply move it to a new status and
+	// stores only the status, lock time and the number of an invoice it
+	// issues, so it never overwrites balances changed by payments in the
+	// meantime
+	UpdateStatus(ctx context.Context, invoiceID uuid.UUID, apply func(inv *Invoice) error) (*Invoice, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*Invoice, error)
+	// GetByRunID returns the invoice a recurring or subscription run
+	// generated, or ErrInvoiceNotFound if it has none
+	GetByRunID(ctx context.Context, runID uuid.UUID) (*Invoice, error)
 	List(ctx context.Context, filter map[string]interface{}) ([]Invoice, error)
 	Delete(ctx context.Context, id uuid.UUID) error
-	GetNextInvoiceNumber(ctx context.Context, orgID uuid.UUID) (string, error)
-	ClearItems(ctx context.Context, invoiceID uuid.UUID) error
+	// ReplaceItems locks the invoice, lets apply rebuild its lines and header,
+	// and stores them in place of the current lines and tax breakdown in one
+	// transaction. Nothing is written when apply fails.
+	ReplaceItems(ctx context.Context, invoiceID uuid.UUID, apply func(inv *Invoice) error) (*Invoice, error)
+	// ListOpenForCustomer returns the customer's invoices in a currency that
+	// are in one of OpenInvoiceStatuses and still have a balance
+	ListOpenForCustomer(ctx context.Context, orgID, customerID uuid.UUID, currency string) ([]Invoice, error)
+	// ListOpenForOrganization does the same across all of the organization's customers
+	ListOpenForOrganization(ctx context.Context, orgID uuid.UUID, currency string) ([]Invoice, error)
+	// ReplaceInstallments locks the invoice, lets apply change its payment
+	// schedule and stores the new schedule with the invoice's due date and
+	// status. An empty schedule removes it.
+	ReplaceInstallments(ctx context.Context, invoiceID uuid.UUID, apply func(inv *Invoice) error) (*Invoice, error)
 }
 
 type PaymentRepository interface {
 	Create(ctx context.Context, payment *Payment) error
+	// Record locks the invoices FOR UPDATE and stores the payment that apply
+	// builds from them together with its allocations, the invoices' new
+	// balances, the credit movement the payment causes and the deposits it
+	// uses up, all in one transaction. It fails with ErrPaymentAlreadyRecorded when the payment's
+	// idempotency key has been used before.
+	Record(ctx context.Context, invoiceIDs []uuid.UUID, apply func(invoices []*Invoice) (*Payment, error)) (*Payment, []*Invoice, error)
+	// Reallocate locks the payment and every invoice it is or will be
+	// allocated to, lets apply move its allocations, and stores them with the
+	// invoices' balances and the change in the payment's unapplied credit
+	Reallocate(ctx context.Context, paymentID uuid.UUID, invoiceIDs []uuid.UUID, apply func(payment *Payment, invoices []*Invoice) error) (*Payment, []*Invoice, error)
+	// Refund locks the payment and the invoices it is allocated to, lets
+	// apply take the refund out of it, and stores the refund with the
+	// payment's new allocations, the re-opened balances and the credit
+	// movement it causes
+	Refund(ctx context.Context, paymentID uuid.UUID, apply func(payment *Payment, invoices []*Invoice) (*PaymentRefund, error)) (*Payment, *PaymentRefund, []*Invoice, error)
+	ListRefunds(ctx context.Context, paymentID uuid.UUID) ([]PaymentRefund, error)
+	// SetRefundReference stores the reference under which a refund was paid out
+	SetRefundReference(ctx context.Context, refundID uuid.UUID, reference string) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Payment, error)
+	GetByIdempotencyKey(ctx context.Context, orgID uuid.UUID, key string) (*Payment, error)
+	// GetByInvoiceID returns the payments with an allocation to the invoice
 	GetByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]Payment, error)
+	List(ctx context.Context, filter map[string]interface{}) ([]Payment, error)
 }
 
 type ReadModelRepository interface {
@@ -28,9 +74,302 @@ type ReadModelRepository interface {
 	SearchItems(ctx context.Context, orgID uuid.UUID, query string) ([]ItemRM, error)
 	GetContact(ctx context.Context, id uuid.UUID) (*ContactRM, error)
 	SearchContacts(ctx context.Context, orgID uuid.UUID, customerID uuid.UUID, query string) ([]ContactRM, error)
+	GetWorkOrder(ctx context.Context, id uuid.UUID) (*WorkOrderRM, error)
+	ListWorkOrderServiceLines(ctx context.Context, workOrderID uuid.UUID) ([]WorkOrderServiceLineRM, error)
+	ListWorkOrderPartLines(ctx context.Context, workOrderID uuid.UUID) ([]WorkOrderPartLineRM, error)
+	// ReserveWorkOrderForBilling marks the work order invoiced unless it already is.
+	// It returns false when another invoice already bills the work order.
+	ReserveWorkOrderForBilling(ctx context.Context, workOrderID uuid.UUID) (bool, error)
+	LinkWorkOrderInvoice(ctx context.Context, workOrderID uuid.UUID, invoiceID uuid.UUID) error
+	ReleaseWorkOrderBilling(ctx context.Context, workOrderID uuid.UUID) error
 }
 
 type AuditLogRepository interface {
 	Create(ctx context.Context, log *InvoiceAuditLog) error
 	ListByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]InvoiceAuditLog, error)
 }
+
+type BillingNoteRepository interface {
+	// Issue locks the source invoice and builds the note from it and the notes
+	// issued on it before. The note, the invoice's new balance and any credit
+	// the note gives the customer are stored in the same transaction.
+	Issue(ctx context.Context, invoiceID uuid.UUID, build func(invoice *Invoice, earlier []BillingNote) (*BillingNote, error)) (*BillingNote, *Invoice, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*BillingNote, error)
+	ListByInvoiceID(ctx context.Context, invoiceID uuid.UUID) ([]BillingNote, error)
+	List(ctx context.Context, filter map[string]interface{}) ([]BillingNote, error)
+}
+
+type RecurringProfileRepository interface {
+	Create(ctx context.Context, profile *RecurringProfile) error
+	Update(ctx context.Context, profile *RecurringProfile) error
+	GetByID(ctx context.Context, id uuid.UUID) (*RecurringProfile, error)
+	List(ctx context.Context, filter map[string]interface{}) ([]RecurringProfile, error)
+	// ListDue returns active profiles whose next run is at or before now
+	ListDue(ctx context.Context, now time.Time, limit int) ([]RecurringProfile, error)
+	// AdvanceSchedule stores the profile's next and last run, occurrence count
+	// and status, but only while it is still active at the occurrence count
+	// it had before the run. It returns false when the profile was paused,
+	// cancelled or rescheduled meanwhile; nothing is written then.
+	AdvanceSchedule(ctx context.Context, profile *RecurringProfile, fromCount int) (bool, error)
+	// ClaimRun inserts a pending run for an occurrence. It returns false when the
+	// occurrence was already generated, or is being generated by another worker;
+	// a failed run, or one left pending longer than RunLease, is claimed again.
+	ClaimRun(ctx context.Context, run *RecurringRun) (bool, error)
+	UpdateRun(ctx context.Context, run *RecurringRun) error
+	ListRuns(ctx context.Context, profileID uuid.UUID) ([]RecurringRun, error)
+}
+
+type TaxRepository interface {
+	CreateRate(ctx context.Context, rate *TaxRate) error
+	UpdateRate(ctx context.Context, rate *TaxRate) error
+	GetRateByID(ctx context.Context, id uuid.UUID) (*TaxRate, error)
+	ListRates(ctx context.Context, orgID uuid.UUID) ([]TaxRate, error)
+	CreateGroup(ctx context.Context, group *TaxGroup) error
+	// UpdateGroup saves the group and replaces its components
+	UpdateGroup(ctx context.Context, group *TaxGroup) error
+	GetGroupByID(ctx context.Context, id uuid.UUID) (*TaxGroup, error)
+	ListGroups(ctx context.Context, orgID uuid.UUID) ([]TaxGroup, error)
+	// ResolveCode returns the active rates behind a group or rate code, in
+	// the order they apply. Group codes take precedence over rate codes.
+	ResolveCode(ctx context.Context, orgID uuid.UUID, code string) ([]TaxRate, error)
+}
+
+type PaymentTermRepository interface {
+	// CreateTerm stores the term; a default term takes over from the
+	// organization's previous default in the same transaction
+	CreateTerm(ctx context.Context, term *PaymentTerm) error
+	UpdateTerm(ctx context.Context, term *PaymentTerm) error
+	// DeleteTerm removes the term and its customer assignments
+	DeleteTerm(ctx context.Context, id uuid.UUID) error
+	GetTerm(ctx context.Context, id uuid.UUID) (*PaymentTerm, error)
+	GetTermByCode(ctx context.Context, orgID uuid.UUID, code string) (*PaymentTerm, error)
+	ListTerms(ctx context.Context, orgID uuid.UUID) ([]PaymentTerm, error)
+	// GetDefaultTerm returns ErrPaymentTermNotFound when the organization has no default
+	GetDefaultTerm(ctx context.Context, orgID uuid.UUID) (*PaymentTerm, error)
+	// GetCustomerTerm returns the customer's term, or ErrPaymentTermNotFound
+	// when none is assigned
+	GetCustomerTerm(ctx context.Context, orgID, customerID uuid.UUID) (*PaymentTerm, error)
+	AssignCustomerTerm(ctx context.Context, assignment *CustomerPaymentTerm) error
+	UnassignCustomerTerm(ctx context.Context, orgID, customerID uuid.UUID) error
+}
+
+type NumberSequenceRepository interface {
+	// Get returns the organization's series for a document type, or the
+	// unsaved default series seeded from the documents already numbered
+	Get(ctx context.Context, orgID uuid.UUID, docType DocumentType) (*NumberSequence, error)
+	// Save stores the series settings, waiting for any allocation in progress
+	Save(ctx context.Context, seq *NumberSequence) error
+}
+
+type CustomerCreditRepository interface {
+	ListBalances(ctx context.Context, orgID, customerID uuid.UUID) ([]CustomerCredit, error)
+	// GetBalance returns the customer's credit in a currency, zero if there is none
+	GetBalance(ctx context.Context, orgID, customerID uuid.UUID, currency string) (money.Amount, error)
+	ListEntries(ctx context.Context, orgID, customerID uuid.UUID) ([]CustomerCreditEntry, error)
+	// Post records a movement and updates the balance, refusing to take it below zero
+	Post(ctx context.Context, entry *CustomerCreditEntry) error
+}
+
+type BankStatementRepository interface {
+	// Create stores the statement with its lines
+	Create(ctx context.Context, statement *BankStatement) error
+	// ExistingFingerprints returns which of the fingerprints belong to lines
+	// imported before
+	ExistingFingerprints(ctx context.Context, orgID uuid.UUID, fingerprints []string) (map[string]bool, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*BankStatement, error)
+	List(ctx context.Context, orgID uuid.UUID) ([]BankStatement, error)
+	GetLine(ctx context.Context, id uuid.UUID) (*BankStatementLine, error)
+	ListLines(ctx context.Context, orgID uuid.UUID, statuses []StatementLineStatus) ([]BankStatementLine, error)
+	// SaveDecision stores the decision taken on a line unless another
+	// reviewer decided it first, in which case it fails with ErrInvalidInput
+	SaveDecision(ctx context.Context, line *BankStatementLine) error
+}
+
+type DirectDebitRepository interface {
+	// SaveCreditor creates or updates the organization's creditor for its scheme
+	SaveCreditor(ctx context.Context, creditor *DirectDebitCreditor) error
+	GetCreditor(ctx context.Context, orgID uuid.UUID, scheme DirectDebitScheme) (*DirectDebitCreditor, error)
+	// CreateMandate stores a mandate, failing with ErrInvalidInput when the
+	// customer already has an active one for the scheme
+	CreateMandate(ctx context.Context, mandate *DirectDebitMandate) error
+	GetMandate(ctx context.Context, id uuid.UUID) (*DirectDebitMandate, error)
+	ListMandates(ctx context.Context, orgID, customerID uuid.UUID) ([]DirectDebitMandate, error)
+	UpdateMandate(ctx context.Context, mandate *DirectDebitMandate) error
+	// CreateBatch locks the creditor and the invoices due by dueBy whose
+	// customer has an active mandate, and stores the batch build makes of
+	// them. Invoices already in collection are left out. The collected
+	// invoices go into collection and the creditor's counters and the
+	// mandates' first collection are saved in the same transaction.
+	CreateBatch(ctx context.Context, orgID uuid.UUID, scheme DirectDebitScheme, dueBy time.Time,
+		build func(creditor *DirectDebitCreditor, invoices []*Invoice, mandates map[uuid.UUID]*DirectDebitMandate) (*DirectDebitBatch, error)) (*DirectDebitBatch, error)
+	GetBatch(ctx context.Context, id uuid.UUID) (*DirectDebitBatch, error)
+	ListBatches(ctx context.Context, orgID uuid.UUID) ([]DirectDebitBatch, error)
+	// FindItem returns the item a bank quotes by end-to-end ID or trace number
+	FindItem(ctx context.Context, orgID uuid.UUID, ref string) (*DirectDebitItem, error)
+	// SaveItem stores the outcome of an item. Once it is no longer pending its
+	// invoice leaves collection, and the batch is settled when no item is left.
+	SaveItem(ctx context.Context, item *DirectDebitItem) error
+}
+
+type DunningRepository interface {
+	GetPolicy(ctx context.Context, orgID uuid.UUID) (*DunningPolicy, error)
+	// SavePolicy creates or replaces the organization's policy
+	SavePolicy(ctx context.Context, policy *DunningPolicy) error
+	ListPolicies(ctx context.Context) ([]DunningPolicy, error)
+	// ListCandidates returns the invoices in one of OpenInvoiceStatuses with
+	// a balance that are, or have an unpaid installment, due before dueBefore
+	// and not in collection, of one organization or, when orgID is nil, of
+	// all of them
+	ListCandidates(ctx context.Context, orgID *uuid.UUID, dueBefore time.Time) ([]Invoice, error)
+	// MarkOverdue moves the invoice to overdue if it still has the status
+	// from and a balance, reporting whether it did
+	MarkOverdue(ctx context.Context, invoiceID uuid.UUID, from InvoiceStatus) (bool, error)
+	// MarkInstallmentsOverdue moves the given installments to overdue unless
+	// they have been paid since, reporting how many it moved
+	MarkInstallmentsOverdue(ctx context.Context, installmentIDs []uuid.UUID) (int64, error)
+	// ClaimReminder stores the reminder unless it was already sent, reporting
+	// whether this call stored it
+	ClaimReminder(ctx context.Context, reminder *DunningReminder) (bool, error)
+	ListReminders(ctx context.Context, invoiceIDs []uuid.UUID) ([]DunningReminder, error)
+}
+
+type LateFeeRepository interface {
+	GetPolicy(ctx context.Context, orgID uuid.UUID) (*LateFeePolicy, error)
+	// SavePolicy creates or replaces the organization's policy
+	SavePolicy(ctx context.Context, policy *LateFeePolicy) error
+	ListPolicies(ctx context.Context) ([]LateFeePolicy, error)
+	// ListCandidates returns the organization's overdue invoices with a
+	// balance that are not in collection and are not themselves fee invoices
+	ListCandidates(ctx context.Context, orgID uuid.UUID) ([]Invoice, error)
+	ListCharges(ctx context.Context, invoiceIDs []uuid.UUID) ([]LateFeeCharge, error)
+	// ChargeLine locks the invoice, lets charge work out the fee from it and
+	// the fees charged before, and stores the fee together with the late fee
+	// line and the invoice's new totals. It returns a nil charge when charge
+	// does, or when the fee was already stored by another run.
+	ChargeLine(ctx context.Context, invoiceID uuid.UUID, charge func(inv *Invoice, previous []LateFeeCharge) *LateFeeCharge) (*LateFeeCharge, *Invoice, error)
+	// ClaimCharge stores a fee to be billed on its own invoice unless it was
+	// already stored, reporting whether this call stored it
+	ClaimCharge(ctx context.Context, charge *LateFeeCharge) (bool, error)
+	// ReleaseCharge removes a claimed fee whose invoice could not be created
+	ReleaseCharge(ctx context.Context, chargeID uuid.UUID) error
+	SetFeeInvoice(ctx context.Context, chargeID, feeInvoiceID uuid.UUID) error
+	// ListUnbilledCharges returns the organization's fees billed on their own
+	// invoice, claimed before the given time, whose invoice was never linked
+	// or is still a draft, oldest first
+	ListUnbilledCharges(ctx context.Context, orgID uuid.UUID, claimedBefore time.Time) ([]LateFeeCharge, error)
+}
+
+type EstimateRepository interface {
+	// Create stores the estimate, allocating the next number of the
+	// organization's estimate series in the same transaction
+	Create(ctx context.Context, estimate *Estimate) error
+	// Update stores the estimate, replacing its lines and tax breakdown
+	Update(ctx context.Context, estimate *Estimate) error
+	// UpdateStatus stores only the estimate's status
+	UpdateStatus(ctx context.Context, estimate *Estimate) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Estimate, error)
+	List(ctx context.Context, filter map[string]interface{}) ([]Estimate, error)
+	// ReserveConversion marks the estimate accepted and converted unless it
+	// already is converted, reporting whether this call reserved it
+	ReserveConversion(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	// ReleaseConversion undoes a reservation whose invoice could not be
+	// created, restoring the status the estimate had
+	ReleaseConversion(ctx context.Context, id uuid.UUID, status EstimateStatus) error
+	LinkInvoice(ctx context.Context, id, invoiceID uuid.UUID) error
+}
+
+type ProgressBillingRepository interface {
+	// Reserve stores bill ahead of its invoice. The work order or estimate it
+	// bills stays locked while check decides, from what its progress bills
+	// and deposits already add up to, whether the bill may be made, so two
+	// concurrent bills cannot together bill past the job's total. A progress
+	// bill refuses a job invoiced in full and adds to what the job has billed.
+	Reserve(ctx context.Context, bill *ProgressBill, check func(billed, deposited money.Amount) error) error
+	// Release removes a bill whose invoice could not be created
+	Release(ctx context.Context, bill *ProgressBill) error
+	LinkInvoice(ctx context.Context, billID, invoiceID uuid.UUID) error
+	GetByInvoice(ctx context.Context, invoiceID uuid.UUID) (*ProgressBill, error)
+	// Recount works out again what the job of a progress invoice has billed,
+	// leaving out voided invoices
+	Recount(ctx context.Context, invoiceID uuid.UUID) error
+	// ListBySource returns the bills of a work order or estimate, oldest first
+	ListBySource(ctx context.Context, sourceType BillingSourceType, sourceID uuid.UUID) ([]ProgressBill, error)
+	// ListDeposits returns the customer's deposits, oldest first
+	ListDeposits(ctx context.Context, orgID, customerID uuid.UUID) ([]ProgressBill, error)
+	// ListHeldDeposits returns the customer's deposits in currency that still
+	// hold credit for final invoices, oldest first
+	ListHeldDeposits(ctx context.Context, orgID, customerID uuid.UUID, currency string) ([]ProgressBill, error)
+}
+
+type ConsolidationRepository interface {
+	// ClaimRun inserts a pending run for a customer's period. It returns false
+	// when the period was already consolidated or is being consolidated by
+	// another worker; a run that failed, found nothing to bill or whose
+	// invoice was voided or deleted is claimed again.
+	ClaimRun(ctx context.Context, run *ConsolidationRun) (bool, error)
+	GetRun(ctx context.Context, orgID, customerID uuid.UUID, currency string, start, end time.Time) (*ConsolidationRun, error)
+	UpdateRun(ctx context.Context, run *ConsolidationRun) error
+	// ListRuns returns the organization's runs, latest period first
+	ListRuns(ctx context.Context, orgID uuid.UUID) ([]ConsolidationRun, error)
+	// ListUnbilledWorkOrders returns the work orders opened from start to end
+	// that have not been billed, of one customer or, when customerID is nil,
+	// of all of them
+	ListUnbilledWorkOrders(ctx context.Context, orgID uuid.UUID, customerID *uuid.UUID, start, end time.Time) ([]WorkOrderRM, error)
+	// ListDrafts returns the standard, tax-exclusive draft invoices in a
+	// currency dated from start to end, with their items
+	ListDrafts(ctx context.Context, orgID uuid.UUID, customerID *uuid.UUID, currency string, start, end time.Time) ([]Invoice, error)
+	// Complete marks the sources billed by the run's invoice and saves the
+	// run, in one transaction: the work orders are linked to the invoice, and
+	// the drafts are voided and point at it, their work orders moving along.
+	// Nothing changes when one of the drafts is no longer a draft.
+	Complete(ctx context.Context, run *ConsolidationRun, workOrderIDs, draftIDs []uuid.UUID) error
+	// Undo reverses the consolidation into an invoice that was voided or
+	// deleted: its drafts are drafts again, billing their work orders, and its
+	// other work orders are released. It returns the restored drafts.
+	Undo(ctx context.Context, invoiceID uuid.UUID) ([]Invoice, error)
+}
+
+type SubscriptionRepository interface {
+	// CreatePlan inserts the plan with its components, returning
+	// ErrPlanCodeTaken when the organization already has a plan by its code
+	CreatePlan(ctx context.Context, plan *SubscriptionPlan) error
+	UpdatePlan(ctx context.Context, plan *SubscriptionPlan) error
+	// GetPlan returns the plan with its components, or ErrPlanNotFound
+	GetPlan(ctx context.Context, id uuid.UUID) (*SubscriptionPlan, error)
+	ListPlans(ctx context.Context, orgID uuid.UUID) ([]SubscriptionPlan, error)
+
+	Create(ctx context.Context, sub *Subscription) error
+	Update(ctx context.Context, sub *Subscription) error
+	// GetByID returns the subscription, or ErrSubscriptionNotFound
+	GetByID(ctx context.Context, id uuid.UUID) (*Subscription, error)
+	List(ctx context.Context, filter map[string]interface{}) ([]Subscription, error)
+	// ListDue returns active subscriptions whose current period closes by now
+	ListDue(ctx context.Context, now time.Time, limit int) ([]Subscription, error)
+	// ChangePlan saves the change and the subscription's new plan, unless
+	// the subscription was moved to another plan meanwhile
+	ChangePlan(ctx context.Context, sub *Subscription, change *SubscriptionPlanChange) error
+	ListPlanChanges(ctx context.Context, subscriptionID uuid.UUID, since time.Time) ([]SubscriptionPlanChange, error)
+
+	// RecordUsage inserts the record. It returns false, with the record
+	// stored earlier under its idempotency key loaded into it, when the usage
+	// had already been recorded, and ErrUsageKeyReused when the key was used
+	// for other usage.
+	RecordUsage(ctx context.Context, record *UsageRecord) (bool, error)
+	// ListUsage returns the subscription's usage from start, up to end unless
+	// end is zero, oldest first
+	ListUsage(ctx context.Context, subscriptionID uuid.UUID, start, end time.Time) ([]UsageRecord, error)
+	// ListUnbilledUsage returns the usage from start not billed yet, recorded
+	// before the given time unless it is zero
+	ListUnbilledUsage(ctx context.Context, subscriptionID uuid.UUID, start, before time.Time) ([]UsageRecord, error)
+
+	// ClaimRun inserts a pending run for a period. It returns false when the
+	// period is already billed or being billed; a failed run, or one left
+	// pending longer than RunLease, is claimed again.
+	ClaimRun(ctx context.Context, run *SubscriptionRun) (bool, error)
+	UpdateRun(ctx context.Context, run *SubscriptionRun) error
+	ListRuns(ctx context.Context, subscriptionID uuid.UUID) ([]SubscriptionRun, error)
+	// ClosePeriod marks the usage billed by the run's invoice, moves the
+	// subscription on to its next period and saves the run, in one
+	// transaction. Nothing changes when the period was closed meanwhile.
+	ClosePeriod(ctx context.Context, sub *Subscription, periodStart time.Time, run *SubscriptionRun, usageIDs []uuid.UUID) error
+}
@@ -0,0 +1,581 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// PricingModel decides how the tiers of a metered component price its usage
+type PricingModel string
+
+const (
+	PricingFlat      PricingModel = "flat"      // Every unit at the price of the single tier
+	PricingVolume    PricingModel = "volume"    // Every unit at the price of the tier the total falls in
+	PricingGraduated PricingModel = "graduated" // Each unit at the price of the tier it falls in
+)
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive    SubscriptionStatus = "active"
+	SubscriptionCancelled SubscriptionStatus = "cancelled" // Ended, and its last period billed
+)
+
+type UsageSource string
+
+const (
+	UsageSourceAPI   UsageSource = "api"
+	UsageSourceKafka UsageSource = "kafka"
+)
+
+// maxPriceTiers keeps a component's tiers to a readable invoice
+const maxPriceTiers = 20
+
+// PriceTier prices the units up to UpTo; the last tier has no limit
+type PriceTier struct {
+	UpTo      *float64     `json:"up_to,omitempty"`
+	UnitPrice money.Amount `json:"unit_price"`
+	FlatFee   money.Amount `json:"flat_fee"` // Charged once when any unit is priced in the tier
+}
+
+type PriceTiers []PriceTier
+
+// Value stores the tiers as JSON
+func (t PriceTiers) Value() (driver.Value, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan reads the tiers from a JSON column
+func (t *PriceTiers) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("cannot scan %T into PriceTiers", src)
+	}
+}
+
+// SubscriptionPlan is what a customer subscribes to: a recurring base fee
+// and metered components priced by usage, billed every Interval. Plans are
+// not edited once subscribed to; a new plan is made and subscriptions moved.
+type SubscriptionPlan struct {
+	ID             uuid.UUID          `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID          `gorm:"type:uuid;index;uniqueIndex:idx_plan_org_code" json:"organization_id"`
+	Code           string             `gorm:"type:varchar(50);uniqueIndex:idx_plan_org_code" json:"code"`
+	Name           string             `gorm:"type:varchar(255)" json:"name"`
+	Currency       string             `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+	Interval       RecurringFrequency `gorm:"type:varchar(20)" json:"interval"`
+	BaseFee        money.Amount       `gorm:"type:decimal(15,2);default:0" json:"base_fee"` // Prorated when the plan changes mid-period
+	ItemID         *uuid.UUID         `gorm:"type:uuid" json:"item_id,omitempty"`           // Item the base fee is billed as
+	TaxCode        string             `gorm:"type:varchar(50)" json:"tax_code,omitempty"`
+	Active         bool               `gorm:"default:true" json:"active"` // Inactive plans take no new subscribers
+	Components     []PlanComponent    `gorm:"foreignKey:PlanID" json:"components"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// PlanComponent is a metered part of a plan, such as site visits or hours
+type PlanComponent struct {
+	ID      uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	PlanID  uuid.UUID    `gorm:"type:uuid;index" json:"plan_id"`
+	Metric  string       `gorm:"type:varchar(100)" json:"metric"` // Key usage is recorded under
+	Name    string       `gorm:"type:varchar(255)" json:"name"`
+	Unit    string       `gorm:"type:varchar(50)" json:"unit,omitempty"`
+	ItemID  *uuid.UUID   `gorm:"type:uuid" json:"item_id,omitempty"`
+	TaxCode string       `gorm:"type:varchar(50)" json:"tax_code,omitempty"`
+	Pricing PricingModel `gorm:"type:varchar(20)" json:"pricing"`
+	Tiers   PriceTiers   `gorm:"type:jsonb" json:"tiers"`
+}
+
+// Subscription bills a customer for a plan period by period. Periods are
+// anchored on StartDate like recurring profiles, so a subscription started
+// on the 31st closes on the last day of shorter months.
+type Subscription struct {
+	ID                 uuid.UUID          `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID     uuid.UUID          `gorm:"type:uuid;index" json:"organization_id"`
+	CustomerID         uuid.UUID          `gorm:"type:uuid;index" json:"customer_id"`
+	PlanID             uuid.UUID          `gorm:"type:uuid;index" json:"plan_id"`
+	PlanSince          time.Time          `json:"plan_since"` // When the current plan took effect
+	Status             SubscriptionStatus `gorm:"type:varchar(20);default:'active';index" json:"status"`
+	StartDate          time.Time          `json:"start_date"`
+	CurrentPeriodStart time.Time          `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time          `gorm:"index" json:"current_period_end"` // First day of the next period
+	PeriodCount        int                `gorm:"default:0" json:"period_count"`   // Periods closed so far
+	EndsAt             *time.Time         `gorm:"index" json:"ends_at,omitempty"`  // First day no longer covered, set on cancellation
+	PaymentTermID      *uuid.UUID         `gorm:"type:uuid" json:"payment_term_id,omitempty"`
+	PurchaseOrder      string             `gorm:"type:varchar(50)" json:"purchase_order,omitempty"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// SubscriptionPlanChange records a move between plans, so the period it
+// falls in can be billed part on each
+type SubscriptionPlanChange struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;index" json:"subscription_id"`
+	FromPlanID     uuid.UUID `gorm:"type:uuid" json:"from_plan_id"`
+	ToPlanID       uuid.UUID `gorm:"type:uuid" json:"to_plan_id"`
+	EffectiveAt    time.Time `gorm:"index" json:"effective_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// UsageRecord is a metered quantity reported for a subscription. The
+// idempotency key makes a retried API call or a redelivered event count once.
+type UsageRecord struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID   `gorm:"type:uuid;uniqueIndex:idx_usage_key" json:"organization_id"`
+	SubscriptionID uuid.UUID   `gorm:"type:uuid;index" json:"subscription_id"`
+	Metric         string      `gorm:"type:varchar(100)" json:"metric"`
+	Quantity       float64     `gorm:"type:decimal(15,2)" json:"quantity"`
+	OccurredAt     time.Time   `gorm:"index" json:"occurred_at"`
+	IdempotencyKey string      `gorm:"type:varchar(255);uniqueIndex:idx_usage_key" json:"idempotency_key"`
+	Source         UsageSource `gorm:"type:varchar(20)" json:"source"`
+	InvoiceID      *uuid.UUID  `gorm:"type:uuid;index" json:"invoice_id,omitempty"` // Set once the period it falls in is billed
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// SubscriptionRun records the closing of one period and the invoice it
+// produced. The unique (subscription, period_start) pair keeps a period from
+// being billed twice.
+type SubscriptionRun struct {
+	ID             uuid.UUID          `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID          `gorm:"type:uuid;index" json:"organization_id"`
+	SubscriptionID uuid.UUID          `gorm:"type:uuid;uniqueIndex:idx_subscription_run_period" json:"subscription_id"`
+	PeriodStart    time.Time          `gorm:"uniqueIndex:idx_subscription_run_period" json:"period_start"`
+	PeriodEnd      time.Time          `json:"period_end"`
+	Status         RecurringRunStatus `gorm:"type:varchar(20)" json:"status"`
+	InvoiceID      *uuid.UUID         `gorm:"type:uuid;index" json:"invoice_id,omitempty"` // Unset when the period had nothing to bill
+	Error          string             `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// Validate checks the plan and its components
+func (p *SubscriptionPlan) Validate() error {
+	if strings.TrimSpace(p.Code) == "" || strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("%w: a plan needs a code and a name", ErrInvalidInput)
+	}
+	switch p.Interval {
+	case RecurringWeekly, RecurringMonthly, RecurringQuarterly, RecurringAnnual:
+	default:
+		return fmt.Errorf("%w: unknown billing period %q", ErrInvalidInput, p.Interval)
+	}
+	if p.BaseFee.IsNegative() {
+		return fmt.Errorf("%w: base fee cannot be negative", ErrInvalidInput)
+	}
+	if p.BaseFee.IsZero() && len(p.Components) == 0 {
+		return fmt.Errorf("%w: a plan needs a base fee or a metered component", ErrInvalidInput)
+	}
+	metrics := make(map[string]bool, len(p.Components))
+	for i := range p.Components {
+		c := &p.Components[i]
+		if c.Metric == "" {
+			return fmt.Errorf("%w: component %d has no metric", ErrInvalidInput, i+1)
+		}
+		if metrics[c.Metric] {
+			return fmt.Errorf("%w: metric %q is priced twice", ErrInvalidInput, c.Metric)
+		}
+		metrics[c.Metric] = true
+		if err := c.Tiers.Validate(c.Pricing); err != nil {
+			return fmt.Errorf("metric %s: %w", c.Metric, err)
+		}
+	}
+	return nil
+}
+
+// Component returns the component metering the metric, if the plan has one
+func (p *SubscriptionPlan) Component(metric string) *PlanComponent {
+	for i := range p.Components {
+		if p.Components[i].Metric == metric {
+			return &p.Components[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks that the tiers fit the pricing model: limits ascending,
+// the last tier unlimited, and a flat price a single tier
+func (t PriceTiers) Validate(model PricingModel) error {
+	switch model {
+	case PricingFlat:
+		if len(t) != 1 {
+			return fmt.Errorf("%w: flat pricing has exactly one tier", ErrInvalidInput)
+		}
+	case PricingVolume, PricingGraduated:
+		if len(t) == 0 || len(t) > maxPriceTiers {
+			return fmt.Errorf("%w: tiered pricing needs 1 to %d tiers", ErrInvalidInput, maxPriceTiers)
+		}
+	default:
+		return fmt.Errorf("%w: unknown pricing model %q", ErrInvalidInput, model)
+	}
+
+	prev := 0.0
+	for i, tier := range t {
+		if tier.UnitPrice.IsNegative() || tier.FlatFee.IsNegative() {
+			return fmt.Errorf("%w: tier %d has a negative price", ErrInvalidInput, i+1)
+		}
+		last := i == len(t)-1
+		if last != (tier.UpTo == nil) {
+			return fmt.Errorf("%w: only the last tier is unlimited", ErrInvalidInput)
+		}
+		if !last {
+			if *tier.UpTo <= prev {
+				return fmt.Errorf("%w: tier limits must increase", ErrInvalidInput)
+			}
+			prev = *tier.UpTo
+		}
+	}
+	return nil
+}
+
+// TierCharge is the usage priced in one tier
+type TierCharge struct {
+	Tier      int // 1-based
+	Quantity  float64
+	UnitPrice money.Amount
+	FlatFee   money.Amount
+}
+
+// Rate prices a period's quantity of the component. Nothing is charged when
+// nothing was used.
+func (c *PlanComponent) Rate(quantity float64) []TierCharge {
+	quantity = roundQuantity(quantity)
+	if quantity <= 0 || len(c.Tiers) == 0 {
+		return nil
+	}
+	switch c.Pricing {
+	case PricingVolume:
+		for i, tier := range c.Tiers {
+			if tier.UpTo == nil || quantity <= *tier.UpTo {
+				return []TierCharge{{Tier: i + 1, Quantity: quantity, UnitPrice: tier.UnitPrice, FlatFee: tier.FlatFee}}
+			}
+		}
+		return nil
+	case PricingGraduated:
+		var charges []TierCharge
+		floor := 0.0
+		for i, tier := range c.Tiers {
+			ceiling := quantity
+			if tier.UpTo != nil && *tier.UpTo < quantity {
+				ceiling = *tier.UpTo
+			}
+			charges = append(charges, TierCharge{Tier: i + 1, Quantity: roundQuantity(ceiling - floor), UnitPrice: tier.UnitPrice, FlatFee: tier.FlatFee})
+			if ceiling >= quantity {
+				break
+			}
+			floor = ceiling
+		}
+		return charges
+	default:
+		tier := c.Tiers[0]
+		return []TierCharge{{Tier: 1, Quantity: quantity, UnitPrice: tier.UnitPrice, FlatFee: tier.FlatFee}}
+	}
+}
+
+// roundQuantity keeps quantities to the two decimals invoice lines carry
+func roundQuantity(q float64) float64 {
+	return math.Round(q*100) / 100
+}
+
+// billingPeriodStart returns the start of the n-th (0-based) period anchored on start
+func billingPeriodStart(interval RecurringFrequency, start time.Time, n int) time.Time {
+	switch interval {
+	case RecurringWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case RecurringQuarterly:
+		return addMonthsClamped(start, 3*n)
+	case RecurringAnnual:
+		return addMonthsClamped(start, 12*n)
+	default:
+		return addMonthsClamped(start, n)
+	}
+}
+
+// Begin starts the subscription on the plan with its first period
+func (s *Subscription) Begin(plan *SubscriptionPlan, start time.Time) error {
+	if !plan.Active {
+		return fmt.Errorf("%w: plan %s takes no new subscribers", ErrInvalidInput, plan.Code)
+	}
+	if start.IsZero() {
+		return fmt.Errorf("%w: a subscription needs a start date", ErrInvalidInput)
+	}
+	s.PlanID = plan.ID
+	s.Status = SubscriptionActive
+	s.StartDate = dayOf(start)
+	s.PlanSince = s.StartDate
+	s.PeriodCount = 0
+	s.CurrentPeriodStart = s.StartDate
+	s.CurrentPeriodEnd = billingPeriodStart(plan.Interval, s.StartDate, 1)
+	return nil
+}
+
+// ClosesAt returns when the current period is billed: its end, or the end
+// of the subscription when that comes first
+func (s *Subscription) ClosesAt() time.Time {
+	if s.EndsAt != nil && s.EndsAt.Before(s.CurrentPeriodEnd) {
+		return *s.EndsAt
+	}
+	return s.CurrentPeriodEnd
+}
+
+// IsFinalPeriod reports whether the subscription ends with the current period
+func (s *Subscription) IsFinalPeriod() bool {
+	return s.EndsAt != nil && !s.EndsAt.After(s.CurrentPeriodEnd)
+}
+
+// AdvancePeriod moves on to the next period once the current one is
+// billed, or ends the subscription after its final period
+func (s *Subscription) AdvancePeriod(interval RecurringFrequency) {
+	if s.IsFinalPeriod() {
+		s.Status = SubscriptionCancelled
+		return
+	}
+	s.PeriodCount++
+	s.CurrentPeriodStart = s.CurrentPeriodEnd
+	s.CurrentPeriodEnd = billingPeriodStart(interval, s.StartDate, s.PeriodCount+1)
+}
+
+// ChangePlan moves the subscription to another plan from the given day of
+// the current period. The period is billed on each plan for its share.
+func (s *Subscription) ChangePlan(from, to *SubscriptionPlan, effectiveAt time.Time) (*SubscriptionPlanChange, error) {
+	if s.Status != SubscriptionActive {
+		return nil, fmt.Errorf("%w: subscription has ended", ErrInvalidInput)
+	}
+	if to.ID == s.PlanID {
+		return nil, fmt.Errorf("%w: the subscription is already on plan %s", ErrInvalidInput, to.Code)
+	}
+	if !to.Active {
+		return nil, fmt.Errorf("%w: plan %s takes no new subscribers", ErrInvalidInput, to.Code)
+	}
+	if to.OrganizationID != from.OrganizationID || !strings.EqualFold(to.Currency, from.Currency) || to.Interval != from.Interval {
+		return nil, fmt.Errorf("%w: plans can only be changed for one with the same currency and billing period", ErrInvalidInput)
+	}
+	effectiveAt = dayOf(effectiveAt)
+	if effectiveAt.Before(s.PlanSince) || effectiveAt.Before(s.CurrentPeriodStart) || !effectiveAt.Before(s.ClosesAt()) {
+		return nil, fmt.Errorf("%w: a plan change takes effect within the current period, from %s", ErrInvalidInput, s.PlanSince.Format("2006-01-02"))
+	}
+
+	change := &SubscriptionPlanChange{
+		ID:             uuid.New(),
+		SubscriptionID: s.ID,
+		FromPlanID:     s.PlanID,
+		ToPlanID:       to.ID,
+		EffectiveAt:    effectiveAt,
+	}
+	s.PlanID = to.ID
+	s.PlanSince = effectiveAt
+	return change, nil
+}
+
+// Cancel ends the subscription on the given day of the current period, or
+// with it when at is zero. The last period is billed up to that day.
+func (s *Subscription) Cancel(at time.Time) error {
+	if s.Status != SubscriptionActive || s.EndsAt != nil {
+		return fmt.Errorf("%w: subscription is already ending", ErrInvalidInput)
+	}
+	if at.IsZero() {
+		at = s.CurrentPeriodEnd
+	}
+	at = dayOf(at)
+	if !at.After(s.CurrentPeriodStart) || !at.After(s.PlanSince) || at.After(s.CurrentPeriodEnd) {
+		return fmt.Errorf("%w: a subscription ends after %s and by %s", ErrInvalidInput,
+			s.PlanSince.Format("2006-01-02"), s.CurrentPeriodEnd.Format("2006-01-02"))
+	}
+	s.EndsAt = &at
+	return nil
+}
+
+// NewUsage checks a reported quantity against the subscription and returns
+// its record. Usage of closed periods is refused, as is usage after the
+// subscription ends. Without a key the record's own ID is used.
+func (s *Subscription) NewUsage(metric string, quantity float64, occurredAt time.Time, key string, source UsageSource) (*UsageRecord, error) {
+	if s.Status != SubscriptionActive {
+		return nil, fmt.Errorf("%w: subscription has ended", ErrInvalidInput)
+	}
+	if strings.TrimSpace(metric) == "" {
+		return nil, fmt.Errorf("%w: usage needs a metric", ErrInvalidInput)
+	}
+	quantity = roundQuantity(quantity)
+	if quantity <= 0 {
+		return nil, fmt.Errorf("%w: usage quantity must be positive", ErrInvalidInput)
+	}
+	if occurredAt.Before(s.CurrentPeriodStart) {
+		return nil, fmt.Errorf("%w: the period of %s has already been billed", ErrInvalidInput, occurredAt.Format("2006-01-02"))
+	}
+	if s.EndsAt != nil && !occurredAt.Before(*s.EndsAt) {
+		return nil, fmt.Errorf("%w: the subscription ends on %s", ErrInvalidInput, s.EndsAt.Format("2006-01-02"))
+	}
+
+	record := &UsageRecord{
+		ID:             uuid.New(),
+		OrganizationID: s.OrganizationID,
+		SubscriptionID: s.ID,
+		Metric:         metric,
+		Quantity:       quantity,
+		OccurredAt:     occurredAt.UTC(),
+		IdempotencyKey: key,
+		Source:         source,
+	}
+	if record.IdempotencyKey == "" {
+		record.IdempotencyKey = record.ID.String()
+	}
+	return record, nil
+}
+
+// PlanSegment is the part of a period spent on one plan
+type PlanSegment struct {
+	PlanID     uuid.UUID
+	Start, End time.Time
+}
+
+// PeriodSegments splits the current period, up to when it closes, by the
+// plan changes made in it
+func (s *Subscription) PeriodSegments(changes []SubscriptionPlanChange) []PlanSegment {
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].EffectiveAt.Before(changes[j].EffectiveAt) })
+	end := s.ClosesAt()
+
+	var inPeriod []SubscriptionPlanChange
+	for _, c := range changes {
+		if !c.EffectiveAt.Before(s.CurrentPeriodStart) && c.EffectiveAt.Before(end) {
+			inPeriod = append(inPeriod, c)
+		}
+	}
+
+	planID := s.PlanID
+	if len(inPeriod) > 0 {
+		planID = inPeriod[0].FromPlanID
+	}
+	segments := []PlanSegment{{PlanID: planID, Start: s.CurrentPeriodStart, End: end}}
+	for _, c := range inPeriod {
+		last := &segments[len(segments)-1]
+		last.End = c.EffectiveAt
+		if !last.End.After(last.Start) {
+			segments = segments[:len(segments)-1]
+		}
+		segments = append(segments, PlanSegment{PlanID: c.ToPlanID, Start: c.EffectiveAt, End: end})
+	}
+	return segments
+}
+
+// SubscriptionCharge is one line of the invoice closing a period
+type SubscriptionCharge struct {
+	Segment     PlanSegment
+	Metric      string // Empty for the base fee
+	ItemID      *uuid.UUID
+	TaxCode     string
+	Name        string
+	Description string
+	Quantity    float64
+	UnitPrice   money.Amount
+}
+
+// PeriodCharges prices the current period: the base fee of each plan for its
+// share of the period, prorated by day, and the usage recorded while on it.
+// Usage is rated on the tiers of each plan separately; usage of metrics the
+// plan does not meter is not charged.
+func (s *Subscription) PeriodCharges(segments []PlanSegment, plans map[uuid.UUID]*SubscriptionPlan, usage []UsageRecord) ([]SubscriptionCharge, error) {
+	periodDays := daysBetween(s.CurrentPeriodStart, s.CurrentPeriodEnd)
+	var charges []SubscriptionCharge
+	for i, seg := range segments {
+		plan, ok := plans[seg.PlanID]
+		if !ok {
+			return nil, fmt.Errorf("%w: plan %s of the period is missing", ErrInvalidInput, seg.PlanID)
+		}
+		currency := plan.Currency
+
+		if plan.BaseFee.IsPositive() {
+			charge := SubscriptionCharge{
+				Segment:     seg,
+				ItemID:      plan.ItemID,
+				TaxCode:     plan.TaxCode,
+				Name:        plan.Name,
+				Description: fmt.Sprintf("%s, %s to %s", plan.Name, seg.Start.Format("2006-01-02"), seg.End.AddDate(0, 0, -1).Format("2006-01-02")),
+				Quantity:    1,
+				UnitPrice:   plan.BaseFee,
+			}
+			if days := daysBetween(seg.Start, seg.End); days < periodDays {
+				charge.UnitPrice = plan.BaseFee.MulDiv(money.New(int64(days)), money.New(int64(periodDays)), MoneyRounding).Round(currency, MoneyRounding)
+				charge.Description += fmt.Sprintf(" (%d of %d days)", days, periodDays)
+			}
+			charges = append(charges, charge)
+		}
+
+		// Usage after the last segment ends, reported before the subscription
+		// was cancelled, is billed with it
+		final := i == len(segments)-1
+		used := make(map[string]float64)
+		for _, u := range usage {
+			if !u.OccurredAt.Before(seg.Start) && (u.OccurredAt.Before(seg.End) || final) {
+				used[u.Metric] += u.Quantity
+			}
+		}
+		for ci := range plan.Components {
+			c := &plan.Components[ci]
+			for _, tc := range c.Rate(used[c.Metric]) {
+				if tc.Quantity > 0 && tc.UnitPrice.IsPositive() {
+					charges = append(charges, SubscriptionCharge{
+						Segment:     seg,
+						Metric:      c.Metric,
+						ItemID:      c.ItemID,
+						TaxCode:     c.TaxCode,
+						Name:        c.Name,
+						Description: usageDescription(c, tc),
+						Quantity:    tc.Quantity,
+						UnitPrice:   tc.UnitPrice,
+					})
+				}
+				if tc.FlatFee.IsPositive() {
+					charges = append(charges, SubscriptionCharge{
+						Segment:     seg,
+						Metric:      c.Metric,
+						ItemID:      c.ItemID,
+						TaxCode:     c.TaxCode,
+						Name:        c.Name,
+						Description: fmt.Sprintf("%s, tier %d fee", c.Name, tc.Tier),
+						Quantity:    1,
+						UnitPrice:   tc.FlatFee,
+					})
+				}
+			}
+		}
+	}
+	return charges, nil
+}
+
+func usageDescription(c *PlanComponent, tc TierCharge) string {
+	unit := c.Unit
+	if unit == "" {
+		unit = c.Metric
+	}
+	desc := fmt.Sprintf("%s: %s %s", c.Name, formatQuantity(tc.Quantity), unit)
+	if len(c.Tiers) > 1 {
+		desc += fmt.Sprintf(", tier %d", tc.Tier)
+	}
+	return desc
+}
+
+func formatQuantity(q float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", q), "0"), ".")
+}
+
+func daysBetween(start, end time.Time) int {
+	return int(math.Round(end.Sub(start).Hours() / 24))
+}
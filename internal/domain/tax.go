@@ -0,0 +1,130 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// TaxRate is a single tax an organization charges, e.g. "CA State 7.25%".
+// Rate is a percentage. A compound rate is charged on the line amount plus
+// every tax that precedes it in its group.
+type TaxRate struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID    `gorm:"type:uuid;uniqueIndex:idx_tax_rate_org_code" json:"organization_id"`
+	Code           string       `gorm:"type:varchar(50);uniqueIndex:idx_tax_rate_org_code" json:"code"`
+	Name           string       `gorm:"type:varchar(100)" json:"name"`
+	Rate           money.Amount `gorm:"type:decimal(9,4)" json:"rate"`
+	Compound       bool         `gorm:"default:false" json:"compound"`
+	Active         bool         `gorm:"default:true" json:"active"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// TaxGroup combines several rates under one code, e.g. state plus county.
+// Components are applied in Sequence order.
+type TaxGroup struct {
+	ID             uuid.UUID           `gorm:"type:uuid;primaryKey" json:"id"`
+	OrganizationID uuid.UUID           `gorm:"type:uuid;uniqueIndex:idx_tax_group_org_code" json:"organization_id"`
+	Code           string              `gorm:"type:varchar(50);uniqueIndex:idx_tax_group_org_code" json:"code"`
+	Name           string              `gorm:"type:varchar(100)" json:"name"`
+	Active         bool                `gorm:"default:true" json:"active"`
+	Components     []TaxGroupComponent `gorm:"foreignKey:TaxGroupID" json:"components"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+type TaxGroupComponent struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	TaxGroupID uuid.UUID `gorm:"type:uuid;index" json:"tax_group_id"`
+	TaxRateID  uuid.UUID `gorm:"type:uuid;index" json:"tax_rate_id"`
+	Sequence   int       `json:"sequence"`
+	TaxRate    *TaxRate  `gorm:"foreignKey:TaxRateID" json:"tax_rate,omitempty"`
+}
+
+// InvoiceTax is one row of an invoice's tax breakdown: everything a single
+// rate charged across the invoice lines. TaxRateID is nil for tax amounts
+// entered by hand on lines without a tax code.
+type InvoiceTax struct {
+	ID            uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
+	InvoiceID     uuid.UUID    `gorm:"type:uuid;index" json:"invoice_id"`
+	TaxRateID     *uuid.UUID   `gorm:"type:uuid;index" json:"tax_rate_id,omitempty"`
+	Code          string       `gorm:"type:varchar(50)" json:"code"`
+	Name          string       `gorm:"type:varchar(100)" json:"name"`
+	Rate          money.Amount `gorm:"type:decimal(9,4)" json:"rate"`
+	Compound      bool         `json:"compound"`
+	TaxableAmount money.Amount `gorm:"type:decimal(15,2)" json:"taxable_amount"`
+	TaxAmount     money.Amount `gorm:"type:decimal(15,2)" json:"tax_amount"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// Validate checks a rate before it is stored
+func (r *TaxRate) Validate() error {
+	if r.Code == "" || r.Name == "" {
+		return fmt.Errorf("%w: tax rate needs a code and a name", ErrInvalidInput)
+	}
+	if r.Rate.IsNegative() || r.Rate.GreaterThan(money.New(100)) {
+		return fmt.Errorf("%w: tax rate must be between 0 and 100 percent", ErrInvalidInput)
+	}
+	return nil
+}
+
+// TaxLine is the tax a single rate adds to a single invoice line
+type TaxLine struct {
+	Rate    TaxRate
+	Taxable money.Amount
+	Amount  money.Amount
+}
+
+// CalculateTax applies rates, in order, to amount (a line amount after
+// discount). Non-compound rates are charged on the net amount; a compound
+// rate is charged on the net amount plus all taxes before it.
+//
+// With inclusive set, amount already contains the taxes: the net amount is
+// backed out first and any rounding difference goes to the last tax, so net
+// plus taxes always equals amount. Every tax is rounded with round.
+func CalculateTax(amount money.Amount, rates []TaxRate, inclusive bool, round func(money.Amount) money.Amount) (money.Amount, []TaxLine) {
+	if len(rates) == 0 {
+		return amount, nil
+	}
+
+	net := amount
+	if inclusive {
+		// Gross amount for a nominal net of 10000 gives the exact gross/net ratio
+		base := money.New(10000)
+		gross := base
+		for _, t := range applyRates(base, rates, func(a money.Amount) money.Amount { return a }) {
+			gross = gross.Add(t.Amount)
+		}
+		net = round(amount.MulDiv(base, gross, MoneyRounding))
+	}
+
+	taxes := applyRates(net, rates, round)
+	if inclusive {
+		residual := amount.Sub(net)
+		for _, t := range taxes {
+			residual = residual.Sub(t.Amount)
+		}
+		last := &taxes[len(taxes)-1]
+		last.Amount = last.Amount.Add(residual)
+	}
+	return net, taxes
+}
+
+func applyRates(net money.Amount, rates []TaxRate, round func(money.Amount) money.Amount) []TaxLine {
+	taxes := make([]TaxLine, 0, len(rates))
+	charged := money.Zero
+	for _, rate := range rates {
+		taxable := net
+		if rate.Compound {
+			taxable = net.Add(charged)
+		}
+		amount := round(taxable.Percent(rate.Rate))
+		taxes = append(taxes, TaxLine{Rate: rate, Taxable: taxable, Amount: amount})
+		charged = charged.Add(amount)
+	}
+	return taxes
+}
@@ -0,0 +1,28 @@
+package external
+
+import (
+	"context"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+)
+
+// PaymentGateway defines the interface of an online payment provider
+type PaymentGateway interface {
+	// Name identifies the provider in transaction references
+	Name() string
+	// CreateIntent starts collecting a payment for an invoice
+	CreateIntent(ctx context.Context, req domain.PaymentIntentRequest) (*domain.PaymentIntent, error)
+	// GetIntent returns an intent as the provider knows it
+	GetIntent(ctx context.Context, intentID string) (*domain.PaymentIntent, error)
+	// Capture collects an authorized intent, or amount of it when positive
+	Capture(ctx context.Context, intentID string, amount money.Amount) (*domain.PaymentIntent, error)
+	// Refund sends amount of a captured transaction back to the payer. A
+	// retry with the same idempotencyKey returns the refund made the first
+	// time instead of refunding again.
+	Refund(ctx context.Context, transactionRef string, amount money.Amount, currency string, idempotencyKey string) (*domain.GatewayRefund, error)
+	// ParseWebhook verifies the signature of a webhook body and decodes it.
+	// It fails with domain.ErrInvalidSignature when the body was not sent
+	// by the provider.
+	ParseWebhook(payload []byte, signature string) (*domain.GatewayEvent, error)
+}
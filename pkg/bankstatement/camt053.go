@@ -0,0 +1,187 @@
+package bankstatement
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Only the parts of ISO 20022 camt.053 needed to match payments are read.
+// Element names are matched without their namespace, so every version of
+// the message is accepted.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID      string      `xml:"Id"`
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	Other   string      `xml:"Acct>Id>Othr>Id"`
+	Ccy     string      `xml:"Acct>Ccy"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value string `xml:",chardata"`
+	Ccy   string `xml:"Ccy,attr"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtEntry struct {
+	Ref         string          `xml:"NtryRef"`
+	Amt         camtAmount      `xml:"Amt"`
+	CdtDbtInd   string          `xml:"CdtDbtInd"`
+	BookingDate camtDate        `xml:"BookgDt"`
+	ValueDate   camtDate        `xml:"ValDt"`
+	AcctSvcrRef string          `xml:"AcctSvcrRef"`
+	AddtlInf    string          `xml:"AddtlNtryInf"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtTxDetails struct {
+	Amt          camtAmount `xml:"Amt"`
+	TxAmt        camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	CdtDbtInd    string     `xml:"CdtDbtInd"`
+	EndToEndID   string     `xml:"Refs>EndToEndId"`
+	AcctSvcrRef  string     `xml:"Refs>AcctSvcrRef"`
+	Unstructured []string   `xml:"RmtInf>Ustrd"`
+	CreditorRef  []string   `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	DebtorName   string     `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPty    string     `xml:"RltdPties>Dbtr>Pty>Nm"`
+	DebtorIBAN   string     `xml:"RltdPties>DbtrAcct>Id>IBAN"`
+	CreditorName string     `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPty  string     `xml:"RltdPties>Cdtr>Pty>Nm"`
+	CreditorIBAN string     `xml:"RltdPties>CdtrAcct>Id>IBAN"`
+}
+
+func parseCAMT053(data []byte) (*Statement, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Statements) == 0 {
+		return nil, fmt.Errorf("no statement in file")
+	}
+
+	// A file may carry several statements of the same account; their
+	// entries are read as one
+	first := doc.Statements[0]
+	st := &Statement{ID: first.ID, Account: first.IBAN, Currency: first.Ccy}
+	if st.Account == "" {
+		st.Account = first.Other
+	}
+	for _, s := range doc.Statements {
+		for _, e := range s.Entries {
+			txs, err := camtTransactions(e)
+			if err != nil {
+				return nil, fmt.Errorf("entry %s: %w", e.Ref, err)
+			}
+			st.Transactions = append(st.Transactions, txs...)
+		}
+	}
+	if st.Currency == "" && len(st.Transactions) > 0 {
+		st.Currency = st.Transactions[0].Currency
+	}
+	return st, nil
+}
+
+// camtTransactions splits a batch entry into its transactions when each
+// carries its own amount, and reads the entry as one transaction otherwise
+func camtTransactions(e camtEntry) ([]Transaction, error) {
+	booked, err := camtParseDate(e.BookingDate)
+	if err != nil {
+		return nil, err
+	}
+	valued, _ := camtParseDate(e.ValueDate)
+
+	details := e.Details
+	split := len(details) > 1
+	for _, d := range details {
+		if d.amount().Value == "" {
+			split = false
+		}
+	}
+	if !split {
+		var d camtTxDetails
+		if len(details) > 0 {
+			d = details[0]
+		}
+		d.Amt, d.TxAmt, d.CdtDbtInd = e.Amt, camtAmount{}, e.CdtDbtInd
+		details = []camtTxDetails{d}
+	}
+
+	txs := make([]Transaction, 0, len(details))
+	for _, d := range details {
+		amt := d.amount()
+		amount, err := parseAmount(amt.Value)
+		if err != nil {
+			return nil, err
+		}
+		indicator := d.CdtDbtInd
+		if indicator == "" {
+			indicator = e.CdtDbtInd
+		}
+		if indicator == "DBIT" {
+			amount = amount.Neg()
+		}
+
+		tx := Transaction{
+			BookingDate: booked,
+			ValueDate:   valued,
+			Amount:      amount,
+			Currency:    amt.Ccy,
+			Reference:   d.EndToEndID,
+			Description: strings.TrimSpace(strings.Join(append(d.CreditorRef, d.Unstructured...), " ")),
+			BankRef:     firstNonEmpty(d.AcctSvcrRef, e.AcctSvcrRef, e.Ref),
+		}
+		if tx.Reference == "NOTPROVIDED" {
+			tx.Reference = ""
+		}
+		if tx.Description == "" {
+			tx.Description = e.AddtlInf
+		}
+		// The counterparty is the payer of money received and the payee of
+		// money paid out
+		if amount.IsNegative() {
+			tx.CounterpartyName, tx.CounterpartyAccount = firstNonEmpty(d.CreditorName, d.CreditorPty), d.CreditorIBAN
+		} else {
+			tx.CounterpartyName, tx.CounterpartyAccount = firstNonEmpty(d.DebtorName, d.DebtorPty), d.DebtorIBAN
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func (d camtTxDetails) amount() camtAmount {
+	if d.Amt.Value != "" {
+		return d.Amt
+	}
+	return d.TxAmt
+}
+
+func camtParseDate(d camtDate) (time.Time, error) {
+	if d.Date != "" {
+		return time.Parse("2006-01-02", strings.TrimSpace(d.Date))
+	}
+	if d.DateTime != "" {
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(d.DateTime)); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02T15:04:05", strings.TrimSpace(d.DateTime))
+	}
+	return time.Time{}, fmt.Errorf("missing date")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
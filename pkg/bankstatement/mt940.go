@@ -0,0 +1,162 @@
+package bankstatement
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mt940Line matches the :61: statement line: value date, optional entry
+// date, debit/credit mark, optional funds code, amount, transaction type,
+// the account owner's reference and the bank's reference
+var mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?([\d,]+)([NFS][A-Z0-9]{3})([^/]*)(?://(.*))?$`)
+
+// mt940Balance matches the opening balance: mark, date, currency, amount
+var mt940Balance = regexp.MustCompile(`^[CD](\d{6})([A-Z]{3})`)
+
+type mt940Field struct {
+	tag   string
+	value string
+}
+
+func parseMT940(data []byte) (*Statement, error) {
+	fields, err := mt940Fields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Statement{}
+	var current *Transaction
+	for _, f := range fields {
+		switch f.tag {
+		case "20":
+			if st.ID == "" {
+				st.ID = f.value
+			}
+		case "25":
+			if st.Account == "" {
+				st.Account = f.value
+			}
+		case "60F", "60M":
+			if m := mt940Balance.FindStringSubmatch(f.value); m != nil && st.Currency == "" {
+				st.Currency = m[2]
+			}
+		case "61":
+			tx, err := parseMT940Line(f.value)
+			if err != nil {
+				return nil, err
+			}
+			st.Transactions = append(st.Transactions, tx)
+			current = &st.Transactions[len(st.Transactions)-1]
+		case "86":
+			if current != nil {
+				applyMT940Info(current, f.value)
+			}
+		}
+	}
+	return st, nil
+}
+
+// mt940Fields splits the file into tagged fields, joining continuation lines
+func mt940Fields(data []byte) ([]mt940Field, error) {
+	var fields []mt940Field
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, ":") {
+			if end := strings.Index(line[1:], ":"); end > 0 {
+				fields = append(fields, mt940Field{tag: line[1 : end+1], value: line[end+2:]})
+				continue
+			}
+		}
+		if line == "-" || strings.HasPrefix(line, "{") || len(fields) == 0 {
+			continue
+		}
+		fields[len(fields)-1].value += "\n" + line
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func parseMT940Line(value string) (Transaction, error) {
+	first, supplementary, _ := strings.Cut(value, "\n")
+	m := mt940Line.FindStringSubmatch(strings.TrimSpace(first))
+	if m == nil {
+		return Transaction{}, fmt.Errorf("malformed statement line %q", first)
+	}
+
+	valued, err := time.Parse("060102", m[1])
+	if err != nil {
+		return Transaction{}, err
+	}
+	booked := valued
+	if m[2] != "" {
+		// The entry date only has month and day; it may fall in the next or
+		// previous year around new year
+		booked, err = time.Parse("060102", m[1][:2]+m[2])
+		if err != nil {
+			return Transaction{}, err
+		}
+		if diff := booked.Sub(valued); diff > 180*24*time.Hour {
+			booked = booked.AddDate(-1, 0, 0)
+		} else if diff < -180*24*time.Hour {
+			booked = booked.AddDate(1, 0, 0)
+		}
+	}
+
+	amount, err := parseAmount(m[5])
+	if err != nil {
+		return Transaction{}, err
+	}
+	// Reversals of credits are debits and the other way around
+	if m[3] == "D" || m[3] == "RC" {
+		amount = amount.Neg()
+	}
+
+	tx := Transaction{
+		BookingDate: booked,
+		ValueDate:   valued,
+		Amount:      amount,
+		Reference:   strings.TrimSpace(m[7]),
+		BankRef:     strings.TrimSpace(m[8]),
+		Description: strings.TrimSpace(supplementary),
+	}
+	if tx.Reference == "NONREF" {
+		tx.Reference = ""
+	}
+	return tx, nil
+}
+
+// applyMT940Info reads the :86: field. Structured fields as used by German
+// banks carry ?20-?29 remittance text, ?31 the account and ?32/?33 the name
+// of the counterparty; anything else is kept as free text.
+func applyMT940Info(tx *Transaction, value string) {
+	value = strings.ReplaceAll(value, "\n", "")
+	if !strings.Contains(value, "?20") {
+		tx.Description = strings.TrimSpace(strings.Join([]string{tx.Description, value}, " "))
+		return
+	}
+
+	var remittance, name []string
+	for _, part := range strings.Split(value, "?")[1:] {
+		if len(part) < 2 {
+			continue
+		}
+		code, text := part[:2], strings.TrimSpace(part[2:])
+		switch {
+		case code >= "20" && code <= "29", code >= "60" && code <= "63":
+			remittance = append(remittance, text)
+		case code == "31":
+			tx.CounterpartyAccount = text
+		case code == "32", code == "33":
+			name = append(name, text)
+		}
+	}
+	tx.Description = strings.TrimSpace(strings.Join(remittance, " "))
+	tx.CounterpartyName = strings.TrimSpace(strings.Join(name, " "))
+}
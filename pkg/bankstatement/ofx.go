@@ -0,0 +1,100 @@
+package bankstatement
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ofxTransaction = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+	ofxStatement   = regexp.MustCompile(`(?is)<STMTRS>(.*?)</STMTRS>`)
+)
+
+// parseOFX reads OFX 1.x (SGML, where leaf elements are not closed) and
+// OFX 2.x (XML) alike by looking up each element's text up to the next tag
+func parseOFX(data []byte) (*Statement, error) {
+	body := string(data)
+	stmt := ofxStatement.FindStringSubmatch(body)
+	if stmt == nil {
+		return nil, fmt.Errorf("no bank statement in file")
+	}
+
+	st := &Statement{
+		Account:  ofxValue(stmt[1], "ACCTID"),
+		Currency: strings.ToUpper(ofxValue(stmt[1], "CURDEF")),
+	}
+	if start, end := ofxValue(stmt[1], "DTSTART"), ofxValue(stmt[1], "DTEND"); start != "" || end != "" {
+		st.ID = start + "-" + end
+	}
+
+	for _, m := range ofxTransaction.FindAllStringSubmatch(stmt[1], -1) {
+		tx, err := parseOFXTransaction(m[1])
+		if err != nil {
+			return nil, err
+		}
+		st.Transactions = append(st.Transactions, tx)
+	}
+	return st, nil
+}
+
+func parseOFXTransaction(block string) (Transaction, error) {
+	raw := ofxValue(block, "TRNAMT")
+	negative := strings.HasPrefix(raw, "-")
+	amount, err := parseAmount(strings.TrimLeft(raw, "+-"))
+	if err != nil {
+		return Transaction{}, fmt.Errorf("transaction %s: %w", ofxValue(block, "FITID"), err)
+	}
+	if negative {
+		amount = amount.Neg()
+	}
+
+	posted, err := ofxDate(ofxValue(block, "DTPOSTED"))
+	if err != nil {
+		return Transaction{}, fmt.Errorf("transaction %s: %w", ofxValue(block, "FITID"), err)
+	}
+	valued := posted
+	if avail := ofxValue(block, "DTAVAIL"); avail != "" {
+		if t, err := ofxDate(avail); err == nil {
+			valued = t
+		}
+	}
+
+	return Transaction{
+		BookingDate:      posted,
+		ValueDate:        valued,
+		Amount:           amount,
+		Currency:         strings.ToUpper(ofxValue(block, "CURRENCY")),
+		Reference:        firstNonEmpty(ofxValue(block, "REFNUM"), ofxValue(block, "CHECKNUM")),
+		Description:      ofxValue(block, "MEMO"),
+		CounterpartyName: ofxValue(block, "NAME"),
+		BankRef:          ofxValue(block, "FITID"),
+	}, nil
+}
+
+// ofxValue returns the text of the first leaf element named tag
+func ofxValue(block, tag string) string {
+	open := "<" + tag + ">"
+	i := strings.Index(block, open)
+	if i < 0 {
+		return ""
+	}
+	rest := block[i+len(open):]
+	if end := strings.IndexAny(rest, "<\r\n"); end >= 0 {
+		rest = rest[:end]
+	}
+	return strings.TrimSpace(ofxUnescape(rest))
+}
+
+func ofxUnescape(s string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&apos;", "'", "&quot;", `"`).Replace(s)
+}
+
+// ofxDate reads YYYYMMDD[HHMMSS[.XXX]][[offset:TZ]]; only the date is kept
+func ofxDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("malformed date %q", s)
+	}
+	return time.Parse("20060102", s[:8])
+}
@@ -0,0 +1,109 @@
+// Package bankstatement reads bank statements exported as CAMT.053, MT940 or
+// OFX into one format-neutral list of transactions.
+package bankstatement
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"erp-billing-service/pkg/money"
+)
+
+type Format string
+
+const (
+	FormatCAMT053 Format = "camt053"
+	FormatMT940   Format = "mt940"
+	FormatOFX     Format = "ofx"
+)
+
+// ErrUnknownFormat is returned when a file is not in a supported format
+var ErrUnknownFormat = errors.New("unknown bank statement format")
+
+// Statement is one account statement
+type Statement struct {
+	Format       Format
+	ID           string // Statement reference given by the bank
+	Account      string // IBAN or account number
+	Currency     string
+	Transactions []Transaction
+}
+
+// Transaction is one booked entry of a statement. Amount is positive for
+// money received and negative for money paid out.
+type Transaction struct {
+	BookingDate         time.Time
+	ValueDate           time.Time
+	Amount              money.Amount
+	Currency            string
+	Reference           string // Reference given by the payer, such as an end-to-end ID
+	Description         string // Remittance information
+	CounterpartyName    string
+	CounterpartyAccount string
+	BankRef             string // The bank's own ID for the entry
+}
+
+// ParseFormat validates a format name received from a client
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.ReplaceAll(s, ".", ""))); f {
+	case FormatCAMT053, FormatMT940, FormatOFX:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+	}
+}
+
+// Detect guesses the format of a statement file from its content
+func Detect(data []byte) (Format, error) {
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	switch {
+	case bytes.Contains(head, []byte("BkToCstmrStmt")):
+		return FormatCAMT053, nil
+	case bytes.Contains(head, []byte("OFXHEADER")), bytes.Contains(bytes.ToUpper(head), []byte("<OFX>")):
+		return FormatOFX, nil
+	case bytes.Contains(head, []byte(":20:")) && bytes.Contains(data, []byte(":61:")):
+		return FormatMT940, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// Parse reads a statement file in the given format
+func Parse(format Format, data []byte) (*Statement, error) {
+	var st *Statement
+	var err error
+	switch format {
+	case FormatCAMT053:
+		st, err = parseCAMT053(data)
+	case FormatMT940:
+		st, err = parseMT940(data)
+	case FormatOFX:
+		st, err = parseOFX(data)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s statement: %w", format, err)
+	}
+	st.Format = format
+	for i := range st.Transactions {
+		if st.Transactions[i].Currency == "" {
+			st.Transactions[i].Currency = st.Currency
+		}
+	}
+	return st, nil
+}
+
+// parseAmount reads an unsigned decimal amount, with a point or a comma as
+// the decimal separator
+func parseAmount(s string) (money.Amount, error) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, ",", "."))
+	s = strings.TrimSuffix(s, ".")
+	return money.Parse(s)
+}
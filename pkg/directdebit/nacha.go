@@ -0,0 +1,180 @@
+package directdebit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"erp-billing-service/pkg/money"
+)
+
+// ACH account types
+const (
+	AccountChecking = "checking"
+	AccountSavings  = "savings"
+)
+
+const (
+	nachaRecordSize     = 94
+	nachaBlockingFactor = 10
+	serviceClassDebits  = "225"
+)
+
+type ACHOriginator struct {
+	CompanyName          string
+	CompanyID            string // Usually "1" followed by the EIN
+	ODFIRouting          string // Routing number of the originating bank
+	ImmediateDestination string // Routing number the file is sent to, usually the ODFI
+	DestinationName      string
+}
+
+type ACHEntry struct {
+	TraceNumber    string // See TraceNumber
+	RoutingNumber  string
+	AccountNumber  string
+	AccountType    string // checking or savings
+	Amount         money.Amount
+	IndividualID   string // Shown to the customer, such as an invoice number
+	IndividualName string
+}
+
+type ACHBatch struct {
+	BatchNumber      int64
+	CreatedAt        time.Time
+	EffectiveDate    time.Time
+	EntryDescription string // Shown on the customer's statement
+	SECCode          string // PPD for consumers, CCD for businesses
+	Originator       ACHOriginator
+	Entries          []ACHEntry
+}
+
+// TraceNumber returns the trace number of an entry: the first eight digits
+// of the ODFI routing number and a seven digit sequence
+func TraceNumber(odfiRouting string, sequence int64) string {
+	return fmt.Sprintf("%.8s%07d", odfiRouting, sequence%10000000)
+}
+
+// GenerateNACHA writes a NACHA file with one debit batch
+func GenerateNACHA(b ACHBatch) ([]byte, error) {
+	o := b.Originator
+	if !ValidRoutingNumber(o.ODFIRouting) || !ValidRoutingNumber(o.ImmediateDestination) {
+		return nil, fmt.Errorf("originator routing numbers are not valid")
+	}
+	if len(b.Entries) == 0 {
+		return nil, fmt.Errorf("batch has no entries")
+	}
+	sec := b.SECCode
+	if sec == "" {
+		sec = "PPD"
+	}
+
+	var records []string
+	records = append(records, "101"+
+		" "+o.ImmediateDestination+
+		nachaField(o.CompanyID, 10)+
+		b.CreatedAt.Format("060102")+
+		b.CreatedAt.Format("1504")+
+		"A"+
+		"094"+
+		"10"+
+		"1"+
+		nachaField(o.DestinationName, 23)+
+		nachaField(o.CompanyName, 23)+
+		nachaField("", 8))
+
+	records = append(records, "5"+serviceClassDebits+
+		nachaField(o.CompanyName, 16)+
+		nachaField("", 20)+
+		nachaField(o.CompanyID, 10)+
+		sec+
+		nachaField(b.EntryDescription, 10)+
+		b.EffectiveDate.Format("060102")+
+		b.EffectiveDate.Format("060102")+
+		"   "+
+		"1"+
+		o.ODFIRouting[:8]+
+		fmt.Sprintf("%07d", b.BatchNumber))
+
+	var hash, debits int64
+	for _, e := range b.Entries {
+		if len(e.TraceNumber) != 15 {
+			return nil, fmt.Errorf("entry %s: trace number must be 15 digits", e.IndividualID)
+		}
+		if !ValidRoutingNumber(e.RoutingNumber) {
+			return nil, fmt.Errorf("entry %s: routing number %s is not valid", e.IndividualID, e.RoutingNumber)
+		}
+		cents := e.Amount.MinorUnitsOf("USD", money.RoundHalfUp)
+		if cents <= 0 || cents > 9999999999 {
+			return nil, fmt.Errorf("entry %s: amount %s is out of range", e.IndividualID, e.Amount)
+		}
+		code := "27"
+		if e.AccountType == AccountSavings {
+			code = "37"
+		}
+		rdfi, _ := strconv.ParseInt(e.RoutingNumber[:8], 10, 64)
+		hash += rdfi
+		debits += cents
+
+		records = append(records, "6"+code+
+			e.RoutingNumber+
+			nachaField(e.AccountNumber, 17)+
+			fmt.Sprintf("%010d", cents)+
+			nachaField(e.IndividualID, 15)+
+			nachaField(e.IndividualName, 22)+
+			"  "+
+			"0"+
+			e.TraceNumber)
+	}
+
+	hash %= 10000000000
+	count := len(b.Entries)
+	records = append(records, "8"+serviceClassDebits+
+		fmt.Sprintf("%06d", count)+
+		fmt.Sprintf("%010d", hash)+
+		fmt.Sprintf("%012d", debits)+
+		fmt.Sprintf("%012d", 0)+
+		nachaField(o.CompanyID, 10)+
+		nachaField("", 19)+
+		nachaField("", 6)+
+		o.ODFIRouting[:8]+
+		fmt.Sprintf("%07d", b.BatchNumber))
+
+	total := len(records) + 1
+	blocks := (total + nachaBlockingFactor - 1) / nachaBlockingFactor
+	records = append(records, "9"+
+		fmt.Sprintf("%06d", 1)+
+		fmt.Sprintf("%06d", blocks)+
+		fmt.Sprintf("%08d", count)+
+		fmt.Sprintf("%010d", hash)+
+		fmt.Sprintf("%012d", debits)+
+		fmt.Sprintf("%012d", 0)+
+		nachaField("", 39))
+
+	// The file is padded with all-nines records to whole blocks
+	for len(records)%nachaBlockingFactor != 0 {
+		records = append(records, strings.Repeat("9", nachaRecordSize))
+	}
+	for i, r := range records {
+		if len(r) != nachaRecordSize {
+			return nil, fmt.Errorf("record %d is %d characters long", i+1, len(r))
+		}
+	}
+	return []byte(strings.Join(records, "\n") + "\n"), nil
+}
+
+// nachaField left-justifies upper-cased printable text in a field of n
+// characters
+func nachaField(s string, n int) string {
+	out := make([]byte, 0, n)
+	for _, r := range strings.ToUpper(s) {
+		if len(out) == n {
+			break
+		}
+		if r < ' ' || r > '~' {
+			r = ' '
+		}
+		out = append(out, byte(r))
+	}
+	return string(out) + strings.Repeat(" ", n-len(out))
+}
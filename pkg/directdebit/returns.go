@@ -0,0 +1,91 @@
+package directdebit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+// ErrUnknownReturnFormat is returned when a file is neither a pain.002 status
+// report nor a NACHA return file
+var ErrUnknownReturnFormat = errors.New("unknown direct debit return format")
+
+// ReturnItem is the outcome of one collection reported by the bank. SEPA
+// items are identified by their end-to-end ID, ACH items by the trace
+// number of the original entry.
+type ReturnItem struct {
+	EndToEndID  string
+	TraceNumber string
+	Accepted    bool
+	ReasonCode  string // Such as AC04 or R01 when not accepted
+}
+
+type pain002Document struct {
+	Groups []struct {
+		Txs []struct {
+			EndToEndID string   `xml:"OrgnlEndToEndId"`
+			Status     string   `xml:"TxSts"`
+			Reasons    []string `xml:"StsRsnInf>Rsn>Cd"`
+		} `xml:"TxInfAndSts"`
+	} `xml:"CstmrPmtStsRpt>OrgnlPmtInfAndSts"`
+}
+
+// ParseReturns reads a SEPA pain.002 payment status report or a NACHA
+// return file
+func ParseReturns(data []byte) ([]ReturnItem, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.Contains(trimmed, []byte("CstmrPmtStsRpt")):
+		return parsePain002(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("1")):
+		return parseNACHAReturns(trimmed)
+	default:
+		return nil, ErrUnknownReturnFormat
+	}
+}
+
+func parsePain002(data []byte) ([]ReturnItem, error) {
+	var doc pain002Document
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var items []ReturnItem
+	for _, g := range doc.Groups {
+		for _, tx := range g.Txs {
+			item := ReturnItem{EndToEndID: strings.TrimSpace(tx.EndToEndID)}
+			switch strings.TrimSpace(tx.Status) {
+			case "ACCP", "ACSC", "ACSP", "ACTC":
+				item.Accepted = true
+			case "RJCT":
+				if len(tx.Reasons) > 0 {
+					item.ReasonCode = strings.TrimSpace(tx.Reasons[0])
+				}
+			default:
+				// Pending statuses say nothing final yet
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// parseNACHAReturns reads the return addenda (type 99) of a return file.
+// Each names the reason code and the trace number of the original entry.
+func parseNACHAReturns(data []byte) ([]ReturnItem, error) {
+	var items []ReturnItem
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) < 21 || !strings.HasPrefix(line, "799") {
+			continue
+		}
+		items = append(items, ReturnItem{
+			TraceNumber: strings.TrimSpace(line[6:21]),
+			ReasonCode:  strings.TrimSpace(line[3:6]),
+		})
+	}
+	return items, scanner.Err()
+}
@@ -0,0 +1,201 @@
+package directdebit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"erp-billing-service/pkg/money"
+)
+
+// SEPA sequence types: the first collection under a mandate and the ones after it
+const (
+	SequenceFirst     = "FRST"
+	SequenceRecurring = "RCUR"
+)
+
+type SEPACreditor struct {
+	Name       string
+	CreditorID string // SEPA creditor identifier
+	IBAN       string
+	BIC        string
+}
+
+type SEPATransaction struct {
+	EndToEndID      string
+	Amount          money.Amount
+	MandateID       string
+	MandateSignedAt time.Time
+	SequenceType    string // FRST or RCUR
+	DebtorName      string
+	DebtorIBAN      string
+	DebtorBIC       string // Optional
+	Remittance      string
+}
+
+type SEPABatch struct {
+	MessageID      string
+	CreatedAt      time.Time
+	CollectionDate time.Time
+	Creditor       SEPACreditor
+	Transactions   []SEPATransaction
+}
+
+type pain008Document struct {
+	XMLName xml.Name     `xml:"Document"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Body    pain008Initn `xml:"CstmrDrctDbtInitn"`
+}
+
+type pain008Initn struct {
+	GrpHdr  pain008GroupHeader `xml:"GrpHdr"`
+	PmtInfs []pain008PmtInf    `xml:"PmtInf"`
+}
+
+type pain008GroupHeader struct {
+	MsgID    string `xml:"MsgId"`
+	CreDtTm  string `xml:"CreDtTm"`
+	NbOfTxs  int    `xml:"NbOfTxs"`
+	CtrlSum  string `xml:"CtrlSum"`
+	InitgPty struct {
+		Nm string `xml:"Nm"`
+	} `xml:"InitgPty"`
+}
+
+type pain008PmtInf struct {
+	PmtInfID string `xml:"PmtInfId"`
+	PmtMtd   string `xml:"PmtMtd"`
+	NbOfTxs  int    `xml:"NbOfTxs"`
+	CtrlSum  string `xml:"CtrlSum"`
+	PmtTpInf struct {
+		SvcLvl   string `xml:"SvcLvl>Cd"`
+		LclInstr string `xml:"LclInstrm>Cd"`
+		SeqTp    string `xml:"SeqTp"`
+	} `xml:"PmtTpInf"`
+	ReqdColltnDt string       `xml:"ReqdColltnDt"`
+	CdtrNm       string       `xml:"Cdtr>Nm"`
+	CdtrIBAN     string       `xml:"CdtrAcct>Id>IBAN"`
+	CdtrAgt      pain008Agent `xml:"CdtrAgt"`
+	ChrgBr       string       `xml:"ChrgBr"`
+	CdtrSchmeID  struct {
+		ID     string `xml:"Id>PrvtId>Othr>Id"`
+		Scheme string `xml:"Id>PrvtId>Othr>SchmeNm>Prtry"`
+	} `xml:"CdtrSchmeId"`
+	Txs []pain008Tx `xml:"DrctDbtTxInf"`
+}
+
+type pain008Agent struct {
+	BIC   string `xml:"FinInstnId>BIC,omitempty"`
+	Other string `xml:"FinInstnId>Othr>Id,omitempty"`
+}
+
+type pain008Tx struct {
+	EndToEndID string `xml:"PmtId>EndToEndId"`
+	InstdAmt   struct {
+		Ccy   string `xml:"Ccy,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"InstdAmt"`
+	MndtID    string       `xml:"DrctDbtTx>MndtRltdInf>MndtId"`
+	DtOfSgntr string       `xml:"DrctDbtTx>MndtRltdInf>DtOfSgntr"`
+	DbtrAgt   pain008Agent `xml:"DbtrAgt"`
+	DbtrNm    string       `xml:"Dbtr>Nm"`
+	DbtrIBAN  string       `xml:"DbtrAcct>Id>IBAN"`
+	Ustrd     string       `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+// GeneratePain008 writes a SEPA Core direct debit initiation
+// (pain.008.001.02) with one payment information block per sequence type
+func GeneratePain008(b SEPABatch) ([]byte, error) {
+	if len(b.Transactions) == 0 {
+		return nil, fmt.Errorf("batch has no transactions")
+	}
+
+	doc := pain008Document{Xmlns: "urn:iso:std:iso:20022:tech:xsd:pain.008.001.02"}
+	doc.Body.GrpHdr.MsgID = b.MessageID
+	doc.Body.GrpHdr.CreDtTm = b.CreatedAt.UTC().Format("2006-01-02T15:04:05")
+	doc.Body.GrpHdr.InitgPty.Nm = sepaText(b.Creditor.Name, 70)
+
+	var total money.Amount
+	for _, seq := range []string{SequenceFirst, SequenceRecurring} {
+		info := pain008PmtInf{
+			PmtInfID:     b.MessageID + "-" + seq,
+			PmtMtd:       "DD",
+			ReqdColltnDt: b.CollectionDate.Format("2006-01-02"),
+			CdtrNm:       sepaText(b.Creditor.Name, 70),
+			CdtrIBAN:     NormalizeIBAN(b.Creditor.IBAN),
+			CdtrAgt:      agent(b.Creditor.BIC),
+			ChrgBr:       "SLEV",
+		}
+		info.PmtTpInf.SvcLvl = "SEPA"
+		info.PmtTpInf.LclInstr = "CORE"
+		info.PmtTpInf.SeqTp = seq
+		info.CdtrSchmeID.ID = b.Creditor.CreditorID
+		info.CdtrSchmeID.Scheme = "SEPA"
+
+		var sum money.Amount
+		for _, t := range b.Transactions {
+			if t.SequenceType != seq {
+				continue
+			}
+			if !t.Amount.IsPositive() {
+				return nil, fmt.Errorf("transaction %s: amount must be positive", t.EndToEndID)
+			}
+			tx := pain008Tx{
+				EndToEndID: t.EndToEndID,
+				MndtID:     t.MandateID,
+				DtOfSgntr:  t.MandateSignedAt.Format("2006-01-02"),
+				DbtrAgt:    agent(t.DebtorBIC),
+				DbtrNm:     sepaText(t.DebtorName, 70),
+				DbtrIBAN:   NormalizeIBAN(t.DebtorIBAN),
+				Ustrd:      sepaText(t.Remittance, 140),
+			}
+			tx.InstdAmt.Ccy = "EUR"
+			tx.InstdAmt.Value = t.Amount.StringFixed("EUR")
+			info.Txs = append(info.Txs, tx)
+			sum = sum.Add(t.Amount)
+		}
+		if len(info.Txs) == 0 {
+			continue
+		}
+		info.NbOfTxs = len(info.Txs)
+		info.CtrlSum = sum.StringFixed("EUR")
+		doc.Body.PmtInfs = append(doc.Body.PmtInfs, info)
+		doc.Body.GrpHdr.NbOfTxs += len(info.Txs)
+		total = total.Add(sum)
+	}
+	doc.Body.GrpHdr.CtrlSum = total.StringFixed("EUR")
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// agent names a bank by BIC, or as not provided, which SEPA allows for
+// IBAN-only payments
+func agent(bic string) pain008Agent {
+	if bic == "" {
+		return pain008Agent{Other: "NOTPROVIDED"}
+	}
+	return pain008Agent{BIC: bic}
+}
+
+// sepaText keeps the Latin characters allowed in SEPA messages and cuts the
+// text to n characters
+func sepaText(s string, n int) string {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '/', r == '-', r == '?', r == ':', r == '(', r == ')', r == '.', r == ',', r == '\'', r == '+', r == ' ':
+		default:
+			r = ' '
+		}
+		out = append(out, r)
+		if len(out) == n {
+			break
+		}
+	}
+	return string(out)
+}
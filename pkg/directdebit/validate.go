@@ -0,0 +1,55 @@
+// Package directdebit writes SEPA pain.008 and NACHA ACH debit files and
+// reads the status and return files banks send back for them.
+package directdebit
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// NormalizeIBAN removes spaces and upper-cases an IBAN
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.ReplaceAll(iban, " ", ""))
+}
+
+// ValidIBAN checks the structure and the mod-97 check digits of an IBAN
+func ValidIBAN(iban string) bool {
+	iban = NormalizeIBAN(iban)
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	for i, r := range iban {
+		upper, digit := r >= 'A' && r <= 'Z', r >= '0' && r <= '9'
+		if (i < 2 && !upper) || (i >= 2 && i < 4 && !digit) || (!upper && !digit) {
+			return false
+		}
+	}
+
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		} else {
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// ValidRoutingNumber checks an ABA routing number and its check digit
+func ValidRoutingNumber(routing string) bool {
+	if len(routing) != 9 {
+		return false
+	}
+	weights := [9]int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i, r := range routing {
+		if r < '0' || r > '9' {
+			return false
+		}
+		sum += int(r-'0') * weights[i]
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,23 @@
+package money
+
+import "strings"
+
+// DefaultCurrency is used when an invoice or payment carries no currency code
+const DefaultCurrency = "USD"
+
+// minorUnits lists ISO 4217 currencies whose minor units differ from 2
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// MinorUnits returns the number of fractional digits used by an ISO 4217
+// currency code. Unknown or empty codes default to 2.
+func MinorUnits(currency string) int {
+	if d, ok := minorUnits[strings.ToUpper(strings.TrimSpace(currency))]; ok {
+		return d
+	}
+	return 2
+}
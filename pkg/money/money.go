@@ -0,0 +1,463 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of fractional digits an Amount carries internally.
+// It is wider than any currency's minor units so intermediate results
+// (unit price × quantity, percentages) keep sub-cent precision until they
+// are explicitly rounded.
+const Scale = 4
+
+const scaleFactor int64 = 10000
+
+// Amount is an exact fixed-point decimal value with Scale fractional digits.
+// The zero value is 0.
+type Amount struct {
+	units int64
+}
+
+// RoundingMode selects how an Amount is rounded to a currency's minor units
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds ties away from zero (1.005 -> 1.01, -1.005 -> -1.01)
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds ties to the nearest even digit (banker's rounding)
+	RoundHalfEven
+	// RoundDown truncates towards zero
+	RoundDown
+	// RoundUp rounds away from zero
+	RoundUp
+)
+
+// Zero is the zero Amount
+var Zero = Amount{}
+
+// New creates an Amount from an integer number of major units
+func New(major int64) Amount {
+	return Amount{units: major * scaleFactor}
+}
+
+// FromMinor creates an Amount from an integer number of minor units of the currency
+func FromMinor(minor int64, currency string) Amount {
+	return Amount{units: minor * pow10(Scale-MinorUnits(currency))}
+}
+
+// FromFloat converts a float64 using its shortest decimal representation,
+// so FromFloat(0.1) is exactly 0.1. Digits beyond Scale are rounded half-up.
+func FromFloat(f float64) Amount {
+	a, err := Parse(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Zero
+	}
+	return a
+}
+
+// Parse parses a decimal string such as "-12.345". Digits beyond Scale are rounded half-up.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Zero, fmt.Errorf("money: empty amount")
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Zero, fmt.Errorf("money: invalid amount %q", s)
+	}
+	return fromRat(r, RoundHalfUp)
+}
+
+// MustParse is like Parse but panics on error. Intended for constants and tests.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Add returns a + b
+func (a Amount) Add(b Amount) Amount {
+	return Amount{units: a.units + b.units}
+}
+
+// Sub returns a - b
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{units: a.units - b.units}
+}
+
+// Neg returns -a
+func (a Amount) Neg() Amount {
+	return Amount{units: -a.units}
+}
+
+// Abs returns |a|
+func (a Amount) Abs() Amount {
+	if a.units < 0 {
+		return a.Neg()
+	}
+	return a
+}
+
+// Mul multiplies a by a decimal factor such as a quantity. The factor is taken
+// at its shortest decimal representation and the result is rounded half-up to Scale.
+func (a Amount) Mul(factor float64) Amount {
+	f, ok := new(big.Rat).SetString(strconv.FormatFloat(factor, 'f', -1, 64))
+	if !ok {
+		return Zero
+	}
+	res, _ := fromRat(f.Mul(f, a.rat()), RoundHalfUp)
+	return res
+}
+
+// MulAmount multiplies two Amounts, rounding the result half-up to Scale
+func (a Amount) MulAmount(b Amount) Amount {
+	res, _ := fromRat(new(big.Rat).Mul(a.rat(), b.rat()), RoundHalfUp)
+	return res
+}
+
+// Percent returns pct percent of a (pct=7.5 means 7.5%), rounded half-up to Scale
+func (a Amount) Percent(pct Amount) Amount {
+	r := new(big.Rat).Mul(a.rat(), pct.rat())
+	res, _ := fromRat(r.Quo(r, big.NewRat(100, 1)), RoundHalfUp)
+	return res
+}
+
+// Div divides a by b, rounding the result with mode to Scale. Division by zero returns Zero.
+func (a Amount) Div(b Amount, mode RoundingMode) Amount {
+	if b.IsZero() {
+		return Zero
+	}
+	res, _ := fromRat(new(big.Rat).Quo(a.rat(), b.rat()), mode)
+	return res
+}
+
+// MulDiv returns a*num/den without intermediate rounding, rounding the result
+// with mode to Scale. Division by zero returns Zero.
+func (a Amount) MulDiv(num, den Amount, mode RoundingMode) Amount {
+	if den.IsZero() {
+		return Zero
+	}
+	r := new(big.Rat).Mul(a.rat(), num.rat())
+	res, _ := fromRat(r.Quo(r, den.rat()), mode)
+	return res
+}
+
+// Round rounds a to the minor units of currency using mode
+func (a Amount) Round(currency string, mode RoundingMode) Amount {
+	return a.RoundTo(MinorUnits(currency), mode)
+}
+
+// RoundTo rounds a to the given number of fractional digits using mode
+func (a Amount) RoundTo(digits int, mode RoundingMode) Amount {
+	if digits >= Scale {
+		return a
+	}
+	if digits < 0 {
+		digits = 0
+	}
+	step := pow10(Scale - digits)
+	return Amount{units: roundDiv(a.units, step, mode) * step}
+}
+
+// Allocate splits a, already rounded to currency, across the given weights so
+// that the parts sum exactly to a. Remainders go to the largest weights first.
+func (a Amount) Allocate(currency string, weights []Amount) []Amount {
+	parts := make([]Amount, len(weights))
+	if len(weights) == 0 {
+		return parts
+	}
+	var total Amount
+	for _, w := range weights {
+		total = total.Add(w.Abs())
+	}
+	if total.IsZero() {
+		parts[0] = a
+		return parts
+	}
+
+	step := pow10(Scale - MinorUnits(currency))
+	var allocated Amount
+	for i, w := range weights {
+		share := new(big.Rat).Mul(a.rat(), w.Abs().rat())
+		share.Quo(share, total.rat())
+		part, _ := fromRat(share, RoundDown)
+		parts[i] = part.RoundTo(MinorUnits(currency), RoundDown)
+		allocated = allocated.Add(parts[i])
+	}
+
+	remainder := a.Sub(allocated)
+	unit := Amount{units: step}
+	if remainder.IsNegative() {
+		unit = unit.Neg()
+	}
+	for i := 0; !remainder.IsZero() && i < len(parts)*2; i++ {
+		idx := largestIndex(weights, i%len(weights))
+		parts[idx] = parts[idx].Add(unit)
+		remainder = remainder.Sub(unit)
+	}
+	return parts
+}
+
+// Cmp returns -1, 0 or +1 depending on whether a is less than, equal to or greater than b
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a.units < b.units:
+		return -1
+	case a.units > b.units:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Equal reports whether a == b
+func (a Amount) Equal(b Amount) bool { return a.units == b.units }
+
+// LessThan reports whether a < b
+func (a Amount) LessThan(b Amount) bool { return a.units < b.units }
+
+// GreaterThan reports whether a > b
+func (a Amount) GreaterThan(b Amount) bool { return a.units > b.units }
+
+// IsZero reports whether a == 0
+func (a Amount) IsZero() bool { return a.units == 0 }
+
+// IsNegative reports whether a < 0
+func (a Amount) IsNegative() bool { return a.units < 0 }
+
+// IsPositive reports whether a > 0
+func (a Amount) IsPositive() bool { return a.units > 0 }
+
+// Min returns the smaller of a and b
+func Min(a, b Amount) Amount {
+	if a.units < b.units {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of a and b
+func Max(a, b Amount) Amount {
+	if a.units > b.units {
+		return a
+	}
+	return b
+}
+
+// Sum adds up all amounts
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, a := range amounts {
+		total = total.Add(a)
+	}
+	return total
+}
+
+// MinorUnitsOf returns a expressed as an integer number of the currency's
+// minor units, rounding with mode if a carries more precision.
+func (a Amount) MinorUnitsOf(currency string, mode RoundingMode) int64 {
+	return roundDiv(a.units, pow10(Scale-MinorUnits(currency)), mode)
+}
+
+// Float64 returns the nearest float64. Use only at boundaries that require a
+// float (e.g. event payloads owned by other services), never for arithmetic.
+func (a Amount) Float64() float64 {
+	f, _ := strconv.ParseFloat(a.String(), 64)
+	return f
+}
+
+// String formats a with at least two fractional digits, e.g. "12.50" or "0.125"
+func (a Amount) String() string {
+	units := a.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	whole := units / scaleFactor
+	frac := fmt.Sprintf("%0*d", Scale, units%scaleFactor)
+	frac = strings.TrimRight(frac, "0")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	return fmt.Sprintf("%s%d.%s", sign, whole, frac)
+}
+
+// StringFixed formats a rounded half-up to exactly the currency's minor units
+func (a Amount) StringFixed(currency string) string {
+	digits := MinorUnits(currency)
+	r := a.RoundTo(digits, RoundHalfUp)
+	units := r.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	whole := units / scaleFactor
+	if digits == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	frac := fmt.Sprintf("%0*d", Scale, units%scaleFactor)[:digits]
+	return fmt.Sprintf("%s%d.%s", sign, whole, frac)
+}
+
+// MarshalJSON encodes a as a JSON number without going through float64
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number, a numeric string or null
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		*a = Zero
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		s = str
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value implements driver.Valuer so GORM writes decimals as exact strings
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan implements sql.Scanner for numeric/decimal columns
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = Zero
+		return nil
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case int64:
+		*a = New(v)
+		return nil
+	case float64:
+		*a = FromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) rat() *big.Rat {
+	return big.NewRat(a.units, scaleFactor)
+}
+
+func fromRat(r *big.Rat, mode RoundingMode) (Amount, error) {
+	scaled := new(big.Rat).Mul(r, big.NewRat(scaleFactor, 1))
+	num := new(big.Int).Set(scaled.Num())
+	den := scaled.Denom()
+
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		twice := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+		cmpHalf := twice.Cmp(den)
+		if shouldRoundAway(mode, cmpHalf, q.Bit(0) == 1) {
+			if num.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+	if !q.IsInt64() {
+		return Zero, fmt.Errorf("money: amount out of range")
+	}
+	return Amount{units: q.Int64()}, nil
+}
+
+// roundDiv divides n by d (d > 0) rounding according to mode
+func roundDiv(n, d int64, mode RoundingMode) int64 {
+	q := n / d
+	rem := n % d
+	if rem == 0 {
+		return q
+	}
+	if rem < 0 {
+		rem = -rem
+	}
+	cmpHalf := 0
+	switch {
+	case rem*2 < d:
+		cmpHalf = -1
+	case rem*2 > d:
+		cmpHalf = 1
+	}
+	if shouldRoundAway(mode, cmpHalf, q%2 != 0) {
+		if n < 0 {
+			return q - 1
+		}
+		return q + 1
+	}
+	return q
+}
+
+// shouldRoundAway decides whether a truncated quotient must move away from
+// zero. cmpHalf compares the discarded remainder with one half.
+func shouldRoundAway(mode RoundingMode, cmpHalf int, odd bool) bool {
+	switch mode {
+	case RoundDown:
+		return false
+	case RoundUp:
+		return true
+	case RoundHalfEven:
+		return cmpHalf > 0 || (cmpHalf == 0 && odd)
+	default:
+		return cmpHalf >= 0
+	}
+}
+
+func largestIndex(weights []Amount, rank int) int {
+	idx := make([]int, len(weights))
+	for i := range idx {
+		idx[i] = i
+	}
+	// Stable selection sort by descending weight; weights lists are short
+	for i := 0; i < len(idx); i++ {
+		for j := i + 1; j < len(idx); j++ {
+			if weights[idx[j]].Abs().GreaterThan(weights[idx[i]].Abs()) {
+				idx[i], idx[j] = idx[j], idx[i]
+			}
+		}
+	}
+	return idx[rank]
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,175 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/bankstatement"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+const camt053Sample = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Id>STMT-2026-05-04</Id>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">250.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-04</Dt></BookgDt>
+        <ValDt><Dt>2026-05-04</Dt></ValDt>
+        <AcctSvcrRef>BANK-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <Refs><EndToEndId>E2E-77</EndToEndId></Refs>
+          <RltdPties><Dbtr><Nm>Acme Corp</Nm></Dbtr><DbtrAcct><Id><IBAN>FR1420041010050500013M02606</IBAN></Id></DbtrAcct></RltdPties>
+          <RmtInf><Ustrd>Payment INV-2026-0001</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">19.99</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><Dt>2026-05-05</Dt></BookgDt>
+        <AcctSvcrRef>BANK-2</AcctSvcrRef>
+        <AddtlNtryInf>Bank fees</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+const mt940Sample = `:20:STARTUMS
+:25:10020030/1234567
+:28C:00001/001
+:60F:C260503EUR1000,00
+:61:2605040504CR250,00NTRFNONREF//BANK-1
+:86:166?00GUTSCHRIFT?20INV-2026-0001?21THANK YOU?3112345678?32ACME?33CORP
+:61:260505DR19,99NCHGFEES
+:86:Bank fees
+:62F:C260505EUR1230,01
+-`
+
+const ofxSample = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKACCTFROM><BANKID>121000248<ACCTID>987654321<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20260501<DTEND>20260505
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20260504120000[-5:EST]
+<TRNAMT>250.00
+<FITID>BANK-1
+<NAME>ACME CORP
+<MEMO>INV-2026-0001
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20260505
+<TRNAMT>-19.99
+<FITID>BANK-2
+<NAME>Bank fees
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+// TestParseBankStatements tests that every format reads into the same transactions
+func TestParseBankStatements(t *testing.T) {
+	tests := []struct {
+		format   bankstatement.Format
+		data     string
+		currency string
+	}{
+		{format: bankstatement.FormatCAMT053, data: camt053Sample, currency: "EUR"},
+		{format: bankstatement.FormatMT940, data: mt940Sample, currency: "EUR"},
+		{format: bankstatement.FormatOFX, data: ofxSample, currency: "USD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			detected, err := bankstatement.Detect([]byte(tt.data))
+			if err != nil || detected != tt.format {
+				t.Fatalf("Expected %s to be detected, got %s (%v)", tt.format, detected, err)
+			}
+			st, err := bankstatement.Parse(tt.format, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(st.Transactions) != 2 {
+				t.Fatalf("Expected 2 transactions, got %d", len(st.Transactions))
+			}
+
+			received, fee := st.Transactions[0], st.Transactions[1]
+			if !received.Amount.Equal(money.MustParse("250.00")) || !fee.Amount.Equal(money.MustParse("-19.99")) {
+				t.Errorf("Expected 250.00 received and 19.99 paid, got %s and %s", received.Amount, fee.Amount)
+			}
+			if received.Currency != tt.currency || !received.BookingDate.Equal(time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("Expected %s booked on 2026-05-04, got %s on %s", tt.currency, received.Currency, received.BookingDate)
+			}
+			if received.BankRef != "BANK-1" {
+				t.Errorf("Expected bank reference BANK-1, got %q", received.BankRef)
+			}
+			if received.CounterpartyName == "" {
+				t.Errorf("Expected the payer's name, got none")
+			}
+		})
+	}
+}
+
+// TestMatchStatementLine tests matching received money to open invoices
+func TestMatchStatementLine(t *testing.T) {
+	acme, other := uuid.New(), uuid.New()
+	first := &domain.Invoice{ID: uuid.New(), CustomerID: acme, InvoiceNumber: "INV-2026-0001", Currency: "EUR", DueDate: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), TotalAmount: money.MustParse("250.00")}
+	tenth := &domain.Invoice{ID: uuid.New(), CustomerID: other, InvoiceNumber: "INV-2026-00010", ReferenceNo: "PO-4411", Currency: "EUR", DueDate: time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC), TotalAmount: money.MustParse("90.00")}
+	invoices := []*domain.Invoice{first, tenth}
+	names := map[uuid.UUID][]string{acme: {"Acme", "Acme Corp"}, other: {"Globex"}}
+
+	line := func(amount, reference, payer string) *domain.BankStatementLine {
+		return &domain.BankStatementLine{Amount: money.MustParse(amount), Currency: "EUR", Description: reference, CounterpartyName: payer}
+	}
+
+	m := domain.MatchStatementLine(line("250.00", "Payment INV-2026-0001", "ACME CORP"), invoices, names)
+	if m.InvoiceID == nil || *m.InvoiceID != first.ID || !m.AutoApply() {
+		t.Errorf("Expected INV-2026-0001 to be auto-applied, got %+v", m)
+	}
+
+	// The number of the tenth invoice starts with the first one's
+	m = domain.MatchStatementLine(line("40.00", "inv 2026 00010", ""), invoices, names)
+	if m.InvoiceID == nil || *m.InvoiceID != tenth.ID || m.AutoApply() {
+		t.Errorf("Expected a suggestion of INV-2026-00010 only, got %+v", m)
+	}
+
+	m = domain.MatchStatementLine(line("90.00", "order PO 4411", ""), invoices, names)
+	if m.InvoiceID == nil || *m.InvoiceID != tenth.ID || m.Score != 70 {
+		t.Errorf("Expected the reference and amount to point to INV-2026-00010, got %+v", m)
+	}
+
+	m = domain.MatchStatementLine(line("12.34", "thanks", "Acme"), invoices, names)
+	if m.InvoiceID != nil || m.CustomerID == nil || *m.CustomerID != acme {
+		t.Errorf("Expected only the customer to be recognised, got %+v", m)
+	}
+
+	m = domain.MatchStatementLine(line("-250.00", "INV-2026-0001", "Acme"), invoices, names)
+	if m.InvoiceID != nil || m.Score != 0 {
+		t.Errorf("Expected money paid out not to match, got %+v", m)
+	}
+
+	var l domain.BankStatementLine
+	l.Apply(domain.StatementMatch{InvoiceID: &first.ID, Score: 50})
+	if l.Status != domain.StatementLineSuggested {
+		t.Errorf("Expected a suggested line, got %s", l.Status)
+	}
+	if err := l.Decide(domain.DecisionIgnored, nil, "duplicate", "tester", time.Now()); err != nil || l.Status != domain.StatementLineIgnored {
+		t.Errorf("Expected the line to be ignored, got %s (%v)", l.Status, err)
+	}
+	if err := l.Decide(domain.DecisionConfirmed, nil, "", "tester", time.Now()); err == nil {
+		t.Errorf("Expected a decided line to be refused")
+	}
+}
@@ -0,0 +1,231 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// issuedInvoice is a sent invoice with a single line of 3 x 10.00 plus 3.00 tax
+func issuedInvoice(lineID uuid.UUID) *domain.Invoice {
+	locked := time.Now()
+	return &domain.Invoice{
+		ID:            uuid.New(),
+		InvoiceNumber: "INV-0001",
+		Status:        domain.InvoiceStatusSent,
+		LockedAt:      &locked,
+		TotalAmount:   money.MustParse("33.00"),
+		BalanceAmount: money.MustParse("33.00"),
+		Items: []domain.InvoiceItem{{
+			ID:        lineID,
+			Name:      "Widget",
+			Quantity:  3,
+			UnitPrice: money.MustParse("10.00"),
+			Tax:       money.MustParse("3.00"),
+			Total:     money.MustParse("33.00"),
+		}},
+	}
+}
+
+// TestInvoice_DraftNote tests how note lines are built from the source invoice
+func TestInvoice_DraftNote(t *testing.T) {
+	lineID := uuid.New()
+	earlierCredit := domain.BillingNote{
+		NoteType: domain.BillingNoteCredit,
+		Items: []domain.BillingNoteItem{{
+			InvoiceItemID: &lineID,
+			Quantity:      2,
+			Tax:           money.MustParse("2.00"),
+			Total:         money.MustParse("22.00"),
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		req       domain.NoteRequest
+		earlier   []domain.BillingNote
+		wantTotal string
+		wantTax   string
+		wantErr   bool
+	}{
+		{
+			name:      "partial credit is prorated from the source line",
+			req:       domain.NoteRequest{Type: domain.BillingNoteCredit, Lines: []domain.NoteLine{{InvoiceItemID: &lineID, Quantity: 1}}},
+			wantTotal: "11.00",
+			wantTax:   "1.00",
+		},
+		{
+			name:      "last part of a line takes exactly what is left",
+			req:       domain.NoteRequest{Type: domain.BillingNoteCredit, Lines: []domain.NoteLine{{InvoiceItemID: &lineID}}},
+			earlier:   []domain.BillingNote{earlierCredit},
+			wantTotal: "11.00",
+			wantTax:   "1.00",
+		},
+		{
+			name: "last part of a line after fractional credits takes what is left",
+			req:  domain.NoteRequest{Type: domain.BillingNoteCredit, Lines: []domain.NoteLine{{InvoiceItemID: &lineID, Quantity: 1.8}}},
+			earlier: []domain.BillingNote{
+				{NoteType: domain.BillingNoteCredit, Items: []domain.BillingNoteItem{{InvoiceItemID: &lineID, Quantity: 0.1, Tax: money.MustParse("0.10"), Total: money.MustParse("1.10")}}},
+				{NoteType: domain.BillingNoteCredit, Items: []domain.BillingNoteItem{{InvoiceItemID: &lineID, Quantity: 0.2, Tax: money.MustParse("0.20"), Total: money.MustParse("2.20")}}},
+				{NoteType: domain.BillingNoteCredit, Items: []domain.BillingNoteItem{{InvoiceItemID: &lineID, Quantity: 0.9, Tax: money.MustParse("0.90"), Total: money.MustParse("9.90")}}},
+			},
+			wantTotal: "19.80",
+			wantTax:   "1.80",
+		},
+		{
+			name:    "cannot credit more than remains on the line",
+			req:     domain.NoteRequest{Type: domain.BillingNoteCredit, Lines: []domain.NoteLine{{InvoiceItemID: &lineID, Quantity: 2}}},
+			earlier: []domain.BillingNote{earlierCredit},
+			wantErr: true,
+		},
+		{
+			name:    "credit lines must reference an invoice line",
+			req:     domain.NoteRequest{Type: domain.BillingNoteCredit, Lines: []domain.NoteLine{{Name: "Goodwill", Quantity: 1, UnitPrice: money.MustParse("5.00")}}},
+			wantErr: true,
+		},
+		{
+			name:      "debit note can add an extra charge",
+			req:       domain.NoteRequest{Type: domain.BillingNoteDebit, Lines: []domain.NoteLine{{Name: "Freight", Quantity: 2, UnitPrice: money.MustParse("7.50")}}},
+			wantTotal: "15.00",
+			wantTax:   "0.00",
+		},
+		{
+			name:      "full reversal credits whatever is left",
+			req:       domain.NoteRequest{Type: domain.BillingNoteCredit, Full: true},
+			earlier:   []domain.BillingNote{earlierCredit},
+			wantTotal: "11.00",
+			wantTax:   "1.00",
+		},
+		{
+			name:    "empty note is rejected",
+			req:     domain.NoteRequest{Type: domain.BillingNoteDebit},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := issuedInvoice(lineID)
+			note, err := inv.DraftNote(tt.req, tt.earlier)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidInput) {
+					t.Errorf("Expected ErrInvalidInput, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !note.TotalAmount.Equal(money.MustParse(tt.wantTotal)) {
+				t.Errorf("Expected total %s, got %s", tt.wantTotal, note.TotalAmount)
+			}
+			if !note.TaxTotal.Equal(money.MustParse(tt.wantTax)) {
+				t.Errorf("Expected tax %s, got %s", tt.wantTax, note.TaxTotal)
+			}
+			if !inv.BalanceAmount.Equal(money.MustParse("33.00")) {
+				t.Errorf("Drafting a note must not touch the balance, got %s", inv.BalanceAmount)
+			}
+		})
+	}
+}
+
+// TestInvoice_DraftNote_Draft tests that notes need an issued invoice
+func TestInvoice_DraftNote_Draft(t *testing.T) {
+	inv := issuedInvoice(uuid.New())
+	inv.LockedAt = nil
+	inv.Status = domain.InvoiceStatusDraft
+
+	_, err := inv.DraftNote(domain.NoteRequest{Type: domain.BillingNoteCredit, Full: true}, nil)
+	if !errors.Is(err, domain.ErrInvoiceNotIssued) {
+		t.Errorf("Expected ErrInvoiceNotIssued, got %v", err)
+	}
+}
+
+// TestInvoice_ApplyNote tests how notes move the invoice balance, including
+// credit beyond what is still owed
+func TestInvoice_ApplyNote(t *testing.T) {
+	tests := []struct {
+		name         string
+		paid         string
+		noteType     domain.BillingNoteType
+		noteTotal    string
+		wantBalance  string
+		wantToCredit string
+		wantStatus   domain.InvoiceStatus
+		wantErr      bool
+	}{
+		{name: "credit reduces an unpaid balance", paid: "0", noteType: domain.BillingNoteCredit, noteTotal: "10.00", wantBalance: "23.00", wantToCredit: "0", wantStatus: domain.InvoiceStatusSent},
+		{name: "credit settling the balance marks the invoice paid", paid: "0", noteType: domain.BillingNoteCredit, noteTotal: "33.00", wantBalance: "0", wantToCredit: "0", wantStatus: domain.InvoiceStatusPaid},
+		{name: "credit on a paid invoice goes to customer credit", paid: "33.00", noteType: domain.BillingNoteCredit, noteTotal: "10.00", wantBalance: "0", wantToCredit: "10.00", wantStatus: domain.InvoiceStatusPaid},
+		{name: "credit beyond the balance keeps only the excess", paid: "30.00", noteType: domain.BillingNoteCredit, noteTotal: "10.00", wantBalance: "0", wantToCredit: "7.00", wantStatus: domain.InvoiceStatusPaid},
+		{name: "credit beyond the invoice total is rejected", paid: "0", noteType: domain.BillingNoteCredit, noteTotal: "40.00", wantErr: true},
+		{name: "debit adds to the balance", paid: "10.00", noteType: domain.BillingNoteDebit, noteTotal: "5.00", wantBalance: "28.00", wantToCredit: "0", wantStatus: domain.InvoiceStatusPartial},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := issuedInvoice(uuid.New())
+			inv.PaidAmount = money.MustParse(tt.paid)
+			inv.RecalculateBalance()
+			inv.Status = inv.SettlementStatus()
+
+			note := &domain.BillingNote{NoteType: tt.noteType, TotalAmount: money.MustParse(tt.noteTotal), IssueDate: time.Now()}
+			err := inv.ApplyNote(note)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidInput) {
+					t.Errorf("Expected ErrInvalidInput, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !inv.BalanceAmount.Equal(money.MustParse(tt.wantBalance)) {
+				t.Errorf("Expected balance %s, got %s", tt.wantBalance, inv.BalanceAmount)
+			}
+			if !note.ToCredit.Equal(money.MustParse(tt.wantToCredit)) {
+				t.Errorf("Expected %s to customer credit, got %s", tt.wantToCredit, note.ToCredit)
+			}
+			if inv.Status != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, inv.Status)
+			}
+		})
+	}
+}
+
+// TestInvoice_ApplyNote_DebitAfterExcessCredit tests that credit already moved
+// to the customer is not owed back when the invoice is debited later
+func TestInvoice_ApplyNote_DebitAfterExcessCredit(t *testing.T) {
+	inv := issuedInvoice(uuid.New())
+	inv.PaidAmount = money.MustParse("33.00")
+	inv.RecalculateBalance()
+	inv.Status = domain.InvoiceStatusPaid
+
+	credit := &domain.BillingNote{NoteType: domain.BillingNoteCredit, TotalAmount: money.MustParse("10.00"), IssueDate: time.Now()}
+	if err := inv.ApplyNote(credit); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry := domain.CreditEntryForNote(credit)
+	if entry == nil || !entry.Amount.Equal(money.MustParse("10.00")) {
+		t.Fatalf("Expected a 10.00 customer credit entry, got %+v", entry)
+	}
+
+	debit := &domain.BillingNote{NoteType: domain.BillingNoteDebit, TotalAmount: money.MustParse("4.00"), IssueDate: time.Now()}
+	if err := inv.ApplyNote(debit); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !inv.BalanceAmount.Equal(money.MustParse("4.00")) {
+		t.Errorf("Expected balance 4.00, got %s", inv.BalanceAmount)
+	}
+	if inv.Status != domain.InvoiceStatusPartial {
+		t.Errorf("Expected status partial, got %s", inv.Status)
+	}
+	if domain.CreditEntryForNote(debit) != nil {
+		t.Error("Debit notes must not post customer credit")
+	}
+}
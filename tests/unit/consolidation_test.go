@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestConsolidationPeriod(t *testing.T) {
+	start, end, err := domain.ConsolidationPeriod(time.Date(2026, 9, 1, 15, 30, 0, 0, time.UTC), day(9, 30))
+	if err != nil || !start.Equal(day(9, 1)) || !end.Equal(day(9, 30)) {
+		t.Errorf("ConsolidationPeriod() = %s, %s, %v, want midnight of September 1 and 30", start, end, err)
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"no start", time.Time{}, day(9, 30)},
+		{"ends before it starts", day(9, 30), day(9, 1)},
+		{"longer than a year", day(1, 1), time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if _, _, err := domain.ConsolidationPeriod(tt.start, tt.end); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+}
+
+func TestPreviousMonth(t *testing.T) {
+	start, end := domain.PreviousMonth(time.Date(2026, 3, 15, 10, 0, 0, 0, time.UTC))
+	if !start.Equal(day(2, 1)) || !end.Equal(day(2, 28)) {
+		t.Errorf("PreviousMonth(March 15) = %s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	start, end = domain.PreviousMonth(day(1, 1))
+	if !start.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("PreviousMonth(January 1) = %s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+}
+
+func TestParseConsolidationSource(t *testing.T) {
+	if src, err := domain.ParseConsolidationSource(""); err != nil || src != domain.ConsolidateAll {
+		t.Errorf("ParseConsolidationSource(\"\") = %q, %v, want all", src, err)
+	}
+	if src, err := domain.ParseConsolidationSource("draft_invoices"); err != nil || src != domain.ConsolidateDrafts {
+		t.Errorf("ParseConsolidationSource(draft_invoices) = %q, %v", src, err)
+	}
+	if _, err := domain.ParseConsolidationSource("estimates"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("unknown source error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestConsolidationGroupLabels(t *testing.T) {
+	woID := uuid.MustParse("1a2b3c4d-0000-0000-0000-000000000000")
+	wo := &domain.WorkOrderRM{ID: woID, Summary: "Boiler service"}
+	if got := domain.WorkOrderGroupLabel(wo); got != "Work order 1a2b3c4d: Boiler service" {
+		t.Errorf("WorkOrderGroupLabel() = %q", got)
+	}
+
+	draft := &domain.Invoice{InvoiceNumber: "INV-7"}
+	if got := domain.DraftGroupLabel(draft); got != "Invoice INV-7" {
+		t.Errorf("DraftGroupLabel() = %q", got)
+	}
+	draft.WorkOrderID = &woID
+	draft.Subject = "Call-out"
+	if got := domain.DraftGroupLabel(draft); got != "Work order 1a2b3c4d, invoice INV-7: Call-out" {
+		t.Errorf("DraftGroupLabel() of a work order invoice = %q", got)
+	}
+
+	wo.Summary = strings.Repeat("x", 300)
+	if got := domain.WorkOrderGroupLabel(wo); len([]rune(got)) != 255 {
+		t.Errorf("long label has %d characters, want it cut to 255", len([]rune(got)))
+	}
+}
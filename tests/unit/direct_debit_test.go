@@ -0,0 +1,165 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/directdebit"
+	"erp-billing-service/pkg/money"
+)
+
+func TestDirectDebitAccountValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+		check func() bool
+	}{
+		{"IBAN", true, func() bool { return directdebit.ValidIBAN(directdebit.NormalizeIBAN("de89 3704 0044 0532 0130 00")) }},
+		{"IBAN with wrong check digits", false, func() bool { return directdebit.ValidIBAN("DE88370400440532013000") }},
+		{"IBAN with non-ASCII letters", false, func() bool { return directdebit.ValidIBAN("DE89370400440532013ÄÄ0") }},
+		{"routing number", true, func() bool { return directdebit.ValidRoutingNumber("021000021") }},
+		{"routing number with wrong checksum", false, func() bool { return directdebit.ValidRoutingNumber("021000022") }},
+		{"short routing number", false, func() bool { return directdebit.ValidRoutingNumber("02100002") }},
+	}
+	for _, tt := range tests {
+		if got := tt.check(); got != tt.valid {
+			t.Errorf("%s: valid = %v, want %v", tt.name, got, tt.valid)
+		}
+	}
+
+	now := time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)
+	mandate := &domain.DirectDebitMandate{Scheme: domain.SchemeACH, Reference: "M-1", DebtorName: "Acme", SignedAt: now.AddDate(0, -1, 0),
+		RoutingNumber: "021000021", AccountNumber: "12345678"}
+	if err := mandate.Validate(now); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if mandate.AccountType != directdebit.AccountChecking {
+		t.Errorf("AccountType = %q, want checking by default", mandate.AccountType)
+	}
+	if mandate.SequenceType() != directdebit.SequenceFirst {
+		t.Errorf("SequenceType() = %q before the first collection, want FRST", mandate.SequenceType())
+	}
+	mandate.FirstCollected = &now
+	if mandate.SequenceType() != directdebit.SequenceRecurring {
+		t.Errorf("SequenceType() = %q after the first collection, want RCUR", mandate.SequenceType())
+	}
+	mandate.SignedAt = now.AddDate(0, 0, 1)
+	if err := mandate.Validate(now); err == nil {
+		t.Error("Validate() accepted a mandate signed in the future")
+	}
+}
+
+func TestGenerateDirectDebitFiles(t *testing.T) {
+	created := time.Date(2026, 5, 4, 9, 30, 0, 0, time.UTC)
+	collection := time.Date(2026, 5, 6, 0, 0, 0, 0, time.UTC)
+
+	t.Run("pain.008", func(t *testing.T) {
+		file, err := directdebit.GeneratePain008(directdebit.SEPABatch{
+			MessageID:      "DD-SEPA-1",
+			CreatedAt:      created,
+			CollectionDate: collection,
+			Creditor:       directdebit.SEPACreditor{Name: "Webnox GmbH", CreditorID: "DE98ZZZ09999999999", IBAN: "DE89370400440532013000"},
+			Transactions: []directdebit.SEPATransaction{
+				{EndToEndID: "E2E-1", Amount: money.MustParse("100.50"), MandateID: "M-1", MandateSignedAt: created, SequenceType: directdebit.SequenceFirst,
+					DebtorName: "Acme", DebtorIBAN: "GB82WEST12345698765432", Remittance: "Invoice INV-1"},
+				{EndToEndID: "E2E-2", Amount: money.MustParse("20"), MandateID: "M-2", MandateSignedAt: created, SequenceType: directdebit.SequenceRecurring,
+					DebtorName: "Globex", DebtorIBAN: "DE89370400440532013000", Remittance: "Invoice INV-2"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("GeneratePain008() error = %v", err)
+		}
+		xml := string(file)
+		for _, want := range []string{"urn:iso:std:iso:20022:tech:xsd:pain.008.001.02", "<NbOfTxs>2</NbOfTxs>", "<CtrlSum>120.50</CtrlSum>",
+			"<SeqTp>FRST</SeqTp>", "<SeqTp>RCUR</SeqTp>", "<ReqdColltnDt>2026-05-06</ReqdColltnDt>", `<InstdAmt Ccy="EUR">100.50</InstdAmt>`, "<Id>NOTPROVIDED</Id>"} {
+			if !strings.Contains(xml, want) {
+				t.Errorf("pain.008 file is missing %s", want)
+			}
+		}
+	})
+
+	t.Run("NACHA", func(t *testing.T) {
+		file, err := directdebit.GenerateNACHA(directdebit.ACHBatch{
+			BatchNumber:      7,
+			CreatedAt:        created,
+			EffectiveDate:    collection,
+			EntryDescription: "INVOICE",
+			Originator:       directdebit.ACHOriginator{CompanyName: "Webnox Inc", CompanyID: "1234567890", ODFIRouting: "021000021", ImmediateDestination: "021000021"},
+			Entries: []directdebit.ACHEntry{
+				{TraceNumber: directdebit.TraceNumber("021000021", 1), RoutingNumber: "011000015", AccountNumber: "12345678", Amount: money.MustParse("100.50"), IndividualID: "INV-1", IndividualName: "Acme"},
+				{TraceNumber: directdebit.TraceNumber("021000021", 2), RoutingNumber: "021000021", AccountNumber: "99", AccountType: directdebit.AccountSavings, Amount: money.MustParse("20"), IndividualID: "INV-2", IndividualName: "Globex"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("GenerateNACHA() error = %v", err)
+		}
+		records := strings.Split(strings.TrimSuffix(string(file), "\n"), "\n")
+		if len(records)%10 != 0 {
+			t.Errorf("file has %d records, want a multiple of 10", len(records))
+		}
+		for i, r := range records {
+			if len(r) != 94 {
+				t.Errorf("record %d is %d characters long", i+1, len(r))
+			}
+		}
+		if got := records[2][1:3]; got != "27" {
+			t.Errorf("checking debit transaction code = %s, want 27", got)
+		}
+		if got := records[3][1:3]; got != "37" {
+			t.Errorf("savings debit transaction code = %s, want 37", got)
+		}
+		if got := records[2][79:94]; got != "021000020000001" {
+			t.Errorf("trace number = %s, want 021000020000001", got)
+		}
+		// Entry hash is the sum of the receiving banks' eight digit routing prefixes
+		control := records[4]
+		if got := control[10:20]; got != "0003200003" {
+			t.Errorf("entry hash = %s, want 0003200003", got)
+		}
+		if got := control[20:32]; got != "000000012050" {
+			t.Errorf("total debits = %s, want 000000012050", got)
+		}
+	})
+}
+
+func TestParseDirectDebitReturns(t *testing.T) {
+	pain002 := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.03">
+  <CstmrPmtStsRpt>
+    <OrgnlPmtInfAndSts>
+      <TxInfAndSts><OrgnlEndToEndId>E2E-1</OrgnlEndToEndId><TxSts>ACSC</TxSts></TxInfAndSts>
+      <TxInfAndSts><OrgnlEndToEndId>E2E-2</OrgnlEndToEndId><TxSts>RJCT</TxSts><StsRsnInf><Rsn><Cd>AC04</Cd></Rsn></StsRsnInf></TxInfAndSts>
+      <TxInfAndSts><OrgnlEndToEndId>E2E-3</OrgnlEndToEndId><TxSts>PDNG</TxSts></TxInfAndSts>
+    </OrgnlPmtInfAndSts>
+  </CstmrPmtStsRpt>
+</Document>`
+	items, err := directdebit.ParseReturns([]byte(pain002))
+	if err != nil {
+		t.Fatalf("ParseReturns(pain.002) error = %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2 (pending ones are skipped)", len(items))
+	}
+	if !items[0].Accepted || items[0].EndToEndID != "E2E-1" {
+		t.Errorf("items[0] = %+v, want E2E-1 accepted", items[0])
+	}
+	if items[1].Accepted || items[1].ReasonCode != "AC04" {
+		t.Errorf("items[1] = %+v, want E2E-2 rejected with AC04", items[1])
+	}
+
+	addenda := "799R01021000020000001      02100002" + strings.Repeat(" ", 58)
+	nacha := "101 021000021 1234567890" + strings.Repeat(" ", 70) + "\n" + addenda + "\n"
+	items, err = directdebit.ParseReturns([]byte(nacha))
+	if err != nil {
+		t.Fatalf("ParseReturns(NACHA) error = %v", err)
+	}
+	if len(items) != 1 || items[0].TraceNumber != "021000020000001" || items[0].ReasonCode != "R01" || items[0].Accepted {
+		t.Errorf("items = %+v, want one R01 return of trace 021000020000001", items)
+	}
+
+	if _, err := directdebit.ParseReturns([]byte("not a return file")); err == nil {
+		t.Error("ParseReturns() accepted an unknown format")
+	}
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+)
+
+// TestResolveDiscount tests fixed and percentage discounts and their limits
+func TestResolveDiscount(t *testing.T) {
+	round := func(a money.Amount) money.Amount { return a.Round("USD", domain.MoneyRounding) }
+
+	tests := []struct {
+		name    string
+		base    string
+		fixed   string
+		percent string
+		want    string
+		wantErr bool
+	}{
+		{name: "no discount", base: "100.00", fixed: "0", percent: "0", want: "0"},
+		{name: "fixed amount", base: "100.00", fixed: "12.50", percent: "0", want: "12.50"},
+		{name: "percentage is rounded", base: "33.33", fixed: "0", percent: "15", want: "5.00"},
+		{name: "both kinds rejected", base: "100.00", fixed: "5", percent: "5", wantErr: true},
+		{name: "more than the amount rejected", base: "10.00", fixed: "10.01", percent: "0", wantErr: true},
+		{name: "over 100 percent rejected", base: "10.00", fixed: "0", percent: "101", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := domain.ResolveDiscount(money.MustParse(tt.base), money.MustParse(tt.fixed), money.MustParse(tt.percent), round)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidInput) {
+					t.Errorf("Expected ErrInvalidInput, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !got.Equal(money.MustParse(tt.want)) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestDocumentDiscountAllocation tests that an invoice discount spreads exactly over its lines
+func TestDocumentDiscountAllocation(t *testing.T) {
+	discount := money.MustParse("10.00")
+	weights := []money.Amount{money.MustParse("33.33"), money.MustParse("33.33"), money.MustParse("33.34")}
+
+	shares := discount.Allocate("USD", weights)
+	if !money.Sum(shares...).Equal(discount) {
+		t.Errorf("Shares %v do not add up to %s", shares, discount)
+	}
+	for i, share := range shares {
+		if share.LessThan(money.MustParse("3.33")) || share.GreaterThan(money.MustParse("3.34")) {
+			t.Errorf("Share %d is %s, expected about a third", i, share)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+)
+
+func TestDaysFromDueUsesOrganizationTimeZone(t *testing.T) {
+	inv := &domain.Invoice{DueDate: time.Date(2026, 5, 4, 0, 0, 0, 0, time.UTC)}
+	// 23:30 UTC on the due date is already the next day in Berlin, but not in New York
+	now := time.Date(2026, 5, 4, 23, 30, 0, 0, time.UTC)
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	newYork, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want int
+	}{
+		{"UTC", time.UTC, 0},
+		{"Europe/Berlin", berlin, 1},
+		{"America/New_York", newYork, 0},
+	}
+	for _, tt := range tests {
+		if got := domain.DaysFromDue(inv, now, tt.loc); got != tt.want {
+			t.Errorf("%s: DaysFromDue() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := domain.DaysFromDue(inv, now.AddDate(0, 0, -5), time.UTC); got != -5 {
+		t.Errorf("DaysFromDue() before the due date = %d, want -5", got)
+	}
+}
+
+func TestDunningPolicy(t *testing.T) {
+	policy := &domain.DunningPolicy{TimeZone: "Europe/Berlin", Steps: domain.DunningSteps{
+		{DaysFromDue: 30, Level: 3, Name: "Final notice"},
+		{DaysFromDue: -3, Level: 0, Name: "Upcoming payment"},
+		{DaysFromDue: 7, Level: 2, Name: "First reminder"},
+		{DaysFromDue: 0, Level: 1, Name: "Payment due"},
+	}}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if policy.Steps[0].DaysFromDue != -3 || policy.Steps[3].DaysFromDue != 30 {
+		t.Errorf("steps are not sorted by day: %+v", policy.Steps)
+	}
+	if policy.Lead() != 3 {
+		t.Errorf("Lead() = %d, want 3", policy.Lead())
+	}
+
+	tests := []struct {
+		days int
+		want string
+	}{
+		{-5, ""},
+		{-3, "Upcoming payment"},
+		{-1, "Upcoming payment"},
+		{0, "Payment due"},
+		{12, "First reminder"},
+		{45, "Final notice"},
+	}
+	for _, tt := range tests {
+		step := policy.StepFor(tt.days)
+		got := ""
+		if step != nil {
+			got = step.Name
+		}
+		if got != tt.want {
+			t.Errorf("StepFor(%d) = %q, want %q", tt.days, got, tt.want)
+		}
+	}
+
+	invalid := []*domain.DunningPolicy{
+		{TimeZone: "Mars/Olympus", Steps: domain.DefaultDunningSteps},
+		{Steps: domain.DunningSteps{{DaysFromDue: 7, Level: 1, Name: "A"}, {DaysFromDue: 7, Level: 2, Name: "B"}}},
+		{Steps: domain.DunningSteps{{DaysFromDue: 0, Level: 2, Name: "A"}, {DaysFromDue: 7, Level: 1, Name: "B"}}},
+	}
+	for i, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("invalid policy %d was accepted", i)
+		}
+	}
+}
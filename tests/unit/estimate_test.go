@@ -0,0 +1,82 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+)
+
+func TestEstimateStatusTransitions(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		from, to domain.EstimateStatus
+		ok       bool
+	}{
+		{domain.EstimateStatusDraft, domain.EstimateStatusSent, true},
+		{domain.EstimateStatusDraft, domain.EstimateStatusAccepted, false},
+		{domain.EstimateStatusSent, domain.EstimateStatusAccepted, true},
+		{domain.EstimateStatusSent, domain.EstimateStatusDeclined, true},
+		{domain.EstimateStatusSent, domain.EstimateStatusDraft, false},
+		{domain.EstimateStatusDeclined, domain.EstimateStatusDraft, true},
+		{domain.EstimateStatusDeclined, domain.EstimateStatusAccepted, false},
+		{domain.EstimateStatusExpired, domain.EstimateStatusDraft, true},
+		{domain.EstimateStatusAccepted, domain.EstimateStatusDeclined, false},
+	}
+	for _, tt := range tests {
+		e := &domain.Estimate{Status: tt.from}
+		err := e.TransitionTo(tt.to, now)
+		if tt.ok && (err != nil || e.Status != tt.to) {
+			t.Errorf("%s -> %s: error = %v, status = %s", tt.from, tt.to, err, e.Status)
+		}
+		if !tt.ok && !errors.Is(err, domain.ErrInvalidEstimateTransition) {
+			t.Errorf("%s -> %s: error = %v, want ErrInvalidEstimateTransition", tt.from, tt.to, err)
+		}
+	}
+
+	converted := &domain.Estimate{Status: domain.EstimateStatusAccepted, ConvertedAt: &now}
+	if err := converted.TransitionTo(domain.EstimateStatusDeclined, now); !errors.Is(err, domain.ErrEstimateConverted) {
+		t.Errorf("TransitionTo() on a converted estimate error = %v, want ErrEstimateConverted", err)
+	}
+}
+
+func TestEstimateExpiry(t *testing.T) {
+	expiry := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	e := &domain.Estimate{Status: domain.EstimateStatusSent, ExpiryDate: &expiry}
+
+	// The expiry date is the last day the estimate can be accepted
+	lastDay := time.Date(2026, 3, 10, 23, 59, 0, 0, time.UTC)
+	if got := e.CurrentStatus(lastDay); got != domain.EstimateStatusSent {
+		t.Errorf("CurrentStatus() on the expiry date = %s, want sent", got)
+	}
+	dayAfter := time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)
+	if got := e.CurrentStatus(dayAfter); got != domain.EstimateStatusExpired {
+		t.Errorf("CurrentStatus() after the expiry date = %s, want expired", got)
+	}
+
+	if err := e.TransitionTo(domain.EstimateStatusAccepted, dayAfter); !errors.Is(err, domain.ErrInvalidEstimateTransition) {
+		t.Errorf("accepting an expired estimate error = %v, want ErrInvalidEstimateTransition", err)
+	}
+	if err := e.TransitionTo(domain.EstimateStatusExpired, dayAfter); err != nil || e.Status != domain.EstimateStatusExpired {
+		t.Errorf("TransitionTo(expired) error = %v, status = %s", err, e.Status)
+	}
+	if err := e.TransitionTo(domain.EstimateStatusDraft, dayAfter); err != nil || !e.IsEditable() {
+		t.Errorf("reopening an expired estimate error = %v, status = %s", err, e.Status)
+	}
+
+	// Drafts and answered estimates do not expire
+	accepted := &domain.Estimate{Status: domain.EstimateStatusAccepted, ExpiryDate: &expiry}
+	if accepted.IsExpired(dayAfter) {
+		t.Error("IsExpired() = true for an accepted estimate")
+	}
+}
+
+func TestParseEstimateStatus(t *testing.T) {
+	if got, err := domain.ParseEstimateStatus("accepted"); err != nil || got != domain.EstimateStatusAccepted {
+		t.Errorf("ParseEstimateStatus(accepted) = %s, %v", got, err)
+	}
+	if _, err := domain.ParseEstimateStatus("paid"); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("ParseEstimateStatus(paid) error = %v, want ErrInvalidInput", err)
+	}
+}
@@ -0,0 +1,229 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+)
+
+func scheduledInvoice(t *testing.T) *domain.Invoice {
+	t.Helper()
+	inv := &domain.Invoice{
+		InvoiceNumber: "INV-1",
+		Status:        domain.InvoiceStatusSent,
+		InvoiceDate:   day(1, 15),
+		DueDate:       day(1, 31),
+		TotalAmount:   money.MustParse("100.00"),
+		Currency:      "USD",
+	}
+	installments, err := domain.GenerateInstallments(inv.TotalAmount, "USD", 3, day(1, 31), domain.RecurringMonthly)
+	if err != nil {
+		t.Fatalf("GenerateInstallments() error = %v", err)
+	}
+	if err := inv.SetInstallments(installments); err != nil {
+		t.Fatalf("SetInstallments() error = %v", err)
+	}
+	inv.RecalculateBalance()
+	return inv
+}
+
+func TestGenerateInstallments(t *testing.T) {
+	inv := scheduledInvoice(t)
+
+	want := []struct {
+		due    time.Time
+		amount string
+	}{
+		{day(1, 31), "33.34"},
+		{day(2, 28), "33.33"},
+		{day(3, 31), "33.33"},
+	}
+	for i, w := range want {
+		inst := inv.Installments[i]
+		if inst.Sequence != i+1 || !inst.DueDate.Equal(w.due) || !inst.Amount.Equal(money.MustParse(w.amount)) {
+			t.Errorf("installment %d = #%d %s %s, want %s %s", i, inst.Sequence, inst.DueDate.Format("2006-01-02"), inst.Amount, w.due.Format("2006-01-02"), w.amount)
+		}
+	}
+	if !inv.DueDate.Equal(day(3, 31)) {
+		t.Errorf("DueDate = %s, want the last installment's", inv.DueDate.Format("2006-01-02"))
+	}
+
+	if _, err := domain.GenerateInstallments(inv.TotalAmount, "USD", 3, day(1, 31), domain.RecurringCustom); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("custom frequency error = %v, want ErrInvalidInput", err)
+	}
+	if _, err := domain.GenerateInstallments(inv.TotalAmount, "USD", 1, day(1, 31), domain.RecurringMonthly); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("single installment error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestSetInstallmentsValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		installments []domain.Installment
+	}{
+		{"short of the total", []domain.Installment{
+			{DueDate: day(2, 1), Amount: money.MustParse("50.00")},
+			{DueDate: day(3, 1), Amount: money.MustParse("40.00")},
+		}},
+		{"before the invoice date", []domain.Installment{
+			{DueDate: day(1, 1), Amount: money.MustParse("50.00")},
+			{DueDate: day(3, 1), Amount: money.MustParse("50.00")},
+		}},
+		{"same due date", []domain.Installment{
+			{DueDate: day(2, 1), Amount: money.MustParse("50.00")},
+			{DueDate: day(2, 1), Amount: money.MustParse("50.00")},
+		}},
+		{"fractional cents", []domain.Installment{
+			{DueDate: day(2, 1), Amount: money.MustParse("50.005")},
+			{DueDate: day(3, 1), Amount: money.MustParse("49.995")},
+		}},
+	}
+	for _, tt := range tests {
+		inv := &domain.Invoice{Status: domain.InvoiceStatusSent, InvoiceDate: day(1, 15), TotalAmount: money.MustParse("100.00")}
+		if err := inv.SetInstallments(tt.installments); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+
+	// Entered out of order, they are put in due date order
+	inv := &domain.Invoice{Status: domain.InvoiceStatusSent, InvoiceDate: day(1, 15), TotalAmount: money.MustParse("100.00")}
+	err := inv.SetInstallments([]domain.Installment{
+		{DueDate: day(3, 1), Amount: money.MustParse("70.00")},
+		{DueDate: day(2, 1), Amount: money.MustParse("30.00")},
+	})
+	if err != nil || inv.Installments[0].Sequence != 1 || !inv.Installments[0].Amount.Equal(money.MustParse("30.00")) {
+		t.Errorf("SetInstallments() error = %v, first = %+v", err, inv.Installments[0])
+	}
+
+	paid := &domain.Invoice{Status: domain.InvoiceStatusPaid, TotalAmount: money.MustParse("100.00")}
+	if err := paid.SetInstallments(inv.Installments); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("schedule on a paid invoice error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestInstallmentsFollowPayments(t *testing.T) {
+	inv := scheduledInvoice(t)
+
+	inv.ApplyPayment(money.MustParse("40.00"))
+	want := []struct {
+		paid   string
+		status domain.InstallmentStatus
+	}{
+		{"33.34", domain.InstallmentPaid},
+		{"6.66", domain.InstallmentPartial},
+		{"0", domain.InstallmentPending},
+	}
+	for i, w := range want {
+		inst := inv.Installments[i]
+		if !inst.PaidAmount.Equal(money.MustParse(w.paid)) || inst.Status != w.status {
+			t.Errorf("installment %d = %s %s, want %s %s", i+1, inst.PaidAmount, inst.Status, w.paid, w.status)
+		}
+	}
+	if got := inv.CurrentDueDate(); !got.Equal(day(2, 28)) {
+		t.Errorf("CurrentDueDate() = %s, want the second installment's", got.Format("2006-01-02"))
+	}
+
+	// Credit notes settle installments like payments
+	inv.CreditedAmount = money.MustParse("60.00")
+	inv.RecalculateBalance()
+	if inv.Installments[2].Status != domain.InstallmentPaid || !inv.BalanceAmount.IsZero() {
+		t.Errorf("fully settled: last installment %s, balance %s", inv.Installments[2].Status, inv.BalanceAmount)
+	}
+	if got := inv.CurrentDueDate(); !got.Equal(inv.DueDate) {
+		t.Errorf("CurrentDueDate() when paid = %s, want the invoice due date", got.Format("2006-01-02"))
+	}
+
+	// Reversing a payment reopens the installments in reverse order
+	inv.CreditedAmount = money.Zero
+	inv.UnapplyPayment(money.MustParse("40.00"))
+	if inv.Installments[0].Status != domain.InstallmentPending || !inv.Installments[0].PaidAmount.IsZero() {
+		t.Errorf("after reversal first installment = %s %s", inv.Installments[0].PaidAmount, inv.Installments[0].Status)
+	}
+}
+
+func TestInstallmentOverdue(t *testing.T) {
+	inv := scheduledInvoice(t)
+	now := day(2, 10)
+
+	lapsed := inv.LapsedInstallments(now, time.UTC)
+	if len(lapsed) != 1 || lapsed[0].Sequence != 1 {
+		t.Fatalf("LapsedInstallments() = %d installments, want the first", len(lapsed))
+	}
+	if days := domain.DaysFromDue(inv, now, time.UTC); days != 10 {
+		t.Errorf("DaysFromDue() = %d, want 10 days past the first installment", days)
+	}
+
+	// The dunning run marks the installment and the invoice overdue
+	lapsed[0].Status = domain.InstallmentOverdue
+	inv.Status = domain.InvoiceStatusOverdue
+
+	// Part of the overdue installment keeps the invoice overdue
+	inv.ApplyPayment(money.MustParse("20.00"))
+	if inv.Installments[0].Status != domain.InstallmentOverdue || inv.SettlementStatus() != domain.InvoiceStatusOverdue {
+		t.Errorf("part paid: installment %s, invoice %s, want both overdue", inv.Installments[0].Status, inv.SettlementStatus())
+	}
+
+	// Paying it off makes the invoice due again on the next installment
+	inv.ApplyPayment(money.MustParse("13.34"))
+	if inv.Installments[0].Status != domain.InstallmentPaid {
+		t.Errorf("paid installment status = %s", inv.Installments[0].Status)
+	}
+	if got := inv.SettlementStatus(); got != domain.InvoiceStatusPartial {
+		t.Errorf("SettlementStatus() = %s, want partial", got)
+	}
+	if err := inv.TransitionTo(inv.SettlementStatus(), domain.TriggerPayment, now); err != nil {
+		t.Errorf("TransitionTo(partial) error = %v", err)
+	}
+	if days := domain.DaysFromDue(inv, now, time.UTC); days >= 0 {
+		t.Errorf("DaysFromDue() = %d, want the second installment not yet due", days)
+	}
+}
+
+func TestKeepSchedule(t *testing.T) {
+	inv := scheduledInvoice(t)
+	inv.DueDate = day(5, 1)
+	if err := inv.KeepSchedule(); err != nil || !inv.DueDate.Equal(day(3, 31)) {
+		t.Errorf("KeepSchedule() error = %v, due date = %s", err, inv.DueDate.Format("2006-01-02"))
+	}
+	inv.TotalAmount = money.MustParse("120.00")
+	if err := inv.KeepSchedule(); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("KeepSchedule() with a new total error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestAgingBalance(t *testing.T) {
+	asOf := day(4, 15)
+
+	// Installments age by their own due dates
+	inv := scheduledInvoice(t)
+	inv.ApplyPayment(money.MustParse("10.00"))
+	var aged domain.AgingBalance
+	aged.Age(inv, asOf)
+	if !aged.Days61To90.Equal(money.MustParse("23.34")) || !aged.Days31To60.Equal(money.MustParse("33.33")) || !aged.Days1To30.Equal(money.MustParse("33.33")) {
+		t.Errorf("installments aged = %+v", aged)
+	}
+
+	// A debit note is owed on the invoice's due date
+	inv.DebitedAmount = money.MustParse("5.00")
+	inv.RecalculateBalance()
+	var debited domain.AgingBalance
+	debited.Age(inv, asOf)
+	if !debited.Days1To30.Equal(money.MustParse("38.33")) || !debited.Total().Equal(money.MustParse("95.00")) {
+		t.Errorf("with debit note aged = %+v, total %s", debited, debited.Total())
+	}
+
+	plain := &domain.Invoice{DueDate: day(4, 30), TotalAmount: money.MustParse("50.00")}
+	old := &domain.Invoice{DueDate: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC), TotalAmount: money.MustParse("20.00")}
+	var total domain.AgingBalance
+	for _, i := range []*domain.Invoice{plain, old} {
+		var b domain.AgingBalance
+		b.Age(i, asOf)
+		total.Add(b)
+	}
+	if !total.Current.Equal(money.MustParse("50.00")) || !total.Over90.Equal(money.MustParse("20.00")) || !total.Total().Equal(money.MustParse("70.00")) {
+		t.Errorf("aged total = %+v", total)
+	}
+}
@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"sync"
+	"testing"
+
+	"erp-billing-service/internal/adapters/outbound/postgres"
+	"erp-billing-service/internal/domain"
+
+	"gorm.io/gorm/schema"
+)
+
+// TestInvoiceColumns tests that edits and status changes only write columns
+// that exist and that they own, so they never clobber what payments and
+// notes keep up to date
+func TestInvoiceColumns(t *testing.T) {
+	invoiceSchema, err := schema.Parse(&domain.Invoice{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("Failed to parse invoice schema: %v", err)
+	}
+	paymentOwned := []string{"paid_amount", "early_pay_taken", "credited_amount", "debited_amount", "excess_credit", "late_fee_total"}
+
+	tests := []struct {
+		name      string
+		columns   []string
+		forbidden []string
+	}{
+		{name: "draft edit", columns: postgres.InvoiceEditColumns, forbidden: append([]string{"status", "locked_at", "invoice_number", "organization_id", "kind"}, paymentOwned...)},
+		{name: "status change", columns: postgres.InvoiceStatusColumns, forbidden: append([]string{"balance_amount", "total_amount", "due_date"}, paymentOwned...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written := make(map[string]bool, len(tt.columns))
+			for _, col := range tt.columns {
+				if invoiceSchema.LookUpField(col) == nil {
+					t.Errorf("Column %s does not exist on invoices", col)
+				}
+				written[col] = true
+			}
+			for _, col := range tt.forbidden {
+				if written[col] {
+					t.Errorf("Column %s is not owned by a %s", col, tt.name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+)
+
+// TestInvoice_TransitionTo tests legal and illegal status moves
+func TestInvoice_TransitionTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    domain.InvoiceStatus
+		to      domain.InvoiceStatus
+		trigger domain.StatusTrigger
+		wantErr bool
+	}{
+		{name: "draft can be sent", from: domain.InvoiceStatusDraft, to: domain.InvoiceStatusSent, trigger: domain.TriggerManual},
+		{name: "void cannot return to draft", from: domain.InvoiceStatusVoid, to: domain.InvoiceStatusDraft, trigger: domain.TriggerManual, wantErr: true},
+		{name: "paid cannot be sent manually", from: domain.InvoiceStatusPaid, to: domain.InvoiceStatusSent, trigger: domain.TriggerManual, wantErr: true},
+		{name: "paid reopens after refund", from: domain.InvoiceStatusPaid, to: domain.InvoiceStatusPartial, trigger: domain.TriggerPayment},
+		{name: "sent cannot be marked paid manually", from: domain.InvoiceStatusSent, to: domain.InvoiceStatusPaid, trigger: domain.TriggerManual, wantErr: true},
+		{name: "overdue can be disputed", from: domain.InvoiceStatusOverdue, to: domain.InvoiceStatusDisputed, trigger: domain.TriggerManual},
+		{name: "partial can be written off", from: domain.InvoiceStatusPartial, to: domain.InvoiceStatusWrittenOff, trigger: domain.TriggerManual},
+		{name: "unpaid sent invoice can be written off", from: domain.InvoiceStatusSent, to: domain.InvoiceStatusWrittenOff, trigger: domain.TriggerManual},
+		{name: "same status is a no-op", from: domain.InvoiceStatusSent, to: domain.InvoiceStatusSent, trigger: domain.TriggerManual},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := &domain.Invoice{Status: tt.from}
+			err := inv.TransitionTo(tt.to, tt.trigger, time.Now())
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidStatusTransition) {
+					t.Errorf("Expected ErrInvalidStatusTransition, got %v", err)
+				}
+				if inv.Status != tt.from {
+					t.Errorf("Status changed to %s on rejected transition", inv.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if inv.Status != tt.to {
+				t.Errorf("Expected status %s, got %s", tt.to, inv.Status)
+			}
+		})
+	}
+}
+
+// TestInvoice_TransitionToLocksIssuedInvoice tests that leaving draft locks the invoice
+func TestInvoice_TransitionToLocksIssuedInvoice(t *testing.T) {
+	inv := &domain.Invoice{Status: domain.InvoiceStatusDraft}
+	if inv.IsLocked() {
+		t.Fatal("Draft invoice should not be locked")
+	}
+	if err := inv.TransitionTo(domain.InvoiceStatusSent, domain.TriggerManual, time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !inv.IsLocked() {
+		t.Error("Sent invoice should be locked")
+	}
+}
+
+// TestInvoice_CannotVoidWithPayments tests that paid money blocks voiding
+func TestInvoice_CannotVoidWithPayments(t *testing.T) {
+	inv := &domain.Invoice{Status: domain.InvoiceStatusOverdue, PaidAmount: money.New(10)}
+	if err := inv.TransitionTo(domain.InvoiceStatusVoid, domain.TriggerManual, time.Now()); !errors.Is(err, domain.ErrInvalidStatusTransition) {
+		t.Errorf("Expected ErrInvalidStatusTransition, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+)
+
+func overdueInvoice(total string) *domain.Invoice {
+	inv := &domain.Invoice{
+		Currency:    "USD",
+		Status:      domain.InvoiceStatusOverdue,
+		DueDate:     time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		TotalAmount: money.MustParse(total),
+	}
+	inv.RecalculateBalance()
+	return inv
+}
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLateFeeGracePeriod(t *testing.T) {
+	policy := &domain.LateFeePolicy{Type: domain.LateFeeFlat, Amount: money.MustParse("25"), GraceDays: 5}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	inv := overdueInvoice("1000")
+
+	if c := policy.ComputeLateFee(inv, day(3, 6), nil); c != nil {
+		t.Errorf("fee charged within the grace period: %+v", c)
+	}
+	c := policy.ComputeLateFee(inv, day(3, 7), nil)
+	if c == nil || !c.Amount.Equal(money.MustParse("25")) {
+		t.Fatalf("ComputeLateFee() = %+v, want a fee of 25", c)
+	}
+	if !c.AccruedThrough.Equal(day(3, 1)) {
+		t.Errorf("AccruedThrough = %s, want the due date", c.AccruedThrough)
+	}
+	// A flat fee is charged once
+	if again := policy.ComputeLateFee(inv, day(4, 7), []domain.LateFeeCharge{*c}); again != nil {
+		t.Errorf("flat fee charged twice: %+v", again)
+	}
+}
+
+func TestLateFeePercentExcludesEarlierFees(t *testing.T) {
+	policy := &domain.LateFeePolicy{Type: domain.LateFeePercent, Rate: money.MustParse("1.5")}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	inv := overdueInvoice("1000")
+	inv.PaidAmount = money.MustParse("200.10")
+	inv.LateFeeTotal = money.MustParse("10")
+	inv.TotalAmount = inv.TotalAmount.Add(inv.LateFeeTotal)
+	inv.RecalculateBalance()
+
+	c := policy.ComputeLateFee(inv, day(3, 2), nil)
+	if c == nil {
+		t.Fatal("ComputeLateFee() = nil, want a fee")
+	}
+	if !c.Basis.Equal(money.MustParse("799.90")) {
+		t.Errorf("Basis = %s, want 799.90", c.Basis)
+	}
+	// 1.5% of 799.90 is 11.9985
+	if !c.Amount.Equal(money.MustParse("12.00")) {
+		t.Errorf("Amount = %s, want 12.00", c.Amount)
+	}
+}
+
+func TestLateFeeInterestAccruesByInterval(t *testing.T) {
+	policy := &domain.LateFeePolicy{Type: domain.LateFeeInterest, Rate: money.MustParse("18.25"), IntervalDays: 30, Cap: money.MustParse("20")}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	inv := overdueInvoice("1000")
+
+	if c := policy.ComputeLateFee(inv, day(3, 30), nil); c != nil {
+		t.Errorf("interest charged before a full interval: %+v", c)
+	}
+	// 18.25% a year is 0.05% a day: 30 days on 1000 is 15
+	first := policy.ComputeLateFee(inv, day(3, 31), nil)
+	if first == nil || !first.Amount.Equal(money.MustParse("15")) || first.Days != 30 {
+		t.Fatalf("ComputeLateFee() = %+v, want 15 for 30 days", first)
+	}
+	if !first.AccruedFrom.Equal(day(3, 1)) || !first.AccruedThrough.Equal(day(3, 31)) {
+		t.Errorf("accrual = %s to %s, want 2026-03-01 to 2026-03-31", first.AccruedFrom, first.AccruedThrough)
+	}
+
+	previous := []domain.LateFeeCharge{*first}
+	if c := policy.ComputeLateFee(inv, day(4, 29), previous); c != nil {
+		t.Errorf("interest charged before the next interval: %+v", c)
+	}
+	// The next 15 would pass the cap of 20
+	second := policy.ComputeLateFee(inv, day(4, 30), previous)
+	if second == nil || !second.Amount.Equal(money.MustParse("5")) || !second.Capped {
+		t.Fatalf("ComputeLateFee() = %+v, want 5, capped", second)
+	}
+	if !second.AccruedFrom.Equal(day(3, 31)) {
+		t.Errorf("AccruedFrom = %s, want the end of the last charge", second.AccruedFrom)
+	}
+
+	previous = append(previous, *second)
+	if c := policy.ComputeLateFee(inv, day(6, 30), previous); c != nil {
+		t.Errorf("interest charged past the cap: %+v", c)
+	}
+}
+
+func TestLateFeeNothingOnSettledInvoice(t *testing.T) {
+	policy := &domain.LateFeePolicy{Type: domain.LateFeeFlat, Amount: money.MustParse("25")}
+	inv := overdueInvoice("100")
+	inv.PaidAmount = money.MustParse("100")
+	inv.RecalculateBalance()
+	if c := policy.ComputeLateFee(inv, day(4, 1), nil); c != nil {
+		t.Errorf("fee charged on a settled invoice: %+v", c)
+	}
+}
+
+func TestAddLateFeeLineKeepsAdjustmentApart(t *testing.T) {
+	inv := overdueInvoice("1000")
+	inv.Adjustment = money.MustParse("-5")
+	policy := &domain.LateFeePolicy{Type: domain.LateFeeFlat, Amount: money.MustParse("25"), Mode: domain.LateFeeAsLine}
+	c := policy.ComputeLateFee(inv, day(3, 2), nil)
+
+	inv.AddLateFeeLine(c)
+	if !inv.LateFeeTotal.Equal(money.MustParse("25")) || !inv.Adjustment.Equal(money.MustParse("-5")) {
+		t.Errorf("LateFeeTotal = %s, Adjustment = %s, want 25 and -5", inv.LateFeeTotal, inv.Adjustment)
+	}
+	if !inv.BalanceAmount.Equal(money.MustParse("1025")) {
+		t.Errorf("BalanceAmount = %s, want 1025", inv.BalanceAmount)
+	}
+	if len(inv.Items) != 1 || inv.Items[0].ItemType != domain.LateFeeItemType {
+		t.Errorf("Items = %+v, want one late fee line", inv.Items)
+	}
+}
+
+func TestLateFeePolicyValidate(t *testing.T) {
+	tests := []domain.LateFeePolicy{
+		{Type: domain.LateFeeFlat},
+		{Type: domain.LateFeePercent, Rate: money.MustParse("101")},
+		{Type: "weekly"},
+		{Type: domain.LateFeeFlat, Amount: money.MustParse("10"), Mode: "email"},
+		{Type: domain.LateFeeFlat, Amount: money.MustParse("10"), GraceDays: -1},
+	}
+	for _, policy := range tests {
+		if err := policy.Validate(); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("Validate(%+v) error = %v, want ErrInvalidInput", policy, err)
+		}
+	}
+}
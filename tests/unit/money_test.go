@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"erp-billing-service/pkg/money"
+)
+
+// TestMoney_AddIsExact tests that repeated addition does not drift
+func TestMoney_AddIsExact(t *testing.T) {
+	total := money.Zero
+	for i := 0; i < 10; i++ {
+		total = total.Add(money.MustParse("0.10"))
+	}
+	if !total.Equal(money.New(1)) {
+		t.Errorf("Expected 1.00, got %s", total)
+	}
+}
+
+// TestMoney_Round tests rounding modes and per-currency minor units
+func TestMoney_Round(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   string
+		currency string
+		mode     money.RoundingMode
+		want     string
+	}{
+		{name: "half up rounds tie away from zero", amount: "1.005", currency: "USD", mode: money.RoundHalfUp, want: "1.01"},
+		{name: "half up negative", amount: "-1.005", currency: "USD", mode: money.RoundHalfUp, want: "-1.01"},
+		{name: "half even rounds tie to even", amount: "1.005", currency: "USD", mode: money.RoundHalfEven, want: "1.00"},
+		{name: "half even rounds odd tie up", amount: "1.015", currency: "USD", mode: money.RoundHalfEven, want: "1.02"},
+		{name: "down truncates", amount: "1.019", currency: "USD", mode: money.RoundDown, want: "1.01"},
+		{name: "up rounds away from zero", amount: "1.011", currency: "USD", mode: money.RoundUp, want: "1.02"},
+		{name: "zero decimal currency", amount: "150.5", currency: "JPY", mode: money.RoundHalfUp, want: "151.00"},
+		{name: "three decimal currency", amount: "1.2345", currency: "KWD", mode: money.RoundHalfUp, want: "1.235"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := money.MustParse(tt.amount).Round(tt.currency, tt.mode)
+			if got.String() != tt.want {
+				t.Errorf("Round(%s, %s) = %s, want %s", tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMoney_Mul tests multiplying by a quantity
+func TestMoney_Mul(t *testing.T) {
+	got := money.MustParse("19.99").Mul(3)
+	if got.String() != "59.97" {
+		t.Errorf("Expected 59.97, got %s", got)
+	}
+}
+
+// TestMoney_Allocate tests that allocated parts always sum to the whole
+func TestMoney_Allocate(t *testing.T) {
+	weights := []money.Amount{money.New(1), money.New(1), money.New(1)}
+	parts := money.MustParse("10.00").Allocate("USD", weights)
+	if !money.Sum(parts...).Equal(money.MustParse("10.00")) {
+		t.Errorf("Expected parts to sum to 10.00, got %v", parts)
+	}
+	if parts[0].String() != "3.34" {
+		t.Errorf("Expected remainder on first part, got %s", parts[0])
+	}
+}
+
+// TestMoney_JSON tests that amounts round-trip through JSON as numbers
+func TestMoney_JSON(t *testing.T) {
+	var payload struct {
+		Amount money.Amount `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": 123.45}`), &payload); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"amount":123.45}` {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+}
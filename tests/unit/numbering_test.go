@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// TestNumberSequenceAdvance tests number formats and period resets
+func TestNumberSequenceAdvance(t *testing.T) {
+	mar2026 := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	apr2026 := time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC)
+	jan2027 := time.Date(2027, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		mutate func(s *domain.NumberSequence)
+		times  []time.Time
+		want   []string
+	}{
+		{
+			name:   "default format keeps counting",
+			mutate: func(s *domain.NumberSequence) {},
+			times:  []time.Time{mar2026, jan2027},
+			want:   []string{"INV-2026-0001", "INV-2027-0002"},
+		},
+		{
+			name: "yearly reset",
+			mutate: func(s *domain.NumberSequence) {
+				s.ResetPeriod = domain.ResetYearly
+			},
+			times: []time.Time{mar2026, apr2026, jan2027},
+			want:  []string{"INV-2026-0001", "INV-2026-0002", "INV-2027-0001"},
+		},
+		{
+			name: "monthly reset",
+			mutate: func(s *domain.NumberSequence) {
+				s.Format = "{PREFIX}{YY}{MM}-{SEQ}"
+				s.Padding = 3
+				s.ResetPeriod = domain.ResetMonthly
+			},
+			times: []time.Time{mar2026, mar2026, apr2026},
+			want:  []string{"INV2603-001", "INV2603-002", "INV2604-001"},
+		},
+		{
+			name: "fiscal year starting in April",
+			mutate: func(s *domain.NumberSequence) {
+				s.Format = "{PREFIX}/FY{FY}/{SEQ}"
+				s.ResetPeriod = domain.ResetFiscalYear
+				s.FiscalYearStart = 4
+			},
+			times: []time.Time{mar2026, apr2026, jan2027},
+			want:  []string{"INV/FY2026/0001", "INV/FY2027/0001", "INV/FY2027/0002"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := domain.DefaultNumberSequence(uuid.New(), domain.DocumentInvoice)
+			tt.mutate(seq)
+			if err := seq.Validate(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for i, at := range tt.times {
+				if preview := seq.Preview(at); preview != tt.want[i] {
+					t.Errorf("Preview %d: expected %s, got %s", i, tt.want[i], preview)
+				}
+				if got := seq.Advance(at); got != tt.want[i] {
+					t.Errorf("Number %d: expected %s, got %s", i, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+// TestNumberSequenceValidate tests that formats must keep numbers unique
+func TestNumberSequenceValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		reset  domain.SequenceReset
+	}{
+		{name: "no sequence token", format: "{PREFIX}-{YYYY}", reset: domain.ResetNever},
+		{name: "yearly reset without year", format: "{PREFIX}-{SEQ}", reset: domain.ResetYearly},
+		{name: "monthly reset without month", format: "{PREFIX}-{YYYY}-{SEQ}", reset: domain.ResetMonthly},
+		{name: "fiscal reset without fiscal year", format: "{PREFIX}-{YYYY}-{SEQ}", reset: domain.ResetFiscalYear},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq := domain.DefaultNumberSequence(uuid.New(), domain.DocumentCreditNote)
+			seq.Format = tt.format
+			seq.ResetPeriod = tt.reset
+			if err := seq.Validate(); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("Expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"erp-billing-service/internal/adapters/outbound/gateway"
+	"erp-billing-service/internal/application"
+	"erp-billing-service/internal/application/dto"
+	"erp-billing-service/internal/config"
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// TestMockGateway tests the deterministic mock provider and its signed webhooks
+func TestMockGateway(t *testing.T) {
+	ctx := context.Background()
+	g := gateway.NewMockGateway("secret")
+	req := domain.PaymentIntentRequest{
+		OrganizationID: uuid.New(),
+		InvoiceID:      uuid.New(),
+		Amount:         money.MustParse("120.00"),
+		Currency:       "USD",
+		PaymentMethod:  domain.PaymentMethodCard,
+	}
+
+	intent, err := g.CreateIntent(ctx, req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	again, _ := g.CreateIntent(ctx, req)
+	if again.ID != intent.ID || intent.Status != domain.PaymentIntentRequiresCapture {
+		t.Errorf("Expected the same intent awaiting capture, got %s and %s (%s)", intent.ID, again.ID, intent.Status)
+	}
+
+	captured, err := g.Capture(ctx, intent.ID, money.Zero)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if captured.Status != domain.PaymentIntentSucceeded || captured.TransactionRef == "" {
+		t.Errorf("Expected a succeeded charge, got %+v", captured)
+	}
+	if _, err := g.Capture(ctx, "pi_unknown", money.Zero); !errors.Is(err, domain.ErrPaymentIntentNotFound) {
+		t.Errorf("Expected ErrPaymentIntentNotFound, got %v", err)
+	}
+
+	payload, signature, err := g.Webhook(captured)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	event, err := g.ParseWebhook(payload, signature)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if event.Type != domain.GatewayEventPaymentSucceeded || event.TransactionRef != captured.TransactionRef ||
+		event.InvoiceID != req.InvoiceID || !event.Amount.Equal(req.Amount) {
+		t.Errorf("Webhook does not match the capture: %+v", event)
+	}
+	if _, err := g.ParseWebhook(payload, "00"+signature[2:]); !errors.Is(err, domain.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a tampered signature, got %v", err)
+	}
+	if _, err := gateway.NewMockGateway("other").ParseWebhook(payload, signature); !errors.Is(err, domain.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for another secret, got %v", err)
+	}
+
+	// Amounts ending in .02 are declined
+	req.Amount = money.MustParse("10.02")
+	declined, _ := g.CreateIntent(ctx, req)
+	declined, err = g.Capture(ctx, declined.ID, money.Zero)
+	if err != nil || declined.Status != domain.PaymentIntentFailed || declined.TransactionRef != "" {
+		t.Errorf("Expected a declined capture, got %+v (%v)", declined, err)
+	}
+}
+
+// TestGatewayService_CaptureOtherOrganization tests that an intent of another
+// organization is refused before anything is captured
+func TestGatewayService_CaptureOtherOrganization(t *testing.T) {
+	ctx := context.Background()
+	g := gateway.NewMockGateway("secret")
+	intent, err := g.CreateIntent(ctx, domain.PaymentIntentRequest{
+		OrganizationID: uuid.New(),
+		InvoiceID:      uuid.New(),
+		Amount:         money.MustParse("50.00"),
+		Currency:       "USD",
+		PaymentMethod:  domain.PaymentMethodCard,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	service := application.NewGatewayService(g, nil, nil, nil)
+	_, err = service.CapturePaymentIntent(ctx, uuid.New(), intent.ID, dto.CapturePaymentIntentRequest{}, "tester")
+	if !errors.Is(err, domain.ErrPaymentIntentNotFound) {
+		t.Fatalf("Expected ErrPaymentIntentNotFound, got %v", err)
+	}
+	after, err := g.GetIntent(ctx, intent.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if after.Status != domain.PaymentIntentRequiresCapture {
+		t.Errorf("Expected the intent to stay uncaptured, got %s", after.Status)
+	}
+}
+
+// TestConfig_GatewayRequired tests that the service does not start without a
+// payment gateway provider and webhook secret
+func TestConfig_GatewayRequired(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		secret   string
+		wantErr  bool
+	}{
+		{name: "provider and secret set", provider: "mock", secret: "s3cret"},
+		{name: "provider missing", secret: "s3cret", wantErr: true},
+		{name: "secret missing", provider: "mock", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PAYMENT_GATEWAY_PROVIDER", tt.provider)
+			t.Setenv("PAYMENT_GATEWAY_WEBHOOK_SECRET", tt.secret)
+			cfg, err := config.Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if cfg.GatewayProvider != tt.provider || cfg.GatewaySecret != tt.secret {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.provider, tt.secret, cfg.GatewayProvider, cfg.GatewaySecret)
+			}
+		})
+	}
+}
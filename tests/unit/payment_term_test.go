@@ -0,0 +1,116 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+)
+
+func TestPaymentTermDueDate(t *testing.T) {
+	invoiceDate := time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		term domain.PaymentTerm
+		want time.Time
+	}{
+		{domain.PaymentTerm{Type: domain.PaymentTermDueOnReceipt}, invoiceDate},
+		{domain.PaymentTerm{Type: domain.PaymentTermNet, Days: 15}, time.Date(2026, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{domain.PaymentTerm{Type: domain.PaymentTermNet, Days: 60}, time.Date(2026, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{domain.PaymentTerm{Type: domain.PaymentTermEndOfMonth}, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{domain.PaymentTerm{Type: domain.PaymentTermEndOfMonth, Days: 15}, time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.term.DueDate(invoiceDate); !got.Equal(tt.want) {
+			t.Errorf("%s %d: DueDate() = %s, want %s", tt.term.Type, tt.term.Days, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+		}
+	}
+	// End of February in a non-leap year
+	eom := domain.PaymentTerm{Type: domain.PaymentTermEndOfMonth}
+	if got := eom.DueDate(time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC)); got.Day() != 28 {
+		t.Errorf("DueDate() = %s, want 2026-02-28", got.Format("2006-01-02"))
+	}
+}
+
+func TestStandardPaymentTermsAreValid(t *testing.T) {
+	for _, term := range domain.StandardPaymentTerms {
+		if err := term.Validate(); err != nil {
+			t.Errorf("%s: Validate() error = %v", term.Code, err)
+		}
+	}
+
+	invalid := []domain.PaymentTerm{
+		{Code: "X", Name: "X", Type: "weekly"},
+		{Code: "X", Name: "X", Type: domain.PaymentTermNet, Days: -1},
+		{Code: "X", Name: "X", Type: domain.PaymentTermDueOnReceipt, Days: 10},
+		{Code: "X", Name: "X", Type: domain.PaymentTermNet, Days: 30, DiscountPct: money.New(2)},
+		{Type: domain.PaymentTermNet, Days: 30},
+	}
+	for _, term := range invalid {
+		if err := term.Validate(); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("Validate(%+v) error = %v, want ErrInvalidInput", term, err)
+		}
+	}
+}
+
+func termInvoice(term domain.PaymentTerm, dueDate time.Time) *domain.Invoice {
+	inv := &domain.Invoice{
+		Currency:    "USD",
+		InvoiceDate: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+		DueDate:     dueDate,
+		TotalAmount: money.MustParse("1000.50"),
+	}
+	term.ApplyTo(inv)
+	inv.RecalculateBalance()
+	return inv
+}
+
+func TestPaymentTermApplyTo(t *testing.T) {
+	term := domain.StandardPaymentTerms[len(domain.StandardPaymentTerms)-1] // 2/10 Net 30
+	inv := termInvoice(term, time.Time{})
+	if want := time.Date(2026, 2, 4, 0, 0, 0, 0, time.UTC); !inv.DueDate.Equal(want) {
+		t.Errorf("DueDate = %s, want %s", inv.DueDate, want)
+	}
+	if inv.EarlyPayBy == nil || !inv.EarlyPayBy.Equal(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("EarlyPayBy = %v, want 2026-01-15", inv.EarlyPayBy)
+	}
+
+	// A due date entered by hand is kept
+	manual := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if inv := termInvoice(term, manual); !inv.DueDate.Equal(manual) {
+		t.Errorf("DueDate = %s, want the one entered", inv.DueDate)
+	}
+}
+
+func TestEarlyPaymentDiscount(t *testing.T) {
+	term := domain.StandardPaymentTerms[len(domain.StandardPaymentTerms)-1] // 2/10 Net 30
+	inWindow := time.Date(2026, 1, 15, 18, 0, 0, 0, time.UTC)
+
+	// 2% of 1000.50 is 20.01, so 980.49 settles the invoice
+	inv := termInvoice(term, time.Time{})
+	if got := inv.EarlyPayDiscount(money.MustParse("980.49"), inWindow); !got.Equal(money.MustParse("20.01")) {
+		t.Fatalf("EarlyPayDiscount() = %s, want 20.01", got)
+	}
+	if got := inv.EarlyPayDiscount(money.MustParse("980.48"), inWindow); !got.IsZero() {
+		t.Errorf("EarlyPayDiscount() for a payment that does not settle the invoice = %s, want 0", got)
+	}
+	if got := inv.EarlyPayDiscount(money.MustParse("1000.50"), inWindow.AddDate(0, 0, 1)); !got.IsZero() {
+		t.Errorf("EarlyPayDiscount() after the window = %s, want 0", got)
+	}
+
+	inv.TakeEarlyPayDiscount(money.MustParse("20.01"))
+	inv.ApplyPayment(money.MustParse("980.49"))
+	if !inv.AmountDue().IsZero() || inv.SettlementStatus() != domain.InvoiceStatusPaid {
+		t.Fatalf("AmountDue() = %s, want the invoice settled", inv.AmountDue())
+	}
+	if inv.RevokeEarlyPayDiscount() {
+		t.Error("RevokeEarlyPayDiscount() revoked the discount of a settled invoice")
+	}
+
+	// The payment bounces: the discount is no longer earned
+	inv.UnapplyPayment(money.MustParse("980.49"))
+	if !inv.RevokeEarlyPayDiscount() || !inv.BalanceAmount.Equal(money.MustParse("1000.50")) {
+		t.Errorf("BalanceAmount = %s after revoking, want 1000.50", inv.BalanceAmount)
+	}
+}
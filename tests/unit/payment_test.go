@@ -0,0 +1,251 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+// TestValidatePayment tests payment amount, method and date checks
+func TestValidatePayment(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	inv := &domain.Invoice{
+		InvoiceNumber: "INV-2026-0001",
+		TotalAmount:   money.MustParse("100.00"),
+		PaidAmount:    money.MustParse("40.00"),
+	}
+
+	tests := []struct {
+		name    string
+		amount  string
+		method  string
+		paidAt  time.Time
+		wantErr bool
+	}{
+		{name: "partial payment", amount: "10.00", method: domain.PaymentMethodCash, paidAt: now},
+		{name: "pays off the balance", amount: "60.00", method: domain.PaymentMethodBankTransfer, paidAt: now.AddDate(0, 0, -3)},
+		{name: "unknown method", amount: "10.00", method: "barter", paidAt: now, wantErr: true},
+		{name: "zero amount", amount: "0", method: domain.PaymentMethodCard, paidAt: now, wantErr: true},
+		{name: "negative amount", amount: "-5.00", method: domain.PaymentMethodCard, paidAt: now, wantErr: true},
+		{name: "future date", amount: "10.00", method: domain.PaymentMethodCheck, paidAt: now.Add(time.Hour), wantErr: true},
+		{name: "overpayment becomes credit", amount: "75.00", method: domain.PaymentMethodCash, paidAt: now},
+		{name: "credit up to the amount due", amount: "60.00", method: domain.PaymentMethodCredit, paidAt: now},
+		{name: "credit beyond the amount due", amount: "60.01", method: domain.PaymentMethodCredit, paidAt: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := domain.ValidatePayment(inv, money.MustParse(tt.amount), tt.method, tt.paidAt, now)
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrInvalidInput) {
+					t.Errorf("Expected ErrInvalidInput, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// TestApplyPaymentOverpayment tests that the excess of a payment is kept as customer credit
+func TestApplyPaymentOverpayment(t *testing.T) {
+	inv := &domain.Invoice{
+		TotalAmount: money.MustParse("100.00"),
+		PaidAmount:  money.MustParse("40.00"),
+	}
+	applied, unapplied := inv.ApplyPayment(money.MustParse("75.00"))
+	if !applied.Equal(money.MustParse("60.00")) || !unapplied.Equal(money.MustParse("15.00")) {
+		t.Fatalf("Expected 60.00 applied and 15.00 unapplied, got %s and %s", applied, unapplied)
+	}
+	if !inv.BalanceAmount.IsZero() || !inv.PaidAmount.Equal(inv.TotalAmount) {
+		t.Errorf("Expected the invoice to be settled, paid %s balance %s", inv.PaidAmount, inv.BalanceAmount)
+	}
+
+	payment := &domain.Payment{Amount: applied, Unapplied: unapplied, PaymentMethod: domain.PaymentMethodCash}
+	entry := domain.CreditEntryForPayment(payment)
+	if entry == nil || entry.EntryType != domain.CreditEntryOverpayment || !entry.Amount.Equal(unapplied) {
+		t.Errorf("Expected an overpayment credit of %s, got %+v", unapplied, entry)
+	}
+
+	fromCredit := &domain.Payment{Amount: money.MustParse("20.00"), PaymentMethod: domain.PaymentMethodCredit}
+	entry = domain.CreditEntryForPayment(fromCredit)
+	if entry == nil || entry.EntryType != domain.CreditEntryApplied || !entry.Amount.Equal(money.MustParse("-20.00")) {
+		t.Errorf("Expected credit of 20.00 to be used, got %+v", entry)
+	}
+}
+
+// TestPlanAllocations tests the automatic allocation strategies
+func TestPlanAllocations(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 5, d, 0, 0, 0, 0, time.UTC) }
+	older := &domain.Invoice{ID: uuid.New(), InvoiceNumber: "INV-1", DueDate: day(1), TotalAmount: money.MustParse("100.00")}
+	newer := &domain.Invoice{ID: uuid.New(), InvoiceNumber: "INV-2", DueDate: day(15), TotalAmount: money.MustParse("50.00")}
+	settled := &domain.Invoice{ID: uuid.New(), InvoiceNumber: "INV-0", DueDate: day(1), TotalAmount: money.MustParse("80.00"), PaidAmount: money.MustParse("80.00")}
+	invoices := []*domain.Invoice{newer, settled, older}
+
+	allocations, err := domain.PlanAllocations(domain.AllocateOldestFirst, money.MustParse("120.00"), invoices)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(allocations) != 2 || allocations[0].InvoiceID != older.ID || !allocations[0].Amount.Equal(money.MustParse("100.00")) ||
+		allocations[1].InvoiceID != newer.ID || !allocations[1].Amount.Equal(money.MustParse("20.00")) {
+		t.Errorf("Expected 100.00 on INV-1 then 20.00 on INV-2, got %+v", allocations)
+	}
+
+	allocations, _ = domain.PlanAllocations(domain.AllocateExactMatch, money.MustParse("50.00"), invoices)
+	if len(allocations) != 1 || allocations[0].InvoiceID != newer.ID {
+		t.Errorf("Expected the exact match INV-2, got %+v", allocations)
+	}
+	allocations, _ = domain.PlanAllocations(domain.AllocateExactMatch, money.MustParse("60.00"), invoices)
+	if len(allocations) != 0 {
+		t.Errorf("Expected no allocation without an exact match, got %+v", allocations)
+	}
+
+	if _, err := domain.PlanAllocations(domain.AllocateManual, money.MustParse("60.00"), invoices); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for the manual strategy, got %v", err)
+	}
+}
+
+// TestPaymentAllocate tests splitting a payment across invoices and moving it later
+func TestPaymentAllocate(t *testing.T) {
+	orgID, customerID := uuid.New(), uuid.New()
+	a := &domain.Invoice{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, InvoiceNumber: "INV-A", TotalAmount: money.MustParse("100.00")}
+	b := &domain.Invoice{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, InvoiceNumber: "INV-B", TotalAmount: money.MustParse("40.00")}
+	invoices := map[uuid.UUID]*domain.Invoice{a.ID: a, b.ID: b}
+
+	payment := &domain.Payment{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, Currency: "USD", Unapplied: money.MustParse("150.00")}
+	err := payment.Allocate([]domain.PaymentAllocation{
+		{InvoiceID: a.ID, Amount: money.MustParse("100.00")},
+		{InvoiceID: b.ID, Amount: money.MustParse("30.00")},
+	}, invoices, "tester")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !payment.Amount.Equal(money.MustParse("130.00")) || !payment.Unapplied.Equal(money.MustParse("20.00")) {
+		t.Errorf("Expected 130.00 allocated and 20.00 unapplied, got %s and %s", payment.Amount, payment.Unapplied)
+	}
+	if !a.BalanceAmount.IsZero() || !b.BalanceAmount.Equal(money.MustParse("10.00")) {
+		t.Errorf("Expected balances 0.00 and 10.00, got %s and %s", a.BalanceAmount, b.BalanceAmount)
+	}
+
+	// Moving 50.00 off INV-A re-opens it and 40.00 of the payment returns to credit
+	err = payment.Allocate([]domain.PaymentAllocation{
+		{InvoiceID: a.ID, Amount: money.MustParse("50.00")},
+		{InvoiceID: b.ID, Amount: money.MustParse("40.00")},
+	}, invoices, "tester")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !a.PaidAmount.Equal(money.MustParse("50.00")) || !b.BalanceAmount.IsZero() || !payment.Unapplied.Equal(money.MustParse("60.00")) {
+		t.Errorf("Expected INV-A paid 50.00, INV-B settled and 60.00 unapplied, got %s, %s and %s", a.PaidAmount, b.BalanceAmount, payment.Unapplied)
+	}
+	entry := domain.CreditEntryForReallocation(payment, money.MustParse("20.00"))
+	if entry == nil || entry.EntryType != domain.CreditEntryReallocated || !entry.Amount.Equal(money.MustParse("40.00")) {
+		t.Errorf("Expected 40.00 to return to credit, got %+v", entry)
+	}
+
+	tests := []struct {
+		name        string
+		received    string
+		allocations []domain.PaymentAllocation
+	}{
+		{name: "more than is due", received: "500.00", allocations: []domain.PaymentAllocation{{InvoiceID: b.ID, Amount: money.MustParse("40.01")}}},
+		{name: "more than was received", received: "120.00", allocations: []domain.PaymentAllocation{{InvoiceID: a.ID, Amount: money.MustParse("100.00")}, {InvoiceID: b.ID, Amount: money.MustParse("40.00")}}},
+		{name: "same invoice twice", received: "500.00", allocations: []domain.PaymentAllocation{{InvoiceID: a.ID, Amount: money.MustParse("10.00")}, {InvoiceID: a.ID, Amount: money.MustParse("10.00")}}},
+		{name: "zero amount", received: "500.00", allocations: []domain.PaymentAllocation{{InvoiceID: a.ID, Amount: money.Zero}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &domain.Payment{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, Currency: "USD", Unapplied: money.MustParse(tt.received)}
+			fresh := map[uuid.UUID]*domain.Invoice{
+				a.ID: {ID: a.ID, OrganizationID: orgID, CustomerID: customerID, TotalAmount: a.TotalAmount},
+				b.ID: {ID: b.ID, OrganizationID: orgID, CustomerID: customerID, TotalAmount: b.TotalAmount},
+			}
+			if err := p.Allocate(tt.allocations, fresh, "tester"); !errors.Is(err, domain.ErrInvalidInput) {
+				t.Errorf("Expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+
+	other := &domain.Invoice{ID: uuid.New(), OrganizationID: orgID, CustomerID: uuid.New(), TotalAmount: money.MustParse("10.00")}
+	invoices[other.ID] = other
+	err = payment.Allocate([]domain.PaymentAllocation{{InvoiceID: other.ID, Amount: money.MustParse("10.00")}}, invoices, "tester")
+	if !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for another customer's invoice, got %v", err)
+	}
+}
+
+// TestPaymentRefund tests taking money back out of a payment
+func TestPaymentRefund(t *testing.T) {
+	orgID, customerID := uuid.New(), uuid.New()
+	a := &domain.Invoice{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, InvoiceNumber: "INV-A", TotalAmount: money.MustParse("100.00")}
+	b := &domain.Invoice{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, InvoiceNumber: "INV-B", TotalAmount: money.MustParse("40.00")}
+	invoices := map[uuid.UUID]*domain.Invoice{a.ID: a, b.ID: b}
+
+	payment := &domain.Payment{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, Currency: "USD", PaymentMethod: domain.PaymentMethodCheck, Unapplied: money.MustParse("150.00")}
+	err := payment.Allocate([]domain.PaymentAllocation{
+		{InvoiceID: a.ID, Amount: money.MustParse("100.00")},
+		{InvoiceID: b.ID, Amount: money.MustParse("40.00")},
+	}, invoices, "tester")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The 10.00 of credit goes first, the other 25.00 comes off INV-B
+	refund := &domain.PaymentRefund{RefundType: domain.RefundTypeRefund, Amount: money.MustParse("35.00")}
+	kept, err := payment.Refund(refund)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := payment.Allocate(kept, invoices, "tester"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !refund.FromCredit.Equal(money.MustParse("10.00")) || !b.BalanceAmount.Equal(money.MustParse("25.00")) || !a.BalanceAmount.IsZero() {
+		t.Errorf("Expected 10.00 from credit and INV-B re-opened by 25.00, got %s and %s", refund.FromCredit, b.BalanceAmount)
+	}
+	if payment.Status != domain.PaymentStatusPartiallyRefunded || !payment.Received().Equal(money.MustParse("115.00")) || !payment.Unapplied.IsZero() {
+		t.Errorf("Expected a partially refunded payment of 115.00, got %s of %s", payment.Status, payment.Received())
+	}
+	entry := domain.CreditEntryForRefund(payment, refund)
+	if entry == nil || entry.EntryType != domain.CreditEntryRefunded || !entry.Amount.Equal(money.MustParse("-10.00")) {
+		t.Errorf("Expected 10.00 of credit to be refunded, got %+v", entry)
+	}
+
+	if _, err := payment.Refund(&domain.PaymentRefund{RefundType: domain.RefundTypeRefund, Amount: money.MustParse("115.01")}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for a refund beyond the payment, got %v", err)
+	}
+
+	reversal := &domain.PaymentRefund{RefundType: domain.RefundTypeReversal}
+	kept, err = payment.Refund(reversal)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := payment.Allocate(kept, invoices, "tester"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reversal.Amount.Equal(money.MustParse("115.00")) || payment.Status != domain.PaymentStatusReversed || !payment.Received().IsZero() {
+		t.Errorf("Expected the remaining 115.00 to be reversed, got %s and %s", reversal.Amount, payment.Status)
+	}
+	if !a.PaidAmount.IsZero() || !b.PaidAmount.IsZero() || domain.CreditEntryForRefund(payment, reversal) != nil {
+		t.Errorf("Expected both invoices re-opened without touching credit, paid %s and %s", a.PaidAmount, b.PaidAmount)
+	}
+	if _, err := payment.Refund(&domain.PaymentRefund{RefundType: domain.RefundTypeReversal}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for a second reversal, got %v", err)
+	}
+
+	fromCredit := &domain.Payment{ID: uuid.New(), CustomerID: customerID, Currency: "USD", PaymentMethod: domain.PaymentMethodCredit, Amount: money.MustParse("20.00")}
+	reversal = &domain.PaymentRefund{RefundType: domain.RefundTypeReversal}
+	if _, err := fromCredit.Refund(reversal); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entry = domain.CreditEntryForRefund(fromCredit, reversal)
+	if entry == nil || entry.EntryType != domain.CreditEntryReversed || !entry.Amount.Equal(money.MustParse("20.00")) {
+		t.Errorf("Expected 20.00 of credit to be given back, got %+v", entry)
+	}
+}
@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+func TestResolveBillAmount(t *testing.T) {
+	round := func(a money.Amount) money.Amount { return a.Round("USD", domain.MoneyRounding) }
+	contract := money.MustParse("12345.67")
+
+	got, err := domain.ResolveBillAmount(contract, money.MustParse("30"), money.Zero, round)
+	if err != nil || !got.Equal(money.MustParse("3703.70")) {
+		t.Errorf("30%% of %s = %s, %v; want 3703.70", contract, got, err)
+	}
+	got, err = domain.ResolveBillAmount(money.Zero, money.Zero, money.MustParse("500.00"), round)
+	if err != nil || !got.Equal(money.MustParse("500.00")) {
+		t.Errorf("fixed amount without a job = %s, %v; want 500.00", got, err)
+	}
+
+	tests := []struct {
+		name            string
+		contract        money.Amount
+		percent, amount money.Amount
+	}{
+		{"both given", contract, money.MustParse("10"), money.MustParse("100.00")},
+		{"neither given", contract, money.Zero, money.Zero},
+		{"over 100 percent", contract, money.MustParse("120"), money.Zero},
+		{"negative amount", contract, money.Zero, money.MustParse("-5.00")},
+		{"percentage without a job", money.Zero, money.MustParse("10"), money.Zero},
+	}
+	for _, tt := range tests {
+		if _, err := domain.ResolveBillAmount(tt.contract, tt.percent, tt.amount, round); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+}
+
+func TestCheckBillable(t *testing.T) {
+	contract := money.MustParse("1000.00")
+	if err := domain.CheckBillable(contract, money.MustParse("600.00"), money.MustParse("400.00")); err != nil {
+		t.Errorf("billing the rest error = %v", err)
+	}
+	if err := domain.CheckBillable(contract, money.MustParse("600.00"), money.MustParse("400.01")); !errors.Is(err, domain.ErrOverBilling) {
+		t.Errorf("billing past the total error = %v, want ErrOverBilling", err)
+	}
+	if err := domain.CheckBillable(contract, contract, money.MustParse("0.01")); !errors.Is(err, domain.ErrOverBilling) {
+		t.Errorf("billing a job billed in full error = %v, want ErrOverBilling", err)
+	}
+}
+
+func TestAllocateDeposits(t *testing.T) {
+	first := domain.ProgressBill{ID: uuid.New(), Held: money.MustParse("300.00"), Applied: money.MustParse("100.00")}
+	unpaid := domain.ProgressBill{ID: uuid.New(), Amount: money.MustParse("500.00")}
+	second := domain.ProgressBill{ID: uuid.New(), Held: money.MustParse("250.00")}
+
+	taken, total := domain.AllocateDeposits([]domain.ProgressBill{first, unpaid, second}, money.MustParse("320.00"))
+	if !total.Equal(money.MustParse("320.00")) {
+		t.Errorf("total = %s, want 320.00", total)
+	}
+	if !taken[first.ID].Equal(money.MustParse("200.00")) || !taken[second.ID].Equal(money.MustParse("120.00")) {
+		t.Errorf("taken = %v, want 200.00 from the first deposit and 120.00 from the second", taken)
+	}
+	if _, ok := taken[unpaid.ID]; ok {
+		t.Error("an unpaid deposit was applied")
+	}
+
+	// A final invoice smaller than the deposits leaves the rest held
+	_, total = domain.AllocateDeposits([]domain.ProgressBill{second}, money.MustParse("50.00"))
+	if !total.Equal(money.MustParse("50.00")) {
+		t.Errorf("total = %s, want 50.00", total)
+	}
+}
+
+func TestProgressBillMatches(t *testing.T) {
+	estimateID, workOrderID := uuid.New(), uuid.New()
+	deposit := domain.ProgressBill{SourceType: domain.BillingSourceEstimate, SourceID: &estimateID}
+	retainer := domain.ProgressBill{}
+
+	if !deposit.Matches(workOrderID, estimateID) {
+		t.Error("a deposit on the estimate does not apply to its work order's invoice")
+	}
+	if deposit.Matches(workOrderID) || deposit.Matches() {
+		t.Error("a deposit applies to an invoice of another job")
+	}
+	if !retainer.Matches() {
+		t.Error("a retainer does not apply to every invoice of the customer")
+	}
+}
+
+func TestDepositCreditEntries(t *testing.T) {
+	orgID, customerID := uuid.New(), uuid.New()
+	deposit := &domain.Invoice{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, InvoiceNumber: "INV-D", Kind: domain.InvoiceKindDeposit, TotalAmount: money.MustParse("500.00")}
+	standard := &domain.Invoice{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, InvoiceNumber: "INV-S", Kind: domain.InvoiceKindStandard, TotalAmount: money.MustParse("200.00")}
+	invoices := map[uuid.UUID]*domain.Invoice{deposit.ID: deposit, standard.ID: standard}
+
+	payment := &domain.Payment{ID: uuid.New(), OrganizationID: orgID, CustomerID: customerID, Currency: "USD", Unapplied: money.MustParse("700.00")}
+	err := payment.Allocate([]domain.PaymentAllocation{
+		{InvoiceID: deposit.ID, Amount: money.MustParse("500.00")},
+		{InvoiceID: standard.ID, Amount: money.MustParse("200.00")},
+	}, invoices, "tester")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Only what is paid on the deposit invoice is held as credit
+	entries := domain.DepositCreditEntries(payment, nil, []*domain.Invoice{deposit, standard})
+	if len(entries) != 1 || entries[0].EntryType != domain.CreditEntryDeposit || !entries[0].Amount.Equal(money.MustParse("500.00")) || *entries[0].InvoiceID != deposit.ID {
+		t.Fatalf("Expected 500.00 held for INV-D, got %+v", entries)
+	}
+
+	// Moving 200.00 off the deposit invoice takes it back out of credit
+	previous := append([]domain.PaymentAllocation(nil), payment.Allocations...)
+	err = payment.Allocate([]domain.PaymentAllocation{
+		{InvoiceID: deposit.ID, Amount: money.MustParse("300.00")},
+		{InvoiceID: standard.ID, Amount: money.MustParse("200.00")},
+	}, invoices, "tester")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entries = domain.DepositCreditEntries(payment, previous, []*domain.Invoice{deposit, standard})
+	if len(entries) != 1 || !entries[0].Amount.Equal(money.MustParse("-200.00")) {
+		t.Errorf("Expected -200.00 on the deposit, got %+v", entries)
+	}
+
+	// Unchanged allocations move nothing
+	if entries := domain.DepositCreditEntries(payment, payment.Allocations, []*domain.Invoice{deposit, standard}); len(entries) != 0 {
+		t.Errorf("Expected no entries, got %+v", entries)
+	}
+}
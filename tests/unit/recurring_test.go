@@ -0,0 +1,170 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/cron"
+)
+
+// TestCron_Parse tests which expressions the cron parser accepts
+func TestCron_Parse(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "every minute", expr: "* * * * *"},
+		{name: "first of the month at six", expr: "0 6 1 * *"},
+		{name: "steps, ranges and lists", expr: "*/15 8-17 * * 1-5"},
+		{name: "list of days", expr: "0 0 1,15 * *"},
+		{name: "step from a value", expr: "5/20 * * * *"},
+		{name: "too few fields", expr: "0 6 1 *", wantErr: true},
+		{name: "too many fields", expr: "0 6 1 * * 2026", wantErr: true},
+		{name: "minute out of range", expr: "60 * * * *", wantErr: true},
+		{name: "day of month zero", expr: "0 0 0 * *", wantErr: true},
+		{name: "reversed range", expr: "0 17-8 * * *", wantErr: true},
+		{name: "zero step", expr: "*/0 * * * *", wantErr: true},
+		{name: "not a number", expr: "0 six * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cron.Parse(tt.expr)
+			if tt.wantErr && err == nil {
+				t.Errorf("Expected an error for %q", tt.expr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Unexpected error for %q: %v", tt.expr, err)
+			}
+		})
+	}
+}
+
+// TestCron_Next tests the first matching time after a given time
+func TestCron_Next(t *testing.T) {
+	at := func(month time.Month, d, hour, min int) time.Time {
+		return time.Date(2026, month, d, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{name: "later the same day", expr: "0 6 * * *", from: at(time.March, 10, 5, 30), want: at(time.March, 10, 6, 0)},
+		{name: "strictly after a match", expr: "0 6 * * *", from: at(time.March, 10, 6, 0), want: at(time.March, 11, 6, 0)},
+		{name: "seconds are dropped", expr: "* * * * *", from: at(time.March, 10, 6, 0).Add(30 * time.Second), want: at(time.March, 10, 6, 1)},
+		{name: "next month", expr: "0 0 1 * *", from: at(time.January, 15, 0, 0), want: at(time.February, 1, 0, 0)},
+		{name: "skips months without the day", expr: "0 0 31 * *", from: at(time.February, 1, 0, 0), want: at(time.March, 31, 0, 0)},
+		{name: "weekdays only", expr: "0 9 * * 1-5", from: at(time.October, 16, 10, 0), want: at(time.October, 19, 9, 0)},
+		{name: "either day field matches", expr: "0 0 1 * 0", from: at(time.October, 2, 0, 0), want: at(time.October, 4, 0, 0)},
+		{name: "steps within the hour", expr: "*/15 * * * *", from: at(time.March, 10, 6, 16), want: at(time.March, 10, 6, 30)},
+		{name: "never matches", expr: "0 0 30 2 *", from: at(time.March, 10, 0, 0), want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := cron.Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestRecurringProfile_Occurrence tests the n-th scheduled date per frequency
+func TestRecurringProfile_Occurrence(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency domain.RecurringFrequency
+		cronExpr  string
+		start     time.Time
+		n         int
+		want      time.Time
+	}{
+		{name: "first occurrence is the start date", frequency: domain.RecurringMonthly, start: day(time.January, 15), n: 0, want: day(time.January, 15)},
+		{name: "weekly", frequency: domain.RecurringWeekly, start: day(time.January, 1), n: 3, want: day(time.January, 22)},
+		{name: "monthly from the 31st clamps to short months", frequency: domain.RecurringMonthly, start: day(time.January, 31), n: 1, want: day(time.February, 28)},
+		{name: "monthly returns to the 31st after a short month", frequency: domain.RecurringMonthly, start: day(time.January, 31), n: 2, want: day(time.March, 31)},
+		{name: "quarterly", frequency: domain.RecurringQuarterly, start: day(time.January, 31), n: 1, want: day(time.April, 30)},
+		{name: "annual", frequency: domain.RecurringAnnual, start: day(time.March, 1), n: 1, want: time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "custom may start on the start date", frequency: domain.RecurringCustom, cronExpr: "0 0 * * 1", start: day(time.January, 5), n: 0, want: day(time.January, 5)},
+		{name: "custom counts matches from the start date", frequency: domain.RecurringCustom, cronExpr: "0 0 * * 1", start: day(time.January, 1), n: 2, want: day(time.January, 19)},
+		{name: "invalid custom expression has no occurrence", frequency: domain.RecurringCustom, cronExpr: "bad", start: day(time.January, 1), n: 0, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &domain.RecurringProfile{Frequency: tt.frequency, CronExpression: tt.cronExpr, StartDate: tt.start}
+			if got := p.Occurrence(tt.n); !got.Equal(tt.want) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestRecurringProfile_CatchUp tests the occurrences a scheduler generates
+// after being down for a while, and that the profile completes at its end date
+func TestRecurringProfile_CatchUp(t *testing.T) {
+	end := day(time.April, 30)
+	p := &domain.RecurringProfile{
+		Frequency: domain.RecurringMonthly,
+		StartDate: day(time.January, 31),
+		EndDate:   &end,
+		Status:    domain.RecurringProfileActive,
+	}
+	p.ScheduleNext()
+
+	// Same loop as the scheduler: generate every occurrence due at now
+	now := day(time.March, 31)
+	var generated []time.Time
+	for p.NextRunAt != nil && !p.NextRunAt.After(now) {
+		generated = append(generated, *p.NextRunAt)
+		p.OccurrenceCount++
+		p.ScheduleNext()
+	}
+
+	want := []time.Time{day(time.January, 31), day(time.February, 28), day(time.March, 31)}
+	if len(generated) != len(want) {
+		t.Fatalf("Expected %d occurrences, got %v", len(want), generated)
+	}
+	for i := range want {
+		if !generated[i].Equal(want[i]) {
+			t.Errorf("Occurrence %d: expected %s, got %s", i, want[i], generated[i])
+		}
+	}
+	if p.NextRunAt == nil || !p.NextRunAt.Equal(day(time.April, 30)) {
+		t.Fatalf("Expected next run on April 30, got %v", p.NextRunAt)
+	}
+
+	// The occurrence after the end date completes the profile
+	p.OccurrenceCount++
+	p.ScheduleNext()
+	if p.NextRunAt != nil || p.Status != domain.RecurringProfileCompleted {
+		t.Errorf("Expected the profile to complete, got next run %v and status %s", p.NextRunAt, p.Status)
+	}
+}
+
+// TestRecurringProfile_SkipMissed tests that a resumed profile skips what it
+// missed instead of back-filling it
+func TestRecurringProfile_SkipMissed(t *testing.T) {
+	p := &domain.RecurringProfile{
+		Frequency: domain.RecurringWeekly,
+		StartDate: day(time.January, 1),
+		Status:    domain.RecurringProfileActive,
+	}
+	p.SkipMissed(day(time.January, 20))
+
+	if p.NextRunAt == nil || !p.NextRunAt.Equal(day(time.January, 22)) {
+		t.Fatalf("Expected next run on January 22, got %v", p.NextRunAt)
+	}
+	if p.OccurrenceCount != 3 {
+		t.Errorf("Expected 3 skipped occurrences, got %d", p.OccurrenceCount)
+	}
+}
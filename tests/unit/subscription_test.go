@@ -0,0 +1,263 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+func upTo(q float64) *float64 { return &q }
+
+func tieredVisits(pricing domain.PricingModel) *domain.PlanComponent {
+	return &domain.PlanComponent{
+		Metric:  "site_visits",
+		Name:    "Site visits",
+		Pricing: pricing,
+		Tiers: domain.PriceTiers{
+			{UpTo: upTo(10), UnitPrice: money.MustParse("5.00")},
+			{UpTo: upTo(50), UnitPrice: money.MustParse("4.00"), FlatFee: money.MustParse("20.00")},
+			{UnitPrice: money.MustParse("3.00")},
+		},
+	}
+}
+
+func TestPlanComponentRate(t *testing.T) {
+	graduated := tieredVisits(domain.PricingGraduated).Rate(60)
+	want := []struct {
+		qty   float64
+		price string
+	}{{10, "5.00"}, {40, "4.00"}, {10, "3.00"}}
+	if len(graduated) != len(want) {
+		t.Fatalf("graduated Rate(60) = %+v, want 3 tiers", graduated)
+	}
+	for i, w := range want {
+		if graduated[i].Tier != i+1 || graduated[i].Quantity != w.qty || !graduated[i].UnitPrice.Equal(money.MustParse(w.price)) {
+			t.Errorf("graduated tier %d = %+v, want %v at %s", i+1, graduated[i], w.qty, w.price)
+		}
+	}
+	if !graduated[1].FlatFee.Equal(money.MustParse("20.00")) {
+		t.Errorf("graduated tier 2 flat fee = %s, want 20.00", graduated[1].FlatFee)
+	}
+
+	volume := tieredVisits(domain.PricingVolume)
+	if got := volume.Rate(60); len(got) != 1 || got[0].Tier != 3 || got[0].Quantity != 60 {
+		t.Errorf("volume Rate(60) = %+v, want all 60 in tier 3", got)
+	}
+	if got := volume.Rate(10); len(got) != 1 || got[0].Tier != 1 {
+		t.Errorf("volume Rate(10) = %+v, want tier 1 up to its limit", got)
+	}
+	if got := volume.Rate(0); got != nil {
+		t.Errorf("Rate(0) = %+v, want nothing charged", got)
+	}
+
+	flat := &domain.PlanComponent{Metric: "hours", Pricing: domain.PricingFlat, Tiers: domain.PriceTiers{{UnitPrice: money.MustParse("2.50")}}}
+	if got := flat.Rate(3.333); len(got) != 1 || got[0].Quantity != 3.33 {
+		t.Errorf("flat Rate(3.333) = %+v, want 3.33 units", got)
+	}
+}
+
+func TestPriceTiersValidate(t *testing.T) {
+	if err := tieredVisits(domain.PricingGraduated).Tiers.Validate(domain.PricingGraduated); err != nil {
+		t.Errorf("valid tiers: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		model domain.PricingModel
+		tiers domain.PriceTiers
+	}{
+		{"flat with two tiers", domain.PricingFlat, domain.PriceTiers{{UpTo: upTo(5)}, {}}},
+		{"no tiers", domain.PricingVolume, nil},
+		{"last tier limited", domain.PricingVolume, domain.PriceTiers{{UpTo: upTo(5)}}},
+		{"unlimited tier before the last", domain.PricingGraduated, domain.PriceTiers{{}, {}}},
+		{"limits not increasing", domain.PricingGraduated, domain.PriceTiers{{UpTo: upTo(10)}, {UpTo: upTo(10)}, {}}},
+		{"negative price", domain.PricingFlat, domain.PriceTiers{{UnitPrice: money.MustParse("-1.00")}}},
+		{"unknown model", "stairstep", domain.PriceTiers{{}}},
+	}
+	for _, tt := range tests {
+		if err := tt.tiers.Validate(tt.model); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+}
+
+func TestSubscriptionPlanValidate(t *testing.T) {
+	plan := &domain.SubscriptionPlan{Code: "BASIC", Name: "Basic", Interval: domain.RecurringMonthly, BaseFee: money.MustParse("30.00")}
+	if err := plan.Validate(); err != nil {
+		t.Fatalf("valid plan: %v", err)
+	}
+
+	plan.BaseFee = money.Zero
+	if err := plan.Validate(); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("plan without fee or components: error = %v, want ErrInvalidInput", err)
+	}
+	plan.Components = []domain.PlanComponent{*tieredVisits(domain.PricingVolume), *tieredVisits(domain.PricingGraduated)}
+	if err := plan.Validate(); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("metric priced twice: error = %v, want ErrInvalidInput", err)
+	}
+	plan.Components = plan.Components[:1]
+	plan.Interval = "daily"
+	if err := plan.Validate(); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("unknown interval: error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestSubscriptionPeriodsAnchorOnStartDate(t *testing.T) {
+	plan := &domain.SubscriptionPlan{ID: uuid.New(), Code: "BASIC", Interval: domain.RecurringMonthly, Active: true}
+	sub := &domain.Subscription{}
+	if err := sub.Begin(plan, time.Date(2026, 1, 31, 14, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if !sub.CurrentPeriodStart.Equal(day(1, 31)) || !sub.CurrentPeriodEnd.Equal(day(2, 28)) {
+		t.Fatalf("first period = %s to %s, want January 31 to February 28", sub.CurrentPeriodStart, sub.CurrentPeriodEnd)
+	}
+
+	sub.AdvancePeriod(plan.Interval)
+	sub.AdvancePeriod(plan.Interval)
+	if !sub.CurrentPeriodStart.Equal(day(3, 31)) || !sub.CurrentPeriodEnd.Equal(day(4, 30)) || sub.PeriodCount != 2 {
+		t.Errorf("third period = %s to %s (count %d), want March 31 to April 30", sub.CurrentPeriodStart, sub.CurrentPeriodEnd, sub.PeriodCount)
+	}
+
+	plan.Active = false
+	if err := (&domain.Subscription{}).Begin(plan, day(1, 1)); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("Begin on an inactive plan: error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestSubscriptionPlanChangeIsProrated(t *testing.T) {
+	orgID := uuid.New()
+	basic := &domain.SubscriptionPlan{
+		ID: uuid.New(), OrganizationID: orgID, Code: "BASIC", Name: "Basic", Currency: "USD",
+		Interval: domain.RecurringMonthly, BaseFee: money.MustParse("30.00"), Active: true,
+		Components: []domain.PlanComponent{{Metric: "site_visits", Name: "Site visits", Pricing: domain.PricingFlat,
+			Tiers: domain.PriceTiers{{UnitPrice: money.MustParse("2.00")}}}},
+	}
+	pro := &domain.SubscriptionPlan{
+		ID: uuid.New(), OrganizationID: orgID, Code: "PRO", Name: "Pro", Currency: "USD",
+		Interval: domain.RecurringMonthly, BaseFee: money.MustParse("60.00"), Active: true,
+		Components: []domain.PlanComponent{{Metric: "site_visits", Name: "Site visits", Pricing: domain.PricingGraduated,
+			Tiers: domain.PriceTiers{{UpTo: upTo(10)}, {UnitPrice: money.MustParse("1.50")}}}},
+	}
+	sub := &domain.Subscription{ID: uuid.New(), OrganizationID: orgID}
+	if err := sub.Begin(basic, day(9, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	euro := *pro
+	euro.ID, euro.Currency = uuid.New(), "EUR"
+	if _, err := sub.ChangePlan(basic, &euro, day(9, 21)); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("change to another currency: error = %v, want ErrInvalidInput", err)
+	}
+	if _, err := sub.ChangePlan(basic, pro, day(10, 1)); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("change after the period: error = %v, want ErrInvalidInput", err)
+	}
+
+	change, err := sub.ChangePlan(basic, pro, time.Date(2026, 9, 21, 9, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.PlanID != pro.ID || !change.EffectiveAt.Equal(day(9, 21)) {
+		t.Fatalf("after change: plan %s effective %s, want pro from September 21", sub.PlanID, change.EffectiveAt)
+	}
+
+	segments := sub.PeriodSegments([]domain.SubscriptionPlanChange{*change})
+	if len(segments) != 2 || segments[0].PlanID != basic.ID || !segments[0].End.Equal(day(9, 21)) ||
+		segments[1].PlanID != pro.ID || !segments[1].End.Equal(day(10, 1)) {
+		t.Fatalf("PeriodSegments() = %+v, want basic to September 21 and pro to October 1", segments)
+	}
+
+	usage := []domain.UsageRecord{
+		{Metric: "site_visits", Quantity: 5, OccurredAt: day(9, 10)},
+		{Metric: "site_visits", Quantity: 15, OccurredAt: day(9, 25)},
+		{Metric: "hours", Quantity: 8, OccurredAt: day(9, 25)},
+	}
+	plans := map[uuid.UUID]*domain.SubscriptionPlan{basic.ID: basic, pro.ID: pro}
+	charges, err := sub.PeriodCharges(segments, plans, usage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		plan  uuid.UUID
+		qty   float64
+		price string
+	}{
+		{basic.ID, 1, "20.00"}, // 20 of 30 days
+		{basic.ID, 5, "2.00"},
+		{pro.ID, 1, "20.00"}, // 10 of 30 days
+		{pro.ID, 5, "1.50"},  // The first 10 visits are free
+	}
+	if len(charges) != len(want) {
+		t.Fatalf("PeriodCharges() = %+v, want %d lines", charges, len(want))
+	}
+	for i, w := range want {
+		c := charges[i]
+		if c.Segment.PlanID != w.plan || c.Quantity != w.qty || !c.UnitPrice.Equal(money.MustParse(w.price)) {
+			t.Errorf("charge %d = %v x %s on %s, want %v x %s", i, c.Quantity, c.UnitPrice, c.Segment.PlanID, w.qty, w.price)
+		}
+	}
+}
+
+func TestSubscriptionCancelAndUsage(t *testing.T) {
+	plan := &domain.SubscriptionPlan{ID: uuid.New(), Code: "BASIC", Name: "Basic", Interval: domain.RecurringMonthly,
+		BaseFee: money.MustParse("30.00"), Active: true}
+	sub := &domain.Subscription{ID: uuid.New()}
+	if err := sub.Begin(plan, day(9, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, at := range []time.Time{day(9, 1), day(10, 2)} {
+		if err := sub.Cancel(at); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("Cancel(%s): error = %v, want ErrInvalidInput", at.Format("2006-01-02"), err)
+		}
+	}
+	if err := sub.Cancel(day(9, 15)); err != nil {
+		t.Fatal(err)
+	}
+	if !sub.ClosesAt().Equal(day(9, 15)) || !sub.IsFinalPeriod() {
+		t.Errorf("cancelled subscription closes at %s (final %v), want September 15", sub.ClosesAt(), sub.IsFinalPeriod())
+	}
+	if err := sub.Cancel(time.Time{}); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("second Cancel: error = %v, want ErrInvalidInput", err)
+	}
+
+	record, err := sub.NewUsage("site_visits", 2, day(9, 14), "", domain.UsageSourceAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.IdempotencyKey != record.ID.String() {
+		t.Errorf("usage without a key got key %q, want its ID", record.IdempotencyKey)
+	}
+	refused := []struct {
+		name string
+		qty  float64
+		at   time.Time
+	}{
+		{"before the period", 1, day(8, 31)},
+		{"after the subscription ends", 1, day(9, 15)},
+		{"no quantity", 0.001, day(9, 10)},
+	}
+	for _, tt := range refused {
+		if _, err := sub.NewUsage("site_visits", tt.qty, tt.at, "k", domain.UsageSourceAPI); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want ErrInvalidInput", tt.name, err)
+		}
+	}
+
+	segments := sub.PeriodSegments(nil)
+	charges, err := sub.PeriodCharges(segments, map[uuid.UUID]*domain.SubscriptionPlan{plan.ID: plan}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charges) != 1 || !charges[0].UnitPrice.Equal(money.MustParse("14.00")) {
+		t.Errorf("final period charges = %+v, want 14 of 30 days of the base fee", charges)
+	}
+
+	sub.AdvancePeriod(plan.Interval)
+	if sub.Status != domain.SubscriptionCancelled {
+		t.Errorf("status after the final period = %s, want cancelled", sub.Status)
+	}
+}
@@ -0,0 +1,89 @@
+package unit
+
+import (
+	"testing"
+
+	"erp-billing-service/internal/domain"
+	"erp-billing-service/pkg/money"
+
+	"github.com/google/uuid"
+)
+
+func taxRate(code, rate string, compound bool) domain.TaxRate {
+	return domain.TaxRate{ID: uuid.New(), Code: code, Name: code, Rate: money.MustParse(rate), Compound: compound}
+}
+
+// TestCalculateTax tests exclusive, grouped, compound and inclusive taxes
+func TestCalculateTax(t *testing.T) {
+	round := func(a money.Amount) money.Amount { return a.Round("USD", domain.MoneyRounding) }
+
+	tests := []struct {
+		name      string
+		amount    string
+		rates     []domain.TaxRate
+		inclusive bool
+		wantNet   string
+		wantTaxes []string
+	}{
+		{
+			name:      "single exclusive rate",
+			amount:    "100.00",
+			rates:     []domain.TaxRate{taxRate("VAT", "10", false)},
+			wantNet:   "100.00",
+			wantTaxes: []string{"10.00"},
+		},
+		{
+			name:      "state plus county",
+			amount:    "100.00",
+			rates:     []domain.TaxRate{taxRate("STATE", "5", false), taxRate("COUNTY", "2", false)},
+			wantNet:   "100.00",
+			wantTaxes: []string{"5.00", "2.00"},
+		},
+		{
+			name:      "compound rate is charged on earlier taxes",
+			amount:    "100.00",
+			rates:     []domain.TaxRate{taxRate("GST", "5", false), taxRate("QST", "9.975", true)},
+			wantNet:   "100.00",
+			wantTaxes: []string{"5.00", "10.47"},
+		},
+		{
+			name:      "inclusive rate is backed out of the price",
+			amount:    "110.00",
+			rates:     []domain.TaxRate{taxRate("VAT", "10", false)},
+			inclusive: true,
+			wantNet:   "100.00",
+			wantTaxes: []string{"10.00"},
+		},
+		{
+			name:      "inclusive rounding difference goes to the tax",
+			amount:    "10.00",
+			rates:     []domain.TaxRate{taxRate("NYC", "8.875", false)},
+			inclusive: true,
+			wantNet:   "9.18",
+			wantTaxes: []string{"0.82"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount := money.MustParse(tt.amount)
+			net, taxes := domain.CalculateTax(amount, tt.rates, tt.inclusive, round)
+			if !net.Equal(money.MustParse(tt.wantNet)) {
+				t.Errorf("Expected net %s, got %s", tt.wantNet, net)
+			}
+			if len(taxes) != len(tt.wantTaxes) {
+				t.Fatalf("Expected %d taxes, got %d", len(tt.wantTaxes), len(taxes))
+			}
+			sum := net
+			for i, want := range tt.wantTaxes {
+				if !taxes[i].Amount.Equal(money.MustParse(want)) {
+					t.Errorf("Tax %s: expected %s, got %s", taxes[i].Rate.Code, want, taxes[i].Amount)
+				}
+				sum = sum.Add(taxes[i].Amount)
+			}
+			if tt.inclusive && !sum.Equal(amount) {
+				t.Errorf("Net plus taxes %s does not add up to %s", sum, amount)
+			}
+		})
+	}
+}
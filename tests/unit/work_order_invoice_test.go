@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/adapters/inbound/kafka"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// TestWorkOrderRM_ReserveForBilling tests which work orders can be reserved for a full invoice
+func TestWorkOrderRM_ReserveForBilling(t *testing.T) {
+	invoiceID := uuid.New()
+
+	tests := []struct {
+		name    string
+		wo      domain.WorkOrderRM
+		wantErr bool
+	}{
+		{name: "unbilled work order", wo: domain.WorkOrderRM{BillingStatus: domain.WorkOrderBillingUnbilled}},
+		{name: "work order synced without a billing status", wo: domain.WorkOrderRM{}},
+		{name: "already reserved", wo: domain.WorkOrderRM{BillingStatus: domain.WorkOrderBillingInvoiced}, wantErr: true},
+		{name: "linked to an invoice", wo: domain.WorkOrderRM{InvoiceID: &invoiceID}, wantErr: true},
+		{name: "billed through progress invoices", wo: domain.WorkOrderRM{BilledAmount: 250}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wo := tt.wo
+			err := wo.ReserveForBilling()
+			if tt.wantErr {
+				if !errors.Is(err, domain.ErrWorkOrderAlreadyInvoiced) {
+					t.Errorf("Expected ErrWorkOrderAlreadyInvoiced, got %v", err)
+				}
+				if wo.BillingStatus != tt.wo.BillingStatus {
+					t.Errorf("A failed reservation must not change the status, got %q", wo.BillingStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if wo.BillingStatus != domain.WorkOrderBillingInvoiced {
+				t.Errorf("Expected status %q, got %q", domain.WorkOrderBillingInvoiced, wo.BillingStatus)
+			}
+		})
+	}
+}
+
+// TestWorkOrderRM_BillingLifecycle walks a work order through reserve, create
+// and link, and through release when the invoice cannot be created
+func TestWorkOrderRM_BillingLifecycle(t *testing.T) {
+	wo := domain.WorkOrderRM{ID: uuid.New(), BillingStatus: domain.WorkOrderBillingUnbilled}
+
+	// Reserve, then create the invoice and link it
+	if err := wo.ReserveForBilling(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := wo.ReserveForBilling(); !errors.Is(err, domain.ErrWorkOrderAlreadyInvoiced) {
+		t.Fatalf("A second reservation must fail, got %v", err)
+	}
+	invoiceID := uuid.New()
+	wo.LinkInvoice(invoiceID)
+	if wo.InvoiceID == nil || *wo.InvoiceID != invoiceID {
+		t.Fatalf("Expected invoice %s, got %v", invoiceID, wo.InvoiceID)
+	}
+
+	// A work order update from its own service must not undo the billing
+	_, updates, err := kafka.ProjectWorkOrderUpdated(shared_events.WorkOrderUpdatedPayload{
+		WorkOrderID:   wo.ID.String(),
+		Status:        "completed",
+		BillingStatus: domain.WorkOrderBillingUnbilled,
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := updates["billing_status"]; ok {
+		t.Error("A work order update must not overwrite the billing status")
+	}
+	if err := wo.CheckBillable(); !errors.Is(err, domain.ErrWorkOrderAlreadyInvoiced) {
+		t.Errorf("An invoiced work order must not be billable, got %v", err)
+	}
+
+	// Releasing after a failed or voided invoice makes it billable again
+	wo.ReleaseBilling()
+	if wo.BillingStatus != domain.WorkOrderBillingUnbilled || wo.InvoiceID != nil {
+		t.Errorf("Expected an unbilled work order without invoice, got %q and %v", wo.BillingStatus, wo.InvoiceID)
+	}
+	if err := wo.ReserveForBilling(); err != nil {
+		t.Errorf("A released work order must be billable again, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"erp-billing-service/internal/adapters/inbound/kafka"
+	"erp-billing-service/internal/domain"
+
+	shared_events "github.com/efs/shared-events"
+	"github.com/google/uuid"
+)
+
+// TestProjectWorkOrderCreated tests the read model built from a created work order
+func TestProjectWorkOrderCreated(t *testing.T) {
+	woID, orgID := uuid.New(), uuid.New()
+	occurredAt := day(time.March, 1)
+
+	tests := []struct {
+		name     string
+		payload  shared_events.WorkOrderCreatedPayload
+		wantErr  bool
+		wantCust bool
+	}{
+		{
+			name:     "billing status from the event is ignored",
+			payload:  shared_events.WorkOrderCreatedPayload{WorkOrderID: woID.String(), OrganizationID: orgID.String(), CustomerID: uuid.NewString(), BillingStatus: domain.WorkOrderBillingInvoiced},
+			wantCust: true,
+		},
+		{
+			name:    "missing customer stays unset",
+			payload: shared_events.WorkOrderCreatedPayload{WorkOrderID: woID.String(), OrganizationID: orgID.String()},
+		},
+		{
+			name:    "invalid work order ID is rejected",
+			payload: shared_events.WorkOrderCreatedPayload{WorkOrderID: "not-a-uuid", OrganizationID: orgID.String()},
+			wantErr: true,
+		},
+		{
+			name:    "invalid organization ID is rejected",
+			payload: shared_events.WorkOrderCreatedPayload{WorkOrderID: woID.String(), OrganizationID: ""},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm, err := kafka.ProjectWorkOrderCreated(tt.payload, occurredAt)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rm.ID != woID || rm.OrganizationID != orgID {
+				t.Errorf("Expected work order %s in organization %s, got %s in %s", woID, orgID, rm.ID, rm.OrganizationID)
+			}
+			if rm.BillingStatus != domain.WorkOrderBillingUnbilled {
+				t.Errorf("Expected a new work order to be unbilled, got %q", rm.BillingStatus)
+			}
+			if rm.InvoiceID != nil || rm.BilledAmount != 0 {
+				t.Errorf("Expected no invoice or billed amount, got %v and %v", rm.InvoiceID, rm.BilledAmount)
+			}
+			if (rm.CustomerID != nil) != tt.wantCust {
+				t.Errorf("Expected customer set to be %v, got %v", tt.wantCust, rm.CustomerID)
+			}
+			if rm.CreatedAt == nil || !rm.CreatedAt.Equal(occurredAt) {
+				t.Errorf("Expected created at %s, got %v", occurredAt, rm.CreatedAt)
+			}
+		})
+	}
+}
+
+// TestProjectWorkOrderUpdated tests that updates never touch billing-owned columns
+func TestProjectWorkOrderUpdated(t *testing.T) {
+	woID := uuid.New()
+
+	tests := []struct {
+		name     string
+		payload  shared_events.WorkOrderUpdatedPayload
+		wantCols []string
+		wantErr  bool
+	}{
+		{
+			name:     "billing status from the event is ignored",
+			payload:  shared_events.WorkOrderUpdatedPayload{WorkOrderID: woID.String(), Status: "completed", BillingStatus: domain.WorkOrderBillingUnbilled},
+			wantCols: []string{"status", "updated_at"},
+		},
+		{
+			name:     "every work order field",
+			payload:  shared_events.WorkOrderUpdatedPayload{WorkOrderID: woID.String(), Summary: "Boiler service", Status: "open", GrandTotal: 120},
+			wantCols: []string{"summary", "status", "grand_total", "updated_at"},
+		},
+		{
+			name:    "invalid work order ID is rejected",
+			payload: shared_events.WorkOrderUpdatedPayload{WorkOrderID: "", Status: "open"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, updates, err := kafka.ProjectWorkOrderUpdated(tt.payload, day(time.March, 2))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if id != woID {
+				t.Errorf("Expected work order %s, got %s", woID, id)
+			}
+			if len(updates) != len(tt.wantCols) {
+				t.Errorf("Expected columns %v, got %v", tt.wantCols, updates)
+			}
+			for _, col := range tt.wantCols {
+				if _, ok := updates[col]; !ok {
+					t.Errorf("Expected column %s to be updated", col)
+				}
+			}
+			for _, col := range []string{"billing_status", "invoice_id", "billed_amount"} {
+				if _, ok := updates[col]; ok {
+					t.Errorf("Column %s is owned by billing and must not be updated", col)
+				}
+			}
+		})
+	}
+}